@@ -0,0 +1,28 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internalversion
+
+import (
+	"github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"k8s.io/apimachinery/pkg/conversion"
+)
+
+// Convert_internalversion_Target_To_v1alpha1_Target converts the target without its uid,
+// v1alpha1 addresses the resources by their name only.
+func Convert_internalversion_Target_To_v1alpha1_Target(in *Target, out *v1alpha1.Target, s conversion.Scope) error {
+	return autoConvert_internalversion_Target_To_v1alpha1_Target(in, out, s)
+}
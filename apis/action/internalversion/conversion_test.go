@@ -0,0 +1,86 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internalversion
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestConversion(t *testing.T) {
+	scheme := runtime.NewScheme()
+	err := v1alpha1.AddToScheme(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = AddToScheme(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := &v1alpha1.ResourcePatch{
+		Resource:           v1alpha1.GroupVersionResource{Version: "v1", Resource: "pods"},
+		Target:             v1alpha1.Target{Name: "a", Namespace: "default"},
+		DurationNanosecond: time.Second,
+		Method:             v1alpha1.PatchMethodCreate,
+		Template:           []byte(`{"metadata":{"name":"a"}}`),
+	}
+	in.APIVersion = v1alpha1.APIVersion
+	in.Kind = v1alpha1.ResourcePatchKind
+
+	internal := &ResourcePatch{}
+	err = scheme.Convert(in, internal, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ResourcePatch{
+		Resource:           GroupVersionResource{Version: "v1", Resource: "pods"},
+		Target:             Target{Name: "a", Namespace: "default"},
+		DurationNanosecond: time.Second,
+		Method:             PatchMethodCreate,
+		Template:           []byte(`{"metadata":{"name":"a"}}`),
+	}
+	if !reflect.DeepEqual(internal, want) {
+		t.Fatalf("want %#v, got %#v", want, internal)
+	}
+
+	out := &v1alpha1.ResourcePatch{}
+	err = scheme.Convert(internal.DeepCopy(), out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.TypeMeta = in.TypeMeta
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("want %#v, got %#v", in, out)
+	}
+}
+
+func TestDeepCopy(t *testing.T) {
+	in := &ResourcePatch{
+		Method:   PatchMethodPatch,
+		Template: []byte(`{"a":"b"}`),
+	}
+	out := in.DeepCopy()
+	out.Template[2] = 'c'
+	if string(in.Template) != `{"a":"b"}` {
+		t.Errorf("the copy shares the template: %s", in.Template)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internalversion
+
+import (
+	"encoding/json"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ResourcePatch is a change of a recording, to an object of the cluster at a duration since the start of the recording.
+type ResourcePatch struct {
+	// Resource represents the resource to be patched.
+	Resource GroupVersionResource
+	// Target represents the target of the ResourcePatch.
+	Target Target
+	// DurationNanosecond represents the duration of the patch in nanoseconds, since the start of the recording.
+	DurationNanosecond time.Duration
+	// Method represents the method of the patch.
+	Method PatchMethod
+	// Template is the object to create, or the strategic merge patch to apply.
+	// Of a delete, it is the object as it was deleted, if known, which the delete does not need.
+	Template json.RawMessage
+	// Checkpoint is whether the patch is the whole object as of it, written after so many patches to the object
+	// for the readers following the object to start from it rather than from the patches before it.
+	// It changes nothing of the object, and the readers not knowing it apply it as any other patch.
+	Checkpoint bool
+}
+
+// PatchMethod defines the method used to patch a resource.
+type PatchMethod string
+
+const (
+	// PatchMethodCreate means that the resource will be created by create.
+	PatchMethodCreate PatchMethod = "create"
+	// PatchMethodPatch means that the resource will be patched by patch.
+	PatchMethodPatch PatchMethod = "patch"
+	// PatchMethodDelete means that the resource will be deleted by delete.
+	PatchMethodDelete PatchMethod = "delete"
+)
+
+// Target is a struct that represents the target of the ResourcePatch.
+type Target struct {
+	// Name represents the name of the resource to be patched.
+	Name string
+	// Namespace represents the namespace of the resource to be patched.
+	Namespace string
+	// UID represents the uid of the resource to be patched, empty if it is unknown.
+	UID types.UID
+}
+
+// GroupVersionResource is a struct that represents the group version resource.
+type GroupVersionResource struct {
+	// Group represents the group of the resource.
+	Group string
+	// Version represents the version of the resource.
+	Version string
+	// Resource represents the type of the resource.
+	Resource string
+}
@@ -0,0 +1,26 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +k8s:deepcopy-gen=package
+// +groupName=action.kwok.x-k8s.io
+
+// Package v1alpha1 implements the v1alpha1 apiVersion of the actions of the recordings,
+// the ResourcePatch documents of kwokctl snapshot record following the objects of the cluster,
+// which kectl puts, builds and inverts.
+//
+// It only depends on k8s.io/apimachinery and sigs.k8s.io/yaml, for the tools reading the recordings not to import kectl.
+// The fields are only added to within a version, the documents of a version are read by all the later releases.
+package v1alpha1
@@ -0,0 +1,179 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecoder(t *testing.T) {
+	recording := `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: default
+---
+# only a comment
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: namespaces
+target:
+  name: default
+durationNanosecond: 1000000000
+method: patch
+template:
+  metadata:
+    labels:
+      a: b
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: namespaces
+target:
+  name: default
+durationNanosecond: 2000000000
+method: delete
+`
+	decoder := NewDecoder(strings.NewReader(recording))
+
+	rp, obj, err := decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp != nil || obj == nil || obj.GetKind() != "Namespace" {
+		t.Fatalf("want the Namespace, got %v, %v", rp, obj)
+	}
+
+	rp, _, err = decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp == nil || rp.Method != PatchMethodPatch || rp.DurationNanosecond != time.Second || string(rp.Template) != `{"metadata":{"labels":{"a":"b"}}}` {
+		t.Fatalf("unexpected patch %#v", rp)
+	}
+	if gr := rp.Resource.GroupResource(); gr.String() != "namespaces" {
+		t.Errorf("want namespaces, got %s", gr)
+	}
+
+	rp, _, err = decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp == nil || rp.Method != PatchMethodDelete || rp.DurationNanosecond != 2*time.Second {
+		t.Fatalf("unexpected patch %#v", rp)
+	}
+
+	_, _, err = decoder.Decode()
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("want EOF, got %v", err)
+	}
+}
+
+func TestDecoderInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		recording string
+		wantErr   string
+	}{
+		{
+			name:      "without target",
+			recording: "apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource: {version: v1, resource: pods}\nmethod: delete\n",
+			wantErr:   "without a resource or a target",
+		},
+		{
+			name:      "without template",
+			recording: "apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource: {version: v1, resource: pods}\ntarget: {name: a}\nmethod: create\n",
+			wantErr:   "without a template",
+		},
+		{
+			name:      "unsupported method",
+			recording: "apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource: {version: v1, resource: pods}\ntarget: {name: a}\nmethod: update\n",
+			wantErr:   "unsupported resource patch method",
+		},
+		{
+			name:      "checkpoint of a delete",
+			recording: "apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource: {version: v1, resource: pods}\ntarget: {name: a}\nmethod: delete\ncheckpoint: true\n",
+			wantErr:   "which only a patch is",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := NewDecoder(strings.NewReader(tt.recording)).Decode()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("want error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	rp := &ResourcePatch{
+		Resource:           GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		Target:             Target{Name: "a", Namespace: "default"},
+		DurationNanosecond: time.Millisecond,
+		Method:             PatchMethodPatch,
+		Template:           []byte(`{"spec":{"replicas":2}}`),
+	}
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(rp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp.APIVersion != "" || rp.Kind != "" {
+		t.Errorf("the encoded ResourcePatch is changed: %#v", rp.TypeMeta)
+	}
+
+	want := `---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+durationNanosecond: 1000000
+kind: ResourcePatch
+method: patch
+resource:
+  group: apps
+  resource: deployments
+  version: v1
+target:
+  name: a
+  namespace: default
+template:
+  spec:
+    replicas: 2
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("want\n%s\ngot\n%s", want, got)
+	}
+
+	got, _, err := NewDecoder(buf).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.TypeMeta = rp.TypeMeta
+	if string(got.Template) != string(rp.Template) || got.Resource != rp.Resource || got.Target != rp.Target ||
+		got.DurationNanosecond != rp.DurationNanosecond || got.Method != rp.Method {
+		t.Errorf("want %#v, got %#v", rp, got)
+	}
+}
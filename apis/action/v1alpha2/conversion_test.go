@@ -0,0 +1,85 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/kectl/apis/action/internalversion"
+	"github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestConversion(t *testing.T) {
+	scheme := runtime.NewScheme()
+	err := v1alpha1.AddToScheme(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = AddToScheme(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = internalversion.AddToScheme(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := &ResourcePatch{
+		Resource:           GroupVersionResource{Version: "v1", Resource: "pods"},
+		Target:             Target{Name: "a", Namespace: "default", UID: "0c2f"},
+		DurationNanosecond: time.Second,
+		Method:             PatchMethodPatch,
+		Template:           []byte(`{"metadata":{"labels":{"a":"b"}}}`),
+	}
+
+	internal := &internalversion.ResourcePatch{}
+	err = scheme.Convert(in, internal, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if internal.Target.UID != "0c2f" {
+		t.Fatalf("want the uid 0c2f, got %q", internal.Target.UID)
+	}
+
+	out := &ResourcePatch{}
+	err = scheme.Convert(internal.DeepCopy(), out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("want %#v, got %#v", in, out)
+	}
+
+	old := &v1alpha1.ResourcePatch{}
+	err = scheme.Convert(internal.DeepCopy(), old, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &v1alpha1.ResourcePatch{
+		Resource:           v1alpha1.GroupVersionResource{Version: "v1", Resource: "pods"},
+		Target:             v1alpha1.Target{Name: "a", Namespace: "default"},
+		DurationNanosecond: time.Second,
+		Method:             v1alpha1.PatchMethodPatch,
+		Template:           []byte(`{"metadata":{"labels":{"a":"b"}}}`),
+	}
+	if !reflect.DeepEqual(old, want) {
+		t.Fatalf("want %#v, got %#v", want, old)
+	}
+}
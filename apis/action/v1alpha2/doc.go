@@ -0,0 +1,27 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +k8s:deepcopy-gen=package
+// +k8s:conversion-gen=github.com/wzshiming/kectl/apis/action/internalversion
+// +groupName=action.kwok.x-k8s.io
+
+// Package v1alpha2 implements the v1alpha2 apiVersion of the actions of the recordings,
+// which addresses the objects of the ResourcePatches by their uid as well as their name,
+// for the changes to an object deleted and created again with the same name to be told apart.
+//
+// A recording of v1alpha2 starts with a Recording document, which tells its readers the version of the documents following it,
+// while a recording without one is of v1alpha1, as of `kwokctl snapshot record`.
+package v1alpha2
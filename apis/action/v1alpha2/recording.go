@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// Decoder reads the documents of a recording in YAML or JSON, the objects followed by the ResourcePatches.
+// The Recording document it starts with is skipped.
+type Decoder struct {
+	decoder *utilyaml.YAMLOrJSONDecoder
+}
+
+// NewDecoder returns a decoder reading the documents of the recording from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		decoder: utilyaml.NewYAMLOrJSONDecoder(r, 4096),
+	}
+}
+
+// Decode reads the next document, either a ResourcePatch or another object. It returns io.EOF once all are read.
+func (d *Decoder) Decode() (*ResourcePatch, *unstructured.Unstructured, error) {
+	for {
+		var raw json.RawMessage
+		err := d.decoder.Decode(&raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+			// The empty documents, such as the ones of comments only, are skipped
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		err = obj.UnmarshalJSON(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		if obj.GetAPIVersion() == APIVersion && obj.GetKind() == RecordingKind {
+			continue
+		}
+		if obj.GetAPIVersion() != APIVersion || obj.GetKind() != ResourcePatchKind {
+			return nil, obj, nil
+		}
+
+		rp := &ResourcePatch{}
+		err = json.Unmarshal(raw, rp)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = rp.Validate()
+		if err != nil {
+			return nil, nil, err
+		}
+		return rp, nil, nil
+	}
+}
+
+// Validate checks the ResourcePatch has what its method needs.
+func (rp *ResourcePatch) Validate() error {
+	if rp.Resource.Resource == "" || rp.Target.Name == "" {
+		return errors.New("resource patch without a resource or a target")
+	}
+	switch rp.Method {
+	case PatchMethodCreate, PatchMethodPatch:
+		if len(rp.Template) == 0 {
+			return fmt.Errorf("resource patch to %s %s without a template", rp.Method, rp.Target.Name)
+		}
+	case PatchMethodDelete:
+	default:
+		return fmt.Errorf("unsupported resource patch method %q", rp.Method)
+	}
+	if rp.Checkpoint && rp.Method != PatchMethodPatch {
+		return fmt.Errorf("resource patch to %s %s as a checkpoint, which only a patch is", rp.Method, rp.Target.Name)
+	}
+	return nil
+}
+
+// Encoder writes the documents of a recording in YAML, after the Recording document it starts with.
+type Encoder struct {
+	w       io.Writer
+	started bool
+}
+
+// NewEncoder returns an encoder writing the documents of a recording to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w: w,
+	}
+}
+
+// Encode writes the object as a document, a ResourcePatch with its apiVersion and kind even if they are not set.
+// The Recording document is written before the first one, unless the first one is the Recording.
+func (e *Encoder) Encode(obj interface{}) error {
+	if _, ok := obj.(*Recording); !ok && !e.started {
+		e.started = true
+		err := e.Encode(&Recording{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: APIVersion,
+				Kind:       RecordingKind,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	e.started = true
+	if rp, ok := obj.(*ResourcePatch); ok && (rp.APIVersion == "" || rp.Kind == "") {
+		rp = rp.DeepCopy()
+		rp.APIVersion = APIVersion
+		rp.Kind = ResourcePatchKind
+		obj = rp
+	}
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, "---\n%s", data)
+	return err
+}
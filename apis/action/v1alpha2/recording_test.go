@@ -0,0 +1,93 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestEncoder(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	encoder := NewEncoder(buf)
+	err := encoder.Encode(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": "default", "uid": "0c2f"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := &ResourcePatch{
+		Resource:           GroupVersionResource{Version: "v1", Resource: "namespaces"},
+		Target:             Target{Name: "default", UID: "0c2f"},
+		DurationNanosecond: time.Second,
+		Method:             PatchMethodDelete,
+	}
+	err = encoder.Encode(rp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `---
+apiVersion: action.kwok.x-k8s.io/v1alpha2
+kind: Recording
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: default
+  uid: 0c2f
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha2
+durationNanosecond: 1000000000
+kind: ResourcePatch
+method: delete
+resource:
+  resource: namespaces
+  version: v1
+target:
+  name: default
+  uid: 0c2f
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("want\n%s\ngot\n%s", want, got)
+	}
+
+	decoder := NewDecoder(buf)
+	_, obj, err := decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj == nil || obj.GetKind() != "Namespace" {
+		t.Fatalf("want the Namespace after the Recording, got %v", obj)
+	}
+	got, _, err := decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Target != rp.Target || got.Method != rp.Method {
+		t.Fatalf("want %#v, got %#v", rp, got)
+	}
+	_, _, err = decoder.Decode()
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("want EOF, got %v", err)
+	}
+}
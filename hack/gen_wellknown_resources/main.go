@@ -55,6 +55,11 @@ func gen() error {
 		return err
 	}
 
+	serverVersion, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return err
+	}
+
 	var wellKnown []resource
 
 	for _, list := range resourceList {
@@ -119,6 +124,10 @@ type resource struct {
 }
 
 // Don't edit this file directly. It is generated by hack/gen_wellknown_resources
+
+// KubeVersion is the version of Kubernetes the resources are discovered from.
+const KubeVersion = ` + fmt.Sprintf("%q", serverVersion.GitVersion) + `
+
 var resources = ` + out
 
 	formated, err := format.Source([]byte(out))
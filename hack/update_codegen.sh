@@ -0,0 +1,54 @@
+#!/usr/bin/env bash
+# Copyright 2024 The Kubernetes Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+DIR="$(dirname "${BASH_SOURCE[0]}")"
+
+ROOT_DIR="$(realpath "${DIR}/..")"
+
+KUBE_VERSION=v0.31.3
+
+function deepcopy-gen() {
+  go run k8s.io/code-generator/cmd/deepcopy-gen@${KUBE_VERSION} "$@"
+}
+
+function conversion-gen() {
+  go run k8s.io/code-generator/cmd/conversion-gen@${KUBE_VERSION} "$@"
+}
+
+cd "${ROOT_DIR}/apis"
+
+rm -f \
+  ./action/internalversion/zz_generated.*.go \
+  ./action/v1alpha1/zz_generated.*.go \
+  ./action/v1alpha2/zz_generated.*.go
+
+echo "Generating deepcopy"
+deepcopy-gen \
+  ./action/internalversion/ \
+  ./action/v1alpha1/ \
+  ./action/v1alpha2/ \
+  --output-file zz_generated.deepcopy.go \
+  --go-header-file "${ROOT_DIR}/hack/boilerplate/boilerplate.generatego.txt"
+
+echo "Generating conversion"
+conversion-gen \
+  ./action/internalversion/ \
+  ./action/v1alpha2/ \
+  --output-file zz_generated.conversion.go \
+  --go-header-file "${ROOT_DIR}/hack/boilerplate/boilerplate.generatego.txt"
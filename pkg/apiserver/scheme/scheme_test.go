@@ -0,0 +1,40 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheme
+
+import (
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/scheme"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRecognizes(t *testing.T) {
+	tests := []schema.GroupVersionKind{
+		{Group: "apiregistration.k8s.io", Version: "v1", Kind: "APIService"},
+		{Group: "apiregistration.k8s.io", Version: "v1beta1", Kind: "APIService"},
+		{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"},
+		{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"},
+	}
+	for _, gvk := range tests {
+		t.Run(gvk.String(), func(t *testing.T) {
+			if !scheme.Scheme.Recognizes(gvk) {
+				t.Errorf("Recognizes(%v) = false, want true", gvk)
+			}
+		})
+	}
+}
@@ -0,0 +1,436 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/wzshiming/kectl/pkg/scheme"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// apiserverClient serves the operations through the API of kube-apiserver, for clusters whose etcd is not reachable.
+// The objects are exchanged as JSON under the keys they would have in etcd,
+// and the resource versions are taken as the revisions, which they are for an apiserver backed by etcd.
+type apiserverClient struct {
+	dynamic   dynamic.Interface
+	discovery discovery.DiscoveryInterface
+	mapper    *restmapper.DeferredDiscoveryRESTMapper
+}
+
+// NewAPIServerClient creates a new client of the apiserver of the kubeconfig,
+// the default loading rules of kubectl apply if the kubeconfig is empty.
+func NewAPIServerClient(kubeconfig string) (Client, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, nil).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newAPIServerClient(restConfig)
+}
+
+func newAPIServerClient(restConfig *rest.Config) (Client, error) {
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	cached := memory.NewMemCacheClient(discoveryClient)
+	return &apiserverClient{
+		dynamic:   dynamicClient,
+		discovery: cached,
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(cached),
+	}, nil
+}
+
+// resourceFor returns the preferred version of the resource.
+func (c *apiserverClient) resourceFor(gr schema.GroupResource) (schema.GroupVersionResource, bool, error) {
+	gvr, err := c.mapper.ResourceFor(gr.WithVersion(""))
+	if err != nil {
+		return schema.GroupVersionResource{}, false, err
+	}
+	kind, err := c.mapper.KindFor(gvr)
+	if err != nil {
+		return schema.GroupVersionResource{}, false, err
+	}
+	mapping, err := c.mapper.RESTMapping(kind.GroupKind(), kind.Version)
+	if err != nil {
+		return schema.GroupVersionResource{}, false, err
+	}
+	return gvr, mapping.Scope.Name() == "namespace", nil
+}
+
+func (c *apiserverClient) resourceInterface(gr schema.GroupResource, namespace string) (dynamic.ResourceInterface, error) {
+	gvr, namespaced, err := c.resourceFor(gr)
+	if err != nil {
+		return nil, err
+	}
+	if namespaced && namespace != "" {
+		return c.dynamic.Resource(gvr).Namespace(namespace), nil
+	}
+	return c.dynamic.Resource(gvr), nil
+}
+
+// listableResources returns all the resources that can be listed, at their preferred version.
+func (c *apiserverClient) listableResources() ([]schema.GroupResource, error) {
+	lists, err := c.discovery.ServerPreferredResources()
+	if err != nil && len(lists) == 0 {
+		return nil, err
+	}
+	var grs []schema.GroupResource
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range list.APIResources {
+			if !hasVerb(resource.Verbs, "list") {
+				continue
+			}
+			grs = append(grs, gv.WithResource(resource.Name).GroupResource())
+		}
+	}
+	return grs, nil
+}
+
+func hasVerb(verbs []string, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// keyOf returns the key of the object as it would be in etcd.
+func keyOf(prefix string, gr schema.GroupResource, obj *unstructured.Unstructured) ([]byte, error) {
+	key, _, err := getPrefix(prefix, gr, obj.GetName(), obj.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	return []byte(key), nil
+}
+
+// revisionOf returns the resource version as a revision, it is opaque to clients but an etcd revision in practice.
+func revisionOf(resourceVersion string) int64 {
+	rev, _ := strconv.ParseInt(resourceVersion, 10, 64)
+	return rev
+}
+
+func (c *apiserverClient) Get(ctx context.Context, prefix string, opOpts ...OpOption) (rev int64, err error) {
+	if prefix == "" {
+		return 0, fmt.Errorf("prefix is required")
+	}
+	opt := opOption(opOpts)
+	if opt.response == nil {
+		return 0, fmt.Errorf("response is required")
+	}
+
+	// The objects are listed by resource in the order of the apiserver, not by key
+	if opt.startAfter != "" {
+		return 0, fmt.Errorf("continuing a list after a key is not supported through the apiserver")
+	}
+
+	grs := []schema.GroupResource{opt.gr}
+	if opt.gr.Empty() {
+		if opt.name != "" || opt.namespace != "" {
+			return 0, fmt.Errorf("namespace and name must be omitted if there is no GroupResource")
+		}
+		grs, err = c.listableResources()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for _, gr := range grs {
+		r, err := c.get(ctx, prefix, gr, opt)
+		if err != nil {
+			return 0, err
+		}
+		if rev == 0 {
+			rev = r
+		}
+	}
+	return rev, nil
+}
+
+func (c *apiserverClient) get(ctx context.Context, prefix string, gr schema.GroupResource, opt Op) (int64, error) {
+	ri, err := c.resourceInterface(gr, opt.namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	if opt.name != "" {
+		getOpts := metav1.GetOptions{}
+		if opt.serializable {
+			getOpts.ResourceVersion = "0"
+		}
+		obj, err := ri.Get(ctx, opt.name, getOpts)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return 0, nil
+			}
+			return 0, err
+		}
+		err = c.respond(prefix, gr, obj, opt)
+		if err != nil {
+			return 0, err
+		}
+		return revisionOf(obj.GetResourceVersion()), nil
+	}
+
+	listOpts := metav1.ListOptions{
+		Limit: opt.pageLimit,
+	}
+	if opt.revision != 0 {
+		listOpts.ResourceVersion = strconv.FormatInt(opt.revision, 10)
+		listOpts.ResourceVersionMatch = metav1.ResourceVersionMatchExact
+	} else if opt.serializable {
+		// Served from the watch cache of the apiserver, like a serializable read is served by the local member
+		listOpts.ResourceVersion = "0"
+		listOpts.Limit = 0
+	}
+
+	var rev int64
+	for {
+		list, err := ri.List(ctx, listOpts)
+		if err != nil {
+			return 0, err
+		}
+		for i := range list.Items {
+			err = c.respond(prefix, gr, &list.Items[i], opt)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if rev == 0 {
+			rev = revisionOf(list.GetResourceVersion())
+		}
+		if list.GetContinue() == "" {
+			break
+		}
+		listOpts.Continue = list.GetContinue()
+		listOpts.ResourceVersion = ""
+		listOpts.ResourceVersionMatch = ""
+	}
+	return rev, nil
+}
+
+func (c *apiserverClient) respond(prefix string, gr schema.GroupResource, obj *unstructured.Unstructured, opt Op) error {
+	key, err := keyOf(prefix, gr, obj)
+	if err != nil {
+		return err
+	}
+	kv := &KeyValue{
+		Key:      key,
+		Revision: revisionOf(obj.GetResourceVersion()),
+	}
+	if !opt.keysOnly {
+		kv.Value, err = obj.MarshalJSON()
+		if err != nil {
+			return err
+		}
+	}
+	return opt.response(kv)
+}
+
+func (c *apiserverClient) Put(ctx context.Context, prefix string, value []byte, opOpts ...OpOption) error {
+	opt := opOption(opOpts)
+	if opt.gr.Empty() || opt.name == "" {
+		return fmt.Errorf("put only support single")
+	}
+	obj, err := decodeUnstructured(value)
+	if err != nil {
+		return err
+	}
+	ri, err := c.resourceInterface(opt.gr, opt.namespace)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ri.Get(ctx, opt.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		existing = nil
+	} else if err != nil {
+		return err
+	}
+
+	key, _, err := getPrefix(prefix, opt.gr, opt.name, opt.namespace)
+	if err != nil {
+		return err
+	}
+
+	var result *unstructured.Unstructured
+	if existing == nil {
+		if opt.modRevision != 0 {
+			return fmt.Errorf("%s: %w", key, ErrConflict)
+		}
+		obj.SetResourceVersion("")
+		result, err = ri.Create(ctx, obj, metav1.CreateOptions{})
+		if err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				return fmt.Errorf("%s: %w", key, ErrConflict)
+			}
+			return err
+		}
+	} else {
+		// The resource version guards the update like the mod revision guards the put of etcd
+		resourceVersion := existing.GetResourceVersion()
+		if opt.modRevision != 0 {
+			resourceVersion = strconv.FormatInt(opt.modRevision, 10)
+		}
+		obj.SetResourceVersion(resourceVersion)
+		obj.SetUID(existing.GetUID())
+		result, err = ri.Update(ctx, obj, metav1.UpdateOptions{})
+		if err != nil {
+			if apierrors.IsConflict(err) {
+				return fmt.Errorf("%s: %w", key, ErrConflict)
+			}
+			return err
+		}
+	}
+
+	if opt.response != nil {
+		var r *KeyValue
+		if existing != nil {
+			r = &KeyValue{
+				Key:      []byte(key),
+				Value:    value,
+				Revision: revisionOf(result.GetResourceVersion()),
+			}
+			if !opt.keysOnly {
+				r.PrevValue, err = existing.MarshalJSON()
+				if err != nil {
+					return err
+				}
+			}
+		}
+		err = opt.response(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeUnstructured decodes the value in any of the media types of etcd.
+func decodeUnstructured(value []byte) (*unstructured.Unstructured, error) {
+	inMediaType, _, err := encoding.DetectAndExtract(value)
+	if err != nil {
+		return nil, err
+	}
+	if inMediaType != encoding.JsonMediaType {
+		value, _, err = encoding.Convert(scheme.Codecs, inMediaType, encoding.JsonMediaType, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	obj := &unstructured.Unstructured{}
+	err = obj.UnmarshalJSON(bytes.TrimSpace(value))
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (c *apiserverClient) Delete(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	opt := opOption(opOpts)
+	if opt.gr.Empty() {
+		return fmt.Errorf("resource is required")
+	}
+	ri, err := c.resourceInterface(opt.gr, opt.namespace)
+	if err != nil {
+		return err
+	}
+
+	var targets []unstructured.Unstructured
+	if opt.name != "" {
+		obj, err := ri.Get(ctx, opt.name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		targets = append(targets, *obj)
+	} else {
+		list, err := ri.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		targets = list.Items
+	}
+
+	for i := range targets {
+		obj := &targets[i]
+		resourceVersion := obj.GetResourceVersion()
+		err := ri.Delete(ctx, obj.GetName(), metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{
+				UID:             ptr(obj.GetUID()),
+				ResourceVersion: &resourceVersion,
+			},
+		})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if opt.response != nil {
+			key, err := keyOf(prefix, opt.gr, obj)
+			if err != nil {
+				return err
+			}
+			r := &KeyValue{
+				Key:      key,
+				Revision: revisionOf(resourceVersion),
+			}
+			if !opt.keysOnly {
+				r.PrevValue, err = obj.MarshalJSON()
+				if err != nil {
+					return err
+				}
+			}
+			err = opt.response(r)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newTestAPIServerClient(objects ...runtime.Object) *apiserverClient {
+	discovery := &fakediscovery.FakeDiscovery{
+		Fake: &clienttesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"get", "list", "watch"}},
+					},
+				},
+			},
+		},
+	}
+	cached := memory.NewMemCacheClient(discovery)
+	dynamic := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Version: "v1", Resource: "pods"}: "PodList",
+		},
+		objects...,
+	)
+	return &apiserverClient{
+		dynamic:   dynamic,
+		discovery: cached,
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(cached),
+	}
+}
+
+func newTestPod(namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("Pod")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetResourceVersion("5")
+	return obj
+}
+
+func TestAPIServerClientGet(t *testing.T) {
+	c := newTestAPIServerClient(newTestPod("default", "a"), newTestPod("kube-system", "b"))
+	pods := schema.GroupResource{Resource: "pods"}
+
+	tests := []struct {
+		name string
+		opts []OpOption
+		want []string
+	}{
+		{
+			name: "all",
+			want: []string{"/registry/pods/default/a", "/registry/pods/kube-system/b"},
+		},
+		{
+			name: "resource",
+			opts: []OpOption{WithGR(pods)},
+			want: []string{"/registry/pods/default/a", "/registry/pods/kube-system/b"},
+		},
+		{
+			name: "namespace",
+			opts: []OpOption{WithGR(pods), WithName("", "kube-system")},
+			want: []string{"/registry/pods/kube-system/b"},
+		},
+		{
+			name: "single",
+			opts: []OpOption{WithGR(pods), WithName("a", "default")},
+			want: []string{"/registry/pods/default/a"},
+		},
+		{
+			name: "not found",
+			opts: []OpOption{WithGR(pods), WithName("c", "default")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			_, err := c.Get(context.Background(), "/registry", append(tt.opts, WithResponse(func(kv *KeyValue) error {
+				if kv.Revision != 5 {
+					t.Errorf("revision = %d, want 5", kv.Revision)
+				}
+				got = append(got, string(kv.Key))
+				return nil
+			}))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("keys = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("keys = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIServerClientPut(t *testing.T) {
+	c := newTestAPIServerClient(newTestPod("default", "a"))
+	pods := schema.GroupResource{Resource: "pods"}
+	ctx := context.Background()
+
+	value := []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"b","namespace":"default","labels":{"k":"v"}}}`)
+	err := c.Put(ctx, "/registry", value, WithGR(pods), WithName("b", "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *KeyValue
+	_, err = c.Get(ctx, "/registry", WithGR(pods), WithName("b", "default"), WithResponse(func(kv *KeyValue) error {
+		got = kv
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("the created object is not found")
+	}
+	obj, err := decodeUnstructured(got.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.GetLabels()["k"] != "v" {
+		t.Errorf("labels = %v, want k=v", obj.GetLabels())
+	}
+
+	err = c.Delete(ctx, "/registry", WithGR(pods), WithName("b", "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	_, err = c.Get(ctx, "/registry", WithGR(pods), WithName("b", "default"), WithResponse(func(kv *KeyValue) error {
+		got = kv
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("the deleted object is still found: %s", got.Key)
+	}
+}
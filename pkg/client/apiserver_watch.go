@@ -0,0 +1,154 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+func (c *apiserverClient) Watch(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	opt := opOption(opOpts)
+	if opt.response == nil {
+		return fmt.Errorf("response is required")
+	}
+	// The API has no watch across the resources, and events of separate watches can not be ordered
+	if opt.gr.Empty() {
+		return fmt.Errorf("resource is required to watch through the apiserver")
+	}
+	ri, err := c.resourceInterface(opt.gr, opt.namespace)
+	if err != nil {
+		return err
+	}
+
+	listOpts := metav1.ListOptions{
+		AllowWatchBookmarks: opt.maxRevision != 0,
+	}
+	if opt.name != "" {
+		listOpts.FieldSelector = fields.OneTermEqualSelector("metadata.name", opt.name).String()
+	}
+	if opt.revision != 0 {
+		// The events after the resource version are delivered, the revision itself is included for etcd
+		listOpts.ResourceVersion = strconv.FormatInt(opt.revision-1, 10)
+	}
+
+	tracker := newRevisionTracker(opt.revision)
+
+	w, err := ri.Watch(ctx, listOpts)
+	if err != nil {
+		return c.watchError(ctx, ri, err, opt, tracker)
+	}
+	defer w.Stop()
+
+	prev := map[string][]byte{}
+	for {
+		var event watch.Event
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+			event = e
+		}
+
+		if event.Type == watch.Error {
+			return c.watchError(ctx, ri, apierrors.FromObject(event.Object), opt, tracker)
+		}
+		obj, ok := event.Object.(*unstructured.Unstructured)
+		if !ok {
+			return fmt.Errorf("unexpected object %T in watch event", event.Object)
+		}
+		rev := revisionOf(obj.GetResourceVersion())
+		if opt.maxRevision != 0 && rev > opt.maxRevision {
+			return nil
+		}
+		if event.Type == watch.Bookmark {
+			if opt.maxRevision != 0 && rev >= opt.maxRevision {
+				return nil
+			}
+			continue
+		}
+
+		err := tracker.observe(rev)
+		if err != nil {
+			return err
+		}
+		key, err := keyOf(prefix, opt.gr, obj)
+		if err != nil {
+			return err
+		}
+		value, err := obj.MarshalJSON()
+		if err != nil {
+			return err
+		}
+
+		// The API sends the last state of the deleted objects, which is the previous value of etcd
+		r := &KeyValue{
+			Key:      key,
+			Revision: rev,
+		}
+		if event.Type == watch.Deleted {
+			r.PrevValue = value
+			delete(prev, string(key))
+		} else {
+			r.Value = value
+			r.PrevValue = prev[string(key)]
+			prev[string(key)] = value
+		}
+		if opt.keysOnly {
+			r.Value, r.PrevValue = nil, nil
+		}
+		err = opt.response(r)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// watchError translates the expired resource version of the API to the compaction of etcd.
+// The compact revision is not exposed by the API, so the gap runs up to the current revision.
+func (c *apiserverClient) watchError(ctx context.Context, ri dynamic.ResourceInterface, err error, opt Op, tracker *revisionTracker) error {
+	if !apierrors.IsResourceExpired(err) && !apierrors.IsGone(err) {
+		return err
+	}
+	if opt.gap != nil {
+		list, listErr := ri.List(ctx, metav1.ListOptions{Limit: 1})
+		if listErr != nil {
+			return listErr
+		}
+		gap := Gap{
+			From: tracker.last + 1,
+			To:   revisionOf(list.GetResourceVersion()),
+		}
+		gapErr := opt.gap(gap)
+		if gapErr != nil {
+			return gapErr
+		}
+	}
+	return rpctypes.ErrCompacted
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Backend creates a Client for the endpoints of the URI schemes it is registered for,
+// or for any endpoints when it is selected by name.
+type Backend interface {
+	// New creates a new Client.
+	New(conf Config) (Client, error)
+}
+
+// BackendFunc is an adapter to allow the use of ordinary functions as Backend.
+type BackendFunc func(conf Config) (Client, error)
+
+// New calls f(conf).
+func (f BackendFunc) New(conf Config) (Client, error) {
+	return f(conf)
+}
+
+var (
+	backendsMut sync.RWMutex
+	backends    = map[string]Backend{}
+)
+
+// The schemes understood by the etcd client, endpoints without a scheme are also etcd.
+func init() {
+	for _, scheme := range []string{"", "etcd", "http", "https", "unix", "unixs"} {
+		RegisterBackend(scheme, BackendFunc(newEtcdClient))
+	}
+}
+
+// RegisterBackend registers the backend for the URI scheme of the endpoints,
+// the scheme is also the name to select the backend by.
+func RegisterBackend(scheme string, backend Backend) {
+	backendsMut.Lock()
+	defer backendsMut.Unlock()
+	backends[scheme] = backend
+}
+
+// Backends returns the registered URI schemes.
+func Backends() []string {
+	backendsMut.RLock()
+	defer backendsMut.RUnlock()
+	schemes := make([]string, 0, len(backends))
+	for scheme := range backends {
+		if scheme != "" {
+			schemes = append(schemes, scheme)
+		}
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// NewClient creates a new client with the backend selected by the URI scheme of the endpoints.
+func NewClient(conf Config) (Client, error) {
+	scheme, err := endpointsScheme(conf.Endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	backendsMut.RLock()
+	backend, ok := backends[scheme]
+	backendsMut.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported endpoint scheme %q, supported: %s", scheme, strings.Join(Backends(), ", "))
+	}
+	return backend.New(conf)
+}
+
+// NewClientWithBackend creates a new client with the named backend, whatever the URI scheme of the endpoints.
+// An empty name selects the backend by the URI scheme like NewClient.
+func NewClientWithBackend(name string, conf Config) (Client, error) {
+	if name == "" {
+		return NewClient(conf)
+	}
+
+	backendsMut.RLock()
+	backend, ok := backends[name]
+	backendsMut.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported backend %q, supported: %s", name, strings.Join(Backends(), ", "))
+	}
+	return backend.New(conf)
+}
+
+// endpointsScheme returns the URI scheme shared by all the endpoints.
+func endpointsScheme(endpoints []string) (string, error) {
+	var scheme string
+	for i, ep := range endpoints {
+		s, _, _ := strings.Cut(ep, "://")
+		if s == ep {
+			s = ""
+		}
+		if i == 0 {
+			scheme = s
+		} else if s != scheme {
+			return "", fmt.Errorf("endpoints must share the same scheme, got %q and %q", scheme, s)
+		}
+	}
+	return scheme, nil
+}
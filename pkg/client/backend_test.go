@@ -0,0 +1,84 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"testing"
+)
+
+func TestEndpointsScheme(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoints  []string
+		wantScheme string
+		wantErr    bool
+	}{
+		{
+			name:       "no scheme",
+			endpoints:  []string{"127.0.0.1:2379", "127.0.0.2:2379"},
+			wantScheme: "",
+		},
+		{
+			name:       "https",
+			endpoints:  []string{"https://127.0.0.1:2379"},
+			wantScheme: "https",
+		},
+		{
+			name:      "mixed",
+			endpoints: []string{"https://127.0.0.1:2379", "file:///tmp/snapshot.db"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotScheme, err := endpointsScheme(tt.endpoints)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("endpointsScheme() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotScheme != tt.wantScheme {
+				t.Errorf("endpointsScheme() = %v, want %v", gotScheme, tt.wantScheme)
+			}
+		})
+	}
+}
+
+func TestNewClientUnsupportedScheme(t *testing.T) {
+	_, err := NewClient(Config{Endpoints: []string{"unknown://foo"}})
+	if err == nil {
+		t.Errorf("NewClient() should fail for unknown scheme")
+	}
+}
+
+func TestNewClientWithBackend(t *testing.T) {
+	_, err := NewClientWithBackend("unknown", Config{Endpoints: []string{"127.0.0.1:2379"}})
+	if err == nil {
+		t.Errorf("NewClientWithBackend() should fail for unknown backend")
+	}
+
+	var got Config
+	RegisterBackend("test", BackendFunc(func(conf Config) (Client, error) {
+		got = conf
+		return nil, nil
+	}))
+	_, err = NewClientWithBackend("test", Config{Endpoints: []string{"127.0.0.1:2379"}})
+	if err != nil {
+		t.Fatalf("NewClientWithBackend() error = %v", err)
+	}
+	if len(got.Endpoints) != 1 || got.Endpoints[0] != "127.0.0.1:2379" {
+		t.Errorf("NewClientWithBackend() endpoints = %v", got.Endpoints)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// maxBatchBytes keeps the transactions under the default request size limit of etcd of 1.5MiB.
+const maxBatchBytes = 1 << 20
+
+// Batch puts the values in transactions of several puts, to reduce the round trips of bulk writes.
+// The values too large for a transaction, and all the values of the clients not speaking the etcd API
+// in full such as kine, are put one at a time.
+type Batch struct {
+	client Client
+	prefix string
+	size   int
+
+	puts  []batchPut
+	bytes int
+}
+
+type batchPut struct {
+	key   string
+	value []byte
+	opt   Op
+	// opOpts are the options as given, the opt has the key encoded by the key codec and in the etcd namespace of the client
+	opOpts []OpOption
+	done   func(err error) error
+}
+
+// NewBatch creates a new Batch committing the puts by size, at most the --max-txn-ops of etcd.
+func NewBatch(client Client, prefix string, size int) *Batch {
+	return &Batch{
+		client: client,
+		prefix: prefix,
+		size:   size,
+	}
+}
+
+// Put adds the put of the value to the batch, and commits the batch once it is full.
+// The done is called with the result of the put when it is committed,
+// the first error returned by the done of the puts committed is returned, such as to abort the writes.
+func (b *Batch) Put(ctx context.Context, value []byte, done func(err error) error, opOpts ...OpOption) error {
+	inner, _ := unwrapHook(b.client)
+	inner, codec := unwrapKeyCodec(inner)
+	inner, namespace := unwrapNamespace(inner)
+	c, ok := inner.(*client)
+	if !ok || c.kine || b.size <= 1 || len(value) > maxBatchBytes {
+		return done(b.client.Put(ctx, b.prefix, value, opOpts...))
+	}
+
+	opt := opOption(opOpts)
+	if codec != nil {
+		opt = encodeOp(codec, opt)
+	}
+	if namespace != "" {
+		opt = namespaceOp(namespace, opt)
+	}
+	key, single, err := c.getPrefix(namespace+b.prefix, opt)
+	if err != nil {
+		return done(err)
+	}
+	if !single {
+		return done(fmt.Errorf("put only support single"))
+	}
+	if opt.modRevision != 0 {
+		// The puts guarded by a revision fail on their own
+		return done(b.client.Put(ctx, b.prefix, value, opOpts...))
+	}
+
+	if b.bytes+len(value) > maxBatchBytes {
+		err = b.Flush(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	b.puts = append(b.puts, batchPut{key: key, value: value, opt: opt, opOpts: opOpts, done: done})
+	b.bytes += len(value)
+	if len(b.puts) < b.size {
+		return nil
+	}
+	return b.Flush(ctx)
+}
+
+// Flush commits the puts in the batch.
+func (b *Batch) Flush(ctx context.Context) error {
+	if len(b.puts) == 0 {
+		return nil
+	}
+	puts := b.puts
+	b.puts = nil
+	b.bytes = 0
+
+	// The transactions go to the client under the hook, which observes them as the puts they commit
+	inner, hook := unwrapHook(b.client)
+	inner, _ = unwrapKeyCodec(inner)
+	inner, _ = unwrapNamespace(inner)
+	c := inner.(*client)
+	ops := make([]clientv3.Op, 0, len(puts))
+	for _, p := range puts {
+		opts := []clientv3.OpOption{}
+		if p.opt.response != nil {
+			if p.opt.keysOnly {
+				opts = append(opts, clientv3.WithKeysOnly())
+			}
+			opts = append(opts, clientv3.WithPrevKV())
+		}
+		ops = append(ops, clientv3.OpPut(p.key, string(p.value), opts...))
+	}
+
+	start := time.Now()
+	txnResp, err := c.client.Txn(ctx).Then(ops...).Commit()
+	if hook != nil && !errors.Is(err, rpctypes.ErrTooManyOps) && !errors.Is(err, rpctypes.ErrRequestTooLarge) {
+		latency := time.Since(start)
+		for _, p := range puts {
+			info := OpInfo{
+				Key:     p.key,
+				Count:   1,
+				Bytes:   int64(len(p.value)),
+				Latency: latency,
+				Err:     err,
+			}
+			if err == nil {
+				info.Revision = txnResp.Header.Revision
+			}
+			hook.OnPut(ctx, info)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, rpctypes.ErrTooManyOps) || errors.Is(err, rpctypes.ErrRequestTooLarge) {
+			// Over the limits of the server, which may be lower than the defaults
+			return b.putEach(ctx, puts)
+		}
+		var firstErr error
+		for _, p := range puts {
+			firstErr = first(firstErr, p.done(err))
+		}
+		return firstErr
+	}
+
+	var firstErr error
+	for i, p := range puts {
+		err := b.respond(p, txnResp.Responses[i].GetResponsePut(), txnResp.Header.Revision)
+		firstErr = first(firstErr, p.done(err))
+	}
+	return firstErr
+}
+
+// putEach puts the values of the batch one at a time.
+func (b *Batch) putEach(ctx context.Context, puts []batchPut) error {
+	var firstErr error
+	for _, p := range puts {
+		err := b.client.Put(ctx, b.prefix, p.value, p.opOpts...)
+		firstErr = first(firstErr, p.done(err))
+	}
+	return firstErr
+}
+
+func first(err, next error) error {
+	if err != nil {
+		return err
+	}
+	return next
+}
+
+// respond calls the response of the put the same as Put, with the previous value of the key if there was one.
+func (b *Batch) respond(p batchPut, resp *etcdserverpb.PutResponse, revision int64) error {
+	if p.opt.response == nil {
+		return nil
+	}
+	var r *KeyValue
+	if resp != nil && resp.PrevKv != nil {
+		r = &KeyValue{
+			Key:       resp.PrevKv.Key,
+			Value:     p.value,
+			PrevValue: resp.PrevKv.Value,
+			Revision:  revision,
+		}
+	}
+	return p.opt.response(r)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// txnKVServer keeps the puts of the transactions and the single puts, refusing the transactions over maxTxnOps.
+type txnKVServer struct {
+	etcdserverpb.UnimplementedKVServer
+	maxTxnOps int
+
+	mut  sync.Mutex
+	txns []int
+	puts int
+	keys []string
+}
+
+func (s *txnKVServer) Put(ctx context.Context, req *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.puts++
+	s.keys = append(s.keys, string(req.Key))
+	return &etcdserverpb.PutResponse{Header: &etcdserverpb.ResponseHeader{Revision: 1}}, nil
+}
+
+func (s *txnKVServer) Txn(ctx context.Context, req *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error) {
+	if len(req.Success) > s.maxTxnOps {
+		return nil, rpctypes.ErrGRPCTooManyOps
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.txns = append(s.txns, len(req.Success))
+	resp := &etcdserverpb.TxnResponse{Header: &etcdserverpb.ResponseHeader{Revision: 1}, Succeeded: true}
+	for _, op := range req.Success {
+		s.keys = append(s.keys, string(op.GetRequestPut().Key))
+		resp.Responses = append(resp.Responses, &etcdserverpb.ResponseOp{
+			Response: &etcdserverpb.ResponseOp_ResponsePut{ResponsePut: &etcdserverpb.PutResponse{}},
+		})
+	}
+	return resp, nil
+}
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		maxTxnOps int
+		values    []int
+		wantTxns  []int
+		wantPuts  int
+	}{
+		{
+			name:      "by size",
+			size:      2,
+			maxTxnOps: 128,
+			values:    []int{10, 10, 10, 10, 10},
+			wantTxns:  []int{2, 2, 1},
+		},
+		{
+			name:      "too large values put alone",
+			size:      4,
+			maxTxnOps: 128,
+			values:    []int{10, maxBatchBytes + 1, 10, maxBatchBytes / 2, maxBatchBytes / 2},
+			wantTxns:  []int{3, 1},
+			wantPuts:  1,
+		},
+		{
+			name:      "over the max txn ops of the server",
+			size:      4,
+			maxTxnOps: 2,
+			values:    []int{10, 10, 10},
+			wantPuts:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := grpc.NewServer(grpc.MaxRecvMsgSize(4 * maxBatchBytes))
+			kv := &txnKVServer{maxTxnOps: tt.maxTxnOps}
+			etcdserverpb.RegisterKVServer(srv, kv)
+			go func() {
+				_ = srv.Serve(lis)
+			}()
+			defer srv.Stop()
+
+			c, err := newEtcdClient(Config{
+				Endpoints:   []string{lis.Addr().String()},
+				DialTimeout: time.Second,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			batch := NewBatch(c, "/registry", tt.size)
+			var done int
+			for i, size := range tt.values {
+				err = batch.Put(ctx, make([]byte, size), func(err error) error {
+					if err != nil {
+						return err
+					}
+					done++
+					return nil
+				},
+					WithGR(schema.GroupResource{Resource: "configmaps"}),
+					WithName(fmt.Sprintf("cm%d", i), "default"),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err = batch.Flush(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if done != len(tt.values) {
+				t.Errorf("done %d puts, want %d", done, len(tt.values))
+			}
+			if fmt.Sprint(kv.txns) != fmt.Sprint(tt.wantTxns) || kv.puts != tt.wantPuts {
+				t.Errorf("committed transactions of %v and %d puts, want %v and %d", kv.txns, kv.puts, tt.wantTxns, tt.wantPuts)
+			}
+			if len(kv.keys) != len(tt.values) {
+				t.Errorf("put %d keys, want %d: %v", len(kv.keys), len(tt.values), kv.keys)
+			}
+		})
+	}
+}
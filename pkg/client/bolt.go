@@ -0,0 +1,274 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ErrReadOnly is returned when writing to a backend that is opened read-only.
+// The files of the file backend are opened read-only, unless mode=rw is in the query of the endpoint.
+var ErrReadOnly = errors.New("backend is read-only")
+
+// The buckets and keys of the etcd mvcc store in the bolt database.
+var (
+	boltKeyBucket           = []byte("key")
+	boltMetaBucket          = []byte("meta")
+	boltScheduledCompactKey = []byte("scheduledCompactRev")
+	boltFinishedCompactKey  = []byte("finishedCompactRev")
+)
+
+// The revisions are keyed by the main revision, '_' and the sub revision, deletions are marked with a trailing 't'.
+const (
+	boltRevBytesLen       = 8 + 1 + 8
+	boltMarkedRevBytesLen = boltRevBytesLen + 1
+	boltMarkTombstone     = 't'
+)
+
+// errBoltStop stops the iteration over the revisions.
+var errBoltStop = errors.New("stop")
+
+// The snapshot files of etcd, e.g. file:///var/lib/etcd/member/snap/db or file:///tmp/seed.db?mode=rw.
+func init() {
+	RegisterBackend("file", BackendFunc(newBoltClient))
+}
+
+// boltClient reads the bolt database file of etcd, such as a snapshot, without an etcd server.
+// If it is writable, the changes are written in the layout of etcd, so that the file can be restored as a snapshot.
+type boltClient struct {
+	db       *bolt.DB
+	writable bool
+
+	// mut serializes the writes, index is the latest revision of each key built on the first write.
+	mut   sync.Mutex
+	index map[string]boltIndexEntry
+}
+
+// newBoltClient opens the bolt database file of the only endpoint.
+func newBoltClient(conf Config) (Client, error) {
+	if len(conf.Endpoints) != 1 {
+		return nil, fmt.Errorf("exactly one file endpoint is required, got %d", len(conf.Endpoints))
+	}
+	path, rawQuery, _ := strings.Cut(strings.TrimPrefix(conf.Endpoints[0], "file://"), "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", conf.Endpoints[0], err)
+	}
+
+	var writable bool
+	switch mode := query.Get("mode"); mode {
+	case "", "ro":
+	case "rw":
+		writable = true
+	default:
+		return nil, fmt.Errorf("unsupported mode %q of endpoint %q, one of: ro, rw", mode, conf.Endpoints[0])
+	}
+
+	// bbolt creates the missing files even read-only, a mistyped path is not to leave an empty database behind
+	if !writable {
+		_, err = os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", path, err)
+		}
+	}
+
+	// The file is locked while an etcd server is using it, so do not wait forever for it.
+	db, err := bolt.Open(path, 0o600, &bolt.Options{
+		ReadOnly: !writable,
+		Timeout:  conf.DialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+
+	if writable {
+		err = db.Update(func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{boltKeyBucket, boltMetaBucket} {
+				_, err := tx.CreateBucketIfNotExists(name)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("init %s: %w", path, err)
+		}
+	}
+
+	return &boltClient{
+		db:       db,
+		writable: writable,
+	}, nil
+}
+
+// revisions returns the compact revision and the current revision of the store.
+func (c *boltClient) revisions() (compactRev, currentRev int64, err error) {
+	err = c.db.View(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(boltMetaBucket); meta != nil {
+			// A scheduled compaction may not have finished, the revisions up to it may be partially gone
+			for _, key := range [][]byte{boltFinishedCompactKey, boltScheduledCompactKey} {
+				if v := meta.Get(key); len(v) >= boltRevBytesLen {
+					compactRev = max(compactRev, boltBytesToRev(v))
+				}
+			}
+		}
+
+		keys := tx.Bucket(boltKeyBucket)
+		if keys == nil {
+			return fmt.Errorf("bucket %q not found, not a database file of etcd", boltKeyBucket)
+		}
+		if k, _ := keys.Cursor().Last(); k != nil {
+			currentRev = boltBytesToRev(k)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return compactRev, max(currentRev, compactRev, 1), nil
+}
+
+// iterate calls fn with every change from the revision in revision order,
+// the value of a deletion only has the key set.
+func (c *boltClient) iterate(ctx context.Context, from int64, fn func(rev int64, deleted bool, kv *mvccpb.KeyValue) error) error {
+	err := c.db.View(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(boltKeyBucket)
+		if keys == nil {
+			return fmt.Errorf("bucket %q not found, not a database file of etcd", boltKeyBucket)
+		}
+		cursor := keys.Cursor()
+		for k, v := cursor.Seek(boltRevToBytes(from, 0, false)); k != nil; k, v = cursor.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if len(k) < boltRevBytesLen {
+				return fmt.Errorf("invalid revision %x", k)
+			}
+			kv, err := c.decodeKeyValue(v)
+			if err != nil {
+				return fmt.Errorf("revision %d: %w", boltBytesToRev(k), err)
+			}
+			deleted := len(k) == boltMarkedRevBytesLen && k[boltRevBytesLen] == boltMarkTombstone
+			err = fn(boltBytesToRev(k), deleted, kv)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errBoltStop) {
+		return nil
+	}
+	return err
+}
+
+// decodeKeyValue decodes the key-value stored at a revision.
+// The pages of a file opened read-only are memory-mapped and never remapped while it is open,
+// so the key and the value are slices of them rather than copies. A writable file is remapped as it grows.
+func (c *boltClient) decodeKeyValue(data []byte) (*mvccpb.KeyValue, error) {
+	kv := &mvccpb.KeyValue{}
+	if c.writable {
+		err := kv.Unmarshal(data)
+		if err != nil {
+			return nil, err
+		}
+		return kv, nil
+	}
+
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch typ {
+		case protowire.BytesType:
+			b, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+			switch num {
+			case 1:
+				kv.Key = b
+			case 5:
+				kv.Value = b
+			}
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+			switch num {
+			case 2:
+				kv.CreateRevision = int64(v)
+			case 3:
+				kv.ModRevision = int64(v)
+			case 4:
+				kv.Version = int64(v)
+			case 6:
+				kv.Lease = int64(v)
+			}
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	return kv, nil
+}
+
+// boltRevToBytes returns the revision bytes of the main and sub revision.
+func boltRevToBytes(main, sub int64, deleted bool) []byte {
+	b := make([]byte, boltRevBytesLen, boltMarkedRevBytesLen)
+	binary.BigEndian.PutUint64(b, uint64(main))
+	b[8] = '_'
+	binary.BigEndian.PutUint64(b[9:], uint64(sub))
+	if deleted {
+		b = append(b, boltMarkTombstone)
+	}
+	return b
+}
+
+// boltBytesToRev returns the main revision of the revision bytes.
+func boltBytesToRev(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b[0:8]))
+}
+
+// boltMatch returns whether the key is the path, or under it if it is not single.
+func boltMatch(key []byte, path string, single bool) bool {
+	if single {
+		return string(key) == path
+	}
+	return strings.HasPrefix(string(key), path)
+}
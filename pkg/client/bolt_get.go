@@ -0,0 +1,171 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+func (c *boltClient) Get(ctx context.Context, prefix string, opOpts ...OpOption) (rev int64, err error) {
+	if prefix == "" {
+		return 0, fmt.Errorf("prefix is required")
+	}
+
+	opt := opOption(opOpts)
+	if opt.response == nil {
+		return 0, fmt.Errorf("response is required")
+	}
+
+	path, single, err := opt.Key(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	compactRev, currentRev, err := c.revisions()
+	if err != nil {
+		return 0, err
+	}
+
+	rev = currentRev
+	if opt.revision != 0 {
+		rev = opt.revision
+	}
+	if rev < compactRev {
+		return 0, rpctypes.ErrCompacted
+	}
+	if rev > currentRev {
+		return 0, rpctypes.ErrFutureRev
+	}
+
+	latest, err := c.latest(ctx, path, single, rev)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := make([]string, 0, len(latest))
+	for key := range latest {
+		if opt.startAfter != "" && key <= opt.startAfter {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]*mvccpb.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		kvs = append(kvs, latest[key])
+	}
+	err = iterateGetList(kvs, opt.response)
+	if err != nil {
+		return 0, err
+	}
+	return rev, nil
+}
+
+// boltReplayWorkers is the number of workers replaying the revisions of a file opened read-only in parallel,
+// each worker replays at least boltMinRevisionsPerWorker revisions.
+var (
+	boltReplayWorkers               = runtime.GOMAXPROCS(0)
+	boltMinRevisionsPerWorker int64 = 10000
+)
+
+type boltChangeState struct {
+	deleted bool
+	kv      *mvccpb.KeyValue
+}
+
+// latest returns the latest value of the keys matching the path up to the revision.
+// The changes are stored by revision, so they are replayed up to the revision to get the latest values.
+// The revisions of a file opened read-only are split into spans replayed in parallel, then merged in order.
+func (c *boltClient) latest(ctx context.Context, path string, single bool, rev int64) (map[string]*mvccpb.KeyValue, error) {
+	first, err := c.firstRevision()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := int64(1)
+	if !c.writable {
+		workers = max(1, min(int64(boltReplayWorkers), (rev-first+1)/boltMinRevisionsPerWorker))
+	}
+	span := (rev-first)/workers + 1
+
+	spans := make([]map[string]boltChangeState, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := range spans {
+		from := first + int64(i)*span
+		to := min(from+span-1, rev)
+		changes := map[string]boltChangeState{}
+		spans[i] = changes
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.iterate(ctx, from, func(r int64, deleted bool, kv *mvccpb.KeyValue) error {
+				if r > to {
+					return errBoltStop
+				}
+				if !boltMatch(kv.Key, path, single) {
+					return nil
+				}
+				changes[string(kv.Key)] = boltChangeState{deleted: deleted, kv: kv}
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	err = errors.Join(errs...)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := map[string]*mvccpb.KeyValue{}
+	for _, changes := range spans {
+		for key, change := range changes {
+			if change.deleted {
+				delete(latest, key)
+			} else {
+				latest[key] = change.kv
+			}
+		}
+	}
+	return latest, nil
+}
+
+// firstRevision returns the first revision stored.
+func (c *boltClient) firstRevision() (int64, error) {
+	var first int64
+	err := c.db.View(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(boltKeyBucket)
+		if keys == nil {
+			return fmt.Errorf("bucket %q not found, not a database file of etcd", boltKeyBucket)
+		}
+		if k, _ := keys.Cursor().First(); k != nil {
+			first = boltBytesToRev(k)
+		}
+		return nil
+	})
+	return first, err
+}
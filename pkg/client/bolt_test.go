@@ -0,0 +1,356 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type boltChange struct {
+	rev     int64
+	key     string
+	value   string
+	deleted bool
+}
+
+// newTestBoltClient writes the changes in the layout of the etcd mvcc store and opens it.
+func newTestBoltClient(t *testing.T, compactRev int64, changes []boltChange) Client {
+	path := filepath.Join(t.TempDir(), "db")
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		keys, err := tx.CreateBucket(boltKeyBucket)
+		if err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucket(boltMetaBucket)
+		if err != nil {
+			return err
+		}
+		if compactRev != 0 {
+			err = meta.Put(boltFinishedCompactKey, boltRevToBytes(compactRev, 0, false))
+			if err != nil {
+				return err
+			}
+		}
+		for _, c := range changes {
+			kv := &mvccpb.KeyValue{Key: []byte(c.key)}
+			if !c.deleted {
+				kv.Value = []byte(c.value)
+				kv.ModRevision = c.rev
+			}
+			data, err := kv.Marshal()
+			if err != nil {
+				return err
+			}
+			err = keys.Put(boltRevToBytes(c.rev, 0, c.deleted), data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClient(Config{Endpoints: []string{"file://" + path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+var testBoltChanges = []boltChange{
+	{rev: 2, key: "/registry/pods/default/a", value: "a1"},
+	{rev: 3, key: "/registry/pods/default/b", value: "b1"},
+	{rev: 4, key: "/registry/configmaps/default/a", value: "c1"},
+	{rev: 5, key: "/registry/pods/default/a", value: "a2"},
+	{rev: 6, key: "/registry/pods/default/b", deleted: true},
+}
+
+func TestBoltGet(t *testing.T) {
+	c := newTestBoltClient(t, 0, testBoltChanges)
+
+	tests := []struct {
+		name     string
+		revision int64
+		want     []KeyValue
+		wantRev  int64
+	}{
+		{
+			name: "latest",
+			want: []KeyValue{
+				{Key: []byte("/registry/pods/default/a"), Value: []byte("a2"), Revision: 5},
+			},
+			wantRev: 6,
+		},
+		{
+			name:     "at revision",
+			revision: 3,
+			want: []KeyValue{
+				{Key: []byte("/registry/pods/default/a"), Value: []byte("a1"), Revision: 2},
+				{Key: []byte("/registry/pods/default/b"), Value: []byte("b1"), Revision: 3},
+			},
+			wantRev: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []KeyValue
+			rev, err := c.Get(context.Background(), "/registry",
+				WithGR(schema.GroupResource{Resource: "pods"}),
+				WithRevision(tt.revision),
+				WithResponse(func(kv *KeyValue) error {
+					got = append(got, *kv)
+					return nil
+				}),
+			)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if rev != tt.wantRev {
+				t.Errorf("Get() rev = %d, want %d", rev, tt.wantRev)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoltGetParallel(t *testing.T) {
+	var changes []boltChange
+	want := map[string]string{}
+	for rev := int64(2); rev < 200; rev++ {
+		key := fmt.Sprintf("/registry/pods/default/p%d", rev%7)
+		if rev%5 == 0 {
+			changes = append(changes, boltChange{rev: rev, key: key, deleted: true})
+			delete(want, key)
+			continue
+		}
+		value := fmt.Sprint(rev)
+		changes = append(changes, boltChange{rev: rev, key: key, value: value})
+		want[key] = value
+	}
+	c := newTestBoltClient(t, 0, changes)
+
+	defer func(workers int, minRevisions int64) {
+		boltReplayWorkers, boltMinRevisionsPerWorker = workers, minRevisions
+	}(boltReplayWorkers, boltMinRevisionsPerWorker)
+	for _, workers := range []int{1, 3, 8} {
+		boltReplayWorkers, boltMinRevisionsPerWorker = workers, 1
+
+		got := map[string]string{}
+		_, err := c.Get(context.Background(), "/registry",
+			WithGR(schema.GroupResource{Resource: "pods"}),
+			WithResponse(func(kv *KeyValue) error {
+				got[string(kv.Key)] = string(kv.Value)
+				return nil
+			}),
+		)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Get() with %d workers = %v, want %v", workers, got, want)
+		}
+	}
+}
+
+func TestBoltDecodeKeyValue(t *testing.T) {
+	want := &mvccpb.KeyValue{
+		Key:            []byte("/registry/pods/default/a"),
+		CreateRevision: 2,
+		ModRevision:    5,
+		Version:        3,
+		Value:          []byte("a2"),
+		Lease:          7,
+	}
+	data, err := want.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, writable := range []bool{false, true} {
+		got, err := (&boltClient{writable: writable}).decodeKeyValue(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("decodeKeyValue() writable %v = %v, want %v", writable, got, want)
+		}
+	}
+}
+
+func TestBoltWatch(t *testing.T) {
+	c := newTestBoltClient(t, 0, testBoltChanges)
+
+	var got []KeyValue
+	err := c.Watch(context.Background(), "/registry",
+		WithGR(schema.GroupResource{Resource: "pods"}),
+		WithRevision(3),
+		WithMaxRevision(6),
+		WithResponse(func(kv *KeyValue) error {
+			got = append(got, *kv)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	want := []KeyValue{
+		{Key: []byte("/registry/pods/default/b"), Value: []byte("b1"), Revision: 3},
+		{Key: []byte("/registry/pods/default/a"), Value: []byte("a2"), PrevValue: []byte("a1"), Revision: 5},
+		{Key: []byte("/registry/pods/default/b"), PrevValue: []byte("b1"), Revision: 6},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Watch() = %v, want %v", got, want)
+	}
+}
+
+func TestBoltCompacted(t *testing.T) {
+	c := newTestBoltClient(t, 5, testBoltChanges[3:])
+
+	_, err := c.Get(context.Background(), "/registry",
+		WithRevision(3),
+		WithResponse(func(kv *KeyValue) error {
+			return nil
+		}),
+	)
+	if !errors.Is(err, rpctypes.ErrCompacted) {
+		t.Errorf("Get() error = %v, want %v", err, rpctypes.ErrCompacted)
+	}
+
+	var gaps []Gap
+	err = c.Watch(context.Background(), "/registry",
+		WithRevision(3),
+		WithGap(func(gap Gap) error {
+			gaps = append(gaps, gap)
+			return nil
+		}),
+		WithResponse(func(kv *KeyValue) error {
+			return nil
+		}),
+	)
+	if !errors.Is(err, rpctypes.ErrCompacted) {
+		t.Errorf("Watch() error = %v, want %v", err, rpctypes.ErrCompacted)
+	}
+	if want := []Gap{{From: 3, To: 4}}; !reflect.DeepEqual(gaps, want) {
+		t.Errorf("Watch() gaps = %v, want %v", gaps, want)
+	}
+}
+
+func TestBoltReadOnly(t *testing.T) {
+	c := newTestBoltClient(t, 0, testBoltChanges)
+
+	err := c.Put(context.Background(), "/registry", []byte("v"),
+		WithGR(schema.GroupResource{Resource: "pods"}),
+		WithName("a", "default"),
+	)
+	if !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Put() error = %v, want %v", err, ErrReadOnly)
+	}
+}
+
+func TestBoltReadOnlyMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	_, err := NewClient(Config{Endpoints: []string{"file://" + path}})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("NewClient() error = %v, want %v", err, fs.ErrNotExist)
+	}
+	// The missing file is not created
+	_, err = os.Stat(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("stat %s error = %v, want %v", path, err, fs.ErrNotExist)
+	}
+}
+
+func TestBoltWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	c, err := NewClient(Config{Endpoints: []string{"file://" + path + "?mode=rw"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	pods := WithGR(schema.GroupResource{Resource: "pods"})
+
+	for _, value := range []string{"a1", "a2"} {
+		err = c.Put(ctx, "/registry", []byte(value), pods, WithName("a", "default"))
+		if err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	err = c.Put(ctx, "/registry", []byte("b1"), pods, WithName("b", "default"))
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	err = c.Put(ctx, "/registry", []byte("a3"), pods, WithName("a", "default"), WithModRevision(2))
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Put() error = %v, want %v", err, ErrConflict)
+	}
+
+	var deleted []KeyValue
+	err = c.Delete(ctx, "/registry", pods, WithName("b", "default"), WithResponse(func(kv *KeyValue) error {
+		deleted = append(deleted, *kv)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if want := []KeyValue{{Key: []byte("/registry/pods/default/b"), PrevValue: []byte("b1"), Revision: 5}}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("Delete() = %v, want %v", deleted, want)
+	}
+
+	var got []KeyValue
+	err = c.Watch(ctx, "/registry", pods,
+		WithRevision(1),
+		WithResponse(func(kv *KeyValue) error {
+			got = append(got, *kv)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	want := []KeyValue{
+		{Key: []byte("/registry/pods/default/a"), Value: []byte("a1"), Revision: 2},
+		{Key: []byte("/registry/pods/default/a"), Value: []byte("a2"), PrevValue: []byte("a1"), Revision: 3},
+		{Key: []byte("/registry/pods/default/b"), Value: []byte("b1"), Revision: 4},
+		{Key: []byte("/registry/pods/default/b"), PrevValue: []byte("b1"), Revision: 5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Watch() = %v, want %v", got, want)
+	}
+}
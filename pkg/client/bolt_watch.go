@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+// Watch replays the history stored in the file.
+// A file does not change, so the watch returns once the current revision is delivered.
+func (c *boltClient) Watch(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	opt := opOption(opOpts)
+	if opt.response == nil {
+		return fmt.Errorf("response is required")
+	}
+
+	path, single, err := opt.Key(prefix)
+	if err != nil {
+		return err
+	}
+
+	compactRev, currentRev, err := c.revisions()
+	if err != nil {
+		return err
+	}
+
+	start := opt.revision
+	if start == 0 {
+		start = currentRev + 1
+	}
+	end := currentRev
+	if opt.maxRevision != 0 && opt.maxRevision < end {
+		end = opt.maxRevision
+	}
+
+	tracker := newRevisionTracker(start)
+	if start < compactRev {
+		if opt.gap != nil {
+			if gap, ok := tracker.compacted(compactRev); ok {
+				err := opt.gap(gap)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return rpctypes.ErrCompacted
+	}
+
+	// The values before the start are still needed for the previous values of the events
+	prev := map[string][]byte{}
+	return c.iterate(ctx, 0, func(rev int64, deleted bool, kv *mvccpb.KeyValue) error {
+		if rev > end {
+			return errBoltStop
+		}
+		if !boltMatch(kv.Key, path, single) {
+			return nil
+		}
+
+		key := string(kv.Key)
+		if rev >= start {
+			err := tracker.observe(rev)
+			if err != nil {
+				return err
+			}
+			r := &KeyValue{
+				Key:       kv.Key,
+				PrevValue: prev[key],
+				Revision:  rev,
+			}
+			if !deleted {
+				r.Value = kv.Value
+			}
+			err = opt.response(r)
+			if err != nil {
+				return err
+			}
+		}
+
+		if deleted {
+			delete(prev, key)
+		} else {
+			prev[key] = kv.Value
+		}
+		return nil
+	})
+}
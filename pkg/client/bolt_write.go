@@ -0,0 +1,247 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+// boltIndexEntry is the latest revision of a key.
+type boltIndexEntry struct {
+	revBytes       []byte
+	createRevision int64
+	version        int64
+}
+
+// loadIndex builds the index of the keys on the first write, the caller must hold the mut.
+func (c *boltClient) loadIndex(ctx context.Context) error {
+	if c.index != nil {
+		return nil
+	}
+	index := map[string]boltIndexEntry{}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltKeyBucket).ForEach(func(k, v []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			kv := &mvccpb.KeyValue{}
+			err := kv.Unmarshal(v)
+			if err != nil {
+				return fmt.Errorf("revision %d: %w", boltBytesToRev(k), err)
+			}
+			if len(k) == boltMarkedRevBytesLen && k[boltRevBytesLen] == boltMarkTombstone {
+				delete(index, string(kv.Key))
+				return nil
+			}
+			index[string(kv.Key)] = boltIndexEntry{
+				revBytes:       bytes.Clone(k),
+				createRevision: kv.CreateRevision,
+				version:        kv.Version,
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	c.index = index
+	return nil
+}
+
+// nextRevision returns the revision of the next write.
+func (c *boltClient) nextRevision(tx *bolt.Tx) int64 {
+	var rev int64
+	if k, _ := tx.Bucket(boltKeyBucket).Cursor().Last(); k != nil {
+		rev = boltBytesToRev(k)
+	}
+	if meta := tx.Bucket(boltMetaBucket); meta != nil {
+		if v := meta.Get(boltScheduledCompactKey); len(v) >= boltRevBytesLen {
+			rev = max(rev, boltBytesToRev(v))
+		}
+	}
+	// The revision of an empty etcd is 1, so the first write is at 2
+	return max(rev, 1) + 1
+}
+
+func (c *boltClient) Put(ctx context.Context, prefix string, value []byte, opOpts ...OpOption) error {
+	if !c.writable {
+		return ErrReadOnly
+	}
+
+	opt := opOption(opOpts)
+	key, single, err := opt.Key(prefix)
+	if err != nil {
+		return err
+	}
+	if !single {
+		return fmt.Errorf("put only support single")
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	err = c.loadIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	entry, exists := c.index[key]
+	if opt.modRevision != 0 && (!exists || boltBytesToRev(entry.revBytes) != opt.modRevision) {
+		return fmt.Errorf("%s: %w", key, ErrConflict)
+	}
+
+	var rev int64
+	var prevValue []byte
+	var newEntry boltIndexEntry
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(boltKeyBucket)
+		rev = c.nextRevision(tx)
+
+		kv := &mvccpb.KeyValue{
+			Key:            []byte(key),
+			Value:          value,
+			CreateRevision: rev,
+			ModRevision:    rev,
+			Version:        1,
+		}
+		if exists {
+			prev := &mvccpb.KeyValue{}
+			err := prev.Unmarshal(keys.Get(entry.revBytes))
+			if err != nil {
+				return err
+			}
+			prevValue = prev.Value
+			kv.CreateRevision = entry.createRevision
+			kv.Version = entry.version + 1
+		}
+
+		data, err := kv.Marshal()
+		if err != nil {
+			return err
+		}
+		newEntry = boltIndexEntry{
+			revBytes:       boltRevToBytes(rev, 0, false),
+			createRevision: kv.CreateRevision,
+			version:        kv.Version,
+		}
+		return keys.Put(newEntry.revBytes, data)
+	})
+	if err != nil {
+		return err
+	}
+	c.index[key] = newEntry
+
+	if opt.response != nil {
+		// Like etcd, there is nothing to respond for a new key
+		var r *KeyValue
+		if exists {
+			r = &KeyValue{
+				Key:       []byte(key),
+				Value:     value,
+				PrevValue: prevValue,
+				Revision:  rev,
+			}
+		}
+		err = opt.response(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *boltClient) Delete(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	if !c.writable {
+		return ErrReadOnly
+	}
+
+	opt := opOption(opOpts)
+	path, single, err := opt.Key(prefix)
+	if err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	err = c.loadIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	for key := range c.index {
+		if boltMatch([]byte(key), path, single) {
+			keys = append(keys, key)
+		}
+	}
+	// Deleting nothing does not make a revision
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+
+	// All the keys are deleted in one revision, like a single delete range of etcd
+	var rev int64
+	prevValues := make([][]byte, len(keys))
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltKeyBucket)
+		rev = c.nextRevision(tx)
+		for i, key := range keys {
+			prev := &mvccpb.KeyValue{}
+			err := prev.Unmarshal(bucket.Get(c.index[key].revBytes))
+			if err != nil {
+				return err
+			}
+			prevValues[i] = prev.Value
+
+			data, err := (&mvccpb.KeyValue{Key: []byte(key)}).Marshal()
+			if err != nil {
+				return err
+			}
+			err = bucket.Put(boltRevToBytes(rev, int64(i), true), data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		delete(c.index, key)
+	}
+
+	if opt.response != nil {
+		for i, key := range keys {
+			err = opt.response(&KeyValue{
+				Key:       []byte(key),
+				PrevValue: prevValues[i],
+				Revision:  rev,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
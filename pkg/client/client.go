@@ -18,7 +18,9 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"strings"
+	"time"
 
 	"github.com/etcd-io/auger/pkg/encoding"
 	clientv3 "go.etcd.io/etcd/client/v3"
@@ -32,6 +34,10 @@ type Client interface {
 	Get(ctx context.Context, prefix string, opOpts ...OpOption) (rev int64, err error)
 
 	// Watch is a method that watches for changes to a key-value pair on the etcd server.
+	// Events are delivered in revision order, events of the same transaction share a revision.
+	// If revisions are lost to compaction, the gap callback is called before the error is returned,
+	// so consumers never miss data silently, or before the targets are listed again with WithRelist.
+	// A watch interrupted by the server is resumed after the last delivered revision.
 	Watch(ctx context.Context, prefix string, opOpts ...OpOption) error
 
 	// Delete is a method that deletes a key-value pair from the etcd server.
@@ -44,22 +50,32 @@ type Client interface {
 // client is the etcd client.
 type client struct {
 	client *clientv3.Client
+	// kine makes the writes in the forms supported by kine
+	kine bool
+	// tls is the configuration to reach the HTTP endpoints of the server
+	tls *tls.Config
+	// features is what the server supports, nil until negotiated
+	features *Features
 }
 
 type Config = clientv3.Config
 
-// NewClient creates a new etcd client.
-func NewClient(conf Config) (Client, error) {
+// newEtcdClient creates a new etcd client.
+func newEtcdClient(conf Config) (Client, error) {
 	cli, err := clientv3.New(conf)
 	if err != nil {
 		return nil, err
 	}
 	return &client{
 		client: cli,
+		tls:    conf.TLS,
 	}, nil
 }
 
 func (c *client) getPrefix(prefix string, opt Op) (string, bool, error) {
+	if opt.key != "" {
+		return opt.key, true, nil
+	}
 	var single bool
 	var arr [4]string
 	s := arr[:0]
@@ -91,6 +107,20 @@ type Op struct {
 	pageLimit int64
 	keysOnly  bool
 	revision  int64
+	gap       func(gap Gap) error
+	relist    bool
+	// progress is called with the revisions the watch is known to be complete up to
+	progress         func(revision int64) error
+	progressInterval time.Duration
+	// startAfter is the key the list continues after
+	startAfter string
+	// key is the key of the target as the responses have it, instead of the one of gr, name and namespace
+	key string
+
+	serializable bool
+
+	modRevision int64
+	maxRevision int64
 }
 
 // OpOption is the option for the operation.
@@ -111,6 +141,15 @@ func WithName(name, namespace string) OpOption {
 	}
 }
 
+// WithKey sets the single key of the target as the keys of the responses have it, for a key listed to be written back.
+// The backends of keys target it instead of the key of the gr, name and namespace, which a key codec hashing the names
+// can not recover from it, and the apiserver keeps targeting the gr, name and namespace.
+func WithKey(key string) OpOption {
+	return func(o *Op) {
+		o.key = key
+	}
+}
+
 // WithResponse sets the response callback for the target.
 func WithResponse(response func(kv *KeyValue) error) OpOption {
 	return func(o *Op) {
@@ -139,6 +178,61 @@ func WithRevision(revision int64) OpOption {
 	}
 }
 
+// WithSerializable makes the read served by the local member without a consensus round,
+// it takes load off the leader at the cost of possibly returning stale data.
+func WithSerializable() OpOption {
+	return func(o *Op) {
+		o.serializable = true
+	}
+}
+
+// WithModRevision sets the mod revision that the target must still have for the write to succeed.
+func WithModRevision(modRevision int64) OpOption {
+	return func(o *Op) {
+		o.modRevision = modRevision
+	}
+}
+
+// WithMaxRevision sets the last revision to watch, the watch returns once all the events up to it are delivered.
+func WithMaxRevision(maxRevision int64) OpOption {
+	return func(o *Op) {
+		o.maxRevision = maxRevision
+	}
+}
+
+// WithGap sets the callback for the revision gaps detected while watching.
+func WithGap(gap func(gap Gap) error) OpOption {
+	return func(o *Op) {
+		o.gap = gap
+	}
+}
+
+// WithRelist makes the watch list the targets again when revisions are lost to compaction and continue after the list,
+// instead of returning the error. The listed key-values keep their own revisions, and the deletions in the gap are not delivered.
+func WithRelist() OpOption {
+	return func(o *Op) {
+		o.relist = true
+	}
+}
+
+// WithStartAfter makes the list return only the keys after the key, to continue a list interrupted at it.
+func WithStartAfter(key string) OpOption {
+	return func(o *Op) {
+		o.startAfter = key
+	}
+}
+
+// WithProgress makes the watch request a progress notification at the interval, and call progress with its revision,
+// up to which all the events have been delivered. The watch is resumed after it rather than after the last event.
+// The server only notifies the watches that have caught up, so the revisions mark the watch as alive while idle.
+// Backends without progress notifications never call it.
+func WithProgress(interval time.Duration, progress func(revision int64) error) OpOption {
+	return func(o *Op) {
+		o.progressInterval = interval
+		o.progress = progress
+	}
+}
+
 func opOption(opts []OpOption) Op {
 	var opt Op
 	for _, o := range opts {
@@ -147,11 +241,70 @@ func opOption(opts []OpOption) Op {
 	return opt
 }
 
+// NewOp returns the operation of the options, for the implementations of Client outside of this package.
+func NewOp(opts ...OpOption) Op {
+	return opOption(opts)
+}
+
+// Key returns the key of the target under the prefix, and whether it is a single key rather than a prefix.
+func (o Op) Key(prefix string) (key string, single bool, err error) {
+	if o.key != "" {
+		return o.key, true, nil
+	}
+	return getPrefix(prefix, o.gr, o.name, o.namespace)
+}
+
+// GR returns the gr of the target.
+func (o Op) GR() schema.GroupResource { return o.gr }
+
+// Name returns the name of the target.
+func (o Op) Name() string { return o.name }
+
+// Namespace returns the namespace of the target.
+func (o Op) Namespace() string { return o.namespace }
+
+// Response returns the response callback.
+func (o Op) Response() func(kv *KeyValue) error { return o.response }
+
+// PageLimit returns the page limit.
+func (o Op) PageLimit() int64 { return o.pageLimit }
+
+// KeysOnly returns whether only the keys are wanted.
+func (o Op) KeysOnly() bool { return o.keysOnly }
+
+// Revision returns the revision to read at or to watch from.
+func (o Op) Revision() int64 { return o.revision }
+
+// Serializable returns whether the read may be served by the local member.
+func (o Op) Serializable() bool { return o.serializable }
+
+// ModRevision returns the mod revision that the target must still have for the write to succeed.
+func (o Op) ModRevision() int64 { return o.modRevision }
+
+// MaxRevision returns the last revision to watch.
+func (o Op) MaxRevision() int64 { return o.maxRevision }
+
+// Gap returns the callback for the revision gaps.
+func (o Op) Gap() func(gap Gap) error { return o.gap }
+
+// Relist returns whether the watch lists the targets again after a gap.
+func (o Op) Relist() bool { return o.relist }
+
+// StartAfter returns the key the list continues after.
+func (o Op) StartAfter() string { return o.startAfter }
+
+// Progress returns the callback for the progress of the watch.
+func (o Op) Progress() func(revision int64) error { return o.progress }
+
+// ProgressInterval returns the interval of the progress of the watch.
+func (o Op) ProgressInterval() time.Duration { return o.progressInterval }
+
 // KeyValue is the key-value pair.
 type KeyValue struct {
 	Key       []byte
 	Value     []byte
 	PrevValue []byte
+	Revision  int64
 }
 
 // PrefixFromGR returns the prefix of the given GroupResource.
@@ -24,14 +24,17 @@ import (
 
 func (c *client) Delete(ctx context.Context, prefix string, opOpts ...OpOption) error {
 	opt := opOption(opOpts)
-	prefix, _, err := c.getPrefix(prefix, opt)
+	if c.kine {
+		return c.kineDelete(ctx, prefix, opt)
+	}
+	prefix, single, err := c.getPrefix(prefix, opt)
 	if err != nil {
 		return err
 	}
 
 	opts := []clientv3.OpOption{}
 
-	if opt.name == "" {
+	if !single {
 		opts = append(opts, clientv3.WithPrefix())
 	}
 
@@ -52,6 +55,7 @@ func (c *client) Delete(ctx context.Context, prefix string, opOpts ...OpOption)
 			r := &KeyValue{
 				Key:       kv.Key,
 				PrevValue: kv.Value,
+				Revision:  resp.Header.Revision,
 			}
 			err = opt.response(r)
 			if err != nil {
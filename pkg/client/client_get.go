@@ -34,16 +34,23 @@ func (c *client) Get(ctx context.Context, prefix string, opOpts ...OpOption) (re
 		return 0, fmt.Errorf("response is required")
 	}
 
-	path, single, err := getPrefix(prefix, opt.gr, opt.name, opt.namespace)
+	path, single, err := opt.Key(prefix)
 	if err != nil {
 		return 0, err
 	}
 
 	opts := make([]clientv3.OpOption, 0, 3)
 
-	// specify whether it is a key or a prefix
+	// specify whether it is a key or a prefix, the end of the prefix is kept while the start moves with the pages
 	if !single {
-		opts = append(opts, clientv3.WithPrefix())
+		opts = append(opts, clientv3.WithRange(clientv3.GetPrefixRangeEnd(path)))
+		if opt.pageLimit > 0 {
+			opts = append(opts, clientv3.WithLimit(opt.pageLimit))
+		}
+	}
+
+	if opt.serializable {
+		opts = append(opts, clientv3.WithSerializable())
 	}
 
 	// specify an explicit revision and always use it
@@ -66,7 +73,11 @@ func (c *client) Get(ctx context.Context, prefix string, opOpts ...OpOption) (re
 		return resp.Header.Revision, nil
 	}
 
-	for key := path; ; {
+	key := path
+	if opt.startAfter >= path {
+		key = opt.startAfter + "\x00"
+	}
+	for {
 		resp, err := c.client.Get(ctx, key, opts...)
 		if err != nil {
 			return 0, err
@@ -97,8 +108,9 @@ func (c *client) Get(ctx context.Context, prefix string, opOpts ...OpOption) (re
 func iterateGetList(kvs []*mvccpb.KeyValue, callback func(kv *KeyValue) error) error {
 	for _, kv := range kvs {
 		err := callback(&KeyValue{
-			Key:   kv.Key,
-			Value: kv.Value,
+			Key:      kv.Key,
+			Value:    kv.Value,
+			Revision: kv.ModRevision,
 		})
 		if err != nil {
 			return err
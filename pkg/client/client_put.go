@@ -18,11 +18,15 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
+// ErrConflict is returned when the target has been modified since the given mod revision.
+var ErrConflict = errors.New("the object has been modified")
+
 func (c *client) Put(ctx context.Context, prefix string, value []byte, opOpts ...OpOption) error {
 	opt := opOption(opOpts)
 	prefix, single, err := c.getPrefix(prefix, opt)
@@ -32,6 +36,9 @@ func (c *client) Put(ctx context.Context, prefix string, value []byte, opOpts ..
 	if !single {
 		return fmt.Errorf("put only support single")
 	}
+	if c.kine {
+		return c.kinePut(ctx, prefix, value, opt)
+	}
 
 	opts := []clientv3.OpOption{}
 
@@ -42,9 +49,25 @@ func (c *client) Put(ctx context.Context, prefix string, value []byte, opOpts ..
 		opts = append(opts, clientv3.WithPrevKV())
 	}
 
-	resp, err := c.client.Put(ctx, prefix, string(value), opts...)
-	if err != nil {
-		return err
+	var resp *clientv3.PutResponse
+	if opt.modRevision != 0 {
+		txnResp, err := c.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(prefix), "=", opt.modRevision)).
+			Then(clientv3.OpPut(prefix, string(value), opts...)).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if !txnResp.Succeeded {
+			return fmt.Errorf("%s: %w", prefix, ErrConflict)
+		}
+		resp = (*clientv3.PutResponse)(txnResp.Responses[0].GetResponsePut())
+		resp.Header = txnResp.Header
+	} else {
+		resp, err = c.client.Put(ctx, prefix, string(value), opts...)
+		if err != nil {
+			return err
+		}
 	}
 
 	if opt.response != nil {
@@ -54,6 +77,7 @@ func (c *client) Put(ctx context.Context, prefix string, value []byte, opOpts ..
 				Key:       resp.PrevKv.Key,
 				Value:     value,
 				PrevValue: resp.PrevKv.Value,
+				Revision:  resp.Header.Revision,
 			}
 		}
 		err = opt.response(r)
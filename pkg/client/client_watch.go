@@ -18,18 +18,24 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
+// watchResumeDelay is the wait before a watch interrupted by the server is resumed.
+const watchResumeDelay = 500 * time.Millisecond
+
 func (c *client) Watch(ctx context.Context, prefix string, opOpts ...OpOption) error {
 	opt := opOption(opOpts)
 	if opt.response == nil {
 		return fmt.Errorf("response is required")
 	}
 
-	prefix, single, err := c.getPrefix(prefix, opt)
+	key, single, err := c.getPrefix(prefix, opt)
 	if err != nil {
 		return err
 	}
@@ -43,27 +49,144 @@ func (c *client) Watch(ctx context.Context, prefix string, opOpts ...OpOption) e
 		opts = append(opts, clientv3.WithPrefix())
 	}
 
-	if opt.revision != 0 {
-		opts = append(opts, clientv3.WithRev(opt.revision))
+	opts = append(opts, clientv3.WithPrevKV())
+
+	if opt.progress != nil || (opt.maxRevision != 0 && !c.requestsProgress()) {
+		opts = append(opts, clientv3.WithProgressNotify())
 	}
 
-	opts = append(opts, clientv3.WithPrevKV())
+	tracker := newRevisionTracker(opt.revision)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	switch {
+	case !c.requestsProgress():
+		// The server only sends the periodic progress notifications
+	case opt.maxRevision != 0:
+		// Events of other keys are not delivered,
+		// so progress notifications are the only way to know that the max revision has passed.
+		go c.requestProgress(ctx, 500*time.Millisecond)
+	case opt.progress != nil:
+		go c.requestProgress(ctx, opt.progressInterval)
+	}
+
+	relist := func() (int64, error) {
+		return c.Get(ctx, prefix, append(opOpts, WithRevision(0))...)
+	}
+
+	next := opt.revision
+	for {
+		resume, err := c.watchOnce(ctx, key, opts, opt, tracker, &next, relist)
+		if err != nil || !resume {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watchResumeDelay):
+		}
+	}
+}
+
+// watchOnce watches from the next revision, which it advances as the events are delivered,
+// and returns whether the watch is to be resumed from it.
+func (c *client) watchOnce(ctx context.Context, key string, opts []clientv3.OpOption, opt Op, tracker *revisionTracker, next *int64, relist func() (int64, error)) (bool, error) {
+	if *next != 0 {
+		opts = append(opts, clientv3.WithRev(*next))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The server cancels the watch when its member loses the leader, rather than leaving it stalled
+	watchChan := c.client.Watch(clientv3.WithRequireLeader(ctx), key, opts...)
 
-	watchChan := c.client.Watch(ctx, prefix, opts...)
 	for watchResp := range watchChan {
+		if watchResp.IsProgressNotify() {
+			if opt.maxRevision != 0 && watchResp.Header.Revision >= opt.maxRevision {
+				return false, nil
+			}
+			if opt.progress != nil && watchResp.Header.Revision+1 >= *next {
+				err := opt.progress(watchResp.Header.Revision)
+				if err != nil {
+					return false, err
+				}
+				// All the events up to the revision have been delivered
+				*next = watchResp.Header.Revision + 1
+			}
+		}
+
+		if watchResp.CompactRevision != 0 {
+			if opt.gap != nil {
+				if gap, ok := tracker.compacted(watchResp.CompactRevision); ok {
+					err := opt.gap(gap)
+					if err != nil {
+						return false, err
+					}
+				}
+			}
+			if opt.relist {
+				rev, err := relist()
+				if err != nil {
+					return false, err
+				}
+				tracker.reset(rev)
+				*next = rev + 1
+				return true, nil
+			}
+		}
+		if err := watchResp.Err(); err != nil {
+			if errors.Is(err, rpctypes.ErrNoLeader) {
+				return true, nil
+			}
+			return false, err
+		}
+
+		if *next == 0 {
+			*next = watchResp.Header.Revision + 1
+		}
 		for _, event := range watchResp.Events {
+			if opt.maxRevision != 0 && event.Kv.ModRevision > opt.maxRevision {
+				return false, nil
+			}
+			err := tracker.observe(event.Kv.ModRevision)
+			if err != nil {
+				return false, err
+			}
 			r := &KeyValue{
-				Key:   event.Kv.Key,
-				Value: event.Kv.Value,
+				Key:      event.Kv.Key,
+				Value:    event.Kv.Value,
+				Revision: event.Kv.ModRevision,
 			}
 			if event.PrevKv != nil {
 				r.PrevValue = event.PrevKv.Value
 			}
-			err := opt.response(r)
+			err = opt.response(r)
 			if err != nil {
-				return err
+				return false, err
 			}
+			*next = event.Kv.ModRevision + 1
+		}
+	}
+
+	// The channel is closed without an error once the ctx is done,
+	// otherwise the server has ended the watch and it is resumed.
+	return ctx.Err() == nil, nil
+}
+
+// requestProgress periodically requests a progress notification until the ctx is done,
+// the server only sends it once the watch is synced.
+func (c *client) requestProgress(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// The request goes to the stream of the watch, which is keyed by the metadata of its ctx
+			_ = c.client.RequestProgress(clientv3.WithRequireLeader(ctx))
 		}
 	}
-	return nil
 }
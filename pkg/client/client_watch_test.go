@@ -0,0 +1,122 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"google.golang.org/grpc"
+)
+
+// progressWatchServer sends an event at the revision 5 on the creation of a watch,
+// and answers the progress requests with the revision 7, as a cluster idle after it.
+type progressWatchServer struct {
+	etcdserverpb.UnimplementedWatchServer
+	progressNotify chan bool
+}
+
+func (s *progressWatchServer) Watch(stream etcdserverpb.Watch_WatchServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if create := req.GetCreateRequest(); create != nil {
+			s.progressNotify <- create.ProgressNotify
+			err = stream.Send(&etcdserverpb.WatchResponse{
+				Header:  &etcdserverpb.ResponseHeader{Revision: 5},
+				Created: true,
+			})
+			if err != nil {
+				return err
+			}
+			err = stream.Send(&etcdserverpb.WatchResponse{
+				Header: &etcdserverpb.ResponseHeader{Revision: 5},
+				Events: []*mvccpb.Event{
+					{Kv: &mvccpb.KeyValue{Key: []byte("/registry/configmaps/default/a"), Value: []byte("a"), ModRevision: 5}},
+				},
+			})
+		} else if req.GetProgressRequest() != nil {
+			err = stream.Send(&etcdserverpb.WatchResponse{
+				Header:  &etcdserverpb.ResponseHeader{Revision: 7},
+				WatchId: -1,
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestWatchProgress(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	ws := &progressWatchServer{progressNotify: make(chan bool, 1)}
+	etcdserverpb.RegisterWatchServer(srv, ws)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	defer srv.Stop()
+
+	c, err := newEtcdClient(Config{
+		Endpoints:   []string{lis.Addr().String()},
+		DialTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var events []int64
+	var progress []int64
+	err = c.Watch(ctx, "/registry",
+		WithResponse(func(kv *KeyValue) error {
+			events = append(events, kv.Revision)
+			return nil
+		}),
+		WithProgress(10*time.Millisecond, func(revision int64) error {
+			progress = append(progress, revision)
+			// The watch is alive while idle
+			if len(progress) == 2 {
+				cancel()
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !<-ws.progressNotify {
+		t.Errorf("watch is created without the progress notifications")
+	}
+	if len(events) != 1 || events[0] != 5 {
+		t.Errorf("events = %v, want [5]", events)
+	}
+	if len(progress) != 2 || progress[0] != 7 || progress[1] != 7 {
+		t.Errorf("progress = %v, want [7 7]", progress)
+	}
+}
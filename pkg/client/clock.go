@@ -0,0 +1,81 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrServerTimeUnsupported is returned by ServerTime for the clients not reaching an etcd server.
+var ErrServerTimeUnsupported = errors.New("the time of the server is not available from the backend")
+
+// ServerTime returns the time of the etcd server, as reported by the Date header of its version endpoint.
+// The time has the precision of a second, the one of the header.
+func ServerTime(ctx context.Context, c Client) (time.Time, error) {
+	inner, _ := unwrapHook(c)
+	inner, _ = unwrapKeyCodec(inner)
+	inner, _ = unwrapNamespace(inner)
+	cli, ok := inner.(*client)
+	if !ok || cli.kine {
+		return time.Time{}, ErrServerTimeUnsupported
+	}
+	return cli.serverTime(ctx)
+}
+
+func (c *client) serverTime(ctx context.Context) (time.Time, error) {
+	endpoints := c.client.Endpoints()
+	if len(endpoints) == 0 {
+		return time.Time{}, ErrServerTimeUnsupported
+	}
+
+	scheme := "http"
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.tls != nil {
+		scheme = "https"
+		transport.TLSClientConfig = c.tls.Clone()
+	}
+	defer transport.CloseIdleConnections()
+
+	endpoint := endpoints[0]
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		endpoint = endpoint[i+3:]
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+endpoint+"/version", nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	date := resp.Header.Get("Date")
+	if date == "" {
+		return time.Time{}, fmt.Errorf("no date in the response of %s: %w", req.URL, ErrServerTimeUnsupported)
+	}
+	t, err := http.ParseTime(date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse the date of the response of %s: %w", req.URL, err)
+	}
+	return t, nil
+}
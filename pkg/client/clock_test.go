@@ -0,0 +1,61 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+)
+
+func TestServerTime(t *testing.T) {
+	date := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Date", date.Format(http.TimeFormat))
+		_, _ = w.Write([]byte(`{"etcdserver":"3.5.0"}`))
+	}))
+	defer server.Close()
+
+	c, err := client.NewClientWithBackend("etcd", client.Config{Endpoints: []string{server.Listener.Addr().String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = client.WithNamespace(c, "/cluster-a")
+
+	got, err := client.ServerTime(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(date) {
+		t.Errorf("got %v, want %v", got, date)
+	}
+
+	_, err = client.ServerTime(context.Background(), fake.NewClient())
+	if !errors.Is(err, client.ErrServerTimeUnsupported) {
+		t.Errorf("got %v for the fake client, want %v", err, client.ErrServerTimeUnsupported)
+	}
+}
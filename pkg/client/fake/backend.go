@@ -0,0 +1,53 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+// The in-memory stores, e.g. mem:// or mem://name, for the commands to try out on without an etcd.
+func init() {
+	client.RegisterBackend("mem", client.BackendFunc(newMemClient))
+}
+
+var (
+	storesMut sync.Mutex
+	stores    = map[string]*Client{}
+)
+
+// newMemClient returns the in-memory store of the name of the only endpoint, created empty on its first use,
+// so that the clients of the same name in the process share it.
+func newMemClient(conf client.Config) (client.Client, error) {
+	if len(conf.Endpoints) != 1 {
+		return nil, fmt.Errorf("exactly one mem endpoint is required, got %d", len(conf.Endpoints))
+	}
+	name := strings.TrimPrefix(conf.Endpoints[0], "mem://")
+
+	storesMut.Lock()
+	defer storesMut.Unlock()
+	c, ok := stores[name]
+	if !ok {
+		c = NewClient()
+		stores[name] = c
+	}
+	return c, nil
+}
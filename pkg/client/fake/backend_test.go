@@ -0,0 +1,52 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+func TestMemBackend(t *testing.T) {
+	newClient := func(endpoint string) *Client {
+		t.Helper()
+		c, err := client.NewClient(client.Config{Endpoints: []string{endpoint}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.(*Client)
+	}
+
+	a := newClient("mem://a")
+	put(t, a, "default", "pod", "value")
+
+	// The clients of the same name share the store
+	if got := newClient("mem://a").Revision(); got != 2 {
+		t.Errorf("revision of mem://a = %d, want 2", got)
+	}
+	if got := newClient("mem://b").Revision(); got != 1 {
+		t.Errorf("revision of mem://b = %d, want 1", got)
+	}
+
+	err := client.ReadOnly(a).Put(context.Background(), "/registry", []byte("value"), client.WithGR(pods), client.WithName("pod", "default"))
+	if !errors.Is(err, client.ErrReadOnly) {
+		t.Errorf("Put() of the read-only client error = %v, want %v", err, client.ErrReadOnly)
+	}
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake provides an in-memory client.Client for tests, without an etcd.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+// event is a change of a key at a revision, a deletion has no value.
+type event struct {
+	key      string
+	value    []byte
+	revision int64
+}
+
+// Client is an in-memory client.Client with the revisions, compaction and watch semantics of etcd.
+type Client struct {
+	mut        sync.Mutex
+	revision   int64
+	compactRev int64
+	history    []event
+	// changed is closed and replaced on every change, to wake up the watchers
+	changed chan struct{}
+}
+
+var _ client.Client = (*Client)(nil)
+
+// NewClient creates a new empty Client at revision 1, like a new etcd.
+func NewClient() *Client {
+	return &Client{
+		revision: 1,
+		changed:  make(chan struct{}),
+	}
+}
+
+// Revision returns the current revision.
+func (c *Client) Revision() int64 {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.revision
+}
+
+// Compact discards the history before the revision, reads and watches before it fail with rpctypes.ErrCompacted.
+func (c *Client) Compact(revision int64) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if revision > c.revision {
+		return rpctypes.ErrFutureRev
+	}
+	if revision <= c.compactRev {
+		return rpctypes.ErrCompacted
+	}
+	c.compactRev = revision
+	return nil
+}
+
+type keyValue struct {
+	value       []byte
+	modRevision int64
+}
+
+// stateAt returns the key-values as of the revision.
+func (c *Client) stateAt(revision int64) map[string]keyValue {
+	state := map[string]keyValue{}
+	for _, e := range c.history {
+		if e.revision > revision {
+			break
+		}
+		if e.value == nil {
+			delete(state, e.key)
+		} else {
+			state[e.key] = keyValue{value: e.value, modRevision: e.revision}
+		}
+	}
+	return state
+}
+
+func match(key, target string, single bool) bool {
+	if single {
+		return key == target
+	}
+	return strings.HasPrefix(key, target)
+}
+
+// matchedKeys returns the keys of the state matching the target in order.
+func matchedKeys(state map[string]keyValue, target string, single bool) []string {
+	var keys []string
+	for key := range state {
+		if match(key, target, single) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *Client) Get(ctx context.Context, prefix string, opOpts ...client.OpOption) (int64, error) {
+	if prefix == "" {
+		return 0, fmt.Errorf("prefix is required")
+	}
+	opt := client.NewOp(opOpts...)
+	if opt.Response() == nil {
+		return 0, fmt.Errorf("response is required")
+	}
+	target, single, err := opt.Key(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mut.Lock()
+	rev := c.revision
+	if opt.Revision() != 0 {
+		rev = opt.Revision()
+	}
+	if rev < c.compactRev {
+		c.mut.Unlock()
+		return 0, rpctypes.ErrCompacted
+	}
+	if rev > c.revision {
+		c.mut.Unlock()
+		return 0, rpctypes.ErrFutureRev
+	}
+	state := c.stateAt(rev)
+	c.mut.Unlock()
+
+	// The callbacks run unlocked, so that they can write
+	for _, key := range matchedKeys(state, target, single) {
+		if opt.StartAfter() != "" && key <= opt.StartAfter() {
+			continue
+		}
+		kv := &client.KeyValue{
+			Key:      []byte(key),
+			Revision: state[key].modRevision,
+		}
+		if !opt.KeysOnly() {
+			kv.Value = state[key].value
+		}
+		err = opt.Response()(kv)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return rev, nil
+}
+
+// commit appends the events at the next revision and wakes up the watchers, it must be called locked.
+func (c *Client) commit(events []event) int64 {
+	c.revision++
+	for i := range events {
+		events[i].revision = c.revision
+	}
+	c.history = append(c.history, events...)
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return c.revision
+}
+
+func (c *Client) Put(ctx context.Context, prefix string, value []byte, opOpts ...client.OpOption) error {
+	opt := client.NewOp(opOpts...)
+	key, single, err := opt.Key(prefix)
+	if err != nil {
+		return err
+	}
+	if !single {
+		return fmt.Errorf("put only support single")
+	}
+
+	c.mut.Lock()
+	prev, exists := c.stateAt(c.revision)[key]
+	if opt.ModRevision() != 0 && opt.ModRevision() != prev.modRevision {
+		c.mut.Unlock()
+		return fmt.Errorf("%s: %w", key, client.ErrConflict)
+	}
+	value = append([]byte(nil), value...)
+	rev := c.commit([]event{{key: key, value: value}})
+	c.mut.Unlock()
+
+	if opt.Response() != nil {
+		var r *client.KeyValue
+		if exists {
+			r = &client.KeyValue{
+				Key:      []byte(key),
+				Value:    value,
+				Revision: rev,
+			}
+			if !opt.KeysOnly() {
+				r.PrevValue = prev.value
+			}
+		}
+		return opt.Response()(r)
+	}
+	return nil
+}
+
+func (c *Client) Delete(ctx context.Context, prefix string, opOpts ...client.OpOption) error {
+	opt := client.NewOp(opOpts...)
+	target, single, err := opt.Key(prefix)
+	if err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	state := c.stateAt(c.revision)
+	keys := matchedKeys(state, target, single)
+	if len(keys) == 0 {
+		c.mut.Unlock()
+		return nil
+	}
+	events := make([]event, 0, len(keys))
+	for _, key := range keys {
+		events = append(events, event{key: key})
+	}
+	rev := c.commit(events)
+	c.mut.Unlock()
+
+	if opt.Response() != nil {
+		for _, key := range keys {
+			r := &client.KeyValue{
+				Key:      []byte(key),
+				Revision: rev,
+			}
+			if !opt.KeysOnly() {
+				r.PrevValue = state[key].value
+			}
+			err = opt.Response()(r)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) Watch(ctx context.Context, prefix string, opOpts ...client.OpOption) error {
+	opt := client.NewOp(opOpts...)
+	if opt.Response() == nil {
+		return fmt.Errorf("response is required")
+	}
+	target, single, err := opt.Key(prefix)
+	if err != nil {
+		return err
+	}
+
+	c.mut.Lock()
+	start := opt.Revision()
+	if start == 0 {
+		start = c.revision + 1
+	}
+	if start <= c.compactRev {
+		compactRev := c.compactRev
+		c.mut.Unlock()
+		if gap := opt.Gap(); gap != nil {
+			err = gap(client.Gap{From: start, To: compactRev - 1})
+			if err != nil {
+				return err
+			}
+		}
+		if !opt.Relist() {
+			return rpctypes.ErrCompacted
+		}
+		rev, err := c.Get(ctx, prefix, append(opOpts, client.WithRevision(0))...)
+		if err != nil {
+			return err
+		}
+		start = rev + 1
+	} else {
+		c.mut.Unlock()
+	}
+
+	// prev follows the values of the keys to fill in the previous values of the events
+	var prev map[string]keyValue
+	next := 0
+	for {
+		c.mut.Lock()
+		if prev == nil {
+			prev = c.stateAt(start - 1)
+			for next < len(c.history) && c.history[next].revision < start {
+				next++
+			}
+		}
+		events := c.history[next:]
+		next = len(c.history)
+		current := c.revision
+		changed := c.changed
+		c.mut.Unlock()
+
+		for _, e := range events {
+			if opt.MaxRevision() != 0 && e.revision > opt.MaxRevision() {
+				return nil
+			}
+			p := prev[e.key]
+			if e.value == nil {
+				delete(prev, e.key)
+			} else {
+				prev[e.key] = keyValue{value: e.value, modRevision: e.revision}
+			}
+			if !match(e.key, target, single) {
+				continue
+			}
+			r := &client.KeyValue{
+				Key:      []byte(e.key),
+				Revision: e.revision,
+			}
+			if !opt.KeysOnly() {
+				r.Value = e.value
+				r.PrevValue = p.value
+			}
+			err = opt.Response()(r)
+			if err != nil {
+				return err
+			}
+		}
+
+		if opt.MaxRevision() != 0 && current >= opt.MaxRevision() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		}
+	}
+}
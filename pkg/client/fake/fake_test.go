@@ -0,0 +1,226 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var pods = schema.GroupResource{Resource: "pods"}
+
+func put(t *testing.T, c *Client, namespace, name, value string) {
+	t.Helper()
+	err := c.Put(context.Background(), "/registry", []byte(value), client.WithGR(pods), client.WithName(name, namespace))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func list(t *testing.T, c *Client, opts ...client.OpOption) map[string]string {
+	t.Helper()
+	got := map[string]string{}
+	_, err := c.Get(context.Background(), "/registry", append(opts, client.WithResponse(func(kv *client.KeyValue) error {
+		got[string(kv.Key)] = string(kv.Value)
+		return nil
+	}))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestClient(t *testing.T) {
+	c := NewClient()
+	ctx := context.Background()
+
+	put(t, c, "default", "a", "1")
+	put(t, c, "default", "b", "1")
+	put(t, c, "kube-system", "c", "1")
+	put(t, c, "default", "a", "2")
+
+	want := map[string]string{
+		"/registry/pods/default/a": "2",
+		"/registry/pods/default/b": "1",
+	}
+	if got := list(t, c, client.WithGR(pods), client.WithName("", "default")); !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() = %v, want %v", got, want)
+	}
+
+	want = map[string]string{
+		"/registry/pods/default/a": "1",
+		"/registry/pods/default/b": "1",
+	}
+	if got := list(t, c, client.WithGR(pods), client.WithName("", "default"), client.WithRevision(3)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() at revision 3 = %v, want %v", got, want)
+	}
+
+	err := c.Put(ctx, "/registry", []byte("3"), client.WithGR(pods), client.WithName("a", "default"), client.WithModRevision(2))
+	if !errors.Is(err, client.ErrConflict) {
+		t.Errorf("Put() with a stale mod revision = %v, want %v", err, client.ErrConflict)
+	}
+
+	err = c.Delete(ctx, "/registry", client.WithGR(pods), client.WithName("", "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]string{
+		"/registry/pods/kube-system/c": "1",
+	}
+	if got := list(t, c, client.WithGR(pods)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Get() after Delete() = %v, want %v", got, want)
+	}
+
+	err = c.Compact(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Get(ctx, "/registry", client.WithRevision(2), client.WithResponse(func(kv *client.KeyValue) error { return nil }))
+	if !errors.Is(err, rpctypes.ErrCompacted) {
+		t.Errorf("Get() at a compacted revision = %v, want %v", err, rpctypes.ErrCompacted)
+	}
+}
+
+type change struct {
+	key       string
+	value     string
+	prevValue string
+	revision  int64
+}
+
+func TestClientWatch(t *testing.T) {
+	c := NewClient()
+	put(t, c, "default", "a", "1")
+	put(t, c, "kube-system", "b", "1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var got []change
+	done := make(chan error)
+	go func() {
+		done <- c.Watch(ctx, "/registry",
+			client.WithGR(pods),
+			client.WithName("", "default"),
+			client.WithRevision(2),
+			client.WithMaxRevision(5),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				got = append(got, change{string(kv.Key), string(kv.Value), string(kv.PrevValue), kv.Revision})
+				return nil
+			}),
+		)
+	}()
+
+	put(t, c, "default", "a", "2")
+	err := c.Delete(ctx, "/registry", client.WithGR(pods), client.WithName("a", "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []change{
+		{key: "/registry/pods/default/a", value: "1", revision: 2},
+		{key: "/registry/pods/default/a", value: "2", prevValue: "1", revision: 4},
+		{key: "/registry/pods/default/a", prevValue: "2", revision: 5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Watch() = %v, want %v", got, want)
+	}
+
+	err = c.Compact(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gap client.Gap
+	err = c.Watch(ctx, "/registry",
+		client.WithRevision(2),
+		client.WithGap(func(g client.Gap) error {
+			gap = g
+			return nil
+		}),
+		client.WithResponse(func(kv *client.KeyValue) error { return nil }),
+	)
+	if !errors.Is(err, rpctypes.ErrCompacted) {
+		t.Errorf("Watch() from a compacted revision = %v, want %v", err, rpctypes.ErrCompacted)
+	}
+	if gap != (client.Gap{From: 2, To: 2}) {
+		t.Errorf("gap = %v, want 2-2", gap)
+	}
+}
+
+func TestClientWatchRelist(t *testing.T) {
+	c := NewClient()
+	put(t, c, "default", "a", "1")
+	put(t, c, "kube-system", "b", "1")
+	err := c.Delete(context.Background(), "/registry", client.WithGR(pods), client.WithName("a", "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	put(t, c, "default", "c", "1")
+	err = c.Compact(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var gap client.Gap
+	var got []change
+	err = c.Watch(ctx, "/registry",
+		client.WithGR(pods),
+		client.WithRevision(2),
+		client.WithMaxRevision(6),
+		client.WithRelist(),
+		client.WithGap(func(g client.Gap) error {
+			gap = g
+			return nil
+		}),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			got = append(got, change{string(kv.Key), string(kv.Value), string(kv.PrevValue), kv.Revision})
+			// A change after the list is watched
+			if string(kv.Key) == "/registry/pods/kube-system/b" {
+				put(t, c, "default", "d", "1")
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gap != (client.Gap{From: 2, To: 3}) {
+		t.Errorf("gap = %v, want 2-3", gap)
+	}
+	want := []change{
+		{key: "/registry/pods/default/c", value: "1", revision: 5},
+		{key: "/registry/pods/kube-system/b", value: "1", revision: 3},
+		{key: "/registry/pods/default/d", value: "1", revision: 6},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Watch() = %v, want %v", got, want)
+	}
+}
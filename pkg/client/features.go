@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+// ErrFeaturesUnsupported is returned by NegotiateFeatures for the clients not reaching an etcd server.
+var ErrFeaturesUnsupported = errors.New("the features are only negotiated with etcd")
+
+// Features is what the server supports, negotiated on connecting to it, for the operations to adapt to the server
+// rather than to fail deep into a long one on a request it does not support.
+type Features struct {
+	// Version is the version of etcd, or of the etcd API served by kine
+	Version string `json:"version"`
+	Kine    bool   `json:"kine,omitempty"`
+	// AuthEnabled is whether the authentication is enabled, nil if it is unknown, as of the servers before etcd 3.5
+	AuthEnabled *bool `json:"authEnabled,omitempty"`
+	// RequestProgress is whether the server answers the requests of progress notifications of the watches, from etcd 3.4.
+	// Without it, the watches rely on the periodic progress notifications of the server.
+	RequestProgress bool `json:"requestProgress"`
+	// Warnings tells what the operations do differently with the server, or may fail at
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+var (
+	versionRequestProgress = utilversion.MustParseGeneric("3.4.0")
+	versionAuthStatus      = utilversion.MustParseGeneric("3.5.0")
+)
+
+// NegotiateFeatures detects the features of the etcd server, which the client adapts to from then on.
+func NegotiateFeatures(ctx context.Context, c Client) (*Features, error) {
+	inner, _ := unwrapHook(c)
+	inner, _ = unwrapKeyCodec(inner)
+	inner, _ = unwrapNamespace(inner)
+	cli, ok := inner.(*client)
+	if !ok {
+		return nil, ErrFeaturesUnsupported
+	}
+	features, err := cli.detectFeatures(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cli.features = features
+	return features, nil
+}
+
+// FeaturesOf returns the features negotiated with the server of the client, nil if they are not.
+func FeaturesOf(c Client) *Features {
+	inner, _ := unwrapHook(c)
+	inner, _ = unwrapKeyCodec(inner)
+	inner, _ = unwrapNamespace(inner)
+	cli, ok := inner.(*client)
+	if !ok {
+		return nil
+	}
+	return cli.features
+}
+
+func (c *client) detectFeatures(ctx context.Context) (*Features, error) {
+	endpoints := c.client.Endpoints()
+	if len(endpoints) == 0 {
+		return nil, ErrFeaturesUnsupported
+	}
+	status, err := c.client.Status(ctx, endpoints[0])
+	if err != nil {
+		return nil, fmt.Errorf("status of %s: %w", endpoints[0], err)
+	}
+
+	features := &Features{
+		Version:         status.Version,
+		Kine:            c.kine,
+		RequestProgress: true,
+	}
+	version, err := utilversion.ParseGeneric(status.Version)
+	if err != nil {
+		features.Warnings = append(features.Warnings,
+			fmt.Sprintf("unknown version %q of the server, the features of the latest etcd are assumed", status.Version))
+		return features, nil
+	}
+	if !version.AtLeast(versionRequestProgress) {
+		features.RequestProgress = false
+		features.Warnings = append(features.Warnings,
+			fmt.Sprintf("etcd %s does not answer the requests of progress notifications, from 3.4: the watches up to a revision and the progress of the idle watches wait for the periodic progress notifications of the server", status.Version))
+	}
+	if version.AtLeast(versionAuthStatus) {
+		resp, err := c.client.AuthStatus(ctx)
+		// The status is denied to the users without the root role on some versions, the authentication is unknown then
+		if err == nil {
+			enabled := resp.Enabled
+			features.AuthEnabled = &enabled
+		}
+	}
+	return features, nil
+}
+
+// requestsProgress returns whether the server answers the requests of progress notifications, assumed unless the features tell otherwise.
+func (c *client) requestsProgress() bool {
+	return c.features == nil || c.features.RequestProgress
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// featuresServer reports the version, and the authentication from the versions supporting its status.
+type featuresServer struct {
+	etcdserverpb.UnimplementedMaintenanceServer
+	etcdserverpb.UnimplementedAuthServer
+	version     string
+	authEnabled bool
+}
+
+func (s *featuresServer) Status(ctx context.Context, req *etcdserverpb.StatusRequest) (*etcdserverpb.StatusResponse, error) {
+	return &etcdserverpb.StatusResponse{Header: &etcdserverpb.ResponseHeader{}, Version: s.version}, nil
+}
+
+func (s *featuresServer) AuthStatus(ctx context.Context, req *etcdserverpb.AuthStatusRequest) (*etcdserverpb.AuthStatusResponse, error) {
+	if s.version < "3.5" {
+		return nil, status.Error(codes.Unimplemented, "unknown method AuthStatus")
+	}
+	return &etcdserverpb.AuthStatusResponse{Header: &etcdserverpb.ResponseHeader{}, Enabled: s.authEnabled}, nil
+}
+
+func TestNegotiateFeatures(t *testing.T) {
+	enabled := true
+	tests := []struct {
+		name                string
+		version             string
+		authEnabled         bool
+		wantRequestProgress bool
+		wantAuthEnabled     *bool
+		wantWarnings        int
+	}{
+		{
+			name:                "latest",
+			version:             "3.5.9",
+			authEnabled:         true,
+			wantRequestProgress: true,
+			wantAuthEnabled:     &enabled,
+		},
+		{
+			name:                "without requests of progress",
+			version:             "3.3.27",
+			wantRequestProgress: false,
+			wantWarnings:        1,
+		},
+		{
+			name:                "unknown version",
+			version:             "unknown",
+			wantRequestProgress: true,
+			wantWarnings:        1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := grpc.NewServer()
+			fs := &featuresServer{version: tt.version, authEnabled: tt.authEnabled}
+			etcdserverpb.RegisterMaintenanceServer(srv, fs)
+			etcdserverpb.RegisterAuthServer(srv, fs)
+			go func() {
+				_ = srv.Serve(lis)
+			}()
+			defer srv.Stop()
+
+			c, err := newEtcdClient(Config{
+				Endpoints:   []string{lis.Addr().String()},
+				DialTimeout: time.Second,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			features, err := NegotiateFeatures(ctx, WithNamespace(c, "/tenant"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if features.Version != tt.version || features.RequestProgress != tt.wantRequestProgress || len(features.Warnings) != tt.wantWarnings {
+				t.Errorf("got %+v", features)
+			}
+			if (features.AuthEnabled == nil) != (tt.wantAuthEnabled == nil) ||
+				(features.AuthEnabled != nil && *features.AuthEnabled != *tt.wantAuthEnabled) {
+				t.Errorf("got auth enabled %v, want %v", features.AuthEnabled, tt.wantAuthEnabled)
+			}
+			if c.(*client).requestsProgress() != tt.wantRequestProgress {
+				t.Errorf("the client does not adapt to the features")
+			}
+		})
+	}
+}
+
+func TestWatchWithoutRequestProgress(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	ws := &progressWatchServer{progressNotify: make(chan bool, 1)}
+	etcdserverpb.RegisterWatchServer(srv, ws)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	defer srv.Stop()
+
+	c, err := newEtcdClient(Config{
+		Endpoints:   []string{lis.Addr().String()},
+		DialTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(*client).features = &Features{Version: "3.3.27"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	err = c.Watch(ctx, "/registry", WithMaxRevision(6), WithResponse(func(kv *KeyValue) error {
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The watch relies on the periodic notifications of the server, without requesting them
+	if !<-ws.progressNotify {
+		t.Errorf("watch is created without the progress notifications")
+	}
+}
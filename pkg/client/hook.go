@@ -0,0 +1,229 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Hook observes the operations of a client, to layer metrics, audit logging or progress reporting on all the commands.
+// The methods are called synchronously as the operations complete, so they are to be quick.
+type Hook interface {
+	// OnGet is called once a Get completes, with the key-values delivered to its response.
+	OnGet(ctx context.Context, info OpInfo)
+	// OnPut is called once a Put completes, with the bytes of the value.
+	// The puts committed together by a Batch share the latency of their transaction.
+	OnPut(ctx context.Context, info OpInfo)
+	// OnDelete is called once a Delete completes, with the key-values delivered to its response.
+	OnDelete(ctx context.Context, info OpInfo)
+	// OnWatchEvent is called once each event of a Watch has been handled by its response.
+	OnWatchEvent(ctx context.Context, info WatchEventInfo)
+}
+
+// OpInfo is the information of a completed operation.
+type OpInfo struct {
+	// Key is the key or the prefix of the keys of the operation.
+	Key string
+	// Revision is the revision of the operation, 0 if it is not known such as for a failed one.
+	Revision int64
+	// Count is the number of the key-values delivered to the response, or 1 for a Put.
+	Count int
+	// Bytes is the number of the bytes of the key-values delivered to the response, or of the value put.
+	Bytes int64
+	// Latency is the time the operation takes, including the response.
+	Latency time.Duration
+	// Err is the error of the operation.
+	Err error
+}
+
+// WatchEventInfo is the information of an event of a watch.
+type WatchEventInfo struct {
+	// Key is the key of the event.
+	Key string
+	// Revision is the revision of the event.
+	Revision int64
+	// Deleted is whether the event is a deletion.
+	Deleted bool
+	// Bytes is the number of the bytes of the value, or of the previous value of a deletion.
+	Bytes int64
+	// Latency is the time the response takes to handle the event.
+	Latency time.Duration
+	// Err is the error returned by the response.
+	Err error
+}
+
+// WithHook returns the client calling the hook for the operations of c.
+func WithHook(c Client, hook Hook) Client {
+	return &hookClient{
+		Client: c,
+		hook:   hook,
+	}
+}
+
+type hookClient struct {
+	Client
+	hook Hook
+}
+
+// unwrapHook returns the client under the hook, and the hook if there is one.
+func unwrapHook(c Client) (Client, Hook) {
+	if h, ok := c.(*hookClient); ok {
+		return h.Client, h.hook
+	}
+	return c, nil
+}
+
+// observe replaces the response of the operation with the one counting the key-values delivered to it.
+func observe(prefix string, opOpts []OpOption, info *OpInfo) []OpOption {
+	opt := opOption(opOpts)
+	key, _, _ := opt.Key(prefix)
+	info.Key = key
+	if opt.response == nil {
+		return opOpts
+	}
+	response := opt.response
+	return append(opOpts, WithResponse(func(kv *KeyValue) error {
+		if kv != nil {
+			info.Count++
+			info.Bytes += int64(len(kv.Value) + len(kv.PrevValue))
+			if kv.Revision > info.Revision {
+				info.Revision = kv.Revision
+			}
+		}
+		return response(kv)
+	}))
+}
+
+func (c *hookClient) Get(ctx context.Context, prefix string, opOpts ...OpOption) (int64, error) {
+	var info OpInfo
+	opOpts = observe(prefix, opOpts, &info)
+	start := time.Now()
+	rev, err := c.Client.Get(ctx, prefix, opOpts...)
+	info.Latency = time.Since(start)
+	info.Revision = rev
+	info.Err = err
+	c.hook.OnGet(ctx, info)
+	return rev, err
+}
+
+func (c *hookClient) Put(ctx context.Context, prefix string, value []byte, opOpts ...OpOption) error {
+	key, _, _ := opOption(opOpts).Key(prefix)
+	start := time.Now()
+	err := c.Client.Put(ctx, prefix, value, opOpts...)
+	c.hook.OnPut(ctx, OpInfo{
+		Key:     key,
+		Count:   1,
+		Bytes:   int64(len(value)),
+		Latency: time.Since(start),
+		Err:     err,
+	})
+	return err
+}
+
+func (c *hookClient) Delete(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	var info OpInfo
+	opOpts = observe(prefix, opOpts, &info)
+	start := time.Now()
+	err := c.Client.Delete(ctx, prefix, opOpts...)
+	info.Latency = time.Since(start)
+	info.Err = err
+	c.hook.OnDelete(ctx, info)
+	return err
+}
+
+func (c *hookClient) Watch(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	opt := opOption(opOpts)
+	if opt.response == nil {
+		return c.Client.Watch(ctx, prefix, opOpts...)
+	}
+	response := opt.response
+	return c.Client.Watch(ctx, prefix, append(opOpts, WithResponse(func(kv *KeyValue) error {
+		info := WatchEventInfo{
+			Key:      string(kv.Key),
+			Revision: kv.Revision,
+			Deleted:  len(kv.Value) == 0,
+			Bytes:    int64(len(kv.Value)),
+		}
+		if info.Deleted {
+			info.Bytes = int64(len(kv.PrevValue))
+		}
+		start := time.Now()
+		err := response(kv)
+		info.Latency = time.Since(start)
+		info.Err = err
+		c.hook.OnWatchEvent(ctx, info)
+		return err
+	}))...)
+}
+
+// NewSlogHook returns the hook logging the operations to the logger, at the debug level,
+// or at the error level for the failed ones.
+func NewSlogHook(logger *slog.Logger) Hook {
+	return &slogHook{
+		logger: logger,
+	}
+}
+
+type slogHook struct {
+	logger *slog.Logger
+}
+
+func (h *slogHook) logOp(ctx context.Context, op string, info OpInfo) {
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("key", info.Key),
+		slog.Int64("revision", info.Revision),
+		slog.Int("count", info.Count),
+		slog.Int64("bytes", info.Bytes),
+		slog.Duration("latency", info.Latency),
+	}
+	if info.Err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("err", info.Err))
+	}
+	h.logger.LogAttrs(ctx, level, op, attrs...)
+}
+
+func (h *slogHook) OnGet(ctx context.Context, info OpInfo) {
+	h.logOp(ctx, "get", info)
+}
+
+func (h *slogHook) OnPut(ctx context.Context, info OpInfo) {
+	h.logOp(ctx, "put", info)
+}
+
+func (h *slogHook) OnDelete(ctx context.Context, info OpInfo) {
+	h.logOp(ctx, "delete", info)
+}
+
+func (h *slogHook) OnWatchEvent(ctx context.Context, info WatchEventInfo) {
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("key", info.Key),
+		slog.Int64("revision", info.Revision),
+		slog.Bool("deleted", info.Deleted),
+		slog.Int64("bytes", info.Bytes),
+		slog.Duration("latency", info.Latency),
+	}
+	if info.Err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("err", info.Err))
+	}
+	h.logger.LogAttrs(ctx, level, "watch event", attrs...)
+}
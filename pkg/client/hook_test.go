@@ -0,0 +1,99 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type recordHook struct {
+	ops    []string
+	infos  []client.OpInfo
+	events []client.WatchEventInfo
+}
+
+func (h *recordHook) record(op string, info client.OpInfo) {
+	// The latency varies from run to run
+	info.Latency = 0
+	h.ops = append(h.ops, op)
+	h.infos = append(h.infos, info)
+}
+
+func (h *recordHook) OnGet(ctx context.Context, info client.OpInfo)    { h.record("get", info) }
+func (h *recordHook) OnPut(ctx context.Context, info client.OpInfo)    { h.record("put", info) }
+func (h *recordHook) OnDelete(ctx context.Context, info client.OpInfo) { h.record("delete", info) }
+func (h *recordHook) OnWatchEvent(ctx context.Context, info client.WatchEventInfo) {
+	info.Latency = 0
+	h.events = append(h.events, info)
+}
+
+func TestWithHook(t *testing.T) {
+	hook := &recordHook{}
+	etcdclient := client.WithHook(fake.NewClient(), hook)
+	ctx := context.Background()
+	configmaps := client.WithGR(schema.GroupResource{Resource: "configmaps"})
+	nop := client.WithResponse(func(kv *client.KeyValue) error { return nil })
+
+	err := etcdclient.Put(ctx, "/registry", []byte("abc"), configmaps, client.WithName("a", "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = etcdclient.Put(ctx, "/registry", []byte("de"), configmaps, client.WithName("b", "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = etcdclient.Get(ctx, "/registry", configmaps, client.WithName("", "default"), nop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = etcdclient.Delete(ctx, "/registry", configmaps, client.WithName("a", "default"), nop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = etcdclient.Watch(ctx, "/registry", configmaps, client.WithRevision(1), client.WithMaxRevision(4), nop)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantOps := []string{"put", "put", "get", "delete"}
+	if !reflect.DeepEqual(hook.ops, wantOps) {
+		t.Errorf("ops = %v, want %v", hook.ops, wantOps)
+	}
+	wantInfos := []client.OpInfo{
+		{Key: "/registry/configmaps/default/a", Count: 1, Bytes: 3},
+		{Key: "/registry/configmaps/default/b", Count: 1, Bytes: 2},
+		{Key: "/registry/configmaps/default/", Revision: 3, Count: 2, Bytes: 5},
+		{Key: "/registry/configmaps/default/a", Revision: 4, Count: 1, Bytes: 3},
+	}
+	if !reflect.DeepEqual(hook.infos, wantInfos) {
+		t.Errorf("infos = %+v, want %+v", hook.infos, wantInfos)
+	}
+	wantEvents := []client.WatchEventInfo{
+		{Key: "/registry/configmaps/default/a", Revision: 2, Bytes: 3},
+		{Key: "/registry/configmaps/default/b", Revision: 3, Bytes: 2},
+		{Key: "/registry/configmaps/default/a", Revision: 4, Deleted: true, Bytes: 3},
+	}
+	if !reflect.DeepEqual(hook.events, wantEvents) {
+		t.Errorf("events = %+v, want %+v", hook.events, wantEvents)
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// KeyCodec maps the names and namespaces of the objects to the segments of their keys,
+// for the distributions storing them escaped or hashed rather than as they are.
+type KeyCodec interface {
+	// EncodeName returns the segments of the key of the name and namespace.
+	EncodeName(name, namespace string) (string, string)
+	// EncodeKey returns the key as stored, the inverse of DecodeKey, to continue a list after it.
+	EncodeKey(key string) string
+	// DecodeKey returns the key with the names and namespaces as the objects have them,
+	// or the key as stored if they can not be recovered from it.
+	DecodeKey(key string) string
+}
+
+var (
+	keyCodecsMut sync.RWMutex
+	keyCodecs    = map[string]KeyCodec{
+		"none":   nil,
+		"escape": escapeKeyCodec{},
+		"sha256": sha256KeyCodec{},
+	}
+)
+
+// RegisterKeyCodec registers the key codec by name, the nil codec stores the keys as they are.
+func RegisterKeyCodec(name string, codec KeyCodec) {
+	keyCodecsMut.Lock()
+	defer keyCodecsMut.Unlock()
+	keyCodecs[name] = codec
+}
+
+// KeyCodecs returns the names of the registered key codecs.
+func KeyCodecs() []string {
+	keyCodecsMut.RLock()
+	defer keyCodecsMut.RUnlock()
+	names := make([]string, 0, len(keyCodecs))
+	for name := range keyCodecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// KeyCodecByName returns the key codec registered by the name.
+func KeyCodecByName(name string) (KeyCodec, error) {
+	keyCodecsMut.RLock()
+	codec, ok := keyCodecs[name]
+	keyCodecsMut.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown key codec %q, one of: (%s)", name, strings.Join(KeyCodecs(), ", "))
+	}
+	return codec, nil
+}
+
+// escapeKeyCodec percent-encodes the slashes, the percent signs and the control characters of the names and namespaces.
+type escapeKeyCodec struct{}
+
+func (escapeKeyCodec) EncodeName(name, namespace string) (string, string) {
+	return escapeKeySegment(name), escapeKeySegment(namespace)
+}
+
+func (escapeKeyCodec) EncodeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = escapeKeySegment(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func (escapeKeyCodec) DecodeKey(key string) string {
+	decoded, err := url.PathUnescape(key)
+	if err != nil {
+		return key
+	}
+	return decoded
+}
+
+func escapeKeySegment(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		// The bytes above ASCII are kept, the keys after a prefix start with its largest byte
+		if c == '/' || c == '%' || c < 0x20 || c == 0x7f {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// sha256KeyCodec stores the names as the hex of their SHA-256, which can not be decoded,
+// so the keys listed have the hashes and the names are only in the objects.
+type sha256KeyCodec struct{}
+
+func (sha256KeyCodec) EncodeName(name, namespace string) (string, string) {
+	if name == "" {
+		return name, namespace
+	}
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:]), namespace
+}
+
+func (sha256KeyCodec) EncodeKey(key string) string { return key }
+
+func (sha256KeyCodec) DecodeKey(key string) string { return key }
+
+// WithKeyCodec returns the client storing the keys of c encoded by the codec,
+// the targets of the operations and the keys of the responses are the decoded ones.
+func WithKeyCodec(c Client, codec KeyCodec) Client {
+	if codec == nil {
+		return c
+	}
+	return &keyCodecClient{
+		Client: c,
+		codec:  codec,
+	}
+}
+
+type keyCodecClient struct {
+	Client
+	codec KeyCodec
+}
+
+// NamesInKeys returns whether the keys of the responses of the client have the names of the objects,
+// false for a key codec that can not decode the names it encodes, such as sha256.
+func NamesInKeys(c Client) bool {
+	inner, _ := unwrapHook(c)
+	_, codec := unwrapKeyCodec(inner)
+	if codec == nil {
+		return true
+	}
+	name, namespace := codec.EncodeName("a%b", "c")
+	return codec.DecodeKey("/"+namespace+"/"+name) == "/c/a%b"
+}
+
+// unwrapKeyCodec returns the client under the key codec, and the key codec if there is one.
+func unwrapKeyCodec(c Client) (Client, KeyCodec) {
+	if k, ok := c.(*keyCodecClient); ok {
+		return k.Client, k.codec
+	}
+	return c, nil
+}
+
+// encodeOp returns the operation targeting the keys as stored.
+func encodeOp(codec KeyCodec, opt Op) Op {
+	if opt.name != "" || opt.namespace != "" {
+		opt.name, opt.namespace = codec.EncodeName(opt.name, opt.namespace)
+	}
+	if opt.startAfter != "" {
+		opt.startAfter = codec.EncodeKey(opt.startAfter)
+	}
+	if opt.key != "" {
+		opt.key = codec.EncodeKey(opt.key)
+	}
+	if opt.response != nil {
+		response := opt.response
+		opt.response = func(kv *KeyValue) error {
+			if kv != nil {
+				decoded := *kv
+				decoded.Key = []byte(codec.DecodeKey(string(kv.Key)))
+				kv = &decoded
+			}
+			return response(kv)
+		}
+	}
+	return opt
+}
+
+func (c *keyCodecClient) encode(opOpts []OpOption) []OpOption {
+	opt := encodeOp(c.codec, opOption(opOpts))
+	return []OpOption{func(o *Op) {
+		*o = opt
+	}}
+}
+
+func (c *keyCodecClient) Get(ctx context.Context, prefix string, opOpts ...OpOption) (int64, error) {
+	return c.Client.Get(ctx, prefix, c.encode(opOpts)...)
+}
+
+func (c *keyCodecClient) Put(ctx context.Context, prefix string, value []byte, opOpts ...OpOption) error {
+	return c.Client.Put(ctx, prefix, value, c.encode(opOpts)...)
+}
+
+func (c *keyCodecClient) Delete(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	return c.Client.Delete(ctx, prefix, c.encode(opOpts)...)
+}
+
+func (c *keyCodecClient) Watch(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	return c.Client.Watch(ctx, prefix, c.encode(opOpts)...)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWithKeyCodec(t *testing.T) {
+	configmaps := client.WithGR(schema.GroupResource{Resource: "configmaps"})
+	tests := []struct {
+		codec      string
+		stored     []string
+		listed     []string
+		startAfter string
+		after      []string
+	}{
+		{
+			codec:      "none",
+			stored:     []string{"/registry/configmaps/default/a", "/registry/configmaps/default/b%c"},
+			listed:     []string{"/registry/configmaps/default/a", "/registry/configmaps/default/b%c"},
+			startAfter: "/registry/configmaps/default/a",
+			after:      []string{"/registry/configmaps/default/b%c"},
+		},
+		{
+			codec:      "escape",
+			stored:     []string{"/registry/configmaps/default/a", "/registry/configmaps/default/b%25c"},
+			listed:     []string{"/registry/configmaps/default/a", "/registry/configmaps/default/b%c"},
+			startAfter: "/registry/configmaps/default/a",
+			after:      []string{"/registry/configmaps/default/b%c"},
+		},
+		{
+			codec: "sha256",
+			// The hashes of a and b%c, the names are only in the values
+			stored: []string{
+				"/registry/configmaps/default/ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb",
+				"/registry/configmaps/default/d3adc365017e3d0384ead3acdd8080bdd89557d06a9294d8e8811d3786161e69",
+			},
+			listed: []string{
+				"/registry/configmaps/default/ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb",
+				"/registry/configmaps/default/d3adc365017e3d0384ead3acdd8080bdd89557d06a9294d8e8811d3786161e69",
+			},
+			startAfter: "/registry/configmaps/default/ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb",
+			after:      []string{"/registry/configmaps/default/d3adc365017e3d0384ead3acdd8080bdd89557d06a9294d8e8811d3786161e69"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec, func(t *testing.T) {
+			ctx := context.Background()
+			codec, err := client.KeyCodecByName(tt.codec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw := fake.NewClient()
+			etcdclient := client.WithKeyCodec(raw, codec)
+			for _, name := range []string{"a", "b%c"} {
+				err := etcdclient.Put(ctx, "/registry", []byte(name), configmaps, client.WithName(name, "default"))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			keys := func(c client.Client, opOpts ...client.OpOption) []string {
+				var keys []string
+				_, err := c.Get(ctx, "/registry", append(opOpts, configmaps, client.WithResponse(func(kv *client.KeyValue) error {
+					keys = append(keys, string(kv.Key))
+					return nil
+				}))...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return keys
+			}
+			if got := keys(raw); !reflect.DeepEqual(got, tt.stored) {
+				t.Errorf("stored keys = %v, want %v", got, tt.stored)
+			}
+			if got := keys(etcdclient); !reflect.DeepEqual(got, tt.listed) {
+				t.Errorf("listed keys = %v, want %v", got, tt.listed)
+			}
+			if got := keys(etcdclient, client.WithStartAfter(tt.startAfter)); !reflect.DeepEqual(got, tt.after) {
+				t.Errorf("keys after %s = %v, want %v", tt.startAfter, got, tt.after)
+			}
+
+			var value string
+			_, err = etcdclient.Get(ctx, "/registry", configmaps, client.WithName("b%c", "default"), client.WithResponse(func(kv *client.KeyValue) error {
+				value = string(kv.Value)
+				return nil
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != "b%c" {
+				t.Errorf("value of b%%c = %q, want %q", value, "b%c")
+			}
+
+			// The keys listed are written back to themselves, not to the keys of their names encoded again
+			for _, key := range keys(etcdclient) {
+				err := etcdclient.Put(ctx, "/registry", []byte("rewritten"), configmaps, client.WithKey(key))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := keys(raw); !reflect.DeepEqual(got, tt.stored) {
+				t.Errorf("stored keys after rewriting = %v, want %v", got, tt.stored)
+			}
+			for _, key := range keys(etcdclient) {
+				err := etcdclient.Delete(ctx, "/registry", configmaps, client.WithKey(key))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := keys(raw); len(got) != 0 {
+				t.Errorf("stored keys after deleting = %v, want none", got)
+			}
+		})
+	}
+
+	_, err := client.KeyCodecByName("unknown")
+	if err == nil {
+		t.Errorf("KeyCodecByName() of an unknown codec did not fail")
+	}
+}
+
+func TestNamesInKeys(t *testing.T) {
+	for codec, want := range map[string]bool{
+		"none":   true,
+		"escape": true,
+		"sha256": false,
+	} {
+		keyCodec, err := client.KeyCodecByName(codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		etcdclient := client.WithKeyCodec(fake.NewClient(), keyCodec)
+		if got := client.NamesInKeys(etcdclient); got != want {
+			t.Errorf("NamesInKeys() of %s = %v, want %v", codec, got, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Kine serves the etcd API from SQL databases for k3s, it is selected by name as it has no URI scheme of its own.
+func init() {
+	RegisterBackend("kine", BackendFunc(newKineClient))
+}
+
+// newKineClient creates a new client of kine.
+// Kine only supports the writes the apiserver sends, which are transactions guarded by the mod revision of the key,
+// so the puts and deletes are written that way, and work the same against etcd.
+func newKineClient(conf Config) (Client, error) {
+	cli, err := clientv3.New(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		client: cli,
+		kine:   true,
+	}, nil
+}
+
+// kinePut creates the key if it does not exist yet, or updates it at the mod revision it was read at.
+func (c *client) kinePut(ctx context.Context, key string, value []byte, opt Op) error {
+	getResp, err := c.client.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	var prev *mvccpb.KeyValue
+	var modRevision int64
+	if len(getResp.Kvs) != 0 {
+		prev = getResp.Kvs[0]
+		modRevision = prev.ModRevision
+	}
+	if opt.modRevision != 0 && opt.modRevision != modRevision {
+		return fmt.Errorf("%s: %w", key, ErrConflict)
+	}
+
+	txn := c.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", modRevision)).
+		Then(clientv3.OpPut(key, string(value)))
+	if prev != nil {
+		txn = txn.Else(clientv3.OpGet(key))
+	}
+	txnResp, err := txn.Commit()
+	if err != nil {
+		return err
+	}
+	if !txnResp.Succeeded {
+		return fmt.Errorf("%s: %w", key, ErrConflict)
+	}
+
+	if opt.response != nil {
+		var r *KeyValue
+		if prev != nil {
+			r = &KeyValue{
+				Key:      prev.Key,
+				Value:    value,
+				Revision: txnResp.Header.Revision,
+			}
+			if !opt.keysOnly {
+				r.PrevValue = prev.Value
+			}
+		}
+		err = opt.response(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kineDelete deletes the keys one by one, each at the mod revision it was read at.
+// The keys are read by pages of the page limit, all of them as of the revision of the first page.
+func (c *client) kineDelete(ctx context.Context, prefix string, opt Op) error {
+	path, single, err := opt.Key(prefix)
+	if err != nil {
+		return err
+	}
+	opts := []clientv3.OpOption{}
+	if !single {
+		opts = append(opts, clientv3.WithRange(clientv3.GetPrefixRangeEnd(path)))
+		if opt.pageLimit > 0 {
+			opts = append(opts, clientv3.WithLimit(opt.pageLimit))
+		}
+	}
+
+	key := path
+	var rev int64
+	for {
+		getResp, err := c.client.Get(ctx, key, opts...)
+		if err != nil {
+			return err
+		}
+
+		for _, kv := range getResp.Kvs {
+			err = c.kineDeleteKey(ctx, kv, opt)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !getResp.More || len(getResp.Kvs) == 0 {
+			return nil
+		}
+		// The next pages are read as of the first one, for the keys deleted not to move them
+		if rev == 0 {
+			rev = getResp.Header.Revision
+			opts = append(opts, clientv3.WithRev(rev))
+		}
+		key = string(append(getResp.Kvs[len(getResp.Kvs)-1].Key, 0))
+	}
+}
+
+// kineDeleteKey deletes the key at the mod revision it was read at.
+func (c *client) kineDeleteKey(ctx context.Context, kv *mvccpb.KeyValue, opt Op) error {
+	key := string(kv.Key)
+	txnResp, err := c.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+		Then(clientv3.OpDelete(key)).
+		Else(clientv3.OpGet(key)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !txnResp.Succeeded {
+		return fmt.Errorf("%s: %w", key, ErrConflict)
+	}
+
+	if opt.response != nil {
+		r := &KeyValue{
+			Key:      kv.Key,
+			Revision: txnResp.Header.Revision,
+		}
+		if !opt.keysOnly {
+			r.PrevValue = kv.Value
+		}
+		return opt.response(r)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// newEmbedKineClient starts an etcd in the process, and returns a client of kine to it.
+func newEmbedKineClient(t *testing.T) (Client, *clientv3.Client) {
+	t.Helper()
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+	// The ports are picked by the system, the peer one is never dialed by a single member
+	local := []url.URL{{Scheme: "http", Host: "127.0.0.1:0"}}
+	cfg.ListenClientUrls = local
+	cfg.AdvertiseClientUrls = local
+	cfg.ListenPeerUrls = local
+	cfg.AdvertisePeerUrls = local
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("etcd is not ready")
+	}
+
+	conf := Config{
+		Endpoints:   []string{e.Clients[0].Addr().String()},
+		DialTimeout: 5 * time.Second,
+	}
+	c, err := newKineClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := clientv3.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = raw.Close()
+	})
+	return c, raw
+}
+
+func TestKineClient(t *testing.T) {
+	c, raw := newEmbedKineClient(t)
+	ctx := context.Background()
+	gr := schema.GroupResource{Resource: "configmaps"}
+
+	var created []*KeyValue
+	for _, name := range []string{"a", "b", "c"} {
+		err := c.Put(ctx, "/registry", []byte(name+"0"),
+			WithGR(gr),
+			WithName(name, "default"),
+			WithResponse(func(kv *KeyValue) error {
+				created = append(created, kv)
+				return nil
+			}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A create has no previous value to respond with
+	if len(created) != 3 || created[0] != nil {
+		t.Errorf("created responded %v, want nil for the creates", created)
+	}
+
+	resp, err := raw.Get(ctx, "/registry/configmaps/default/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := resp.Kvs[0].ModRevision
+	err = c.Put(ctx, "/registry", []byte("a1"),
+		WithGR(gr),
+		WithName("a", "default"),
+		WithModRevision(read),
+		WithResponse(func(kv *KeyValue) error {
+			if string(kv.PrevValue) != "a0" || string(kv.Value) != "a1" {
+				t.Errorf("updated %q to %q, want a0 to a1", kv.PrevValue, kv.Value)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The update at the mod revision read before the last one conflicts, and leaves the key as it is
+	err = c.Put(ctx, "/registry", []byte("a2"),
+		WithGR(gr),
+		WithName("a", "default"),
+		WithModRevision(read),
+	)
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Put() at a stale mod revision = %v, want %v", err, ErrConflict)
+	}
+	resp, err = raw.Get(ctx, "/registry/configmaps/default/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(resp.Kvs[0].Value); got != "a1" {
+		t.Errorf("the conflicting update wrote %q, want a1", got)
+	}
+
+	// The keys of the prefix are deleted by pages smaller than them
+	var deleted []string
+	err = c.Delete(ctx, "/registry",
+		WithGR(gr),
+		WithPageLimit(2),
+		WithResponse(func(kv *KeyValue) error {
+			deleted = append(deleted, string(kv.Key)+"="+string(kv.PrevValue))
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/registry/configmaps/default/a=a1", "/registry/configmaps/default/b=b0", "/registry/configmaps/default/c=c0"}
+	if len(deleted) != len(want) {
+		t.Fatalf("deleted %q, want %q", deleted, want)
+	}
+	for i := range want {
+		if deleted[i] != want[i] {
+			t.Errorf("deleted %q, want %q", deleted, want)
+			break
+		}
+	}
+	resp, err = raw.Get(ctx, "/registry/configmaps/", clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 0 {
+		t.Errorf("%d keys are left after the delete", resp.Count)
+	}
+}
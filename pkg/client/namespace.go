@@ -0,0 +1,94 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+)
+
+// WithNamespace returns the client of the keys of c under the etcd namespace, the key prefix isolating a logical cluster
+// from the others sharing the etcd, as the --namespace of the gRPC proxy of etcd does.
+// The namespace is prepended to the keys as stored, below the prefix of k8s, and the keys of the responses are without it.
+func WithNamespace(c Client, namespace string) Client {
+	if namespace == "" {
+		return c
+	}
+	return &namespaceClient{
+		Client:    c,
+		namespace: namespace,
+	}
+}
+
+type namespaceClient struct {
+	Client
+	namespace string
+}
+
+// unwrapNamespace returns the client under the etcd namespace, and the namespace if there is one.
+func unwrapNamespace(c Client) (Client, string) {
+	if n, ok := c.(*namespaceClient); ok {
+		return n.Client, n.namespace
+	}
+	return c, ""
+}
+
+// namespaceOp returns the operation continuing after the key in the namespace, and responding with the keys without it.
+func namespaceOp(namespace string, opt Op) Op {
+	if opt.startAfter != "" {
+		opt.startAfter = namespace + opt.startAfter
+	}
+	if opt.key != "" {
+		opt.key = namespace + opt.key
+	}
+	if opt.response != nil {
+		response := opt.response
+		prefix := []byte(namespace)
+		opt.response = func(kv *KeyValue) error {
+			if kv != nil {
+				trimmed := *kv
+				trimmed.Key = bytes.TrimPrefix(kv.Key, prefix)
+				kv = &trimmed
+			}
+			return response(kv)
+		}
+	}
+	return opt
+}
+
+func (c *namespaceClient) wrap(opOpts []OpOption) []OpOption {
+	opt := namespaceOp(c.namespace, opOption(opOpts))
+	return []OpOption{func(o *Op) {
+		*o = opt
+	}}
+}
+
+func (c *namespaceClient) Get(ctx context.Context, prefix string, opOpts ...OpOption) (int64, error) {
+	return c.Client.Get(ctx, c.namespace+prefix, c.wrap(opOpts)...)
+}
+
+func (c *namespaceClient) Put(ctx context.Context, prefix string, value []byte, opOpts ...OpOption) error {
+	return c.Client.Put(ctx, c.namespace+prefix, value, c.wrap(opOpts)...)
+}
+
+func (c *namespaceClient) Delete(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	return c.Client.Delete(ctx, c.namespace+prefix, c.wrap(opOpts)...)
+}
+
+func (c *namespaceClient) Watch(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	return c.Client.Watch(ctx, c.namespace+prefix, c.wrap(opOpts)...)
+}
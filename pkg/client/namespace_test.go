@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWithNamespace(t *testing.T) {
+	ctx := context.Background()
+	configmaps := client.WithGR(schema.GroupResource{Resource: "configmaps"})
+	raw := fake.NewClient()
+	a := client.WithNamespace(raw, "/cluster-a")
+	b := client.WithNamespace(raw, "/cluster-b")
+	for _, name := range []string{"x", "y"} {
+		err := a.Put(ctx, "/registry", []byte(name), configmaps, client.WithName(name, "default"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := b.Put(ctx, "/registry", []byte("z"), configmaps, client.WithName("z", "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := func(c client.Client, prefix string, opOpts ...client.OpOption) []string {
+		var keys []string
+		_, err := c.Get(ctx, prefix, append(opOpts, client.WithResponse(func(kv *client.KeyValue) error {
+			keys = append(keys, string(kv.Key))
+			return nil
+		}))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+	want := []string{"/cluster-a/registry/configmaps/default/x", "/cluster-a/registry/configmaps/default/y"}
+	if got := keys(raw, "/cluster-a/registry", configmaps); !reflect.DeepEqual(got, want) {
+		t.Errorf("stored keys = %v, want %v", got, want)
+	}
+	want = []string{"/registry/configmaps/default/x", "/registry/configmaps/default/y"}
+	if got := keys(a, "/registry", configmaps); !reflect.DeepEqual(got, want) {
+		t.Errorf("listed keys = %v, want %v", got, want)
+	}
+	want = []string{"/registry/configmaps/default/y"}
+	if got := keys(a, "/registry", configmaps, client.WithStartAfter("/registry/configmaps/default/x")); !reflect.DeepEqual(got, want) {
+		t.Errorf("keys after x = %v, want %v", got, want)
+	}
+
+	err = a.Delete(ctx, "/registry", configmaps, client.WithName("x", "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var watched []string
+	err = a.Watch(ctx, "/registry",
+		client.WithRevision(1),
+		client.WithMaxRevision(raw.Revision()),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			watched = append(watched, string(kv.Key))
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The deletion of x, and none of the keys of the other namespace
+	want = []string{"/registry/configmaps/default/x", "/registry/configmaps/default/y", "/registry/configmaps/default/x"}
+	if !reflect.DeepEqual(watched, want) {
+		t.Errorf("watched keys = %v, want %v", watched, want)
+	}
+
+	// A key listed is targeted in the namespace
+	err = a.Delete(ctx, "/registry", configmaps, client.WithKey("/registry/configmaps/default/y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keys(raw, "/cluster-a/registry", configmaps); len(got) != 0 {
+		t.Errorf("stored keys after deleting y = %v, want none", got)
+	}
+
+	if client.WithNamespace(raw, "") != client.Client(raw) {
+		t.Errorf("WithNamespace() of no namespace wraps the client")
+	}
+}
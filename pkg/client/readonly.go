@@ -0,0 +1,41 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+)
+
+// ReadOnly returns the client reading c, refusing the writes with ErrReadOnly.
+func ReadOnly(c Client) Client {
+	return &readOnlyClient{
+		Client: c,
+	}
+}
+
+// readOnlyClient is a client refusing the writes, such as of the backends built from a recording.
+type readOnlyClient struct {
+	Client
+}
+
+func (c *readOnlyClient) Put(ctx context.Context, prefix string, value []byte, opOpts ...OpOption) error {
+	return ErrReadOnly
+}
+
+func (c *readOnlyClient) Delete(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	return ErrReadOnly
+}
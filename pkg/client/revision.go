@@ -0,0 +1,74 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOutOfOrder is returned when a watch delivers an event older than the previous one.
+var ErrOutOfOrder = errors.New("revision out of order")
+
+// Gap describes a range of revisions that a watch could not deliver,
+// usually because they have been compacted.
+type Gap struct {
+	// From is the first revision that may be missing.
+	From int64 `json:"from"`
+	// To is the last revision that may be missing.
+	To int64 `json:"to"`
+}
+
+// revisionTracker asserts the monotonic delivery of revisions.
+type revisionTracker struct {
+	last int64
+}
+
+func newRevisionTracker(start int64) *revisionTracker {
+	t := &revisionTracker{}
+	if start > 0 {
+		t.last = start - 1
+	}
+	return t
+}
+
+// observe records the revision of a delivered event.
+// Events of one transaction share a revision, so only a decrease is an error.
+func (t *revisionTracker) observe(rev int64) error {
+	if rev < t.last {
+		return fmt.Errorf("%w: got %d after %d", ErrOutOfOrder, rev, t.last)
+	}
+	t.last = rev
+	return nil
+}
+
+// reset continues the tracking after the revision the targets are listed again at.
+func (t *revisionTracker) reset(rev int64) {
+	t.last = rev
+}
+
+// compacted returns the gap between the last delivered revision and the compact revision.
+func (t *revisionTracker) compacted(compactRev int64) (Gap, bool) {
+	gap := Gap{
+		From: t.last + 1,
+		To:   compactRev - 1,
+	}
+	if gap.From > gap.To {
+		return Gap{}, false
+	}
+	return gap, true
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRevisionTracker(t *testing.T) {
+	tests := []struct {
+		name      string
+		start     int64
+		revs      []int64
+		compact   int64
+		wantErr   error
+		wantGap   Gap
+		wantFound bool
+	}{
+		{
+			name:  "monotonic",
+			start: 10,
+			revs:  []int64{10, 12, 12, 15},
+		},
+		{
+			name:    "out of order",
+			start:   10,
+			revs:    []int64{12, 11},
+			wantErr: ErrOutOfOrder,
+		},
+		{
+			name:      "compacted before any event",
+			start:     10,
+			compact:   20,
+			wantGap:   Gap{From: 10, To: 19},
+			wantFound: true,
+		},
+		{
+			name:      "compacted after events",
+			start:     10,
+			revs:      []int64{11, 13},
+			compact:   20,
+			wantGap:   Gap{From: 14, To: 19},
+			wantFound: true,
+		},
+		{
+			name:    "compacted without gap",
+			start:   10,
+			revs:    []int64{11, 13},
+			compact: 14,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newRevisionTracker(tt.start)
+			var err error
+			for _, rev := range tt.revs {
+				err = tracker.observe(rev)
+				if err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("observe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.compact == 0 {
+				return
+			}
+			gap, found := tracker.compacted(tt.compact)
+			if found != tt.wantFound || gap != tt.wantGap {
+				t.Errorf("compacted() = %v, %v, want %v, %v", gap, found, tt.wantGap, tt.wantFound)
+			}
+		})
+	}
+}
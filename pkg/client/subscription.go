@@ -0,0 +1,177 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// SubscriptionManager shares one upstream watch between multiple in-process subscribers.
+// It does not replace WithResponse: a watch with a single consumer, such as the one of get --watch,
+// keeps passing WithResponse to Watch, so that an error of the consumer stops the watch.
+type SubscriptionManager struct {
+	client Client
+	prefix string
+	opOpts []OpOption
+
+	mut     sync.Mutex
+	subs    map[*Subscription]struct{}
+	running bool
+	closed  bool
+}
+
+// Subscription is a filtered view of the events of a SubscriptionManager.
+type Subscription struct {
+	filter func(kv *KeyValue) bool
+	ch     chan *KeyValue
+	done   chan struct{}
+	once   sync.Once
+	mgr    *SubscriptionManager
+
+	// mut is held while sending to ch and while closing it, so that an event is never sent to a closed channel
+	mut sync.Mutex
+}
+
+// NewSubscriptionManager creates a new SubscriptionManager watching the prefix with the given options.
+// The response option is owned by the manager and will be overridden.
+func NewSubscriptionManager(client Client, prefix string, opOpts ...OpOption) *SubscriptionManager {
+	return &SubscriptionManager{
+		client: client,
+		prefix: prefix,
+		opOpts: opOpts,
+		subs:   map[*Subscription]struct{}{},
+	}
+}
+
+// Subscribe registers a new subscriber with a buffer of the given size.
+// Events for which the filter returns false are skipped, a nil filter receives all events.
+func (m *SubscriptionManager) Subscribe(filter func(kv *KeyValue) bool, size int) *Subscription {
+	s := &Subscription{
+		filter: filter,
+		ch:     make(chan *KeyValue, size),
+		done:   make(chan struct{}),
+		mgr:    m,
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	if m.closed {
+		close(s.ch)
+		return s
+	}
+	m.subs[s] = struct{}{}
+	return s
+}
+
+// Run starts the upstream watch and blocks until it ends.
+// All subscriber channels are closed when Run returns.
+func (m *SubscriptionManager) Run(ctx context.Context) error {
+	m.mut.Lock()
+	if m.running || m.closed {
+		m.mut.Unlock()
+		return fmt.Errorf("subscription manager can only be run once")
+	}
+	m.running = true
+	m.mut.Unlock()
+
+	defer m.close()
+
+	opOpts := make([]OpOption, 0, len(m.opOpts)+1)
+	opOpts = append(opOpts, m.opOpts...)
+	opOpts = append(opOpts, WithResponse(func(kv *KeyValue) error {
+		return m.dispatch(ctx, kv)
+	}))
+
+	return m.client.Watch(ctx, m.prefix, opOpts...)
+}
+
+// dispatch delivers the event to every matching subscriber in order.
+// A slow subscriber applies backpressure to the upstream watch rather than losing events.
+func (m *SubscriptionManager) dispatch(ctx context.Context, kv *KeyValue) error {
+	m.mut.Lock()
+	subs := make([]*Subscription, 0, len(m.subs))
+	for s := range m.subs {
+		subs = append(subs, s)
+	}
+	m.mut.Unlock()
+
+	for _, s := range subs {
+		if s.filter != nil && !s.filter(kv) {
+			continue
+		}
+		err := s.send(ctx, kv)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *SubscriptionManager) close() {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.closed = true
+	for s := range m.subs {
+		s.closeResult()
+		delete(m.subs, s)
+	}
+}
+
+// ResultChan returns the channel of the events, it is closed when the manager stops.
+func (s *Subscription) ResultChan() <-chan *KeyValue {
+	return s.ch
+}
+
+// Stop unregisters the subscriber, releases any delivery blocked on it and closes its channel.
+func (s *Subscription) Stop() {
+	s.once.Do(func() {
+		close(s.done)
+		s.mgr.mut.Lock()
+		defer s.mgr.mut.Unlock()
+		// The channel of a subscription no longer registered is already closed by the manager
+		if _, ok := s.mgr.subs[s]; ok {
+			delete(s.mgr.subs, s)
+			s.closeResult()
+		}
+	})
+}
+
+// send delivers the event unless the subscriber is stopped.
+func (s *Subscription) send(ctx context.Context, kv *KeyValue) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	select {
+	case s.ch <- kv:
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (s *Subscription) closeResult() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	close(s.ch)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type eventsClient struct {
+	Client
+	events []*KeyValue
+}
+
+func (c *eventsClient) Watch(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	opt := opOption(opOpts)
+	for _, kv := range c.events {
+		err := opt.response(kv)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSubscriptionManager(t *testing.T) {
+	c := &eventsClient{
+		events: []*KeyValue{
+			{Key: []byte("/registry/pods/default/a")},
+			{Key: []byte("/registry/leases/default/b")},
+			{Key: []byte("/registry/pods/default/c")},
+		},
+	}
+
+	m := NewSubscriptionManager(c, "/registry")
+
+	all := m.Subscribe(nil, 0)
+	pods := m.Subscribe(func(kv *KeyValue) bool {
+		return strings.HasPrefix(string(kv.Key), "/registry/pods/")
+	}, 0)
+	stopped := m.Subscribe(nil, 0)
+	stopped.Stop()
+
+	collect := func(s *Subscription, out *[]string, wg *sync.WaitGroup) {
+		defer wg.Done()
+		for kv := range s.ResultChan() {
+			*out = append(*out, string(kv.Key))
+		}
+	}
+
+	var wg sync.WaitGroup
+	var gotAll, gotPods []string
+	wg.Add(2)
+	go collect(all, &gotAll, &wg)
+	go collect(pods, &gotPods, &wg)
+
+	err := m.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	wg.Wait()
+
+	wantAll := []string{"/registry/pods/default/a", "/registry/leases/default/b", "/registry/pods/default/c"}
+	if !reflect.DeepEqual(gotAll, wantAll) {
+		t.Errorf("all subscriber got = %v, want %v", gotAll, wantAll)
+	}
+	wantPods := []string{"/registry/pods/default/a", "/registry/pods/default/c"}
+	if !reflect.DeepEqual(gotPods, wantPods) {
+		t.Errorf("pods subscriber got = %v, want %v", gotPods, wantPods)
+	}
+
+	if err := m.Run(context.Background()); err == nil {
+		t.Errorf("Run() twice should fail")
+	}
+
+	if _, ok := <-m.Subscribe(nil, 0).ResultChan(); ok {
+		t.Errorf("Subscribe() after Run() should return a closed channel")
+	}
+}
+
+// endlessClient watches an event over and over until the context is done.
+type endlessClient struct {
+	Client
+}
+
+func (c *endlessClient) Watch(ctx context.Context, prefix string, opOpts ...OpOption) error {
+	opt := opOption(opOpts)
+	for ctx.Err() == nil {
+		err := opt.response(&KeyValue{Key: []byte("/registry/pods/default/a")})
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func TestSubscriptionStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewSubscriptionManager(&endlessClient{}, "/registry")
+	stopped := m.Subscribe(nil, 1)
+	running := m.Subscribe(nil, 1)
+	done := make(chan error)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+	// The others go on until the manager stops
+	runningClosed := make(chan struct{})
+	go func() {
+		defer close(runningClosed)
+		for range running.ResultChan() {
+		}
+	}()
+
+	// Stopping while the events are being delivered closes the channel, ending the range over it
+	var received int
+	for range stopped.ResultChan() {
+		received++
+		if received == 10 {
+			stopped.Stop()
+		}
+	}
+	if received < 10 {
+		t.Errorf("received %d events before the channel is closed, want at least 10", received)
+	}
+	stopped.Stop()
+
+	cancel()
+	<-done
+	<-runningClosed
+	// Stopping after the manager closed the channel does not close it twice
+	running.Stop()
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newCtlAnalyzeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "analyze",
+		Short: "Analyzes the resource of k8s in etcd",
+	}
+	cmd.AddCommand(
+		newCtlAnalyzeStorageVersionsCommand(),
+		newCtlAnalyzeAutoscalingCommand(),
+		newCtlAnalyzeAuditCommand(),
+		newCtlAnalyzeDeprecationsCommand(),
+		newCtlAnalyzeRBACCommand(),
+		newCtlAnalyzeChurnCommand(),
+	)
+	return cmd
+}
+
+type analyzeStorageVersionsFlagpole struct {
+	Output      string
+	Prefix      string
+	ChunkSize   int64
+	Consistency string
+	All         bool
+}
+
+func newCtlAnalyzeStorageVersionsCommand() *cobra.Command {
+	flags := &analyzeStorageVersionsFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.RangeArgs(0, 1),
+		Use:   "storage-versions [resource]",
+		Short: "Reports the resource of k8s in etcd stored at more than one API version",
+		Long: "Reports the resource of k8s in etcd stored at more than one API version.\n" +
+			"This is usual in the middle of an upgrade, the objects at the older version can be rewritten with migrate storage-version.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = analyzeStorageVersionsCommand(cmd.Context(), etcdclient, flags, args)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().Int64Var(&flags.ChunkSize, "chunk-size", 500, "chunk size of the list pager")
+	cmd.Flags().StringVar(&flags.Consistency, "consistency", "s", "consistency of the reads. One of: (l, s, linearizable, serializable).")
+	cmd.Flags().BoolVar(&flags.All, "all", false, "report the resources stored at a single version as well")
+
+	return cmd
+}
+
+type storageVersion struct {
+	Resource string `json:"resource"`
+	Version  string `json:"version"`
+	Count    int    `json:"count"`
+}
+
+// storageVersionOf returns the API version the value is stored at,
+// the values that can not be decoded are reported as encrypted or unknown.
+func storageVersionOf(value []byte) string {
+	if bytes.HasPrefix(value, []byte(encryptedValuePrefix)) {
+		return "encrypted"
+	}
+	inMediaType, _, err := encoding.DetectAndExtract(value)
+	if err != nil {
+		return "unknown"
+	}
+	typeMeta, err := encoding.DecodeTypeMeta(inMediaType, value)
+	if err != nil || typeMeta.APIVersion == "" {
+		return "unknown"
+	}
+	return typeMeta.APIVersion
+}
+
+func analyzeStorageVersionsCommand(ctx context.Context, etcdclient client.Client, flags *analyzeStorageVersionsFlagpole, args []string) error {
+	var targetGr schema.GroupResource
+	if len(args) != 0 {
+		gr := schema.ParseGroupResource(args[0])
+		if gr.Empty() {
+			return fmt.Errorf("invalid resource %q", args[0])
+		}
+		if correctGr, _, found := wellknown.CorrectGroupResource(gr); found {
+			gr = correctGr
+		}
+		targetGr = gr
+	}
+
+	consistencyOpts, err := consistencyOpOptions(flags.Consistency, false)
+	if err != nil {
+		return err
+	}
+
+	counts := map[storageVersion]int{}
+	_, err = etcdclient.Get(ctx, flags.Prefix,
+		append(consistencyOpts,
+			client.WithGR(targetGr),
+			client.WithPageLimit(flags.ChunkSize),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				gr, _, ok := groupResourceFromKey(flags.Prefix, string(kv.Key))
+				if !ok {
+					return nil
+				}
+				counts[storageVersion{
+					Resource: gr.String(),
+					Version:  storageVersionOf(kv.Value),
+				}]++
+				return nil
+			}),
+		)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	versions := map[string]int{}
+	for version := range counts {
+		versions[version.Resource]++
+	}
+
+	results := make([]storageVersion, 0, len(counts))
+	var mixed int
+	for version, count := range counts {
+		if versions[version.Resource] < 2 && !flags.All {
+			continue
+		}
+		version.Count = count
+		results = append(results, version)
+	}
+	for _, n := range versions {
+		if n >= 2 {
+			mixed++
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Version < b.Version
+	})
+
+	switch flags.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(results)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "RESOURCE\tVERSION\tCOUNT")
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", result.Resource, result.Version, result.Count)
+		}
+		err = w.Flush()
+	default:
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%d resources are stored at more than one version\n", mixed)
+	return nil
+}
@@ -0,0 +1,409 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+)
+
+type analyzeAuditFlagpole struct {
+	Output        string
+	Prefix        string
+	TracePath     string
+	AuditLogPath  string
+	SinceRevision int64
+}
+
+func newCtlAnalyzeAuditCommand() *cobra.Command {
+	flags := &analyzeAuditFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Use:   "audit",
+		Short: "Compares a trace with the audit log of the apiserver over the same window",
+		Long: "Compares a trace with the audit log of the apiserver over the same window.\n" +
+			"The writes are correlated by key, the ones in the audit log but missing from the trace show how complete the trace is, " +
+			"and the ones in the trace but missing from the audit log were written to etcd bypassing the apiserver.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := analyzeAuditCommand(flags)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().StringVar(&flags.TracePath, "trace", "", "path of the trace, as printed by get --raw-revision-range in any output format")
+	cmd.Flags().StringVar(&flags.AuditLogPath, "audit-log", "", "path of the audit log of the apiserver, in JSON lines")
+	cmd.Flags().Int64Var(&flags.SinceRevision, "since-revision", 0, "revision the window starts after, the objects of the trace up to it are not taken as writes")
+
+	return cmd
+}
+
+// auditComparison is the comparison of the writes of a resource.
+type auditComparison struct {
+	Resource string `json:"resource"`
+	Audit    int    `json:"audit"`
+	Trace    int    `json:"trace"`
+	Matched  int    `json:"matched"`
+	// MissingFromTrace are the writes in the audit log missing from the trace
+	MissingFromTrace int `json:"missingFromTrace"`
+	// MissingFromAudit are the writes in the trace missing from the audit log, that bypassed the apiserver
+	MissingFromAudit int `json:"missingFromAudit"`
+}
+
+// auditDiscrepancy is a key written a different number of times in the audit log and in the trace.
+type auditDiscrepancy struct {
+	Key   string `json:"key"`
+	Audit int    `json:"audit"`
+	Trace int    `json:"trace"`
+}
+
+type auditReport struct {
+	Resources     []auditComparison  `json:"resources"`
+	Discrepancies []auditDiscrepancy `json:"discrepancies"`
+}
+
+// auditWrites are the writes of the audit log by key.
+type auditWrites struct {
+	keys map[string]int
+	// unnamed are the writes by the prefix they were made under without naming the object,
+	// such as the creations with a generated name or the collection deletions, -1 for any number of them
+	unnamed map[string]int
+}
+
+// The subresources writing to the key of their object, the others such as exec or token are not stored.
+var auditStoredSubresources = map[string]struct{}{
+	"":                    {},
+	"status":              {},
+	"scale":               {},
+	"binding":             {},
+	"eviction":            {},
+	"ephemeralcontainers": {},
+	"resize":              {},
+	"approval":            {},
+	"finalize":            {},
+}
+
+// The groups of the reviews, which are never stored.
+var auditUnstoredGroups = map[string]struct{}{
+	"authentication.k8s.io": {},
+	"authorization.k8s.io":  {},
+}
+
+// readAuditWrites reads the successful writes from the audit log in JSON lines.
+func readAuditWrites(r io.Reader, prefix string) (*auditWrites, error) {
+	writes := &auditWrites{
+		keys:    map[string]int{},
+		unnamed: map[string]int{},
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+		var event auditv1.Event
+		err := json.Unmarshal(data, &event)
+		if err != nil {
+			return nil, fmt.Errorf("audit log line %d: %w", line, err)
+		}
+
+		if event.Stage != auditv1.StageResponseComplete || event.ObjectRef == nil {
+			continue
+		}
+		switch event.Verb {
+		case "create", "update", "patch", "delete", "deletecollection":
+		default:
+			continue
+		}
+		if event.ResponseStatus != nil && (event.ResponseStatus.Code < 200 || event.ResponseStatus.Code >= 300) {
+			continue
+		}
+		if strings.Contains(event.RequestURI, "dryRun=") {
+			continue
+		}
+		ref := event.ObjectRef
+		if _, ok := auditUnstoredGroups[ref.APIGroup]; ok {
+			continue
+		}
+		if _, ok := auditStoredSubresources[ref.Subresource]; !ok {
+			continue
+		}
+
+		keyPrefix, err := client.PrefixFromGR(schema.GroupResource{Group: ref.APIGroup, Resource: ref.Resource})
+		if err != nil {
+			return nil, fmt.Errorf("audit log line %d: %w", line, err)
+		}
+		key := prefix + "/" + keyPrefix + "/"
+		if ref.Namespace != "" {
+			key += ref.Namespace + "/"
+		}
+
+		name := ref.Name
+		if name == "" && event.ResponseObject != nil && event.Verb == "create" {
+			// The name generated for the object is only in the response
+			var obj struct {
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+			}
+			_ = json.Unmarshal(event.ResponseObject.Raw, &obj)
+			name = obj.Metadata.Name
+		}
+		switch {
+		case name != "":
+			writes.keys[key+name]++
+		case event.Verb == "deletecollection":
+			writes.unnamed[key] = -1
+		case writes.unnamed[key] != -1:
+			writes.unnamed[key]++
+		}
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return writes, nil
+}
+
+// parseTraceHeader parses the header of a document of a trace,
+// in the form of `key | revision` followed by ` | deleted` for the deletions, as printed in any output format.
+func parseTraceHeader(line, prefix string) (key string, revision int64, deleted bool, ok bool) {
+	line = strings.TrimPrefix(line, "# ")
+	if !strings.HasPrefix(line, prefix+"/") {
+		return "", 0, false, false
+	}
+	fields := strings.Split(line, " | ")
+	if len(fields) < 2 {
+		return "", 0, false, false
+	}
+	last := len(fields) - 1
+	if fields[last] == "deleted" {
+		deleted = true
+		last--
+	}
+	if last < 1 {
+		return "", 0, false, false
+	}
+	revision, err := strconv.ParseInt(fields[last], 10, 64)
+	if err != nil {
+		return "", 0, false, false
+	}
+	return fields[0], revision, deleted, true
+}
+
+// readTraceWrites reads the writes by key from the headers of the documents of a trace.
+func readTraceWrites(r io.Reader, prefix string, sinceRevision int64) (map[string]int, error) {
+	writes := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		key, revision, _, ok := parseTraceHeader(scanner.Text(), prefix)
+		if !ok || revision <= sinceRevision {
+			continue
+		}
+		writes[key]++
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return writes, nil
+}
+
+// compareAuditWrites correlates the writes of the audit log and of the trace by key.
+func compareAuditWrites(prefix string, audit *auditWrites, trace map[string]int) *auditReport {
+	keys := map[string]struct{}{}
+	for key := range audit.keys {
+		keys[key] = struct{}{}
+	}
+	for key := range trace {
+		keys[key] = struct{}{}
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	unnamed := map[string]int{}
+	for p, n := range audit.unnamed {
+		unnamed[p] = n
+	}
+	unnamedPrefixes := make([]string, 0, len(unnamed))
+	for p := range unnamed {
+		unnamedPrefixes = append(unnamedPrefixes, p)
+	}
+	sort.Strings(unnamedPrefixes)
+
+	report := &auditReport{}
+	resources := map[string]*auditComparison{}
+	for _, key := range sortedKeys {
+		a, t := audit.keys[key], trace[key]
+		matched := min(a, t)
+
+		// The writes not naming the object are matched to the rest of the trace under their prefix
+		for _, p := range unnamedPrefixes {
+			if matched == t {
+				break
+			}
+			if unnamed[p] == 0 || !strings.HasPrefix(key, p) {
+				continue
+			}
+			extra := t - matched
+			if unnamed[p] > 0 {
+				extra = min(extra, unnamed[p])
+				unnamed[p] -= extra
+			}
+			a += extra
+			matched += extra
+		}
+
+		gr, _, _ := groupResourceFromKey(prefix, key)
+		c, ok := resources[gr.String()]
+		if !ok {
+			c = &auditComparison{Resource: gr.String()}
+			resources[gr.String()] = c
+		}
+		c.Audit += a
+		c.Trace += t
+		c.Matched += matched
+		c.MissingFromTrace += a - matched
+		c.MissingFromAudit += t - matched
+
+		if a != t {
+			report.Discrepancies = append(report.Discrepancies, auditDiscrepancy{Key: key, Audit: a, Trace: t})
+		}
+	}
+
+	// The unnamed writes left over have no write in the trace
+	for _, p := range unnamedPrefixes {
+		if unnamed[p] <= 0 {
+			continue
+		}
+		gr, _, _ := groupResourceFromKey(prefix, p)
+		c, ok := resources[gr.String()]
+		if !ok {
+			c = &auditComparison{Resource: gr.String()}
+			resources[gr.String()] = c
+		}
+		c.Audit += unnamed[p]
+		c.MissingFromTrace += unnamed[p]
+		report.Discrepancies = append(report.Discrepancies, auditDiscrepancy{Key: p, Audit: unnamed[p]})
+	}
+
+	for _, c := range resources {
+		report.Resources = append(report.Resources, *c)
+	}
+	sort.Slice(report.Resources, func(i, j int) bool {
+		return report.Resources[i].Resource < report.Resources[j].Resource
+	})
+	return report
+}
+
+func formatCompleteness(matched, audit int) string {
+	if audit == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(matched)*100/float64(audit))
+}
+
+func analyzeAuditCommand(flags *analyzeAuditFlagpole) error {
+	if flags.TracePath == "" || flags.AuditLogPath == "" {
+		return fmt.Errorf("trace and audit-log are required")
+	}
+	if flags.Output != "table" && flags.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+
+	traceFile, err := openRecording(flags.TracePath)
+	if err != nil {
+		return err
+	}
+	defer traceFile.Close()
+	trace, err := readTraceWrites(traceFile, flags.Prefix, flags.SinceRevision)
+	if err != nil {
+		return fmt.Errorf("trace %s: %w", flags.TracePath, err)
+	}
+
+	auditFile, err := os.Open(flags.AuditLogPath)
+	if err != nil {
+		return err
+	}
+	defer auditFile.Close()
+	audit, err := readAuditWrites(auditFile, flags.Prefix)
+	if err != nil {
+		return fmt.Errorf("audit log %s: %w", flags.AuditLogPath, err)
+	}
+
+	report := compareAuditWrites(flags.Prefix, audit, trace)
+
+	switch flags.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "RESOURCE\tAUDIT\tTRACE\tMATCHED\tMISSING FROM TRACE\tMISSING FROM AUDIT\tCOMPLETENESS")
+		for _, c := range report.Resources {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n", c.Resource, c.Audit, c.Trace, c.Matched, c.MissingFromTrace, c.MissingFromAudit, formatCompleteness(c.Matched, c.Audit))
+		}
+		if len(report.Discrepancies) != 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "KEY\tAUDIT\tTRACE")
+			for _, d := range report.Discrepancies {
+				fmt.Fprintf(w, "%s\t%d\t%d\n", d.Key, d.Audit, d.Trace)
+			}
+		}
+		err = w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	var total auditComparison
+	for _, c := range report.Resources {
+		total.Audit += c.Audit
+		total.Matched += c.Matched
+		total.MissingFromTrace += c.MissingFromTrace
+		total.MissingFromAudit += c.MissingFromAudit
+	}
+	fmt.Fprintf(os.Stderr, "trace completeness %s, %d writes in the audit log missing from the trace, %d writes in the trace missing from the audit log\n",
+		formatCompleteness(total.Matched, total.Audit), total.MissingFromTrace, total.MissingFromAudit)
+	return nil
+}
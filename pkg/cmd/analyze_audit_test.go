@@ -0,0 +1,101 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadTraceWrites(t *testing.T) {
+	trace := `---
+# /registry/pods/default/a | application/vnd.kubernetes.protobuf | 10
+apiVersion: v1
+kind: Pod
+---
+# /registry/pods/default/a | application/vnd.kubernetes.protobuf | 12
+apiVersion: v1
+kind: Pod
+---
+# /registry/pods/default/a | 13 | deleted
+/registry/configmaps/default/b | 14
+/registry/configmaps/default/b | 15 | deleted
+`
+	got, err := readTraceWrites(strings.NewReader(trace), "/registry", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"/registry/pods/default/a":       2,
+		"/registry/configmaps/default/b": 2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readTraceWrites() = %v, want %v", got, want)
+	}
+}
+
+func TestCompareAuditWrites(t *testing.T) {
+	auditLog := strings.Join([]string{
+		// Written and recorded
+		`{"stage":"ResponseComplete","verb":"update","requestURI":"/api/v1/namespaces/default/pods/a/status","objectRef":{"resource":"pods","namespace":"default","name":"a","apiVersion":"v1","subresource":"status"},"responseStatus":{"code":200}}`,
+		// Written but missing from the trace
+		`{"stage":"ResponseComplete","verb":"patch","requestURI":"/api/v1/namespaces/default/pods/a","objectRef":{"resource":"pods","namespace":"default","name":"a","apiVersion":"v1"},"responseStatus":{"code":200}}`,
+		// Not written
+		`{"stage":"ResponseStarted","verb":"update","objectRef":{"resource":"pods","namespace":"default","name":"a","apiVersion":"v1"}}`,
+		`{"stage":"ResponseComplete","verb":"update","objectRef":{"resource":"pods","namespace":"default","name":"a","apiVersion":"v1"},"responseStatus":{"code":409}}`,
+		`{"stage":"ResponseComplete","verb":"create","requestURI":"/api/v1/namespaces/default/pods?dryRun=All","objectRef":{"resource":"pods","namespace":"default","name":"c","apiVersion":"v1"},"responseStatus":{"code":201}}`,
+		`{"stage":"ResponseComplete","verb":"create","objectRef":{"resource":"pods","namespace":"default","name":"a","apiVersion":"v1","subresource":"exec"},"responseStatus":{"code":101}}`,
+		`{"stage":"ResponseComplete","verb":"create","objectRef":{"resource":"subjectaccessreviews","apiGroup":"authorization.k8s.io","apiVersion":"v1"},"responseStatus":{"code":201}}`,
+		`{"stage":"ResponseComplete","verb":"get","objectRef":{"resource":"configmaps","namespace":"default","name":"b","apiVersion":"v1"},"responseStatus":{"code":200}}`,
+		// A name generated in the response
+		`{"stage":"ResponseComplete","verb":"create","objectRef":{"resource":"leases","namespace":"kube-system","apiGroup":"coordination.k8s.io","apiVersion":"v1"},"responseStatus":{"code":201},"responseObject":{"metadata":{"name":"lease-x1"}}}`,
+		// Deleting the events written by name
+		`{"stage":"ResponseComplete","verb":"deletecollection","objectRef":{"resource":"events","namespace":"default","apiVersion":"v1"},"responseStatus":{"code":200}}`,
+	}, "\n")
+	audit, err := readAuditWrites(strings.NewReader(auditLog), "/registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trace := map[string]int{
+		"/registry/pods/default/a":              1,
+		"/registry/leases/kube-system/lease-x1": 1,
+		"/registry/events/default/e1":           1,
+		"/registry/events/default/e2":           1,
+		// Written bypassing the apiserver
+		"/registry/configmaps/default/b": 1,
+	}
+	got := compareAuditWrites("/registry", audit, trace)
+
+	wantResources := []auditComparison{
+		{Resource: "configmaps", Trace: 1, MissingFromAudit: 1},
+		{Resource: "events", Audit: 2, Trace: 2, Matched: 2},
+		{Resource: "leases.coordination.k8s.io", Audit: 1, Trace: 1, Matched: 1},
+		{Resource: "pods", Audit: 2, Trace: 1, Matched: 1, MissingFromTrace: 1},
+	}
+	if !reflect.DeepEqual(got.Resources, wantResources) {
+		t.Errorf("compareAuditWrites() resources = %+v, want %+v", got.Resources, wantResources)
+	}
+	wantDiscrepancies := []auditDiscrepancy{
+		{Key: "/registry/configmaps/default/b", Audit: 0, Trace: 1},
+		{Key: "/registry/pods/default/a", Audit: 2, Trace: 1},
+	}
+	if !reflect.DeepEqual(got.Discrepancies, wantDiscrepancies) {
+		t.Errorf("compareAuditWrites() discrepancies = %+v, want %+v", got.Discrepancies, wantDiscrepancies)
+	}
+}
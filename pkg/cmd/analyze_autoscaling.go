@@ -0,0 +1,265 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"text/tabwriter"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type analyzeAutoscalingFlagpole struct {
+	Output        string
+	Prefix        string
+	SinceRevision int64
+}
+
+func newCtlAnalyzeAutoscalingCommand() *cobra.Command {
+	flags := &analyzeAutoscalingFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Use:   "autoscaling",
+		Short: "Reports the autoscaling decisions against the pod and node churn from the etcd history",
+		Long: "Reports the autoscaling decisions against the pod and node churn from the etcd history.\n" +
+			"The replica changes of the HorizontalPodAutoscalers, the recommendation changes of the VerticalPodAutoscalers, " +
+			"the pods and the nodes created and deleted are listed in revision order, as a timeline to evaluate the autoscalers with.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = analyzeAutoscalingCommand(cmd.Context(), etcdclient, flags)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().Int64Var(&flags.SinceRevision, "since-revision", 0, "revision to start the timeline after")
+
+	return cmd
+}
+
+type autoscalingEvent struct {
+	Revision int64  `json:"revision"`
+	Type     string `json:"type"`
+	Object   string `json:"object"`
+	Change   string `json:"change"`
+}
+
+var (
+	hpaGroupResource  = schema.GroupResource{Group: "autoscaling", Resource: "horizontalpodautoscalers"}
+	podGroupResource  = schema.GroupResource{Resource: "pods"}
+	nodeGroupResource = schema.GroupResource{Resource: "nodes"}
+)
+
+const vpaResource = "verticalpodautoscalers"
+
+// autoscalingObject is the part of the autoscalers that is followed.
+type autoscalingObject struct {
+	Status struct {
+		CurrentReplicas *int32          `json:"currentReplicas"`
+		DesiredReplicas *int32          `json:"desiredReplicas"`
+		Recommendation  json.RawMessage `json:"recommendation"`
+	} `json:"status"`
+}
+
+func decodeAutoscalingObject(value []byte) (*autoscalingObject, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	_, data, err := convertValue(value, encoding.JsonMediaType)
+	if err != nil {
+		return nil, err
+	}
+	var obj autoscalingObject
+	err = json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
+
+// autoscalingEventOf returns the event of the change of the key, if it is of interest to the report.
+func autoscalingEventOf(prefix string, kv *client.KeyValue) (autoscalingEvent, bool, error) {
+	gr, rest, ok := groupResourceFromKey(prefix, string(kv.Key))
+	if !ok {
+		return autoscalingEvent{}, false, nil
+	}
+	event := autoscalingEvent{
+		Revision: kv.Revision,
+		Object:   rest,
+	}
+
+	switch {
+	case gr == podGroupResource, gr == nodeGroupResource:
+		event.Type = gr.Resource[:len(gr.Resource)-1]
+		switch {
+		case len(kv.Value) == 0:
+			event.Change = "deleted"
+		case len(kv.PrevValue) == 0:
+			event.Change = "created"
+		default:
+			return autoscalingEvent{}, false, nil
+		}
+		return event, true, nil
+
+	case gr == hpaGroupResource:
+		event.Type = "hpa"
+		obj, err := decodeAutoscalingObject(kv.Value)
+		if err != nil {
+			return autoscalingEvent{}, false, err
+		}
+		prev, err := decodeAutoscalingObject(kv.PrevValue)
+		if err != nil {
+			return autoscalingEvent{}, false, err
+		}
+		if obj == nil {
+			event.Change = "deleted"
+			return event, true, nil
+		}
+		var from *int32
+		if prev != nil {
+			from = prev.Status.DesiredReplicas
+		}
+		to := obj.Status.DesiredReplicas
+		if reflect.DeepEqual(from, to) {
+			return autoscalingEvent{}, false, nil
+		}
+		event.Change = fmt.Sprintf("desired replicas %s -> %s", formatReplicas(from), formatReplicas(to))
+		return event, true, nil
+
+	case gr.Resource == vpaResource:
+		event.Type = "vpa"
+		obj, err := decodeAutoscalingObject(kv.Value)
+		if err != nil {
+			return autoscalingEvent{}, false, err
+		}
+		prev, err := decodeAutoscalingObject(kv.PrevValue)
+		if err != nil {
+			return autoscalingEvent{}, false, err
+		}
+		if obj == nil {
+			event.Change = "deleted"
+			return event, true, nil
+		}
+		if prev != nil && string(prev.Status.Recommendation) == string(obj.Status.Recommendation) {
+			return autoscalingEvent{}, false, nil
+		}
+		if len(obj.Status.Recommendation) == 0 {
+			return autoscalingEvent{}, false, nil
+		}
+		event.Change = "recommendation " + string(obj.Status.Recommendation)
+		return event, true, nil
+	}
+	return autoscalingEvent{}, false, nil
+}
+
+func formatReplicas(replicas *int32) string {
+	if replicas == nil {
+		return "none"
+	}
+	return fmt.Sprint(*replicas)
+}
+
+func analyzeAutoscalingCommand(ctx context.Context, etcdclient client.Client, flags *analyzeAutoscalingFlagpole) error {
+	if flags.SinceRevision <= 0 {
+		return fmt.Errorf("since-revision is required")
+	}
+	if flags.Output != "table" && flags.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+
+	end, err := currentRevision(ctx, etcdclient, flags.Prefix)
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	var events []autoscalingEvent
+	if end > flags.SinceRevision {
+		err = etcdclient.Watch(ctx, flags.Prefix,
+			client.WithRevision(flags.SinceRevision+1),
+			client.WithMaxRevision(end),
+			client.WithGap(func(gap client.Gap) error {
+				fmt.Fprintf(os.Stderr, "warning: revisions %d-%d have been compacted and are missing from the report\n", gap.From, gap.To)
+				return nil
+			}),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				event, ok, err := autoscalingEventOf(flags.Prefix, kv)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", kv.Key, err)
+					return nil
+				}
+				if !ok {
+					return nil
+				}
+				counts[event.Type+" "+event.Change]++
+				events = append(events, event)
+				return nil
+			}),
+		)
+		if err != nil {
+			if errors.Is(err, rpctypes.ErrCompacted) {
+				return fmt.Errorf("revision %d has been compacted, choose a later one: %w", flags.SinceRevision, err)
+			}
+			return err
+		}
+	}
+
+	switch flags.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(events)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "REVISION\tTYPE\tOBJECT\tCHANGE")
+		for _, event := range events {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", event.Revision, event.Type, event.Object, event.Change)
+		}
+		err = w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	var scales int
+	for _, event := range events {
+		if event.Type == "hpa" || event.Type == "vpa" {
+			scales++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d autoscaler changes, %d pods created, %d pods deleted, %d nodes created, %d nodes deleted up to revision %d\n",
+		scales, counts["pod created"], counts["pod deleted"], counts["node created"], counts["node deleted"], end)
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+func TestAutoscalingEventOf(t *testing.T) {
+	hpa := func(desired int) []byte {
+		return []byte(`{"apiVersion":"autoscaling/v2","kind":"HorizontalPodAutoscaler","metadata":{"name":"web","namespace":"default"},"status":{"desiredReplicas":` + strconv.Itoa(desired) + `}}`)
+	}
+
+	tests := []struct {
+		name   string
+		kv     *client.KeyValue
+		want   autoscalingEvent
+		wantOk bool
+	}{
+		{
+			name: "pod created",
+			kv: &client.KeyValue{
+				Key:      []byte("/registry/pods/default/web-1"),
+				Value:    []byte(podJSON),
+				Revision: 10,
+			},
+			want:   autoscalingEvent{Revision: 10, Type: "pod", Object: "default/web-1", Change: "created"},
+			wantOk: true,
+		},
+		{
+			name: "pod updated",
+			kv: &client.KeyValue{
+				Key:       []byte("/registry/pods/default/web-1"),
+				Value:     []byte(podJSON),
+				PrevValue: []byte(podJSON),
+			},
+		},
+		{
+			name: "node deleted",
+			kv: &client.KeyValue{
+				Key:       []byte("/registry/minions/node-1"),
+				PrevValue: []byte("{}"),
+				Revision:  11,
+			},
+			want:   autoscalingEvent{Revision: 11, Type: "node", Object: "node-1", Change: "deleted"},
+			wantOk: true,
+		},
+		{
+			name: "hpa scaled",
+			kv: &client.KeyValue{
+				Key:       []byte("/registry/horizontalpodautoscalers/default/web"),
+				Value:     hpa(4),
+				PrevValue: hpa(2),
+				Revision:  12,
+			},
+			want:   autoscalingEvent{Revision: 12, Type: "hpa", Object: "default/web", Change: "desired replicas 2 -> 4"},
+			wantOk: true,
+		},
+		{
+			name: "hpa unchanged",
+			kv: &client.KeyValue{
+				Key:       []byte("/registry/horizontalpodautoscalers/default/web"),
+				Value:     hpa(2),
+				PrevValue: hpa(2),
+			},
+		},
+		{
+			name: "other",
+			kv: &client.KeyValue{
+				Key:   []byte("/registry/configmaps/default/web"),
+				Value: []byte("{}"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := autoscalingEventOf("/registry", tt.kv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("autoscalingEventOf() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,485 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type analyzeChurnFlagpole struct {
+	Output   string
+	Prefix   string
+	Interval time.Duration
+	Top      int
+}
+
+func newCtlAnalyzeChurnCommand() *cobra.Command {
+	flags := &analyzeChurnFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "churn <path>",
+		Short: "Reports the rates of the events of each resource, the top writers, the patch sizes and the busiest namespaces of a recording",
+		Long: "Reports the churn of a recording, - for stdin, as capacity data: the events of each resource with their rate and peak rate,\n" +
+			"the events of each interval of time, the field managers writing the most, the namespaces changing the most and the distribution of the sizes of the patches.\n" +
+			"The time of an event of a recording of kectl is the one of the bookmark before it, and the one of a kwok recording the duration of its change.\n" +
+			"The writer of an event is the manager of the latest managed fields of the object, and the patch of an update of a recording of kectl\n" +
+			"is the one from the version before it, as the patches of a kwok recording. The objects the recording starts with are not events.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := analyzeChurnCommand(flags, args[0], os.Stdout)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().DurationVar(&flags.Interval, "interval", time.Minute, "interval of time the events are counted by, for the peak rates and the timeline")
+	cmd.Flags().IntVar(&flags.Top, "top", 10, "number of the top writers and busiest namespaces to report, 0 for all")
+
+	return cmd
+}
+
+// churnPatchBuckets are the upper bounds of the buckets of the sizes of the patches, the last one is for the larger ones.
+var churnPatchBuckets = []int64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10}
+
+type churnResource struct {
+	Resource string `json:"resource"`
+	Events   int    `json:"events"`
+	Creates  int    `json:"creates"`
+	Updates  int    `json:"updates"`
+	Deletes  int    `json:"deletes"`
+	// Rate is the events per second over the recording, and PeakRate over its busiest interval
+	Rate       float64         `json:"rate"`
+	PeakRate   float64         `json:"peakRate"`
+	PatchBytes churnPatchBytes `json:"patchBytes"`
+
+	intervals map[int]int
+	patches   []int64
+}
+
+// churnPatchBytes is the distribution of the sizes of the patches, in percentiles.
+type churnPatchBytes struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P99 int64 `json:"p99"`
+	Max int64 `json:"max"`
+}
+
+type churnInterval struct {
+	// Start is the time since the start of the recording
+	Start    string  `json:"start"`
+	Events   int     `json:"events"`
+	Rate     float64 `json:"rate"`
+	Busiest  string  `json:"busiest,omitempty"`
+	resource map[string]int
+}
+
+type churnCount struct {
+	Name   string `json:"name"`
+	Events int    `json:"events"`
+}
+
+type churnPatchBucket struct {
+	// LE is the upper bound of the sizes of the patches, 0 for the ones larger than the other buckets
+	LE      int64 `json:"le"`
+	Patches int   `json:"patches"`
+}
+
+type churnReport struct {
+	Duration   string             `json:"duration"`
+	Interval   string             `json:"interval"`
+	Events     int                `json:"events"`
+	Rate       float64            `json:"rate"`
+	Resources  []*churnResource   `json:"resources"`
+	Intervals  []*churnInterval   `json:"intervals"`
+	Writers    []churnCount       `json:"writers"`
+	Namespaces []churnCount       `json:"namespaces"`
+	Patches    []churnPatchBucket `json:"patches"`
+}
+
+// recordingChurn counts the events of a recording as they are scanned.
+type recordingChurn struct {
+	prefix   string
+	interval time.Duration
+	jsonl    bool
+
+	recordingClock
+	// duration is the latest time of an event since the start
+	duration time.Duration
+	// state is the last version of the objects of a recording of kectl, as compared for the patches
+	state map[string][]byte
+
+	events     int
+	resources  map[string]*churnResource
+	intervals  map[int]*churnInterval
+	writers    map[string]int
+	namespaces map[string]int
+}
+
+func newRecordingChurn(prefix string, interval time.Duration, jsonl bool) *recordingChurn {
+	return &recordingChurn{
+		prefix:     prefix,
+		interval:   interval,
+		jsonl:      jsonl,
+		state:      map[string][]byte{},
+		resources:  map[string]*churnResource{},
+		intervals:  map[int]*churnInterval{},
+		writers:    map[string]int{},
+		namespaces: map[string]int{},
+	}
+}
+
+func (c *recordingChurn) add(doc *recordingRawDocument) error {
+	switch {
+	case doc.Header, doc.Bookmark != nil:
+		c.tick(doc)
+	case doc.Key != "":
+		return c.key(doc)
+	case doc.Object != nil && isKwokResourcePatch(doc.Object):
+		return c.resourcePatch(doc.Object)
+	}
+	return nil
+}
+
+func (c *recordingChurn) key(doc *recordingRawDocument) error {
+	gr, rest, ok := groupResourceFromKey(c.prefix, doc.Key)
+	if !ok {
+		return fmt.Errorf("key %q is not under %q", doc.Key, c.prefix)
+	}
+	namespace, _, err := splitName(rest)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", doc.Key, err)
+	}
+	object, err := keyDocumentObject(doc, c.jsonl)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", doc.Key, err)
+	}
+
+	var after []byte
+	var writer string
+	if object != nil && !doc.Deleted {
+		obj := &unstructured.Unstructured{Object: object}
+		writer = latestManager(obj)
+		after, err = comparableJSON(obj)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", doc.Key, err)
+		}
+	}
+	before := c.state[doc.Key]
+	if doc.Deleted {
+		delete(c.state, doc.Key)
+	} else if after != nil {
+		c.state[doc.Key] = after
+	}
+
+	method := c.method(doc)
+	if method == "object" {
+		return nil
+	}
+	patch := int64(-1)
+	if method == "update" && before != nil && after != nil {
+		data, err := twoWayPatch(before, after)
+		if err != nil {
+			return fmt.Errorf("patch %q: %w", doc.Key, err)
+		}
+		patch = int64(len(data))
+	}
+	c.event(c.elapsed(), gr.String(), namespace, method, writer, patch)
+	return nil
+}
+
+// resourcePatch counts the change of a kwok recording, apart from the checkpoints which are not changes of their own.
+func (c *recordingChurn) resourcePatch(obj *unstructured.Unstructured) error {
+	rp, err := decodeKwokResourcePatch(obj)
+	if err != nil {
+		return err
+	}
+	if rp.Checkpoint {
+		return nil
+	}
+	var writer string
+	if rp.Method != actionv1alpha1.PatchMethodDelete && len(rp.Template) != 0 {
+		template := &unstructured.Unstructured{}
+		err = template.UnmarshalJSON(rp.Template)
+		if err == nil {
+			writer = latestManager(template)
+		}
+	}
+	method := "update"
+	patch := int64(len(rp.Template))
+	switch rp.Method {
+	case actionv1alpha1.PatchMethodCreate:
+		method, patch = "create", -1
+	case actionv1alpha1.PatchMethodDelete:
+		method, patch = "delete", -1
+	}
+	c.event(rp.DurationNanosecond, rp.Resource.GroupResource().String(), rp.Target.Namespace, method, writer, patch)
+	return nil
+}
+
+// event counts an event at the time since the start, with the size of its patch, -1 for the events without one.
+func (c *recordingChurn) event(at time.Duration, resource, namespace, method, writer string, patch int64) {
+	c.events++
+	c.duration = max(c.duration, at)
+
+	r, ok := c.resources[resource]
+	if !ok {
+		r = &churnResource{
+			Resource:  resource,
+			intervals: map[int]int{},
+		}
+		c.resources[resource] = r
+	}
+	r.Events++
+	switch method {
+	case "create":
+		r.Creates++
+	case "update":
+		r.Updates++
+	case "delete":
+		r.Deletes++
+	}
+	if patch >= 0 {
+		r.patches = append(r.patches, patch)
+	}
+
+	n := int(at / c.interval)
+	r.intervals[n]++
+	i, ok := c.intervals[n]
+	if !ok {
+		i = &churnInterval{
+			Start:    "+" + (time.Duration(n) * c.interval).String(),
+			resource: map[string]int{},
+		}
+		c.intervals[n] = i
+	}
+	i.Events++
+	i.resource[resource]++
+
+	if writer != "" {
+		c.writers[writer]++
+	}
+	if namespace != "" {
+		c.namespaces[namespace]++
+	}
+}
+
+func (c *recordingChurn) report(top int) *churnReport {
+	report := &churnReport{
+		Duration:   c.duration.String(),
+		Interval:   c.interval.String(),
+		Events:     c.events,
+		Rate:       churnRate(c.events, c.duration),
+		Resources:  make([]*churnResource, 0, len(c.resources)),
+		Intervals:  make([]*churnInterval, 0, len(c.intervals)),
+		Writers:    topChurnCounts(c.writers, top),
+		Namespaces: topChurnCounts(c.namespaces, top),
+	}
+
+	var patches []int64
+	for _, r := range c.resources {
+		r.Rate = churnRate(r.Events, c.duration)
+		for _, n := range r.intervals {
+			r.PeakRate = max(r.PeakRate, churnRate(n, c.interval))
+		}
+		sort.Slice(r.patches, func(i, j int) bool {
+			return r.patches[i] < r.patches[j]
+		})
+		if len(r.patches) != 0 {
+			r.PatchBytes = churnPatchBytes{
+				P50: percentile(r.patches, 50),
+				P90: percentile(r.patches, 90),
+				P99: percentile(r.patches, 99),
+				Max: r.patches[len(r.patches)-1],
+			}
+		}
+		patches = append(patches, r.patches...)
+		report.Resources = append(report.Resources, r)
+	}
+	sort.Slice(report.Resources, func(i, j int) bool {
+		if report.Resources[i].Events != report.Resources[j].Events {
+			return report.Resources[i].Events > report.Resources[j].Events
+		}
+		return report.Resources[i].Resource < report.Resources[j].Resource
+	})
+
+	keys := make([]int, 0, len(c.intervals))
+	for n := range c.intervals {
+		keys = append(keys, n)
+	}
+	sort.Ints(keys)
+	for _, n := range keys {
+		i := c.intervals[n]
+		i.Rate = churnRate(i.Events, c.interval)
+		for resource, events := range i.resource {
+			if events > i.resource[i.Busiest] || (events == i.resource[i.Busiest] && resource < i.Busiest) {
+				i.Busiest = resource
+			}
+		}
+		report.Intervals = append(report.Intervals, i)
+	}
+
+	report.Patches = make([]churnPatchBucket, len(churnPatchBuckets)+1)
+	for i, le := range churnPatchBuckets {
+		report.Patches[i].LE = le
+	}
+	for _, patch := range patches {
+		i := sort.Search(len(churnPatchBuckets), func(i int) bool {
+			return patch <= churnPatchBuckets[i]
+		})
+		report.Patches[i].Patches++
+	}
+	return report
+}
+
+// churnRate returns the events per second over the duration, 0 for a recording of no duration.
+func churnRate(events int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(events) / d.Seconds()
+}
+
+// percentile returns the nearest-rank percentile of the sorted values.
+func percentile(sorted []int64, p int) int64 {
+	i := (len(sorted)*p + 99) / 100
+	return sorted[max(i, 1)-1]
+}
+
+// topChurnCounts returns the counts, the largest first, up to top, 0 for all.
+func topChurnCounts(counts map[string]int, top int) []churnCount {
+	sorted := make([]churnCount, 0, len(counts))
+	for name, events := range counts {
+		sorted = append(sorted, churnCount{Name: name, Events: events})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Events != sorted[j].Events {
+			return sorted[i].Events > sorted[j].Events
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	if top > 0 && len(sorted) > top {
+		sorted = sorted[:top]
+	}
+	return sorted
+}
+
+// latestManager returns the manager of the latest managed fields of the object, the one that wrote it last.
+func latestManager(obj *unstructured.Unstructured) string {
+	var manager string
+	var latest time.Time
+	for _, field := range obj.GetManagedFields() {
+		if field.Time != nil && !field.Time.Time.Before(latest) {
+			latest = field.Time.Time
+			manager = field.Manager
+		}
+	}
+	return manager
+}
+
+func analyzeChurnCommand(flags *analyzeChurnFlagpole, path string, w io.Writer) (err error) {
+	if flags.Output != "table" && flags.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+	if flags.Interval <= 0 {
+		return fmt.Errorf("invalid interval %s", flags.Interval)
+	}
+
+	var r io.ReadCloser
+	if path == "-" {
+		r, err = decompress(os.Stdin)
+	} else {
+		r, err = openRecording(path)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	scanner := newRecordingScanner(r, flags.Prefix)
+	churn := newRecordingChurn(flags.Prefix, flags.Interval, scanner.JSONL)
+	err = scanner.Scan(churn.add)
+	if err != nil {
+		return err
+	}
+	report := churn.report(flags.Top)
+
+	switch flags.Output {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(tw, "RESOURCE\tEVENTS\tCREATES\tUPDATES\tDELETES\tRATE\tPEAK RATE\tPATCH P50\tPATCH P99\tPATCH MAX")
+		for _, r := range report.Resources {
+			p50, p99, pmax := "-", "-", "-"
+			if len(r.patches) != 0 {
+				p50, p99, pmax = formatBytes(float64(r.PatchBytes.P50)), formatBytes(float64(r.PatchBytes.P99)), formatBytes(float64(r.PatchBytes.Max))
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%.2f/s\t%.2f/s\t%s\t%s\t%s\n", r.Resource, r.Events, r.Creates, r.Updates, r.Deletes, r.Rate, r.PeakRate, p50, p99, pmax)
+		}
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "TIME\tEVENTS\tRATE\tBUSIEST")
+		for _, i := range report.Intervals {
+			fmt.Fprintf(tw, "%s\t%d\t%.2f/s\t%s\n", i.Start, i.Events, i.Rate, i.Busiest)
+		}
+		if len(report.Writers) != 0 {
+			fmt.Fprintln(tw)
+			fmt.Fprintln(tw, "WRITER\tEVENTS")
+			for _, c := range report.Writers {
+				fmt.Fprintf(tw, "%s\t%d\n", c.Name, c.Events)
+			}
+		}
+		if len(report.Namespaces) != 0 {
+			fmt.Fprintln(tw)
+			fmt.Fprintln(tw, "NAMESPACE\tEVENTS")
+			for _, c := range report.Namespaces {
+				fmt.Fprintf(tw, "%s\t%d\n", c.Name, c.Events)
+			}
+		}
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "PATCH SIZE\tPATCHES")
+		for _, b := range report.Patches {
+			size := "> " + formatBytes(float64(churnPatchBuckets[len(churnPatchBuckets)-1]))
+			if b.LE != 0 {
+				size = "<= " + formatBytes(float64(b.LE))
+			}
+			fmt.Fprintf(tw, "%s\t%d\n", size, b.Patches)
+		}
+		err = tw.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d events over %s, %.2f/s\n", report.Events, report.Duration, report.Rate)
+	return nil
+}
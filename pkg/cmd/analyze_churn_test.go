@@ -0,0 +1,129 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordingChurn(t *testing.T) {
+	const recording = `---
+# clock-skew | 10ms | 500ms | 2024-01-01T00:00:00Z
+---
+# /registry/pods/default/a | application/json | 2
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+spec:
+  nodeName: ""
+---
+# bookmark | 3 | 2024-01-01T00:00:30Z
+---
+# /registry/pods/default/a | application/json | 4
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+  managedFields:
+  - manager: kube-scheduler
+    operation: Update
+    time: "2024-01-01T00:00:31Z"
+spec:
+  nodeName: node-1
+---
+# /registry/pods/kube-system/b | application/json | 5
+apiVersion: v1
+kind: Pod
+metadata:
+  name: b
+  namespace: kube-system
+  managedFields:
+  - manager: kubelet
+    operation: Update
+    time: "2024-01-01T00:00:31Z"
+---
+# bookmark | 6 | 2024-01-01T00:02:00Z
+---
+# /registry/pods/default/a | 7 | deleted
+---
+# /registry/nodes/node-1 | application/json | 8
+apiVersion: v1
+kind: Node
+metadata:
+  name: node-1
+  managedFields:
+  - manager: kubelet
+    operation: Update
+    time: "2024-01-01T00:02:01Z"
+`
+	scanner := newRecordingScanner(strings.NewReader(recording), "/registry")
+	churn := newRecordingChurn("/registry", time.Minute, scanner.JSONL)
+	err := scanner.Scan(churn.add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := churn.report(10)
+
+	if report.Events != 4 || report.Duration != "2m0s" {
+		t.Errorf("events = %d over %s, want 4 over 2m0s", report.Events, report.Duration)
+	}
+	pods := report.Resources[0]
+	if pods.Resource != "pods" || pods.Creates != 1 || pods.Updates != 1 || pods.Deletes != 1 {
+		t.Errorf("pods = %+v, want 1 create, 1 update and 1 delete", pods)
+	}
+	if pods.PeakRate != 2.0/60 {
+		t.Errorf("pods peak rate = %v, want %v", pods.PeakRate, 2.0/60)
+	}
+	if pods.PatchBytes.Max != int64(len(`{"spec":{"nodeName":"node-1"}}`)) {
+		t.Errorf("pods patch bytes = %+v", pods.PatchBytes)
+	}
+
+	var intervals []string
+	for _, i := range report.Intervals {
+		intervals = append(intervals, i.Start+" "+i.Busiest)
+	}
+	wantIntervals := []string{"+0s pods", "+2m0s nodes"}
+	if !reflect.DeepEqual(intervals, wantIntervals) {
+		t.Errorf("intervals = %q, want %q", intervals, wantIntervals)
+	}
+	wantWriters := []churnCount{{Name: "kubelet", Events: 2}, {Name: "kube-scheduler", Events: 1}}
+	if !reflect.DeepEqual(report.Writers, wantWriters) {
+		t.Errorf("writers = %+v, want %+v", report.Writers, wantWriters)
+	}
+	wantNamespaces := []churnCount{{Name: "default", Events: 2}, {Name: "kube-system", Events: 1}}
+	if !reflect.DeepEqual(report.Namespaces, wantNamespaces) {
+		t.Errorf("namespaces = %+v, want %+v", report.Namespaces, wantNamespaces)
+	}
+	if report.Patches[0].Patches != 1 {
+		t.Errorf("patches = %+v, want 1 of up to 256 bytes", report.Patches)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for p, want := range map[int]int64{50: 5, 90: 9, 99: 10, 1: 1} {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("percentile(%d) = %d, want %d", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/scheme"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type analyzeDeprecationsFlagpole struct {
+	Output        string
+	Prefix        string
+	ChunkSize     int64
+	Consistency   string
+	TargetVersion string
+}
+
+func newCtlAnalyzeDeprecationsCommand() *cobra.Command {
+	flags := &analyzeDeprecationsFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.RangeArgs(0, 1),
+		Use:   "deprecations [resource]",
+		Short: "Reports the resource of k8s in etcd stored at API versions removed from Kubernetes",
+		Long: "Reports the resource of k8s in etcd stored at API versions removed from Kubernetes, with the version to migrate them to.\n" +
+			"The objects are served at the newer versions, but an apiserver that no longer knows the stored version can not decode them, " +
+			"so they are to be migrated before upgrading to the release removing it.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = analyzeDeprecationsCommand(cmd.Context(), etcdclient, flags, args)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().Int64Var(&flags.ChunkSize, "chunk-size", 500, "chunk size of the list pager")
+	cmd.Flags().StringVar(&flags.Consistency, "consistency", "s", "consistency of the reads. One of: (l, s, linearizable, serializable).")
+	cmd.Flags().StringVar(&flags.TargetVersion, "target-version", "", "Kubernetes release to upgrade to, e.g. 1.32, to only report the versions removed up to it")
+
+	return cmd
+}
+
+// removedAPI is an API version of a resource removed from Kubernetes.
+type removedAPI struct {
+	Resource    string
+	APIVersion  string
+	RemovedIn   int
+	Replacement string
+}
+
+// removedAPIs are the API versions of the persisted resources removed from Kubernetes, by the minor version removing them,
+// from https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var removedAPIs = []removedAPI{
+	{Resource: "daemonsets", APIVersion: "extensions/v1beta1", RemovedIn: 16, Replacement: "apps/v1"},
+	{Resource: "deployments", APIVersion: "extensions/v1beta1", RemovedIn: 16, Replacement: "apps/v1"},
+	{Resource: "replicasets", APIVersion: "extensions/v1beta1", RemovedIn: 16, Replacement: "apps/v1"},
+	{Resource: "networkpolicies", APIVersion: "extensions/v1beta1", RemovedIn: 16, Replacement: "networking.k8s.io/v1"},
+	{Resource: "podsecuritypolicies", APIVersion: "extensions/v1beta1", RemovedIn: 16, Replacement: "policy/v1beta1"},
+	{Resource: "controllerrevisions", APIVersion: "apps/v1beta1", RemovedIn: 16, Replacement: "apps/v1"},
+	{Resource: "deployments", APIVersion: "apps/v1beta1", RemovedIn: 16, Replacement: "apps/v1"},
+	{Resource: "statefulsets", APIVersion: "apps/v1beta1", RemovedIn: 16, Replacement: "apps/v1"},
+	{Resource: "controllerrevisions", APIVersion: "apps/v1beta2", RemovedIn: 16, Replacement: "apps/v1"},
+	{Resource: "daemonsets", APIVersion: "apps/v1beta2", RemovedIn: 16, Replacement: "apps/v1"},
+	{Resource: "deployments", APIVersion: "apps/v1beta2", RemovedIn: 16, Replacement: "apps/v1"},
+	{Resource: "replicasets", APIVersion: "apps/v1beta2", RemovedIn: 16, Replacement: "apps/v1"},
+	{Resource: "statefulsets", APIVersion: "apps/v1beta2", RemovedIn: 16, Replacement: "apps/v1"},
+
+	{Resource: "mutatingwebhookconfigurations", APIVersion: "admissionregistration.k8s.io/v1beta1", RemovedIn: 22, Replacement: "admissionregistration.k8s.io/v1"},
+	{Resource: "validatingwebhookconfigurations", APIVersion: "admissionregistration.k8s.io/v1beta1", RemovedIn: 22, Replacement: "admissionregistration.k8s.io/v1"},
+	{Resource: "customresourcedefinitions", APIVersion: "apiextensions.k8s.io/v1beta1", RemovedIn: 22, Replacement: "apiextensions.k8s.io/v1"},
+	{Resource: "apiservices", APIVersion: "apiregistration.k8s.io/v1beta1", RemovedIn: 22, Replacement: "apiregistration.k8s.io/v1"},
+	{Resource: "certificatesigningrequests", APIVersion: "certificates.k8s.io/v1beta1", RemovedIn: 22, Replacement: "certificates.k8s.io/v1"},
+	{Resource: "leases", APIVersion: "coordination.k8s.io/v1beta1", RemovedIn: 22, Replacement: "coordination.k8s.io/v1"},
+	{Resource: "ingresses", APIVersion: "extensions/v1beta1", RemovedIn: 22, Replacement: "networking.k8s.io/v1"},
+	{Resource: "ingresses", APIVersion: "networking.k8s.io/v1beta1", RemovedIn: 22, Replacement: "networking.k8s.io/v1"},
+	{Resource: "ingressclasses", APIVersion: "networking.k8s.io/v1beta1", RemovedIn: 22, Replacement: "networking.k8s.io/v1"},
+	{Resource: "clusterrolebindings", APIVersion: "rbac.authorization.k8s.io/v1beta1", RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{Resource: "clusterroles", APIVersion: "rbac.authorization.k8s.io/v1beta1", RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{Resource: "rolebindings", APIVersion: "rbac.authorization.k8s.io/v1beta1", RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{Resource: "roles", APIVersion: "rbac.authorization.k8s.io/v1beta1", RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{Resource: "priorityclasses", APIVersion: "scheduling.k8s.io/v1beta1", RemovedIn: 22, Replacement: "scheduling.k8s.io/v1"},
+	{Resource: "csidrivers", APIVersion: "storage.k8s.io/v1beta1", RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{Resource: "csinodes", APIVersion: "storage.k8s.io/v1beta1", RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{Resource: "storageclasses", APIVersion: "storage.k8s.io/v1beta1", RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{Resource: "volumeattachments", APIVersion: "storage.k8s.io/v1beta1", RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+
+	{Resource: "cronjobs", APIVersion: "batch/v1beta1", RemovedIn: 25, Replacement: "batch/v1"},
+	{Resource: "endpointslices", APIVersion: "discovery.k8s.io/v1beta1", RemovedIn: 25, Replacement: "discovery.k8s.io/v1"},
+	{Resource: "events", APIVersion: "events.k8s.io/v1beta1", RemovedIn: 25, Replacement: "events.k8s.io/v1"},
+	{Resource: "horizontalpodautoscalers", APIVersion: "autoscaling/v2beta1", RemovedIn: 25, Replacement: "autoscaling/v2"},
+	{Resource: "poddisruptionbudgets", APIVersion: "policy/v1beta1", RemovedIn: 25, Replacement: "policy/v1"},
+	// Replaced by the Pod Security Admission, which has no objects
+	{Resource: "podsecuritypolicies", APIVersion: "policy/v1beta1", RemovedIn: 25},
+	{Resource: "runtimeclasses", APIVersion: "node.k8s.io/v1beta1", RemovedIn: 25, Replacement: "node.k8s.io/v1"},
+
+	{Resource: "flowschemas", APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", RemovedIn: 26, Replacement: "flowcontrol.apiserver.k8s.io/v1beta3"},
+	{Resource: "prioritylevelconfigurations", APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", RemovedIn: 26, Replacement: "flowcontrol.apiserver.k8s.io/v1beta3"},
+	{Resource: "horizontalpodautoscalers", APIVersion: "autoscaling/v2beta2", RemovedIn: 26, Replacement: "autoscaling/v2"},
+
+	{Resource: "csistoragecapacities", APIVersion: "storage.k8s.io/v1beta1", RemovedIn: 27, Replacement: "storage.k8s.io/v1"},
+
+	{Resource: "flowschemas", APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", RemovedIn: 29, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{Resource: "prioritylevelconfigurations", APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", RemovedIn: 29, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+
+	{Resource: "flowschemas", APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", RemovedIn: 32, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{Resource: "prioritylevelconfigurations", APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", RemovedIn: 32, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// parseMinorVersion parses the minor version of a Kubernetes 1.x release, such as 1.32 or v1.32.0.
+func parseMinorVersion(s string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] != "1" {
+		return 0, fmt.Errorf("invalid Kubernetes version %q", s)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil || minor < 0 {
+		return 0, fmt.Errorf("invalid Kubernetes version %q", s)
+	}
+	return minor, nil
+}
+
+type deprecation struct {
+	Resource      string `json:"resource"`
+	StoredVersion string `json:"storedVersion"`
+	RemovedIn     string `json:"removedIn"`
+	Replacement   string `json:"replacement,omitempty"`
+	Count         int    `json:"count"`
+	Suggestion    string `json:"suggestion"`
+
+	removedIn int
+}
+
+// deprecationSuggestion returns how the objects of the resource stored at the removed API version are migrated.
+func deprecationSuggestion(gr schema.GroupResource, api removedAPI) string {
+	if api.Replacement == "" {
+		return fmt.Sprintf("delete them, %s has no replacement", api.APIVersion)
+	}
+	stored, err := schema.ParseGroupVersion(api.APIVersion)
+	if err != nil {
+		return ""
+	}
+	replacement, err := schema.ParseGroupVersion(api.Replacement)
+	if err == nil && replacement.Group == stored.Group && canConvertVersion(gr, stored, replacement) {
+		return fmt.Sprintf("kectl migrate storage-version %s --to-version %s", gr, api.Replacement)
+	}
+	// The objects move to another group, or to a version kectl has no conversion to, which has to be converted by an apiserver
+	return fmt.Sprintf("kubectl get %s -A -o json | kubectl replace -f -, served from %s", gr, api.Replacement)
+}
+
+// canConvertVersion returns whether the scheme has a conversion of the kind of the resource from the version to the target version.
+func canConvertVersion(gr schema.GroupResource, gv, targetGv schema.GroupVersion) bool {
+	for kind := range scheme.Scheme.KnownTypes(gv) {
+		gvk := gv.WithKind(kind)
+		plural, _ := meta.UnsafeGuessKindToResource(gvk)
+		if plural.Resource != gr.Resource {
+			continue
+		}
+		_, err := convertObjectVersion([]byte("{}"), gvk, targetGv.WithKind(kind))
+		return err == nil
+	}
+	return false
+}
+
+func analyzeDeprecationsCommand(ctx context.Context, etcdclient client.Client, flags *analyzeDeprecationsFlagpole, args []string) error {
+	var targetGr schema.GroupResource
+	if len(args) != 0 {
+		gr := schema.ParseGroupResource(args[0])
+		if gr.Empty() {
+			return fmt.Errorf("invalid resource %q", args[0])
+		}
+		if correctGr, _, found := wellknown.CorrectGroupResource(gr); found {
+			gr = correctGr
+		}
+		targetGr = gr
+	}
+
+	targetMinor := -1
+	if flags.TargetVersion != "" {
+		var err error
+		targetMinor, err = parseMinorVersion(flags.TargetVersion)
+		if err != nil {
+			return err
+		}
+	}
+
+	if flags.Output != "table" && flags.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+
+	consistencyOpts, err := consistencyOpOptions(flags.Consistency, false)
+	if err != nil {
+		return err
+	}
+
+	results, total, err := findDeprecations(ctx, etcdclient, flags.Prefix, targetMinor,
+		append(consistencyOpts,
+			client.WithGR(targetGr),
+			client.WithPageLimit(flags.ChunkSize),
+		)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	switch flags.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(results)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "RESOURCE\tSTORED VERSION\tREMOVED IN\tREPLACEMENT\tCOUNT\tSUGGESTION")
+		for _, result := range results {
+			replacement := result.Replacement
+			if replacement == "" {
+				replacement = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", result.Resource, result.StoredVersion, result.RemovedIn, replacement, result.Count, result.Suggestion)
+		}
+		err = w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	if flags.TargetVersion != "" {
+		fmt.Fprintf(os.Stderr, "%d objects are stored at API versions removed up to %s\n", total, flags.TargetVersion)
+	} else {
+		fmt.Fprintf(os.Stderr, "%d objects are stored at removed API versions\n", total)
+	}
+	return nil
+}
+
+// findDeprecations returns the resources stored at the API versions removed up to the target minor version, or all of them if it is negative,
+// with the number of the objects stored at them.
+func findDeprecations(ctx context.Context, etcdclient client.Client, prefix string, targetMinor int, opOpts ...client.OpOption) ([]deprecation, int, error) {
+	removed := map[[2]string]removedAPI{}
+	for _, api := range removedAPIs {
+		if targetMinor >= 0 && api.RemovedIn > targetMinor {
+			continue
+		}
+		removed[[2]string{api.Resource, api.APIVersion}] = api
+	}
+
+	type storedAt struct {
+		gr      schema.GroupResource
+		version string
+	}
+	counts := map[storedAt]int{}
+	var total int
+	_, err := etcdclient.Get(ctx, prefix,
+		append(opOpts,
+			client.WithResponse(func(kv *client.KeyValue) error {
+				gr, _, ok := groupResourceFromKey(prefix, string(kv.Key))
+				if !ok {
+					return nil
+				}
+				version := storageVersionOf(kv.Value)
+				if _, ok := removed[[2]string{gr.Resource, version}]; !ok {
+					return nil
+				}
+				counts[storedAt{gr: gr, version: version}]++
+				total++
+				return nil
+			}),
+		)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]deprecation, 0, len(counts))
+	for at, count := range counts {
+		api := removed[[2]string{at.gr.Resource, at.version}]
+		results = append(results, deprecation{
+			Resource:      at.gr.String(),
+			StoredVersion: at.version,
+			RemovedIn:     fmt.Sprintf("1.%d", api.RemovedIn),
+			Replacement:   api.Replacement,
+			Count:         count,
+			Suggestion:    deprecationSuggestion(at.gr, api),
+			removedIn:     api.RemovedIn,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.removedIn != b.removedIn {
+			return a.removedIn < b.removedIn
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.StoredVersion < b.StoredVersion
+	})
+	return results, total, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFindDeprecations(t *testing.T) {
+	etcdclient := fake.NewClient()
+	for _, obj := range []struct {
+		gr    schema.GroupResource
+		name  string
+		value string
+	}{
+		{schema.GroupResource{Group: "apps", Resource: "deployments"}, "a", `{"apiVersion":"extensions/v1beta1","kind":"Deployment"}`},
+		{schema.GroupResource{Group: "apps", Resource: "deployments"}, "b", `{"apiVersion":"apps/v1","kind":"Deployment"}`},
+		{schema.GroupResource{Group: "policy", Resource: "poddisruptionbudgets"}, "a", `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget"}`},
+		{schema.GroupResource{Group: "policy", Resource: "poddisruptionbudgets"}, "b", `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget"}`},
+		{schema.GroupResource{Group: "flowcontrol.apiserver.k8s.io", Resource: "flowschemas"}, "a", `{"apiVersion":"flowcontrol.apiserver.k8s.io/v1beta3","kind":"FlowSchema"}`},
+	} {
+		err := etcdclient.Put(context.Background(), "/registry", []byte(obj.value),
+			client.WithGR(obj.gr),
+			client.WithName(obj.name, "default"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		targetMinor int
+		want        []deprecation
+		wantTotal   int
+	}{
+		{
+			name:        "all",
+			targetMinor: -1,
+			want: []deprecation{
+				{Resource: "deployments.apps", StoredVersion: "extensions/v1beta1", RemovedIn: "1.16", Replacement: "apps/v1", Count: 1, removedIn: 16,
+					Suggestion: "kubectl get deployments.apps -A -o json | kubectl replace -f -, served from apps/v1"},
+				{Resource: "poddisruptionbudgets.policy", StoredVersion: "policy/v1beta1", RemovedIn: "1.25", Replacement: "policy/v1", Count: 2, removedIn: 25,
+					Suggestion: "kectl migrate storage-version poddisruptionbudgets.policy --to-version policy/v1"},
+				{Resource: "flowschemas.flowcontrol.apiserver.k8s.io", StoredVersion: "flowcontrol.apiserver.k8s.io/v1beta3", RemovedIn: "1.32", Replacement: "flowcontrol.apiserver.k8s.io/v1", Count: 1, removedIn: 32,
+					Suggestion: "kubectl get flowschemas.flowcontrol.apiserver.k8s.io -A -o json | kubectl replace -f -, served from flowcontrol.apiserver.k8s.io/v1"},
+			},
+			wantTotal: 4,
+		},
+		{
+			name:        "up to the target",
+			targetMinor: 16,
+			want: []deprecation{
+				{Resource: "deployments.apps", StoredVersion: "extensions/v1beta1", RemovedIn: "1.16", Replacement: "apps/v1", Count: 1, removedIn: 16,
+					Suggestion: "kubectl get deployments.apps -A -o json | kubectl replace -f -, served from apps/v1"},
+			},
+			wantTotal: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := findDeprecations(context.Background(), etcdclient, "/registry", tt.targetMinor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || total != tt.wantTotal {
+				t.Errorf("findDeprecations() = %+v, %d, want %+v, %d", got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestParseMinorVersion(t *testing.T) {
+	for _, s := range []string{"1.32", "v1.32", "v1.32.0"} {
+		minor, err := parseMinorVersion(s)
+		if err != nil || minor != 32 {
+			t.Errorf("parseMinorVersion(%q) = %d, %v, want 32", s, minor, err)
+		}
+	}
+	for _, s := range []string{"", "32", "2.0", "1.x"} {
+		_, err := parseMinorVersion(s)
+		if err == nil {
+			t.Errorf("parseMinorVersion(%q) is not an error", s)
+		}
+	}
+}
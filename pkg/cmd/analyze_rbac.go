@@ -0,0 +1,431 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type analyzeRBACFlagpole struct {
+	Output        string
+	Prefix        string
+	ChunkSize     int64
+	Consistency   string
+	IncludeSystem bool
+}
+
+func newCtlAnalyzeRBACCommand() *cobra.Command {
+	flags := &analyzeRBACFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Use:   "rbac",
+		Short: "Reports the wildcard grants, the bindings to missing subjects or roles, and the unused roles in etcd",
+		Long: "Reports the wildcard grants, the bindings to missing subjects or roles, and the unused roles in etcd,\n" +
+			"as a security review read straight from the storage when the apiserver can not be queried.\n" +
+			"The subjects checked are the ServiceAccounts and their namespaces, the users and groups are not stored in etcd.\n" +
+			"The roles of the defaults of k8s, system: prefixed or labeled kubernetes.io/bootstrapping=rbac-defaults, are left out unless --include-system.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = analyzeRBACCommand(cmd.Context(), etcdclient, flags)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().Int64Var(&flags.ChunkSize, "chunk-size", 500, "chunk size of the list pager")
+	cmd.Flags().StringVar(&flags.Consistency, "consistency", "s", "consistency of the reads. One of: (l, s, linearizable, serializable).")
+	cmd.Flags().BoolVar(&flags.IncludeSystem, "include-system", false, "also report the roles and bindings of the defaults of k8s")
+
+	return cmd
+}
+
+// The kinds of the findings of the RBAC analysis.
+const (
+	rbacFindingWildcard       = "wildcard"
+	rbacFindingMissingSubject = "missing-subject"
+	rbacFindingMissingRole    = "missing-role"
+	rbacFindingUnused         = "unused"
+)
+
+type rbacFinding struct {
+	Kind   string `json:"kind"`
+	Object string `json:"object"`
+	Detail string `json:"detail"`
+}
+
+var (
+	roleGroupResource               = schema.GroupResource{Group: rbacv1.GroupName, Resource: "roles"}
+	roleBindingGroupResource        = schema.GroupResource{Group: rbacv1.GroupName, Resource: "rolebindings"}
+	clusterRoleGroupResource        = schema.GroupResource{Group: rbacv1.GroupName, Resource: "clusterroles"}
+	clusterRoleBindingGroupResource = schema.GroupResource{Group: rbacv1.GroupName, Resource: "clusterrolebindings"}
+	serviceAccountGroupResource     = schema.GroupResource{Resource: "serviceaccounts"}
+	namespaceGroupResource          = schema.GroupResource{Resource: "namespaces"}
+)
+
+// rbacInventory is what the RBAC analysis reads from etcd.
+type rbacInventory struct {
+	roles               []rbacv1.Role
+	roleBindings        []rbacv1.RoleBinding
+	clusterRoles        []rbacv1.ClusterRole
+	clusterRoleBindings []rbacv1.ClusterRoleBinding
+	// serviceAccounts are the namespace/name of the ServiceAccounts
+	serviceAccounts map[string]struct{}
+	namespaces      map[string]struct{}
+}
+
+// readRBACInventory reads the RBAC objects, and the keys of the ServiceAccounts and namespaces they may refer to.
+func readRBACInventory(ctx context.Context, etcdclient client.Client, prefix string, opOpts ...client.OpOption) (*rbacInventory, error) {
+	inv := &rbacInventory{
+		serviceAccounts: map[string]struct{}{},
+		namespaces:      map[string]struct{}{},
+	}
+
+	decode := func(gr schema.GroupResource, add func(data []byte) error) error {
+		_, err := etcdclient.Get(ctx, prefix,
+			append(opOpts,
+				client.WithGR(gr),
+				client.WithResponse(func(kv *client.KeyValue) error {
+					_, data, err := convertValue(kv.Value, encoding.JsonMediaType)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "%s: %v\n", kv.Key, err)
+						return nil
+					}
+					err = add(data)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "%s: %v\n", kv.Key, err)
+					}
+					return nil
+				}),
+			)...,
+		)
+		return err
+	}
+	err := decode(roleGroupResource, func(data []byte) error {
+		var obj rbacv1.Role
+		err := json.Unmarshal(data, &obj)
+		inv.roles = append(inv.roles, obj)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = decode(roleBindingGroupResource, func(data []byte) error {
+		var obj rbacv1.RoleBinding
+		err := json.Unmarshal(data, &obj)
+		inv.roleBindings = append(inv.roleBindings, obj)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = decode(clusterRoleGroupResource, func(data []byte) error {
+		var obj rbacv1.ClusterRole
+		err := json.Unmarshal(data, &obj)
+		inv.clusterRoles = append(inv.clusterRoles, obj)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = decode(clusterRoleBindingGroupResource, func(data []byte) error {
+		var obj rbacv1.ClusterRoleBinding
+		err := json.Unmarshal(data, &obj)
+		inv.clusterRoleBindings = append(inv.clusterRoleBindings, obj)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The ServiceAccounts and namespaces are only checked to exist, their keys are enough
+	for _, keys := range []struct {
+		gr  schema.GroupResource
+		set map[string]struct{}
+	}{
+		{serviceAccountGroupResource, inv.serviceAccounts},
+		{namespaceGroupResource, inv.namespaces},
+	} {
+		_, err = etcdclient.Get(ctx, prefix,
+			append(opOpts,
+				client.WithGR(keys.gr),
+				client.WithKeysOnly(),
+				client.WithResponse(func(kv *client.KeyValue) error {
+					_, rest, ok := groupResourceFromKey(prefix, string(kv.Key))
+					if ok {
+						keys.set[rest] = struct{}{}
+					}
+					return nil
+				}),
+			)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return inv, nil
+}
+
+// isSystemRBAC returns whether the RBAC object is one of the defaults of k8s.
+func isSystemRBAC(meta metav1.ObjectMeta) bool {
+	return strings.HasPrefix(meta.Name, "system:") || meta.Labels["kubernetes.io/bootstrapping"] == "rbac-defaults"
+}
+
+// wildcardRules returns the rules granting any verb, resource or API group, or any non-resource URL.
+func wildcardRules(rules []rbacv1.PolicyRule) []string {
+	var found []string
+	for _, rule := range rules {
+		if !slices.Contains(rule.Verbs, rbacv1.VerbAll) &&
+			!slices.Contains(rule.APIGroups, rbacv1.APIGroupAll) &&
+			!slices.Contains(rule.Resources, rbacv1.ResourceAll) &&
+			!slices.Contains(rule.NonResourceURLs, rbacv1.NonResourceAll) {
+			continue
+		}
+		if len(rule.NonResourceURLs) != 0 {
+			found = append(found, fmt.Sprintf("grants verbs %s on nonResourceURLs %s",
+				formatRuleValues(rule.Verbs), formatRuleValues(rule.NonResourceURLs)))
+			continue
+		}
+		found = append(found, fmt.Sprintf("grants verbs %s on apiGroups %s resources %s",
+			formatRuleValues(rule.Verbs), formatRuleValues(rule.APIGroups), formatRuleValues(rule.Resources)))
+	}
+	return found
+}
+
+func formatRuleValues(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		// The core group is the empty one
+		if value == "" {
+			value = `""`
+		}
+		quoted = append(quoted, value)
+	}
+	return "[" + strings.Join(quoted, " ") + "]"
+}
+
+// rbacFindingOrder is the order the kinds of findings are reported in, the most severe first.
+var rbacFindingOrder = map[string]int{
+	rbacFindingWildcard:       0,
+	rbacFindingMissingSubject: 1,
+	rbacFindingMissingRole:    2,
+	rbacFindingUnused:         3,
+}
+
+// analyzeRBAC returns the findings of the RBAC objects, sorted by kind and object.
+func analyzeRBAC(inv *rbacInventory, includeSystem bool) []rbacFinding {
+	var findings []rbacFinding
+
+	roles := map[string]struct{}{}
+	for _, role := range inv.roles {
+		roles[path.Join(role.Namespace, role.Name)] = struct{}{}
+	}
+	clusterRoles := map[string]struct{}{}
+	for _, role := range inv.clusterRoles {
+		clusterRoles[role.Name] = struct{}{}
+	}
+
+	// The roles referred to by the bindings, as namespace/name for the Roles and name for the ClusterRoles
+	usedRoles := map[string]struct{}{}
+	usedClusterRoles := map[string]struct{}{}
+	checkBinding := func(meta metav1.ObjectMeta, object string, subjects []rbacv1.Subject, roleRef rbacv1.RoleRef) {
+		var found bool
+		switch roleRef.Kind {
+		case "Role":
+			usedRoles[path.Join(meta.Namespace, roleRef.Name)] = struct{}{}
+			_, found = roles[path.Join(meta.Namespace, roleRef.Name)]
+		default:
+			usedClusterRoles[roleRef.Name] = struct{}{}
+			_, found = clusterRoles[roleRef.Name]
+		}
+		// The defaults still make the roles they refer to used
+		if !includeSystem && isSystemRBAC(meta) {
+			return
+		}
+		if !found {
+			findings = append(findings, rbacFinding{
+				Kind:   rbacFindingMissingRole,
+				Object: object,
+				Detail: fmt.Sprintf("%s %s is missing", roleRef.Kind, roleRef.Name),
+			})
+		}
+		for _, subject := range subjects {
+			if subject.Kind != rbacv1.ServiceAccountKind {
+				continue
+			}
+			ns := subject.Namespace
+			if ns == "" {
+				ns = meta.Namespace
+			}
+			if _, ok := inv.namespaces[ns]; !ok {
+				findings = append(findings, rbacFinding{
+					Kind:   rbacFindingMissingSubject,
+					Object: object,
+					Detail: fmt.Sprintf("ServiceAccount %s/%s is in the missing namespace %s", ns, subject.Name, ns),
+				})
+				continue
+			}
+			if _, ok := inv.serviceAccounts[path.Join(ns, subject.Name)]; !ok {
+				findings = append(findings, rbacFinding{
+					Kind:   rbacFindingMissingSubject,
+					Object: object,
+					Detail: fmt.Sprintf("ServiceAccount %s/%s is missing", ns, subject.Name),
+				})
+			}
+		}
+	}
+	for _, binding := range inv.roleBindings {
+		checkBinding(binding.ObjectMeta, path.Join(roleBindingGroupResource.String(), binding.Namespace, binding.Name), binding.Subjects, binding.RoleRef)
+	}
+	for _, binding := range inv.clusterRoleBindings {
+		checkBinding(binding.ObjectMeta, path.Join(clusterRoleBindingGroupResource.String(), binding.Name), binding.Subjects, binding.RoleRef)
+	}
+
+	// A ClusterRole is used through the aggregated ones selecting it
+	var aggregations []labels.Selector
+	for _, role := range inv.clusterRoles {
+		if role.AggregationRule == nil {
+			continue
+		}
+		for _, s := range role.AggregationRule.ClusterRoleSelectors {
+			selector, err := metav1.LabelSelectorAsSelector(&s)
+			if err == nil && !selector.Empty() {
+				aggregations = append(aggregations, selector)
+			}
+		}
+	}
+
+	for _, role := range inv.roles {
+		if !includeSystem && isSystemRBAC(role.ObjectMeta) {
+			continue
+		}
+		object := path.Join(roleGroupResource.String(), role.Namespace, role.Name)
+		for _, rule := range wildcardRules(role.Rules) {
+			findings = append(findings, rbacFinding{Kind: rbacFindingWildcard, Object: object, Detail: rule})
+		}
+		if _, ok := usedRoles[path.Join(role.Namespace, role.Name)]; !ok {
+			findings = append(findings, rbacFinding{Kind: rbacFindingUnused, Object: object, Detail: "no binding refers to it"})
+		}
+	}
+	for _, role := range inv.clusterRoles {
+		if !includeSystem && isSystemRBAC(role.ObjectMeta) {
+			continue
+		}
+		object := path.Join(clusterRoleGroupResource.String(), role.Name)
+		// The rules of an aggregated ClusterRole are the ones of the ClusterRoles it selects, reported on them
+		if role.AggregationRule == nil {
+			for _, rule := range wildcardRules(role.Rules) {
+				findings = append(findings, rbacFinding{Kind: rbacFindingWildcard, Object: object, Detail: rule})
+			}
+		}
+		if _, ok := usedClusterRoles[role.Name]; ok {
+			continue
+		}
+		aggregated := false
+		for _, selector := range aggregations {
+			if selector.Matches(labels.Set(role.Labels)) {
+				aggregated = true
+				break
+			}
+		}
+		if !aggregated {
+			findings = append(findings, rbacFinding{Kind: rbacFindingUnused, Object: object, Detail: "no binding refers to it, nor aggregates it"})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Kind != findings[j].Kind {
+			return rbacFindingOrder[findings[i].Kind] < rbacFindingOrder[findings[j].Kind]
+		}
+		return findings[i].Object < findings[j].Object
+	})
+	return findings
+}
+
+func analyzeRBACCommand(ctx context.Context, etcdclient client.Client, flags *analyzeRBACFlagpole) error {
+	if flags.Output != "table" && flags.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+
+	consistencyOpts, err := consistencyOpOptions(flags.Consistency, false)
+	if err != nil {
+		return err
+	}
+
+	inv, err := readRBACInventory(ctx, etcdclient, flags.Prefix,
+		append(consistencyOpts,
+			client.WithPageLimit(flags.ChunkSize),
+		)...,
+	)
+	if err != nil {
+		return err
+	}
+	findings := analyzeRBAC(inv, flags.IncludeSystem)
+
+	switch flags.Output {
+	case "json":
+		if findings == nil {
+			findings = []rbacFinding{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(findings)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "KIND\tOBJECT\tDETAIL")
+		for _, finding := range findings {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", finding.Kind, finding.Object, finding.Detail)
+		}
+		err = w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, finding := range findings {
+		counts[finding.Kind]++
+	}
+	fmt.Fprintf(os.Stderr, "%d wildcard grants, %d bindings to missing subjects, %d bindings to missing roles, %d unused roles in %d roles and %d bindings\n",
+		counts[rbacFindingWildcard], counts[rbacFindingMissingSubject], counts[rbacFindingMissingRole], counts[rbacFindingUnused],
+		len(inv.roles)+len(inv.clusterRoles), len(inv.roleBindings)+len(inv.clusterRoleBindings))
+	return nil
+}
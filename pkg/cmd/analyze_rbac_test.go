@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestAnalyzeRBAC(t *testing.T) {
+	etcdclient := fake.NewClient()
+	for _, obj := range []struct {
+		gr        schema.GroupResource
+		namespace string
+		name      string
+		value     string
+	}{
+		{namespaceGroupResource, "", "team", `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"team"}}`},
+		{serviceAccountGroupResource, "team", "deployer", `{"apiVersion":"v1","kind":"ServiceAccount","metadata":{"name":"deployer","namespace":"team"}}`},
+		{roleGroupResource, "team", "deploy", `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"Role","metadata":{"name":"deploy","namespace":"team"},
+			"rules":[{"apiGroups":["apps"],"resources":["deployments"],"verbs":["*"]}]}`},
+		{roleGroupResource, "team", "stale", `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"Role","metadata":{"name":"stale","namespace":"team"},
+			"rules":[{"apiGroups":[""],"resources":["pods"],"verbs":["get"]}]}`},
+		{roleBindingGroupResource, "team", "deploy", `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"RoleBinding","metadata":{"name":"deploy","namespace":"team"},
+			"roleRef":{"apiGroup":"rbac.authorization.k8s.io","kind":"Role","name":"deploy"},
+			"subjects":[{"kind":"ServiceAccount","name":"deployer"},{"kind":"ServiceAccount","name":"gone"},{"kind":"User","name":"alice"}]}`},
+		{clusterRoleGroupResource, "", "view", `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRole","metadata":{"name":"view"},
+			"aggregationRule":{"clusterRoleSelectors":[{"matchLabels":{"example.com/aggregate-to-view":"true"}}]},"rules":[{"apiGroups":["*"],"resources":["*"],"verbs":["get"]}]}`},
+		{clusterRoleGroupResource, "", "view-widgets", `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRole","metadata":{"name":"view-widgets","labels":{"example.com/aggregate-to-view":"true"}},
+			"rules":[{"apiGroups":["example.com"],"resources":["widgets"],"verbs":["get"]}]}`},
+		{clusterRoleGroupResource, "", "system:node", `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRole","metadata":{"name":"system:node"},
+			"rules":[{"nonResourceURLs":["*"],"verbs":["get"]}]}`},
+		{clusterRoleBindingGroupResource, "", "viewers", `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRoleBinding","metadata":{"name":"viewers"},
+			"roleRef":{"apiGroup":"rbac.authorization.k8s.io","kind":"ClusterRole","name":"view"},
+			"subjects":[{"kind":"ServiceAccount","name":"ci","namespace":"build"}]}`},
+		{clusterRoleBindingGroupResource, "", "admins", `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRoleBinding","metadata":{"name":"admins"},
+			"roleRef":{"apiGroup":"rbac.authorization.k8s.io","kind":"ClusterRole","name":"removed-admin"},
+			"subjects":[{"kind":"Group","name":"admins"}]}`},
+	} {
+		err := etcdclient.Put(context.Background(), "/registry", []byte(obj.value),
+			client.WithGR(obj.gr),
+			client.WithName(obj.name, obj.namespace),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inv, err := readRBACInventory(context.Background(), etcdclient, "/registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		includeSystem bool
+		want          []rbacFinding
+	}{
+		{
+			name: "default",
+			want: []rbacFinding{
+				{Kind: rbacFindingWildcard, Object: "roles.rbac.authorization.k8s.io/team/deploy", Detail: "grants verbs [*] on apiGroups [apps] resources [deployments]"},
+				{Kind: rbacFindingMissingSubject, Object: "clusterrolebindings.rbac.authorization.k8s.io/viewers", Detail: "ServiceAccount build/ci is in the missing namespace build"},
+				{Kind: rbacFindingMissingSubject, Object: "rolebindings.rbac.authorization.k8s.io/team/deploy", Detail: "ServiceAccount team/gone is missing"},
+				{Kind: rbacFindingMissingRole, Object: "clusterrolebindings.rbac.authorization.k8s.io/admins", Detail: "ClusterRole removed-admin is missing"},
+				{Kind: rbacFindingUnused, Object: "roles.rbac.authorization.k8s.io/team/stale", Detail: "no binding refers to it"},
+			},
+		},
+		{
+			name:          "include system",
+			includeSystem: true,
+			want: []rbacFinding{
+				{Kind: rbacFindingWildcard, Object: "clusterroles.rbac.authorization.k8s.io/system:node", Detail: "grants verbs [get] on nonResourceURLs [*]"},
+				{Kind: rbacFindingWildcard, Object: "roles.rbac.authorization.k8s.io/team/deploy", Detail: "grants verbs [*] on apiGroups [apps] resources [deployments]"},
+				{Kind: rbacFindingMissingSubject, Object: "clusterrolebindings.rbac.authorization.k8s.io/viewers", Detail: "ServiceAccount build/ci is in the missing namespace build"},
+				{Kind: rbacFindingMissingSubject, Object: "rolebindings.rbac.authorization.k8s.io/team/deploy", Detail: "ServiceAccount team/gone is missing"},
+				{Kind: rbacFindingMissingRole, Object: "clusterrolebindings.rbac.authorization.k8s.io/admins", Detail: "ClusterRole removed-admin is missing"},
+				{Kind: rbacFindingUnused, Object: "clusterroles.rbac.authorization.k8s.io/system:node", Detail: "no binding refers to it, nor aggregates it"},
+				{Kind: rbacFindingUnused, Object: "roles.rbac.authorization.k8s.io/team/stale", Detail: "no binding refers to it"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := analyzeRBAC(inv, tt.includeSystem)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("analyzeRBAC() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
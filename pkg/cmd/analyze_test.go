@@ -0,0 +1,65 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/wzshiming/kectl/pkg/scheme"
+)
+
+func TestStorageVersionOf(t *testing.T) {
+	pb, _, err := encoding.Convert(scheme.Codecs, encoding.JsonMediaType, encoding.StorageBinaryMediaType, []byte(podJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value []byte
+		want  string
+	}{
+		{
+			name:  "protobuf",
+			value: pb,
+			want:  "v1",
+		},
+		{
+			name:  "json",
+			value: []byte(`{"apiVersion":"apiextensions.k8s.io/v1beta1","kind":"CustomResourceDefinition"}`),
+			want:  "apiextensions.k8s.io/v1beta1",
+		},
+		{
+			name:  "encrypted",
+			value: []byte("k8s:enc:aescbc:v1:key1:data"),
+			want:  "encrypted",
+		},
+		{
+			name:  "malformed",
+			value: []byte("k8s\x00"),
+			want:  "unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := storageVersionOf(tt.value); got != tt.want {
+				t.Errorf("storageVersionOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+// tokenCredentials sends an auth token issued beforehand, such as a JWT signed with the key of etcd,
+// with every request in the same metadata the etcd client uses for the tokens it gets by username and password.
+// Unlike those, it can not be refreshed once expired.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{
+		rpctypes.TokenFieldNameGRPC: string(t),
+	}, nil
+}
+
+// RequireTransportSecurity returns false, as the transport security of etcd is optional.
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
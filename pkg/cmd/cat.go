@@ -0,0 +1,270 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+type catFlagpole struct {
+	Output string
+	Prefix string
+}
+
+func newCtlCatCommand() *cobra.Command {
+	flags := &catFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "cat <path>",
+		Short: "Prints a recording as YAML, JSON Lines or a table of its events",
+		Long: "Prints a recording, - for stdin, decrypted, decompressed and decoded whatever its format, as YAML, JSON Lines or a table of its events.\n" +
+			"The documents in another format than the output are converted, the ones of kectl as if they were recorded in the output format from values of JSON.\n" +
+			"The table has the time of each event since the start of the recording, its method, resource and target,\n" +
+			"the time of a recording of kectl being the one of the bookmark before it, and the one of a kwok recording the duration of its change.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := catCommand(flags, args[0], os.Stdout)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "yaml", "output format. One of: (yaml, jsonl, table).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+
+	return cmd
+}
+
+func catCommand(flags *catFlagpole, input string, w io.Writer) (err error) {
+	var r io.ReadCloser
+	if input == "-" {
+		r, err = decompress(os.Stdin)
+	} else {
+		r, err = openRecording(input)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	scanner := newRecordingScanner(r, flags.Prefix)
+	switch flags.Output {
+	case "yaml", "jsonl":
+		c, err := newRecordingCat(w, flags.Output, scanner.JSONL)
+		if err != nil {
+			return err
+		}
+		return scanner.Scan(c.cat)
+	case "table":
+		t := newRecordingEventTable(w, flags.Prefix)
+		err = scanner.Scan(t.add)
+		if err != nil {
+			return err
+		}
+		return t.Flush()
+	default:
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+}
+
+// recordingCat writes the documents of a recording in the output format, the ones in the other format converted.
+type recordingCat struct {
+	w       io.Writer
+	output  string
+	convert bool
+	// printers print the documents of the keys with and without a revision,
+	// which are converted as if their objects were the values of etcd
+	printer             func(kv *client.KeyValue) error
+	printerWithRevision func(kv *client.KeyValue) error
+}
+
+func newRecordingCat(w io.Writer, output string, jsonl bool) (*recordingCat, error) {
+	c := &recordingCat{
+		w:       w,
+		output:  output,
+		convert: jsonl != (output == "jsonl"),
+	}
+	var err error
+	c.printer, err = newPrinter(w, printerOptions{
+		Output:     output,
+		DecodeMode: decodeModeLenient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.printerWithRevision, err = newPrinter(w, printerOptions{
+		Output:       output,
+		WithRevision: true,
+		DecodeMode:   decodeModeLenient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *recordingCat) cat(doc *recordingRawDocument) error {
+	if !c.convert {
+		_, err := c.w.Write(doc.Data)
+		return err
+	}
+	switch {
+	case doc.ClockSkew != nil:
+		return printClockSkew(c.w, c.output, *doc.ClockSkew)
+	case doc.Bookmark != nil:
+		return printBookmark(c.w, c.output, doc.Revision, *doc.Bookmark)
+	case doc.Key != "":
+		kv, err := c.keyValue(doc)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", doc.Key, err)
+		}
+		if kv.Revision != 0 {
+			return c.printerWithRevision(kv)
+		}
+		return c.printer(kv)
+	case doc.Object != nil:
+		data, err := doc.Object.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if c.output == "jsonl" {
+			_, err = fmt.Fprintf(c.w, "%s\n", data)
+			return err
+		}
+		data, err = yaml.JSONToYAML(data)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(c.w, "---\n%s", data)
+		return err
+	}
+	// The documents of nothing but comments have nothing to convert
+	return nil
+}
+
+// keyValue returns the document of a key as the key-value it is printed from, the value of a deletion as its previous one.
+func (c *recordingCat) keyValue(doc *recordingRawDocument) (*client.KeyValue, error) {
+	kv := &client.KeyValue{
+		Key:      []byte(doc.Key),
+		Revision: doc.Revision,
+	}
+	var value []byte
+	if c.output == "yaml" {
+		var line jsonLine
+		err := json.Unmarshal(doc.Data, &line)
+		if err != nil {
+			return nil, err
+		}
+		value = line.Object
+		if len(value) == 0 {
+			value = line.Raw
+		}
+	} else {
+		header, body, _ := strings.Cut(strings.TrimPrefix(string(doc.Data), "---\n"), "\n")
+		fields := strings.Split(header, " | ")
+		if len(fields) > 2 && fields[1] == "raw" {
+			// The values that could not be decoded are kept as they are, in a comment
+			value = []byte(strings.TrimSuffix(strings.TrimPrefix(body, "# "), "\n"))
+		} else if strings.TrimSpace(body) != "" {
+			data, err := yaml.YAMLToJSON([]byte(body))
+			if err != nil {
+				return nil, err
+			}
+			value = data
+		}
+	}
+	if doc.Deleted {
+		kv.PrevValue = value
+	} else {
+		kv.Value = value
+	}
+	return kv, nil
+}
+
+// recordingEventTable writes a table of the events of a recording, with the time since its start.
+type recordingEventTable struct {
+	*tabwriter.Writer
+	prefix string
+	recordingClock
+}
+
+func newRecordingEventTable(w io.Writer, prefix string) *recordingEventTable {
+	t := &recordingEventTable{
+		Writer: tabwriter.NewWriter(w, 0, 0, 3, ' ', 0),
+		prefix: prefix,
+	}
+	fmt.Fprintln(t, "TIME\tREVISION\tMETHOD\tRESOURCE\tTARGET")
+	return t
+}
+
+func (t *recordingEventTable) add(doc *recordingRawDocument) error {
+	switch {
+	case doc.Header, doc.Bookmark != nil:
+		t.tick(doc)
+	case doc.Key != "":
+		gr, rest, ok := groupResourceFromKey(t.prefix, doc.Key)
+		if !ok {
+			return fmt.Errorf("key %q is not under %q", doc.Key, t.prefix)
+		}
+		namespace, name, err := splitName(rest)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", doc.Key, err)
+		}
+		method := t.method(doc)
+		revision := "-"
+		if doc.Revision != 0 {
+			revision = strconv.FormatInt(doc.Revision, 10)
+		}
+		fmt.Fprintf(t, "+%s\t%s\t%s\t%s\t%s\n", t.elapsed(), revision, method, gr, eventTarget(namespace, name))
+	case doc.Object != nil:
+		if !isKwokResourcePatch(doc.Object) {
+			ref, _, err := snapshotObjectRefOf(doc.Object)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(t, "+0s\t-\tobject\t%s\t%s\n", ref.gr, eventTarget(ref.namespace, ref.name))
+			return nil
+		}
+		rp, err := decodeKwokResourcePatch(doc.Object)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(t, "+%s\t-\t%s\t%s\t%s\n", rp.DurationNanosecond, kwokPatchMethod(rp), rp.Resource.GroupResource(), eventTarget(rp.Target.Namespace, rp.Target.Name))
+	}
+	return nil
+}
+
+// eventTarget returns the namespace and the name of the target of an event, the name alone for a cluster-scoped one.
+func eventTarget(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCatCommand(t *testing.T) {
+	const recording = `---
+# clock-skew | 10ms | 500ms | 2024-01-01T00:00:00Z
+---
+# /registry/pods/default/a | application/json | 2
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+---
+# bookmark | 3 | 2024-01-01T00:05:00Z
+---
+# /registry/pods/default/b | application/json | 4
+apiVersion: v1
+kind: Pod
+metadata:
+  name: b
+  namespace: default
+---
+# /registry/pods/default/a | 5 | deleted
+`
+	const jsonlRecording = `{"clockSkew":{"skew":10000000,"uncertainty":500000000,"time":"2024-01-01T00:00:00Z"}}
+{"key":"/registry/pods/default/a","revision":2,"mediaType":"application/json","object":{"apiVersion":"v1","kind":"Pod","metadata":{"name":"a","namespace":"default"}}}
+{"revision":3,"bookmark":"2024-01-01T00:05:00Z"}
+{"key":"/registry/pods/default/b","revision":4,"mediaType":"application/json","object":{"apiVersion":"v1","kind":"Pod","metadata":{"name":"b","namespace":"default"}}}
+{"key":"/registry/pods/default/a","revision":5,"deleted":true}
+`
+	// The objects converted are followed by an empty line, as the ones of get
+	const convertedRecording = `---
+# clock-skew | 10ms | 500ms | 2024-01-01T00:00:00Z
+---
+# /registry/pods/default/a | application/json | 2
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+
+---
+# bookmark | 3 | 2024-01-01T00:05:00Z
+---
+# /registry/pods/default/b | application/json | 4
+apiVersion: v1
+kind: Pod
+metadata:
+  name: b
+  namespace: default
+
+---
+# /registry/pods/default/a | 5 | deleted
+`
+	const kwokRecording = `---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: a
+  namespace: default
+durationNanosecond: 1000000000
+method: delete
+`
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		want   string
+	}{
+		{
+			name:   "yaml as it is",
+			input:  recording,
+			output: "yaml",
+			want:   recording,
+		},
+		{
+			name:   "yaml to jsonl",
+			input:  recording,
+			output: "jsonl",
+			want:   jsonlRecording,
+		},
+		{
+			name:   "jsonl to yaml",
+			input:  jsonlRecording,
+			output: "yaml",
+			want:   convertedRecording,
+		},
+		{
+			name:   "table",
+			input:  recording,
+			output: "table",
+			want: `TIME    REVISION   METHOD   RESOURCE   TARGET
++0s     2          object   pods       default/a
++5m0s   4          create   pods       default/b
++5m0s   5          delete   pods       default/a
+`,
+		},
+		{
+			name:   "kwok table",
+			input:  kwokRecording,
+			output: "table",
+			want: `TIME   REVISION   METHOD   RESOURCE   TARGET
++0s    -          object   pods       default/a
++1s    -          delete   pods       default/a
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := filepath.Join(t.TempDir(), "recording")
+			err := os.WriteFile(input, []byte(tt.input), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			err = catCommand(&catFlagpole{Output: tt.output, Prefix: "/registry"}, input, &buf)
+			if err != nil {
+				t.Fatalf("catCommand() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("catCommand() = \n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpointChunkSize is the number of documents between the saves of the checkpoint.
+const checkpointChunkSize = 1000
+
+// watchCheckpointInterval is the least time between the saves of the checkpoint of a watch.
+const watchCheckpointInterval = time.Second
+
+// checkpoint is the progress of a transfer, saved to the checkpoint file for an interrupted transfer to resume from it.
+type checkpoint struct {
+	// Target is what is transferred, a checkpoint is only resumed by the same transfer
+	Target string `json:"target"`
+	// Revision is the revision the objects are listed at, or the one a watch has written all the events up to
+	Revision int64 `json:"revision,omitempty"`
+	// LastKey is the key of the last document transferred
+	LastKey string `json:"lastKey,omitempty"`
+	// Count is the number of documents transferred
+	Count  int               `json:"count"`
+	Chunks []checkpointChunk `json:"chunks"`
+}
+
+// checkpointChunk is a run of transferred documents, with the digest to verify them when resuming.
+type checkpointChunk struct {
+	Count int `json:"count"`
+	// Size is the bytes the documents take in the file written to
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256"`
+}
+
+// checkpointer records the documents as they are transferred, and saves the checkpoint at the end of each chunk.
+type checkpointer struct {
+	path  string
+	state checkpoint
+
+	// sync is called before the checkpoint is saved, to flush the documents written
+	sync func() error
+
+	chunk    checkpointChunk
+	hash     hash.Hash
+	key      string
+	lastSave time.Time
+
+	// transferred is the number of documents transferred before the interruption,
+	// verified is the number of the chunks of them verified against the documents read again
+	transferred   int
+	verified      int
+	verifiedCount int
+	verifying     int
+	verifyHash    hash.Hash
+}
+
+// loadCheckpoint loads the checkpoint of the target from the file, or starts a new one if the file does not exist.
+func loadCheckpoint(path string, target string) (*checkpointer, error) {
+	c := &checkpointer{
+		path: path,
+		state: checkpoint{
+			Target: target,
+		},
+		hash:       sha256.New(),
+		verifyHash: sha256.New(),
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(data, &c.state)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	if c.state.Target != target {
+		return nil, fmt.Errorf("checkpoint %s is of %q rather than %q, remove it to start over", path, c.state.Target, target)
+	}
+	c.transferred = c.state.Count
+	return c, nil
+}
+
+// resumed returns whether documents have been transferred before.
+func (c *checkpointer) resumed() bool {
+	return c.transferred != 0
+}
+
+// Write adds the bytes of the document being transferred to the chunk.
+func (c *checkpointer) Write(p []byte) (int, error) {
+	c.hash.Write(p)
+	c.chunk.Size += int64(len(p))
+	return len(p), nil
+}
+
+// add counts the document written since the last one.
+func (c *checkpointer) add(key string) {
+	c.chunk.Count++
+	c.key = key
+}
+
+// commit counts the document written since the last one, and saves the checkpoint at the end of the chunk.
+func (c *checkpointer) commit(key string) error {
+	c.add(key)
+	if c.chunk.Count < checkpointChunkSize {
+		return nil
+	}
+	return c.flush()
+}
+
+// progress records that a watch has written all the events up to the revision, such as before the first event
+// of a later revision or at a bookmark, and saves the checkpoint at most once per interval,
+// so that the watch resumed from it starts at a whole revision.
+func (c *checkpointer) progress(revision int64) error {
+	if revision <= c.state.Revision || time.Since(c.lastSave) < watchCheckpointInterval {
+		return nil
+	}
+	c.state.Revision = revision
+	if c.chunk.Count == 0 {
+		return c.save()
+	}
+	return c.flush()
+}
+
+// flush ends the chunk and saves the checkpoint, the documents of a partial chunk are kept as a shorter one.
+func (c *checkpointer) flush() error {
+	if c.chunk.Count == 0 {
+		return nil
+	}
+	if c.sync != nil {
+		err := c.sync()
+		if err != nil {
+			return err
+		}
+	}
+	c.chunk.SHA256 = hex.EncodeToString(c.hash.Sum(nil))
+	c.state.Chunks = append(c.state.Chunks, c.chunk)
+	c.state.Count += c.chunk.Count
+	c.state.LastKey = c.key
+	c.chunk = checkpointChunk{}
+	c.hash.Reset()
+	return c.save()
+}
+
+// save writes the checkpoint to a temporary file renamed over the checkpoint file,
+// so that an interruption never leaves it half written.
+func (c *checkpointer) save() error {
+	data, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	err = os.Rename(tmp.Name(), c.path)
+	if err != nil {
+		return err
+	}
+	c.lastSave = time.Now()
+	return nil
+}
+
+// done removes the checkpoint file once the transfer completes.
+func (c *checkpointer) done() error {
+	err := os.Remove(c.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// verifyFile checks the chunks against the file written before the interruption,
+// and returns the size they take, the rest of the file is not covered by the checkpoint.
+func (c *checkpointer) verifyFile(r io.Reader) (int64, error) {
+	var size int64
+	h := sha256.New()
+	for i, chunk := range c.state.Chunks {
+		h.Reset()
+		n, err := io.CopyN(h, r, chunk.Size)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, fmt.Errorf("chunk %d is truncated to %d of %d bytes", i, n, chunk.Size)
+			}
+			return 0, err
+		}
+		if hex.EncodeToString(h.Sum(nil)) != chunk.SHA256 {
+			return 0, fmt.Errorf("chunk %d does not match the checkpoint", i)
+		}
+		size += chunk.Size
+	}
+	return size, nil
+}
+
+// verify checks the document against the chunks of the documents transferred before the interruption,
+// and returns whether it is one of them, which is not transferred again.
+func (c *checkpointer) verify(data []byte) (bool, error) {
+	if c.verifiedCount >= c.transferred {
+		return false, nil
+	}
+	chunk := c.state.Chunks[c.verified]
+	c.verifyHash.Write(data)
+	c.verifying++
+	if c.verifying < chunk.Count {
+		return true, nil
+	}
+	if hex.EncodeToString(c.verifyHash.Sum(nil)) != chunk.SHA256 {
+		return false, fmt.Errorf("chunk %d does not match the checkpoint, the input has changed", c.verified)
+	}
+	c.verified++
+	c.verifiedCount += chunk.Count
+	c.verifying = 0
+	c.verifyHash.Reset()
+	return true, nil
+}
+
+// verifiedAll returns an error if fewer documents than transferred before have been verified.
+func (c *checkpointer) verifiedAll() error {
+	if c.verifiedCount < c.transferred {
+		return fmt.Errorf("the input ends after %d of the %d documents of the checkpoint", c.verifiedCount+c.verifying, c.transferred)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func configMapDocuments(n int) []string {
+	docs := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		docs = append(docs, fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm-%04d\n  namespace: default\n  creationTimestamp: \"2024-01-01T00:00:00Z\"\ndata:\n  i: %q\n", i, fmt.Sprint(i)))
+	}
+	return docs
+}
+
+func TestPutCommandCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.yaml")
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+	docs := configMapDocuments(2500)
+
+	// The document that cannot be decoded interrupts the put
+	broken := append([]string{}, docs...)
+	broken[1500] = "metadata: [\n"
+	err := os.WriteFile(input, []byte(strings.Join(broken, "---\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	etcdclient := fake.NewClient()
+	start := etcdclient.Revision()
+	flags := &putFlagpole{
+		Output:         "none",
+		Path:           input,
+		Prefix:         "/registry",
+		DecodeMode:     "lenient",
+		CheckpointFile: checkpointFile,
+	}
+	err = putCommand(context.Background(), etcdclient, flags, nil)
+	if err == nil {
+		t.Fatal("putCommand() error = nil, want the decode error")
+	}
+	if got := etcdclient.Revision() - start; got != 1500 {
+		t.Fatalf("revision = %d, want 1500 puts before the interruption", got)
+	}
+
+	// A changed input is refused rather than resumed
+	changed := append([]string{}, docs...)
+	changed[0] = strings.Replace(changed[0], `i: "0"`, `i: "changed"`, 1)
+	err = os.WriteFile(input, []byte(strings.Join(changed, "---\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = putCommand(context.Background(), etcdclient, flags, nil)
+	if err == nil || !strings.Contains(err.Error(), "does not match the checkpoint") {
+		t.Fatalf("putCommand() error = %v, want the changed input refused", err)
+	}
+
+	err = os.WriteFile(input, []byte(strings.Join(docs, "---\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = putCommand(context.Background(), etcdclient, flags, nil)
+	if err != nil {
+		t.Fatalf("putCommand() error = %v", err)
+	}
+	if got := etcdclient.Revision() - start; got != 2500 {
+		t.Errorf("revision = %d, want the documents put before not put again", got)
+	}
+	if _, err := os.Stat(checkpointFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("checkpoint file is kept after the put completes: %v", err)
+	}
+}
+
+func TestGetCommandCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.yaml")
+	err := os.WriteFile(input, []byte(strings.Join(configMapDocuments(2500), "---\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etcdclient := fake.NewClient()
+	err = putCommand(context.Background(), etcdclient, &putFlagpole{
+		Output:     "none",
+		Path:       input,
+		Prefix:     "/registry",
+		DecodeMode: "lenient",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := filepath.Join(dir, "want.yaml")
+	err = getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:       "yaml",
+		Prefix:       "/registry",
+		DecodeMode:   "lenient",
+		MaxBandwidth: "0",
+		Path:         want,
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := filepath.Join(dir, "got.yaml")
+	flags := &getFlagpole{
+		Output:         "yaml",
+		Prefix:         "/registry",
+		DecodeMode:     "lenient",
+		MaxBandwidth:   "0",
+		Path:           got,
+		CheckpointFile: filepath.Join(dir, "checkpoint.json"),
+	}
+
+	// Interrupt the first get after 1500 documents
+	file, err := os.OpenFile(got, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr := schema.GroupResource{Resource: "configmaps"}
+	cp, err := openExportCheckpoint(file, flags, gr, "", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	printer, err := newPrinter(io.MultiWriter(file, cp), printerOptions{Output: "yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errInterrupted := errors.New("interrupted")
+	var count int
+	err = checkpointedGet(context.Background(), etcdclient, "/registry", cp,
+		client.WithGR(gr),
+		client.WithName("", "default"),
+		client.WithPageLimit(100),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			if count == 1500 {
+				return errInterrupted
+			}
+			count++
+			err := printer(kv)
+			if err != nil {
+				return err
+			}
+			return cp.commit(string(kv.Key))
+		}),
+	)
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("checkpointedGet() error = %v, want interrupted", err)
+	}
+	// A document half written after the checkpoint is dropped when resuming
+	_, err = file.WriteString("---\n# /registry/configmaps/default/cm-1500 | ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	// The resumed get continues the same revision
+	err = etcdclient.Put(context.Background(), "/registry", []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm-1500a","namespace":"default"}}`),
+		client.WithGR(gr),
+		client.WithName("cm-1500a", "default"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = getCommand(context.Background(), etcdclient, flags, []string{"configmaps"})
+	if err != nil {
+		t.Fatalf("getCommand() error = %v", err)
+	}
+
+	wantData, err := os.ReadFile(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotData, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotData) != string(wantData) {
+		t.Errorf("resumed get wrote %d bytes, want the %d bytes of an uninterrupted get", len(gotData), len(wantData))
+	}
+	if _, err := os.Stat(flags.CheckpointFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("checkpoint file is kept after the get completes: %v", err)
+	}
+}
+
+func TestGetCommandWatchCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	etcdclient := fake.NewClient()
+	gr := schema.GroupResource{Resource: "configmaps"}
+	put := func(name string) {
+		err := etcdclient.Put(context.Background(), "/registry", []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"`+name+`","namespace":"default"}}`),
+			client.WithGR(gr),
+			client.WithName(name, "default"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("a")
+	put("b")
+
+	flags := &getFlagpole{
+		Output:         "yaml",
+		Prefix:         "/registry",
+		DecodeMode:     "lenient",
+		MaxBandwidth:   "0",
+		Watch:          true,
+		Path:           filepath.Join(dir, "trace.yaml"),
+		CheckpointFile: filepath.Join(dir, "trace.state"),
+	}
+	// watchUntil watches until the object is written, then interrupts the watch
+	watchUntil := func(name string, change func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- getCommand(ctx, etcdclient, flags, []string{"configmaps"})
+		}()
+		change()
+		for i := 0; ; i++ {
+			data, _ := os.ReadFile(flags.Path)
+			if strings.Contains(string(data), "/registry/configmaps/default/"+name+" ") {
+				break
+			}
+			if i == 100 {
+				t.Fatalf("%s is not written:\n%s", name, data)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		err := <-done
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	watchUntil("c", func() { put("c") })
+	if _, err := os.Stat(flags.CheckpointFile); err != nil {
+		t.Fatalf("checkpoint is not kept: %v", err)
+	}
+
+	// The changes while interrupted are watched from the checkpoint, without listing again
+	put("d")
+	watchUntil("d", func() {})
+
+	data, err := os.ReadFile(flags.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, _, ok := strings.Cut(line, " | "); ok {
+			keys = append(keys, strings.TrimPrefix(key, "# /registry/configmaps/default/"))
+		}
+	}
+	if got, want := strings.Join(keys, ","), "a,b,c,d"; got != want {
+		t.Errorf("trace = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+// clockSkewPrefix starts the line a recording declares the clock skew of the recorder with.
+const clockSkewPrefix = "# clock-skew | "
+
+// clockSkewTimeout bounds the request for the time of the server, the recording does not wait on it.
+const clockSkewTimeout = 5 * time.Second
+
+// clockSkew is how far the clock of the recorder is ahead of the one of the etcd server, at the time of the recorder.
+type clockSkew struct {
+	Skew time.Duration `json:"skew"`
+	// Uncertainty is how far off the skew may be, the time of the server is of the precision of a second
+	Uncertainty time.Duration `json:"uncertainty"`
+	Time        time.Time     `json:"time"`
+}
+
+// exceeds returns whether the skew is surely beyond the max, 0 for no max.
+func (s clockSkew) exceeds(max time.Duration) bool {
+	if max <= 0 {
+		return false
+	}
+	skew := s.Skew
+	if skew < 0 {
+		skew = -skew
+	}
+	return skew-s.Uncertainty > max
+}
+
+func (s clockSkew) String() string {
+	return fmt.Sprintf("%s ± %s", s.Skew, s.Uncertainty)
+}
+
+// measureClockSkew measures the clock skew of the recorder against the etcd server.
+// The Date header of the server is truncated to the second, so it is taken at the middle of its second.
+func measureClockSkew(ctx context.Context, etcdclient client.Client) (clockSkew, error) {
+	ctx, cancel := context.WithTimeout(ctx, clockSkewTimeout)
+	defer cancel()
+	before := time.Now()
+	server, err := client.ServerTime(ctx, etcdclient)
+	if err != nil {
+		return clockSkew{}, err
+	}
+	after := time.Now()
+	rtt := after.Sub(before)
+	local := before.Add(rtt / 2)
+	server = server.Add(time.Second / 2)
+	return clockSkew{
+		Skew:        local.Sub(server).Round(time.Millisecond),
+		Uncertainty: (rtt/2 + time.Second/2).Round(time.Millisecond),
+		Time:        local.UTC().Truncate(time.Second),
+	}, nil
+}
+
+// recordClockSkew measures the clock skew of the recorder, and writes it at the start of the recording in the output format.
+// The recording goes on without it if the server does not tell its time, and with a warning if the skew is beyond the max.
+func recordClockSkew(ctx context.Context, etcdclient client.Client, w io.Writer, output string, binary bool, max time.Duration) error {
+	skew, err := measureClockSkew(ctx, etcdclient)
+	if err != nil {
+		if !errors.Is(err, client.ErrServerTimeUnsupported) {
+			fmt.Fprintf(os.Stderr, "warning: can not measure the clock skew against the server: %v\n", err)
+		}
+		return nil
+	}
+	if skew.exceeds(max) {
+		fmt.Fprintf(os.Stderr, "warning: the clock of the recorder is %s off the one of the server, beyond --max-clock-skew of %s\n", skew, max)
+	}
+	if binary {
+		return nil
+	}
+	return printClockSkew(w, output, skew)
+}
+
+func printClockSkew(w io.Writer, output string, skew clockSkew) error {
+	var err error
+	line := fmt.Sprintf("%s%s | %s | %s\n", clockSkewPrefix, skew.Skew, skew.Uncertainty, skew.Time.UTC().Format(time.RFC3339))
+	switch output {
+	case "json", "yaml":
+		_, err = fmt.Fprintf(w, "---\n%s", line)
+	case "jsonl":
+		err = writeJSONLine(w, &jsonLine{ClockSkew: &skew})
+	default:
+		_, err = io.WriteString(w, line)
+	}
+	return err
+}
+
+// peekClockSkew returns the clock skew the recording declares before its documents, without reading past it.
+func peekClockSkew(r *bufio.Reader) (clockSkew, bool) {
+	// The declaration is on the first lines of the recording
+	data, _ := r.Peek(256)
+	text := strings.TrimPrefix(string(data), "---\n")
+	line, _, ok := strings.Cut(text, "\n")
+	if !ok {
+		return clockSkew{}, false
+	}
+	if strings.HasPrefix(line, "{") {
+		var l jsonLine
+		if json.Unmarshal([]byte(line), &l) != nil || l.ClockSkew == nil {
+			return clockSkew{}, false
+		}
+		return *l.ClockSkew, true
+	}
+	return parseClockSkew(line)
+}
+
+func parseClockSkew(line string) (clockSkew, bool) {
+	rest, ok := strings.CutPrefix(line, clockSkewPrefix)
+	if !ok {
+		return clockSkew{}, false
+	}
+	parts := strings.Split(rest, " | ")
+	if len(parts) != 3 {
+		return clockSkew{}, false
+	}
+	skew, err := time.ParseDuration(parts[0])
+	if err != nil {
+		return clockSkew{}, false
+	}
+	uncertainty, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return clockSkew{}, false
+	}
+	t, err := time.Parse(time.RFC3339, parts[2])
+	if err != nil {
+		return clockSkew{}, false
+	}
+	return clockSkew{Skew: skew, Uncertainty: uncertainty, Time: t}, true
+}
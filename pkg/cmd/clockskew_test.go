@@ -0,0 +1,100 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestClockSkewRecording(t *testing.T) {
+	skew := clockSkew{
+		Skew:        -3200 * time.Millisecond,
+		Uncertainty: 510 * time.Millisecond,
+		Time:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	const object = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n"
+	const jsonObject = `{"key":"/registry/configmaps/default/a","object":{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a"}}}` + "\n"
+	tests := []struct {
+		output   string
+		document string
+	}{
+		{output: "yaml", document: "---\n# /registry/configmaps/default/a | application/yaml\n" + object},
+		{output: "jsonl", document: jsonObject},
+		{output: "key", document: "/registry/configmaps/default/a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := printClockSkew(&buf, tt.output, skew)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf.WriteString(tt.document)
+
+			got, ok := peekClockSkew(bufio.NewReader(bytes.NewReader(buf.Bytes())))
+			if !ok {
+				t.Fatalf("no clock skew in %q", buf.String())
+			}
+			if got != skew {
+				t.Errorf("got %v at %v, want %v at %v", got, got.Time, skew, skew.Time)
+			}
+			if tt.output == "key" {
+				return
+			}
+
+			var names []string
+			err = decodeReader(&buf, nil, func(obj *unstructured.Unstructured) error {
+				names = append(names, obj.GetName())
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(names, ",") != "a" {
+				t.Errorf("decoded %v, want [a]", names)
+			}
+		})
+	}
+}
+
+func TestClockSkewExceeds(t *testing.T) {
+	tests := []struct {
+		name string
+		skew clockSkew
+		max  time.Duration
+		want bool
+	}{
+		{name: "within", skew: clockSkew{Skew: time.Second, Uncertainty: 500 * time.Millisecond}, max: 2 * time.Second},
+		{name: "beyond", skew: clockSkew{Skew: 3 * time.Second, Uncertainty: 500 * time.Millisecond}, max: 2 * time.Second, want: true},
+		{name: "behind", skew: clockSkew{Skew: -3 * time.Second, Uncertainty: 500 * time.Millisecond}, max: 2 * time.Second, want: true},
+		{name: "within the uncertainty", skew: clockSkew{Skew: 2400 * time.Millisecond, Uncertainty: 500 * time.Millisecond}, max: 2 * time.Second},
+		{name: "no max", skew: clockSkew{Skew: time.Hour}, max: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.skew.exceeds(tt.max); got != tt.want {
+				t.Errorf("exceeds(%v) = %v, want %v", tt.max, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The compressions of the recordings.
+const (
+	compressionNone = "none"
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// compressionFromPath returns the compression of the extension of the path, none without a known one.
+func compressionFromPath(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return compressionGzip
+	case strings.HasSuffix(path, ".zst"):
+		return compressionZstd
+	}
+	return compressionNone
+}
+
+// compressWriter compresses the writes to the writer under it, which only gets all of them once closed.
+type compressWriter interface {
+	io.WriteCloser
+	// Flush writes what has been written so far, for a reader of the output to decompress it.
+	Flush() error
+}
+
+// newCompressWriter returns the writer compressing to w at the level, 0 for the default level of the compression.
+// It is nil without compression.
+func newCompressWriter(w io.Writer, compression string, level int) (compressWriter, error) {
+	switch compression {
+	case compressionNone:
+		if level != 0 {
+			return nil, fmt.Errorf("--compression-level needs a compression")
+		}
+		return nil, nil
+	case compressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		} else if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return nil, fmt.Errorf("invalid gzip compression level %d, want 1-9", level)
+		}
+		return gzip.NewWriterLevel(w, level)
+	case compressionZstd:
+		opts := []zstd.EOption{}
+		if level != 0 {
+			if level < 1 || level > 22 {
+				return nil, fmt.Errorf("invalid zstd compression level %d, want 1-22", level)
+			}
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	}
+	return nil, fmt.Errorf("unsupported compression %q", compression)
+}
+
+// decompress returns the content of r, decrypted if it is encrypted with age, decompressed if it starts with the magic bytes of gzip or zstd
+// whatever the extension of its path, or the documents of the chunks of a recording of the v2 format,
+// or of the records of a recording of the binary format, compressed or not.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	if isRecordingEncrypted(br) {
+		d, err := decryptRecording(br)
+		if err != nil {
+			return nil, err
+		}
+		return decompress(d)
+	}
+	if isRecordingV2(br) {
+		_, err := br.Discard(len(recordingV2Magic))
+		if err != nil {
+			return nil, err
+		}
+		return newRecordingV2Reader(br)
+	}
+	// A shorter input is not compressed
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, zstdMagic):
+		d, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return decodeRecordingBinary(d.IOReadCloser())
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return decodeRecordingBinary(gr)
+	}
+	return decodeRecordingBinary(io.NopCloser(br))
+}
+
+// decodeRecordingBinary returns the reader of the documents of a binary recording, or the reader as it is for the other formats.
+func decodeRecordingBinary(r io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	if !isRecordingBinary(br) {
+		return struct {
+			io.Reader
+			io.Closer
+		}{br, r}, nil
+	}
+	_, err := br.Discard(len(recordingBinaryMagic))
+	if err != nil {
+		return nil, err
+	}
+	rr, err := newRecordingBinaryReader(br)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{rr, r}, nil
+}
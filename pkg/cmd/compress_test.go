@@ -0,0 +1,155 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client/fake"
+)
+
+func TestCompress(t *testing.T) {
+	data := []byte(strings.Repeat("apiVersion: v1\nkind: ConfigMap\n---\n", 100))
+	tests := []struct {
+		compression string
+		level       int
+		wantErr     bool
+	}{
+		{compression: compressionNone},
+		{compression: compressionGzip},
+		{compression: compressionGzip, level: 9},
+		{compression: compressionZstd},
+		{compression: compressionZstd, level: 19},
+		{compression: compressionNone, level: 3, wantErr: true},
+		{compression: compressionGzip, level: 10, wantErr: true},
+		{compression: compressionZstd, level: 23, wantErr: true},
+		{compression: "lz4", wantErr: true},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w, err := newCompressWriter(&buf, tt.compression, tt.level)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("newCompressWriter(%s, %d) did not fail", tt.compression, tt.level)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w == nil {
+			buf.Write(data)
+		} else {
+			_, err = w.Write(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = w.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() >= len(data) {
+				t.Errorf("%s compressed %d bytes to %d", tt.compression, len(data), buf.Len())
+			}
+		}
+
+		// Detected by the magic bytes
+		r, err := decompress(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = r.Close()
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s decompressed %q, want %q", tt.compression, got, data)
+		}
+	}
+
+	for path, want := range map[string]string{
+		"run.yaml":     compressionNone,
+		"run.yaml.gz":  compressionGzip,
+		"run.yaml.zst": compressionZstd,
+	} {
+		if got := compressionFromPath(path); got != want {
+			t.Errorf("compressionFromPath(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestGetPutCompressed(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.yaml")
+	err := os.WriteFile(input, []byte(strings.Join(configMapDocuments(100), "---\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etcdclient := fake.NewClient()
+	err = putCommand(context.Background(), etcdclient, &putFlagpole{
+		Output:     "none",
+		Path:       input,
+		Prefix:     "/registry",
+		DecodeMode: "lenient",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The extension does not tell the compression of the replay
+	path := filepath.Join(dir, "run.yaml")
+	err = getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:           "yaml",
+		Prefix:           "/registry",
+		DecodeMode:       "lenient",
+		MaxBandwidth:     "0",
+		Path:             path,
+		Compression:      compressionZstd,
+		CompressionLevel: 3,
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, zstdMagic) {
+		t.Fatalf("%s is not compressed with zstd", path)
+	}
+
+	replayed := fake.NewClient()
+	err = putCommand(context.Background(), replayed, &putFlagpole{
+		Output:     "none",
+		Path:       path,
+		Prefix:     "/registry",
+		DecodeMode: "lenient",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := replayed.Revision(), etcdclient.Revision(); got != want {
+		t.Errorf("replayed %d revisions, want %d", got, want)
+	}
+}
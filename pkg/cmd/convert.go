@@ -0,0 +1,237 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/wzshiming/kectl/pkg/scheme"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	serializerjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
+)
+
+// errMalformedValue is returned when a value stored in etcd can not be decoded.
+var errMalformedValue = errors.New("malformed value")
+
+// decodeMode is how the values that do not match the scheme are handled.
+type decodeMode string
+
+const (
+	// decodeModeLenient does its best with such values, they are printed raw and unknown fields are dropped.
+	decodeModeLenient decodeMode = "lenient"
+	// decodeModeStrict fails on such values, on unknown types as well as on unknown fields.
+	decodeModeStrict decodeMode = "strict"
+)
+
+// parseDecodeMode returns the decode mode of the name.
+func parseDecodeMode(s string) (decodeMode, error) {
+	switch mode := decodeMode(s); mode {
+	case decodeModeLenient, decodeModeStrict:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported decode mode: %q", s)
+	}
+}
+
+// convertValue detects the media type of the value and converts it to the out media type.
+// Truncated or corrupt values result in errMalformedValue instead of a panic.
+func convertValue(value []byte, outMediaType string) (inMediaType string, data []byte, err error) {
+	var buf bytes.Buffer
+	inMediaType, err = convertValueTo(&buf, value, outMediaType)
+	if err != nil {
+		return inMediaType, nil, err
+	}
+	return inMediaType, buf.Bytes(), nil
+}
+
+// convertValueTo is like convertValue, but the converted value is encoded straight into buf,
+// so that a reused buffer saves allocating a new output for every value.
+func convertValueTo(buf *bytes.Buffer, value []byte, outMediaType string) (inMediaType string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errMalformedValue, r)
+		}
+	}()
+
+	inMediaType, _, err = encoding.DetectAndExtract(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errMalformedValue, err)
+	}
+
+	// Only the objects decoded by the scheme are streamed, the rest takes the shortcuts of auger
+	if inMediaType != encoding.StorageBinaryMediaType || outMediaType == encoding.ProtobufMediaType {
+		data, _, err := encoding.Convert(scheme.Codecs, inMediaType, outMediaType, value)
+		if err != nil {
+			return inMediaType, err
+		}
+		buf.Write(data)
+		return inMediaType, nil
+	}
+
+	typeMeta, err := encoding.DecodeTypeMeta(inMediaType, value)
+	if err != nil {
+		return inMediaType, err
+	}
+	gv, err := schema.ParseGroupVersion(typeMeta.APIVersion)
+	if err != nil {
+		return inMediaType, err
+	}
+	inCodec, err := newCodec(gv, encoding.ProtobufMediaType)
+	if err != nil {
+		return inMediaType, err
+	}
+	outCodec, err := newCodec(gv, outMediaType)
+	if err != nil {
+		return inMediaType, err
+	}
+
+	obj, err := runtime.Decode(inCodec, value)
+	if err != nil {
+		return inMediaType, fmt.Errorf("error decoding from %s: %w", inMediaType, err)
+	}
+	err = outCodec.Encode(obj, buf)
+	if err != nil {
+		return inMediaType, fmt.Errorf("error encoding to %s: %w", outMediaType, err)
+	}
+	return inMediaType, nil
+}
+
+// convertValueToVersion is like convertValueTo, but the object is converted to the version first.
+func convertValueToVersion(buf *bytes.Buffer, value []byte, outMediaType string, gv schema.GroupVersion) (inMediaType string, err error) {
+	inMediaType, data, err := convertValue(value, encoding.JsonMediaType)
+	if err != nil {
+		return inMediaType, err
+	}
+
+	data, converted, err := convertVersion(data, gv)
+	if err != nil {
+		return inMediaType, err
+	}
+	// Have the fields of all the objects in the same order as the converted ones, to be compared easily
+	if !converted {
+		var obj map[string]any
+		err = json.Unmarshal(data, &obj)
+		if err != nil {
+			return inMediaType, err
+		}
+		data, err = json.Marshal(obj)
+		if err != nil {
+			return inMediaType, err
+		}
+	}
+
+	if outMediaType != encoding.JsonMediaType {
+		data, _, err = encoding.Convert(scheme.Codecs, encoding.JsonMediaType, outMediaType, data)
+		if err != nil {
+			return inMediaType, err
+		}
+	} else if !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	buf.Write(data)
+	return inMediaType, nil
+}
+
+// newCodec returns the codec of the media type for the group version.
+func newCodec(gv schema.GroupVersion, mediaType string) (runtime.Codec, error) {
+	info, ok := runtime.SerializerInfoForMediaType(scheme.Codecs.SupportedMediaTypes(), mediaType)
+	if !ok {
+		return nil, fmt.Errorf("unsupported media type: %s", mediaType)
+	}
+	encoder := scheme.Codecs.EncoderForVersion(info.Serializer, gv)
+	decoder := scheme.Codecs.DecoderToVersion(info.Serializer, gv)
+	return scheme.Codecs.CodecForVersions(encoder, decoder, gv, gv), nil
+}
+
+// maxPooledBufferSize is the capacity above which buffers are not kept for reuse,
+// so that a single huge value does not pin its memory for the rest of the run.
+const maxPooledBufferSize = 16 << 20
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// convertToStorage converts the JSON object to the storage media type.
+// Kinds unknown to the scheme can only be served by the apiserver as custom resources,
+// which are always stored as JSON, so they are passed through unchanged.
+func convertToStorage(data []byte, mediaType string) ([]byte, error) {
+	if mediaType == encoding.JsonMediaType {
+		return data, nil
+	}
+
+	typeMeta, err := encoding.DecodeTypeMeta(encoding.JsonMediaType, data)
+	if err != nil {
+		return nil, err
+	}
+	if !scheme.Scheme.Recognizes(typeMeta.GroupVersionKind()) {
+		return data, nil
+	}
+
+	data, _, err = encoding.Convert(scheme.Codecs, encoding.JsonMediaType, mediaType, data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// checkStrict returns an error if the JSON object is of a type unknown to the scheme
+// that would be stored as the media type, or has fields that do not exist in its type.
+func checkStrict(data []byte, mediaType string) error {
+	typeMeta, err := encoding.DecodeTypeMeta(encoding.JsonMediaType, data)
+	if err != nil {
+		return err
+	}
+	gvk := typeMeta.GroupVersionKind()
+	if !scheme.Scheme.Recognizes(gvk) {
+		if mediaType == encoding.JsonMediaType {
+			return nil
+		}
+		return fmt.Errorf("unknown type %s", gvk)
+	}
+	return strictDecode(data, gvk)
+}
+
+// strictSerializer decodes the JSON objects of the scheme as the apiserver does in its strict field validation,
+// failing on the unknown and the duplicate fields, and on the fields only matching case-insensitively.
+var strictSerializer = serializerjson.NewSerializerWithOptions(serializerjson.DefaultMetaFactory, scheme.Scheme, scheme.Scheme,
+	serializerjson.SerializerOptions{Strict: true})
+
+// strictDecode checks that every field of the data exists in the type of gvk.
+func strictDecode(data []byte, gvk schema.GroupVersionKind) error {
+	_, _, err := strictSerializer.Decode(data, &gvk, nil)
+	return err
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/wzshiming/kectl/pkg/scheme"
+)
+
+const podJSON = `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","namespace":"default"},"spec":{"containers":[{"name":"c","image":"busybox"}]}}`
+
+func FuzzConvertValue(f *testing.F) {
+	pb, _, err := encoding.Convert(scheme.Codecs, encoding.JsonMediaType, encoding.StorageBinaryMediaType, []byte(podJSON))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add([]byte(podJSON))
+	f.Add(pb)
+	f.Add(pb[:len(pb)/2])
+	f.Add([]byte("k8s\x00"))
+	f.Add([]byte("{}"))
+
+	f.Fuzz(func(t *testing.T, value []byte) {
+		for _, outMediaType := range []string{encoding.JsonMediaType, encoding.YamlMediaType} {
+			_, _, _ = convertValue(value, outMediaType)
+		}
+	})
+}
+
+func TestConvertToStorage(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		mediaType string
+		wantProto bool
+	}{
+		{
+			name:      "pod",
+			data:      podJSON,
+			mediaType: encoding.StorageBinaryMediaType,
+			wantProto: true,
+		},
+		{
+			name:      "custom resource under k8s.io",
+			data:      `{"apiVersion":"gateway.networking.k8s.io/v1","kind":"Gateway","metadata":{"name":"gw","namespace":"default"}}`,
+			mediaType: encoding.StorageBinaryMediaType,
+		},
+		{
+			name:      "json",
+			data:      podJSON,
+			mediaType: encoding.JsonMediaType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertToStorage([]byte(tt.data), tt.mediaType)
+			if err != nil {
+				t.Fatalf("convertToStorage() error = %v", err)
+			}
+			gotProto := bytes.HasPrefix(got, encoding.ProtoEncodingPrefix)
+			if gotProto != tt.wantProto {
+				t.Errorf("convertToStorage() proto = %v, want %v", gotProto, tt.wantProto)
+			}
+			if !gotProto && string(got) != tt.data {
+				t.Errorf("convertToStorage() = %s, want passthrough", got)
+			}
+		})
+	}
+}
+
+func TestConvertValueTo(t *testing.T) {
+	pb, _, err := encoding.Convert(scheme.Codecs, encoding.JsonMediaType, encoding.StorageBinaryMediaType, []byte(podJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range [][]byte{pb, []byte(podJSON)} {
+		for _, outMediaType := range []string{encoding.JsonMediaType, encoding.YamlMediaType} {
+			inMediaType, _, err := encoding.DetectAndExtract(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _, err := encoding.Convert(scheme.Codecs, inMediaType, outMediaType, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			_, err = convertValueTo(&buf, value, outMediaType)
+			if err != nil {
+				t.Fatalf("convertValueTo() error = %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("convertValueTo() from %s to %s = %s, want %s", inMediaType, outMediaType, buf.Bytes(), want)
+			}
+		}
+	}
+}
+
+// largePod returns a pod of about size bytes in storage, like the ones carrying big annotations.
+func largePod(b *testing.B, size int) []byte {
+	pod := fmt.Sprintf(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod","namespace":"default","annotations":{"large":%q}},"spec":{"containers":[{"name":"c","image":"busybox"}]}}`,
+		strings.Repeat("x", size))
+	pb, _, err := encoding.Convert(scheme.Codecs, encoding.JsonMediaType, encoding.StorageBinaryMediaType, []byte(pod))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return pb
+}
+
+func BenchmarkConvertValue(b *testing.B) {
+	value := largePod(b, 4<<20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, data, err := encoding.Convert(scheme.Codecs, encoding.StorageBinaryMediaType, encoding.JsonMediaType, value)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, _ = fmt.Fprintf(io.Discard, "---\n# %s | %s\n%s\n", "key", encoding.StorageBinaryMediaType, data)
+	}
+}
+
+func BenchmarkConvertValueTo(b *testing.B) {
+	value := largePod(b, 4<<20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := getBuffer()
+		_, err := convertValueTo(buf, value, encoding.JsonMediaType)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, _ = fmt.Fprintf(io.Discard, "---\n# %s | %s\n", "key", encoding.StorageBinaryMediaType)
+		_, _ = buf.WriteTo(io.Discard)
+		putBuffer(buf)
+	}
+}
+
+func TestCheckStrict(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		mediaType string
+		wantErr   bool
+	}{
+		{
+			name:      "pod",
+			data:      podJSON,
+			mediaType: encoding.StorageBinaryMediaType,
+		},
+		{
+			name:      "unknown field",
+			data:      `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"pod"},"spec":{"unknown":true}}`,
+			mediaType: encoding.StorageBinaryMediaType,
+			wantErr:   true,
+		},
+		{
+			name:      "unknown type",
+			data:      `{"apiVersion":"gateway.networking.k8s.io/v1","kind":"Gateway","metadata":{"name":"gw"}}`,
+			mediaType: encoding.StorageBinaryMediaType,
+			wantErr:   true,
+		},
+		{
+			name:      "custom resource",
+			data:      `{"apiVersion":"auger.x-k8s.io/v1","kind":"Foo","metadata":{"name":"foo"},"spec":{"any":true}}`,
+			mediaType: encoding.JsonMediaType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkStrict([]byte(tt.data), tt.mediaType)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkStrict() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -18,13 +18,17 @@ limitations under the License.
 package cmd
 
 import (
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
 	"go.etcd.io/etcd/client/pkg/v3/transport"
 )
 
 type flagpole struct {
+	Flavor                string
+	Backend               string
 	Insecure              bool
 	InsecureSkipVerify    bool
 	InsecureDiscovery     bool
@@ -33,12 +37,36 @@ type flagpole struct {
 	CommandTimeOut        time.Duration
 	KeepAliveTime         time.Duration
 	KeepAliveTimeout      time.Duration
+	PermitWithoutStream   bool
+	MaxSendMsgSize        string
+	MaxRecvMsgSize        string
+	RequestTimeout        time.Duration
+	MaxRetries            int
+	RetryBackoff          time.Duration
+	RetryMaxBackoff       time.Duration
 	DNSClusterServiceName string
 
 	TLS transport.TLSInfo
 
-	User     string
-	Password string
+	User      string
+	Password  string
+	AuthToken string
+
+	Kubeconfig  string
+	PortForward bool
+
+	SSH                      string
+	SSHIdentity              string
+	SSHInsecureIgnoreHostKey bool
+	SSHRemoteCerts           bool
+
+	IKnowWhatIAmDoing bool
+
+	LogRequests bool
+
+	KeyCodec string
+
+	EtcdNamespace string
 }
 
 // NewCtlCommand returns a new cobra.Command for use ctl
@@ -49,6 +77,9 @@ func NewCtlCommand() *cobra.Command {
 		defaultCommandTimeOut   = 5 * time.Second
 		defaultKeepAliveTime    = 2 * time.Second
 		defaultKeepAliveTimeOut = 6 * time.Second
+		defaultMaxRetries       = 5
+		defaultRetryBackoff     = 100 * time.Millisecond
+		defaultRetryMaxBackoff  = 5 * time.Second
 	)
 
 	cmd := &cobra.Command{
@@ -56,11 +87,20 @@ func NewCtlCommand() *cobra.Command {
 		Short: "A simple command line client for directly access data objects stored in etcd by Kubernetes.",
 	}
 	cmd.PersistentFlags().StringSliceVar(&flags.Endpoints, "endpoints", []string{"127.0.0.1:2379"}, "gRPC endpoints")
+	cmd.PersistentFlags().StringVar(&flags.Flavor, "flavor", "", "flavor of the cluster that sets the defaults of the endpoints, TLS and backend. One of: (kubeadm, k3s). Defaults to kubeadm if its certificates are found.")
+	cmd.PersistentFlags().StringVar(&flags.Backend, "backend", "", "storage backend. One of: ("+strings.Join(client.Backends(), ", ")+"). Defaults to the one of the endpoint URI scheme.")
 
 	cmd.PersistentFlags().DurationVar(&flags.DialTimeout, "dial-timeout", defaultDialTimeout, "dial timeout for client connections")
 	cmd.PersistentFlags().DurationVar(&flags.CommandTimeOut, "command-timeout", defaultCommandTimeOut, "timeout for short running command (excluding dial timeout)")
 	cmd.PersistentFlags().DurationVar(&flags.KeepAliveTime, "keepalive-time", defaultKeepAliveTime, "keepalive time for client connections")
 	cmd.PersistentFlags().DurationVar(&flags.KeepAliveTimeout, "keepalive-timeout", defaultKeepAliveTimeOut, "keepalive timeout for client connections")
+	cmd.PersistentFlags().BoolVar(&flags.PermitWithoutStream, "keepalive-permit-without-stream", false, "send keepalive pings even without active requests or watches, to keep an idle connection open through middleboxes dropping it")
+	cmd.PersistentFlags().StringVar(&flags.MaxSendMsgSize, "max-send-msg-size", "0", "maximum bytes of a request to send, e.g. 16Mi, to put values larger than the default 2Mi. 0 for the default.")
+	cmd.PersistentFlags().StringVar(&flags.MaxRecvMsgSize, "max-recv-msg-size", "0", "maximum bytes of a response to receive, e.g. 64Mi, below the default 2Gi to bound the memory of a response. 0 for the default.")
+	cmd.PersistentFlags().DurationVar(&flags.RequestTimeout, "request-timeout", 0, "timeout for each attempt of a request, 0 means no timeout")
+	cmd.PersistentFlags().IntVar(&flags.MaxRetries, "max-retries", defaultMaxRetries, "maximum number of retries of a request failing with a transient error, such as during a leader election")
+	cmd.PersistentFlags().DurationVar(&flags.RetryBackoff, "retry-backoff", defaultRetryBackoff, "wait before the first retry, doubled for each following one")
+	cmd.PersistentFlags().DurationVar(&flags.RetryMaxBackoff, "retry-max-backoff", defaultRetryMaxBackoff, "maximum wait between retries")
 
 	cmd.PersistentFlags().BoolVar(&flags.Insecure, "insecure-transport", true, "disable transport security for client connections")
 	cmd.PersistentFlags().BoolVar(&flags.InsecureDiscovery, "insecure-discovery", true, "accept insecure SRV records describing cluster endpoints")
@@ -70,13 +110,46 @@ func NewCtlCommand() *cobra.Command {
 	cmd.PersistentFlags().StringVar(&flags.TLS.TrustedCAFile, "cacert", "", "verify certificates of TLS-enabled secure servers using this CA bundle")
 	cmd.PersistentFlags().StringVar(&flags.User, "user", "", "username[:password] for authentication (prompt if password is not supplied)")
 	cmd.PersistentFlags().StringVar(&flags.Password, "password", "", "password for authentication (if this option is used, --user option shouldn't include password)")
+	cmd.PersistentFlags().StringVar(&flags.AuthToken, "auth-token", "", "auth token issued beforehand for authentication, such as a JWT (it is not refreshed, unlike the tokens of --user)")
 	cmd.PersistentFlags().StringVarP(&flags.TLS.ServerName, "discovery-srv", "d", "", "domain name to query for SRV records describing cluster endpoints")
 	cmd.PersistentFlags().StringVarP(&flags.DNSClusterServiceName, "discovery-srv-name", "", "", "service name to query when using DNS discovery")
+	cmd.PersistentFlags().StringVar(&flags.Kubeconfig, "kubeconfig", "", "access the objects through the apiserver of the kubeconfig instead of etcd")
+	cmd.PersistentFlags().BoolVar(&flags.PortForward, "port-forward", false, "access etcd through a port-forward to its pod found by the apiserver of the kubeconfig, instead of the apiserver")
+	cmd.PersistentFlags().StringVar(&flags.SSH, "ssh", "", "user@host[:port] to open an SSH tunnel to and dial etcd from, the endpoints are as seen from that host")
+	cmd.PersistentFlags().StringVar(&flags.SSHIdentity, "ssh-identity", "", "private key for SSH authentication (defaults to the ssh-agent and the keys in ~/.ssh)")
+	cmd.PersistentFlags().BoolVar(&flags.SSHInsecureIgnoreHostKey, "ssh-insecure-ignore-host-key", false, "skip the SSH host key verification against ~/.ssh/known_hosts (CAUTION: this option should be enabled only for testing purposes)")
+	cmd.PersistentFlags().BoolVar(&flags.SSHRemoteCerts, "ssh-remote-certs", false, "read the --cert, --key and --cacert files from the SSH host over SFTP")
+	cmd.PersistentFlags().BoolVar(&flags.LogRequests, "log-requests", false, "log each request to etcd and each watch event to stderr, with its key, revision, bytes and latency")
+	cmd.PersistentFlags().StringVar(&flags.EtcdNamespace, "etcd-namespace", "", "etcd namespace the keys are under, the key prefix of a logical cluster sharing the etcd with others, as the --namespace of the gRPC proxy of etcd. The --prefix of k8s is within it.")
+	cmd.PersistentFlags().StringVar(&flags.KeyCodec, "key-codec", "none", "encoding of the names and namespaces in the keys, for the distributions escaping or hashing them. One of: ("+strings.Join(client.KeyCodecs(), ", ")+").")
+	cmd.PersistentFlags().BoolVar(&flags.IKnowWhatIAmDoing, "i-know-what-i-am-doing", false, "write even if the control plane appears to be running")
 
 	cmd.AddCommand(
 		newCtlGetCommand(),
 		newCtlDelCommand(),
 		newCtlPutCommand(),
+		newCtlEncryptCommand(),
+		newCtlMigrateCommand(),
+		newCtlReconstructCommand(),
+		newCtlQueryCommand(),
+		newCtlTouchCommand(),
+		newCtlRenameCommand(),
+		newCtlAnalyzeCommand(),
+		newCtlRecordingCommand(),
+		newCtlInfoCommand(),
+		newCtlVerifyRecordingCommand(),
+		newCtlConvertRecordingCommand(),
+		newCtlSplitCommand(),
+		newCtlTrimCommand(),
+		newCtlGrepCommand(),
+		newCtlCatCommand(),
+		newCtlDiffCommand(),
+		newCtlSnapshotCommand(),
+		newCtlKeyOfCommand(),
+		newCtlFollowCommand(),
+		newCtlExportCommand(),
+		newCtlJobCommand(),
+		newCtlVersionCommand(),
 	)
 	return cmd
 }
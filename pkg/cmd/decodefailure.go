@@ -0,0 +1,85 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// decodeFailures counts the values that can not be decoded by resource, in the lenient mode where they are written raw,
+// for a resource failing on all its values, such as one of an aggregated API unknown to kectl, to be summarized once
+// rather than found among the documents.
+type decodeFailures struct {
+	prefix string
+
+	mut       sync.Mutex
+	resources map[string]*decodeFailure
+}
+
+type decodeFailure struct {
+	count int
+	// key and err are of the first value failing
+	key string
+	err error
+}
+
+func newDecodeFailures(prefix string) *decodeFailures {
+	return &decodeFailures{
+		prefix:    prefix,
+		resources: map[string]*decodeFailure{},
+	}
+}
+
+func (f *decodeFailures) add(key string, err error) {
+	if f == nil {
+		return
+	}
+	resource := "unknown"
+	if gr, _, ok := groupResourceFromKey(f.prefix, key); ok {
+		resource = gr.String()
+	}
+
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	failure, ok := f.resources[resource]
+	if !ok {
+		failure = &decodeFailure{key: key, err: err}
+		f.resources[resource] = failure
+	}
+	failure.count++
+}
+
+// report writes a warning for each resource with values that can not be decoded, in the order of the resources.
+func (f *decodeFailures) report(w io.Writer) {
+	if f == nil {
+		return
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	resources := make([]string, 0, len(f.resources))
+	for resource := range f.resources {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		failure := f.resources[resource]
+		fmt.Fprintf(w, "warning: %d values of %s can not be decoded and are written raw, such as %s: %v\n", failure.count, resource, failure.key, failure.err)
+	}
+}
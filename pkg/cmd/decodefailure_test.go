@@ -0,0 +1,70 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+func TestDecodeFailures(t *testing.T) {
+	failures := newDecodeFailures("/registry")
+	printer, err := newPrinter(io.Discard, printerOptions{
+		Output:     "yaml",
+		DecodeMode: decodeModeLenient,
+		Failures:   failures,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kv := range []*client.KeyValue{
+		{Key: []byte("/registry/metrics.example.com/nodemetrics/n0"), Value: []byte("k8s\x00\x0a\x01")},
+		{Key: []byte("/registry/metrics.example.com/nodemetrics/n1"), Value: []byte("k8s\x00\x0a\x02")},
+		{Key: []byte("/registry/configmaps/default/a"), Value: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a"}}`)},
+		{Key: []byte("/registry/configmaps/default/b"), Value: []byte("not an object")},
+	} {
+		err := printer(kv)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	failures.report(&buf)
+	got := buf.String()
+	for _, want := range []string{
+		"warning: 1 values of configmaps can not be decoded and are written raw, such as /registry/configmaps/default/b: ",
+		"warning: 2 values of nodemetrics.metrics.example.com can not be decoded and are written raw, such as /registry/metrics.example.com/nodemetrics/n0: ",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("report() = %q, want it to contain %q", got, want)
+		}
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("\n")); n != 2 {
+		t.Errorf("report() wrote %d lines, want one for each resource", n)
+	}
+
+	// Without failures there is nothing to report
+	buf.Reset()
+	newDecodeFailures("/registry").report(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("report() = %q, want nothing", buf.String())
+	}
+}
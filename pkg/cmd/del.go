@@ -18,12 +18,16 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/etcd-io/auger/pkg/encoding"
 	"github.com/spf13/cobra"
 	"github.com/wzshiming/kectl/pkg/client"
 	"github.com/wzshiming/kectl/pkg/wellknown"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -32,6 +36,8 @@ type delFlagpole struct {
 	Output       string
 	Prefix       string
 	AllNamespace bool
+	Freeze       []string
+	Cascade      bool
 }
 
 func newCtlDelCommand() *cobra.Command {
@@ -46,6 +52,10 @@ func newCtlDelCommand() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			err = checkLiveControlPlane(cmd.Context(), cmd, etcdclient, flags.Prefix)
+			if err != nil {
+				return err
+			}
 			err = delCommand(cmd.Context(), etcdclient, flags, args)
 
 			if err != nil {
@@ -59,6 +69,8 @@ func newCtlDelCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&flags.Namespace, "namespace", "n", "", "namespace of resource")
 	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
 	cmd.Flags().BoolVarP(&flags.AllNamespace, "all-namespace", "A", false, "all namespace")
+	cmd.Flags().StringSliceVar(&flags.Freeze, "freeze", nil, "objects never to be deleted, in the form of resource/namespace/name or resource/name.")
+	cmd.Flags().BoolVar(&flags.Cascade, "cascade", false, "also delete the instances of the customresourcedefinitions being deleted, instead of refusing to orphan them")
 
 	return cmd
 }
@@ -102,20 +114,54 @@ func delCommand(ctx context.Context, etcdclient client.Client, flags *delFlagpol
 		}
 	}
 
-	opOpts := []client.OpOption{
-		client.WithName(targetName, targetNamespace),
-		client.WithGR(targetGr),
-	}
-
+	var responseOpts []client.OpOption
 	if response != nil {
-		opOpts = append(opOpts,
+		responseOpts = []client.OpOption{
 			client.WithKeysOnly(),
 			client.WithResponse(response),
+		}
+	}
+
+	frozen, err := parseFrozenObjects(flags.Freeze)
+	if err != nil {
+		return err
+	}
+	del := func(opOpts ...client.OpOption) error {
+		opOpts = append(opOpts, responseOpts...)
+		if len(frozen) == 0 {
+			return etcdclient.Delete(ctx, flags.Prefix, opOpts...)
+		}
+		return deleteUnfrozen(ctx, etcdclient, flags.Prefix, frozen, opOpts)
+	}
+
+	// Deleting everything under the prefix deletes the custom resources too,
+	// only deleting the definitions alone would orphan them.
+	if targetGr == crdGroupResource {
+		instances, err := customResourceInstances(ctx, etcdclient, flags.Prefix,
+			client.WithName(targetName, targetNamespace),
+			client.WithGR(targetGr),
 		)
+		if err != nil {
+			return err
+		}
+		if len(instances) != 0 && !flags.Cascade {
+			msgs := make([]string, 0, len(instances))
+			for _, i := range instances {
+				msgs = append(msgs, fmt.Sprintf("%s has %d instances under %s/%s/%s", i.CRD, i.Count, flags.Prefix, i.GroupResource.Group, i.GroupResource.Resource))
+			}
+			return fmt.Errorf("refusing to orphan custom resources, use --cascade to delete them too: %s", strings.Join(msgs, ", "))
+		}
+		for _, i := range instances {
+			err = del(client.WithGR(i.GroupResource))
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	err := etcdclient.Delete(ctx, flags.Prefix,
-		opOpts...,
+	err = del(
+		client.WithName(targetName, targetNamespace),
+		client.WithGR(targetGr),
 	)
 	if err != nil {
 		return err
@@ -126,3 +172,140 @@ func delCommand(ctx context.Context, etcdclient client.Client, flags *delFlagpol
 	}
 	return nil
 }
+
+var crdGroupResource = schema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}
+
+// crdInstances is the number of custom resources stored for a customresourcedefinition.
+type crdInstances struct {
+	CRD           string
+	GroupResource schema.GroupResource
+	Count         int
+}
+
+// customResourceInstances returns the customresourcedefinitions selected by the options
+// that still have custom resources stored, which would be orphaned by deleting the definitions alone.
+func customResourceInstances(ctx context.Context, etcdclient client.Client, prefix string, opOpts ...client.OpOption) ([]crdInstances, error) {
+	var crds []string
+	_, err := etcdclient.Get(ctx, prefix,
+		append(opOpts,
+			client.WithResponse(func(kv *client.KeyValue) error {
+				gr, name, ok := groupResourceFromKey(prefix, string(kv.Key))
+				if ok && gr == crdGroupResource {
+					crds = append(crds, objectNameOf(kv.Value, name))
+				}
+				return nil
+			}),
+		)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []crdInstances
+	for _, crd := range crds {
+		// The name of a customresourcedefinition is always <plural>.<group>
+		resource, group, ok := strings.Cut(crd, ".")
+		if !ok {
+			continue
+		}
+		gr := schema.GroupResource{Group: group, Resource: resource}
+		var count int
+		_, err := etcdclient.Get(ctx, prefix,
+			client.WithGR(gr),
+			client.WithKeysOnly(),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				count++
+				return nil
+			}),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if count != 0 {
+			instances = append(instances, crdInstances{CRD: crd, GroupResource: gr, Count: count})
+		}
+	}
+	return instances, nil
+}
+
+// objectNameOf returns the name in the metadata of the value,
+// or the name in its key if the value can not be decoded, such as an encrypted one.
+// The key only has the name if the key codec of the client can decode it.
+func objectNameOf(value []byte, name string) string {
+	_, data, err := convertValue(value, encoding.JsonMediaType)
+	if err != nil {
+		return name
+	}
+	var obj metav1.PartialObjectMetadata
+	err = json.Unmarshal(data, &obj)
+	if err != nil || obj.Name == "" {
+		return name
+	}
+	return obj.Name
+}
+
+// deleteUnfrozen deletes the keys one by one, except the frozen objects.
+// The frozen objects are told by their keys as listed, for the key codecs that can not decode the names from the keys.
+func deleteUnfrozen(ctx context.Context, etcdclient client.Client, prefix string, frozen frozenObjects, opOpts []client.OpOption) error {
+	frozenKeys := map[string]struct{}{}
+	for obj := range frozen {
+		_, err := etcdclient.Get(ctx, prefix,
+			client.WithGR(obj.gr),
+			client.WithName(obj.name, obj.namespace),
+			client.WithKeysOnly(),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				frozenKeys[string(kv.Key)] = struct{}{}
+				return nil
+			}),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	type target struct {
+		key       string
+		gr        schema.GroupResource
+		namespace string
+		name      string
+	}
+	var targets []target
+	_, err := etcdclient.Get(ctx, prefix,
+		append(opOpts,
+			client.WithKeysOnly(),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				gr, rest, ok := groupResourceFromKey(prefix, string(kv.Key))
+				if !ok {
+					return nil
+				}
+				namespace, name, err := splitName(rest)
+				if err != nil {
+					return err
+				}
+				if _, ok := frozenKeys[string(kv.Key)]; ok {
+					fmt.Fprintf(os.Stderr, "skip frozen %s\n", kv.Key)
+					return nil
+				}
+				targets = append(targets, target{key: string(kv.Key), gr: gr, namespace: namespace, name: name})
+				return nil
+			}),
+		)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		err = etcdclient.Delete(ctx, prefix,
+			append(opOpts,
+				client.WithGR(t.gr),
+				client.WithName(t.name, t.namespace),
+				client.WithKey(t.key),
+			)...,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDelCommandCascade(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		cascade bool
+		wantErr string
+		want    []string
+	}{
+		{
+			name:    "refuse to orphan",
+			args:    []string{"crd", "widgets.example.com"},
+			wantErr: "widgets.example.com has 2 instances under /registry/example.com/widgets",
+			want: []string{
+				"/registry/apiextensions.k8s.io/customresourcedefinitions/gadgets.example.com",
+				"/registry/apiextensions.k8s.io/customresourcedefinitions/widgets.example.com",
+				"/registry/example.com/widgets/default/a",
+				"/registry/example.com/widgets/default/b",
+			},
+		},
+		{
+			name: "no instances",
+			args: []string{"crd", "gadgets.example.com"},
+			want: []string{
+				"/registry/apiextensions.k8s.io/customresourcedefinitions/widgets.example.com",
+				"/registry/example.com/widgets/default/a",
+				"/registry/example.com/widgets/default/b",
+			},
+		},
+		{
+			name:    "cascade",
+			args:    []string{"crd"},
+			cascade: true,
+			want:    nil,
+		},
+		{
+			name: "everything",
+			args: nil,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			etcdclient := fake.NewClient()
+			for _, obj := range []struct {
+				gr              schema.GroupResource
+				namespace, name string
+			}{
+				{crdGroupResource, "", "widgets.example.com"},
+				{crdGroupResource, "", "gadgets.example.com"},
+				{schema.GroupResource{Group: "example.com", Resource: "widgets"}, "default", "a"},
+				{schema.GroupResource{Group: "example.com", Resource: "widgets"}, "default", "b"},
+			} {
+				err := etcdclient.Put(ctx, "/registry", []byte("{}"), client.WithGR(obj.gr), client.WithName(obj.name, obj.namespace))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := delCommand(ctx, etcdclient, &delFlagpole{
+				Output:  "none",
+				Prefix:  "/registry",
+				Cascade: tt.cascade,
+			}, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("delCommand() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			_, err = etcdclient.Get(ctx, "/registry", client.WithKeysOnly(), client.WithResponse(func(kv *client.KeyValue) error {
+				got = append(got, string(kv.Key))
+				return nil
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelCommandHashedKeys(t *testing.T) {
+	widgets := schema.GroupResource{Group: "example.com", Resource: "widgets"}
+	configmaps := schema.GroupResource{Resource: "configmaps"}
+	tests := []struct {
+		name    string
+		args    []string
+		freeze  []string
+		wantErr string
+		want    []string
+	}{
+		{
+			name:    "refuse to orphan",
+			args:    []string{"crd", "widgets.example.com"},
+			wantErr: "widgets.example.com has 1 instances under /registry/example.com/widgets",
+			want:    []string{"widgets.example.com", "default/a", "default/b", "default/w"},
+		},
+		{
+			name:   "freeze",
+			args:   []string{"configmaps"},
+			freeze: []string{"configmaps/default/a"},
+			want:   []string{"widgets.example.com", "default/a", "default/w"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			etcdclient, _ := newHashedKeysClient(t)
+			for _, obj := range []struct {
+				gr              schema.GroupResource
+				namespace, name string
+				value           string
+			}{
+				{crdGroupResource, "", "widgets.example.com", `{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition","metadata":{"name":"widgets.example.com"}}`},
+				{configmaps, "default", "a", "{}"},
+				{configmaps, "default", "b", "{}"},
+				{widgets, "default", "w", "{}"},
+			} {
+				err := etcdclient.Put(ctx, "/registry", []byte(obj.value), client.WithGR(obj.gr), client.WithName(obj.name, obj.namespace))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := delCommand(ctx, etcdclient, &delFlagpole{
+				Output:    "none",
+				Namespace: "default",
+				Prefix:    "/registry",
+				Freeze:    tt.freeze,
+			}, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("delCommand() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			// The names of the objects left, each looked up by its name as the keys only have its hash
+			var got []string
+			for _, obj := range []struct {
+				gr              schema.GroupResource
+				namespace, name string
+			}{
+				{crdGroupResource, "", "widgets.example.com"},
+				{configmaps, "default", "a"},
+				{configmaps, "default", "b"},
+				{widgets, "default", "w"},
+			} {
+				_, err = etcdclient.Get(ctx, "/registry", client.WithGR(obj.gr), client.WithName(obj.name, obj.namespace), client.WithResponse(func(kv *client.KeyValue) error {
+					got = append(got, path.Join(obj.namespace, obj.name))
+					return nil
+				}))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("objects = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type diffFlagpole struct {
+	Output      string
+	Prefix      string
+	IgnoreField []string
+}
+
+func newCtlDiffCommand() *cobra.Command {
+	flags := &diffFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(2),
+		Use:   "diff <path> <path>",
+		Short: "Compares the objects at the end of two recordings or snapshots, field by field",
+		Long: "Compares the objects at the end of two recordings or snapshots, - for stdin, object by object,\n" +
+			"reporting the objects added, removed and changed from the first to the second with the fields changed.\n" +
+			"The objects at the end of a recording of kectl are the latest documents of its keys without the deleted ones,\n" +
+			"and the ones of a kwok recording its objects with its changes applied. Lists of objects, such as of kubectl get -o yaml, are compared by their items.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := diffCommand(flags, args[0], args[1], os.Stdout)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "text", "output format. One of: (text, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().StringSliceVar(&flags.IgnoreField, "ignore-field", []string{"metadata.resourceVersion", "metadata.managedFields"}, "paths of the fields not to compare, with the fields under them, such as metadata.generation or status")
+
+	return cmd
+}
+
+type objectDiff struct {
+	Object  string        `json:"object"`
+	Changes []fieldChange `json:"changes"`
+}
+
+type recordingDiff struct {
+	Added     []string     `json:"added"`
+	Removed   []string     `json:"removed"`
+	Changed   []objectDiff `json:"changed"`
+	Unchanged int          `json:"unchanged"`
+}
+
+func diffCommand(flags *diffFlagpole, a, b string, w io.Writer) error {
+	if flags.Output != "text" && flags.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+	if a == "-" && b == "-" {
+		return fmt.Errorf("only one of the paths can be stdin")
+	}
+	before, err := readFinalObjects(a, flags.Prefix, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", a, err)
+	}
+	after, err := readFinalObjects(b, flags.Prefix, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", b, err)
+	}
+
+	diff := compareObjects(before, after, flags.IgnoreField)
+	switch flags.Output {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(diff)
+	case "text":
+		for _, id := range diff.Removed {
+			fmt.Fprintf(w, "- %s\n", id)
+		}
+		for _, id := range diff.Added {
+			fmt.Fprintf(w, "+ %s\n", id)
+		}
+		for _, d := range diff.Changed {
+			fmt.Fprintf(w, "~ %s\n", d.Object)
+			for _, change := range d.Changes {
+				fmt.Fprintf(w, "    %s\n", change)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d added, %d removed, %d changed and %d unchanged objects\n", len(diff.Added), len(diff.Removed), len(diff.Changed), diff.Unchanged)
+	return nil
+}
+
+// compareObjects compares the objects by reference, sorted by it, without the fields under the ignored paths.
+func compareObjects(before, after kwokObjects, ignore []string) *recordingDiff {
+	diff := &recordingDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []objectDiff{},
+	}
+	refs := make([]snapshotObjectRef, 0, len(before)+len(after))
+	for ref := range before {
+		refs = append(refs, ref)
+	}
+	for ref := range after {
+		if _, ok := before[ref]; !ok {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].String() < refs[j].String()
+	})
+
+	for _, ref := range refs {
+		old, ok := before[ref]
+		if !ok {
+			diff.Added = append(diff.Added, ref.String())
+			continue
+		}
+		new, ok := after[ref]
+		if !ok {
+			diff.Removed = append(diff.Removed, ref.String())
+			continue
+		}
+		changes := compareFields(comparableFields(old, ignore), comparableFields(new, ignore))
+		if len(changes) == 0 {
+			diff.Unchanged++
+			continue
+		}
+		diff.Changed = append(diff.Changed, objectDiff{
+			Object:  ref.String(),
+			Changes: changes,
+		})
+	}
+	return diff
+}
+
+// comparableFields returns the flattened fields of the object, without the ones under the ignored paths.
+func comparableFields(obj *unstructured.Unstructured, ignore []string) map[string]string {
+	fields := map[string]string{}
+	flattenFields(fields, "", obj.Object)
+	for path := range fields {
+		for _, prefix := range ignore {
+			if path == prefix || strings.HasPrefix(path, prefix+".") || strings.HasPrefix(path, prefix+"[") {
+				delete(fields, path)
+				break
+			}
+		}
+	}
+	return fields
+}
+
+// readFinalObjects returns the objects at the end of the recording or the snapshot at the path, - for stdin.
+// The changes of a kwok recording to objects not known before them are warned about to warn and left out.
+func readFinalObjects(path, prefix string, warn io.Writer) (kwokObjects, error) {
+	var r io.ReadCloser
+	var err error
+	if path == "-" {
+		r, err = decompress(os.Stdin)
+	} else {
+		r, err = openRecording(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	objects := kwokObjects{}
+	scanner := newRecordingScanner(r, prefix)
+	err = scanner.Scan(func(doc *recordingRawDocument) error {
+		switch {
+		case doc.Header, doc.Bookmark != nil:
+			return nil
+		case doc.Key != "":
+			gr, rest, ok := groupResourceFromKey(prefix, doc.Key)
+			if !ok {
+				return fmt.Errorf("key %q is not under %q", doc.Key, prefix)
+			}
+			namespace, name, err := splitName(rest)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", doc.Key, err)
+			}
+			ref := snapshotObjectRef{gr: gr, namespace: namespace, name: name}
+			if doc.Deleted {
+				delete(objects, ref)
+				return nil
+			}
+			object, err := keyDocumentObject(doc, scanner.JSONL)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", doc.Key, err)
+			}
+			if object == nil {
+				fmt.Fprintf(warn, "warning: skip %s, its value can not be decoded\n", doc.Key)
+				delete(objects, ref)
+				return nil
+			}
+			objects[ref] = &unstructured.Unstructured{Object: object}
+		case doc.Object != nil:
+			if isKwokResourcePatch(doc.Object) {
+				rp, err := decodeKwokResourcePatch(doc.Object)
+				if err != nil {
+					return err
+				}
+				obj, err := objects.apply(rp)
+				if err != nil {
+					return err
+				}
+				if obj == nil && rp.Method == actionv1alpha1.PatchMethodPatch {
+					ref := snapshotObjectRef{gr: rp.Resource.GroupResource(), namespace: rp.Target.Namespace, name: rp.Target.Name}
+					fmt.Fprintf(warn, "warning: skip the patch of %s, the object is not known before it\n", ref)
+				}
+				return nil
+			}
+			if !doc.Object.IsList() {
+				return objects.add(doc.Object)
+			}
+			return doc.Object.EachListItem(func(item runtime.Object) error {
+				return objects.add(item.(*unstructured.Unstructured))
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
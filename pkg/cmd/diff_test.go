@@ -0,0 +1,172 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffCommand(t *testing.T) {
+	const recording = `---
+# /registry/deployments/default/web | application/json | 2
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+  resourceVersion: "2"
+spec:
+  replicas: 1
+---
+# /registry/pods/default/old | application/json | 3
+apiVersion: v1
+kind: Pod
+metadata:
+  name: old
+  namespace: default
+---
+# /registry/pods/default/same | application/json | 4
+apiVersion: v1
+kind: Pod
+metadata:
+  name: same
+  namespace: default
+  resourceVersion: "4"
+---
+# bookmark | 5 | 2024-01-01T00:00:00Z
+---
+# /registry/pods/default/gone | application/json | 6
+apiVersion: v1
+kind: Pod
+metadata:
+  name: gone
+  namespace: default
+---
+# /registry/pods/default/gone | 7 | deleted
+`
+	const kwokRecording = `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+  resourceVersion: "10"
+spec:
+  replicas: 1
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: same
+  namespace: default
+  resourceVersion: "11"
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  group: apps
+  version: v1
+  resource: deployments
+target:
+  name: web
+  namespace: default
+durationNanosecond: 1000
+method: patch
+template:
+  spec:
+    replicas: 3
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: new
+  namespace: default
+durationNanosecond: 2000
+method: create
+template:
+  apiVersion: v1
+  kind: Pod
+  metadata:
+    name: new
+    namespace: default
+`
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.yaml")
+	err := os.WriteFile(a, []byte(recording), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(b, []byte(kwokRecording), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = diffCommand(&diffFlagpole{
+		Output:      "text",
+		Prefix:      "/registry",
+		IgnoreField: []string{"metadata.resourceVersion", "metadata.managedFields"},
+	}, a, b, &buf)
+	if err != nil {
+		t.Fatalf("diffCommand() error = %v", err)
+	}
+	want := `- pods/default/old
++ pods/default/new
+~ deployments.apps/default/web
+    ~ spec.replicas: 1 -> 3
+`
+	if got := buf.String(); got != want {
+		t.Errorf("diffCommand() = \n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestReadFinalObjectsList(t *testing.T) {
+	const list = `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: a
+    namespace: default
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: node-1
+`
+	path := filepath.Join(t.TempDir(), "list.yaml")
+	err := os.WriteFile(path, []byte(list), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, err := readFinalObjects(path, "/registry", io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := compareObjects(kwokObjects{}, objects, nil).Added
+	if len(got) != 2 || got[0] != "nodes/node-1" || got[1] != "pods/default/a" {
+		t.Errorf("readFinalObjects() = %q, want the pod and the node", got)
+	}
+}
@@ -0,0 +1,344 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/server/options/encryptionconfig"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+func newCtlEncryptCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Manages the encryption at rest of the resource of k8s in etcd",
+	}
+	cmd.AddCommand(
+		newCtlEncryptRotateCommand(),
+		newCtlEncryptStatusCommand(),
+	)
+	return cmd
+}
+
+type encryptRotateFlagpole struct {
+	Output                   string
+	Prefix                   string
+	ChunkSize                int64
+	EncryptionProviderConfig string
+}
+
+func newCtlEncryptRotateCommand() *cobra.Command {
+	flags := &encryptRotateFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.RangeArgs(0, 1),
+		Use:   "rotate [resource]",
+		Short: "Re-encrypts the resource of k8s in etcd with the first provider of the encryption configuration",
+		Long: "Re-encrypts the resource of k8s in etcd with the first provider of the encryption configuration.\n" +
+			"Values already written by the first provider are skipped, so an interrupted rotation can be resumed by running it again.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = checkLiveControlPlane(cmd.Context(), cmd, etcdclient, flags.Prefix)
+			if err != nil {
+				return err
+			}
+			err = encryptRotateCommand(cmd.Context(), etcdclient, flags, args)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "key", "output format. One of: (key, none).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().Int64Var(&flags.ChunkSize, "chunk-size", 500, "chunk size of the list pager")
+	cmd.Flags().StringVar(&flags.EncryptionProviderConfig, "encryption-provider-config", "", "the file containing the EncryptionConfiguration used by kube-apiserver")
+
+	return cmd
+}
+
+func encryptRotateCommand(ctx context.Context, etcdclient client.Client, flags *encryptRotateFlagpole, args []string) error {
+	if flags.EncryptionProviderConfig == "" {
+		return fmt.Errorf("encryption-provider-config is required")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	config, err := encryptionconfig.LoadEncryptionConfig(ctx, flags.EncryptionProviderConfig, false, "kectl")
+	if err != nil {
+		return err
+	}
+	transformers := encryptionconfig.StaticTransformers(config.Transformers)
+
+	var targetGrs []schema.GroupResource
+	if len(args) != 0 {
+		gr := schema.ParseGroupResource(args[0])
+		if gr.Empty() {
+			return fmt.Errorf("invalid resource %q", args[0])
+		}
+		if correctGr, _, found := wellknown.CorrectGroupResource(gr); found {
+			gr = correctGr
+		}
+		targetGrs = []schema.GroupResource{gr}
+	} else {
+		targetGrs = encryptedGroupResources(config.Transformers)
+	}
+
+	var rotated, skipped int
+	for _, gr := range targetGrs {
+		transformer := transformers.TransformerForResource(gr)
+
+		keyPrefix, err := client.PrefixFromGR(gr)
+		if err != nil {
+			return err
+		}
+		keyPrefix = flags.Prefix + "/" + keyPrefix + "/"
+
+		_, err = etcdclient.Get(ctx, flags.Prefix,
+			client.WithGR(gr),
+			client.WithPageLimit(flags.ChunkSize),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				dataCtx := value.DefaultContext(kv.Key)
+				data, stale, err := transformer.TransformFromStorage(ctx, kv.Value, dataCtx)
+				if err != nil {
+					return fmt.Errorf("%s: %w", kv.Key, err)
+				}
+				if !stale {
+					skipped++
+					return nil
+				}
+
+				data, err = transformer.TransformToStorage(ctx, data, dataCtx)
+				if err != nil {
+					return fmt.Errorf("%s: %w", kv.Key, err)
+				}
+
+				namespace, name, err := splitKeyName(keyPrefix, string(kv.Key))
+				if err != nil {
+					return err
+				}
+
+				err = etcdclient.Put(ctx, flags.Prefix, data,
+					client.WithGR(gr),
+					client.WithName(name, namespace),
+					client.WithKey(string(kv.Key)),
+					client.WithModRevision(kv.Revision),
+				)
+				if err != nil {
+					// The object has been written again since it was read,
+					// which is always done by the first provider.
+					if errors.Is(err, client.ErrConflict) {
+						skipped++
+						return nil
+					}
+					return err
+				}
+
+				rotated++
+				if flags.Output == "key" {
+					fmt.Fprintf(os.Stdout, "%s\n", kv.Key)
+				}
+				return nil
+			}),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if flags.Output == "key" {
+		fmt.Fprintf(os.Stderr, "rotate %d keys, skip %d keys\n", rotated, skipped)
+	}
+	return nil
+}
+
+// encryptedGroupResources returns the resources covered by the encryption configuration,
+// the wildcards are expanded with the well-known resources.
+func encryptedGroupResources(transformers map[schema.GroupResource]value.Transformer) []schema.GroupResource {
+	set := map[schema.GroupResource]struct{}{}
+	for gr := range transformers {
+		if gr.Resource != "*" {
+			set[gr] = struct{}{}
+			continue
+		}
+		for _, known := range wellknown.GroupResources() {
+			if gr.Group == "*" || gr.Group == known.Group {
+				set[known] = struct{}{}
+			}
+		}
+	}
+
+	grs := make([]schema.GroupResource, 0, len(set))
+	for gr := range set {
+		grs = append(grs, gr)
+	}
+	sort.Slice(grs, func(i, j int) bool {
+		return grs[i].String() < grs[j].String()
+	})
+	return grs
+}
+
+type encryptStatusFlagpole struct {
+	Output      string
+	Prefix      string
+	ChunkSize   int64
+	Consistency string
+}
+
+func newCtlEncryptStatusCommand() *cobra.Command {
+	flags := &encryptStatusFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.RangeArgs(0, 1),
+		Use:   "status [resource]",
+		Short: "Reports how the resource of k8s in etcd is encrypted",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = encryptStatusCommand(cmd.Context(), etcdclient, flags, args)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().Int64Var(&flags.ChunkSize, "chunk-size", 500, "chunk size of the list pager")
+	cmd.Flags().StringVar(&flags.Consistency, "consistency", "s", "consistency of the reads. One of: (l, s, linearizable, serializable).")
+
+	return cmd
+}
+
+type encryptionStatus struct {
+	Resource string `json:"resource"`
+	Provider string `json:"provider"`
+	KeyName  string `json:"keyName,omitempty"`
+	Count    int    `json:"count"`
+}
+
+const encryptedValuePrefix = "k8s:enc:"
+
+// parseEncryptedValuePrefix returns the provider and key name of the value,
+// the values are prefixed with k8s:enc:<provider>:<version>:<key name>: when encrypted.
+func parseEncryptedValuePrefix(value []byte) (provider, keyName string) {
+	if !bytes.HasPrefix(value, []byte(encryptedValuePrefix)) {
+		return "identity", ""
+	}
+	parts := bytes.SplitN(value[len(encryptedValuePrefix):], []byte(":"), 4)
+	if len(parts) < 4 {
+		return "unknown", ""
+	}
+	return string(parts[0]) + "/" + string(parts[1]), string(parts[2])
+}
+
+func encryptStatusCommand(ctx context.Context, etcdclient client.Client, flags *encryptStatusFlagpole, args []string) error {
+	var targetGr schema.GroupResource
+	if len(args) != 0 {
+		gr := schema.ParseGroupResource(args[0])
+		if gr.Empty() {
+			return fmt.Errorf("invalid resource %q", args[0])
+		}
+		if correctGr, _, found := wellknown.CorrectGroupResource(gr); found {
+			gr = correctGr
+		}
+		targetGr = gr
+	}
+
+	consistencyOpts, err := consistencyOpOptions(flags.Consistency, false)
+	if err != nil {
+		return err
+	}
+
+	counts := map[encryptionStatus]int{}
+	_, err = etcdclient.Get(ctx, flags.Prefix,
+		append(consistencyOpts,
+			client.WithGR(targetGr),
+			client.WithPageLimit(flags.ChunkSize),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				gr, _, ok := groupResourceFromKey(flags.Prefix, string(kv.Key))
+				if !ok {
+					return nil
+				}
+				provider, keyName := parseEncryptedValuePrefix(kv.Value)
+				counts[encryptionStatus{
+					Resource: gr.String(),
+					Provider: provider,
+					KeyName:  keyName,
+				}]++
+				return nil
+			}),
+		)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	statuses := make([]encryptionStatus, 0, len(counts))
+	for status, count := range counts {
+		status.Count = count
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		a, b := statuses[i], statuses[j]
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.KeyName < b.KeyName
+	})
+
+	switch flags.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(statuses)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "RESOURCE\tPROVIDER\tKEY\tCOUNT")
+		for _, status := range statuses {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", status.Resource, status.Provider, status.KeyName, status.Count)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/server/options/encryptionconfig"
+	"k8s.io/apiserver/pkg/storage/value"
+)
+
+// writeEncryptionConfig writes the encryption configuration of the configmaps with aescbc providers of the keys in order.
+func writeEncryptionConfig(t *testing.T, keys ...string) string {
+	t.Helper()
+	secrets := map[string]string{
+		// base64 of 32 bytes each
+		"key1": "MTIzNDU2Nzg5MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTI=",
+		"key2": "YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXphYmNkZWY=",
+	}
+	config := "apiVersion: apiserver.config.k8s.io/v1\nkind: EncryptionConfiguration\nresources:\n- resources:\n  - configmaps\n  providers:\n"
+	for _, key := range keys {
+		config += "  - aescbc:\n      keys:\n      - name: " + key + "\n        secret: " + secrets[key] + "\n"
+	}
+	path := filepath.Join(t.TempDir(), "encryption.yaml")
+	err := os.WriteFile(path, []byte(config), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncryptedGroupResources(t *testing.T) {
+	configmaps := schema.GroupResource{Resource: "configmaps"}
+	secrets := schema.GroupResource{Resource: "secrets"}
+	deployments := schema.GroupResource{Group: "apps", Resource: "deployments"}
+
+	tests := []struct {
+		name     string
+		grs      []schema.GroupResource
+		want     []schema.GroupResource
+		contains []schema.GroupResource
+		excludes []schema.GroupResource
+	}{
+		{
+			name: "resources",
+			grs:  []schema.GroupResource{secrets, configmaps},
+			want: []schema.GroupResource{configmaps, secrets},
+		},
+		{
+			name:     "group wildcard",
+			grs:      []schema.GroupResource{{Group: "apps", Resource: "*"}},
+			contains: []schema.GroupResource{deployments, {Group: "apps", Resource: "statefulsets"}},
+			excludes: []schema.GroupResource{configmaps, secrets},
+		},
+		{
+			name:     "wildcard",
+			grs:      []schema.GroupResource{{Group: "*", Resource: "*"}},
+			contains: []schema.GroupResource{configmaps, secrets, deployments},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformers := map[schema.GroupResource]value.Transformer{}
+			for _, gr := range tt.grs {
+				transformers[gr] = nil
+			}
+			got := encryptedGroupResources(transformers)
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encryptedGroupResources() = %v, want %v", got, tt.want)
+			}
+			for _, gr := range tt.contains {
+				if !slices.Contains(got, gr) {
+					t.Errorf("encryptedGroupResources() = %v, want it to contain %v", got, gr)
+				}
+			}
+			for _, gr := range tt.excludes {
+				if slices.Contains(got, gr) {
+					t.Errorf("encryptedGroupResources() = %v, want it not to contain %v", got, gr)
+				}
+			}
+			if !slices.IsSortedFunc(got, func(a, b schema.GroupResource) int {
+				return strings.Compare(a.String(), b.String())
+			}) {
+				t.Errorf("encryptedGroupResources() = %v, want it sorted", got)
+			}
+		})
+	}
+
+	// The wildcard of all the groups expands to each well-known resource once
+	got := encryptedGroupResources(map[schema.GroupResource]value.Transformer{{Group: "*", Resource: "*"}: nil})
+	want := map[schema.GroupResource]struct{}{}
+	for _, gr := range wellknown.GroupResources() {
+		want[gr] = struct{}{}
+	}
+	if len(got) != len(want) {
+		t.Errorf("encryptedGroupResources() of the wildcard = %d resources, want %d", len(got), len(want))
+	}
+}
+
+func TestParseEncryptedValuePrefix(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		wantProvider string
+		wantKeyName  string
+	}{
+		{
+			name:         "identity",
+			value:        "k8s\x00\n\x0f\n\x02v1\x12\tConfigMap",
+			wantProvider: "identity",
+		},
+		{
+			name:         "aescbc",
+			value:        "k8s:enc:aescbc:v1:key1:\x8a\x01\x02",
+			wantProvider: "aescbc/v1",
+			wantKeyName:  "key1",
+		},
+		{
+			name:         "kms",
+			value:        "k8s:enc:kms:v2:vault:\x0a\x01",
+			wantProvider: "kms/v2",
+			wantKeyName:  "vault",
+		},
+		{
+			name:         "truncated",
+			value:        "k8s:enc:aescbc:v1",
+			wantProvider: "unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, keyName := parseEncryptedValuePrefix([]byte(tt.value))
+			if provider != tt.wantProvider || keyName != tt.wantKeyName {
+				t.Errorf("parseEncryptedValuePrefix() = %q, %q, want %q, %q", provider, keyName, tt.wantProvider, tt.wantKeyName)
+			}
+		})
+	}
+}
+
+func TestEncryptRotateCommand(t *testing.T) {
+	ctx := context.Background()
+	etcdclient := fake.NewClient()
+	configmaps := schema.GroupResource{Resource: "configmaps"}
+
+	// The configmaps encrypted by key2
+	config, err := encryptionconfig.LoadEncryptionConfig(ctx, writeEncryptionConfig(t, "key2"), false, "kectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transformer := encryptionconfig.StaticTransformers(config.Transformers).TransformerForResource(configmaps)
+	for _, name := range []string{"a", "b"} {
+		data, err := transformer.TransformToStorage(ctx, []byte(name), value.DefaultContext("/registry/configmaps/default/"+name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = etcdclient.Put(ctx, "/registry", data, client.WithGR(configmaps), client.WithName(name, "default"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = encryptRotateCommand(ctx, etcdclient, &encryptRotateFlagpole{
+		Output:                   "none",
+		Prefix:                   "/registry",
+		EncryptionProviderConfig: writeEncryptionConfig(t, "key1", "key2"),
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := storedValues(t, etcdclient)
+	if len(values) != 2 {
+		t.Errorf("stored keys = %d, want 2", len(values))
+	}
+	for key, data := range values {
+		if !strings.HasPrefix(data, "k8s:enc:aescbc:v1:key1:") {
+			t.Errorf("%s is not encrypted by key1: %q", key, data)
+		}
+	}
+}
+
+func TestEncryptRotateCommandHashedKeys(t *testing.T) {
+	ctx := context.Background()
+	etcdclient, raw := newHashedKeysClient(t)
+	configmaps := schema.GroupResource{Resource: "configmaps"}
+
+	// The configmaps encrypted by key2, as they are authenticated by their keys as stored
+	config, err := encryptionconfig.LoadEncryptionConfig(ctx, writeEncryptionConfig(t, "key2"), false, "kectl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transformer := encryptionconfig.StaticTransformers(config.Transformers).TransformerForResource(configmaps)
+	for _, name := range []string{"a", "b"} {
+		err := etcdclient.Put(ctx, "/registry", []byte(name), client.WithGR(configmaps), client.WithName(name, "default"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for key, data := range storedValues(t, raw) {
+		data, err := transformer.TransformToStorage(ctx, []byte(data), value.DefaultContext(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = raw.Put(ctx, "/registry", data, client.WithKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = encryptRotateCommand(ctx, etcdclient, &encryptRotateFlagpole{
+		Output:                   "none",
+		Prefix:                   "/registry",
+		EncryptionProviderConfig: writeEncryptionConfig(t, "key1", "key2"),
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The keys listed are rotated, rather than the keys of their hashes hashed again
+	values := storedValues(t, raw)
+	if len(values) != 2 {
+		t.Errorf("stored keys = %d, want 2", len(values))
+	}
+	for key, data := range values {
+		if !strings.HasPrefix(data, "k8s:enc:aescbc:v1:key1:") {
+			t.Errorf("%s is not encrypted by key1: %q", key, data)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.etcd.io/etcd/client/pkg/v3/srv"
+)
+
+// srvScheme is the scheme of the endpoints resolved from the DNS SRV records of a domain,
+// such as dns+srv://example.com or dns+srv://example.com/name for the service name.
+const srvScheme = "dns+srv://"
+
+// expandEndpoints resolves the dns+srv endpoints to the members and normalizes the others,
+// it returns the domain of the SRV records for the verification of the server name.
+func expandEndpoints(eps []string, insecureDiscovery bool) ([]string, string, error) {
+	var (
+		ret    []string
+		domain string
+	)
+	for _, ep := range eps {
+		ep = strings.TrimSpace(ep)
+		if rest, ok := strings.CutPrefix(ep, srvScheme); ok {
+			d, serviceName, _ := strings.Cut(rest, "/")
+			if d == "" {
+				return nil, "", fmt.Errorf("invalid endpoint %q, missing domain", ep)
+			}
+			members, err := srvEndpoints(d, serviceName, insecureDiscovery)
+			if err != nil {
+				return nil, "", err
+			}
+			ret = append(ret, members...)
+			domain = d
+			continue
+		}
+		ep, err := normalizeEndpoint(ep)
+		if err != nil {
+			return nil, "", err
+		}
+		ret = append(ret, ep)
+	}
+	return ret, domain, nil
+}
+
+// srvEndpoints returns the client endpoints of the members published in the SRV records of the domain,
+// the insecure ones are dropped unless insecure is true.
+func srvEndpoints(domain, serviceName string, insecure bool) ([]string, error) {
+	srvs, err := srv.GetClient("etcd-client", domain, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	eps := srvs.Endpoints
+	if insecure {
+		return eps, nil
+	}
+	// strip insecure connections
+	ret := []string{}
+	for _, ep := range eps {
+		if strings.HasPrefix(ep, "http://") {
+			fmt.Fprintf(os.Stderr, "ignoring discovered insecure endpoint %q\n", ep)
+			continue
+		}
+		ret = append(ret, ep)
+	}
+	return ret, nil
+}
+
+// normalizeEndpoint checks the host of the etcd endpoints and adds the default client port if missing.
+// IPv6 literals are bracketed, a bare literal such as ::1 is taken as a host without port.
+func normalizeEndpoint(ep string) (string, error) {
+	scheme, hostport, ok := strings.Cut(ep, "://")
+	if !ok {
+		scheme, hostport = "", ep
+	}
+	switch scheme {
+	case "", "etcd", "http", "https":
+	default:
+		// The unix sockets and the other backends take paths
+		return ep, nil
+	}
+
+	var u *url.URL
+	if scheme != "" {
+		var err error
+		u, err = url.Parse(ep)
+		if err != nil {
+			return "", fmt.Errorf("invalid endpoint %q: %w", ep, err)
+		}
+		hostport = u.Host
+	}
+
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+		if literal, ok := strings.CutPrefix(hostport, "["); ok {
+			host, ok = strings.CutSuffix(literal, "]")
+			if !ok || net.ParseIP(host) == nil {
+				return "", fmt.Errorf("invalid endpoint %q: %w", ep, err)
+			}
+		} else if strings.Contains(hostport, ":") && (u != nil || net.ParseIP(hostport) == nil) {
+			return "", fmt.Errorf("invalid endpoint %q, IPv6 literals are bracketed like [::1]:2379: %w", ep, err)
+		}
+		port = strconv.Itoa(etcdClientPort)
+	}
+	if host == "" {
+		return "", fmt.Errorf("invalid endpoint %q, missing host", ep)
+	}
+
+	hostport = net.JoinHostPort(host, port)
+	if u == nil {
+		return hostport, nil
+	}
+	u.Host = hostport
+	return u.String(), nil
+}
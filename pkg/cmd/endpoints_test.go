@@ -0,0 +1,70 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+)
+
+func TestNormalizeEndpoint(t *testing.T) {
+	tests := []struct {
+		ep      string
+		want    string
+		wantErr bool
+	}{
+		{ep: "127.0.0.1:2379", want: "127.0.0.1:2379"},
+		{ep: "127.0.0.1", want: "127.0.0.1:2379"},
+		{ep: "etcd.example.com", want: "etcd.example.com:2379"},
+		{ep: "[::1]:2379", want: "[::1]:2379"},
+		{ep: "[fd00::1]:32379", want: "[fd00::1]:32379"},
+		{ep: "::1", want: "[::1]:2379"},
+		{ep: "https://[fd00::1]:2379", want: "https://[fd00::1]:2379"},
+		{ep: "https://[fd00::1]", want: "https://[fd00::1]:2379"},
+		{ep: "http://127.0.0.1:2379", want: "http://127.0.0.1:2379"},
+		{ep: "unix:///run/kine.sock", want: "unix:///run/kine.sock"},
+		{ep: "file:///var/lib/etcd/member/snap/db?mode=ro", want: "file:///var/lib/etcd/member/snap/db?mode=ro"},
+		{ep: "[::1", wantErr: true},
+		{ep: "https://::1:2379", wantErr: true},
+		{ep: ":2379", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ep, func(t *testing.T) {
+			got, err := normalizeEndpoint(tt.ep)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeEndpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeEndpoint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandEndpoints(t *testing.T) {
+	_, _, err := expandEndpoints([]string{"dns+srv://"}, true)
+	if err == nil {
+		t.Errorf("expandEndpoints() expected an error for a missing domain")
+	}
+
+	got, domain, err := expandEndpoints([]string{" 10.0.0.1", "[fd00::2]:2379"}, true)
+	if err != nil {
+		t.Fatalf("expandEndpoints() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != "10.0.0.1:2379" || got[1] != "[fd00::2]:2379" || domain != "" {
+		t.Errorf("expandEndpoints() = %v, %q", got, domain)
+	}
+}
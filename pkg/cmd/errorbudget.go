@@ -0,0 +1,100 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The classes of the errors of writing a document.
+const (
+	errorClassDecode     = "decode"
+	errorClassConflict   = "conflict"
+	errorClassConnection = "connection"
+	errorClassOther      = "other"
+)
+
+var errorClasses = []string{errorClassDecode, errorClassConflict, errorClassConnection, errorClassOther}
+
+// classifyWriteError returns the class of the error returned by writing to the backend.
+func classifyWriteError(err error) string {
+	if errors.Is(err, client.ErrConflict) {
+		return errorClassConflict
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errorClassConnection
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return errorClassConnection
+	}
+	return errorClassOther
+}
+
+// errorBudget counts the errors of the documents by class,
+// so that the rest of the documents are still written until the budget is exceeded.
+type errorBudget struct {
+	// max is the number of errors tolerated, 0 is unlimited.
+	max      int
+	failFast bool
+	counts   map[string]int
+	total    int
+}
+
+func newErrorBudget(max int, failFast bool) *errorBudget {
+	return &errorBudget{
+		max:      max,
+		failFast: failFast,
+		counts:   map[string]int{},
+	}
+}
+
+// add reports the error and returns an error once the budget is exceeded.
+func (b *errorBudget) add(class string, err error) error {
+	b.counts[class]++
+	b.total++
+	fmt.Fprintf(os.Stderr, "error: %s: %v\n", class, err)
+	if b.failFast {
+		return err
+	}
+	if b.max > 0 && b.total > b.max {
+		return fmt.Errorf("more than %d errors: %w", b.max, err)
+	}
+	return nil
+}
+
+// summary returns the counts of the errors by class, or nil if there is none.
+func (b *errorBudget) summary() error {
+	if b.total == 0 {
+		return nil
+	}
+	s := make([]string, 0, len(errorClasses))
+	for _, class := range errorClasses {
+		if n := b.counts[class]; n != 0 {
+			s = append(s, fmt.Sprintf("%s %d", class, n))
+		}
+	}
+	return fmt.Errorf("%d errors: %s", b.total, strings.Join(s, ", "))
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyWriteError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "conflict",
+			err:  fmt.Errorf("key: %w", client.ErrConflict),
+			want: errorClassConflict,
+		},
+		{
+			name: "unavailable",
+			err:  status.Error(codes.Unavailable, "connection refused"),
+			want: errorClassConnection,
+		},
+		{
+			name: "deadline",
+			err:  fmt.Errorf("put: %w", context.DeadlineExceeded),
+			want: errorClassConnection,
+		},
+		{
+			name: "other",
+			err:  errors.New("etcdserver: request is too large"),
+			want: errorClassOther,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyWriteError(tt.err); got != tt.want {
+				t.Errorf("classifyWriteError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
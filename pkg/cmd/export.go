@@ -0,0 +1,397 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	// The pure Go driver of SQLite, for kectl to build without cgo
+	_ "modernc.org/sqlite"
+)
+
+func newCtlExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Exports a recording to other tools",
+	}
+	cmd.AddCommand(
+		newCtlExportSQLiteCommand(),
+	)
+	return cmd
+}
+
+type exportSQLiteFlagpole struct {
+	Path   string
+	Output string
+	Prefix string
+}
+
+func newCtlExportSQLiteCommand() *cobra.Command {
+	flags := &exportSQLiteFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Use:   "sqlite",
+		Short: "Exports the events and the objects of a recording to a SQLite database",
+		Long: "Exports a recording, - for stdin, to a SQLite database of the tables events, objects and patches, for it to be queried in SQL.\n" +
+			"An event is each document of an object of the recording, with its time, revision, method, resource, namespace, name and writer,\n" +
+			"the objects are the versions of the objects as of the events, in JSON for the json functions of SQLite, the last one of a delete,\n" +
+			"and the patches are the ones of the updates from the version before them, as written by kwok or as computed for a recording of kectl.\n" +
+			"The time of an event of a recording of kectl is the one of the bookmark before it, a kwok recording only tells the elapsed time of its changes.\n" +
+			"The objects the recording starts with are the events of the method object. The database is written to a temporary file renamed over --output.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := exportSQLiteCommand(flags)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", flags.Path, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Path, "path", "-", "path of the recording, - for stdin, or of an object such as s3://bucket/key, gcs://bucket/key or azblob://container/key")
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "", "path of the SQLite database to write")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+
+	return cmd
+}
+
+// exportSQLiteSchema is the schema of the database of a recording.
+const exportSQLiteSchema = `
+CREATE TABLE events (
+	id INTEGER PRIMARY KEY,
+	time TEXT,
+	elapsed INTEGER NOT NULL,
+	revision INTEGER,
+	method TEXT NOT NULL,
+	resource TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	name TEXT NOT NULL,
+	writer TEXT
+);
+CREATE INDEX events_object ON events (resource, namespace, name);
+CREATE TABLE objects (
+	event_id INTEGER PRIMARY KEY REFERENCES events (id),
+	object TEXT NOT NULL
+);
+CREATE TABLE patches (
+	event_id INTEGER PRIMARY KEY REFERENCES events (id),
+	patch TEXT NOT NULL,
+	size INTEGER NOT NULL
+);
+`
+
+// sqliteEvent is a row of the events, with its object and its patch, nil for none.
+type sqliteEvent struct {
+	// Time is the time of the event, zero if the recording does not tell it, and Elapsed the time since the start
+	Time      time.Time
+	Elapsed   time.Duration
+	Revision  int64
+	Method    string
+	Resource  string
+	Namespace string
+	Name      string
+	Writer    string
+	Object    []byte
+	Patch     []byte
+}
+
+// recordingSQLiteExport turns the documents of a recording into the events as they are scanned.
+type recordingSQLiteExport struct {
+	prefix string
+	jsonl  bool
+	write  func(e *sqliteEvent) error
+
+	recordingClock
+	// state is the last version of the objects of a recording of kectl, and objects the ones of a kwok recording
+	state   map[string][]byte
+	objects kwokObjects
+}
+
+func newRecordingSQLiteExport(prefix string, jsonl bool, write func(e *sqliteEvent) error) *recordingSQLiteExport {
+	return &recordingSQLiteExport{
+		prefix:  prefix,
+		jsonl:   jsonl,
+		write:   write,
+		state:   map[string][]byte{},
+		objects: kwokObjects{},
+	}
+}
+
+func (e *recordingSQLiteExport) add(doc *recordingRawDocument) error {
+	switch {
+	case doc.Header, doc.Bookmark != nil:
+		e.tick(doc)
+	case doc.Key != "":
+		return e.key(doc)
+	case doc.Object != nil:
+		if isKwokResourcePatch(doc.Object) {
+			return e.resourcePatch(doc.Object)
+		}
+		return e.object(doc.Object)
+	}
+	return nil
+}
+
+func (e *recordingSQLiteExport) key(doc *recordingRawDocument) error {
+	gr, rest, ok := groupResourceFromKey(e.prefix, doc.Key)
+	if !ok {
+		return fmt.Errorf("key %q is not under %q", doc.Key, e.prefix)
+	}
+	namespace, name, err := splitName(rest)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", doc.Key, err)
+	}
+	object, err := keyDocumentObject(doc, e.jsonl)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", doc.Key, err)
+	}
+
+	event := &sqliteEvent{
+		Elapsed:   e.elapsed(),
+		Revision:  doc.Revision,
+		Method:    e.method(doc),
+		Resource:  gr.String(),
+		Namespace: namespace,
+		Name:      name,
+	}
+	if !e.at.IsZero() {
+		event.Time = e.at
+	}
+	before := e.state[doc.Key]
+	if object != nil {
+		obj := &unstructured.Unstructured{Object: object}
+		event.Object, err = obj.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("key %q: %w", doc.Key, err)
+		}
+		if !doc.Deleted {
+			event.Writer = latestManager(obj)
+		}
+	}
+	if doc.Deleted {
+		delete(e.state, doc.Key)
+		if event.Object == nil {
+			event.Object = before
+		}
+	} else if event.Object != nil {
+		e.state[doc.Key] = event.Object
+	}
+	if event.Method == "update" && before != nil && event.Object != nil {
+		event.Patch, err = twoWayPatch(before, event.Object)
+		if err != nil {
+			return fmt.Errorf("patch %q: %w", doc.Key, err)
+		}
+	}
+	return e.write(event)
+}
+
+// object exports an object a kwok recording starts with.
+func (e *recordingSQLiteExport) object(obj *unstructured.Unstructured) error {
+	ref, _, err := snapshotObjectRefOf(obj)
+	if err != nil {
+		return err
+	}
+	e.objects[ref] = obj
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return e.write(&sqliteEvent{
+		Method:    "object",
+		Resource:  ref.gr.String(),
+		Namespace: ref.namespace,
+		Name:      ref.name,
+		Writer:    latestManager(obj),
+		Object:    data,
+	})
+}
+
+// resourcePatch exports a change of a kwok recording, with the object as of it if the objects before it are known.
+func (e *recordingSQLiteExport) resourcePatch(obj *unstructured.Unstructured) error {
+	rp, err := decodeKwokResourcePatch(obj)
+	if err != nil {
+		return err
+	}
+	event := &sqliteEvent{
+		Elapsed:   rp.DurationNanosecond,
+		Method:    kwokPatchMethod(rp),
+		Resource:  rp.Resource.GroupResource().String(),
+		Namespace: rp.Target.Namespace,
+		Name:      rp.Target.Name,
+	}
+	if rp.Method == actionv1alpha1.PatchMethodPatch && !rp.Checkpoint {
+		event.Patch = rp.Template
+	}
+	after, err := e.objects.apply(rp)
+	if err != nil {
+		return err
+	}
+	if after != nil {
+		event.Object, err = after.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if rp.Method != actionv1alpha1.PatchMethodDelete {
+			event.Writer = latestManager(after)
+		}
+	}
+	return e.write(event)
+}
+
+// sqliteNull returns nil for the zero value, which is written as NULL.
+func sqliteNull[T comparable](v T) any {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
+
+// writeSQLiteEvents creates the tables in the database and returns the function writing the events into them, in the transaction.
+func writeSQLiteEvents(tx *sql.Tx) (func(e *sqliteEvent) error, error) {
+	_, err := tx.Exec(exportSQLiteSchema)
+	if err != nil {
+		return nil, err
+	}
+	events, err := tx.Prepare("INSERT INTO events (time, elapsed, revision, method, resource, namespace, name, writer) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return nil, err
+	}
+	objects, err := tx.Prepare("INSERT INTO objects (event_id, object) VALUES (?, ?)")
+	if err != nil {
+		return nil, err
+	}
+	patches, err := tx.Prepare("INSERT INTO patches (event_id, patch, size) VALUES (?, ?, ?)")
+	if err != nil {
+		return nil, err
+	}
+	return func(e *sqliteEvent) error {
+		var t any
+		if !e.Time.IsZero() {
+			t = e.Time.UTC().Format(time.RFC3339Nano)
+		}
+		result, err := events.Exec(t, int64(e.Elapsed), sqliteNull(e.Revision), e.Method, e.Resource, e.Namespace, e.Name, sqliteNull(e.Writer))
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if e.Object != nil {
+			_, err = objects.Exec(id, string(e.Object))
+			if err != nil {
+				return err
+			}
+		}
+		if e.Patch != nil {
+			_, err = patches.Exec(id, string(e.Patch), len(e.Patch))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+func exportSQLiteCommand(flags *exportSQLiteFlagpole) (err error) {
+	if flags.Output == "" {
+		return fmt.Errorf("--output is required")
+	}
+
+	var r io.ReadCloser
+	if flags.Path == "-" {
+		r, err = decompress(os.Stdin)
+	} else {
+		r, err = openRecording(flags.Path)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// The database is written aside, for an interruption to never leave it half written nor mixed with an older one
+	tmp, err := os.CreateTemp(filepath.Dir(flags.Output), filepath.Base(flags.Output)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_ = tmp.Close()
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	count, err := exportSQLite(r, tmp.Name(), flags.Prefix)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp.Name(), flags.Output)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "export %d events to %s\n", count, flags.Output)
+	return nil
+}
+
+// exportSQLite writes the events of the recording into the database at the path, and returns the number of them.
+func exportSQLite(r io.Reader, path, prefix string) (count int, err error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := db.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	write, err := writeSQLiteEvents(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := newRecordingScanner(r, prefix)
+	e := newRecordingSQLiteExport(prefix, scanner.JSONL, func(event *sqliteEvent) error {
+		count++
+		return write(event)
+	})
+	err = scanner.Scan(e.add)
+	if err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
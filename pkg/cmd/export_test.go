@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportSQLite(t *testing.T) {
+	tests := []struct {
+		name      string
+		recording string
+		want      []string
+	}{
+		{
+			name: "kectl",
+			recording: `---
+# /registry/pods/default/a | application/json | 2
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+spec:
+  nodeName: ""
+---
+# bookmark | 3 | 2024-01-01T00:00:30Z
+---
+# /registry/pods/default/a | application/json | 4
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+  managedFields:
+  - manager: kube-scheduler
+    operation: Update
+    time: "2024-01-01T00:00:31Z"
+spec:
+  nodeName: node-1
+---
+# bookmark | 5 | 2024-01-01T00:01:00Z
+---
+# /registry/pods/default/a | 6 | deleted
+`,
+			want: []string{
+				`|0|2|object|pods|default|a||{"nodeName":""}|`,
+				`2024-01-01T00:00:30Z|0|4|update|pods|default|a|kube-scheduler|{"nodeName":"node-1"}|{"metadata":{"managedFields":[{"manager":"kube-scheduler","operation":"Update","time":"2024-01-01T00:00:31Z"}]},"spec":{"nodeName":"node-1"}}`,
+				`2024-01-01T00:01:00Z|30000000000|6|delete|pods|default|a||{"nodeName":"node-1"}|`,
+			},
+		},
+		{
+			name: "kwok",
+			recording: `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  replicas: 1
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  group: apps
+  version: v1
+  resource: deployments
+target:
+  name: web
+  namespace: default
+durationNanosecond: 1000
+method: patch
+template:
+  spec:
+    replicas: 3
+`,
+			want: []string{
+				`|0||object|deployments.apps|default|web||{"replicas":1}|`,
+				`|1000||patch|deployments.apps|default|web||{"replicas":3}|{"spec":{"replicas":3}}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "recording.db")
+			count, err := exportSQLite(strings.NewReader(tt.recording), path, "/registry")
+			if err != nil {
+				t.Fatalf("exportSQLite() error = %v", err)
+			}
+			if count != len(tt.want) {
+				t.Errorf("exportSQLite() = %d events, want %d", count, len(tt.want))
+			}
+
+			db, err := sql.Open("sqlite", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			rows, err := db.Query(`SELECT coalesce(e.time, ''), e.elapsed, coalesce(e.revision, ''), e.method, e.resource, e.namespace, e.name,
+	coalesce(e.writer, ''), coalesce(json_extract(o.object, '$.spec'), ''), coalesce(p.patch, '')
+FROM events e LEFT JOIN objects o ON o.event_id = e.id LEFT JOIN patches p ON p.event_id = e.id ORDER BY e.id`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var got []string
+			for rows.Next() {
+				fields := make([]string, 10)
+				values := make([]any, len(fields))
+				for i := range fields {
+					values[i] = &fields[i]
+				}
+				err = rows.Scan(values...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, strings.Join(fields, "|"))
+			}
+			if err = rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+
+	// The client side health checking of endpointServiceConfig
+	_ "google.golang.org/grpc/health"
+)
+
+// endpointServiceConfig spreads the requests over the endpoints in turn, leaving out the members reported
+// as not serving by the gRPC health service of etcd, such as a member shutting down during a rolling upgrade.
+// The members without the health service are taken as serving.
+const endpointServiceConfig = `{"loadBalancingPolicy":"round_robin","healthCheckConfig":{"serviceName":""}}`
+
+// endpointUnhealthyFailures is the number of requests in a row failing on an endpoint before it is reported unhealthy.
+const endpointUnhealthyFailures = 3
+
+// endpointHealth tracks the requests by endpoint, and reports the endpoints becoming unhealthy and healthy again.
+type endpointHealth struct {
+	w io.Writer
+
+	mut sync.Mutex
+	// failures is the number of requests in a row failing by endpoint
+	failures map[string]int
+}
+
+func newEndpointHealth(w io.Writer) *endpointHealth {
+	return &endpointHealth{
+		w:        w,
+		failures: map[string]int{},
+	}
+}
+
+// unaryInterceptor records the outcome of each attempt of the requests on the endpoint it was sent to.
+// It runs inside the retry interceptor, so the retries sent to the other endpoints are recorded on their own.
+func (h *endpointHealth) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var p peer.Peer
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(&p))...)
+		// The request never reached an endpoint
+		if p.Addr == nil {
+			return err
+		}
+		// An attempt timing out counts against the endpoint, unlike the request being canceled
+		failed := err != nil && !errors.Is(ctx.Err(), context.Canceled) && (isTransientError(err) || ctx.Err() != nil)
+		h.record(p.Addr.String(), failed, err)
+		return err
+	}
+}
+
+func (h *endpointHealth) record(endpoint string, failed bool, err error) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	failures := h.failures[endpoint]
+	if !failed {
+		if failures >= endpointUnhealthyFailures {
+			fmt.Fprintf(h.w, "endpoint %s is healthy again\n", endpoint)
+		}
+		delete(h.failures, endpoint)
+		return
+	}
+
+	failures++
+	h.failures[endpoint] = failures
+	if failures == endpointUnhealthyFailures {
+		fmt.Fprintf(h.w, "warning: endpoint %s is unhealthy after %d failed requests in a row: %v\n", endpoint, failures, err)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type countingKVServer struct {
+	etcdserverpb.UnimplementedKVServer
+	ranges atomic.Int64
+}
+
+func (s *countingKVServer) Range(ctx context.Context, req *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	s.ranges.Add(1)
+	return &etcdserverpb.RangeResponse{Header: &etcdserverpb.ResponseHeader{Revision: 1}}, nil
+}
+
+// startMember starts a gRPC server with the KV and health services of etcd.
+func startMember(t *testing.T) (string, *countingKVServer, *health.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	kv := &countingKVServer{}
+	hs := health.NewServer()
+	etcdserverpb.RegisterKVServer(srv, kv)
+	healthpb.RegisterHealthServer(srv, hs)
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String(), kv, hs
+}
+
+func TestEndpointFailover(t *testing.T) {
+	addr1, kv1, hs1 := startMember(t)
+	addr2, kv2, _ := startMember(t)
+
+	// The first member is shutting down, as in a rolling upgrade
+	hs1.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	cfg, err := newClientCfg([]string{addr1, addr2}, time.Second, nil, &secureCfg{insecureTransport: true}, nil, &retryCfg{
+		maxRetries: 1,
+		backoff:    time.Millisecond,
+		maxBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := clientv3.New(*cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		_, err = cli.Get(ctx, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := kv1.ranges.Load(); got != 0 {
+		t.Errorf("not serving member got %d requests, want 0", got)
+	}
+	if got := kv2.ranges.Load(); got != 10 {
+		t.Errorf("serving member got %d requests, want 10", got)
+	}
+
+	// Once back, the requests are spread over both members
+	hs1.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	deadline := time.Now().Add(5 * time.Second)
+	for kv1.ranges.Load() == 0 && time.Now().Before(deadline) {
+		_, err = cli.Get(ctx, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if kv1.ranges.Load() == 0 {
+		t.Errorf("member back to serving got no requests")
+	}
+}
+
+func TestEndpointHealthRecord(t *testing.T) {
+	var buf bytes.Buffer
+	h := newEndpointHealth(&buf)
+
+	for i := 0; i < endpointUnhealthyFailures+1; i++ {
+		h.record("10.0.0.1:2379", true, rpctypes.ErrNoLeader)
+	}
+	h.record("10.0.0.2:2379", true, rpctypes.ErrNoLeader)
+	h.record("10.0.0.2:2379", false, nil)
+	h.record("10.0.0.1:2379", false, nil)
+
+	want := []string{
+		"warning: endpoint 10.0.0.1:2379 is unhealthy after 3 failed requests in a row: etcdserver: no leader",
+		"endpoint 10.0.0.1:2379 is healthy again",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("record() reported:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
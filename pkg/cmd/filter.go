@@ -0,0 +1,196 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/scheme"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// objectFilter selects the objects by their key first, and only decodes the values of those left for the label selector.
+type objectFilter struct {
+	prefix    string
+	include   map[schema.GroupResource]struct{}
+	exclude   map[schema.GroupResource]struct{}
+	namespace string
+	selector  labels.Selector
+
+	// selected are the keys of the objects matching the selector, for their deletions to be told apart,
+	// only tracked when watching since a deleted value has no labels left.
+	mut      sync.Mutex
+	selected map[string]struct{}
+}
+
+// newObjectFilter returns the filter of the resources, namespace and label selector, or nil if there is nothing to filter.
+func newObjectFilter(prefix string, include, exclude []string, namespace, selector string, track bool) (*objectFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 && namespace == "" && selector == "" {
+		return nil, nil
+	}
+	f := &objectFilter{
+		prefix:    prefix,
+		namespace: namespace,
+	}
+	var err error
+	f.include, err = parseResourceSet(include)
+	if err != nil {
+		return nil, err
+	}
+	f.exclude, err = parseResourceSet(exclude)
+	if err != nil {
+		return nil, err
+	}
+	if selector != "" {
+		f.selector, err = labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+		}
+		if track {
+			f.selected = map[string]struct{}{}
+		}
+	}
+	return f, nil
+}
+
+func parseResourceSet(resources []string) (map[schema.GroupResource]struct{}, error) {
+	if len(resources) == 0 {
+		return nil, nil
+	}
+	set := map[schema.GroupResource]struct{}{}
+	for _, r := range resources {
+		gr, err := parseStorageGroupResource(r)
+		if err != nil {
+			return nil, err
+		}
+		set[gr] = struct{}{}
+	}
+	return set, nil
+}
+
+// parseStorageGroupResource parses the resource as the one its keys are told apart by.
+func parseStorageGroupResource(r string) (schema.GroupResource, error) {
+	gr := schema.ParseGroupResource(r)
+	if gr.Empty() {
+		return gr, fmt.Errorf("invalid resource %q", r)
+	}
+	if correctGr, _, found := wellknown.CorrectGroupResource(gr); found {
+		gr = correctGr
+	}
+	// The keys of the resources sharing the same storage, such as events, are told apart by the storage alone
+	if p, err := client.PrefixFromGR(gr); err == nil {
+		if storageGr, ok := storagePrefixes[p]; ok {
+			gr = storageGr
+		}
+	}
+	return gr, nil
+}
+
+// match returns whether the object is selected. An update taking an object out of the selector
+// is still matched, for the output to end at its last state rather than at the one before.
+func (f *objectFilter) match(kv *client.KeyValue) bool {
+	gr, rest, ok := groupResourceFromKey(f.prefix, string(kv.Key))
+	if !ok {
+		return false
+	}
+	if f.include != nil {
+		if _, ok := f.include[gr]; !ok {
+			return false
+		}
+	}
+	if _, ok := f.exclude[gr]; ok {
+		return false
+	}
+	if f.namespace != "" {
+		namespace, _, err := splitName(rest)
+		if err != nil || namespace != f.namespace {
+			return false
+		}
+	}
+	if f.selector == nil {
+		return true
+	}
+
+	key := string(kv.Key)
+	if len(kv.Value) == 0 {
+		if f.selected == nil {
+			return false
+		}
+		f.mut.Lock()
+		defer f.mut.Unlock()
+		_, ok := f.selected[key]
+		delete(f.selected, key)
+		return ok
+	}
+
+	matched := f.selector.Matches(labels.Set(labelsOf(kv.Value)))
+	if f.selected == nil {
+		return matched
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if matched {
+		f.selected[key] = struct{}{}
+		return true
+	}
+	_, ok = f.selected[key]
+	delete(f.selected, key)
+	return ok
+}
+
+// labelsOf returns the labels of the stored object, none if it cannot be decoded such as when it is encrypted.
+func labelsOf(value []byte) map[string]string {
+	inMediaType, data, err := encoding.DetectAndExtract(value)
+	if err != nil {
+		return nil
+	}
+	if inMediaType != encoding.JsonMediaType {
+		data, _, err = encoding.Convert(scheme.Codecs, inMediaType, encoding.JsonMediaType, value)
+		if err != nil {
+			return nil
+		}
+	}
+	var obj struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	err = json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil
+	}
+	return obj.Metadata.Labels
+}
+
+// wrap returns the response only called for the selected objects.
+func (f *objectFilter) wrap(response func(kv *client.KeyValue) error) func(kv *client.KeyValue) error {
+	if f == nil {
+		return response
+	}
+	return func(kv *client.KeyValue) error {
+		if !f.match(kv) {
+			return nil
+		}
+		return response(kv)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+func TestObjectFilter(t *testing.T) {
+	web := `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"a","labels":{"app":"web"}}}`
+	db := `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"a","labels":{"app":"db"}}}`
+
+	type event struct {
+		key   string
+		value string
+		want  bool
+	}
+	tests := []struct {
+		name      string
+		include   []string
+		exclude   []string
+		namespace string
+		selector  string
+		events    []event
+	}{
+		{
+			name:    "include",
+			include: []string{"po", "nodes"},
+			events: []event{
+				{key: "/registry/pods/default/a", value: web, want: true},
+				{key: "/registry/minions/node0", value: "{}", want: true},
+				{key: "/registry/configmaps/default/b", value: "{}", want: false},
+			},
+		},
+		{
+			name:    "exclude",
+			exclude: []string{"events"},
+			events: []event{
+				{key: "/registry/pods/default/a", value: web, want: true},
+				{key: "/registry/events/default/e", value: "{}", want: false},
+			},
+		},
+		{
+			name:      "namespace",
+			namespace: "kube-system",
+			events: []event{
+				{key: "/registry/pods/kube-system/a", value: web, want: true},
+				{key: "/registry/pods/default/a", value: web, want: false},
+				{key: "/registry/minions/node0", value: "{}", want: false},
+			},
+		},
+		{
+			name:     "selector",
+			selector: "app=web",
+			events: []event{
+				{key: "/registry/pods/default/a", value: web, want: true},
+				{key: "/registry/pods/default/b", value: db, want: false},
+				{key: "/registry/pods/default/b", value: "", want: false},
+				// Leaving the selector is the last state written
+				{key: "/registry/pods/default/a", value: db, want: true},
+				{key: "/registry/pods/default/a", value: db, want: false},
+				{key: "/registry/pods/default/a", value: web, want: true},
+				{key: "/registry/pods/default/a", value: "", want: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newObjectFilter("/registry", tt.include, tt.exclude, tt.namespace, tt.selector, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, e := range tt.events {
+				got := f.match(&client.KeyValue{Key: []byte(e.key), Value: []byte(e.value)})
+				if got != e.want {
+					t.Errorf("match() of event %d %s = %v, want %v", i, e.key, got, e.want)
+				}
+			}
+		})
+	}
+
+	f, err := newObjectFilter("/registry", nil, nil, "", "", true)
+	if err != nil || f != nil {
+		t.Errorf("newObjectFilter() = %v, %v, want no filter", f, err)
+	}
+	_, err = newObjectFilter("/registry", nil, nil, "", "app in (", true)
+	if err == nil {
+		t.Errorf("newObjectFilter() with an invalid selector did not fail")
+	}
+}
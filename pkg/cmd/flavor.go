@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+)
+
+// k3sServerDir is the data directory of the k3s server.
+var k3sServerDir = "/var/lib/rancher/k3s/server"
+
+// kubeadmPKIDir is the directory of the certificates of the control-plane nodes set up by kubeadm.
+var kubeadmPKIDir = "/etc/kubernetes/pki"
+
+// flavorDefaults returns the defaults of the flags for the flavor of the cluster.
+func flavorDefaults(flavor string) (map[string]string, error) {
+	switch flavor {
+	case "":
+		// The certificates of the apiserver are used by default on the control-plane nodes of kubeadm
+		if _, err := os.Stat(filepath.Join(kubeadmPKIDir, "etcd", "ca.crt")); err != nil {
+			return nil, nil
+		}
+		return kubeadmDefaults(), nil
+	case "kubeadm":
+		return kubeadmDefaults(), nil
+	case "k3s":
+		// k3s runs kine over SQLite unless it is started with the embedded etcd
+		socket := filepath.Join(k3sServerDir, "kine.sock")
+		if _, err := os.Stat(socket); err == nil {
+			return map[string]string{
+				"endpoints": "unix://" + socket,
+				"backend":   "kine",
+			}, nil
+		}
+		tls := filepath.Join(k3sServerDir, "tls", "etcd")
+		return map[string]string{
+			"endpoints":          "https://127.0.0.1:2379",
+			"cert":               filepath.Join(tls, "client.crt"),
+			"key":                filepath.Join(tls, "client.key"),
+			"cacert":             filepath.Join(tls, "server-ca.crt"),
+			"insecure-transport": "false",
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported flavor: %q", flavor)
+	}
+}
+
+func kubeadmDefaults() map[string]string {
+	return map[string]string{
+		"cert":   filepath.Join(kubeadmPKIDir, "apiserver-etcd-client.crt"),
+		"key":    filepath.Join(kubeadmPKIDir, "apiserver-etcd-client.key"),
+		"cacert": filepath.Join(kubeadmPKIDir, "etcd", "ca.crt"),
+	}
+}
+
+// applyFlavor sets the flags not given on the command line to the defaults of the flavor.
+// Without a flavor, the certificates of kubeadm are used if none is given and they are found.
+func applyFlavor(flags *pflag.FlagSet) error {
+	flavor, err := flags.GetString("flavor")
+	if err != nil {
+		return err
+	}
+	defaults, err := flavorDefaults(flavor)
+	if err != nil {
+		return err
+	}
+	if flavor == "" && (flags.Changed("cert") || flags.Changed("key") || flags.Changed("cacert")) {
+		return nil
+	}
+	for name, value := range defaults {
+		if flags.Changed(name) {
+			continue
+		}
+		err = flags.Set(name, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestApplyFlavor(t *testing.T) {
+	dir := t.TempDir()
+	defer func(old string) { k3sServerDir = old }(k3sServerDir)
+	k3sServerDir = dir
+	defer func(old string) { kubeadmPKIDir = old }(kubeadmPKIDir)
+	kubeadmPKIDir = filepath.Join(dir, "pki")
+
+	newFlags := func(args ...string) *pflag.FlagSet {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.String("flavor", "", "")
+		flags.StringSlice("endpoints", []string{"127.0.0.1:2379"}, "")
+		flags.String("backend", "", "")
+		flags.String("cert", "", "")
+		flags.String("key", "", "")
+		flags.String("cacert", "", "")
+		flags.Bool("insecure-transport", true, "")
+		err := flags.Parse(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = applyFlavor(flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return flags
+	}
+
+	flags := newFlags("--flavor=k3s")
+	if got, _ := flags.GetStringSlice("endpoints"); got[0] != "https://127.0.0.1:2379" {
+		t.Errorf("endpoints = %v, want the embedded etcd", got)
+	}
+	if got, _ := flags.GetString("cacert"); got != filepath.Join(dir, "tls", "etcd", "server-ca.crt") {
+		t.Errorf("cacert = %v", got)
+	}
+
+	flags = newFlags("--flavor=k3s", "--cert=my.crt")
+	if got, _ := flags.GetString("cert"); got != "my.crt" {
+		t.Errorf("cert = %v, want the one given", got)
+	}
+
+	err := os.WriteFile(filepath.Join(dir, "kine.sock"), nil, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags = newFlags("--flavor=k3s")
+	if got, _ := flags.GetString("backend"); got != "kine" {
+		t.Errorf("backend = %v, want kine", got)
+	}
+
+	flags = newFlags()
+	if got, _ := flags.GetStringSlice("endpoints"); got[0] != "127.0.0.1:2379" {
+		t.Errorf("endpoints = %v, want the default", got)
+	}
+	if got, _ := flags.GetString("cacert"); got != "" {
+		t.Errorf("cacert = %v, want none without kubeadm", got)
+	}
+
+	err = os.MkdirAll(filepath.Join(kubeadmPKIDir, "etcd"), 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(kubeadmPKIDir, "etcd", "ca.crt"), nil, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags = newFlags()
+	if got, _ := flags.GetString("cert"); got != filepath.Join(kubeadmPKIDir, "apiserver-etcd-client.crt") {
+		t.Errorf("cert = %v, want the one of kubeadm", got)
+	}
+	flags = newFlags("--cacert=my.crt")
+	if got, _ := flags.GetString("cert"); got != "" {
+		t.Errorf("cert = %v, want none when a certificate is given", got)
+	}
+}
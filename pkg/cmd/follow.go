@@ -0,0 +1,292 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type followFlagpole struct {
+	Namespace         string
+	Prefix            string
+	ShowManagedFields bool
+}
+
+func newCtlFollowCommand() *cobra.Command {
+	flags := &followFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.RangeArgs(1, 2),
+		Use:   "follow (resource/name | resource/namespace/name | resource name)",
+		Short: "Follows the changes of a single resource of k8s",
+		Long: "Follows the changes of a single resource of k8s, printing the fields changed by each revision,\n" +
+			"until the resource is deleted.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = followCommand(cmd.Context(), etcdclient, flags, args)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Namespace, "namespace", "n", "", "namespace of resource")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().BoolVar(&flags.ShowManagedFields, "show-managed-fields", false, "also print the changes of metadata.managedFields")
+
+	return cmd
+}
+
+// errFollowDeleted stops the watch once the followed resource is deleted.
+var errFollowDeleted = errors.New("deleted")
+
+func followCommand(ctx context.Context, etcdclient client.Client, flags *followFlagpole, args []string) error {
+	namespace := flags.Namespace
+	if len(args) == 1 && strings.Count(args[0], "/") == 2 {
+		var rest string
+		args[0], rest, _ = strings.Cut(args[0], "/")
+		namespace, rest, _ = strings.Cut(rest, "/")
+		args = []string{args[0], rest}
+	}
+	resource, name, err := parseReference(args)
+	if err != nil {
+		return err
+	}
+
+	gr := schema.ParseGroupResource(resource)
+	if gr.Empty() {
+		return fmt.Errorf("invalid resource %q", resource)
+	}
+	if correctGr, namespaced, found := wellknown.CorrectGroupResource(gr); found {
+		gr = correctGr
+		if !namespaced {
+			namespace = ""
+		} else if namespace == "" {
+			namespace = "default"
+		}
+	}
+
+	object := gr.String() + "/" + name
+	if namespace != "" {
+		object = gr.String() + "/" + namespace + "/" + name
+	}
+	f := &follower{
+		w:                 os.Stdout,
+		now:               time.Now,
+		object:            object,
+		showManagedFields: flags.ShowManagedFields,
+	}
+
+	opOpts := []client.OpOption{
+		client.WithName(name, namespace),
+		client.WithGR(gr),
+	}
+
+	rev, err := etcdclient.Get(ctx, flags.Prefix,
+		append(opOpts, client.WithResponse(f.current))...,
+	)
+	if err != nil {
+		return err
+	}
+	if f.last == nil {
+		fmt.Fprintf(f.w, "%s rev %d %s not found, waiting for it to be created\n", f.timestamp(), rev, f.object)
+	}
+
+	err = etcdclient.Watch(ctx, flags.Prefix,
+		append(opOpts,
+			client.WithRevision(rev+1),
+			client.WithResponse(f.event),
+			client.WithGap(func(gap client.Gap) error {
+				fmt.Fprintf(os.Stderr, "warning: revisions %d-%d have been compacted and are missing from the watch\n", gap.From, gap.To)
+				return nil
+			}),
+		)...,
+	)
+	if err != nil && !errors.Is(err, errFollowDeleted) {
+		return err
+	}
+	return nil
+}
+
+// follower prints the changes of the fields of a resource as a log.
+type follower struct {
+	w                 io.Writer
+	now               func() time.Time
+	object            string
+	showManagedFields bool
+
+	// last is the flattened fields of the last revision, nil if the resource does not exist
+	last map[string]string
+}
+
+func (f *follower) timestamp() string {
+	return f.now().Format(time.RFC3339)
+}
+
+// current prints the resource as it is when the follow starts.
+func (f *follower) current(kv *client.KeyValue) error {
+	fields, err := f.flatten(kv.Value)
+	if err != nil {
+		return err
+	}
+	f.last = fields
+	fmt.Fprintf(f.w, "%s rev %d %s exists with %d fields\n", f.timestamp(), kv.Revision, f.object, len(fields))
+	return nil
+}
+
+// event prints the fields changed by the revision, and stops the watch when the resource is deleted.
+func (f *follower) event(kv *client.KeyValue) error {
+	if len(kv.Value) == 0 {
+		fmt.Fprintf(f.w, "%s rev %d %s deleted\n", f.timestamp(), kv.Revision, f.object)
+		f.last = nil
+		return errFollowDeleted
+	}
+
+	fields, err := f.flatten(kv.Value)
+	if err != nil {
+		return err
+	}
+	action := "modified"
+	if f.last == nil {
+		action = "created"
+	}
+	changes := diffFields(f.last, fields)
+	f.last = fields
+
+	fmt.Fprintf(f.w, "%s rev %d %s %s\n", f.timestamp(), kv.Revision, f.object, action)
+	for _, change := range changes {
+		fmt.Fprintf(f.w, "  %s\n", change)
+	}
+	return nil
+}
+
+func (f *follower) flatten(value []byte) (map[string]string, error) {
+	_, data, err := convertValue(value, encoding.JsonMediaType)
+	if err != nil {
+		return nil, err
+	}
+	var obj any
+	err = json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := obj.(map[string]any); ok && !f.showManagedFields {
+		if metadata, ok := m["metadata"].(map[string]any); ok {
+			delete(metadata, "managedFields")
+		}
+	}
+	fields := map[string]string{}
+	flattenFields(fields, "", obj)
+	return fields, nil
+}
+
+// flattenFields sets the JSON encoded values of the leaves of the object by their paths, such as spec.containers[0].image.
+func flattenFields(fields map[string]string, path string, obj any) {
+	switch v := obj.(type) {
+	case map[string]any:
+		if len(v) == 0 && path != "" {
+			fields[path] = "{}"
+		}
+		for key, value := range v {
+			p := key
+			if path != "" {
+				p = path + "." + key
+			}
+			flattenFields(fields, p, value)
+		}
+	case []any:
+		if len(v) == 0 {
+			fields[path] = "[]"
+		}
+		for i, value := range v {
+			flattenFields(fields, path+"["+strconv.Itoa(i)+"]", value)
+		}
+	default:
+		data, _ := json.Marshal(v)
+		fields[path] = string(data)
+	}
+}
+
+// fieldChange is a change of a flattened field, with its JSON encoded values before and after it,
+// the one before empty for an added field and the one after for a removed one.
+type fieldChange struct {
+	Path string `json:"path"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// String returns the change, + for an added field, - for a removed one and ~ for a changed one.
+func (c fieldChange) String() string {
+	switch {
+	case c.Old == "":
+		return fmt.Sprintf("+ %s: %s", c.Path, c.New)
+	case c.New == "":
+		return fmt.Sprintf("- %s: %s", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, c.Old, c.New)
+	}
+}
+
+// compareFields returns the changes between the flattened fields sorted by path.
+func compareFields(old, new map[string]string) []fieldChange {
+	var changes []fieldChange
+	for path, value := range new {
+		if oldValue := old[path]; oldValue != value {
+			changes = append(changes, fieldChange{Path: path, Old: oldValue, New: value})
+		}
+	}
+	for path, value := range old {
+		if _, ok := new[path]; !ok {
+			changes = append(changes, fieldChange{Path: path, Old: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// diffFields returns the changes between the flattened fields sorted by path,
+// + for the added fields, - for the removed ones and ~ for the changed ones.
+func diffFields(old, new map[string]string) []string {
+	changes := compareFields(old, new)
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	return lines
+}
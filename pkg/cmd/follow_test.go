@@ -0,0 +1,101 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+func TestDiffFields(t *testing.T) {
+	old := map[string]string{}
+	flattenFields(old, "", map[string]any{
+		"spec": map[string]any{
+			"replicas": 1.0,
+			"containers": []any{
+				map[string]any{"image": "nginx:1"},
+			},
+		},
+		"metadata": map[string]any{"labels": map[string]any{"app": "foo"}},
+	})
+	new := map[string]string{}
+	flattenFields(new, "", map[string]any{
+		"spec": map[string]any{
+			"replicas": 2.0,
+			"containers": []any{
+				map[string]any{"image": "nginx:2"},
+			},
+			"paused": true,
+		},
+		"metadata": map[string]any{"labels": map[string]any{}},
+	})
+
+	want := []string{
+		`+ metadata.labels: {}`,
+		`- metadata.labels.app: "foo"`,
+		`~ spec.containers[0].image: "nginx:1" -> "nginx:2"`,
+		`+ spec.paused: true`,
+		`~ spec.replicas: 1 -> 2`,
+	}
+	got := diffFields(old, new)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffFields() = %q, want %q", got, want)
+	}
+}
+
+func TestFollower(t *testing.T) {
+	var buf bytes.Buffer
+	f := &follower{
+		w:      &buf,
+		now:    func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) },
+		object: "configmaps/default/foo",
+	}
+
+	events := []*client.KeyValue{
+		{Revision: 2, Value: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"foo","managedFields":[{"manager":"kubectl"}]},"data":{"a":"1"}}`)},
+		{Revision: 3, Value: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"foo","managedFields":[{"manager":"kubelet"}]},"data":{"a":"2"}}`)},
+		{Revision: 4},
+	}
+	for i, kv := range events {
+		err := f.event(kv)
+		if i == len(events)-1 {
+			if !errors.Is(err, errFollowDeleted) {
+				t.Fatalf("event() error = %v, want errFollowDeleted", err)
+			}
+		} else if err != nil {
+			t.Fatalf("event() error = %v", err)
+		}
+	}
+
+	want := `2024-01-01T00:00:00Z rev 2 configmaps/default/foo created
+  + apiVersion: "v1"
+  + data.a: "1"
+  + kind: "ConfigMap"
+  + metadata.name: "foo"
+2024-01-01T00:00:00Z rev 3 configmaps/default/foo modified
+  ~ data.a: "1" -> "2"
+2024-01-01T00:00:00Z rev 4 configmaps/default/foo deleted
+`
+	if got := buf.String(); got != want {
+		t.Errorf("follower output = \n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type frozenObject struct {
+	gr        schema.GroupResource
+	namespace string
+	name      string
+}
+
+// frozenObjects are the objects that are never written nor deleted,
+// such as the scaffolding objects of a shared cluster.
+type frozenObjects map[frozenObject]struct{}
+
+// parseFrozenObjects parses the references in the form of resource/namespace/name, or resource/name for cluster scoped resources.
+func parseFrozenObjects(refs []string) (frozenObjects, error) {
+	frozen := frozenObjects{}
+	for _, ref := range refs {
+		parts := strings.Split(ref, "/")
+		var obj frozenObject
+		switch len(parts) {
+		case 2:
+			obj.name = parts[1]
+		case 3:
+			obj.namespace = parts[1]
+			obj.name = parts[2]
+		default:
+			return nil, fmt.Errorf("invalid frozen object %q, want resource/namespace/name or resource/name", ref)
+		}
+		gr := schema.ParseGroupResource(parts[0])
+		if gr.Empty() || obj.name == "" {
+			return nil, fmt.Errorf("invalid frozen object %q", ref)
+		}
+		if correctGr, _, found := wellknown.CorrectGroupResource(gr); found {
+			gr = correctGr
+		}
+		obj.gr = gr
+		frozen[obj] = struct{}{}
+	}
+	return frozen, nil
+}
+
+// has returns whether the object is frozen.
+func (f frozenObjects) has(gr schema.GroupResource, namespace, name string) bool {
+	_, ok := f[frozenObject{gr: gr, namespace: namespace, name: name}]
+	return ok
+}
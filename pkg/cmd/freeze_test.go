@@ -0,0 +1,111 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseFrozenObjects(t *testing.T) {
+	frozen, err := parseFrozenObjects([]string{"po/kube-system/critical-pod", "nodes/node-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		gr        schema.GroupResource
+		namespace string
+		objName   string
+		want      bool
+	}{
+		{
+			name:      "namespaced",
+			gr:        schema.GroupResource{Resource: "pods"},
+			namespace: "kube-system",
+			objName:   "critical-pod",
+			want:      true,
+		},
+		{
+			name:    "cluster scoped",
+			gr:      schema.GroupResource{Resource: "nodes"},
+			objName: "node-0",
+			want:    true,
+		},
+		{
+			name:      "other namespace",
+			gr:        schema.GroupResource{Resource: "pods"},
+			namespace: "default",
+			objName:   "critical-pod",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := frozen.has(tt.gr, tt.namespace, tt.objName); got != tt.want {
+				t.Errorf("has() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, ref := range []string{"pods", "pods/a/b/c", "/name"} {
+		_, err := parseFrozenObjects([]string{ref})
+		if err == nil {
+			t.Errorf("parseFrozenObjects(%q) should fail", ref)
+		}
+	}
+}
+
+func TestDelCommandFreeze(t *testing.T) {
+	ctx := context.Background()
+	etcdclient := fake.NewClient()
+	pods := schema.GroupResource{Resource: "pods"}
+	for _, name := range []string{"a", "critical-pod"} {
+		err := etcdclient.Put(ctx, "/registry", []byte(podJSON), client.WithGR(pods), client.WithName(name, "kube-system"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := delCommand(ctx, etcdclient, &delFlagpole{
+		Namespace: "kube-system",
+		Output:    "none",
+		Prefix:    "/registry",
+		Freeze:    []string{"pods/kube-system/critical-pod"},
+	}, []string{"pods"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	_, err = etcdclient.Get(ctx, "/registry", client.WithResponse(func(kv *client.KeyValue) error {
+		got = append(got, string(kv.Key))
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/registry/pods/kube-system/critical-pod"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+}
@@ -18,14 +18,18 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/etcd-io/auger/pkg/encoding"
 	"github.com/spf13/cobra"
 	"github.com/wzshiming/kectl/pkg/client"
-	"github.com/wzshiming/kectl/pkg/scheme"
 	"github.com/wzshiming/kectl/pkg/wellknown"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -37,6 +41,54 @@ type getFlagpole struct {
 	WatchOnly    bool
 	Prefix       string
 	AllNamespace bool
+	Consistency  string
+	DecodeMode   string
+
+	OutputVersion string
+
+	RawRevisionRange string
+
+	MaxBandwidth string
+
+	Path           string
+	CheckpointFile string
+
+	Compression      string
+	CompressionLevel int
+	RecordingFormat  string
+
+	EncryptRecipients []string
+	EncryptPassphrase bool
+
+	Parallel int
+	SortBy   string
+
+	ListProgressInterval time.Duration
+	ReportFile           string
+
+	ProgressInterval time.Duration
+
+	FromRevision int64
+	ToRevision   int64
+
+	UntilDuration time.Duration
+	UntilCount    int
+	UntilObject   string
+
+	KeepNoop bool
+
+	SampleRate int
+	SampleRule []string
+
+	IncludeResource []string
+	ExcludeResource []string
+	Selector        string
+
+	HealthAddr       string
+	HealthMaxLag     time.Duration
+	HealthMaxObjects int
+
+	MaxClockSkew time.Duration
 }
 
 func newCtlGetCommand() *cobra.Command {
@@ -60,18 +112,50 @@ func newCtlGetCommand() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&flags.Output, "output", "o", "yaml", "output format. One of: (json, yaml, raw, key).")
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "yaml", "output format. jsonl writes each document as a single line of JSON with its key and revision. One of: (json, yaml, jsonl, raw, key).")
 	cmd.Flags().StringVarP(&flags.Namespace, "namespace", "n", "", "namespace of resource")
 	cmd.Flags().BoolVarP(&flags.Watch, "watch", "w", false, "after listing/getting the requested object, watch for changes")
 	cmd.Flags().BoolVar(&flags.WatchOnly, "watch-only", false, "watch for changes to the requested object(s), without listing/getting first")
+	cmd.Flags().DurationVar(&flags.ProgressInterval, "progress-interval", 0, "interval to print a bookmark of the revision the watch is complete up to and the time, while the watch is alive even if idle. 0 for no bookmarks.")
+	cmd.Flags().Int64Var(&flags.FromRevision, "from-revision", 0, "first revision of the events to watch, the objects are listed as of the revision before it. 0 for after the list.")
+	cmd.Flags().Int64Var(&flags.ToRevision, "to-revision", 0, "last revision of the events to watch, the watch waits for it to be reached and stops once all the events up to it are written. 0 for no limit.")
+	cmd.Flags().DurationVar(&flags.UntilDuration, "until-duration", 0, "stop the watch once it has been watching for this long. 0 for no limit.")
+	cmd.Flags().IntVar(&flags.UntilCount, "until-count", 0, "stop the watch once this many events are written. 0 for no limit.")
+	cmd.Flags().StringVar(&flags.UntilObject, "until-object", "", "CEL predicate of the object of an event to stop the watch once written, e.g. object.metadata.name == 'web' && object.status.phase == 'Running'. The object is the one deleted when deleted is true.")
+	cmd.Flags().BoolVar(&flags.KeepNoop, "keep-noop", false, "keep the events of the watch updating an object without changing it, apart from its resourceVersion and managedFields, which are skipped by default")
+	cmd.Flags().IntVar(&flags.SampleRate, "sample-rate", 1, "keep 1 in this many updates of each resource while watching, the creations and deletions are all kept")
+	cmd.Flags().StringSliceVar(&flags.SampleRule, "sample-rule", nil, "sample rate of a resource overriding --sample-rate, e.g. leases.coordination.k8s.io=10,pods=1")
 	cmd.Flags().Int64Var(&flags.ChunkSize, "chunk-size", 500, "chunk size of the list pager")
+	cmd.Flags().IntVar(&flags.Parallel, "parallel", 1, "number of resources listed at once when getting all of etcd, the output is in the same order as listing them one at a time")
+	cmd.Flags().StringVar(&flags.SortBy, "sort-by", "key", "order of the objects when getting all of etcd, resource sorts them by group, resource, namespace and name for the output not to depend on the keys, buffering each resource. One of: (key, resource).")
+	cmd.Flags().DurationVar(&flags.ListProgressInterval, "list-progress-interval", 0, "interval to print the resources completed, the keys and bytes listed and the resources being listed, when getting all of etcd. 0 for no progress.")
+	cmd.Flags().StringVar(&flags.ReportFile, "report-file", "", "path of the file to write the keys, bytes and duration of each resource to as JSON once getting all of etcd completes")
 	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
 	cmd.Flags().BoolVarP(&flags.AllNamespace, "all-namespace", "A", false, "all namespace")
+	cmd.Flags().StringVar(&flags.Consistency, "consistency", "", "consistency of the reads. One of: (l, s, linearizable, serializable). Defaults to s for listing and l for a single object.")
+	cmd.Flags().StringVar(&flags.DecodeMode, "decode-mode", "lenient", "how values not matching the scheme are handled. One of: (lenient, strict).")
+	cmd.Flags().StringVar(&flags.OutputVersion, "output-version", "", "convert the objects stored at other versions of the group to this version, e.g. policy/v1, the versions without a conversion to it are printed raw")
+	cmd.Flags().StringVar(&flags.MaxBandwidth, "max-bandwidth", "0", "maximum bytes per second to receive, e.g. 10Mi, with the throughput reported every second. 0 for no limit.")
+	cmd.Flags().StringVar(&flags.Path, "path", "", "path of the file to write to instead of stdout, or of an object such as s3://bucket/key, gcs://bucket/key or azblob://container/key")
+	cmd.Flags().StringVar(&flags.Compression, "compression", "", "compression of the output. One of: (none, gzip, zstd). Defaults to the one of the .gz or .zst extension of --path, or none.")
+	cmd.Flags().IntVar(&flags.CompressionLevel, "compression-level", 0, "level of the compression, 1-9 for gzip and 1-22 for zstd. 0 for the default of the compression.")
+	cmd.Flags().StringSliceVar(&flags.EncryptRecipients, "encrypt-recipient", nil, "age recipient to encrypt the output to, an age1 public key or the path of a file of them")
+	cmd.Flags().BoolVar(&flags.EncryptPassphrase, "encrypt-passphrase", false, "encrypt the output with the passphrase of $"+recordingPassphraseEnv)
+	cmd.Flags().StringVar(&flags.RecordingFormat, "recording-format", recordingFormatV1, "format of the recording written to --path. v2 compresses the documents in chunks indexed by revision for the readers to seek to the revisions they want, and is not supported with --watch. binary writes the values as stored rather than decoded, as length-delimited protobuf records. One of: (v1, v2, binary).")
+	cmd.Flags().StringVar(&flags.CheckpointFile, "checkpoint-file", "", "path of the file to save the progress to, for an interrupted get to the --path file to resume from it. It is removed once the get completes, and kept by --watch to resume after the last revision written.")
+	cmd.Flags().StringSliceVar(&flags.IncludeResource, "include-resource", nil, "only get the objects of these resources, when getting all of etcd")
+	cmd.Flags().StringSliceVar(&flags.ExcludeResource, "exclude-resource", nil, "skip the objects of these resources, when getting all of etcd")
+	cmd.Flags().StringVarP(&flags.Selector, "selector", "l", "", "label selector of the objects to get, e.g. app=web,tier!=cache")
+	cmd.Flags().StringVar(&flags.HealthAddr, "health-addr", "", "address to serve /healthz and /status on while watching, e.g. :8080, reporting the lag, the last revision, the objects tracked and the memory")
+	cmd.Flags().DurationVar(&flags.HealthMaxLag, "health-max-lag", time.Minute, "the watch is unhealthy once it has been behind etcd for longer than this. 0 for no limit.")
+	cmd.Flags().IntVar(&flags.HealthMaxObjects, "health-max-objects", 0, "the watch is unhealthy, with a warning, once it tracks more objects than this. 0 for no limit.")
+	cmd.Flags().DurationVar(&flags.MaxClockSkew, "max-clock-skew", 2*time.Second, "clock skew of kectl against the etcd server before a warning. The skew is measured at the start of a recording to --path and written at its start. 0 not to check.")
+	cmd.Flags().StringVar(&flags.RawRevisionRange, "raw-revision-range", "", "dump every revision of the requested object(s) in the range START-[END] from the etcd history, END defaults to the current revision")
 
 	return cmd
 }
 
-func getCommand(ctx context.Context, etcdclient client.Client, flags *getFlagpole, args []string) error {
+func getCommand(ctx context.Context, etcdclient client.Client, flags *getFlagpole, args []string) (err error) {
 	var targetGr schema.GroupResource
 	var targetName string
 	var targetNamespace string
@@ -100,67 +184,336 @@ func getCommand(ctx context.Context, etcdclient client.Client, flags *getFlagpol
 		}
 	}
 
-	var count int
-	var response func(kv *client.KeyValue) error
-
-	switch flags.Output {
-	case "json":
-		outMediaType := encoding.JsonMediaType
-		response = func(kv *client.KeyValue) error {
-			count++
-			value := kv.Value
-			if value == nil {
-				value = kv.PrevValue
-			}
-			inMediaType, _, err := encoding.DetectAndExtract(value)
+	if flags.RawRevisionRange != "" && flags.Watch {
+		return fmt.Errorf("--raw-revision-range and --watch are mutually exclusive")
+	}
+	if flags.CheckpointFile != "" {
+		if flags.Path == "" || flags.Path == "-" {
+			return fmt.Errorf("--checkpoint-file needs the output written to a file with --path")
+		}
+		if flags.RawRevisionRange != "" {
+			return fmt.Errorf("--checkpoint-file is not supported with --raw-revision-range")
+		}
+		if isObjectStoragePath(flags.Path) {
+			return fmt.Errorf("--checkpoint-file needs a local --path, an object in an object storage can not be appended to")
+		}
+	}
+	compression := flags.Compression
+	if compression == "" {
+		compression = compressionFromPath(flags.Path)
+	}
+	if compression != compressionNone && flags.CheckpointFile != "" {
+		return fmt.Errorf("--checkpoint-file can not resume a compressed output")
+	}
+	encrypted := len(flags.EncryptRecipients) != 0 || flags.EncryptPassphrase
+	if encrypted && flags.CheckpointFile != "" {
+		return fmt.Errorf("--checkpoint-file can not resume an encrypted output")
+	}
+	switch flags.RecordingFormat {
+	case "", recordingFormatV1:
+	case recordingFormatV2:
+		if flags.Path == "" || flags.Path == "-" {
+			return fmt.Errorf("--recording-format v2 needs the output written to a file with --path")
+		}
+		if flags.Output == "jsonl" {
+			return fmt.Errorf("--recording-format v2 indexes the documents by their headers, it does not apply to -o jsonl")
+		}
+		if flags.Watch || flags.CheckpointFile != "" || compression != compressionNone || encrypted {
+			return fmt.Errorf("--recording-format v2 is compressed and indexed once the get completes, it does not apply to --watch, --checkpoint-file, --compression or encryption")
+		}
+	case recordingFormatBinary:
+		if (flags.Output != "json" && flags.Output != "yaml") || flags.OutputVersion != "" {
+			return fmt.Errorf("--recording-format binary writes the values as stored, it does not apply to -o %s or --output-version", flags.Output)
+		}
+		if flags.CheckpointFile != "" {
+			return fmt.Errorf("--recording-format binary can not be resumed with --checkpoint-file")
+		}
+	default:
+		return fmt.Errorf("unsupported recording format %q. One of: (v1, v2, binary)", flags.RecordingFormat)
+	}
+	if flags.FromRevision < 0 || flags.ToRevision < 0 {
+		return fmt.Errorf("invalid revision window %d-%d", flags.FromRevision, flags.ToRevision)
+	}
+	if (flags.FromRevision != 0 || flags.ToRevision != 0) && !flags.Watch {
+		return fmt.Errorf("--from-revision and --to-revision only apply to --watch")
+	}
+	if flags.ToRevision != 0 && flags.FromRevision > flags.ToRevision {
+		return fmt.Errorf("--from-revision %d is after --to-revision %d", flags.FromRevision, flags.ToRevision)
+	}
+	if flags.UntilDuration < 0 || flags.UntilCount < 0 {
+		return fmt.Errorf("invalid stop conditions %s and %d", flags.UntilDuration, flags.UntilCount)
+	}
+	if (flags.UntilDuration != 0 || flags.UntilCount != 0 || flags.UntilObject != "") && !flags.Watch {
+		return fmt.Errorf("--until-duration, --until-count and --until-object only apply to --watch")
+	}
+	if flags.UntilObject != "" && flags.Output == "key" {
+		return fmt.Errorf("--until-object needs the values, it does not apply to -o key")
+	}
+	until, err := newWatchUntil(flags.UntilDuration, flags.UntilCount, flags.UntilObject)
+	if err != nil {
+		return err
+	}
+	if flags.KeepNoop && !flags.Watch {
+		return fmt.Errorf("--keep-noop only applies to --watch")
+	}
+	if (flags.SampleRate > 1 || len(flags.SampleRule) != 0) && !flags.Watch {
+		return fmt.Errorf("--sample-rate and --sample-rule only apply to --watch")
+	}
+	sampler, err := newEventSampler(flags.Prefix, flags.SampleRate, flags.SampleRule)
+	if err != nil {
+		return err
+	}
+	if isObjectStoragePath(flags.Path) && (flags.Watch || flags.WatchOnly) && flags.ToRevision == 0 && until == nil {
+		return fmt.Errorf("--watch can not write to an object storage without --to-revision or a stop condition, the object is only written once the get completes")
+	}
+	if flags.Parallel < 0 {
+		return fmt.Errorf("invalid parallel %d", flags.Parallel)
+	}
+	if flags.ProgressInterval < 0 {
+		return fmt.Errorf("invalid progress interval %s", flags.ProgressInterval)
+	}
+	if flags.ProgressInterval != 0 && !flags.Watch {
+		return fmt.Errorf("--progress-interval only applies to --watch")
+	}
+	if flags.HealthAddr != "" && !flags.Watch {
+		return fmt.Errorf("--health-addr only applies to --watch")
+	}
+	if flags.HealthMaxLag < 0 || flags.HealthMaxObjects < 0 {
+		return fmt.Errorf("invalid health limits %s and %d", flags.HealthMaxLag, flags.HealthMaxObjects)
+	}
+
+	if flags.Parallel > 1 && (!targetGr.Empty() || flags.Watch || flags.RawRevisionRange != "" || flags.CheckpointFile != "") {
+		return fmt.Errorf("--parallel only applies to getting all of etcd, without --watch, --raw-revision-range or --checkpoint-file")
+	}
+
+	sortBy, err := parseSortBy(flags.SortBy)
+	if err != nil {
+		return err
+	}
+	if sortBy != sortByKey && (!targetGr.Empty() || flags.Watch || flags.RawRevisionRange != "" || flags.CheckpointFile != "") {
+		return fmt.Errorf("--sort-by only applies to getting all of etcd, without --watch, --raw-revision-range or --checkpoint-file")
+	}
+
+	if flags.ListProgressInterval < 0 {
+		return fmt.Errorf("invalid list progress interval %s", flags.ListProgressInterval)
+	}
+	if (flags.ListProgressInterval != 0 || flags.ReportFile != "") && (!targetGr.Empty() || flags.Watch || flags.RawRevisionRange != "" || flags.CheckpointFile != "") {
+		return fmt.Errorf("--list-progress-interval and --report-file only apply to getting all of etcd, without --watch, --raw-revision-range or --checkpoint-file")
+	}
+
+	if (len(flags.IncludeResource) != 0 || len(flags.ExcludeResource) != 0) && !targetGr.Empty() {
+		return fmt.Errorf("--include-resource and --exclude-resource only apply to getting all of etcd")
+	}
+	if flags.Selector != "" && flags.Output == "key" {
+		return fmt.Errorf("--selector needs the values, it does not apply to -o key")
+	}
+	// The namespace of the key already selects the objects of a resource
+	var filterNamespace string
+	if targetGr.Empty() {
+		filterNamespace = flags.Namespace
+	}
+	filter, err := newObjectFilter(flags.Prefix, flags.IncludeResource, flags.ExcludeResource, filterNamespace, flags.Selector,
+		flags.Watch || flags.RawRevisionRange != "")
+	if err != nil {
+		return err
+	}
+
+	mode, err := parseDecodeMode(flags.DecodeMode)
+	if err != nil {
+		return err
+	}
+
+	var outputVersion schema.GroupVersion
+	if flags.OutputVersion != "" {
+		outputVersion, err = schema.ParseGroupVersion(flags.OutputVersion)
+		if err != nil || outputVersion.Version == "" {
+			return fmt.Errorf("invalid output version %q", flags.OutputVersion)
+		}
+		if targetGr.Empty() || targetGr.Group != outputVersion.Group {
+			return fmt.Errorf("output version %q is not a version of the resource %q", flags.OutputVersion, targetGr)
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	var cp *checkpointer
+	var file *recordingFile
+	if isObjectStoragePath(flags.Path) {
+		var object *objectWriter
+		object, err = createObject(ctx, flags.Path)
+		if err != nil {
+			return err
+		}
+		// The object is written once all of it is, and left as it was on a failure
+		defer func() {
 			if err != nil {
-				fmt.Fprintf(os.Stdout, "---\n# %s | raw | %v\n# %s\n", kv.Key, err, value)
-				return nil
+				err = errors.Join(err, object.Abort())
+				return
 			}
-			data, _, err := encoding.Convert(scheme.Codecs, inMediaType, outMediaType, value)
+			err = object.Close()
+		}()
+		out = object
+	} else if flags.Path != "" && flags.Path != "-" {
+		file, err = createRecording(flags.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+
+		if flags.CheckpointFile != "" {
+			cp, err = openExportCheckpoint(file.File, flags, targetGr, targetName, targetNamespace)
 			if err != nil {
-				fmt.Fprintf(os.Stdout, "---\n# %s | raw | %v\n# %s\n", kv.Key, err, value)
-			} else {
-				fmt.Fprintf(os.Stdout, "---\n# %s | %s\n%s\n", kv.Key, inMediaType, data)
+				return err
 			}
-			return nil
+			out = io.MultiWriter(file, cp)
+		} else {
+			err = file.Truncate(0)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var health *watchHealth
+	if flags.HealthAddr != "" {
+		health = newWatchHealth(flags.HealthMaxLag, flags.HealthMaxObjects, func(ctx context.Context) (int64, error) {
+			return currentRevision(ctx, etcdclient, flags.Prefix)
+		})
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		err = health.serve(ctx, flags.HealthAddr)
+		if err != nil {
+			return err
+		}
+		out = health.countWriter(out)
+	}
+
+	encryptor, err := newEncryptWriter(out, flags.EncryptRecipients, flags.EncryptPassphrase)
+	if err != nil {
+		return err
+	}
+	if encryptor != nil {
+		// The last chunk of the encrypted output is written before the file is closed, after the end of the compressed one
+		defer func() {
+			err = errors.Join(err, encryptor.Close())
+		}()
+		out = encryptor
+	}
+
+	compressor, err := newCompressWriter(out, compression, flags.CompressionLevel)
+	if err != nil {
+		return err
+	}
+	if compressor != nil {
+		// The end of the compressed output is written before the file is closed
+		defer func() {
+			err = errors.Join(err, compressor.Close())
+		}()
+		out = compressor
+	}
+	if flags.RecordingFormat == recordingFormatV2 {
+		var recording *recordingV2Writer
+		recording, err = newRecordingV2Writer(out, flags.Prefix)
+		if err != nil {
+			return err
 		}
-	case "yaml":
-		outMediaType := encoding.YamlMediaType
-		response = func(kv *client.KeyValue) error {
-			count++
-			value := kv.Value
-			if value == nil {
-				value = kv.PrevValue
+		// The index is only written once the get completes, a recording without it is read up to its last chunk
+		defer func() {
+			if err == nil {
+				err = recording.Close()
 			}
-			inMediaType, _, err := encoding.DetectAndExtract(value)
+		}()
+		out = recording
+	}
+	if flags.RecordingFormat == recordingFormatBinary {
+		_, err = out.Write(recordingBinaryMagic)
+		if err != nil {
+			return err
+		}
+	}
+	if flags.Path != "" && flags.Path != "-" && (cp == nil || !cp.resumed()) {
+		// The times of the recording are the ones of kectl, the ones of the objects of the apiserver by the clock of the server
+		err = recordClockSkew(ctx, etcdclient, out, flags.Output, flags.RecordingFormat == recordingFormatBinary, flags.MaxClockSkew)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The values the lenient mode writes raw are summarized by resource once the get ends
+	var failures *decodeFailures
+	if mode == decodeModeLenient {
+		failures = newDecodeFailures(flags.Prefix)
+		defer failures.report(os.Stderr)
+	}
+	printerOpts := printerOptions{
+		Output:        flags.Output,
+		Binary:        flags.RecordingFormat == recordingFormatBinary,
+		WithRevision:  flags.RawRevisionRange != "",
+		DecodeMode:    mode,
+		OutputVersion: outputVersion,
+		Failures:      failures,
+	}
+	printer, err := newPrinter(out, printerOpts)
+	if err != nil {
+		return err
+	}
+
+	bandwidth, err := parseBandwidth(flags.MaxBandwidth)
+	if err != nil {
+		return err
+	}
+	throttle := newThrottle(bandwidth, os.Stderr)
+	defer throttle.done()
+
+	var count int
+	// watched is the revision of the last event written by the watch, -1 while listing
+	watched := int64(-1)
+	// The revision traces of --raw-revision-range keep every write
+	noop := newNoopFilter(flags.KeepNoop || !flags.Watch)
+	response := throttle.wrap(ctx, filter.wrap(noop.wrap(sampler.wrap(health.wrap(func(kv *client.KeyValue) error {
+		if cp != nil && watched >= 0 && kv.Revision > watched {
+			// All the events of the revisions before are written
+			err := cp.progress(watched)
 			if err != nil {
-				fmt.Fprintf(os.Stdout, "---\n# %s | raw | %v\n# %s\n", kv.Key, err, value)
-				return nil
+				return err
 			}
-			data, _, err := encoding.Convert(scheme.Codecs, inMediaType, outMediaType, value)
+		}
+		count++
+		var err error
+		if file != nil {
+			err = file.document(func() error {
+				return printer(kv)
+			})
+		} else {
+			err = printer(kv)
+		}
+		if err != nil {
+			return err
+		}
+		if compressor != nil && watched >= 0 {
+			// The events written are kept in whole blocks, for a watch killed to leave them decompressable
+			err = compressor.Flush()
 			if err != nil {
-				fmt.Fprintf(os.Stdout, "---\n# %s | raw | %v\n# %s\n", kv.Key, err, value)
-			} else {
-				fmt.Fprintf(os.Stdout, "---\n# %s | %s\n%s\n", kv.Key, inMediaType, data)
+				return err
 			}
-			return nil
 		}
-	case "raw":
-		response = func(kv *client.KeyValue) error {
-			count++
-			fmt.Fprintf(os.Stdout, "%s\n%s\n", kv.Key, kv.Value)
-			return nil
+		if cp != nil && flags.Watch {
+			cp.add(string(kv.Key))
+			if watched >= 0 {
+				watched = max(watched, kv.Revision)
+			}
+		}
+		if watched >= 0 {
+			return until.observe(kv)
 		}
-	case "key":
-		response = func(kv *client.KeyValue) error {
-			count++
-			fmt.Fprintf(os.Stdout, "%s\n", kv.Key)
+		if cp != nil && flags.Watch {
 			return nil
 		}
-	default:
-		return fmt.Errorf("unsupported output format: %s", flags.Output)
-	}
+		if cp != nil {
+			return cp.commit(string(kv.Key))
+		}
+		return nil
+	})))))
 
 	opOpts := []client.OpOption{
 		client.WithName(targetName, targetNamespace),
@@ -169,35 +522,198 @@ func getCommand(ctx context.Context, etcdclient client.Client, flags *getFlagpol
 		client.WithResponse(response),
 	}
 
+	// The gaps are written into the output as well, for the recording to tell where it is incomplete
+	writeGap := func(gap client.Gap) error {
+		if flags.RecordingFormat == recordingFormatBinary {
+			return nil
+		}
+		return printGap(out, flags.Output, gap)
+	}
+	warnGap := client.WithGap(func(gap client.Gap) error {
+		fmt.Fprintf(os.Stderr, "warning: revisions %d-%d have been compacted and are missing from the watch\n", gap.From, gap.To)
+		return writeGap(gap)
+	})
+
+	if flags.RawRevisionRange != "" {
+		start, end, err := parseRevisionRange(flags.RawRevisionRange)
+		if err != nil {
+			return err
+		}
+		if end == 0 {
+			end, err = currentRevision(ctx, etcdclient, flags.Prefix)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Values are always requested, the deletions are told apart by their empty value
+		opOpts = append(opOpts,
+			client.WithRevision(start),
+			client.WithMaxRevision(end),
+			warnGap,
+		)
+		err = etcdclient.Watch(ctx, flags.Prefix,
+			opOpts...,
+		)
+		if err != nil {
+			return err
+		}
+
+		if flags.Output == "key" {
+			fmt.Fprintf(os.Stderr, "get %d revisions of keys\n", count)
+		}
+		return nil
+	}
+
 	if flags.Output == "key" {
 		opOpts = append(opOpts,
 			client.WithKeysOnly(),
 		)
 	}
 
-	var err error
+	consistencyOpts, err := consistencyOpOptions(flags.Consistency, targetName != "")
+	if err != nil {
+		return err
+	}
+
 	if flags.Watch {
 		var rev int64
-		if !flags.WatchOnly {
-			rev, err = etcdclient.Get(ctx, flags.Prefix,
-				opOpts...,
-			)
+		if cp != nil && cp.state.Revision != 0 {
+			fmt.Fprintf(os.Stderr, "resume the watch after %d documents at revision %d\n", cp.state.Count, cp.state.Revision)
+			rev = cp.state.Revision + 1
+			health.progress(cp.state.Revision)
+			watched = cp.state.Revision
+		} else if !flags.WatchOnly && flags.FromRevision != 1 {
+			listOpts := append(opOpts, consistencyOpts...)
+			if flags.FromRevision != 0 {
+				// The objects as of the revision before the first event watched
+				listOpts = append(listOpts, client.WithRevision(flags.FromRevision-1))
+			}
+			rev, err = etcdclient.Get(ctx, flags.Prefix, listOpts...)
 			if err != nil {
 				return err
 			}
+			if flags.FromRevision != 0 {
+				rev = flags.FromRevision - 1
+			}
+			health.progress(rev)
+			// The objects listed are all written, the watch is resumed after them from now on
+			watched = rev
+			if cp != nil {
+				err = cp.progress(rev)
+				if err != nil {
+					return err
+				}
+			}
+			if flags.FromRevision != 0 {
+				rev = flags.FromRevision
+			} else {
+				// The watch starts after the revision listed, the write at it is already in the list
+				rev++
+			}
+		} else {
+			watched = 0
+			rev = flags.FromRevision
+		}
+		if flags.ToRevision != 0 {
+			opOpts = append(opOpts, client.WithMaxRevision(flags.ToRevision))
 		}
 
-		opOpts = append(opOpts, client.WithRevision(rev))
+		// A long watch lists the objects again rather than failing once it falls behind the compaction
+		opOpts = append(opOpts,
+			client.WithRevision(rev),
+			client.WithRelist(),
+			client.WithGap(func(gap client.Gap) error {
+				fmt.Fprintf(os.Stderr, "warning: revisions %d-%d have been compacted and are missing from the watch, listing again\n", gap.From, gap.To)
+				return writeGap(gap)
+			}),
+		)
+		if flags.ProgressInterval != 0 {
+			opOpts = append(opOpts,
+				client.WithProgress(flags.ProgressInterval, func(revision int64) error {
+					health.progress(revision)
+					var err error
+					if flags.RecordingFormat == recordingFormatBinary {
+						err = writeRecordingBinaryBookmark(out, revision, time.Now())
+					} else {
+						err = printBookmark(out, flags.Output, revision, time.Now())
+					}
+					if err != nil || cp == nil {
+						return err
+					}
+					watched = revision
+					return cp.progress(revision)
+				}),
+			)
+		} else if health != nil || cp != nil {
+			// The watch is known to be caught up with etcd even when nothing changes
+			opOpts = append(opOpts,
+				client.WithProgress(watchProgressInterval, func(revision int64) error {
+					health.progress(revision)
+					if cp == nil {
+						return nil
+					}
+					watched = revision
+					return cp.progress(revision)
+				}),
+			)
+		}
 
-		err = etcdclient.Watch(ctx, flags.Prefix,
+		watchCtx, cancel := until.context(ctx)
+		defer cancel()
+		err = etcdclient.Watch(watchCtx, flags.Prefix,
 			opOpts...,
 		)
+		if noop != nil && noop.skipped != 0 {
+			fmt.Fprintf(os.Stderr, "skip %d no-op updates\n", noop.skipped)
+		}
+		if sampler != nil && sampler.skipped != 0 {
+			fmt.Fprintf(os.Stderr, "skip %d sampled out updates\n", sampler.skipped)
+		}
+		var stopped bool
+		stopped, err = until.stopped(watchCtx, err)
 		if err != nil {
 			return err
 		}
+
+		if stopped {
+			fmt.Fprintf(os.Stderr, "stop the watch %s\n", until.reason)
+			if cp != nil {
+				return cp.done()
+			}
+		} else if flags.ToRevision != 0 {
+			// The watch also returns once interrupted, which leaves the recording short of the revision
+			if ctx.Err() != nil {
+				return fmt.Errorf("the watch is interrupted before revision %d: %w", flags.ToRevision, ctx.Err())
+			}
+			fmt.Fprintf(os.Stderr, "stop the watch at revision %d\n", flags.ToRevision)
+			if cp != nil {
+				return cp.done()
+			}
+		}
+	} else if cp != nil {
+		err = checkpointedGet(ctx, etcdclient, flags.Prefix, cp,
+			append(opOpts, consistencyOpts...)...,
+		)
+		if err != nil {
+			return err
+		}
+
+		if flags.Output == "key" {
+			fmt.Fprintf(os.Stderr, "get %d keys\n", count)
+		}
+	} else if flags.Parallel > 1 || flags.ListProgressInterval != 0 || flags.ReportFile != "" || flags.SortBy == sortByResource {
+		count, err = getAllParallel(ctx, etcdclient, flags, out, printerOpts, throttle, filter, consistencyOpts)
+		if err != nil {
+			return err
+		}
+
+		if flags.Output == "key" {
+			fmt.Fprintf(os.Stderr, "get %d keys\n", count)
+		}
 	} else {
 		_, err = etcdclient.Get(ctx, flags.Prefix,
-			opOpts...,
+			append(opOpts, consistencyOpts...)...,
 		)
 		if err != nil {
 			return err
@@ -209,3 +725,189 @@ func getCommand(ctx context.Context, etcdclient client.Client, flags *getFlagpol
 	}
 	return nil
 }
+
+// getAllParallel gets all of etcd by resource with the workers of --parallel, at a single revision,
+// with the progress of each resource.
+func getAllParallel(ctx context.Context, etcdclient client.Client, flags *getFlagpole, out io.Writer, printerOpts printerOptions, throttle *throttle, filter *objectFilter, consistencyOpts []client.OpOption) (int, error) {
+	// The resources are listed at the same revision, for the output to be a snapshot as a single list is
+	rev, err := currentRevision(ctx, etcdclient, flags.Prefix)
+	if err != nil {
+		return 0, err
+	}
+	ranges, ok, err := resourceRanges(ctx, etcdclient, flags.Prefix, rev)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		ranges = []string{flags.Prefix}
+	} else if flags.SortBy == sortByResource {
+		sortRangesByResource(flags.Prefix, ranges)
+	}
+	progress := newListProgress(os.Stderr, flags.ListProgressInterval, flags.ReportFile != "", flags.Prefix, ranges)
+	stop := progress.run(ctx)
+
+	var mut sync.Mutex
+	var count int
+	get := func(ctx context.Context, rangePrefix string, w io.Writer) error {
+		progress.begin(rangePrefix)
+		printer, err := newPrinter(w, printerOpts)
+		if err != nil {
+			return err
+		}
+		var rangeCount int
+		// The objects of the range are held to be sorted once all listed
+		var sorted []*client.KeyValue
+		opOpts := []client.OpOption{
+			client.WithPageLimit(flags.ChunkSize),
+			client.WithRevision(rev),
+			client.WithResponse(progress.wrap(rangePrefix, throttle.wrap(ctx, filter.wrap(func(kv *client.KeyValue) error {
+				rangeCount++
+				if flags.SortBy == sortByResource {
+					sorted = append(sorted, kv)
+					return nil
+				}
+				return printer(kv)
+			})))),
+		}
+		if flags.Output == "key" {
+			opOpts = append(opOpts, client.WithKeysOnly())
+		}
+		_, err = etcdclient.Get(ctx, rangePrefix,
+			append(opOpts, consistencyOpts...)...,
+		)
+		if err != nil {
+			return err
+		}
+		if flags.SortBy == sortByResource {
+			sortByResourceOrder(flags.Prefix, sorted)
+			for _, kv := range sorted {
+				err = printer(kv)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		progress.end(rangePrefix)
+		mut.Lock()
+		defer mut.Unlock()
+		count += rangeCount
+		return nil
+	}
+	if ok {
+		err = parallelGet(ctx, ranges, flags.Parallel, out, get)
+	} else {
+		// Some keys are not under a resource, they are listed at once
+		err = get(ctx, flags.Prefix, out)
+	}
+	stop()
+	if err != nil {
+		return 0, err
+	}
+	err = progress.writeReport(flags.ReportFile, rev)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// openExportCheckpoint loads the checkpoint of the get to the file,
+// the file is verified against it and truncated to the documents it covers to be appended to.
+func openExportCheckpoint(file *os.File, flags *getFlagpole, gr schema.GroupResource, name, namespace string) (*checkpointer, error) {
+	key, _, err := client.NewOp(client.WithName(name, namespace), client.WithGR(gr)).Key(flags.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	target := fmt.Sprintf("get -o %s %s", flags.Output, key)
+	if flags.Watch {
+		target += " --watch"
+	}
+	if flags.FromRevision != 0 {
+		target += fmt.Sprintf(" --from-revision %d", flags.FromRevision)
+	}
+	if flags.ToRevision != 0 {
+		target += fmt.Sprintf(" --to-revision %d", flags.ToRevision)
+	}
+	if flags.UntilDuration != 0 {
+		target += fmt.Sprintf(" --until-duration %s", flags.UntilDuration)
+	}
+	if flags.UntilCount != 0 {
+		target += fmt.Sprintf(" --until-count %d", flags.UntilCount)
+	}
+	if flags.UntilObject != "" {
+		target += " --until-object " + flags.UntilObject
+	}
+	if flags.SampleRate > 1 {
+		target += fmt.Sprintf(" --sample-rate %d", flags.SampleRate)
+	}
+	if len(flags.SampleRule) != 0 {
+		target += " --sample-rule " + strings.Join(flags.SampleRule, ",")
+	}
+	if flags.OutputVersion != "" {
+		target += " --output-version " + flags.OutputVersion
+	}
+	if len(flags.IncludeResource) != 0 {
+		target += " --include-resource " + strings.Join(flags.IncludeResource, ",")
+	}
+	if len(flags.ExcludeResource) != 0 {
+		target += " --exclude-resource " + strings.Join(flags.ExcludeResource, ",")
+	}
+	if gr.Empty() && flags.Namespace != "" {
+		target += " --namespace " + flags.Namespace
+	}
+	if flags.Selector != "" {
+		target += " --selector " + flags.Selector
+	}
+	cp, err := loadCheckpoint(flags.CheckpointFile, target)
+	if err != nil {
+		return nil, err
+	}
+	cp.sync = file.Sync
+
+	var size int64
+	if cp.resumed() {
+		size, err = cp.verifyFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s does not match the checkpoint %s, remove it to start over: %w", flags.Path, flags.CheckpointFile, err)
+		}
+	}
+	err = file.Truncate(size)
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.Seek(size, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// checkpointedGet lists at the revision of the checkpoint after its last key, saving the progress as it goes.
+func checkpointedGet(ctx context.Context, etcdclient client.Client, prefix string, cp *checkpointer, opOpts ...client.OpOption) error {
+	if cp.resumed() {
+		fmt.Fprintf(os.Stderr, "resume after %d documents at revision %d, from %s\n", cp.state.Count, cp.state.Revision, cp.state.LastKey)
+	} else {
+		// The revision is fixed up front, so that the resumed list continues the same snapshot
+		rev, err := currentRevision(ctx, etcdclient, prefix)
+		if err != nil {
+			return err
+		}
+		cp.state.Revision = rev
+	}
+
+	_, err := etcdclient.Get(ctx, prefix,
+		append(opOpts,
+			client.WithRevision(cp.state.Revision),
+			client.WithStartAfter(cp.state.LastKey),
+		)...,
+	)
+	if err != nil {
+		// Keep what has been written so far for the next attempt
+		flushErr := cp.flush()
+		if errors.Is(err, rpctypes.ErrCompacted) {
+			err = fmt.Errorf("revision %d of the checkpoint has been compacted, remove %s to start over: %w", cp.state.Revision, cp.path, err)
+		}
+		return errors.Join(err, flushErr)
+	}
+	return cp.done()
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGetCommandWatchRevisionWindow(t *testing.T) {
+	dir := t.TempDir()
+	etcdclient := fake.NewClient()
+	gr := schema.GroupResource{Resource: "configmaps"}
+	put := func(name string) {
+		err := etcdclient.Put(context.Background(), "/registry", []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"`+name+`","namespace":"default"}}`),
+			client.WithGR(gr),
+			client.WithName(name, "default"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := etcdclient.Revision()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		put(name)
+	}
+
+	written := func(path string) string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if key, _, ok := strings.Cut(line, " | "); ok {
+				names = append(names, strings.TrimPrefix(key, "# /registry/configmaps/default/"))
+			}
+		}
+		return strings.Join(names, ",")
+	}
+
+	tests := []struct {
+		name      string
+		watchOnly bool
+		from, to  int64
+		want      string
+	}{
+		{name: "events in the window", watchOnly: true, from: start + 2, to: start + 4, want: "b,c,d"},
+		{name: "listed before the window", from: start + 3, to: start + 4, want: "a,b,c,d"},
+		{name: "from the first revision", from: 1, to: start + 1, want: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-")+".yaml")
+			err := getCommand(context.Background(), etcdclient, &getFlagpole{
+				Output:       "yaml",
+				Prefix:       "/registry",
+				DecodeMode:   "lenient",
+				MaxBandwidth: "0",
+				Watch:        true,
+				WatchOnly:    tt.watchOnly,
+				FromRevision: tt.from,
+				ToRevision:   tt.to,
+				Path:         path,
+			}, []string{"configmaps"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := written(path); got != tt.want {
+				t.Errorf("written %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// The watch waits for the last revision to be reached by another writer
+	path := filepath.Join(dir, "future.yaml")
+	done := make(chan error, 1)
+	go func() {
+		done <- getCommand(context.Background(), etcdclient, &getFlagpole{
+			Output:       "yaml",
+			Prefix:       "/registry",
+			DecodeMode:   "lenient",
+			MaxBandwidth: "0",
+			Watch:        true,
+			WatchOnly:    true,
+			FromRevision: start + 5,
+			ToRevision:   start + 7,
+			Path:         path,
+		}, []string{"configmaps"})
+	}()
+	for _, name := range []string{"f", "g", "h"} {
+		put(name)
+	}
+	err := <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := written(path), "e,f,g"; got != want {
+		t.Errorf("written %s, want %s", got, want)
+	}
+
+	err = getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:       "yaml",
+		Prefix:       "/registry",
+		DecodeMode:   "lenient",
+		MaxBandwidth: "0",
+		FromRevision: start + 1,
+	}, []string{"configmaps"})
+	if err == nil {
+		t.Errorf("getCommand() with --from-revision but no --watch did not fail")
+	}
+}
+
+func TestGetCommandWatchUntil(t *testing.T) {
+	dir := t.TempDir()
+	etcdclient := fake.NewClient()
+	gr := schema.GroupResource{Resource: "configmaps"}
+	start := etcdclient.Revision()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		err := etcdclient.Put(context.Background(), "/registry", []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"`+name+`","namespace":"default"}}`),
+			client.WithGR(gr),
+			client.WithName(name, "default"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		from     int64
+		duration time.Duration
+		count    int
+		object   string
+		want     string
+		key      bool
+		wantErr  bool
+	}{
+		{name: "count", from: start + 1, count: 2, want: "a,b"},
+		{name: "object", from: start + 1, object: "object.metadata.name == 'c'", want: "a,b,c"},
+		{name: "object missing fields", from: start + 1, object: "object.data.ready == 'true' || object.metadata.name == 'd'", want: "a,b,c,d"},
+		{name: "duration", from: start + 6, duration: 50 * time.Millisecond, want: ""},
+		{name: "invalid object", from: start + 1, object: "object.metadata.name ==", wantErr: true},
+		{name: "not bool", from: start + 1, object: "'a'", wantErr: true},
+		{name: "keys only", from: start + 1, object: "deleted", key: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-")+".yaml")
+			output := "yaml"
+			if tt.key {
+				output = "key"
+			}
+			err := getCommand(context.Background(), etcdclient, &getFlagpole{
+				Output:        output,
+				Prefix:        "/registry",
+				DecodeMode:    "lenient",
+				MaxBandwidth:  "0",
+				Watch:         true,
+				WatchOnly:     true,
+				FromRevision:  tt.from,
+				UntilDuration: tt.duration,
+				UntilCount:    tt.count,
+				UntilObject:   tt.object,
+				Path:          path,
+			}, []string{"configmaps"})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("getCommand() did not fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, line := range strings.Split(string(data), "\n") {
+				if key, _, ok := strings.Cut(line, " | "); ok {
+					names = append(names, strings.TrimPrefix(key, "# /registry/configmaps/default/"))
+				}
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("written %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCommandWatchDelete(t *testing.T) {
+	etcdclient := fake.NewClient()
+	gr := schema.GroupResource{Resource: "configmaps"}
+	start := etcdclient.Revision()
+	err := etcdclient.Put(context.Background(), "/registry", []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"},"data":{"key":"a0"}}`),
+		client.WithGR(gr),
+		client.WithName("a", "default"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = etcdclient.Delete(context.Background(), "/registry", client.WithGR(gr), client.WithName("a", "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "trace.yaml")
+	err = getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:       "yaml",
+		Prefix:       "/registry",
+		DecodeMode:   "lenient",
+		MaxBandwidth: "0",
+		Watch:        true,
+		WatchOnly:    true,
+		FromRevision: start + 1,
+		UntilCount:   2,
+		Path:         path,
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The delete carries the object as it was last, and is told apart from a write of it
+	_, deleted, ok := strings.Cut(string(data), "# /registry/configmaps/default/a | application/json | deleted\n")
+	if !ok || !strings.Contains(deleted, "key: a0") {
+		t.Fatalf("the delete is not written with the object:\n%s", data)
+	}
+
+	var steps []string
+	err = decodeWatchDocuments(strings.NewReader(string(data)), func(doc watchDocument) error {
+		steps = append(steps, doc.eventType+" "+doc.obj.GetName())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(steps, ","); got != " a,DELETED a" {
+		t.Errorf("decoded %s, want the object then its delete", got)
+	}
+
+	// The watch after a list starts after its revision, the delete before it is not written
+	err = getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:        "yaml",
+		Prefix:        "/registry",
+		DecodeMode:    "lenient",
+		MaxBandwidth:  "0",
+		Watch:         true,
+		UntilDuration: 50 * time.Millisecond,
+		Path:          path,
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "/registry/configmaps/default/a") {
+		t.Errorf("the delete before the list is written:\n%s", data)
+	}
+}
+
+func TestGetCommandOutputVersion(t *testing.T) {
+	etcdclient := fake.NewClient()
+	gr := schema.GroupResource{Group: "policy", Resource: "poddisruptionbudgets"}
+	values := map[string]string{
+		"a": `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget","metadata":{"name":"a","namespace":"default"},"spec":{"selector":{}}}`,
+		"b": `{"apiVersion":"policy/v1","kind":"PodDisruptionBudget","metadata":{"name":"b","namespace":"default"},"spec":{"selector":{}}}`,
+	}
+	for name, value := range values {
+		err := etcdclient.Put(context.Background(), "/registry", []byte(value), client.WithGR(gr), client.WithName(name, "default"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "pdbs.yaml")
+	err := getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:        "yaml",
+		Prefix:        "/registry",
+		DecodeMode:    "lenient",
+		MaxBandwidth:  "0",
+		OutputVersion: "policy/v1",
+		Path:          path,
+	}, []string{"poddisruptionbudgets.policy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The empty selector of policy/v1beta1 selects no pods, it is not printed as the one of policy/v1 selecting all of them
+	_, a, _ := strings.Cut(string(data), "# /registry/poddisruptionbudgets/default/a")
+	a, _, _ = strings.Cut(a, "---\n")
+	if !strings.Contains(a, "apiVersion: policy/v1\n") || !strings.Contains(a, "operator: Exists") {
+		t.Errorf("the policy/v1beta1 object is not converted:\n%s", data)
+	}
+	_, b, _ := strings.Cut(string(data), "# /registry/poddisruptionbudgets/default/b")
+	if !strings.Contains(b, "selector: {}") {
+		t.Errorf("the policy/v1 object is changed:\n%s", data)
+	}
+}
@@ -20,9 +20,13 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"os"
 	"strings"
 	"time"
@@ -30,10 +34,12 @@ import (
 	"github.com/bgentry/speakeasy"
 	"github.com/spf13/cobra"
 	"github.com/wzshiming/kectl/pkg/client"
-	"go.etcd.io/etcd/client/pkg/v3/srv"
 	"go.etcd.io/etcd/client/pkg/v3/transport"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/api/resource"
 
+	_ "github.com/wzshiming/kectl/pkg/apiserver/scheme"
 	_ "github.com/wzshiming/kectl/pkg/old/scheme"
 )
 
@@ -50,6 +56,15 @@ type secureCfg struct {
 type authCfg struct {
 	username string
 	password string
+	token    string
+}
+
+type grpcCfg struct {
+	keepAliveTime       time.Duration
+	keepAliveTimeout    time.Duration
+	permitWithoutStream bool
+	maxSendMsgSize      int
+	maxRecvMsgSize      int
 }
 
 type discoveryCfg struct {
@@ -59,31 +74,48 @@ type discoveryCfg struct {
 }
 
 type clientConfig struct {
-	endpoints        []string
-	dialTimeout      time.Duration
-	keepAliveTime    time.Duration
-	keepAliveTimeout time.Duration
-	scfg             *secureCfg
-	acfg             *authCfg
+	kubeconfig  string
+	portForward bool
+	backend     string
+	endpoints   []string
+	dialTimeout time.Duration
+	gcfg        *grpcCfg
+	scfg        *secureCfg
+	acfg        *authCfg
+	rcfg        *retryCfg
+	sshcfg      *sshCfg
 }
 
 func clientConfigFromCmd(cmd *cobra.Command) (*clientConfig, error) {
-	var err error
-	cfg := &clientConfig{}
-	cfg.endpoints, err = endpointsFromCmd(cmd)
+	err := applyFlavor(cmd.Flags())
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.dialTimeout, err = cmd.Flags().GetDuration("dial-timeout")
+	cfg := &clientConfig{}
+	cfg.kubeconfig, err = cmd.Flags().GetString("kubeconfig")
+	if err != nil {
+		return nil, err
+	}
+	cfg.portForward, err = cmd.Flags().GetBool("port-forward")
+	if err != nil {
+		return nil, err
+	}
+	cfg.backend, err = cmd.Flags().GetString("backend")
 	if err != nil {
 		return nil, err
 	}
-	cfg.keepAliveTime, err = cmd.Flags().GetDuration("keepalive-time")
+	endpoints, srvDomain, err := endpointsFromCmd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	cfg.endpoints = endpoints
+
+	cfg.dialTimeout, err = cmd.Flags().GetDuration("dial-timeout")
 	if err != nil {
 		return nil, err
 	}
-	cfg.keepAliveTimeout, err = cmd.Flags().GetDuration("keepalive-timeout")
+	cfg.gcfg, err = grpcCfgFromCmd(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -91,10 +123,24 @@ func clientConfigFromCmd(cmd *cobra.Command) (*clientConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	if cfg.scfg.serverName == "" {
+		cfg.scfg.serverName = srvDomain
+	}
 	cfg.acfg, err = authCfgFromCmd(cmd)
 	if err != nil {
 		return nil, err
 	}
+	cfg.rcfg, err = retryCfgFromCmd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	cfg.sshcfg, err = sshCfgFromCmd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.sshcfg.target != "" && cfg.portForward {
+		return nil, errors.New("--ssh and --port-forward can not be used together")
+	}
 	return cfg, nil
 }
 
@@ -103,19 +149,119 @@ func clientFromCmd(cmd *cobra.Command) (client.Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return cfg.client()
+	logRequests, err := cmd.Flags().GetBool("log-requests")
+	if err != nil {
+		return nil, err
+	}
+	keyCodecName, err := cmd.Flags().GetString("key-codec")
+	if err != nil {
+		return nil, err
+	}
+	keyCodec, err := client.KeyCodecByName(keyCodecName)
+	if err != nil {
+		return nil, err
+	}
+	etcdNamespace, err := cmd.Flags().GetString("etcd-namespace")
+	if err != nil {
+		return nil, err
+	}
+	if etcdNamespace != "" && cfg.kubeconfig != "" && !cfg.portForward {
+		return nil, errors.New("--etcd-namespace does not apply to the apiserver of --kubeconfig")
+	}
+	etcdclient, err := cfg.client()
+	if err != nil {
+		return nil, err
+	}
+	negotiateFeatures(cmd.Context(), etcdclient, cfg.dialTimeout, cfg.acfg != nil, os.Stderr)
+	etcdclient = client.WithNamespace(etcdclient, etcdNamespace)
+	etcdclient = client.WithKeyCodec(etcdclient, keyCodec)
+	if logRequests {
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		etcdclient = client.WithHook(etcdclient, client.NewSlogHook(logger))
+	}
+	return etcdclient, nil
+}
+
+// negotiateFeaturesTimeout bounds the negotiation without a dial timeout.
+const negotiateFeaturesTimeout = 2 * time.Second
+
+// negotiateFeatures negotiates the features of the etcd server for the client to adapt to,
+// warning to w about what the server does not support before a command fails deep into it.
+// The negotiation is bounded by the timeout, the one of dialing, not to hold up a command on a server it can not reach.
+func negotiateFeatures(ctx context.Context, etcdclient client.Client, timeout time.Duration, authenticated bool, w io.Writer) {
+	if timeout <= 0 {
+		timeout = negotiateFeaturesTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	features, err := client.NegotiateFeatures(ctx, etcdclient)
+	if err != nil {
+		if !errors.Is(err, client.ErrFeaturesUnsupported) {
+			fmt.Fprintf(w, "warning: can not negotiate the features of the server: %v\n", err)
+		}
+		return
+	}
+	for _, warning := range features.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+	if features.AuthEnabled != nil && *features.AuthEnabled && !authenticated {
+		fmt.Fprintf(w, "warning: the authentication of the server is enabled, the requests without --user or --auth-token are denied\n")
+	}
 }
 
 func (cc *clientConfig) client() (client.Client, error) {
-	cfg, err := newClientCfg(cc.endpoints, cc.dialTimeout, cc.keepAliveTime, cc.keepAliveTimeout, cc.scfg, cc.acfg)
+	if cc.portForward {
+		endpoint, err := portForwardEtcd(context.Background(), cc.kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		cc.endpoints = []string{endpoint}
+	} else if cc.kubeconfig != "" {
+		return client.NewAPIServerClient(cc.kubeconfig)
+	}
+
+	if cc.sshcfg != nil && cc.sshcfg.target != "" {
+		return cc.clientOverSSH()
+	}
+
+	cfg, err := newClientCfg(cc.endpoints, cc.dialTimeout, cc.gcfg, cc.scfg, cc.acfg, cc.rcfg)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.NewClient(*cfg)
+	return client.NewClientWithBackend(cc.backend, *cfg)
 }
 
-func newClientCfg(endpoints []string, dialTimeout, keepAliveTime, keepAliveTimeout time.Duration, scfg *secureCfg, acfg *authCfg) (*clientv3.Config, error) {
+// clientOverSSH returns the client that dials etcd from the remote host of --ssh.
+func (cc *clientConfig) clientOverSSH() (client.Client, error) {
+	tunnel, err := dialSSH(cc.sshcfg)
+	if err != nil {
+		return nil, err
+	}
+
+	scfg := cc.scfg
+	if cc.sshcfg.remoteCerts {
+		// The certificates are not local files
+		local := *cc.scfg
+		local.cert, local.key, local.cacert = "", "", ""
+		scfg = &local
+	}
+	cfg, err := newClientCfg(cc.endpoints, cc.dialTimeout, cc.gcfg, scfg, cc.acfg, cc.rcfg)
+	if err != nil {
+		return nil, err
+	}
+	if cc.sshcfg.remoteCerts && (cc.scfg.cert != "" || cc.scfg.key != "" || cc.scfg.cacert != "" || !cc.scfg.insecureTransport) {
+		cfg.TLS, err = tunnel.tlsConfig(cc.scfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cfg.DialOptions = append(cfg.DialOptions, grpc.WithContextDialer(tunnel.dialContext))
+
+	return client.NewClientWithBackend(cc.backend, *cfg)
+}
+
+func newClientCfg(endpoints []string, dialTimeout time.Duration, gcfg *grpcCfg, scfg *secureCfg, acfg *authCfg, rcfg *retryCfg) (*clientv3.Config, error) {
 	// set tls if any one tls option set
 	var cfgtls *transport.TLSInfo
 	tlsinfo := transport.TLSInfo{}
@@ -140,10 +286,17 @@ func newClientCfg(endpoints []string, dialTimeout, keepAliveTime, keepAliveTimeo
 	}
 
 	cfg := &clientv3.Config{
-		Endpoints:            endpoints,
-		DialTimeout:          dialTimeout,
-		DialKeepAliveTime:    keepAliveTime,
-		DialKeepAliveTimeout: keepAliveTimeout,
+		Endpoints:   endpoints,
+		DialTimeout: dialTimeout,
+	}
+
+	if gcfg != nil {
+		cfg.DialKeepAliveTime = gcfg.keepAliveTime
+		cfg.DialKeepAliveTimeout = gcfg.keepAliveTimeout
+		cfg.PermitWithoutStream = gcfg.permitWithoutStream
+		// The etcd client defaults to 2MiB to send and 2GiB to receive, for the ones left 0
+		cfg.MaxCallSendMsgSize = gcfg.maxSendMsgSize
+		cfg.MaxCallRecvMsgSize = gcfg.maxRecvMsgSize
 	}
 
 	if cfgtls != nil {
@@ -170,6 +323,28 @@ func newClientCfg(endpoints []string, dialTimeout, keepAliveTime, keepAliveTimeo
 	if acfg != nil {
 		cfg.Username = acfg.username
 		cfg.Password = acfg.password
+		if acfg.token != "" {
+			cfg.DialOptions = append(cfg.DialOptions, grpc.WithPerRPCCredentials(tokenCredentials(acfg.token)))
+		}
+	}
+
+	if rcfg != nil {
+		// The transient errors are retried by the interceptor, which runs inside the one of the etcd client,
+		// the etcd client only keeps a second attempt to refresh the auth token of the user.
+		cfg.MaxUnaryRetries = 1
+		if cfg.Username != "" {
+			cfg.MaxUnaryRetries = 2
+		}
+		cfg.DialOptions = append(cfg.DialOptions, grpc.WithChainUnaryInterceptor(rcfg.unaryInterceptor()))
+	}
+
+	if len(endpoints) > 1 {
+		// The service config of the etcd client is replaced by the one with the health checking of the members
+		cfg.DialOptions = append(cfg.DialOptions,
+			grpc.WithDisableServiceConfig(),
+			grpc.WithDefaultServiceConfig(endpointServiceConfig),
+			grpc.WithChainUnaryInterceptor(newEndpointHealth(os.Stderr).unaryInterceptor()),
+		)
 	}
 
 	return cfg, nil
@@ -242,6 +417,17 @@ func authCfgFromCmd(cmd *cobra.Command) (*authCfg, error) {
 	if err != nil {
 		return nil, err
 	}
+	tokenFlag, err := cmd.Flags().GetString("auth-token")
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenFlag != "" {
+		if userFlag != "" {
+			return nil, errors.New("--auth-token and --user can not be used together")
+		}
+		return &authCfg{token: tokenFlag}, nil
+	}
 
 	if userFlag == "" {
 		return nil, nil
@@ -269,6 +455,107 @@ func authCfgFromCmd(cmd *cobra.Command) (*authCfg, error) {
 	return &cfg, nil
 }
 
+func grpcCfgFromCmd(cmd *cobra.Command) (*grpcCfg, error) {
+	keepAliveTime, err := cmd.Flags().GetDuration("keepalive-time")
+	if err != nil {
+		return nil, err
+	}
+	keepAliveTimeout, err := cmd.Flags().GetDuration("keepalive-timeout")
+	if err != nil {
+		return nil, err
+	}
+	permitWithoutStream, err := cmd.Flags().GetBool("keepalive-permit-without-stream")
+	if err != nil {
+		return nil, err
+	}
+	maxSendMsgSize, err := msgSizeFromCmd(cmd, "max-send-msg-size")
+	if err != nil {
+		return nil, err
+	}
+	maxRecvMsgSize, err := msgSizeFromCmd(cmd, "max-recv-msg-size")
+	if err != nil {
+		return nil, err
+	}
+	return &grpcCfg{
+		keepAliveTime:       keepAliveTime,
+		keepAliveTimeout:    keepAliveTimeout,
+		permitWithoutStream: permitWithoutStream,
+		maxSendMsgSize:      maxSendMsgSize,
+		maxRecvMsgSize:      maxRecvMsgSize,
+	}, nil
+}
+
+// msgSizeFromCmd returns the size of the messages of the flag in bytes, such as 16Mi,
+// up to the largest message of gRPC.
+func msgSizeFromCmd(cmd *cobra.Command, name string) (int, error) {
+	s, err := cmd.Flags().GetString(name)
+	if err != nil {
+		return 0, err
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --%s %q: %w", name, s, err)
+	}
+	size, ok := q.AsInt64()
+	if !ok || size < 0 || size > math.MaxInt32 {
+		return 0, fmt.Errorf("invalid --%s %q", name, s)
+	}
+	return int(size), nil
+}
+
+func retryCfgFromCmd(cmd *cobra.Command) (*retryCfg, error) {
+	requestTimeout, err := cmd.Flags().GetDuration("request-timeout")
+	if err != nil {
+		return nil, err
+	}
+	maxRetries, err := cmd.Flags().GetInt("max-retries")
+	if err != nil {
+		return nil, err
+	}
+	backoff, err := cmd.Flags().GetDuration("retry-backoff")
+	if err != nil {
+		return nil, err
+	}
+	maxBackoff, err := cmd.Flags().GetDuration("retry-max-backoff")
+	if err != nil {
+		return nil, err
+	}
+	if maxRetries < 0 {
+		return nil, fmt.Errorf("invalid --max-retries %d", maxRetries)
+	}
+	return &retryCfg{
+		requestTimeout: requestTimeout,
+		maxRetries:     maxRetries,
+		backoff:        backoff,
+		maxBackoff:     maxBackoff,
+	}, nil
+}
+
+func sshCfgFromCmd(cmd *cobra.Command) (*sshCfg, error) {
+	target, err := cmd.Flags().GetString("ssh")
+	if err != nil {
+		return nil, err
+	}
+	identity, err := cmd.Flags().GetString("ssh-identity")
+	if err != nil {
+		return nil, err
+	}
+	insecureIgnoreHostKey, err := cmd.Flags().GetBool("ssh-insecure-ignore-host-key")
+	if err != nil {
+		return nil, err
+	}
+	remoteCerts, err := cmd.Flags().GetBool("ssh-remote-certs")
+	if err != nil {
+		return nil, err
+	}
+	return &sshCfg{
+		target:                target,
+		identity:              identity,
+		insecureIgnoreHostKey: insecureIgnoreHostKey,
+		remoteCerts:           remoteCerts,
+	}, nil
+}
+
 func discoveryCfgFromCmd(cmd *cobra.Command) (*discoveryCfg, error) {
 	domain, err := cmd.Flags().GetString("discovery-srv")
 	if err != nil {
@@ -289,50 +576,35 @@ func discoveryCfgFromCmd(cmd *cobra.Command) (*discoveryCfg, error) {
 	}, nil
 }
 
-func endpointsFromCmd(cmd *cobra.Command) ([]string, error) {
-	eps, err := endpointsFromFlagValue(cmd)
+// endpointsFromCmd returns the endpoints of the SRV records of --discovery-srv if any,
+// otherwise the --endpoints with the dns+srv ones resolved, along with the domain the server name is verified against.
+func endpointsFromCmd(cmd *cobra.Command) ([]string, string, error) {
+	discoveryCfg, err := discoveryCfgFromCmd(cmd)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	// If domain discovery returns no endpoints, check endpoints flag
-	if len(eps) == 0 {
-		eps, err = cmd.Flags().GetStringSlice("endpoints")
-		if err == nil {
-			for i, ip := range eps {
-				eps[i] = strings.TrimSpace(ip)
-			}
+
+	if discoveryCfg.domain != "" {
+		eps, err := srvEndpoints(discoveryCfg.domain, discoveryCfg.serviceName, discoveryCfg.insecure)
+		if err != nil {
+			return nil, "", err
+		}
+		// If domain discovery returns no endpoints, check endpoints flag
+		if len(eps) != 0 {
+			return eps, "", nil
 		}
 	}
-	return eps, err
-}
 
-func endpointsFromFlagValue(cmd *cobra.Command) ([]string, error) {
-	discoveryCfg, err := discoveryCfgFromCmd(cmd)
+	eps, err := cmd.Flags().GetStringSlice("endpoints")
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
-	// If we still don't have domain discovery, return nothing
-	if discoveryCfg.domain == "" {
-		return []string{}, nil
-	}
-
-	srvs, err := srv.GetClient("etcd-client", discoveryCfg.domain, discoveryCfg.serviceName)
+	eps, domain, err := expandEndpoints(eps, discoveryCfg.insecure)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	eps := srvs.Endpoints
 	if discoveryCfg.insecure {
-		return eps, err
-	}
-	// strip insecure connections
-	ret := []string{}
-	for _, ep := range eps {
-		if strings.HasPrefix(ep, "http://") {
-			fmt.Fprintf(os.Stderr, "ignoring discovered insecure endpoint %q\n", ep)
-			continue
-		}
-		ret = append(ret, ep)
+		domain = ""
 	}
-	return ret, err
+	return eps, domain, nil
 }
@@ -0,0 +1,86 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestGRPCCfgFromCmd(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    grpcCfg
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: grpcCfg{
+				keepAliveTime:    2 * time.Second,
+				keepAliveTimeout: 6 * time.Second,
+			},
+		},
+		{
+			name: "sizes",
+			args: []string{"--keepalive-permit-without-stream", "--max-send-msg-size=16Mi", "--max-recv-msg-size=1G"},
+			want: grpcCfg{
+				keepAliveTime:       2 * time.Second,
+				keepAliveTimeout:    6 * time.Second,
+				permitWithoutStream: true,
+				maxSendMsgSize:      16 << 20,
+				maxRecvMsgSize:      1e9,
+			},
+		},
+		{
+			name:    "larger than gRPC",
+			args:    []string{"--max-recv-msg-size=4Gi"},
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			args:    []string{"--max-send-msg-size=-1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *grpcCfg
+			cmd := NewCtlCommand()
+			cmd.AddCommand(&cobra.Command{
+				Use: "test",
+				RunE: func(cmd *cobra.Command, args []string) error {
+					var err error
+					got, err = grpcCfgFromCmd(cmd)
+					return err
+				},
+			})
+			cmd.SetArgs(append([]string{"test"}, tt.args...))
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			err := cmd.Execute()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("grpcCfgFromCmd() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("grpcCfgFromCmd() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/spf13/cobra"
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type grepFlagpole struct {
+	Resource  string
+	Namespace string
+	Name      string
+	Where     string
+	Output    string
+	Prefix    string
+}
+
+func newCtlGrepCommand() *cobra.Command {
+	flags := &grepFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "grep <path>",
+		Short: "Searches a recording for the documents of the objects matching patterns or a predicate",
+		Long: "Searches a recording, - for stdin, for the documents of the objects whose resource, namespace and name match the globs,\n" +
+			"and whose object matches the CEL predicate of --where, with the time they are recorded at, to tell when an object changed without replaying the recording.\n" +
+			"The time of a document of a recording of kectl is the one of the bookmark before it, and the one of a kwok recording the duration of its change.\n" +
+			"The objects of a kwok recording are followed through its changes for the predicate to see the whole objects, the deleted one when deleted is true.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := grepCommand(flags, args[0], os.Stdout)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Resource, "resource", "", "glob of the resources to match, such as pods or *.apps")
+	cmd.Flags().StringVarP(&flags.Namespace, "namespace", "n", "", "glob of the namespaces to match")
+	cmd.Flags().StringVar(&flags.Name, "name", "", "glob of the names to match, such as web-*")
+	cmd.Flags().StringVar(&flags.Where, "where", "", "CEL predicate of the objects to match, the object deleted when deleted is true, such as \"object.status.phase == 'Failed'\"")
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "line", "output format. line prints the time, revision, event, resource and name of each match, and document the documents as recorded. One of: (line, document).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+
+	return cmd
+}
+
+func grepCommand(flags *grepFlagpole, input string, w io.Writer) (err error) {
+	if flags.Output != "line" && flags.Output != "document" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+	for _, pattern := range []string{flags.Resource, flags.Namespace, flags.Name} {
+		_, err = path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+	g := &recordingGrep{
+		w:         w,
+		prefix:    flags.Prefix,
+		resource:  flags.Resource,
+		namespace: flags.Namespace,
+		name:      flags.Name,
+		document:  flags.Output == "document",
+	}
+	if flags.Where != "" {
+		g.program, err = compileObjectPredicate("--where", flags.Where)
+		if err != nil {
+			return err
+		}
+		g.objects = kwokObjects{}
+	}
+
+	var r io.ReadCloser
+	if input == "-" {
+		r, err = decompress(os.Stdin)
+	} else {
+		r, err = openRecording(input)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	scanner := newRecordingScanner(r, flags.Prefix)
+	g.jsonl = scanner.JSONL
+	err = scanner.Scan(g.grep)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "found %d of %d documents\n", g.matched, g.documents)
+	return nil
+}
+
+// recordingGrep writes the documents of a recording matching the globs and the predicate.
+type recordingGrep struct {
+	w         io.Writer
+	prefix    string
+	resource  string
+	namespace string
+	name      string
+	program   cel.Program
+	document  bool
+	jsonl     bool
+
+	recordingClock
+	// objects is the objects of a kwok recording followed through its changes, for the predicate only
+	objects kwokObjects
+
+	documents int
+	matched   int
+}
+
+func (g *recordingGrep) grep(doc *recordingRawDocument) error {
+	switch {
+	case doc.Header, doc.Bookmark != nil:
+		g.tick(doc)
+		return nil
+	case doc.Key != "":
+		return g.key(doc)
+	case doc.Object != nil:
+		if isKwokResourcePatch(doc.Object) {
+			return g.resourcePatch(doc)
+		}
+		ref, _, err := snapshotObjectRefOf(doc.Object)
+		if err != nil {
+			return err
+		}
+		if g.objects != nil {
+			g.objects[ref] = doc.Object
+		}
+		return g.match(doc, g.time(), "object", ref.gr, ref.namespace, ref.name, doc.Object.Object, false)
+	}
+	return nil
+}
+
+func (g *recordingGrep) key(doc *recordingRawDocument) error {
+	gr, rest, ok := groupResourceFromKey(g.prefix, doc.Key)
+	if !ok {
+		return fmt.Errorf("key %q is not under %q", doc.Key, g.prefix)
+	}
+	namespace, name, err := splitName(rest)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", doc.Key, err)
+	}
+
+	event := g.method(doc)
+
+	var object map[string]any
+	if g.program != nil && g.matchName(gr, namespace, name) {
+		object, err = keyDocumentObject(doc, g.jsonl)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", doc.Key, err)
+		}
+	}
+	return g.match(doc, g.time(), event, gr, namespace, name, object, doc.Deleted)
+}
+
+// resourcePatch matches the change of a kwok recording, against the object as of the change.
+func (g *recordingGrep) resourcePatch(doc *recordingRawDocument) error {
+	rp, err := decodeKwokResourcePatch(doc.Object)
+	if err != nil {
+		return err
+	}
+	ref := snapshotObjectRef{
+		gr:        rp.Resource.GroupResource(),
+		namespace: rp.Target.Namespace,
+		name:      rp.Target.Name,
+	}
+	event := kwokPatchMethod(rp)
+	at := "+" + rp.DurationNanosecond.String()
+
+	var object map[string]any
+	deleted := rp.Method == actionv1alpha1.PatchMethodDelete
+	if g.objects != nil {
+		obj, err := g.objects.apply(rp)
+		if err != nil {
+			return err
+		}
+		if obj != nil {
+			object = obj.Object
+		}
+	}
+	return g.match(doc, at, event, ref.gr, ref.namespace, ref.name, object, deleted)
+}
+
+func (g *recordingGrep) time() string {
+	if g.at.IsZero() {
+		return "-"
+	}
+	return g.at.UTC().Format(time.RFC3339)
+}
+
+func (g *recordingGrep) matchName(gr schema.GroupResource, namespace, name string) bool {
+	if g.resource != "" {
+		full, _ := path.Match(g.resource, gr.String())
+		short, _ := path.Match(g.resource, gr.Resource)
+		if !full && !short {
+			return false
+		}
+	}
+	if g.namespace != "" {
+		if ok, _ := path.Match(g.namespace, namespace); !ok {
+			return false
+		}
+	}
+	if g.name != "" {
+		if ok, _ := path.Match(g.name, name); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *recordingGrep) match(doc *recordingRawDocument, at, event string, gr schema.GroupResource, namespace, name string, object map[string]any, deleted bool) error {
+	g.documents++
+	if !g.matchName(gr, namespace, name) {
+		return nil
+	}
+	if g.program != nil && !matchObjectPredicate(g.program, object, deleted) {
+		return nil
+	}
+	g.matched++
+
+	if g.document {
+		_, err := g.w.Write(doc.Data)
+		return err
+	}
+	revision := "-"
+	if doc.Revision != 0 {
+		revision = strconv.FormatInt(doc.Revision, 10)
+	}
+	_, err := fmt.Fprintf(g.w, "%s %s %s %s %s\n", at, revision, event, gr, eventTarget(namespace, name))
+	return err
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGrepCommand(t *testing.T) {
+	const recording = `---
+# clock-skew | 10ms | 500ms | 2024-01-01T00:00:00Z
+---
+# /registry/pods/default/web-1 | application/yaml | 2
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-1
+  namespace: default
+status:
+  phase: Running
+---
+# /registry/deployments/default/web | application/yaml | 2
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+---
+# bookmark | 3 | 2024-01-01T00:05:00Z
+---
+# /registry/pods/default/web-1 | application/yaml | 4
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-1
+  namespace: default
+status:
+  phase: Failed
+---
+# /registry/pods/kube-system/dns | application/yaml | 5
+apiVersion: v1
+kind: Pod
+metadata:
+  name: dns
+  namespace: kube-system
+status:
+  phase: Failed
+---
+# bookmark | 6 | 2024-01-01T00:10:00Z
+---
+# /registry/pods/default/web-1 | 7 | deleted
+`
+	const kwokRecording = `---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-1
+  namespace: default
+status:
+  phase: Running
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: web-1
+  namespace: default
+durationNanosecond: 1000
+method: patch
+template:
+  status:
+    phase: Failed
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: web-1
+  namespace: default
+durationNanosecond: 2000
+method: delete
+`
+	tests := []struct {
+		name    string
+		input   string
+		flags   grepFlagpole
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "all",
+			input: recording,
+			want: []string{
+				"2024-01-01T00:00:00Z 2 object pods default/web-1",
+				"2024-01-01T00:00:00Z 2 object deployments.apps default/web",
+				"2024-01-01T00:05:00Z 4 update pods default/web-1",
+				"2024-01-01T00:05:00Z 5 create pods kube-system/dns",
+				"2024-01-01T00:10:00Z 7 delete pods default/web-1",
+			},
+		},
+		{
+			name:  "globs",
+			input: recording,
+			flags: grepFlagpole{Resource: "*.apps", Namespace: "def*"},
+			want: []string{
+				"2024-01-01T00:00:00Z 2 object deployments.apps default/web",
+			},
+		},
+		{
+			name:  "where",
+			input: recording,
+			flags: grepFlagpole{Name: "web-*", Where: "!deleted && object.status.phase == 'Failed'"},
+			want: []string{
+				"2024-01-01T00:05:00Z 4 update pods default/web-1",
+			},
+		},
+		{
+			name:  "kwok",
+			input: kwokRecording,
+			flags: grepFlagpole{Where: "object.status.phase == 'Failed'"},
+			want: []string{
+				"+1µs - patch pods default/web-1",
+				"+2µs - delete pods default/web-1",
+			},
+		},
+		{
+			name:    "invalid glob",
+			input:   recording,
+			flags:   grepFlagpole{Name: "["},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := filepath.Join(t.TempDir(), "recording.yaml")
+			err := os.WriteFile(input, []byte(tt.input), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			flags := tt.flags
+			flags.Output = "line"
+			flags.Prefix = "/registry"
+			var buf bytes.Buffer
+			err = grepCommand(&flags, input, &buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("grepCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("grepCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+// watchProgressInterval is how often the progress of the watch is requested for the health and the checkpoint,
+// when no bookmarks are requested with --progress-interval.
+const watchProgressInterval = 10 * time.Second
+
+// watchHealth tracks the progress of a long running watch, to be served on /healthz and /status
+// for an orchestration system to restart or alert on a degraded recorder.
+type watchHealth struct {
+	maxLag     time.Duration
+	maxObjects int
+	// current returns the revision of etcd, to tell the lag of the watch behind it
+	current func(ctx context.Context) (int64, error)
+
+	mut              sync.Mutex
+	start            time.Time
+	lastRevision     int64
+	lastProgressTime time.Time
+	objects          map[string]struct{}
+	highWatermark    int
+	events           int64
+	bytes            int64
+	alerted          bool
+}
+
+// watchStatus is the status served on /status.
+type watchStatus struct {
+	Healthy              bool      `json:"healthy"`
+	Reasons              []string  `json:"reasons,omitempty"`
+	StartTime            time.Time `json:"startTime"`
+	LastRevision         int64     `json:"lastRevision"`
+	CurrentRevision      int64     `json:"currentRevision,omitempty"`
+	LagRevisions         int64     `json:"lagRevisions"`
+	LastProgressTime     time.Time `json:"lastProgressTime"`
+	LagSeconds           float64   `json:"lagSeconds"`
+	Objects              int       `json:"objects"`
+	ObjectsHighWatermark int       `json:"objectsHighWatermark"`
+	Events               int64     `json:"events"`
+	RecordingBytes       int64     `json:"recordingBytes"`
+	MemoryBytes          uint64    `json:"memoryBytes"`
+}
+
+func newWatchHealth(maxLag time.Duration, maxObjects int, current func(ctx context.Context) (int64, error)) *watchHealth {
+	now := time.Now()
+	return &watchHealth{
+		maxLag:           maxLag,
+		maxObjects:       maxObjects,
+		current:          current,
+		start:            now,
+		lastProgressTime: now,
+		objects:          map[string]struct{}{},
+	}
+}
+
+// wrap returns the response callback that tracks the objects and the revision of each key-value it is called with.
+func (h *watchHealth) wrap(response func(kv *client.KeyValue) error) func(kv *client.KeyValue) error {
+	if h == nil {
+		return response
+	}
+	return func(kv *client.KeyValue) error {
+		err := response(kv)
+		if err != nil {
+			return err
+		}
+		h.observe(kv)
+		return nil
+	}
+}
+
+func (h *watchHealth) observe(kv *client.KeyValue) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	h.events++
+	if len(kv.Value) == 0 {
+		delete(h.objects, string(kv.Key))
+	} else {
+		h.objects[string(kv.Key)] = struct{}{}
+	}
+	if n := len(h.objects); n > h.highWatermark {
+		h.highWatermark = n
+		if h.maxObjects != 0 && n > h.maxObjects && !h.alerted {
+			h.alerted = true
+			fmt.Fprintf(os.Stderr, "warning: %d objects tracked, over the limit of %d\n", n, h.maxObjects)
+		}
+	}
+	if kv.Revision > h.lastRevision {
+		h.lastRevision = kv.Revision
+		h.lastProgressTime = time.Now()
+	}
+}
+
+// progress records that the watch is complete up to the revision, such as at the end of the list or at a bookmark.
+func (h *watchHealth) progress(revision int64) {
+	if h == nil {
+		return
+	}
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	if revision >= h.lastRevision {
+		h.lastRevision = revision
+		h.lastProgressTime = time.Now()
+	}
+}
+
+// countWriter returns the writer counting the bytes written to the recording.
+func (h *watchHealth) countWriter(w io.Writer) io.Writer {
+	if h == nil {
+		return w
+	}
+	return writerFunc(func(p []byte) (int, error) {
+		n, err := w.Write(p)
+		h.mut.Lock()
+		h.bytes += int64(n)
+		h.mut.Unlock()
+		return n, err
+	})
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// status returns the status of the watch. It is unhealthy once it has fallen behind etcd for longer than the max lag,
+// or tracks more objects than the max objects.
+func (h *watchHealth) status(ctx context.Context, now time.Time) watchStatus {
+	var current int64
+	var currentErr error
+	if h.current != nil {
+		current, currentErr = h.current(ctx)
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	s := watchStatus{
+		Healthy:              true,
+		StartTime:            h.start,
+		LastRevision:         h.lastRevision,
+		CurrentRevision:      current,
+		LastProgressTime:     h.lastProgressTime,
+		Objects:              len(h.objects),
+		ObjectsHighWatermark: h.highWatermark,
+		Events:               h.events,
+		RecordingBytes:       h.bytes,
+		MemoryBytes:          mem.HeapAlloc,
+	}
+	if current > h.lastRevision {
+		s.LagRevisions = current - h.lastRevision
+		s.LagSeconds = now.Sub(h.lastProgressTime).Seconds()
+	}
+
+	if currentErr != nil {
+		s.Reasons = append(s.Reasons, fmt.Sprintf("failed to get the revision of etcd: %v", currentErr))
+	}
+	if h.maxLag != 0 && s.LagRevisions != 0 && now.Sub(h.lastProgressTime) > h.maxLag {
+		s.Reasons = append(s.Reasons, fmt.Sprintf("%d revisions behind etcd for %s, over the max lag of %s", s.LagRevisions, now.Sub(h.lastProgressTime).Truncate(time.Second), h.maxLag))
+	}
+	if h.maxObjects != 0 && s.Objects > h.maxObjects {
+		s.Reasons = append(s.Reasons, fmt.Sprintf("%d objects tracked, over the max objects of %d", s.Objects, h.maxObjects))
+	}
+	s.Healthy = len(s.Reasons) == 0
+	return s
+}
+
+func (h *watchHealth) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		s := h.status(r.Context(), time.Now())
+		if !s.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, strings.Join(s.Reasons, "\n"))
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		s := h.status(r.Context(), time.Now())
+		w.Header().Set("Content-Type", "application/json")
+		if !s.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(s)
+	})
+	return mux
+}
+
+// serve serves the health on the address until the ctx is done.
+func (h *watchHealth) serve(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Handler:           h.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "warning: health server: %v\n", err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "serve the health on http://%s/healthz and /status\n", listener.Addr())
+	return nil
+}
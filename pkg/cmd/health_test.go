@@ -0,0 +1,99 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+func TestWatchHealth(t *testing.T) {
+	current := int64(10)
+	h := newWatchHealth(time.Minute, 2, func(ctx context.Context) (int64, error) {
+		return current, nil
+	})
+	response := h.wrap(func(kv *client.KeyValue) error {
+		return nil
+	})
+	for _, kv := range []*client.KeyValue{
+		{Key: []byte("/registry/pods/default/a"), Value: []byte("a"), Revision: 2},
+		{Key: []byte("/registry/pods/default/b"), Value: []byte("b"), Revision: 3},
+		{Key: []byte("/registry/pods/default/a"), Value: []byte("a2"), Revision: 4},
+		{Key: []byte("/registry/pods/default/b"), Revision: 5},
+	} {
+		err := response(kv)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := h.countWriter(io.Discard).Write([]byte("written"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := h.status(context.Background(), h.lastProgressTime.Add(2*time.Minute))
+	if s.Healthy || len(s.Reasons) != 1 {
+		t.Errorf("status() = %+v, want unhealthy by the lag", s)
+	}
+	if s.LastRevision != 5 || s.LagRevisions != 5 || s.Objects != 1 || s.ObjectsHighWatermark != 2 || s.Events != 4 || s.RecordingBytes != 7 {
+		t.Errorf("status() = %+v", s)
+	}
+
+	// A bookmark at the revision of etcd catches up
+	h.progress(10)
+	s = h.status(context.Background(), h.lastProgressTime.Add(2*time.Minute))
+	if !s.Healthy || s.LagRevisions != 0 {
+		t.Errorf("status() = %+v, want healthy", s)
+	}
+
+	// Behind, but not for longer than the max lag
+	current = 12
+	s = h.status(context.Background(), h.lastProgressTime.Add(time.Second))
+	if !s.Healthy || s.LagRevisions != 2 {
+		t.Errorf("status() = %+v, want healthy", s)
+	}
+
+	for _, key := range []string{"c", "d"} {
+		err := response(&client.KeyValue{Key: []byte(key), Value: []byte("v"), Revision: 11})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s = h.status(context.Background(), h.lastProgressTime)
+	if s.Healthy || s.ObjectsHighWatermark != 3 {
+		t.Errorf("status() = %+v, want unhealthy by the objects", s)
+	}
+
+	server := httptest.NewServer(h.handler())
+	defer server.Close()
+	for _, path := range []string{"/healthz", "/status"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("%s status code = %d, want %d", path, resp.StatusCode, http.StatusServiceUnavailable)
+		}
+	}
+}
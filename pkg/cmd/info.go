@@ -0,0 +1,353 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type infoFlagpole struct {
+	Output string
+	Prefix string
+}
+
+func newCtlInfoCommand() *cobra.Command {
+	flags := &infoFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "info <path>",
+		Short: "Summarizes a recording before replaying it",
+		Long: "Summarizes a recording, - for stdin, without putting it: its format, compression, revisions, times and duration,\n" +
+			"the objects it starts with and the events following them, by resource and by method.\n" +
+			"The methods of a recording of kectl are the creates, updates and deletes of its keys, and the ones of a kwok recording the ones of its changes,\n" +
+			"with its checkpoints apart. The whole recording is read through, `recording info` reports a complete v2 recording from its index alone.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := infoCommand(flags, args[0])
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+
+	return cmd
+}
+
+// recordingSummary is the info of a recording, with what its documents are.
+type recordingSummary struct {
+	*recordingInfo
+	Encrypted   bool   `json:"encrypted,omitempty"`
+	Compression string `json:"compression,omitempty"`
+	StoredBytes int64  `json:"storedBytes"`
+	// KwokVersion is the version of the ResourcePatches of a kwok recording
+	KwokVersion string     `json:"kwokVersion,omitempty"`
+	ClockSkew   *clockSkew `json:"clockSkew,omitempty"`
+	Duration    string     `json:"duration,omitempty"`
+	// Objects is the number of the objects the recording starts with, and Events the number of the changes following them
+	Objects   int                         `json:"objects"`
+	Events    int                         `json:"events"`
+	Methods   map[string]int              `json:"methods,omitempty"`
+	Resources []*recordingSummaryResource `json:"resources,omitempty"`
+}
+
+type recordingSummaryResource struct {
+	Resource string `json:"resource"`
+	// Objects is the number of the objects of the resource in the recording, the ones it starts with or creates
+	Objects int `json:"objects"`
+	Events  int `json:"events"`
+}
+
+// byteCounter counts the bytes read through it.
+type byteCounter struct {
+	r io.Reader
+	n int64
+}
+
+func (c *byteCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// sniffCompression returns the compression of a recording as stored, the one of the chunks of the v2 format.
+func sniffCompression(br *bufio.Reader) string {
+	if isRecordingV2(br) {
+		return compressionZstd
+	}
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, zstdMagic):
+		return compressionZstd
+	case bytes.HasPrefix(magic, gzipMagic):
+		return compressionGzip
+	}
+	return compressionNone
+}
+
+func readRecordingSummary(path, prefix string) (*recordingSummary, error) {
+	src, err := openRecordingSource(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	stored := &byteCounter{r: src}
+	br := bufio.NewReader(stored)
+	s := newRecordingSummarizer(prefix)
+	format := recordingFormatV1
+	if isRecordingEncrypted(br) {
+		s.summary.Encrypted = true
+	} else {
+		s.summary.Compression = sniffCompression(br)
+		if isRecordingV2(br) {
+			format = recordingFormatV2
+		} else if isRecordingBinary(br) {
+			format = recordingFormatBinary
+		}
+	}
+	r, err := decompress(br)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// The documents are indexed as they would be written in the v2 format, while they are summarized
+	indexer, err := newRecordingV2Writer(io.Discard, prefix)
+	if err != nil {
+		return nil, err
+	}
+	err = newRecordingScanner(io.TeeReader(r, indexer), prefix).Scan(s.add)
+	if err != nil {
+		return nil, err
+	}
+	err = indexer.Close()
+	if err != nil {
+		return nil, err
+	}
+	s.summary.recordingInfo = newRecordingInfo(format, &indexer.index)
+	s.summary.StoredBytes = stored.n
+	return s.finish(), nil
+}
+
+// recordingSummarizer counts the documents of a recording by what they are.
+type recordingSummarizer struct {
+	prefix  string
+	summary *recordingSummary
+
+	recordingClock
+	// duration is the duration of the last change of a kwok recording, and kwok whether it has any
+	duration  time.Duration
+	kwok      bool
+	seen      map[string]bool
+	resources map[string]*recordingSummaryResource
+}
+
+func newRecordingSummarizer(prefix string) *recordingSummarizer {
+	return &recordingSummarizer{
+		prefix: prefix,
+		summary: &recordingSummary{
+			Methods: map[string]int{},
+		},
+		seen:      map[string]bool{},
+		resources: map[string]*recordingSummaryResource{},
+	}
+}
+
+func (s *recordingSummarizer) add(doc *recordingRawDocument) error {
+	switch {
+	case doc.Header:
+		s.tick(doc)
+		if doc.ClockSkew != nil {
+			s.summary.ClockSkew = doc.ClockSkew
+		}
+		if doc.Object != nil {
+			s.summary.KwokVersion = doc.Object.GetAPIVersion()
+		}
+	case doc.Bookmark != nil:
+		s.tick(doc)
+	case doc.Key != "":
+		gr, _, ok := groupResourceFromKey(s.prefix, doc.Key)
+		if !ok {
+			return fmt.Errorf("key %q is not under %q", doc.Key, s.prefix)
+		}
+		method := s.method(doc)
+		if method == "object" {
+			s.object(gr.String(), doc.Key)
+			return nil
+		}
+		s.event(gr.String(), doc.Key, method)
+	case doc.Object != nil:
+		if !isKwokResourcePatch(doc.Object) {
+			ref, _, err := snapshotObjectRefOf(doc.Object)
+			if err != nil {
+				return err
+			}
+			s.object(ref.gr.String(), ref.String())
+			return nil
+		}
+		rp, err := decodeKwokResourcePatch(doc.Object)
+		if err != nil {
+			return err
+		}
+		if s.summary.KwokVersion == "" {
+			s.summary.KwokVersion = doc.Object.GetAPIVersion()
+		}
+		s.kwok = true
+		s.duration = max(s.duration, rp.DurationNanosecond)
+		ref := snapshotObjectRef{
+			gr:        rp.Resource.GroupResource(),
+			namespace: rp.Target.Namespace,
+			name:      rp.Target.Name,
+		}
+		s.event(ref.gr.String(), ref.String(), kwokPatchMethod(rp))
+	}
+	return nil
+}
+
+func (s *recordingSummarizer) resource(resource, id string) *recordingSummaryResource {
+	r, ok := s.resources[resource]
+	if !ok {
+		r = &recordingSummaryResource{Resource: resource}
+		s.resources[resource] = r
+	}
+	if !s.seen[id] {
+		s.seen[id] = true
+		r.Objects++
+	}
+	return r
+}
+
+func (s *recordingSummarizer) object(resource, id string) {
+	s.resource(resource, id)
+	s.summary.Objects++
+}
+
+func (s *recordingSummarizer) event(resource, id, method string) {
+	s.resource(resource, id).Events++
+	s.summary.Events++
+	s.summary.Methods[method]++
+}
+
+func (s *recordingSummarizer) finish() *recordingSummary {
+	switch {
+	case s.kwok:
+		s.summary.Duration = s.duration.String()
+	case !s.start.IsZero():
+		s.summary.Duration = s.elapsed().String()
+	}
+	for _, r := range s.resources {
+		s.summary.Resources = append(s.summary.Resources, r)
+	}
+	sort.Slice(s.summary.Resources, func(i, j int) bool {
+		a, b := s.summary.Resources[i], s.summary.Resources[j]
+		if a.Events != b.Events {
+			return a.Events > b.Events
+		}
+		return a.Resource < b.Resource
+	})
+	return s.summary
+}
+
+func infoCommand(flags *infoFlagpole, path string) error {
+	if flags.Output != "table" && flags.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+	summary, err := readRecordingSummary(path, flags.Prefix)
+	if err != nil {
+		return err
+	}
+
+	switch flags.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summary)
+	default:
+		return printRecordingSummary(os.Stdout, summary)
+	}
+}
+
+func printRecordingSummary(out io.Writer, summary *recordingSummary) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(w, "FORMAT\t%s\n", summary.Format)
+	if summary.Chunks != 0 {
+		fmt.Fprintf(w, "CHUNKS\t%d\n", summary.Chunks)
+	}
+	if summary.Encrypted {
+		fmt.Fprintf(w, "ENCRYPTED\ttrue\n")
+	} else {
+		fmt.Fprintf(w, "COMPRESSION\t%s\n", summary.Compression)
+	}
+	fmt.Fprintf(w, "BYTES\t%s stored, %s of documents\n", formatBytes(float64(summary.StoredBytes)), formatBytes(float64(summary.Bytes)))
+	fmt.Fprintf(w, "DOCUMENTS\t%d\n", summary.Documents)
+	if summary.KwokVersion != "" {
+		fmt.Fprintf(w, "KWOK VERSION\t%s\n", summary.KwokVersion)
+	}
+	if summary.FromRevision != 0 {
+		fmt.Fprintf(w, "REVISIONS\t%d-%d\n", summary.FromRevision, summary.ToRevision)
+	}
+	if summary.FromTime != "" {
+		fmt.Fprintf(w, "TIMES\t%s - %s\n", summary.FromTime, summary.ToTime)
+	}
+	if summary.Duration != "" {
+		fmt.Fprintf(w, "DURATION\t%s\n", summary.Duration)
+	}
+	if summary.ClockSkew != nil {
+		fmt.Fprintf(w, "CLOCK SKEW\t%s\n", summary.ClockSkew)
+	}
+	fmt.Fprintf(w, "OBJECTS\t%d\n", summary.Objects)
+	fmt.Fprintf(w, "EVENTS\t%d\n", summary.Events)
+	if len(summary.Methods) != 0 {
+		methods := make([]string, 0, len(summary.Methods))
+		for method, n := range summary.Methods {
+			methods = append(methods, fmt.Sprintf("%s=%d", method, n))
+		}
+		sort.Strings(methods)
+		fmt.Fprintf(w, "METHODS\t%s\n", strings.Join(methods, ", "))
+	}
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+	if len(summary.Resources) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(w, "RESOURCE\tOBJECTS\tEVENTS\n")
+	for _, r := range summary.Resources {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", r.Resource, r.Objects, r.Events)
+	}
+	return w.Flush()
+}
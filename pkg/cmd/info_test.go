@@ -0,0 +1,163 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadRecordingSummary(t *testing.T) {
+	tests := []struct {
+		name          string
+		recording     string
+		wantObjects   int
+		wantMethods   map[string]int
+		wantDuration  string
+		wantKwok      string
+		wantResources []*recordingSummaryResource
+	}{
+		{
+			name: "kectl",
+			recording: `---
+# clock-skew | 10ms | 500ms | 2024-01-01T00:00:00Z
+---
+# /registry/pods/default/a | application/yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+---
+# bookmark | 3 | 2024-01-01T00:05:00Z
+---
+# /registry/configmaps/default/b | application/yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+  namespace: default
+---
+# /registry/pods/default/a | application/yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+---
+# /registry/pods/default/a | deleted
+---
+# bookmark | 4 | 2024-01-01T00:10:00Z
+`,
+			wantObjects:  1,
+			wantMethods:  map[string]int{"create": 1, "update": 1, "delete": 1},
+			wantDuration: "10m0s",
+			wantResources: []*recordingSummaryResource{
+				{Resource: "pods", Objects: 1, Events: 2},
+				{Resource: "configmaps", Objects: 1, Events: 1},
+			},
+		},
+		{
+			name: "kwok",
+			recording: `---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: a
+  namespace: default
+durationNanosecond: 1000
+method: patch
+template:
+  status:
+    phase: Running
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: a
+  namespace: default
+durationNanosecond: 1000
+method: patch
+checkpoint: true
+template:
+  apiVersion: v1
+  kind: Pod
+  metadata:
+    name: a
+    namespace: default
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: a
+  namespace: default
+durationNanosecond: 2000
+method: delete
+`,
+			wantObjects:  1,
+			wantMethods:  map[string]int{"patch": 1, "checkpoint": 1, "delete": 1},
+			wantDuration: "2µs",
+			wantKwok:     "action.kwok.x-k8s.io/v1alpha1",
+			wantResources: []*recordingSummaryResource{
+				{Resource: "pods", Objects: 1, Events: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "recording.yaml")
+			err := os.WriteFile(path, []byte(tt.recording), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			summary, err := readRecordingSummary(path, "/registry")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Compression != compressionNone || summary.StoredBytes != int64(len(tt.recording)) {
+				t.Errorf("got compression %s of %d bytes, want none of %d", summary.Compression, summary.StoredBytes, len(tt.recording))
+			}
+			if summary.Objects != tt.wantObjects || summary.Duration != tt.wantDuration || summary.KwokVersion != tt.wantKwok {
+				t.Errorf("got %d objects, duration %s and kwok version %q, want %d, %s and %q",
+					summary.Objects, summary.Duration, summary.KwokVersion, tt.wantObjects, tt.wantDuration, tt.wantKwok)
+			}
+			if !reflect.DeepEqual(summary.Methods, tt.wantMethods) {
+				t.Errorf("got methods %v, want %v", summary.Methods, tt.wantMethods)
+			}
+			if !reflect.DeepEqual(summary.Resources, tt.wantResources) {
+				t.Errorf("got resources %+v, want %+v", summary.Resources, tt.wantResources)
+			}
+		})
+	}
+}
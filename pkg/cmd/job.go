@@ -0,0 +1,316 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// jobEnvPrefix is the prefix of the environment variables setting the flags of the task of a job,
+// KECTL_ALL_NAMESPACE for --all-namespace, and KECTL_JOB_ the ones of job itself, KECTL_JOB_TASK for --task.
+const jobEnvPrefix = "KECTL_"
+
+type jobFlagpole struct {
+	Task       string
+	Artifacts  string
+	Analysis   string
+	ResultFile string
+}
+
+// jobTask is a task a job runs, as the command of kectl it runs.
+type jobTask struct {
+	// args are the arguments of the command, before the ones of the environment
+	args []string
+	// ext is the extension of the artifact
+	ext string
+	// stdout is whether the artifact is the output of the command, rather than written to its --path
+	stdout bool
+}
+
+// jobTasks are the tasks of a job, by name.
+var jobTasks = map[string]jobTask{
+	"record": {
+		args: []string{"get", "--all-namespace", "--watch", "--progress-interval=10s"},
+		ext:  ".yaml.zst",
+	},
+	"snapshot": {
+		args: []string{"get", "--all-namespace"},
+		ext:  ".yaml.zst",
+	},
+	"analyze": {
+		args:   []string{"analyze"},
+		ext:    ".json",
+		stdout: true,
+	},
+}
+
+// jobResult is the result of a job, for the controller of the job to consume,
+// as the last line of the output and the termination message of the pod.
+type jobResult struct {
+	Task           string    `json:"task"`
+	Artifact       string    `json:"artifact,omitempty"`
+	StartTime      time.Time `json:"startTime"`
+	CompletionTime time.Time `json:"completionTime"`
+	Duration       string    `json:"duration"`
+	Succeeded      bool      `json:"succeeded"`
+	Error          string    `json:"error,omitempty"`
+}
+
+func newCtlJobCommand() *cobra.Command {
+	flags := &jobFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Use:   "job",
+		Short: "Runs a task as the entrypoint of a Kubernetes Job or CronJob, configured by environment variables",
+		Long: "Runs a task as the entrypoint of a Kubernetes Job or CronJob, configured by environment variables, such as the ones of a ConfigMap.\n" +
+			"The flags of job are set by " + jobEnvPrefix + "JOB_ and the name of the flag, " + jobEnvPrefix + "JOB_TASK for --task,\n" +
+			"and the ones of the command of the task, the global ones included, by " + jobEnvPrefix + " and the name of the flag, " + jobEnvPrefix + "ENDPOINTS for --endpoints.\n" +
+			"record watches all of etcd to a recording, with " + jobEnvPrefix + "UNTIL_DURATION to stop, snapshot gets all of etcd to a recording,\n" +
+			"and analyze runs the analysis of --analysis to a report in JSON.\n" +
+			"The artifact is written to --artifacts, named after the task and the time it starts, and the result of the job\n" +
+			"is printed as the last line of the output and written to --result-file as JSON.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := setFlagsFromEnv(cmd.LocalFlags(), jobEnvPrefix+"JOB_")
+			if err == nil {
+				err = jobCommand(cmd, flags, os.Stdout)
+			}
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	tasks := make([]string, 0, len(jobTasks))
+	for task := range jobTasks {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+	cmd.Flags().StringVar(&flags.Task, "task", "", "task to run. One of: ("+strings.Join(tasks, ", ")+").")
+	cmd.Flags().StringVar(&flags.Artifacts, "artifacts", ".", "directory to write the artifact to, such as a mounted volume, or the prefix of an object such as s3://bucket/prefix, gcs://bucket/prefix or azblob://container/prefix")
+	cmd.Flags().StringVar(&flags.Analysis, "analysis", "storage-versions", "analysis of the analyze task, the command of analyze with its arguments, such as deprecations pods")
+	cmd.Flags().StringVar(&flags.ResultFile, "result-file", "", "path of the file to write the result of the job to as JSON, such as /dev/termination-log for the termination message of the pod")
+
+	return cmd
+}
+
+func jobCommand(cmd *cobra.Command, flags *jobFlagpole, w io.Writer) error {
+	task, ok := jobTasks[flags.Task]
+	if !ok {
+		return fmt.Errorf("unsupported task: %q", flags.Task)
+	}
+
+	start := time.Now()
+	result := &jobResult{
+		Task:      flags.Task,
+		Artifact:  jobArtifactPath(flags.Artifacts, flags.Task, task.ext, start),
+		StartTime: start.UTC().Truncate(time.Second),
+	}
+
+	err := runJobTask(cmd, flags, task, result.Artifact)
+
+	end := time.Now()
+	result.CompletionTime = end.UTC().Truncate(time.Second)
+	result.Duration = end.Sub(start).Round(time.Second).String()
+	result.Succeeded = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	data, merr := json.Marshal(result)
+	if merr != nil {
+		return merr
+	}
+	fmt.Fprintf(w, "%s\n", data)
+	if flags.ResultFile != "" {
+		werr := os.WriteFile(flags.ResultFile, data, 0o644)
+		if werr != nil && err == nil {
+			err = fmt.Errorf("write result: %w", werr)
+		}
+	}
+	return err
+}
+
+// runJobTask runs the command of the task, with the flags of the environment, and the output to the artifact for the analyses.
+func runJobTask(cmd *cobra.Command, flags *jobFlagpole, task jobTask, artifact string) error {
+	args := append([]string{}, task.args...)
+	if flags.Task == "analyze" {
+		args = append(args, strings.Fields(flags.Analysis)...)
+	}
+
+	// The task runs in a command of its own, not to mix its flags with the ones of job
+	root := NewCtlCommand()
+	root.SilenceErrors = true
+	root.SilenceUsage = true
+	c, _, err := root.Find(args)
+	if err != nil {
+		return err
+	}
+	if !c.Runnable() {
+		return fmt.Errorf("%q is not a command", strings.Join(args, " "))
+	}
+
+	// The flags of the arguments and of the environment follow the ones of the task to override them, apart from the one of the artifact
+	set, value := "path", artifact
+	if task.stdout {
+		set, value = "output", "json"
+	}
+	envArgs, err := jobEnvArgs(c, set)
+	if err != nil {
+		return err
+	}
+	args = append(args, jobGlobalArgs(cmd)...)
+	root.SetArgs(append(append(args, envArgs...), "--"+set+"="+value))
+
+	if !task.stdout {
+		return root.ExecuteContext(cmd.Context())
+	}
+
+	// The analyses write their reports to the output, which is the artifact for the time of the task
+	file, err := os.CreateTemp("", "kectl-job-*"+task.ext)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	stdout := os.Stdout
+	os.Stdout = file
+	err = root.ExecuteContext(cmd.Context())
+	os.Stdout = stdout
+	if err != nil {
+		return err
+	}
+	return copyJobArtifact(cmd, file, artifact)
+}
+
+// copyJobArtifact copies the output of the task to the artifact.
+func copyJobArtifact(cmd *cobra.Command, file *os.File, artifact string) error {
+	_, err := file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	if !isObjectStoragePath(artifact) {
+		out, err := os.Create(artifact)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, file)
+		if err != nil {
+			_ = out.Close()
+			return err
+		}
+		return out.Close()
+	}
+
+	object, err := createObject(cmd.Context(), artifact)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(object, file)
+	if err != nil {
+		_ = object.Abort()
+		return err
+	}
+	return object.Close()
+}
+
+// jobArtifactPath returns the path of the artifact of the task starting at the time, in the directory or under the prefix of an object.
+func jobArtifactPath(artifacts, task, ext string, t time.Time) string {
+	name := "kectl-" + task + "-" + t.UTC().Format("20060102T150405Z") + ext
+	if isObjectStoragePath(artifacts) {
+		return strings.TrimSuffix(artifacts, "/") + "/" + name
+	}
+	return filepath.Join(artifacts, name)
+}
+
+// jobEnvArgs returns the flags of the command set by the environment variables, as arguments,
+// failing for the flag set by the job.
+func jobEnvArgs(c *cobra.Command, set string) ([]string, error) {
+	var envArgs []string
+	var err error
+	visit := func(f *pflag.Flag) {
+		name := jobEnvName(jobEnvPrefix, f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if f.Name == set {
+			err = fmt.Errorf("%s can not be set, --%s is set by the job", name, set)
+			return
+		}
+		envArgs = append(envArgs, "--"+f.Name+"="+value)
+	}
+	c.LocalFlags().VisitAll(visit)
+	c.InheritedFlags().VisitAll(visit)
+	if err != nil {
+		return nil, err
+	}
+	return envArgs, nil
+}
+
+// jobGlobalArgs returns the global flags set in the arguments of job, as arguments of its task.
+func jobGlobalArgs(cmd *cobra.Command) []string {
+	var args []string
+	cmd.InheritedFlags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		value := f.Value.String()
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			value = strings.Join(slice.GetSlice(), ",")
+		}
+		args = append(args, "--"+f.Name+"="+value)
+	})
+	return args
+}
+
+// setFlagsFromEnv sets the flags not set by the arguments from the environment variables of the prefix.
+func setFlagsFromEnv(fs *pflag.FlagSet, prefix string) error {
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+		name := jobEnvName(prefix, f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if serr := fs.Set(f.Name, value); serr != nil {
+			err = fmt.Errorf("%s: %w", name, serr)
+		}
+	})
+	return err
+}
+
+// jobEnvName returns the environment variable of the flag, KECTL_ALL_NAMESPACE for all-namespace.
+func jobEnvName(prefix, flag string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJobArtifactPath(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		artifacts string
+		want      string
+	}{
+		{
+			artifacts: "/artifacts",
+			want:      "/artifacts/kectl-record-20240102T030405Z.yaml.zst",
+		},
+		{
+			artifacts: "s3://bucket/nightly/",
+			want:      "s3://bucket/nightly/kectl-record-20240102T030405Z.yaml.zst",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.artifacts, func(t *testing.T) {
+			got := jobArtifactPath(tt.artifacts, "record", ".yaml.zst", at)
+			if got != tt.want {
+				t.Errorf("jobArtifactPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobEnvArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "flags",
+			env: map[string]string{
+				"KECTL_UNTIL_DURATION": "1h",
+				"KECTL_ENDPOINTS":      "etcd-0:2379,etcd-1:2379",
+				"KECTL_JOB_TASK":       "record",
+			},
+			want: []string{"--until-duration=1h", "--endpoints=etcd-0:2379,etcd-1:2379"},
+		},
+		{
+			name: "set by the job",
+			env: map[string]string{
+				"KECTL_PATH": "trace.yaml",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			c, _, err := NewCtlCommand().Find([]string{"get"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := jobEnvArgs(c, "path")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("jobEnvArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jobEnvArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetFlagsFromEnv(t *testing.T) {
+	t.Setenv("KECTL_JOB_TASK", "snapshot")
+	t.Setenv("KECTL_JOB_ARTIFACTS", "/artifacts")
+
+	cmd := newCtlJobCommand()
+	err := cmd.Flags().Parse([]string{"--artifacts=/data"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = setFlagsFromEnv(cmd.Flags(), "KECTL_JOB_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for flag, want := range map[string]string{"task": "snapshot", "artifacts": "/data"} {
+		got, _ := cmd.Flags().GetString(flag)
+		if got != want {
+			t.Errorf("--%s = %q, want %q", flag, got, want)
+		}
+	}
+}
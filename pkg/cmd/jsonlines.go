@@ -0,0 +1,118 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// jsonLine is a document of the jsonl output format, the object with its key and revision on a single line,
+// for the recordings to be processed by jq or stream processors.
+type jsonLine struct {
+	Key       string          `json:"key,omitempty"`
+	Revision  int64           `json:"revision,omitempty"`
+	Deleted   bool            `json:"deleted,omitempty"`
+	MediaType string          `json:"mediaType,omitempty"`
+	Object    json.RawMessage `json:"object,omitempty"`
+	// Raw is the value that can not be decoded, with the Error of decoding it
+	Raw   []byte `json:"raw,omitempty"`
+	Error string `json:"error,omitempty"`
+	// Bookmark is the time the output is complete up to the revision, of the lines of bookmarks only
+	Bookmark *time.Time `json:"bookmark,omitempty"`
+	// ClockSkew is the clock skew of the recorder, of the line a recording starts with only
+	ClockSkew *clockSkew `json:"clockSkew,omitempty"`
+	// Gap is the revisions compacted away from the watch, of the lines of gaps only
+	Gap *client.Gap `json:"gap,omitempty"`
+}
+
+// writeJSONLine writes the line, followed by a newline.
+func writeJSONLine(w io.Writer, line *jsonLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	_, err = w.Write(data)
+	return err
+}
+
+// isJSONStream returns whether the input is a stream of JSON values, such as JSON Lines, rather than of YAML documents.
+func isJSONStream(r *bufio.Reader) bool {
+	for i := 1; ; i++ {
+		peek, err := r.Peek(i)
+		if err != nil {
+			return false
+		}
+		switch peek[i-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{', '[':
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// decodeJSONStream decodes the JSON values of the stream as objects, unwrapping the ones of the jsonl output format.
+// The lines of the bookmarks and values that could not be decoded have no object to put and are skipped,
+// the keys of the deletions are passed to the deleteFunc if it is not nil.
+func decodeJSONStream(r io.Reader, visitFunc func(obj *unstructured.Unstructured) error, deleteFunc func(key string) error) error {
+	d := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		err := d.Decode(&raw)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		// The objects written by other tools are not in the lines of the jsonl output format
+		var line jsonLine
+		if json.Unmarshal(raw, &line) == nil && (line.Key != "" || line.Bookmark != nil || line.ClockSkew != nil || line.Gap != nil) {
+			if line.Deleted && deleteFunc != nil {
+				err = deleteFunc(line.Key)
+				if err != nil {
+					return err
+				}
+			}
+			if line.Deleted || len(line.Object) == 0 {
+				continue
+			}
+			raw = line.Object
+		}
+
+		obj := &unstructured.Unstructured{}
+		err = json.Unmarshal(raw, &obj)
+		if err != nil {
+			return err
+		}
+		err = visitUnstructured(obj, visitFunc)
+		if err != nil {
+			return err
+		}
+	}
+}
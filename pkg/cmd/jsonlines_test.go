@@ -0,0 +1,185 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDecodeJSONStream(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name: "jsonl output",
+			input: `{"clockSkew":{"skew":1000000,"uncertainty":500000000,"time":"2024-01-01T00:00:00Z"}}
+{"key":"/registry/configmaps/default/a","revision":2,"mediaType":"application/json","object":{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"}}}
+{"key":"/registry/configmaps/default/b","revision":3,"deleted":true,"object":{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"b","namespace":"default"}}}
+{"key":"/registry/configmaps/default/c","revision":4,"raw":"AAE=","error":"malformed value"}
+{"revision":4,"bookmark":"2024-01-01T00:00:00Z"}
+`,
+			want: []string{"a"},
+		},
+		{
+			name: "yaml output",
+			input: "---\n# /registry/configmaps/default/a | application/json\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n" +
+				"---\n# bookmark | 3 | 2024-01-01T00:00:00Z\n" +
+				"---\n# /registry/configmaps/default/b | application/json | deleted\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n" +
+				"---\n# /registry/configmaps/default/c | deleted\n",
+			want: []string{"a"},
+		},
+		{
+			name:  "objects",
+			input: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a"}}` + "\n" + `{"apiVersion":"apps/v1","kind":"ControllerRevision","metadata":{"name":"b"},"revision":1}`,
+			want:  []string{"a", "b"},
+		},
+		{
+			name: "list",
+			input: `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}},
+    {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "b"}}
+  ]
+}`,
+			want: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := decodeToUnstructured(strings.NewReader(tt.input), func(obj *unstructured.Unstructured) error {
+				got = append(got, obj.GetName())
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("decoded %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCommandJSONLines(t *testing.T) {
+	dir := t.TempDir()
+	etcdclient := fake.NewClient()
+	gr := schema.GroupResource{Resource: "configmaps"}
+	for _, name := range []string{"a", "b", "c"} {
+		err := etcdclient.Put(context.Background(), "/registry", []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"`+name+`","namespace":"default"}}`),
+			client.WithGR(gr),
+			client.WithName(name, "default"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := etcdclient.Delete(context.Background(), "/registry",
+		client.WithGR(gr),
+		client.WithName("b", "default"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trace := filepath.Join(dir, "trace.jsonl")
+	err = getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:           "jsonl",
+		Prefix:           "/registry",
+		DecodeMode:       "lenient",
+		MaxBandwidth:     "0",
+		RawRevisionRange: "1-",
+		Path:             trace,
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, raw := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var line jsonLine
+		err = json.Unmarshal([]byte(raw), &line)
+		if err != nil {
+			t.Fatalf("line %q: %v", raw, err)
+		}
+		name := strings.TrimPrefix(line.Key, "/registry/configmaps/default/")
+		if line.Deleted {
+			name += " deleted"
+		} else if line.MediaType == "" || !strings.Contains(string(line.Object), `"name":"`+name+`"`) {
+			t.Errorf("line %q is without the object", raw)
+		}
+		got = append(got, name)
+	}
+	if want := "a,b,c,b deleted"; strings.Join(got, ",") != want {
+		t.Errorf("written %v, want %s", got, want)
+	}
+
+	// The objects are put back from the jsonl output
+	path := filepath.Join(dir, "objects.jsonl")
+	err = getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:       "jsonl",
+		Prefix:       "/registry",
+		DecodeMode:   "lenient",
+		MaxBandwidth: "0",
+		Path:         path,
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := fake.NewClient()
+	err = putCommand(context.Background(), replayed, &putFlagpole{
+		Output:     "none",
+		Path:       path,
+		Prefix:     "/registry",
+		DecodeMode: "strict",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	_, err = replayed.Get(context.Background(), "/registry",
+		client.WithGR(gr),
+		client.WithKeysOnly(),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			keys = append(keys, string(kv.Key))
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/registry/configmaps/default/a,/registry/configmaps/default/c"; strings.Join(keys, ",") != want {
+		t.Errorf("replayed %v, want %s", keys, want)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type keyOfFlagpole struct {
+	Namespace     string
+	Output        string
+	Prefix        string
+	EtcdNamespace string
+}
+
+func newCtlKeyOfCommand() *cobra.Command {
+	flags := &keyOfFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.RangeArgs(1, 2),
+		Use:   "key-of (resource/name | resource name)",
+		Short: "Prints the etcd key of the resource of k8s",
+		Long: "Prints the etcd key of the resource of k8s, referenced like kubectl does, e.g. deploy/foo.\n" +
+			"Whether the key exists, the size of its value and its revision are printed as well.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			flags.EtcdNamespace, err = cmd.Flags().GetString("etcd-namespace")
+			if err != nil {
+				return err
+			}
+			err = keyOfCommand(cmd.Context(), etcdclient, flags, args)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, key).")
+	cmd.Flags().StringVarP(&flags.Namespace, "namespace", "n", "", "namespace of resource")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+
+	return cmd
+}
+
+// parseReference returns the resource and name of the reference in the form of resource/name or resource name.
+func parseReference(args []string) (resource, name string, err error) {
+	if len(args) == 2 {
+		resource, name = args[0], args[1]
+	} else {
+		var ok bool
+		resource, name, ok = strings.Cut(args[0], "/")
+		if !ok {
+			return "", "", fmt.Errorf("invalid reference %q, want resource/name", args[0])
+		}
+	}
+	if resource == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("invalid reference %q", strings.Join(args, " "))
+	}
+	return resource, name, nil
+}
+
+func keyOfCommand(ctx context.Context, etcdclient client.Client, flags *keyOfFlagpole, args []string) error {
+	resource, name, err := parseReference(args)
+	if err != nil {
+		return err
+	}
+
+	gr := schema.ParseGroupResource(resource)
+	if gr.Empty() {
+		return fmt.Errorf("invalid resource %q", resource)
+	}
+	namespace := flags.Namespace
+	if correctGr, namespaced, found := wellknown.CorrectGroupResource(gr); found {
+		gr = correctGr
+		if !namespaced {
+			namespace = ""
+		} else if namespace == "" {
+			namespace = "default"
+		}
+	}
+
+	keyPrefix, err := client.PrefixFromGR(gr)
+	if err != nil {
+		return err
+	}
+	key := flags.Prefix + "/" + keyPrefix + "/" + name
+	if namespace != "" {
+		key = flags.Prefix + "/" + keyPrefix + "/" + namespace + "/" + name
+	}
+	// The key as stored, for etcdctl
+	key = flags.EtcdNamespace + key
+
+	if flags.Output == "key" {
+		fmt.Fprintf(os.Stdout, "%s\n", key)
+		return nil
+	}
+	if flags.Output != "table" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+
+	var found *client.KeyValue
+	_, err = etcdclient.Get(ctx, flags.Prefix,
+		client.WithGR(gr),
+		client.WithName(name, namespace),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			found = kv
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "KEY\tEXISTS\tSIZE\tREVISION")
+	if found == nil {
+		fmt.Fprintf(w, "%s\tfalse\t\t\n", key)
+	} else {
+		fmt.Fprintf(w, "%s\ttrue\t%d\t%d\n", key, len(found.Value), found.Revision)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		wantResource string
+		wantName     string
+		wantErr      bool
+	}{
+		{
+			name:         "slash",
+			args:         []string{"deploy/foo"},
+			wantResource: "deploy",
+			wantName:     "foo",
+		},
+		{
+			name:         "separate",
+			args:         []string{"deployments.apps", "foo"},
+			wantResource: "deployments.apps",
+			wantName:     "foo",
+		},
+		{
+			name:    "no name",
+			args:    []string{"deploy"},
+			wantErr: true,
+		},
+		{
+			name:    "too many segments",
+			args:    []string{"deploy/foo/bar"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotResource, gotName, err := parseReference(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotResource != tt.wantResource || gotName != tt.wantName {
+				t.Errorf("parseReference() = %v, %v, want %v, %v", gotResource, gotName, tt.wantResource, tt.wantName)
+			}
+		})
+	}
+}
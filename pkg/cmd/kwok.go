@@ -0,0 +1,231 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	actioninternalversion "github.com/wzshiming/kectl/apis/action/internalversion"
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	actionv1alpha2 "github.com/wzshiming/kectl/apis/action/v1alpha2"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/scheme"
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// actionScheme converts the ResourcePatches between the versions of the recordings, through the internal version.
+var actionScheme = newActionScheme()
+
+func newActionScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	utilruntime.Must(actionv1alpha1.AddToScheme(s))
+	utilruntime.Must(actionv1alpha2.AddToScheme(s))
+	utilruntime.Must(actioninternalversion.AddToScheme(s))
+	return s
+}
+
+// isKwokResourcePatch returns whether the document is a change of a kwok recording rather than an object.
+// The changes are the ResourcePatch of the recordings of `kwokctl snapshot record`, which follow the objects of the cluster
+// with the changes to them in the order they were seen. The ResourcePatches of all versions are changes,
+// for the ones of a version this kectl does not read to fail rather than to be put as objects.
+func isKwokResourcePatch(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == actionv1alpha1.GroupName && gvk.Kind == actionv1alpha1.ResourcePatchKind
+}
+
+// isKwokRecording returns whether the document is the Recording a kwok recording starts with, which only tells its version.
+func isKwokRecording(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == actionv1alpha2.GroupName && gvk.Kind == actionv1alpha2.RecordingKind
+}
+
+// checkKwokVersion returns an error if the document is of a version of the recordings this kectl does not read.
+func checkKwokVersion(obj *unstructured.Unstructured) error {
+	switch obj.GetAPIVersion() {
+	case actionv1alpha1.APIVersion, actionv1alpha2.APIVersion:
+		return nil
+	}
+	return fmt.Errorf("unsupported version %q of the %s of the recording, newer than this kectl reads. One of: (%s, %s)",
+		obj.GetAPIVersion(), obj.GetKind(), actionv1alpha1.Version, actionv1alpha2.Version)
+}
+
+// decodeKwokResourcePatch decodes the ResourcePatch of any version as v1alpha1, which the changes are made by.
+func decodeKwokResourcePatch(obj *unstructured.Unstructured) (*actionv1alpha1.ResourcePatch, error) {
+	internal, err := decodeInternalResourcePatch(obj)
+	if err != nil {
+		return nil, err
+	}
+	rp := &actionv1alpha1.ResourcePatch{}
+	err = actionScheme.Convert(internal, rp, nil)
+	if err != nil {
+		return nil, err
+	}
+	return rp, nil
+}
+
+// decodeInternalResourcePatch decodes and validates the ResourcePatch of any version as the internal version.
+func decodeInternalResourcePatch(obj *unstructured.Unstructured) (*actioninternalversion.ResourcePatch, error) {
+	err := checkKwokVersion(obj)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	var rp interface {
+		runtime.Object
+		Validate() error
+	}
+	if obj.GetAPIVersion() == actionv1alpha2.APIVersion {
+		rp = &actionv1alpha2.ResourcePatch{}
+	} else {
+		rp = &actionv1alpha1.ResourcePatch{}
+	}
+	err = json.Unmarshal(data, rp)
+	if err != nil {
+		return nil, err
+	}
+	err = rp.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	internal := &actioninternalversion.ResourcePatch{}
+	err = actionScheme.Convert(rp, internal, nil)
+	if err != nil {
+		return nil, err
+	}
+	return internal, nil
+}
+
+// writeYAMLDocument writes the value as a YAML document of a recording, a ResourcePatch with its apiVersion and kind.
+func writeYAMLDocument(w io.Writer, v interface{}) error {
+	return actionv1alpha1.NewEncoder(w).Encode(v)
+}
+
+// applyStrategicPatch applies the patch recorded by kwok to the object in JSON,
+// the types unknown to the scheme, such as custom resources, are patched as a JSON merge patch.
+func applyStrategicPatch(original, patch []byte) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	err := obj.UnmarshalJSON(original)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	dataStruct, err := scheme.Scheme.New(obj.GroupVersionKind())
+	if err == nil {
+		patched, err = strategicpatch.StrategicMergePatch(original, patch, dataStruct)
+	} else {
+		patched, err = jsonpatch.MergePatch(original, patch)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	obj = &unstructured.Unstructured{}
+	err = obj.UnmarshalJSON(patched)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// kwokObjects is the objects of a kwok recording by reference, followed through its changes.
+type kwokObjects map[snapshotObjectRef]*unstructured.Unstructured
+
+// add adds an object the recording starts with.
+func (o kwokObjects) add(obj *unstructured.Unstructured) error {
+	ref, _, err := snapshotObjectRefOf(obj)
+	if err != nil {
+		return err
+	}
+	o[ref] = obj
+	return nil
+}
+
+// apply applies the change to the objects, and returns the object as of it,
+// the deleted one of a delete, or nil for a patch of an object not known before it.
+func (o kwokObjects) apply(rp *actionv1alpha1.ResourcePatch) (*unstructured.Unstructured, error) {
+	ref := snapshotObjectRef{
+		gr:        rp.Resource.GroupResource(),
+		namespace: rp.Target.Namespace,
+		name:      rp.Target.Name,
+	}
+	before := o[ref]
+	switch rp.Method {
+	case actionv1alpha1.PatchMethodDelete:
+		delete(o, ref)
+		if len(rp.Template) == 0 {
+			return before, nil
+		}
+	case actionv1alpha1.PatchMethodPatch:
+		if !rp.Checkpoint {
+			if before == nil {
+				return nil, nil
+			}
+			data, err := before.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			after, err := applyStrategicPatch(data, rp.Template)
+			if err != nil {
+				return nil, fmt.Errorf("patch %s: %w", ref, err)
+			}
+			o[ref] = after
+			return after, nil
+		}
+	}
+	obj := &unstructured.Unstructured{}
+	err := obj.UnmarshalJSON(rp.Template)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", rp.Method, ref, err)
+	}
+	if rp.Method != actionv1alpha1.PatchMethodDelete {
+		o[ref] = obj
+	}
+	return obj, nil
+}
+
+// getObjectJSON returns the object in JSON, or nil if it does not exist.
+func getObjectJSON(ctx context.Context, etcdclient client.Client, prefix string, gr schema.GroupResource, name, namespace string) ([]byte, error) {
+	var data []byte
+	_, err := etcdclient.Get(ctx, prefix,
+		client.WithName(name, namespace),
+		client.WithGR(gr),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			var err error
+			_, data, err = convertValue(kv.Value, encoding.JsonMediaType)
+			return err
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestApplyStrategicPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		patch    string
+		field    []string
+		want     interface{}
+	}{
+		{
+			name:     "merge the containers of a pod by name",
+			original: `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"a"},"spec":{"containers":[{"name":"app","image":"app:1"},{"name":"sidecar","image":"sidecar:1"}]}}`,
+			patch:    `{"spec":{"containers":[{"name":"app","image":"app:2"}]}}`,
+			field:    []string{"spec", "containers"},
+			want: []interface{}{
+				map[string]interface{}{"name": "app", "image": "app:2"},
+				map[string]interface{}{"name": "sidecar", "image": "sidecar:1"},
+			},
+		},
+		{
+			name:     "replace the lists of a custom resource",
+			original: `{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"a"},"spec":{"parts":["a","b"],"size":1}}`,
+			patch:    `{"spec":{"parts":["c"]}}`,
+			field:    []string{"spec"},
+			want: map[string]interface{}{
+				"parts": []interface{}{"c"},
+				"size":  int64(1),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := applyStrategicPatch([]byte(tt.original), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, _ := unstructured.NestedFieldNoCopy(obj.Object, tt.field...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyStrategicPatch() %v = %#v, want %#v", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPutCommandKwokRecording(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "recording.yaml")
+	docs := []string{
+		"apiVersion: v1\nkind: Pod\nmetadata:\n  name: a\n  namespace: default\nspec:\n  nodeName: node0\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n  namespace: default\n",
+		`apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: a
+  namespace: default
+durationNanosecond: 1000
+method: patch
+template:
+  status:
+    phase: Running
+`,
+		`apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: configmaps
+target:
+  name: b
+  namespace: default
+durationNanosecond: 2000
+method: delete
+`,
+		`apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: configmaps
+target:
+  name: c
+  namespace: default
+durationNanosecond: 3000
+method: create
+template:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: c
+    namespace: default
+  data:
+    key: value
+`,
+	}
+	err := os.WriteFile(input, []byte(strings.Join(docs, "---\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	etcdclient := fake.NewClient()
+	err = putCommand(context.Background(), etcdclient, &putFlagpole{
+		Output:     "none",
+		Path:       input,
+		Prefix:     "/registry",
+		DecodeMode: "lenient",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	_, err = etcdclient.Get(context.Background(), "/registry",
+		client.WithKeysOnly(),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			keys = append(keys, string(kv.Key))
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/registry/configmaps/default/c", "/registry/pods/default/a"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+
+	data, err := getObjectJSON(context.Background(), etcdclient, "/registry", podGroupResource, "a", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := &unstructured.Unstructured{}
+	err = pod.UnmarshalJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phase, _, _ := unstructured.NestedString(pod.Object, "status", "phase"); phase != "Running" {
+		t.Errorf("status.phase = %q, want Running", phase)
+	}
+	if nodeName, _, _ := unstructured.NestedString(pod.Object, "spec", "nodeName"); nodeName != "node0" {
+		t.Errorf("spec.nodeName = %q, want node0", nodeName)
+	}
+
+	// Tearing down the recording leaves the objects it did not write
+	err = etcdclient.Put(context.Background(), "/registry", []byte("{}"),
+		client.WithGR(schema.GroupResource{Resource: "configmaps"}),
+		client.WithName("keep", "default"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = putCommand(context.Background(), etcdclient, &putFlagpole{
+		Output:      "none",
+		Path:        input,
+		Prefix:      "/registry",
+		DecodeMode:  "lenient",
+		OnlyDeletes: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys = nil
+	_, err = etcdclient.Get(context.Background(), "/registry",
+		client.WithKeysOnly(),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			keys = append(keys, string(kv.Key))
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/registry/configmaps/default/keep"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys after --only-deletes = %v, want %v", keys, want)
+	}
+}
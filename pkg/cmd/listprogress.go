@@ -0,0 +1,217 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+// listProgress tracks the keys and bytes listed of each resource while getting all of etcd,
+// to report the progress periodically and the breakdown once the list completes.
+type listProgress struct {
+	w        io.Writer
+	interval time.Duration
+	start    time.Time
+
+	// mut guards the resources against the responses of parallel lists
+	mut       sync.Mutex
+	resources []*listResourceProgress
+	byRange   map[string]*listResourceProgress
+}
+
+type listResourceProgress struct {
+	listResourceReport
+	start   time.Time
+	started bool
+	done    bool
+}
+
+// listReport is the breakdown of a list of all of etcd, written to --report-file.
+type listReport struct {
+	Revision        int64                `json:"revision"`
+	StartTime       time.Time            `json:"startTime"`
+	DurationSeconds float64              `json:"durationSeconds"`
+	Keys            int                  `json:"keys"`
+	Bytes           int64                `json:"bytes"`
+	Resources       []listResourceReport `json:"resources"`
+}
+
+type listResourceReport struct {
+	Resource        string  `json:"resource"`
+	Keys            int     `json:"keys"`
+	Bytes           int64   `json:"bytes"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// newListProgress returns the progress of listing the ranges under the prefix, reported to w at the interval.
+// It is nil if there is nothing to report.
+func newListProgress(w io.Writer, interval time.Duration, report bool, prefix string, ranges []string) *listProgress {
+	if interval == 0 && !report {
+		return nil
+	}
+	p := &listProgress{
+		w:        w,
+		interval: interval,
+		start:    time.Now(),
+		byRange:  map[string]*listResourceProgress{},
+	}
+	for _, rangePrefix := range ranges {
+		resource := strings.TrimPrefix(rangePrefix, prefix+"/")
+		if gr, _, ok := groupResourceFromKey(prefix, rangePrefix+"/"); ok && gr.Resource != "" {
+			resource = gr.String()
+		}
+		r := &listResourceProgress{
+			listResourceReport: listResourceReport{
+				Resource: resource,
+			},
+		}
+		p.resources = append(p.resources, r)
+		p.byRange[rangePrefix] = r
+	}
+	return p
+}
+
+// run reports the progress at the interval until the returned stop is called, which reports it a last time.
+func (p *listProgress) run(ctx context.Context) (stop func()) {
+	if p == nil || p.interval == 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.report()
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+		p.report()
+	}
+}
+
+// begin marks the list of the range as started.
+func (p *listProgress) begin(rangePrefix string) {
+	if p == nil {
+		return
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	r := p.byRange[rangePrefix]
+	r.started = true
+	r.start = time.Now()
+}
+
+// end marks the list of the range as completed.
+func (p *listProgress) end(rangePrefix string) {
+	if p == nil {
+		return
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	r := p.byRange[rangePrefix]
+	r.done = true
+	r.DurationSeconds = time.Since(r.start).Seconds()
+}
+
+// wrap returns the response callback that counts the key-values of the range before calling the response.
+func (p *listProgress) wrap(rangePrefix string, response func(kv *client.KeyValue) error) func(kv *client.KeyValue) error {
+	if p == nil {
+		return response
+	}
+	r := p.byRange[rangePrefix]
+	return func(kv *client.KeyValue) error {
+		if kv != nil {
+			p.mut.Lock()
+			r.Keys++
+			r.Bytes += int64(len(kv.Key) + len(kv.Value))
+			p.mut.Unlock()
+		}
+		return response(kv)
+	}
+}
+
+// report prints the resources completed, the keys and bytes so far, and those of the resources being listed.
+func (p *listProgress) report() {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	var completed, keys int
+	var bytes int64
+	var current []string
+	for _, r := range p.resources {
+		keys += r.Keys
+		bytes += r.Bytes
+		if r.done {
+			completed++
+		} else if r.started {
+			current = append(current, fmt.Sprintf("%s %d keys %s", r.Resource, r.Keys, formatBytes(float64(r.Bytes))))
+		}
+	}
+	line := fmt.Sprintf("listed %d/%d resources, %d keys, %s in %s",
+		completed, len(p.resources), keys, formatBytes(float64(bytes)), time.Since(p.start).Truncate(time.Second))
+	if len(current) != 0 {
+		line += ", listing " + strings.Join(current, ", ")
+	}
+	fmt.Fprintln(p.w, line)
+}
+
+// listReport returns the breakdown of the list at the revision.
+func (p *listProgress) listReport(revision int64) listReport {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	report := listReport{
+		Revision:        revision,
+		StartTime:       p.start,
+		DurationSeconds: time.Since(p.start).Seconds(),
+		Resources:       make([]listResourceReport, 0, len(p.resources)),
+	}
+	for _, r := range p.resources {
+		report.Keys += r.Keys
+		report.Bytes += r.Bytes
+		report.Resources = append(report.Resources, r.listResourceReport)
+	}
+	return report
+}
+
+// writeReport writes the breakdown of the list at the revision to the file as JSON.
+func (p *listProgress) writeReport(path string, revision int64) error {
+	if p == nil || path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(p.listReport(revision), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+func TestListProgress(t *testing.T) {
+	var buf bytes.Buffer
+	ranges := []string{"/registry/configmaps", "/registry/example.com/widgets", "/registry/services/specs"}
+	progress := newListProgress(&buf, 0, true, "/registry", ranges)
+	nop := func(kv *client.KeyValue) error { return nil }
+
+	for i, rangePrefix := range ranges[:2] {
+		progress.begin(rangePrefix)
+		response := progress.wrap(rangePrefix, nop)
+		for j := 0; j <= i; j++ {
+			err := response(&client.KeyValue{Key: []byte("key"), Value: []byte("value")})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	progress.end(ranges[0])
+
+	progress.report()
+	if got, want := buf.String(), "listed 1/3 resources, 3 keys, 24 B in 0s, listing widgets.example.com 2 keys 16 B\n"; got != want {
+		t.Errorf("report() = %q, want %q", got, want)
+	}
+
+	report := progress.listReport(10)
+	for i := range report.Resources {
+		// The durations vary from run to run
+		report.Resources[i].DurationSeconds = 0
+	}
+	wantResources := []listResourceReport{
+		{Resource: "configmaps", Keys: 1, Bytes: 8},
+		{Resource: "widgets.example.com", Keys: 2, Bytes: 16},
+		{Resource: "services", Keys: 0, Bytes: 0},
+	}
+	if report.Revision != 10 || report.Keys != 3 || report.Bytes != 24 || !reflect.DeepEqual(report.Resources, wantResources) {
+		t.Errorf("listReport() = %+v, want 3 keys of 24 B in %+v", report, wantResources)
+	}
+
+	if newListProgress(&buf, 0, false, "/registry", ranges) != nil {
+		t.Errorf("newListProgress() is not nil with nothing to report")
+	}
+	// The keys right under the prefix are listed as one range
+	progress = newListProgress(&buf, 0, true, "/registry", []string{"/registry"})
+	if got := progress.listReport(0).Resources[0].Resource; !strings.HasPrefix(got, "/registry") {
+		t.Errorf("resource of the prefix = %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// liveLeaseThreshold is how recently a lease must have been renewed for the control plane to look active.
+// The leader election leases of the controllers and the identity leases of the apiservers are renewed every few seconds.
+const liveLeaseThreshold = time.Minute
+
+// checkLiveControlPlane warns if the control plane in front of etcd appears to be running,
+// and refuses the write unless --i-know-what-i-am-doing is passed,
+// because writing behind a running apiserver can leave its watch cache stale.
+func checkLiveControlPlane(ctx context.Context, cmd *cobra.Command, etcdclient client.Client, prefix string) error {
+	// Writes through the apiserver keep its watch cache up to date
+	kubeconfig, err := cmd.Flags().GetString("kubeconfig")
+	if err != nil {
+		return err
+	}
+	portForward, err := cmd.Flags().GetBool("port-forward")
+	if err != nil {
+		return err
+	}
+	if kubeconfig != "" && !portForward {
+		return nil
+	}
+
+	confirmed, err := cmd.Flags().GetBool("i-know-what-i-am-doing")
+	if err != nil {
+		return err
+	}
+
+	// The renew times are by the clocks of the control plane, they are taken against the time of the etcd server
+	// rather than the local one, which may be off by more than the threshold
+	now := time.Now()
+	skew, err := measureClockSkew(ctx, etcdclient)
+	if err == nil {
+		now = now.Add(-skew.Skew - skew.Uncertainty)
+	} else if !errors.Is(err, client.ErrServerTimeUnsupported) {
+		fmt.Fprintf(os.Stderr, "warning: can not measure the clock skew against the server, the leases are taken against the local time: %v\n", err)
+	}
+
+	leases, err := renewedLeases(ctx, etcdclient, prefix, now.Add(-liveLeaseThreshold))
+	if err != nil {
+		return err
+	}
+	if len(leases) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "WARNING: the control plane appears to be running, the leases %s were renewed within the last %s.\n", strings.Join(leases, ", "), liveLeaseThreshold)
+	fmt.Fprintf(os.Stderr, "WARNING: writing to etcd behind a running apiserver can leave its watch cache serving stale objects.\n")
+	if !confirmed {
+		return fmt.Errorf("refusing to write to a live cluster, pass --i-know-what-i-am-doing to continue")
+	}
+	return nil
+}
+
+// renewedLeases returns the names of the leases in kube-system renewed since the time.
+func renewedLeases(ctx context.Context, etcdclient client.Client, prefix string, since time.Time) ([]string, error) {
+	var leases []string
+	_, err := etcdclient.Get(ctx, prefix,
+		client.WithGR(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}),
+		client.WithName("", "kube-system"),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			name, ok := leaseRenewedSince(kv.Value, since)
+			if ok {
+				leases = append(leases, name)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return leases, nil
+}
+
+// leaseRenewedSince returns the name of the lease and whether it was renewed since the time.
+// Values that can not be decoded are not counted.
+func leaseRenewedSince(value []byte, since time.Time) (string, bool) {
+	_, data, err := convertValue(value, encoding.JsonMediaType)
+	if err != nil {
+		return "", false
+	}
+	var lease coordinationv1.Lease
+	err = json.Unmarshal(data, &lease)
+	if err != nil {
+		return "", false
+	}
+	if lease.Spec.RenewTime == nil || lease.Spec.RenewTime.Time.Before(since) {
+		return "", false
+	}
+	return lease.Name, true
+}
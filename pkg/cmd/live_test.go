@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"github.com/wzshiming/kectl/pkg/scheme"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestLeaseRenewedSince(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lease := func(renewTime time.Time) []byte {
+		data := fmt.Sprintf(`{"apiVersion":"coordination.k8s.io/v1","kind":"Lease","metadata":{"name":"kube-scheduler","namespace":"kube-system"},"spec":{"renewTime":%q}}`,
+			renewTime.Format("2006-01-02T15:04:05.000000Z07:00"))
+		pb, _, err := encoding.Convert(scheme.Codecs, encoding.JsonMediaType, encoding.StorageBinaryMediaType, []byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pb
+	}
+
+	tests := []struct {
+		name  string
+		value []byte
+		want  bool
+	}{
+		{
+			name:  "renewed",
+			value: lease(now.Add(-10 * time.Second)),
+			want:  true,
+		},
+		{
+			name:  "expired",
+			value: lease(now.Add(-time.Hour)),
+		},
+		{
+			name:  "never renewed",
+			value: []byte(`{"apiVersion":"coordination.k8s.io/v1","kind":"Lease","metadata":{"name":"kube-scheduler"}}`),
+		},
+		{
+			name:  "malformed",
+			value: []byte("k8s\x00"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got := leaseRenewedSince(tt.value, now.Add(-liveLeaseThreshold))
+			if got != tt.want {
+				t.Errorf("leaseRenewedSince() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckLiveControlPlane(t *testing.T) {
+	tests := []struct {
+		name    string
+		renewed time.Duration
+		args    []string
+		wantErr bool
+	}{
+		{
+			name:    "live",
+			renewed: 10 * time.Second,
+			wantErr: true,
+		},
+		{
+			name:    "live confirmed",
+			renewed: 10 * time.Second,
+			args:    []string{"--i-know-what-i-am-doing"},
+		},
+		{
+			name:    "stopped",
+			renewed: time.Hour,
+		},
+		{
+			// The writes through the apiserver are seen by its watch cache
+			name:    "through the apiserver",
+			renewed: 10 * time.Second,
+			args:    []string{"--kubeconfig", "kubeconfig"},
+		},
+		{
+			name:    "port-forward",
+			renewed: 10 * time.Second,
+			args:    []string{"--kubeconfig", "kubeconfig", "--port-forward"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			etcdclient := fake.NewClient()
+			lease := fmt.Sprintf(`{"apiVersion":"coordination.k8s.io/v1","kind":"Lease","metadata":{"name":"kube-scheduler","namespace":"kube-system"},"spec":{"renewTime":%q}}`,
+				time.Now().Add(-tt.renewed).UTC().Format("2006-01-02T15:04:05.000000Z07:00"))
+			err := etcdclient.Put(context.Background(), "/registry", []byte(lease),
+				client.WithGR(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}),
+				client.WithName("kube-scheduler", "kube-system"),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got error
+			cmd := NewCtlCommand()
+			cmd.AddCommand(&cobra.Command{
+				Use: "test",
+				RunE: func(cmd *cobra.Command, args []string) error {
+					got = checkLiveControlPlane(cmd.Context(), cmd, etcdclient, "/registry")
+					return nil
+				},
+			})
+			cmd.SetArgs(append([]string{"test"}, tt.args...))
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			err = cmd.Execute()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got != nil) != tt.wantErr {
+				t.Errorf("checkLiveControlPlane() error = %v, wantErr %v", got, tt.wantErr)
+			}
+		})
+	}
+}
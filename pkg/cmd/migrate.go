@@ -0,0 +1,414 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/scheme"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newCtlMigrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrates the stored form of the resource of k8s in etcd",
+	}
+	cmd.AddCommand(
+		newCtlMigrateEncodingCommand(),
+		newCtlMigrateStorageVersionCommand(),
+	)
+	return cmd
+}
+
+type migrateEncodingFlagpole struct {
+	Output    string
+	Prefix    string
+	ChunkSize int64
+	To        string
+}
+
+func newCtlMigrateEncodingCommand() *cobra.Command {
+	flags := &migrateEncodingFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.RangeArgs(0, 1),
+		Use:   "encoding [resource]",
+		Short: "Rewrites the resource of k8s in etcd with another storage encoding",
+		Long: "Rewrites the resource of k8s in etcd with another storage encoding.\n" +
+			"Each value is written back only if it has not been modified since it was read.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = checkLiveControlPlane(cmd.Context(), cmd, etcdclient, flags.Prefix)
+			if err != nil {
+				return err
+			}
+			err = migrateEncodingCommand(cmd.Context(), etcdclient, flags, args)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "key", "output format. One of: (key, none).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().Int64Var(&flags.ChunkSize, "chunk-size", 500, "chunk size of the list pager")
+	cmd.Flags().StringVar(&flags.To, "to", "", "target storage encoding. One of: (protobuf, json).")
+
+	return cmd
+}
+
+func migrateEncodingCommand(ctx context.Context, etcdclient client.Client, flags *migrateEncodingFlagpole, args []string) error {
+	var outMediaType string
+	switch flags.To {
+	case "protobuf":
+		outMediaType = encoding.StorageBinaryMediaType
+	case "json":
+		outMediaType = encoding.JsonMediaType
+	default:
+		return fmt.Errorf("unsupported target encoding: %q", flags.To)
+	}
+
+	var targetGr schema.GroupResource
+	if len(args) != 0 {
+		gr := schema.ParseGroupResource(args[0])
+		if gr.Empty() {
+			return fmt.Errorf("invalid resource %q", args[0])
+		}
+		if correctGr, _, found := wellknown.CorrectGroupResource(gr); found {
+			gr = correctGr
+		}
+		targetGr = gr
+	}
+
+	var migrated, skipped, failed int
+	_, err := etcdclient.Get(ctx, flags.Prefix,
+		client.WithGR(targetGr),
+		client.WithPageLimit(flags.ChunkSize),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			if bytes.HasPrefix(kv.Value, []byte(encryptedValuePrefix)) {
+				skipped++
+				return nil
+			}
+
+			inMediaType, _, err := encoding.DetectAndExtract(kv.Value)
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: %v\n", kv.Key, err)
+				return nil
+			}
+			if inMediaType == outMediaType {
+				skipped++
+				return nil
+			}
+
+			data, _, err := encoding.Convert(scheme.Codecs, inMediaType, outMediaType, kv.Value)
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: %v\n", kv.Key, err)
+				return nil
+			}
+
+			gr, rest, ok := groupResourceFromKey(flags.Prefix, string(kv.Key))
+			if !ok {
+				return fmt.Errorf("key %q is not under %q", kv.Key, flags.Prefix)
+			}
+			namespace, name, err := splitName(rest)
+			if err != nil {
+				return err
+			}
+
+			err = etcdclient.Put(ctx, flags.Prefix, data,
+				client.WithGR(gr),
+				client.WithName(name, namespace),
+				client.WithKey(string(kv.Key)),
+				client.WithModRevision(kv.Revision),
+			)
+			if err != nil {
+				if errors.Is(err, client.ErrConflict) {
+					failed++
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					return nil
+				}
+				return err
+			}
+
+			migrated++
+			if flags.Output == "key" {
+				fmt.Fprintf(os.Stdout, "%s\n", kv.Key)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	if flags.Output == "key" {
+		fmt.Fprintf(os.Stderr, "migrate %d keys, skip %d keys, fail %d keys\n", migrated, skipped, failed)
+	}
+	return nil
+}
+
+type migrateStorageVersionFlagpole struct {
+	Output    string
+	Prefix    string
+	ChunkSize int64
+	ToVersion string
+}
+
+func newCtlMigrateStorageVersionCommand() *cobra.Command {
+	flags := &migrateStorageVersionFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "storage-version [resource]",
+		Short: "Rewrites the resource of k8s in etcd stored at other API versions to the target version",
+		Long: "Rewrites the resource of k8s in etcd stored at other API versions to the target version.\n" +
+			"The objects are strictly decoded and converted by the conversions known to kectl, objects with fields that do not exist in their version, " +
+			"or of versions without a conversion to the target version, are reported and left unchanged. The custom resources are relabeled.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = checkLiveControlPlane(cmd.Context(), cmd, etcdclient, flags.Prefix)
+			if err != nil {
+				return err
+			}
+			err = migrateStorageVersionCommand(cmd.Context(), etcdclient, flags, args)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "key", "output format. One of: (key, none).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().Int64Var(&flags.ChunkSize, "chunk-size", 500, "chunk size of the list pager")
+	cmd.Flags().StringVar(&flags.ToVersion, "to-version", "", "target API version, e.g. policy/v1")
+
+	return cmd
+}
+
+func migrateStorageVersionCommand(ctx context.Context, etcdclient client.Client, flags *migrateStorageVersionFlagpole, args []string) error {
+	targetGv, err := schema.ParseGroupVersion(flags.ToVersion)
+	if err != nil || targetGv.Version == "" {
+		return fmt.Errorf("invalid target version %q", flags.ToVersion)
+	}
+
+	gr := schema.ParseGroupResource(args[0])
+	if gr.Empty() {
+		return fmt.Errorf("invalid resource %q", args[0])
+	}
+	if correctGr, _, found := wellknown.CorrectGroupResource(gr); found {
+		gr = correctGr
+	}
+
+	mediaType, err := client.MediaTypeFromGR(gr)
+	if err != nil {
+		return err
+	}
+
+	keyPrefix, err := client.PrefixFromGR(gr)
+	if err != nil {
+		return err
+	}
+	keyPrefix = flags.Prefix + "/" + keyPrefix + "/"
+
+	var migrated, skipped, failed int
+	_, err = etcdclient.Get(ctx, flags.Prefix,
+		client.WithGR(gr),
+		client.WithPageLimit(flags.ChunkSize),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			if bytes.HasPrefix(kv.Value, []byte(encryptedValuePrefix)) {
+				skipped++
+				return nil
+			}
+
+			data, migrate, err := convertStorageVersion(kv.Value, targetGv, mediaType)
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: %v\n", kv.Key, err)
+				return nil
+			}
+			if !migrate {
+				skipped++
+				return nil
+			}
+
+			namespace, name, err := splitKeyName(keyPrefix, string(kv.Key))
+			if err != nil {
+				return err
+			}
+
+			err = etcdclient.Put(ctx, flags.Prefix, data,
+				client.WithGR(gr),
+				client.WithName(name, namespace),
+				client.WithKey(string(kv.Key)),
+				client.WithModRevision(kv.Revision),
+			)
+			if err != nil {
+				if errors.Is(err, client.ErrConflict) {
+					failed++
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					return nil
+				}
+				return err
+			}
+
+			migrated++
+			if flags.Output == "key" {
+				fmt.Fprintf(os.Stdout, "%s\n", kv.Key)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	if flags.Output == "key" {
+		fmt.Fprintf(os.Stderr, "migrate %d keys, skip %d keys, fail %d keys\n", migrated, skipped, failed)
+	}
+	return nil
+}
+
+// convertStorageVersion returns the value converted to the target version in the media type,
+// and false if the value is already stored at the target version.
+func convertStorageVersion(value []byte, targetGv schema.GroupVersion, mediaType string) ([]byte, bool, error) {
+	_, data, err := convertValue(value, encoding.JsonMediaType)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, migrate, err := convertVersion(data, targetGv)
+	if err != nil || !migrate {
+		return nil, false, err
+	}
+
+	data, err = convertToStorage(data, mediaType)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// convertVersion returns the JSON object converted to the target version of the same group,
+// and false if it is already at the target version.
+// The kinds known to the scheme are converted by its conversions, through the internal version of the group if it has one,
+// and the versions without a conversion between them are an error rather than relabeled, as their fields may mean other things,
+// such as the empty selector of a PodDisruptionBudget selecting no pods in policy/v1beta1 and all of them in policy/v1.
+// The kinds unknown to the scheme are the custom resources, which are relabeled as the apiserver does without a conversion webhook.
+func convertVersion(data []byte, targetGv schema.GroupVersion) ([]byte, bool, error) {
+	obj := map[string]any{}
+	err := json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil, false, err
+	}
+
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	if apiVersion == targetGv.String() {
+		return data, false, nil
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, false, err
+	}
+	if gv.Group != targetGv.Group {
+		return nil, false, fmt.Errorf("can not convert %s to another group %s", apiVersion, targetGv)
+	}
+
+	gvk := gv.WithKind(kind)
+	targetGvk := targetGv.WithKind(kind)
+	if !scheme.Scheme.Recognizes(gvk) && !scheme.Scheme.Recognizes(targetGvk) {
+		obj["apiVersion"] = targetGv.String()
+		data, err = json.Marshal(obj)
+		if err != nil {
+			return nil, false, err
+		}
+		return data, true, nil
+	}
+
+	out, err := convertObjectVersion(data, gvk, targetGvk)
+	if err != nil {
+		return nil, false, fmt.Errorf("can not convert %s to %s: %w", apiVersion, targetGv, err)
+	}
+	data, err = json.Marshal(out)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// convertObjectVersion decodes the JSON object as gvk strictly and converts it to the targetGvk with the scheme.
+func convertObjectVersion(data []byte, gvk, targetGvk schema.GroupVersionKind) (runtime.Object, error) {
+	err := strictDecode(data, gvk)
+	if err != nil {
+		return nil, err
+	}
+	in, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, in)
+	if err != nil {
+		return nil, err
+	}
+	out, err := scheme.Scheme.New(targetGvk)
+	if err != nil {
+		return nil, err
+	}
+
+	internalGvk := gvk.GroupKind().WithVersion(runtime.APIVersionInternal)
+	if scheme.Scheme.Recognizes(internalGvk) {
+		internal, err := scheme.Scheme.New(internalGvk)
+		if err != nil {
+			return nil, err
+		}
+		err = scheme.Scheme.Convert(in, internal, nil)
+		if err != nil {
+			return nil, err
+		}
+		in = internal
+	}
+	err = scheme.Scheme.Convert(in, out, nil)
+	if err != nil {
+		return nil, err
+	}
+	out.GetObjectKind().SetGroupVersionKind(targetGvk)
+	return out, nil
+}
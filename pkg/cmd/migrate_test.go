@@ -0,0 +1,245 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/wzshiming/kectl/pkg/client"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestConvertStorageVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		mediaType   string
+		wantMigrate bool
+		wantErr     bool
+	}{
+		{
+			name:        "pdb v1beta1 to v1",
+			value:       `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget","metadata":{"name":"pdb","namespace":"default"},"spec":{"minAvailable":1}}`,
+			mediaType:   encoding.StorageBinaryMediaType,
+			wantMigrate: true,
+		},
+		{
+			name:      "already at target",
+			value:     `{"apiVersion":"policy/v1","kind":"PodDisruptionBudget","metadata":{"name":"pdb","namespace":"default"}}`,
+			mediaType: encoding.StorageBinaryMediaType,
+		},
+		{
+			name:      "unknown field in target",
+			value:     `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget","metadata":{"name":"pdb","namespace":"default"},"spec":{"removed":true}}`,
+			mediaType: encoding.StorageBinaryMediaType,
+			wantErr:   true,
+		},
+		{
+			name:      "another group",
+			value:     `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"d","namespace":"default"}}`,
+			mediaType: encoding.StorageBinaryMediaType,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, migrate, err := convertStorageVersion([]byte(tt.value), schema.GroupVersion{Group: "policy", Version: "v1"}, tt.mediaType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertStorageVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if migrate != tt.wantMigrate {
+				t.Fatalf("convertStorageVersion() migrate = %v, want %v", migrate, tt.wantMigrate)
+			}
+			if !migrate {
+				return
+			}
+			typeMeta, err := encoding.DecodeTypeMeta(tt.mediaType, data)
+			if err != nil {
+				t.Fatalf("DecodeTypeMeta() error = %v", err)
+			}
+			if typeMeta.APIVersion != "policy/v1" {
+				t.Errorf("convertStorageVersion() apiVersion = %v, want policy/v1", typeMeta.APIVersion)
+			}
+		})
+	}
+}
+
+func TestConvertVersion(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		targetGv      schema.GroupVersion
+		wantConverted bool
+		wantSelector  string
+		wantErr       bool
+	}{
+		{
+			name:          "older version",
+			data:          `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget","metadata":{"name":"pdb"},"spec":{"minAvailable":1,"selector":{"matchLabels":{"app":"a"}}}}`,
+			targetGv:      schema.GroupVersion{Group: "policy", Version: "v1"},
+			wantConverted: true,
+			wantSelector:  `{"matchLabels":{"app":"a"}}`,
+		},
+		{
+			// An empty selector selects no pods in policy/v1beta1, but all of them in policy/v1
+			name:          "empty selector",
+			data:          `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget","metadata":{"name":"pdb"},"spec":{"minAvailable":1,"selector":{}}}`,
+			targetGv:      schema.GroupVersion{Group: "policy", Version: "v1"},
+			wantConverted: true,
+			wantSelector:  `{"matchExpressions":[{"key":"pdb.kubernetes.io/deprecated-v1beta1-empty-selector-match","operator":"Exists"}]}`,
+		},
+		{
+			name:          "selector of all pods",
+			data:          `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget","metadata":{"name":"pdb"},"spec":{"selector":{"matchExpressions":[{"key":"pdb.kubernetes.io/deprecated-v1beta1-empty-selector-match","operator":"DoesNotExist"}]}}}`,
+			targetGv:      schema.GroupVersion{Group: "policy", Version: "v1"},
+			wantConverted: true,
+			wantSelector:  `{}`,
+		},
+		{
+			name:     "same version",
+			data:     `{"apiVersion":"policy/v1","kind":"PodDisruptionBudget","metadata":{"name":"pdb"}}`,
+			targetGv: schema.GroupVersion{Group: "policy", Version: "v1"},
+		},
+		{
+			name:     "no conversion",
+			data:     `{"apiVersion":"policy/v1","kind":"PodDisruptionBudget","metadata":{"name":"pdb"},"spec":{"selector":{}}}`,
+			targetGv: schema.GroupVersion{Group: "policy", Version: "v1beta1"},
+			wantErr:  true,
+		},
+		{
+			name:     "field not in version",
+			data:     `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget","metadata":{"name":"pdb"},"spec":{"unknown":1}}`,
+			targetGv: schema.GroupVersion{Group: "policy", Version: "v1"},
+			wantErr:  true,
+		},
+		{
+			name:     "another group",
+			data:     `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"deploy"}}`,
+			targetGv: schema.GroupVersion{Group: "policy", Version: "v1"},
+			wantErr:  true,
+		},
+		{
+			name:          "custom resource",
+			data:          `{"apiVersion":"example.com/v1beta1","kind":"Widget","metadata":{"name":"w"},"spec":{"size":1}}`,
+			targetGv:      schema.GroupVersion{Group: "example.com", Version: "v1"},
+			wantConverted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, converted, err := convertVersion([]byte(tt.data), tt.targetGv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if converted != tt.wantConverted {
+				t.Fatalf("convertVersion() converted = %v, want %v", converted, tt.wantConverted)
+			}
+			if !converted {
+				return
+			}
+			var obj struct {
+				APIVersion string `json:"apiVersion"`
+				Spec       struct {
+					Selector json.RawMessage `json:"selector"`
+				} `json:"spec"`
+			}
+			err = json.Unmarshal(data, &obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.APIVersion != tt.targetGv.String() {
+				t.Errorf("convertVersion() apiVersion = %s, want %s", obj.APIVersion, tt.targetGv)
+			}
+			if tt.wantSelector != "" && string(obj.Spec.Selector) != tt.wantSelector {
+				t.Errorf("convertVersion() selector = %s, want %s", obj.Spec.Selector, tt.wantSelector)
+			}
+		})
+	}
+}
+
+func TestMigrateCommandHashedKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		gr          schema.GroupResource
+		value       string
+		migrate     func(ctx context.Context, etcdclient client.Client) error
+		wantVersion string
+	}{
+		{
+			name:  "encoding",
+			gr:    schema.GroupResource{Resource: "configmaps"},
+			value: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"}}`,
+			migrate: func(ctx context.Context, etcdclient client.Client) error {
+				return migrateEncodingCommand(ctx, etcdclient, &migrateEncodingFlagpole{
+					Output: "none",
+					Prefix: "/registry",
+					To:     "protobuf",
+				}, []string{"configmaps"})
+			},
+			wantVersion: "v1",
+		},
+		{
+			name:  "storage-version",
+			gr:    schema.GroupResource{Group: "policy", Resource: "poddisruptionbudgets"},
+			value: `{"apiVersion":"policy/v1beta1","kind":"PodDisruptionBudget","metadata":{"name":"a","namespace":"default"},"spec":{"minAvailable":1}}`,
+			migrate: func(ctx context.Context, etcdclient client.Client) error {
+				return migrateStorageVersionCommand(ctx, etcdclient, &migrateStorageVersionFlagpole{
+					Output:    "none",
+					Prefix:    "/registry",
+					ToVersion: "policy/v1",
+				}, []string{"poddisruptionbudgets.policy"})
+			},
+			wantVersion: "policy/v1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			etcdclient, raw := newHashedKeysClient(t)
+			err := etcdclient.Put(ctx, "/registry", []byte(tt.value), client.WithGR(tt.gr), client.WithName("a", "default"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = tt.migrate(ctx, etcdclient)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The key listed is rewritten, rather than the key of its hash hashed again
+			values := storedValues(t, raw)
+			if len(values) != 1 {
+				t.Fatalf("stored keys = %d, want 1", len(values))
+			}
+			for key, value := range values {
+				inMediaType, _, err := encoding.DetectAndExtract([]byte(value))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if inMediaType != encoding.StorageBinaryMediaType {
+					t.Errorf("%s media type = %s, want %s", key, inMediaType, encoding.StorageBinaryMediaType)
+				}
+				if got := storageVersionOf([]byte(value)); got != tt.wantVersion {
+					t.Errorf("%s version = %s, want %s", key, got, tt.wantVersion)
+				}
+			}
+		})
+	}
+}
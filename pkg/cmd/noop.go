@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/wzshiming/kectl/pkg/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// noopFilter skips the events of a watch updating an object without changing it,
+// such as the updates of controllers writing back what they read, which can make up most of a recording.
+type noopFilter struct {
+	skipped int
+}
+
+// newNoopFilter returns the filter of the no-op updates, or nil if they are kept.
+func newNoopFilter(keep bool) *noopFilter {
+	if keep {
+		return nil
+	}
+	return &noopFilter{}
+}
+
+func (f *noopFilter) wrap(response func(kv *client.KeyValue) error) func(kv *client.KeyValue) error {
+	if f == nil {
+		return response
+	}
+	return func(kv *client.KeyValue) error {
+		// Only the updates of a watch come with the previous value
+		if len(kv.Value) != 0 && len(kv.PrevValue) != 0 && isNoopUpdate(kv.PrevValue, kv.Value) {
+			f.skipped++
+			return nil
+		}
+		return response(kv)
+	}
+}
+
+// isNoopUpdate returns whether the patch from the value before to the one after is empty,
+// apart from the resourceVersion and the managedFields that any write of the object changes.
+// The values that can not be decoded are always changed.
+func isNoopUpdate(before, after []byte) bool {
+	if bytes.Equal(before, after) {
+		return true
+	}
+	beforeJSON, err := noopComparable(before)
+	if err != nil {
+		return false
+	}
+	afterJSON, err := noopComparable(after)
+	if err != nil {
+		return false
+	}
+	patch, err := twoWayPatch(beforeJSON, afterJSON)
+	if err != nil {
+		return false
+	}
+	return string(patch) == "{}"
+}
+
+func noopComparable(value []byte) ([]byte, error) {
+	_, data, err := convertValue(value, encoding.JsonMediaType)
+	if err != nil {
+		return nil, err
+	}
+	obj := &unstructured.Unstructured{}
+	err = obj.UnmarshalJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+	return obj.MarshalJSON()
+}
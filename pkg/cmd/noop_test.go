@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+func TestIsNoopUpdate(t *testing.T) {
+	pod := `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"a","namespace":"default","resourceVersion":"1","managedFields":[{"manager":"kubelet","time":"2024-01-01T00:00:00Z"}]},"spec":{"containers":[{"name":"c","image":"nginx:1"}]}}`
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   bool
+	}{
+		{name: "same", before: pod, after: pod, want: true},
+		{
+			name:   "resourceVersion and managedFields",
+			before: pod,
+			after:  `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"a","namespace":"default","resourceVersion":"2","managedFields":[{"manager":"kubelet","time":"2024-01-01T00:01:00Z"}]},"spec":{"containers":[{"name":"c","image":"nginx:1"}]}}`,
+			want:   true,
+		},
+		{
+			name:   "reordered fields",
+			before: pod,
+			after:  `{"kind":"Pod","apiVersion":"v1","spec":{"containers":[{"image":"nginx:1","name":"c"}]},"metadata":{"namespace":"default","name":"a"}}`,
+			want:   true,
+		},
+		{
+			name:   "changed",
+			before: pod,
+			after:  `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"a","namespace":"default"},"spec":{"containers":[{"name":"c","image":"nginx:2"}]}}`,
+		},
+		{
+			name:   "custom resource",
+			before: `{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"w","resourceVersion":"1"},"spec":{"size":1}}`,
+			after:  `{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"w","resourceVersion":"2"},"spec":{"size":1}}`,
+			want:   true,
+		},
+		{name: "not decoded", before: "a", after: "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNoopUpdate([]byte(tt.before), []byte(tt.after)); got != tt.want {
+				t.Errorf("isNoopUpdate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoopFilter(t *testing.T) {
+	value := []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"}}`)
+	events := []*client.KeyValue{
+		// Listed
+		{Key: []byte("/registry/configmaps/default/a"), Value: value},
+		// Updated without changes
+		{Key: []byte("/registry/configmaps/default/a"), Value: value, PrevValue: value},
+		// Deleted
+		{Key: []byte("/registry/configmaps/default/a"), PrevValue: value},
+	}
+
+	var written int
+	response := func(kv *client.KeyValue) error {
+		written++
+		return nil
+	}
+	f := newNoopFilter(false)
+	for _, kv := range events {
+		err := f.wrap(response)(kv)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if written != 2 || f.skipped != 1 {
+		t.Errorf("written %d and skipped %d, want 2 and 1", written, f.skipped)
+	}
+
+	if newNoopFilter(true) != nil {
+		t.Errorf("newNoopFilter() keeping the no-op updates is not nil")
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// objectPartSize is the size of the parts an object is uploaded in, above the minimum part of S3 of 5MiB
+// and a multiple of the 256KiB chunks of the resumable uploads of GCS.
+const objectPartSize = 8 << 20
+
+// objectStore is a bucket of an object storage, the recordings are streamed from and to.
+type objectStore interface {
+	// get returns the content of the object.
+	get(ctx context.Context, key string) (io.ReadCloser, error)
+	// put writes the object at once.
+	put(ctx context.Context, key string, data []byte) error
+	// upload starts the upload of the object in parts.
+	upload(ctx context.Context, key string) (objectUpload, error)
+}
+
+// objectUpload is an upload of an object in parts, which is only visible once completed.
+type objectUpload interface {
+	// part uploads the part numbered from 1, the last one may be smaller than objectPartSize or empty.
+	part(ctx context.Context, number int, offset int64, data []byte, last bool) error
+	// complete makes the object of the parts visible.
+	complete(ctx context.Context) error
+	// abort discards the parts uploaded.
+	abort(ctx context.Context) error
+}
+
+// objectStorageSchemes are the schemes of the paths of object storages, with the store of the bucket.
+var objectStorageSchemes = map[string]func(bucket string) (objectStore, error){
+	"s3":     newS3Store,
+	"gcs":    newGCSStore,
+	"gs":     newGCSStore,
+	"azblob": newAzblobStore,
+}
+
+// isObjectStoragePath returns whether the path is an object in an object storage, such as s3://bucket/key.
+func isObjectStoragePath(path string) bool {
+	scheme, _, ok := strings.Cut(path, "://")
+	if !ok {
+		return false
+	}
+	_, ok = objectStorageSchemes[scheme]
+	return ok
+}
+
+// parseObjectStoragePath returns the store of the bucket of the path and the key of the object in it.
+func parseObjectStoragePath(path string) (objectStore, string, error) {
+	scheme, rest, _ := strings.Cut(path, "://")
+	newStore, ok := objectStorageSchemes[scheme]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported object storage %q", path)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		return nil, "", fmt.Errorf("invalid object storage path %q, want %s://bucket/key", path, scheme)
+	}
+	store, err := newStore(bucket)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", path, err)
+	}
+	return store, key, nil
+}
+
+// openObject opens the object at the path to be read as it is downloaded.
+func openObject(ctx context.Context, path string) (io.ReadCloser, error) {
+	store, key, err := parseObjectStoragePath(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := store.get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// objectWriter uploads the writes to an object in parts as they fill up, without a local file.
+// The object is only written once closed, and left as it was if the writes are aborted.
+type objectWriter struct {
+	ctx   context.Context
+	path  string
+	store objectStore
+	key   string
+
+	upload objectUpload
+	buf    bytes.Buffer
+	parts  int
+	offset int64
+}
+
+// createObject returns the writer to the object at the path.
+func createObject(ctx context.Context, path string) (*objectWriter, error) {
+	store, key, err := parseObjectStoragePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return &objectWriter{
+		ctx:   ctx,
+		path:  path,
+		store: store,
+		key:   key,
+	}, nil
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		chunk := min(len(p), objectPartSize-w.buf.Len())
+		w.buf.Write(p[:chunk])
+		p = p[chunk:]
+		if w.buf.Len() == objectPartSize {
+			err := w.flush(false)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flush uploads the buffer as the next part.
+func (w *objectWriter) flush(last bool) error {
+	if w.upload == nil {
+		upload, err := w.store.upload(w.ctx, w.key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", w.path, err)
+		}
+		w.upload = upload
+	}
+	w.parts++
+	err := w.upload.part(w.ctx, w.parts, w.offset, w.buf.Bytes(), last)
+	if err != nil {
+		return fmt.Errorf("%s: part %d: %w", w.path, w.parts, err)
+	}
+	w.offset += int64(w.buf.Len())
+	w.buf.Reset()
+	return nil
+}
+
+// Close writes the object, at once if it fits in a part.
+func (w *objectWriter) Close() error {
+	if w.upload == nil {
+		err := w.store.put(w.ctx, w.key, w.buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("%s: %w", w.path, err)
+		}
+		return nil
+	}
+	err := w.flush(true)
+	if err != nil {
+		return err
+	}
+	err = w.upload.complete(w.ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", w.path, err)
+	}
+	return nil
+}
+
+// Abort discards the parts uploaded, the object is left as it was.
+func (w *objectWriter) Abort() error {
+	if w.upload == nil {
+		return nil
+	}
+	// The writes may have been aborted by the context
+	err := w.upload.abort(context.WithoutCancel(w.ctx))
+	if err != nil {
+		return fmt.Errorf("%s: %w", w.path, err)
+	}
+	return nil
+}
+
+// objectRequest sends the request to the object storage, failing unless its status is 2xx or one of the wanted ones.
+func objectRequest(client *http.Client, req *http.Request, wantStatus ...int) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	for _, status := range wantStatus {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("%s %s: %s: %s", req.Method, redactURL(req.URL), resp.Status, bytes.TrimSpace(msg))
+}
+
+// redactURL returns the URL without its query, which may hold credentials such as a SAS token.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.RawQuery = ""
+	return redacted.String()
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// azblobVersion is the version of the Blob service REST API the requests are made with.
+const azblobVersion = "2021-08-06"
+
+// azblobStore is a container of Azure Blob Storage in the account of AZURE_STORAGE_ACCOUNT,
+// with the SAS token of AZURE_STORAGE_SAS_TOKEN, or of the endpoint of AZURE_STORAGE_ENDPOINT such as Azurite.
+type azblobStore struct {
+	client    *http.Client
+	endpoint  string
+	container string
+	sasToken  string
+}
+
+func newAzblobStore(container string) (objectStore, error) {
+	endpoint := os.Getenv("AZURE_STORAGE_ENDPOINT")
+	if endpoint == "" {
+		account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+		if account == "" {
+			return nil, fmt.Errorf("AZURE_STORAGE_ACCOUNT or AZURE_STORAGE_ENDPOINT is required")
+		}
+		endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+	return &azblobStore{
+		client:    http.DefaultClient,
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		container: container,
+		sasToken:  strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"),
+	}, nil
+}
+
+func (s *azblobStore) blobURL(key string, query url.Values) string {
+	u := s.endpoint + "/" + url.PathEscape(s.container) + "/" + (&url.URL{Path: key}).EscapedPath()
+	q := query.Encode()
+	if s.sasToken != "" {
+		if q != "" {
+			q += "&"
+		}
+		q += s.sasToken
+	}
+	if q != "" {
+		u += "?" + q
+	}
+	return u
+}
+
+func (s *azblobStore) do(ctx context.Context, method, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.blobURL(key, query), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("X-Ms-Version", azblobVersion)
+	return objectRequest(s.client, req)
+}
+
+func (s *azblobStore) get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *azblobStore) put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, nil, data, http.Header{"X-Ms-Blob-Type": {"BlockBlob"}})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *azblobStore) upload(ctx context.Context, key string) (objectUpload, error) {
+	return &azblobUpload{
+		store: s,
+		key:   key,
+	}, nil
+}
+
+// azblobUpload is the upload of a block blob by block, the blocks are discarded by the service unless committed.
+type azblobUpload struct {
+	store  *azblobStore
+	key    string
+	blocks []string
+}
+
+func (u *azblobUpload) part(ctx context.Context, number int, offset int64, data []byte, last bool) error {
+	if len(data) == 0 {
+		return nil
+	}
+	// The IDs of the blocks of a blob are all of the same length
+	id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%08d", number)))
+	resp, err := u.store.do(ctx, http.MethodPut, u.key, url.Values{
+		"comp":    {"block"},
+		"blockid": {id},
+	}, data, nil)
+	if err != nil {
+		return err
+	}
+	u.blocks = append(u.blocks, id)
+	return resp.Body.Close()
+}
+
+func (u *azblobUpload) complete(ctx context.Context) error {
+	body := struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{
+		Latest: u.blocks,
+	}
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := u.store.do(ctx, http.MethodPut, u.key, url.Values{"comp": {"blocklist"}}, data,
+		http.Header{"Content-Type": {"application/xml"}})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (u *azblobUpload) abort(ctx context.Context) error {
+	return nil
+}
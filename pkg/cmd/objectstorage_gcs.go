@@ -0,0 +1,159 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// gcsStore is a bucket of Google Cloud Storage, with the OAuth2 token of GOOGLE_OAUTH_ACCESS_TOKEN,
+// such as of gcloud auth print-access-token, or of the emulator of STORAGE_EMULATOR_HOST.
+type gcsStore struct {
+	client   *http.Client
+	endpoint string
+	bucket   string
+	token    string
+}
+
+func newGCSStore(bucket string) (objectStore, error) {
+	endpoint := "https://storage.googleapis.com"
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		endpoint = host
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+	}
+	return &gcsStore{
+		client:   http.DefaultClient,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		bucket:   bucket,
+		token:    os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+	}, nil
+}
+
+func (s *gcsStore) do(req *http.Request, wantStatus ...int) (*http.Response, error) {
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return objectRequest(s.client, req, wantStatus...)
+}
+
+func (s *gcsStore) get(ctx context.Context, key string) (io.ReadCloser, error) {
+	u := s.endpoint + "/storage/v1/b/" + url.PathEscape(s.bucket) + "/o/" + url.PathEscape(key) + "?alt=media"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *gcsStore) uploadURL(uploadType, key string) string {
+	return s.endpoint + "/upload/storage/v1/b/" + url.PathEscape(s.bucket) + "/o?" + url.Values{
+		"uploadType": {uploadType},
+		"name":       {key},
+	}.Encode()
+}
+
+func (s *gcsStore) put(ctx context.Context, key string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.uploadURL("media", key), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *gcsStore) upload(ctx context.Context, key string) (objectUpload, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.uploadURL("resumable", key), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return nil, fmt.Errorf("start resumable upload: no session URI")
+	}
+	return &gcsUpload{
+		store:   s,
+		session: session,
+	}, nil
+}
+
+// gcsUpload is a resumable upload of GCS, the object is completed by its last part.
+type gcsUpload struct {
+	store   *gcsStore
+	session string
+}
+
+func (u *gcsUpload) part(ctx context.Context, number int, offset int64, data []byte, last bool) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.session, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	end := offset + int64(len(data))
+	total := "*"
+	if last {
+		total = fmt.Sprint(end)
+	}
+	if len(data) == 0 {
+		req.Header.Set("Content-Range", "bytes */"+total)
+	} else {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%s", offset, end-1, total))
+	}
+	// The parts before the last are acknowledged with 308 Resume Incomplete
+	resp, err := u.store.do(req, http.StatusPermanentRedirect)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (u *gcsUpload) complete(ctx context.Context) error {
+	return nil
+}
+
+func (u *gcsUpload) abort(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.session, nil)
+	if err != nil {
+		return err
+	}
+	// A cancelled upload is acknowledged with 499
+	resp, err := u.store.do(req, 499)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
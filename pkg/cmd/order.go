@@ -0,0 +1,103 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+// The orders of the objects when getting all of etcd.
+const (
+	// sortByKey is the order of the keys of etcd, which depends on how the distribution stores the resources
+	sortByKey = "key"
+	// sortByResource is the order of the group, resource, namespace and name of the objects,
+	// the same for the same objects whatever their keys, for two snapshots of the same state to be identical
+	sortByResource = "resource"
+)
+
+func parseSortBy(s string) (string, error) {
+	switch s {
+	case "", sortByKey:
+		return sortByKey, nil
+	case sortByResource:
+		return sortByResource, nil
+	}
+	return "", fmt.Errorf("invalid sort by %q. One of: (key, resource)", s)
+}
+
+// resourceOrderKey is what the objects are sorted by in the resource order.
+type resourceOrderKey struct {
+	group, resource, namespace, name, key string
+}
+
+func resourceOrderKeyOf(prefix, key string) resourceOrderKey {
+	gr, rest, ok := groupResourceFromKey(prefix, key)
+	if !ok {
+		// The keys outside the prefix go last, in key order
+		return resourceOrderKey{group: "\xff", key: key}
+	}
+	namespace, name, namespaced := strings.Cut(rest, "/")
+	if !namespaced {
+		namespace, name = "", rest
+	}
+	return resourceOrderKey{
+		group:     gr.Group,
+		resource:  gr.Resource,
+		namespace: namespace,
+		name:      name,
+		key:       key,
+	}
+}
+
+func (k resourceOrderKey) less(o resourceOrderKey) bool {
+	if k.group != o.group {
+		return k.group < o.group
+	}
+	if k.resource != o.resource {
+		return k.resource < o.resource
+	}
+	if k.namespace != o.namespace {
+		return k.namespace < o.namespace
+	}
+	if k.name != o.name {
+		return k.name < o.name
+	}
+	return k.key < o.key
+}
+
+// sortRangesByResource sorts the prefixes of the resources by their group and resource.
+func sortRangesByResource(prefix string, ranges []string) {
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return resourceOrderKeyOf(prefix, ranges[i]+"/").less(resourceOrderKeyOf(prefix, ranges[j]+"/"))
+	})
+}
+
+// sortByResourceOrder sorts the objects of a range by their group, resource, namespace and name,
+// which the key order only differs from for the namespaces that are the start of another, or the resources of a range listed at once.
+func sortByResourceOrder(prefix string, kvs []*client.KeyValue) {
+	keys := make(map[*client.KeyValue]resourceOrderKey, len(kvs))
+	for _, kv := range kvs {
+		keys[kv] = resourceOrderKeyOf(prefix, string(kv.Key))
+	}
+	sort.SliceStable(kvs, func(i, j int) bool {
+		return keys[kvs[i]].less(keys[kvs[j]])
+	})
+}
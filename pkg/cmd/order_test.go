@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGetCommandSortByResource(t *testing.T) {
+	etcdclient := fake.NewClient()
+	for _, obj := range []struct {
+		gr              schema.GroupResource
+		namespace, name string
+	}{
+		{schema.GroupResource{Group: "apps", Resource: "deployments"}, "default", "web"},
+		{schema.GroupResource{Resource: "nodes"}, "", "node0"},
+		{schema.GroupResource{Resource: "configmaps"}, "kube-system", "b"},
+		{schema.GroupResource{Resource: "configmaps"}, "kube", "a"},
+		{schema.GroupResource{Resource: "endpoints"}, "default", "kubernetes"},
+		{schema.GroupResource{Group: "example.com", Resource: "widgets"}, "default", "w"},
+	} {
+		err := etcdclient.Put(context.Background(), "/registry", []byte("{}"),
+			client.WithGR(obj.gr),
+			client.WithName(obj.name, obj.namespace),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	get := func(sortBy string, parallel int) string {
+		path := filepath.Join(dir, sortBy+".txt")
+		err := getCommand(context.Background(), etcdclient, &getFlagpole{
+			Output:       "key",
+			Prefix:       "/registry",
+			DecodeMode:   "lenient",
+			MaxBandwidth: "0",
+			Parallel:     parallel,
+			SortBy:       sortBy,
+			Path:         path,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(data))
+	}
+
+	want := strings.Join([]string{
+		"/registry/configmaps/kube/a",
+		"/registry/configmaps/kube-system/b",
+		"/registry/services/endpoints/default/kubernetes",
+		"/registry/minions/node0",
+		"/registry/deployments/default/web",
+		"/registry/example.com/widgets/default/w",
+	}, "\n")
+	for _, parallel := range []int{1, 3} {
+		if got := get(sortByResource, parallel); got != want {
+			t.Errorf("get --sort-by resource --parallel %d =\n%s\nwant\n%s", parallel, got, want)
+		}
+	}
+	if got := get(sortByKey, 1); got == want {
+		t.Errorf("get --sort-by key is already in the resource order, the test does not tell them apart")
+	}
+
+	err := getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:       "key",
+		Prefix:       "/registry",
+		DecodeMode:   "lenient",
+		MaxBandwidth: "0",
+		SortBy:       "name",
+	}, nil)
+	if err == nil {
+		t.Errorf("getCommand() with an invalid --sort-by did not fail")
+	}
+}
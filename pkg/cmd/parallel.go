@@ -0,0 +1,128 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+var errFirstKey = errors.New("first key")
+
+// resourceRanges returns the prefixes of the resources stored at the revision in key order,
+// found by getting the first key after each of them rather than listing all the keys.
+// It is not ok if a key is not under the prefix of a resource, such keys can only be listed at once.
+func resourceRanges(ctx context.Context, etcdclient client.Client, prefix string, revision int64) ([]string, bool, error) {
+	var ranges []string
+	var after string
+	for {
+		var key string
+		_, err := etcdclient.Get(ctx, prefix,
+			client.WithRevision(revision),
+			client.WithStartAfter(after),
+			client.WithPageLimit(1),
+			client.WithKeysOnly(),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				key = string(kv.Key)
+				return errFirstKey
+			}),
+		)
+		if err != nil && !errors.Is(err, errFirstKey) {
+			return nil, false, err
+		}
+		if key == "" {
+			return ranges, true, nil
+		}
+
+		_, rest, _ := groupResourceFromKey(prefix, key)
+		rangePrefix := strings.TrimSuffix(key, rest)
+		if rest == "" || rangePrefix == prefix+"/" || !strings.HasSuffix(rangePrefix, "/") {
+			return nil, false, nil
+		}
+		ranges = append(ranges, strings.TrimSuffix(rangePrefix, "/"))
+		// The keys of the resource are all before its prefix followed by the largest byte
+		after = rangePrefix + "\xff"
+	}
+}
+
+// parallelGet gets the ranges with up to workers at once, each into its own buffer,
+// and writes the buffers to w in the order of the ranges, the same as getting them one at a time.
+// A range is only started once the one workers before it has been written, which bounds the buffers held.
+func parallelGet(ctx context.Context, ranges []string, workers int, w io.Writer, get func(ctx context.Context, rangePrefix string, w io.Writer) error) error {
+	if workers <= 1 {
+		// One at a time is already in order, without buffering
+		for _, rangePrefix := range ranges {
+			err := get(ctx, rangePrefix, w)
+			if err != nil {
+				return fmt.Errorf("%s: %w", rangePrefix, err)
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		buf *bytes.Buffer
+		err error
+	}
+	results := make([]chan result, len(ranges))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+
+	slots := make(chan struct{}, workers)
+	go func() {
+		for i, rangePrefix := range ranges {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				buf := &bytes.Buffer{}
+				err := get(ctx, rangePrefix, buf)
+				results[i] <- result{buf: buf, err: err}
+			}()
+		}
+	}()
+
+	for i, rangePrefix := range ranges {
+		var res result
+		select {
+		case res = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if res.err != nil {
+			return fmt.Errorf("%s: %w", rangePrefix, res.err)
+		}
+		_, err := res.buf.WriteTo(w)
+		if err != nil {
+			return err
+		}
+		<-slots
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestResourceRanges(t *testing.T) {
+	etcdclient := fake.NewClient()
+	for _, obj := range []struct {
+		gr              schema.GroupResource
+		namespace, name string
+	}{
+		{schema.GroupResource{Resource: "configmaps"}, "default", "a"},
+		{schema.GroupResource{Resource: "configmaps"}, "kube-system", "b"},
+		{schema.GroupResource{Group: "example.com", Resource: "widgets"}, "default", "w"},
+		{schema.GroupResource{Resource: "nodes"}, "", "node0"},
+		{schema.GroupResource{Resource: "endpoints"}, "default", "kubernetes"},
+		{schema.GroupResource{Resource: "services"}, "default", "kubernetes"},
+	} {
+		err := etcdclient.Put(context.Background(), "/registry", []byte("{}"),
+			client.WithGR(obj.gr),
+			client.WithName(obj.name, obj.namespace),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ranges, ok, err := resourceRanges(context.Background(), etcdclient, "/registry", etcdclient.Revision())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/registry/configmaps",
+		"/registry/example.com/widgets",
+		"/registry/minions",
+		"/registry/services/endpoints",
+		"/registry/services/specs",
+	}
+	if !ok || !reflect.DeepEqual(ranges, want) {
+		t.Errorf("resourceRanges() = %v, %v, want %v", ranges, ok, want)
+	}
+
+	// The keys right under the prefix are not under a resource, they can only be listed at once
+	_, ok, err = resourceRanges(context.Background(), etcdclient, "/registry/configmaps/default", etcdclient.Revision())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Errorf("resourceRanges() ok with a key right under the prefix")
+	}
+}
+
+func TestParallelGet(t *testing.T) {
+	ranges := []string{"a", "b", "c", "d", "e"}
+	var buf bytes.Buffer
+	err := parallelGet(context.Background(), ranges, 3, &buf, func(ctx context.Context, rangePrefix string, w io.Writer) error {
+		// The first ranges complete last
+		time.Sleep(time.Duration('f'-rangePrefix[0]) * time.Millisecond)
+		_, err := fmt.Fprintln(w, rangePrefix)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "a\nb\nc\nd\ne\n"; got != want {
+		t.Errorf("parallelGet() wrote %q, want %q", got, want)
+	}
+
+	// One at a time is written as it goes
+	buf.Reset()
+	err = parallelGet(context.Background(), ranges, 1, &buf, func(ctx context.Context, rangePrefix string, w io.Writer) error {
+		_, err := fmt.Fprintln(w, rangePrefix)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "a\nb\nc\nd\ne\n"; got != want {
+		t.Errorf("parallelGet() with one worker wrote %q, want %q", got, want)
+	}
+
+	err = parallelGet(context.Background(), ranges, 2, io.Discard, func(ctx context.Context, rangePrefix string, w io.Writer) error {
+		if rangePrefix == "c" {
+			return fmt.Errorf("broken")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "c: broken" {
+		t.Errorf("parallelGet() error = %v, want c: broken", err)
+	}
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// writePolicy decides the order, the parallelism and the rate of the bulk writes by resource.
+type writePolicy struct {
+	Rules []writeRule `json:"rules"`
+	// Default applies to the resources matching no rule, and fills the fields left out of the rules
+	Default writeRule `json:"default"`
+}
+
+type writeRule struct {
+	Resource string `json:"resource,omitempty"`
+	// Phase orders the writes, all the writes of a phase complete before the ones of the next phase start
+	Phase int `json:"phase,omitempty"`
+	// Concurrency is the number of writes of the resource in flight
+	Concurrency int `json:"concurrency,omitempty"`
+	// QPS is the maximum writes of the resource per second, 0 for no limit
+	QPS float64 `json:"qps,omitempty"`
+}
+
+// defaultWritePolicy writes the CRDs and namespaces before the objects in them,
+// the pods with more parallelism as there are usually many of them, and throttles the events.
+func defaultWritePolicy() *writePolicy {
+	return &writePolicy{
+		Rules: []writeRule{
+			{Resource: "customresourcedefinitions.apiextensions.k8s.io", Phase: 1, Concurrency: 1},
+			{Resource: "namespaces", Phase: 1},
+			{Resource: "pods", Concurrency: 16},
+			{Resource: "events", QPS: 50},
+			{Resource: "events.events.k8s.io", QPS: 50},
+		},
+		Default: writeRule{
+			Phase:       2,
+			Concurrency: 4,
+		},
+	}
+}
+
+// loadWritePolicy returns the default policy, with the rules of the YAML file at the path replacing the ones of the same resource.
+// An empty path is no policy, the writes are done one at a time in order.
+func loadWritePolicy(path string) (*writePolicy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	policy := defaultWritePolicy()
+	if path != "default" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var overrides writePolicy
+		err = yaml.UnmarshalStrict(data, &overrides)
+		if err != nil {
+			return nil, fmt.Errorf("write policy %s: %w", path, err)
+		}
+		policy.merge(&overrides)
+	}
+	err := policy.validate()
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (p *writePolicy) merge(overrides *writePolicy) {
+	if overrides.Default.Phase != 0 {
+		p.Default.Phase = overrides.Default.Phase
+	}
+	if overrides.Default.Concurrency != 0 {
+		p.Default.Concurrency = overrides.Default.Concurrency
+	}
+	if overrides.Default.QPS != 0 {
+		p.Default.QPS = overrides.Default.QPS
+	}
+	for _, rule := range overrides.Rules {
+		i := 0
+		for _, r := range p.Rules {
+			if r.Resource != rule.Resource {
+				p.Rules[i] = r
+				i++
+			}
+		}
+		p.Rules = append(p.Rules[:i], rule)
+	}
+}
+
+func (p *writePolicy) validate() error {
+	for i, rule := range append([]writeRule{p.Default}, p.Rules...) {
+		if i != 0 && schema.ParseGroupResource(rule.Resource).Empty() {
+			return fmt.Errorf("invalid resource %q in the write policy", rule.Resource)
+		}
+		if rule.Phase < 0 || rule.Concurrency < 0 || rule.QPS < 0 {
+			return fmt.Errorf("negative phase, concurrency or qps for %q in the write policy", rule.Resource)
+		}
+	}
+	return nil
+}
+
+// rule returns the rule of the resource, with the fields left out taken from the default.
+func (p *writePolicy) rule(gr schema.GroupResource) writeRule {
+	rule := p.Default
+	for _, r := range p.Rules {
+		target := schema.ParseGroupResource(r.Resource)
+		// The short names are taken, while a full name such as events stays the core one rather than the one of events.k8s.io
+		if correctGr, _, found := wellknown.CorrectGroupResource(target); found && correctGr.Resource != target.Resource {
+			target = correctGr
+		}
+		if target != gr {
+			continue
+		}
+		rule.Resource = r.Resource
+		if r.Phase != 0 {
+			rule.Phase = r.Phase
+		}
+		if r.Concurrency != 0 {
+			rule.Concurrency = r.Concurrency
+		}
+		if r.QPS != 0 {
+			rule.QPS = r.QPS
+		}
+	}
+	if rule.Concurrency == 0 {
+		rule.Concurrency = 1
+	}
+	return rule
+}
+
+// phases returns the phases of the policy in order.
+func (p *writePolicy) phases() []int {
+	seen := map[int]struct{}{}
+	for _, r := range append([]writeRule{{}}, p.Rules...) {
+		seen[p.rule(schema.ParseGroupResource(r.Resource)).Phase] = struct{}{}
+	}
+	phases := make([]int, 0, len(seen))
+	for phase := range seen {
+		phases = append(phases, phase)
+	}
+	sort.Ints(phases)
+	return phases
+}
+
+// writeScheduler runs the writes by the policy, in parallel up to the concurrency of each resource.
+type writeScheduler struct {
+	ctx    context.Context
+	policy *writePolicy
+
+	wg    sync.WaitGroup
+	mut   sync.Mutex
+	lanes map[schema.GroupResource]*writeLane
+	err   error
+}
+
+type writeLane struct {
+	slots   chan struct{}
+	limiter *rate.Limiter
+}
+
+func newWriteScheduler(ctx context.Context, policy *writePolicy) *writeScheduler {
+	return &writeScheduler{
+		ctx:    ctx,
+		policy: policy,
+		lanes:  map[schema.GroupResource]*writeLane{},
+	}
+}
+
+func (s *writeScheduler) lane(gr schema.GroupResource) *writeLane {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	l, ok := s.lanes[gr]
+	if !ok {
+		rule := s.policy.rule(gr)
+		l = &writeLane{
+			slots: make(chan struct{}, rule.Concurrency),
+		}
+		if rule.QPS > 0 {
+			l.limiter = rate.NewLimiter(rate.Limit(rule.QPS), 1)
+		}
+		s.lanes[gr] = l
+	}
+	return l
+}
+
+// submit runs the write of the resource once it has a free slot and is within its rate,
+// it returns the error of a write failed before so that no more are submitted.
+func (s *writeScheduler) submit(gr schema.GroupResource, write func() error) error {
+	err := s.failed()
+	if err != nil {
+		return err
+	}
+	l := s.lane(gr)
+	if l.limiter != nil {
+		err = l.limiter.Wait(s.ctx)
+		if err != nil {
+			return err
+		}
+	}
+	select {
+	case l.slots <- struct{}{}:
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-l.slots }()
+		err := write()
+		if err != nil {
+			s.mut.Lock()
+			if s.err == nil {
+				s.err = err
+			}
+			s.mut.Unlock()
+		}
+	}()
+	return nil
+}
+
+func (s *writeScheduler) failed() error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.err
+}
+
+// wait waits for the writes in flight, and returns the error of the first failed one.
+func (s *writeScheduler) wait() error {
+	s.wg.Wait()
+	return s.failed()
+}
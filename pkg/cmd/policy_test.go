@@ -0,0 +1,144 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestLoadWritePolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	err := os.WriteFile(path, []byte(`
+default:
+  concurrency: 8
+rules:
+- resource: pods
+  concurrency: 2
+- resource: leases.coordination.k8s.io
+  phase: 3
+  qps: 10
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := loadWritePolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		gr   schema.GroupResource
+		want writeRule
+	}{
+		{
+			gr:   schema.GroupResource{Resource: "pods"},
+			want: writeRule{Resource: "pods", Phase: 2, Concurrency: 2},
+		},
+		{
+			gr:   schema.GroupResource{Resource: "namespaces"},
+			want: writeRule{Resource: "namespaces", Phase: 1, Concurrency: 8},
+		},
+		{
+			gr:   schema.GroupResource{Resource: "events"},
+			want: writeRule{Resource: "events", Phase: 2, Concurrency: 8, QPS: 50},
+		},
+		{
+			gr:   schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"},
+			want: writeRule{Resource: "leases.coordination.k8s.io", Phase: 3, Concurrency: 8, QPS: 10},
+		},
+		{
+			gr:   schema.GroupResource{Resource: "configmaps"},
+			want: writeRule{Phase: 2, Concurrency: 8},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.gr.String(), func(t *testing.T) {
+			if got := policy.rule(tt.gr); got != tt.want {
+				t.Errorf("rule() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got, want := policy.phases(), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("phases() = %v, want %v", got, want)
+	}
+
+	err = os.WriteFile(path, []byte("rules:\n- resource: pods\n  concurrency: -1\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadWritePolicy(path)
+	if err == nil {
+		t.Errorf("loadWritePolicy() error = nil, want the negative concurrency refused")
+	}
+}
+
+func TestPutCommandWritePolicy(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.yaml")
+	docs := []string{
+		"apiVersion: v1\nkind: Pod\nmetadata:\n  name: a\n  namespace: team\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n  namespace: team\n",
+		"apiVersion: v1\nkind: Namespace\nmetadata:\n  name: team\n",
+		"apiVersion: v1\nkind: Pod\nmetadata:\n  name: c\n  namespace: team\n",
+	}
+	err := os.WriteFile(input, []byte(strings.Join(docs, "---\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	etcdclient := fake.NewClient()
+	err = putCommand(context.Background(), etcdclient, &putFlagpole{
+		Output:      "none",
+		Path:        input,
+		Prefix:      "/registry",
+		DecodeMode:  "lenient",
+		WritePolicy: "default",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revisions := map[string]int64{}
+	_, err = etcdclient.Get(context.Background(), "/registry",
+		client.WithKeysOnly(),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			revisions[string(kv.Key)] = kv.Revision
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != len(docs) {
+		t.Fatalf("put %d keys, want %d: %v", len(revisions), len(docs), revisions)
+	}
+	ns := revisions["/registry/namespaces/team"]
+	for key, rev := range revisions {
+		if key != "/registry/namespaces/team" && rev < ns {
+			t.Errorf("%s is put at revision %d before its namespace at %d", key, rev, ns)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// etcdPodNamespace and etcdPodSelector find the static pods of etcd set up by kubeadm,
+// otherwise the pods are selected by the etcd Service.
+const (
+	etcdPodNamespace = "kube-system"
+	etcdPodSelector  = "component=etcd"
+	etcdServiceName  = "etcd"
+	etcdClientPort   = 2379
+)
+
+// portForwardEtcd forwards a local port to the client port of an etcd pod found through the apiserver of the kubeconfig,
+// and returns the local endpoint. The forwarding lasts until the process exits.
+func portForwardEtcd(ctx context.Context, kubeconfig string) (string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, nil).ClientConfig()
+	if err != nil {
+		return "", err
+	}
+	core, err := corev1client.NewForConfig(restConfig)
+	if err != nil {
+		return "", err
+	}
+
+	pod, err := findEtcdPod(ctx, core)
+	if err != nil {
+		return "", err
+	}
+
+	transport, upgrader, err := spdy.RoundTripperFor(restConfig)
+	if err != nil {
+		return "", err
+	}
+	req := core.RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("portforward")
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, req.URL())
+
+	ready := make(chan struct{})
+	forwarder, err := portforward.NewOnAddresses(dialer,
+		[]string{"127.0.0.1"},
+		[]string{fmt.Sprintf("0:%d", etcdClientPort)},
+		make(chan struct{}), ready, io.Discard, os.Stderr,
+	)
+	if err != nil {
+		return "", err
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- forwarder.ForwardPorts()
+	}()
+	select {
+	case <-ready:
+	case err := <-errCh:
+		return "", fmt.Errorf("port-forward to %s/%s: %w", pod.Namespace, pod.Name, err)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	ports, err := forwarder.GetPorts()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(os.Stderr, "forwarding 127.0.0.1:%d to %s/%s:%d\n", ports[0].Local, pod.Namespace, pod.Name, etcdClientPort)
+	return fmt.Sprintf("127.0.0.1:%d", ports[0].Local), nil
+}
+
+// findEtcdPod returns a running pod of etcd, the static pods are looked for before the pods of the Service.
+func findEtcdPod(ctx context.Context, core corev1client.CoreV1Interface) (*corev1.Pod, error) {
+	selector := etcdPodSelector
+	pod, err := findRunningPod(ctx, core, selector)
+	if err != nil {
+		return nil, err
+	}
+	if pod != nil {
+		return pod, nil
+	}
+
+	svc, err := core.Services(etcdPodNamespace).Get(ctx, etcdServiceName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	} else if len(svc.Spec.Selector) != 0 {
+		selector = labels.SelectorFromSet(svc.Spec.Selector).String()
+		pod, err = findRunningPod(ctx, core, selector)
+		if err != nil {
+			return nil, err
+		}
+		if pod != nil {
+			return pod, nil
+		}
+	}
+	return nil, fmt.Errorf("no running etcd pod in %s with %s", etcdPodNamespace, selector)
+}
+
+func findRunningPod(ctx context.Context, core corev1client.CoreV1Interface, selector string) (*corev1.Pod, error) {
+	pods, err := core.Pods(etcdPodNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			return pod, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFindEtcdPod(t *testing.T) {
+	pod := func(name string, labels map[string]string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system", Labels: labels},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "etcd", Namespace: "kube-system"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "etcd"}},
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "static pod",
+			objects: []runtime.Object{
+				pod("etcd-node0", map[string]string{"component": "etcd"}, corev1.PodPending),
+				pod("etcd-node1", map[string]string{"component": "etcd"}, corev1.PodRunning),
+			},
+			want: "etcd-node1",
+		},
+		{
+			name: "service",
+			objects: []runtime.Object{
+				service,
+				pod("etcd-0", map[string]string{"app": "etcd"}, corev1.PodRunning),
+			},
+			want: "etcd-0",
+		},
+		{
+			name: "not running",
+			objects: []runtime.Object{
+				service,
+				pod("etcd-0", map[string]string{"app": "etcd"}, corev1.PodFailed),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "none",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core := fake.NewSimpleClientset(tt.objects...).CoreV1()
+			got, err := findEtcdPod(context.Background(), core)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findEtcdPod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Name != tt.want {
+				t.Errorf("findEtcdPod() = %v, want %v", got.Name, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/wzshiming/kectl/pkg/client"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// printerOptions are the options of the printer.
+type printerOptions struct {
+	// Output is the output format.
+	Output string
+	// WithRevision prints the revision of each key-value and whether it is a deletion as well.
+	WithRevision bool
+	// DecodeMode is how the values that can not be converted are handled,
+	// in the strict mode they fail the printing instead of being printed raw.
+	DecodeMode decodeMode
+	// OutputVersion is the version the objects are converted to before printing, if not empty,
+	// so that objects stored at different versions of the group can be compared.
+	OutputVersion schema.GroupVersion
+	// Failures counts the values printed raw by resource, if not nil.
+	Failures *decodeFailures
+	// Binary writes the key-values as the records of a binary recording, with the values as stored, rather than in the output format.
+	Binary bool
+}
+
+// newPrinter returns a response callback that prints the key-values.
+func newPrinter(w io.Writer, opts printerOptions) (func(kv *client.KeyValue) error, error) {
+	suffix := func(kv *client.KeyValue) string {
+		if !opts.WithRevision {
+			// The deletions of a watch carry the object as it was before them, they are told apart from the writes of it
+			if len(kv.Value) == 0 && len(kv.PrevValue) != 0 {
+				return " | deleted"
+			}
+			return ""
+		}
+		if len(kv.Value) == 0 {
+			return fmt.Sprintf(" | %d | deleted", kv.Revision)
+		}
+		return fmt.Sprintf(" | %d", kv.Revision)
+	}
+
+	if opts.Binary {
+		return newRecordingBinaryPrinter(w), nil
+	}
+
+	if !opts.OutputVersion.Empty() && opts.Output != "json" && opts.Output != "yaml" && opts.Output != "jsonl" {
+		return nil, fmt.Errorf("output version is only supported by the json, yaml and jsonl output formats")
+	}
+
+	switch opts.Output {
+	case "json", "yaml":
+		outMediaType := encoding.JsonMediaType
+		if opts.Output == "yaml" {
+			outMediaType = encoding.YamlMediaType
+		}
+		return func(kv *client.KeyValue) error {
+			value := kv.Value
+			if value == nil {
+				value = kv.PrevValue
+			}
+			// Deletions come without the previous value from the backends that do not keep it, such as kine
+			if len(value) == 0 {
+				if opts.WithRevision {
+					fmt.Fprintf(w, "---\n# %s%s\n", kv.Key, suffix(kv))
+				} else {
+					fmt.Fprintf(w, "---\n# %s | deleted\n", kv.Key)
+				}
+				return nil
+			}
+			buf := getBuffer()
+			defer putBuffer(buf)
+
+			var inMediaType string
+			var err error
+			if opts.OutputVersion.Empty() {
+				inMediaType, err = convertValueTo(buf, value, outMediaType)
+			} else {
+				inMediaType, err = convertValueToVersion(buf, value, outMediaType, opts.OutputVersion)
+			}
+			if err != nil {
+				if opts.DecodeMode == decodeModeStrict {
+					return fmt.Errorf("%s: %w", kv.Key, err)
+				}
+				opts.Failures.add(string(kv.Key), err)
+				fmt.Fprintf(w, "---\n# %s | raw | %v%s\n# %s\n", kv.Key, err, suffix(kv), value)
+				return nil
+			}
+			// The value is written on its own rather than formatted, to not copy it once more
+			fmt.Fprintf(w, "---\n# %s | %s%s\n", kv.Key, inMediaType, suffix(kv))
+			buf.WriteByte('\n')
+			_, err = buf.WriteTo(w)
+			return err
+		}, nil
+	case "jsonl":
+		return func(kv *client.KeyValue) error {
+			line := &jsonLine{
+				Key:      string(kv.Key),
+				Revision: kv.Revision,
+				Deleted:  len(kv.Value) == 0,
+			}
+			value := kv.Value
+			if value == nil {
+				value = kv.PrevValue
+			}
+			if len(value) == 0 {
+				return writeJSONLine(w, line)
+			}
+			buf := getBuffer()
+			defer putBuffer(buf)
+
+			var err error
+			if opts.OutputVersion.Empty() {
+				line.MediaType, err = convertValueTo(buf, value, encoding.JsonMediaType)
+			} else {
+				line.MediaType, err = convertValueToVersion(buf, value, encoding.JsonMediaType, opts.OutputVersion)
+			}
+			if err != nil {
+				if opts.DecodeMode == decodeModeStrict {
+					return fmt.Errorf("%s: %w", kv.Key, err)
+				}
+				opts.Failures.add(string(kv.Key), err)
+				line.MediaType = ""
+				line.Raw = value
+				line.Error = err.Error()
+				return writeJSONLine(w, line)
+			}
+			// The object is compacted for the line not to be broken
+			object := getBuffer()
+			defer putBuffer(object)
+			err = json.Compact(object, buf.Bytes())
+			if err != nil {
+				return fmt.Errorf("%s: %w", kv.Key, err)
+			}
+			line.Object = object.Bytes()
+			return writeJSONLine(w, line)
+		}, nil
+	case "raw":
+		return func(kv *client.KeyValue) error {
+			fmt.Fprintf(w, "%s%s\n%s\n", kv.Key, suffix(kv), kv.Value)
+			return nil
+		}, nil
+	case "key":
+		return func(kv *client.KeyValue) error {
+			fmt.Fprintf(w, "%s%s\n", kv.Key, suffix(kv))
+			return nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported output format: %s", opts.Output)
+	}
+}
+
+// printBookmark prints a bookmark of the revision up to which the output is complete, at the time,
+// as a comment, so that it is skipped by the readers of the output format.
+func printBookmark(w io.Writer, output string, revision int64, t time.Time) error {
+	var err error
+	switch output {
+	case "json", "yaml":
+		_, err = fmt.Fprintf(w, "---\n# bookmark | %d | %s\n", revision, t.UTC().Format(time.RFC3339))
+	case "jsonl":
+		t = t.UTC().Truncate(time.Second)
+		err = writeJSONLine(w, &jsonLine{Revision: revision, Bookmark: &t})
+	default:
+		_, err = fmt.Fprintf(w, "# bookmark | %d | %s\n", revision, t.UTC().Format(time.RFC3339))
+	}
+	return err
+}
+
+// gapHeaderPrefix is the start of the comment of a gap of the revisions compacted away from a watch.
+const gapHeaderPrefix = "# gap | "
+
+// printGap prints the revisions a watch misses for them being compacted, as a comment like a bookmark,
+// so that the recording tells where it is incomplete.
+func printGap(w io.Writer, output string, gap client.Gap) error {
+	var err error
+	switch output {
+	case "json", "yaml":
+		_, err = fmt.Fprintf(w, "---\n%s%d | %d\n", gapHeaderPrefix, gap.From, gap.To)
+	case "jsonl":
+		err = writeJSONLine(w, &jsonLine{Gap: &gap})
+	default:
+		_, err = fmt.Fprintf(w, "%s%d | %d\n", gapHeaderPrefix, gap.From, gap.To)
+	}
+	return err
+}
+
+// parseGapHeader parses the comment of a gap printed by printGap.
+func parseGapHeader(line string) (client.Gap, bool) {
+	rest, ok := strings.CutPrefix(line, gapHeaderPrefix)
+	if !ok {
+		return client.Gap{}, false
+	}
+	from, to, _ := strings.Cut(rest, " | ")
+	var gap client.Gap
+	var err error
+	gap.From, err = strconv.ParseInt(from, 10, 64)
+	if err != nil {
+		return client.Gap{}, false
+	}
+	gap.To, err = strconv.ParseInt(to, 10, 64)
+	if err != nil {
+		return client.Gap{}, false
+	}
+	return gap, true
+}
@@ -17,24 +17,29 @@ limitations under the License.
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/etcd-io/auger/pkg/encoding"
 	"github.com/spf13/cobra"
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
 	"github.com/wzshiming/kectl/pkg/client"
-	"github.com/wzshiming/kectl/pkg/scheme"
 	"github.com/wzshiming/kectl/pkg/wellknown"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/util/yaml"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
 )
 
 type putFlagpole struct {
@@ -43,6 +48,23 @@ type putFlagpole struct {
 	Path         string
 	Prefix       string
 	AllNamespace bool
+	DecodeMode   string
+	Freeze       []string
+	SkippedPath  string
+	MaxErrors    int
+	FailFast     bool
+
+	CheckpointFile string
+	WritePolicy    string
+	BatchSize      int
+
+	OnlyDeletes    bool
+	ApplyDeletions bool
+
+	ReplaySpeed     float64
+	NamespaceSpeeds []string
+
+	MaxClockSkew time.Duration
 }
 
 func newCtlPutCommand() *cobra.Command {
@@ -57,6 +79,10 @@ func newCtlPutCommand() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			err = checkLiveControlPlane(cmd.Context(), cmd, etcdclient, flags.Prefix)
+			if err != nil {
+				return err
+			}
 			err = putCommand(cmd.Context(), etcdclient, flags, args)
 
 			if err != nil {
@@ -69,24 +95,74 @@ func newCtlPutCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&flags.Output, "output", "o", "key", "output format. One of: (key, none).")
 	cmd.Flags().StringVarP(&flags.Namespace, "namespace", "n", "", "namespace of resource")
 	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
-	cmd.Flags().StringVar(&flags.Path, "path", "", "path of the file")
+	cmd.Flags().StringVar(&flags.Path, "path", "", "path of the file, or of an object such as s3://bucket/key, gcs://bucket/key or azblob://container/key")
 	cmd.Flags().BoolVarP(&flags.AllNamespace, "all-namespace", "A", false, "all namespace")
+	cmd.Flags().StringVar(&flags.DecodeMode, "decode-mode", "lenient", "how values not matching the scheme are handled. One of: (lenient, strict).")
+	cmd.Flags().StringSliceVar(&flags.Freeze, "freeze", nil, "objects never to be written, in the form of resource/namespace/name or resource/name.")
+	cmd.Flags().StringVar(&flags.SkippedPath, "skipped-path", "", "path of the file to write the skipped documents to with the reasons, to be put again later.")
+	cmd.Flags().IntVar(&flags.MaxErrors, "max-errors", 0, "number of documents failing to be put tolerated before aborting. 0 for no limit.")
+	cmd.Flags().BoolVar(&flags.FailFast, "fail-fast", false, "abort on the first document failing to be put.")
+	cmd.Flags().StringVar(&flags.WritePolicy, "write-policy", "", "order, parallelism and rate of the writes by resource, 'default' for the built-in policy or the path of a YAML file overriding it. Empty to write one at a time in order.")
+	cmd.Flags().IntVar(&flags.BatchSize, "batch-size", 0, "number of documents put in a single transaction, at most the --max-txn-ops of etcd, 128 by default. 0 to put them one at a time.")
+	cmd.Flags().BoolVar(&flags.ApplyDeletions, "apply-deletions", false, "delete the keys of the deletions of a recorded watch, the documents marked deleted in the yaml and jsonl outputs, rather than skipping them.")
+	cmd.Flags().BoolVar(&flags.OnlyDeletes, "only-deletes", false, "only delete, the objects of the input and the ones created or deleted by the changes of a kwok recording, to tear down what putting it wrote.")
+	cmd.Flags().StringVar(&flags.CheckpointFile, "checkpoint-file", "", "path of the file to save the progress to, for an interrupted put to resume from it. It is removed once the put completes.")
+	cmd.Flags().Float64Var(&flags.ReplaySpeed, "replay-speed", 0, "speed at which the durations of the changes of a kwok recording are waited for, 1 for as recorded and 10 for ten times faster. 0 to apply them as they are read.")
+	cmd.Flags().StringSliceVar(&flags.NamespaceSpeeds, "namespace-speed", nil, "speed of the changes of a namespace, in the form of namespace=speed, on a clock of its own not waiting for the other namespaces. The other namespaces are at --replay-speed.")
+	cmd.Flags().DurationVar(&flags.MaxClockSkew, "max-clock-skew", 2*time.Second, "clock skew the input may have been recorded with before a warning, the times it recorded being off the ones of the objects by as much. 0 not to check.")
 
 	return cmd
 }
 
 func putCommand(ctx context.Context, etcdclient client.Client, flags *putFlagpole, args []string) error {
-	var reader io.Reader
-	var err error
-	switch flags.Path {
-	case "-":
-		reader = os.Stdin
+	mode, err := parseDecodeMode(flags.DecodeMode)
+	if err != nil {
+		return err
+	}
+	frozen, err := parseFrozenObjects(flags.Freeze)
+	if err != nil {
+		return err
+	}
+
+	policy, err := loadWritePolicy(flags.WritePolicy)
+	if err != nil {
+		return err
+	}
+	if policy != nil && flags.CheckpointFile != "" {
+		return fmt.Errorf("--write-policy and --checkpoint-file can not be used together, the checkpoint follows the order of the input")
+	}
+	if flags.BatchSize < 0 {
+		return fmt.Errorf("invalid batch size %d", flags.BatchSize)
+	}
+	if flags.BatchSize > 1 && (policy != nil || flags.CheckpointFile != "") {
+		return fmt.Errorf("--batch-size can not be used with --write-policy or --checkpoint-file, which follow each write")
+	}
+	if flags.OnlyDeletes && (policy != nil || flags.BatchSize > 1) {
+		return fmt.Errorf("--only-deletes can not be used with --write-policy or --batch-size")
+	}
+	if flags.OnlyDeletes && flags.ApplyDeletions {
+		return fmt.Errorf("--only-deletes and --apply-deletions can not be used together, tearing down leaves out the recorded changes")
+	}
+	clocks, err := newReplayClocks(ctx, flags.ReplaySpeed, flags.NamespaceSpeeds)
+	if err != nil {
+		return err
+	}
+	if clocks != nil && (policy != nil || flags.BatchSize > 1 || flags.CheckpointFile != "" || flags.OnlyDeletes) {
+		return fmt.Errorf("--replay-speed and --namespace-speed can not be used with --write-policy, --batch-size, --checkpoint-file or --only-deletes")
+	}
+
+	inputPath := flags.Path
+	switch inputPath {
 	case "":
 		return fmt.Errorf("path is required")
-	default:
-		reader, err = os.Open(flags.Path)
-		if err != nil {
-			return err
+	case "-":
+		if policy != nil {
+			// The input is read once by phase
+			inputPath, err = spoolStdin()
+			if err != nil {
+				return err
+			}
+			defer os.Remove(inputPath)
 		}
 	}
 
@@ -117,6 +193,56 @@ func putCommand(ctx context.Context, etcdclient client.Client, flags *putFlagpol
 		}
 	}
 
+	skipped := newSkippedDocuments(flags.SkippedPath)
+	defer skipped.Close()
+
+	var cp *checkpointer
+	if flags.CheckpointFile != "" {
+		target := "put " + flags.Path
+		if len(args) != 0 {
+			target += " " + strings.Join(args, " ")
+		}
+		if wantNamespace != "" {
+			target += " -n " + wantNamespace
+		}
+		cp, err = loadCheckpoint(flags.CheckpointFile, target)
+		if err != nil {
+			return err
+		}
+		if cp.resumed() {
+			fmt.Fprintf(os.Stderr, "resume after %d documents, verifying them against the input\n", cp.state.Count)
+			// The documents skipped before the interruption are kept
+			skipped.appending = true
+		}
+	}
+
+	// mut guards the skipped documents, the error budget and the output against the writes in parallel
+	var mut sync.Mutex
+	skip := func(obj *unstructured.Unstructured, reason string) error {
+		mut.Lock()
+		defer mut.Unlock()
+		return skipped.add(obj, reason)
+	}
+
+	budget := newErrorBudget(flags.MaxErrors, flags.FailFast)
+	// fail counts the error of the document, which is kept with the skipped ones to be put again
+	fail := func(obj *unstructured.Unstructured, class string, err error) error {
+		mut.Lock()
+		defer mut.Unlock()
+		err = fmt.Errorf("%s %s: %w", obj.GroupVersionKind().Kind, path.Join(obj.GetNamespace(), obj.GetName()), err)
+		skipErr := skipped.add(obj, class+": "+err.Error())
+		if skipErr != nil {
+			return skipErr
+		}
+		return budget.add(class, err)
+	}
+	// failDelete counts the error of the deletion of the key, which has no document to be kept with the skipped ones
+	failDelete := func(key string, class string, err error) error {
+		mut.Lock()
+		defer mut.Unlock()
+		return budget.add(class, fmt.Errorf("delete %s: %w", key, err))
+	}
+
 	start := time.Now()
 
 	var count int
@@ -124,6 +250,8 @@ func putCommand(ctx context.Context, etcdclient client.Client, flags *putFlagpol
 	if flags.Output == "key" {
 		//nolint:unparam
 		response = func(kv *client.KeyValue) error {
+			mut.Lock()
+			defer mut.Unlock()
 			count++
 			if kv != nil {
 				fmt.Fprintf(os.Stdout, "%s\n", kv.Key)
@@ -132,35 +260,59 @@ func putCommand(ctx context.Context, etcdclient client.Client, flags *putFlagpol
 		}
 	}
 
-	err = decodeToUnstructured(reader, func(obj *unstructured.Unstructured) error {
-		targetName := obj.GetName()
-		if targetName == "" {
-			// There will be some unnamed hidden resources, which we should also ignore.
-			return nil
+	var scheduler *writeScheduler
+	var phase int
+	if policy != nil {
+		scheduler = newWriteScheduler(ctx, policy)
+	}
+
+	var batch *client.Batch
+	if flags.BatchSize > 1 {
+		batch = client.NewBatch(etcdclient, flags.Prefix, flags.BatchSize)
+	}
+
+	// filterReason returns why the object is not written, if it is not
+	filterReason := func(targetGr schema.GroupResource, targetNamespace, targetName string) string {
+		if targetNamespace != "" && wantNamespace != "" && targetNamespace != wantNamespace {
+			return "filtered out by namespace"
 		}
 
+		if wantGr != nil && *wantGr != targetGr {
+			return "filtered out by resource"
+		}
+
+		if wantName != "" && wantName != targetName {
+			return "filtered out by name"
+		}
+
+		if frozen.has(targetGr, targetNamespace, targetName) {
+			fmt.Fprintf(os.Stderr, "skip frozen %s/%s\n", targetGr, path.Join(targetNamespace, targetName))
+			return "frozen"
+		}
+		return ""
+	}
+
+	visit := func(obj *unstructured.Unstructured) error {
 		// TODO: Use a safe way to convert GVK to GVR
 		//       Verify that all built-in resources conform to this rule
 		//       For custom resources try to get information from the CRD
 		targetGvr, _ := meta.UnsafeGuessKindToResource(obj.GroupVersionKind())
 
 		targetGr := targetGvr.GroupResource()
-		targetNamespace := obj.GetNamespace()
-
-		if targetNamespace != "" && wantNamespace != "" && targetNamespace != wantNamespace {
+		if policy != nil && policy.rule(targetGr).Phase != phase {
 			return nil
 		}
 
-		if wantGr != nil && *wantGr != targetGr {
-			return nil
+		targetName := obj.GetName()
+		if targetName == "" {
+			// There will be some unnamed hidden resources, which we should also ignore.
+			return skip(obj, "unnamed")
 		}
 
-		if wantName != "" && wantName != targetName {
-			return nil
-		}
+		targetNamespace := obj.GetNamespace()
 
-		if targetName == "" {
-			return nil
+		if reason := filterReason(targetGr, targetNamespace, targetName); reason != "" {
+			return skip(obj, reason)
 		}
 
 		mediaType, err := client.MediaTypeFromGR(targetGr)
@@ -178,12 +330,19 @@ func putCommand(ctx context.Context, etcdclient client.Client, flags *putFlagpol
 
 		data, err := obj.MarshalJSON()
 		if err != nil {
-			return err
+			return fail(obj, errorClassDecode, err)
+		}
+
+		if mode == decodeModeStrict {
+			err = checkStrict(data, mediaType)
+			if err != nil {
+				return fail(obj, errorClassDecode, err)
+			}
 		}
 
-		data, _, err = encoding.Convert(scheme.Codecs, encoding.JsonMediaType, mediaType, data)
+		data, err = convertToStorage(data, mediaType)
 		if err != nil {
-			return err
+			return fail(obj, errorClassDecode, err)
 		}
 
 		opOpts := []client.OpOption{
@@ -198,56 +357,415 @@ func putCommand(ctx context.Context, etcdclient client.Client, flags *putFlagpol
 			)
 		}
 
-		err = etcdclient.Put(ctx, flags.Prefix, data,
-			opOpts...,
-		)
+		write := func() error {
+			err := etcdclient.Put(ctx, flags.Prefix, data,
+				opOpts...,
+			)
+			if err != nil {
+				return fail(obj, classifyWriteError(err), err)
+			}
+			return nil
+		}
+		if scheduler != nil {
+			return scheduler.submit(targetGr, write)
+		}
+		if batch != nil {
+			return batch.Put(ctx, data, func(err error) error {
+				if err != nil {
+					return fail(obj, classifyWriteError(err), err)
+				}
+				return nil
+			}, opOpts...)
+		}
+		return write()
+	}
+
+	// The changes of the recordings of kwokctl are applied to the objects in the order they were recorded
+	var deleted int
+	putObject := visit
+	visit = func(obj *unstructured.Unstructured) error {
+		if !isKwokResourcePatch(obj) {
+			return putObject(obj)
+		}
+		if policy != nil {
+			return fmt.Errorf("the changes of a kwok recording are applied in order, --write-policy is not supported")
+		}
+		rp, err := decodeKwokResourcePatch(obj)
+		if err != nil {
+			return fail(obj, errorClassDecode, err)
+		}
+		if rp.Checkpoint {
+			// The checkpoints change nothing of the objects put by the changes before them
+			return nil
+		}
+		gr := rp.Resource.GroupResource()
+		target := path.Join(gr.String(), rp.Target.Namespace, rp.Target.Name)
+		if batch != nil && rp.Method != actionv1alpha1.PatchMethodCreate {
+			// The changes apply to the objects put before them
+			err = batch.Flush(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		apply := func() error {
+			switch rp.Method {
+			case actionv1alpha1.PatchMethodCreate:
+				created := &unstructured.Unstructured{}
+				err := created.UnmarshalJSON(rp.Template)
+				if err != nil {
+					return fail(obj, errorClassDecode, fmt.Errorf("create %s: %w", target, err))
+				}
+				return putObject(created)
+			case actionv1alpha1.PatchMethodPatch:
+				original, err := getObjectJSON(ctx, etcdclient, flags.Prefix, gr, rp.Target.Name, rp.Target.Namespace)
+				if err != nil {
+					return fail(obj, classifyWriteError(err), fmt.Errorf("patch %s: %w", target, err))
+				}
+				if original == nil {
+					return fail(obj, errorClassOther, fmt.Errorf("patch %s: not found", target))
+				}
+				patched, err := applyStrategicPatch(original, rp.Template)
+				if err != nil {
+					return fail(obj, errorClassDecode, fmt.Errorf("patch %s: %w", target, err))
+				}
+				return putObject(patched)
+			default:
+				if reason := filterReason(gr, rp.Target.Namespace, rp.Target.Name); reason != "" {
+					return skip(obj, reason)
+				}
+				err := etcdclient.Delete(ctx, flags.Prefix,
+					client.WithName(rp.Target.Name, rp.Target.Namespace),
+					client.WithGR(gr),
+				)
+				if err != nil {
+					return fail(obj, classifyWriteError(err), fmt.Errorf("delete %s: %w", target, err))
+				}
+				mut.Lock()
+				deleted++
+				mut.Unlock()
+				return nil
+			}
+		}
+		if clocks != nil {
+			return clocks.submit(rp.Target.Namespace, rp.DurationNanosecond, apply)
+		}
+		return apply()
+	}
+
+	// With --apply-deletions the deletions recorded by a watch delete the objects of their keys, which the documents before them may have put
+	var remove func(key string) error
+	if flags.ApplyDeletions {
+		remove = func(key string) error {
+			gr, rest, ok := groupResourceFromKey(flags.Prefix, key)
+			if !ok {
+				return failDelete(key, errorClassDecode, fmt.Errorf("not under the prefix %q", flags.Prefix))
+			}
+			namespace, name, err := splitName(rest)
+			if err != nil {
+				return failDelete(key, errorClassDecode, err)
+			}
+			if policy != nil && policy.rule(gr).Phase != phase {
+				return nil
+			}
+			// The deletions are not put again later, they are left out of the skipped documents
+			if reason := filterReason(gr, namespace, name); reason != "" {
+				return nil
+			}
+			// The deletion follows the writes read before it
+			if scheduler != nil {
+				err = scheduler.wait()
+				if err != nil {
+					return err
+				}
+			}
+			if batch != nil {
+				err = batch.Flush(ctx)
+				if err != nil {
+					return err
+				}
+			}
+			err = etcdclient.Delete(ctx, flags.Prefix,
+				client.WithName(name, namespace),
+				client.WithGR(gr),
+			)
+			if err != nil {
+				return failDelete(key, classifyWriteError(err), err)
+			}
+			mut.Lock()
+			deleted++
+			mut.Unlock()
+			return nil
+		}
+	}
+
+	// Tearing down deletes the objects putting the input would have written, the other changes are left out
+	if flags.OnlyDeletes {
+		visit = func(obj *unstructured.Unstructured) error {
+			gr, namespace, name, ok, err := teardownTarget(obj)
+			if err != nil {
+				return fail(obj, errorClassDecode, err)
+			}
+			if !ok {
+				return nil
+			}
+			if reason := filterReason(gr, namespace, name); reason != "" {
+				return skip(obj, reason)
+			}
+			opOpts := []client.OpOption{
+				client.WithName(name, namespace),
+				client.WithGR(gr),
+			}
+			if response != nil {
+				opOpts = append(opOpts,
+					client.WithResponse(response),
+					client.WithKeysOnly(),
+				)
+			}
+			err = etcdclient.Delete(ctx, flags.Prefix, opOpts...)
+			if err != nil {
+				return fail(obj, classifyWriteError(err), fmt.Errorf("delete: %w", err))
+			}
+			return nil
+		}
+	}
+
+	if cp != nil && remove != nil {
+		del := remove
+		remove = func(key string) error {
+			data := []byte("deleted " + key)
+			transferred, err := cp.verify(data)
+			if err != nil || transferred {
+				return err
+			}
+			err = del(key)
+			if err != nil {
+				return err
+			}
+			_, _ = cp.Write(data)
+			return cp.commit(key)
+		}
+	}
+
+	if cp != nil {
+		put := visit
+		visit = func(obj *unstructured.Unstructured) error {
+			// The document is digested as it is read, before it is changed to be put
+			data, err := json.Marshal(obj.Object)
+			if err != nil {
+				return err
+			}
+			transferred, err := cp.verify(data)
+			if err != nil || transferred {
+				return err
+			}
+			err = put(obj)
+			if err != nil {
+				return err
+			}
+			_, _ = cp.Write(data)
+			return cp.commit(path.Join(obj.GetKind(), obj.GetNamespace(), obj.GetName()))
+		}
+	}
+
+	// The input is read once by phase, the clock skew it declares is warned about once
+	var warned bool
+	warnClockSkew := func(skew clockSkew) {
+		if warned || !skew.exceeds(flags.MaxClockSkew) {
+			return
+		}
+		warned = true
+		fmt.Fprintf(os.Stderr, "warning: the input was recorded with a clock %s off the one of the server at %s, beyond --max-clock-skew of %s, its times are off the ones of the objects by as much\n",
+			skew, skew.Time.Format(time.RFC3339), flags.MaxClockSkew)
+	}
+
+	if policy != nil {
+		err = putByPhase(inputPath, policy, scheduler, &phase, warnClockSkew, visit, remove)
+	} else {
+		err = decodeRecording(inputPath, warnClockSkew, visit, remove)
+		if err == nil && batch != nil {
+			err = batch.Flush(ctx)
+		}
+		if clocks != nil {
+			err = errors.Join(err, clocks.wait(err))
+		}
+	}
+	if cp != nil {
+		if err != nil {
+			// Keep what has been put so far for the next attempt
+			return errors.Join(err, cp.flush())
+		}
+		err = cp.verifiedAll()
 		if err != nil {
 			return err
 		}
-		return nil
-	})
+		err = cp.done()
+	}
 	if err != nil {
 		return err
 	}
 
-	if flags.Output == "key" {
+	if flags.Output == "key" && flags.OnlyDeletes {
+		fmt.Fprintf(os.Stderr, "delete %d keys\n", count)
+	} else if flags.Output == "key" {
 		fmt.Fprintf(os.Stderr, "put %d keys\n", count)
+		if deleted != 0 {
+			fmt.Fprintf(os.Stderr, "delete %d keys by the deletions of the input\n", deleted)
+		}
+	}
+	return budget.summary()
+}
+
+// teardownTarget returns the object deleted to tear down the document: the object itself,
+// or the target of a create or a delete of a kwok recording. It is not ok for the patches, which leave nothing of their own.
+func teardownTarget(obj *unstructured.Unstructured) (gr schema.GroupResource, namespace, name string, ok bool, err error) {
+	if !isKwokResourcePatch(obj) {
+		if obj.GetName() == "" {
+			return gr, "", "", false, nil
+		}
+		gvr, _ := meta.UnsafeGuessKindToResource(obj.GroupVersionKind())
+		return gvr.GroupResource(), obj.GetNamespace(), obj.GetName(), true, nil
+	}
+	rp, err := decodeKwokResourcePatch(obj)
+	if err != nil {
+		return gr, "", "", false, err
+	}
+	if rp.Method == actionv1alpha1.PatchMethodPatch {
+		return gr, "", "", false, nil
+	}
+	return rp.Resource.GroupResource(), rp.Target.Namespace, rp.Target.Name, true, nil
+}
+
+// putByPhase reads the input once by phase of the policy, waiting for the writes of a phase before the next one.
+func putByPhase(inputPath string, policy *writePolicy, scheduler *writeScheduler, phase *int, skewFunc func(skew clockSkew), visitFunc func(obj *unstructured.Unstructured) error, deleteFunc func(key string) error) error {
+	for _, p := range policy.phases() {
+		*phase = p
+		err := decodeRecording(inputPath, skewFunc, visitFunc, deleteFunc)
+		if err != nil {
+			return errors.Join(err, scheduler.wait())
+		}
+		err = scheduler.wait()
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// decodeFile decodes the documents of the file, or of stdin if the path is -.
+func decodeFile(path string, visitFunc func(obj *unstructured.Unstructured) error) error {
+	return decodeRecording(path, nil, visitFunc, nil)
+}
+
+// decodeRecording is decodeFile, calling the skewFunc with the clock skew the recording declares before its documents, if it does,
+// and the deleteFunc with the key of each deletion it recorded, if it is not nil.
+func decodeRecording(path string, skewFunc func(skew clockSkew), visitFunc func(obj *unstructured.Unstructured) error, deleteFunc func(key string) error) error {
+	if path == "-" {
+		r, err := decompress(os.Stdin)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return decodeRecordingReader(r, skewFunc, visitFunc, deleteFunc)
+	}
+	f, err := openRecording(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return decodeRecordingReader(f, skewFunc, visitFunc, deleteFunc)
+}
+
+// spoolStdin copies stdin to a temporary file to be read more than once, and returns its path.
+func spoolStdin() (string, error) {
+	f, err := os.CreateTemp("", "kectl-put-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, os.Stdin)
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
 func decodeToUnstructured(reader io.Reader, visitFunc func(obj *unstructured.Unstructured) error) error {
-	d := yaml.NewYAMLToJSONDecoder(reader)
+	return decodeReader(reader, nil, visitFunc)
+}
+
+func decodeReader(reader io.Reader, skewFunc func(skew clockSkew), visitFunc func(obj *unstructured.Unstructured) error) error {
+	return decodeRecordingReader(reader, skewFunc, visitFunc, nil)
+}
+
+// decodeRecordingReader is decodeReader, calling the deleteFunc with the key of each deletion of the recording if it is not nil.
+func decodeRecordingReader(reader io.Reader, skewFunc func(skew clockSkew), visitFunc func(obj *unstructured.Unstructured) error, deleteFunc func(key string) error) error {
+	br := bufio.NewReader(reader)
+	if skewFunc != nil {
+		if skew, ok := peekClockSkew(br); ok {
+			skewFunc(skew)
+		}
+	}
+	if isJSONStream(br) {
+		return decodeJSONStream(br, visitFunc, deleteFunc)
+	}
+	// The documents are read with their comments, the headers of the outputs of kectl
+	d := utilyaml.NewYAMLReader(br)
 
 	for {
-		obj := &unstructured.Unstructured{}
-		err := d.Decode(&obj)
+		doc, err := d.Read()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				return nil
 			}
 			return err
 		}
-
-		if obj.IsList() {
-			err = obj.EachListItem(func(object runtime.Object) error {
-				obj := object.(*unstructured.Unstructured)
-				if len(obj.Object) == 0 {
-					return nil
+		// The deletions of a watch carry the object as it was before them, which is not put, as of the jsonl output
+		if isDeletedDocument(doc) {
+			if deleteFunc != nil {
+				err = deleteFunc(deletedDocumentKey(doc))
+				if err != nil {
+					return err
 				}
-				return visitFunc(object.(*unstructured.Unstructured))
-			})
-			if err != nil {
-				return err
 			}
-		} else {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		err = yaml.Unmarshal(doc, &obj)
+		if err != nil {
+			return err
+		}
+
+		err = visitUnstructured(obj, visitFunc)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// visitUnstructured visits the object, or each item of a list.
+func visitUnstructured(obj *unstructured.Unstructured, visitFunc func(obj *unstructured.Unstructured) error) error {
+	// Empty documents are decoded as null
+	if obj == nil {
+		return nil
+	}
+
+	if obj.IsList() {
+		return obj.EachListItem(func(object runtime.Object) error {
+			obj := object.(*unstructured.Unstructured)
 			if len(obj.Object) == 0 {
-				continue
-			}
-			err = visitFunc(obj)
-			if err != nil {
-				return err
+				return nil
 			}
-		}
+			return visitFunc(object.(*unstructured.Unstructured))
+		})
+	}
+	if len(obj.Object) == 0 {
+		return nil
+	}
+	// The Recording document a kwok recording starts with tells the version of the others, and is not an object
+	if isKwokRecording(obj) {
+		return checkKwokVersion(obj)
 	}
+	return visitFunc(obj)
 }
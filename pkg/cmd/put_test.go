@@ -0,0 +1,296 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func FuzzDecodeToUnstructured(f *testing.F) {
+	f.Add([]byte(podJSON))
+	f.Add([]byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: pod\n---\napiVersion: v1\nkind: List\nitems:\n- apiVersion: v1\n  kind: Pod\n  metadata:\n    name: pod\n"))
+	f.Add([]byte("---\n---\n"))
+	f.Add([]byte("{\"key\":\"/registry/pods/default/pod\",\"object\":" + podJSON + "}\n{\"revision\":1,\"bookmark\":\"2024-01-01T00:00:00Z\"}\n"))
+	f.Add([]byte("apiVersion: v1\nkind: List\nitems: [1]\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_ = decodeToUnstructured(bytes.NewReader(data), func(obj *unstructured.Unstructured) error {
+			_ = obj.GetName()
+			return nil
+		})
+	})
+}
+
+func TestPutCommandSkippedPath(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.yaml")
+	skippedPath := filepath.Join(dir, "skipped.yaml")
+	err := os.WriteFile(input, []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+  namespace: default
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+  namespace: other
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: c
+  namespace: default
+unknownField: 1
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	etcdclient := fake.NewClient()
+	err = putCommand(context.Background(), etcdclient, &putFlagpole{
+		Namespace:   "default",
+		Output:      "none",
+		Path:        input,
+		Prefix:      "/registry",
+		DecodeMode:  "strict",
+		SkippedPath: skippedPath,
+	}, []string{"configmaps"})
+	// The document refused by the strict mode is an error, the rest is still put
+	if err == nil || err.Error() != "1 errors: decode 1" {
+		t.Fatalf("putCommand() error = %v, want the decode error counted", err)
+	}
+
+	if got := etcdclient.Revision(); got != 2 {
+		t.Errorf("revision = %d, want 2 for a single put", got)
+	}
+
+	data, err := os.ReadFile(skippedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := string(data)
+	for _, want := range []string{
+		"# skipped: filtered out by namespace\n",
+		"  name: b\n",
+		`unknown field "unknownField"`,
+		"  name: c\n",
+	} {
+		if !strings.Contains(skipped, want) {
+			t.Errorf("skipped documents do not contain %q:\n%s", want, skipped)
+		}
+	}
+	if strings.Contains(skipped, "name: a\n") {
+		t.Errorf("skipped documents contain the put one:\n%s", skipped)
+	}
+}
+
+func TestPutCommandMaxErrors(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.yaml")
+	var docs []string
+	for _, name := range []string{"a", "b", "c", "d"} {
+		docs = append(docs, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: "+name+"\n  namespace: default\nunknownField: 1\n")
+	}
+	err := os.WriteFile(input, []byte(strings.Join(docs, "---\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		maxErrors int
+		failFast  bool
+		wantErr   string
+	}{
+		{
+			name:    "unlimited",
+			wantErr: "4 errors: decode 4",
+		},
+		{
+			name:      "max errors",
+			maxErrors: 2,
+			wantErr:   `more than 2 errors: ConfigMap default/c: strict decoding error: unknown field "unknownField"`,
+		},
+		{
+			name:     "fail fast",
+			failFast: true,
+			wantErr:  `ConfigMap default/a: strict decoding error: unknown field "unknownField"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := putCommand(context.Background(), fake.NewClient(), &putFlagpole{
+				Output:     "none",
+				Path:       input,
+				Prefix:     "/registry",
+				DecodeMode: "strict",
+				MaxErrors:  tt.maxErrors,
+				FailFast:   tt.failFast,
+			}, nil)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("putCommand() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPutCommandDecodeMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		decodeMode string
+		wantErr    string
+	}{
+		{
+			name:       "known fields",
+			input:      "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: default\ndata:\n  key: a0\n",
+			decodeMode: "strict",
+		},
+		{
+			name:       "unknown field",
+			input:      "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: default\nunknownField: 1\n",
+			decodeMode: "strict",
+			wantErr:    `ConfigMap default/a: strict decoding error: unknown field "unknownField"`,
+		},
+		{
+			// The field matching one of the type only case-insensitively is not the one of the type, as for the apiserver
+			name:       "field of another case",
+			input:      "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: default\nData:\n  key: a0\n",
+			decodeMode: "strict",
+			wantErr:    `ConfigMap default/a: strict decoding error: unknown field "Data"`,
+		},
+		{
+			name:       "unknown field lenient",
+			input:      "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: default\nunknownField: 1\n",
+			decodeMode: "lenient",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := filepath.Join(t.TempDir(), "input.yaml")
+			err := os.WriteFile(input, []byte(tt.input), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			etcdclient := fake.NewClient()
+			err = putCommand(context.Background(), etcdclient, &putFlagpole{
+				Output:     "none",
+				Path:       input,
+				Prefix:     "/registry",
+				DecodeMode: tt.decodeMode,
+				FailFast:   true,
+			}, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("putCommand() error = %v, want %v", err, tt.wantErr)
+				}
+				if etcdclient.Revision() != 1 {
+					t.Errorf("the object failing the decoding is put")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestPutCommandDeletions(t *testing.T) {
+	configMap := func(name string) string {
+		return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n  namespace: default\n"
+	}
+	configMapJSON := `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"}}`
+
+	tests := []struct {
+		name           string
+		input          string
+		applyDeletions bool
+		want           []string
+	}{
+		{
+			name: "yaml",
+			input: "# /registry/configmaps/default/a | application/json | 2\n" + configMap("a") +
+				"---\n# /registry/configmaps/default/b | application/json | 3\n" + configMap("b") +
+				"---\n# /registry/configmaps/default/a | application/json | 4 | deleted\n" + configMap("a") +
+				"---\n# /registry/configmaps/default/b | 5 | deleted\n" +
+				"---\n# /registry/configmaps/default/b | application/json | 6\n" + configMap("b"),
+			applyDeletions: true,
+			want:           []string{"/registry/configmaps/default/b"},
+		},
+		{
+			name: "jsonl",
+			input: `{"key":"/registry/configmaps/default/a","revision":2,"object":` + configMapJSON + "}\n" +
+				`{"key":"/registry/configmaps/default/a","revision":3,"deleted":true,"object":` + configMapJSON + "}\n",
+			applyDeletions: true,
+		},
+		{
+			name: "skipped",
+			input: "# /registry/configmaps/default/a | application/json | 2\n" + configMap("a") +
+				"---\n# /registry/configmaps/default/a | 3 | deleted\n",
+			want: []string{"/registry/configmaps/default/a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := filepath.Join(t.TempDir(), "input")
+			err := os.WriteFile(input, []byte(tt.input), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			etcdclient := fake.NewClient()
+			err = putCommand(context.Background(), etcdclient, &putFlagpole{
+				Output:         "none",
+				Path:           input,
+				Prefix:         "/registry",
+				DecodeMode:     "lenient",
+				ApplyDeletions: tt.applyDeletions,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			_, err = etcdclient.Get(context.Background(), "/registry",
+				client.WithGR(schema.GroupResource{Resource: "configmaps"}),
+				client.WithKeysOnly(),
+				client.WithResponse(func(kv *client.KeyValue) error {
+					got = append(got, string(kv.Key))
+					return nil
+				}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type queryFlagpole struct {
+	Prefix string
+}
+
+func newCtlQueryCommand() *cobra.Command {
+	flags := &queryFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Use:   "query",
+		Short: "Builds a get of the resource of k8s in etcd interactively",
+		Long: "Builds a get of the resource of k8s in etcd interactively.\n" +
+			"The resource, namespace, name and output are asked in turn, " +
+			"then the equivalent command line is printed before it is executed.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = queryCommand(cmd.Context(), etcdclient, cmd.InOrStdin(), flags)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+
+	return cmd
+}
+
+func queryCommand(ctx context.Context, etcdclient client.Client, in io.Reader, flags *queryFlagpole) error {
+	getFlags, args, err := askQuery(bufio.NewReader(in), os.Stderr)
+	if err != nil {
+		return err
+	}
+	getFlags.Prefix = flags.Prefix
+
+	fmt.Fprintf(os.Stderr, "%s\n", getCommandLine(getFlags, args))
+	return getCommand(ctx, etcdclient, getFlags, args)
+}
+
+var queryOutputs = []string{"yaml", "json", "raw", "key"}
+
+// askQuery asks the questions of a get and returns its flags and args.
+func askQuery(r *bufio.Reader, w io.Writer) (*getFlagpole, []string, error) {
+	flags := &getFlagpole{
+		ChunkSize: 500,
+	}
+	var args []string
+
+	var namespaced bool
+	for {
+		resource, err := ask(r, w, "Resource, ? to list the known ones, empty for all of etcd", "")
+		if err != nil {
+			return nil, nil, err
+		}
+		if resource == "?" {
+			for _, gr := range wellknown.GroupResources() {
+				fmt.Fprintf(w, "  %s\n", gr)
+			}
+			continue
+		}
+		if resource == "" {
+			break
+		}
+
+		gr := schema.ParseGroupResource(resource)
+		if correctGr, ok, found := wellknown.CorrectGroupResource(gr); found {
+			gr = correctGr
+			namespaced = ok
+		} else {
+			fmt.Fprintf(w, "%q is not a known resource, it is treated as a namespaced custom resource\n", resource)
+			namespaced = true
+		}
+		args = append(args, gr.String())
+		break
+	}
+
+	if len(args) != 0 && namespaced {
+		namespace, err := ask(r, w, "Namespace, * for all", "default")
+		if err != nil {
+			return nil, nil, err
+		}
+		if namespace == "*" {
+			flags.AllNamespace = true
+		} else {
+			flags.Namespace = namespace
+		}
+	}
+
+	if len(args) != 0 && !flags.AllNamespace {
+		name, err := ask(r, w, "Name, empty for all", "")
+		if err != nil {
+			return nil, nil, err
+		}
+		if name != "" {
+			args = append(args, name)
+		}
+	}
+
+	for {
+		output, err := ask(r, w, "Output, one of "+strings.Join(queryOutputs, ", "), "yaml")
+		if err != nil {
+			return nil, nil, err
+		}
+		if slices.Contains(queryOutputs, output) {
+			flags.Output = output
+			break
+		}
+		fmt.Fprintf(w, "unsupported output format: %s\n", output)
+	}
+
+	return flags, args, nil
+}
+
+// ask prints the question and returns the trimmed answer, or the default value if the answer is empty.
+func ask(r *bufio.Reader, w io.Writer, question, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Fprintf(w, "%s [%s]: ", question, defaultValue)
+	} else {
+		fmt.Fprintf(w, "%s: ", question)
+	}
+	line, err := r.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return defaultValue, nil
+	}
+	return line, nil
+}
+
+// getCommandLine returns the non-interactive command line of the get.
+func getCommandLine(flags *getFlagpole, args []string) string {
+	s := []string{"kectl", "get"}
+	s = append(s, args...)
+	if flags.AllNamespace {
+		s = append(s, "-A")
+	} else if flags.Namespace != "" {
+		s = append(s, "-n", flags.Namespace)
+	}
+	s = append(s, "-o", flags.Output)
+	if flags.Prefix != "/registry" {
+		s = append(s, "--prefix", flags.Prefix)
+	}
+	return strings.Join(s, " ")
+}
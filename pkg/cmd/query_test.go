@@ -0,0 +1,65 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAskQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers string
+		want    string
+	}{
+		{
+			name:    "defaults",
+			answers: "pods\n\n\n\n",
+			want:    "kectl get pods -n default -o yaml",
+		},
+		{
+			name:    "all namespaces",
+			answers: "deploy\n*\nkey\n",
+			want:    "kectl get deployments.apps -A -o key",
+		},
+		{
+			name:    "cluster scoped",
+			answers: "nodes\nnode-0\njson\n",
+			want:    "kectl get nodes node-0 -o json",
+		},
+		{
+			name:    "all of etcd",
+			answers: "?\n\nxml\nraw",
+			want:    "kectl get -o raw",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, args, err := askQuery(bufio.NewReader(strings.NewReader(tt.answers)), io.Discard)
+			if err != nil {
+				t.Fatalf("askQuery() error = %v", err)
+			}
+			flags.Prefix = "/registry"
+			if got := getCommandLine(flags, args); got != tt.want {
+				t.Errorf("getCommandLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+type reconstructFlagpole struct {
+	Namespace     string
+	Path          string
+	ChunkSize     int64
+	Prefix        string
+	AllNamespace  bool
+	SinceRevision int64
+	Consistency   string
+	DecodeMode    string
+	MaxPatchChain int
+}
+
+func newCtlReconstructCommand() *cobra.Command {
+	flags := &reconstructFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.RangeArgs(0, 2),
+		Use:   "reconstruct [resource] [name]",
+		Short: "Reconstructs the recording of the resource of k8s from the etcd history",
+		Long: "Reconstructs the recording of the resource of k8s from the etcd history, in the format of `kwokctl snapshot record`.\n" +
+			"The objects as of the since revision are written first, followed by the changes of every later revision up to the current one.\n" +
+			"The trace is the one of `get --watch --from-revision` after the since revision, the revisions compacted away from it are written as gaps.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = reconstructCommand(cmd.Context(), etcdclient, flags, args)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Path, "path", "-", "path of the file to write the recording to, - for stdout")
+	cmd.Flags().StringVarP(&flags.Namespace, "namespace", "n", "", "namespace of resource")
+	cmd.Flags().Int64Var(&flags.ChunkSize, "chunk-size", 500, "chunk size of the list pager")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().BoolVarP(&flags.AllNamespace, "all-namespace", "A", false, "all namespace")
+	cmd.Flags().Int64Var(&flags.SinceRevision, "since-revision", 0, "revision of the initial snapshot")
+	cmd.Flags().StringVar(&flags.DecodeMode, "decode-mode", "lenient", "how values not matching the scheme are handled. One of: (lenient, strict).")
+	cmd.Flags().StringVar(&flags.Consistency, "consistency", "", "consistency of the reads. One of: (l, s, linearizable, serializable). Defaults to s for listing and l for a single object.")
+	cmd.Flags().IntVar(&flags.MaxPatchChain, "max-patch-chain", 100, "number of patches to an object after which the object is checkpointed as a whole, for the readers following it to start from the checkpoint. 0 for never.")
+
+	return cmd
+}
+
+func reconstructCommand(ctx context.Context, etcdclient client.Client, flags *reconstructFlagpole, args []string) (err error) {
+	if flags.SinceRevision <= 0 {
+		return fmt.Errorf("since-revision is required")
+	}
+
+	end, err := currentRevision(ctx, etcdclient, flags.Prefix)
+	if err != nil {
+		return err
+	}
+	if end <= flags.SinceRevision {
+		return fmt.Errorf("no change after revision %d, the current revision is %d", flags.SinceRevision, end)
+	}
+
+	// The trace is the one of get, the objects listed as of the since revision and the watch after it
+	trace, err := os.CreateTemp("", "kectl-reconstruct-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = trace.Close()
+		_ = os.Remove(trace.Name())
+	}()
+
+	err = getCommand(ctx, etcdclient, &getFlagpole{
+		Namespace:    flags.Namespace,
+		Output:       "yaml",
+		ChunkSize:    flags.ChunkSize,
+		Watch:        true,
+		Prefix:       flags.Prefix,
+		AllNamespace: flags.AllNamespace,
+		Consistency:  flags.Consistency,
+		DecodeMode:   flags.DecodeMode,
+		MaxBandwidth: "0",
+		Path:         trace.Name(),
+		FromRevision: flags.SinceRevision + 1,
+		ToRevision:   end,
+	}, args)
+	if err != nil {
+		if errors.Is(err, rpctypes.ErrCompacted) {
+			return fmt.Errorf("revision %d has been compacted, choose a later one: %w", flags.SinceRevision, err)
+		}
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if flags.Path != "-" {
+		file, err := createRecording(flags.Path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, file.Close())
+		}()
+		err = file.Truncate(0)
+		if err != nil {
+			return err
+		}
+		out = file
+	}
+
+	objects, changes, err := convertToKwokRecording(trace, out, flags.MaxPatchChain)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "reconstruct %d objects at revision %d and %d changes up to revision %d\n", objects, flags.SinceRevision, changes, end)
+	return nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// compactAfterList compacts the history up to the current revision once the objects as of the revision are listed,
+// for the watch after the list to miss the revisions before it.
+type compactAfterList struct {
+	*fake.Client
+	revision int64
+}
+
+func (c *compactAfterList) Get(ctx context.Context, prefix string, opOpts ...client.OpOption) (int64, error) {
+	rev, err := c.Client.Get(ctx, prefix, opOpts...)
+	if err != nil || client.NewOp(opOpts...).Revision() != c.revision {
+		return rev, err
+	}
+	return rev, c.Client.Compact(c.Client.Revision())
+}
+
+func TestReconstructCommand(t *testing.T) {
+	gr := schema.GroupResource{Resource: "configmaps"}
+	put := func(t *testing.T, etcdclient client.Client, name, value string) {
+		err := etcdclient.Put(context.Background(), "/registry", []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"`+name+`","namespace":"default"},"data":{"key":"`+value+`"}}`),
+			client.WithGR(gr),
+			client.WithName(name, "default"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// history writes a and b, then updates a, deletes b and creates c after the returned revision
+	history := func(t *testing.T) (*fake.Client, int64) {
+		etcdclient := fake.NewClient()
+		put(t, etcdclient, "a", "a0")
+		put(t, etcdclient, "b", "b0")
+		since := etcdclient.Revision()
+		put(t, etcdclient, "a", "a1")
+		err := etcdclient.Delete(context.Background(), "/registry", client.WithGR(gr), client.WithName("b", "default"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		put(t, etcdclient, "c", "c0")
+		return etcdclient, since
+	}
+
+	tests := []struct {
+		name    string
+		client  func(t *testing.T) (client.Client, int64)
+		want    []string
+		wantGap string
+		wantErr error
+	}{
+		{
+			name: "snapshot and changes",
+			client: func(t *testing.T) (client.Client, int64) {
+				return history(t)
+			},
+			want: []string{"a", "b", `patch a 0s {"data":{"key":"a1"}}`, "delete b 0s with the object", "create c 0s"},
+		},
+		{
+			name: "compacted after the snapshot",
+			client: func(t *testing.T) (client.Client, int64) {
+				etcdclient, since := history(t)
+				return &compactAfterList{Client: etcdclient, revision: since}, since
+			},
+			// The objects are listed again after the gap, the delete in it is missing
+			want:    []string{"a", "b", `patch a 0s {"data":{"key":"a1"}}`, "create c 0s"},
+			wantGap: "# gap | 4 | 5\n",
+		},
+		{
+			name: "compacted snapshot",
+			client: func(t *testing.T) (client.Client, int64) {
+				etcdclient, since := history(t)
+				err := etcdclient.Compact(since + 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return etcdclient, since
+			},
+			wantErr: rpctypes.ErrCompacted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			etcdclient, since := tt.client(t)
+			path := filepath.Join(t.TempDir(), "recording.yaml")
+			err := reconstructCommand(context.Background(), etcdclient, &reconstructFlagpole{
+				Path:          path,
+				Prefix:        "/registry",
+				SinceRevision: since,
+				DecodeMode:    "lenient",
+			}, []string{"configmaps"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !strings.Contains(err.Error(), "revision "+strconv.FormatInt(since, 10)) {
+					t.Fatalf("reconstructCommand() = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := recordingSteps(t, string(data))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reconstructed %q, want %q", got, tt.want)
+			}
+			if gaps := strings.Count(string(data), gapHeaderPrefix); (tt.wantGap == "" && gaps != 0) || (tt.wantGap != "" && !strings.Contains(string(data), tt.wantGap)) {
+				t.Errorf("the gaps are not written as %q:\n%s", tt.wantGap, data)
+			}
+		})
+	}
+}
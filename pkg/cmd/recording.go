@@ -0,0 +1,414 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newCtlRecordingCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recording",
+		Short: "Inspects the recordings of the changes of k8s in etcd",
+		Long: "Inspects the recordings of the changes of k8s in etcd,\n" +
+			"as printed by get --raw-revision-range in the json, yaml or key output format,\n" +
+			"and the recordings of kwokctl snapshot record to be put, such as the ones of reconstruct.",
+	}
+	cmd.AddCommand(
+		newCtlRecordingStatsCommand(),
+		newCtlRecordingInfoCommand(),
+		newCtlRecordingInvertCommand(),
+		newCtlRecordingConvertCommand(),
+	)
+	return cmd
+}
+
+type recordingStatsFlagpole struct {
+	Output         string
+	Prefix         string
+	Top            int
+	Flame          bool
+	Weight         string
+	RevisionWindow int64
+	FromRevision   int64
+	ToRevision     int64
+}
+
+func newCtlRecordingStatsCommand() *cobra.Command {
+	flags := &recordingStatsFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "stats <path>",
+		Short: "Reports the writes and bytes of each resource and object of a recording",
+		Long: "Reports the writes and bytes of each resource and object of a recording, - for stdin.\n" +
+			"With --flame, the update heat is printed as folded stacks of resource;namespace;name and its weight, " +
+			"to be rendered by flamegraph.pl, speedscope or a treemap to find the objects dominating the recording.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := recordingStatsCommand(flags, args[0])
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().IntVar(&flags.Top, "top", 10, "number of the hottest objects to report, 0 for all")
+	cmd.Flags().BoolVar(&flags.Flame, "flame", false, "print the folded stacks of the update heat instead of the report")
+	cmd.Flags().StringVar(&flags.Weight, "weight", "bytes", "weight of the folded stacks. One of: (bytes, count).")
+	cmd.Flags().Int64Var(&flags.RevisionWindow, "revision-window", 0, "number of revisions to break the folded stacks down by, under a root frame of the revisions, to see the heat over time. 0 for the whole recording.")
+
+	cmd.Flags().Int64Var(&flags.FromRevision, "from-revision", 0, "first revision of the documents to report, the chunks of a v2 recording before it are not read. 0 for the first one.")
+	cmd.Flags().Int64Var(&flags.ToRevision, "to-revision", 0, "last revision of the documents to report, the chunks of a v2 recording after it are not read. 0 for the last one.")
+
+	return cmd
+}
+
+// recordingDocument is a document of a recording, with the bytes of its value as printed.
+type recordingDocument struct {
+	Key      string
+	Revision int64
+	Deleted  bool
+	Bytes    int64
+}
+
+// maxRecordingLineSize is the size of the longest line the readers of the recordings read, such as of a document of jsonl,
+// and of the largest record of the binary format, beyond which a length read from a recording is a corruption rather than allocated.
+const maxRecordingLineSize = 64 << 20
+
+// readRecordingDocuments reads the documents of a recording in order.
+// The bytes of a document are the lines after its header up to the next document, which are none in the key output format.
+func readRecordingDocuments(r io.Reader, prefix string, fn func(doc recordingDocument) error) error {
+	var doc *recordingDocument
+	flush := func() error {
+		if doc == nil {
+			return nil
+		}
+		err := fn(*doc)
+		doc = nil
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordingLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if key, revision, deleted, ok := parseTraceHeader(line, prefix); ok {
+			err := flush()
+			if err != nil {
+				return err
+			}
+			doc = &recordingDocument{
+				Key:      key,
+				Revision: revision,
+				Deleted:  deleted,
+			}
+			continue
+		}
+		if line == "---" || strings.HasPrefix(line, "# bookmark | ") {
+			err := flush()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if doc != nil {
+			doc.Bytes += int64(len(line)) + 1
+		}
+	}
+	err := scanner.Err()
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+type objectHeat struct {
+	Key     string `json:"key"`
+	Writes  int    `json:"writes"`
+	Deletes int    `json:"deletes"`
+	Bytes   int64  `json:"bytes"`
+}
+
+func (o *objectHeat) add(doc recordingDocument) {
+	if doc.Deleted {
+		o.Deletes++
+	} else {
+		o.Writes++
+	}
+	o.Bytes += doc.Bytes
+}
+
+type resourceHeat struct {
+	Resource string `json:"resource"`
+	Objects  int    `json:"objects"`
+	Writes   int    `json:"writes"`
+	Deletes  int    `json:"deletes"`
+	Bytes    int64  `json:"bytes"`
+}
+
+type recordingStats struct {
+	Documents    int            `json:"documents"`
+	FromRevision int64          `json:"fromRevision"`
+	ToRevision   int64          `json:"toRevision"`
+	Resources    []resourceHeat `json:"resources"`
+	Objects      []objectHeat   `json:"objects"`
+}
+
+// heatWindow is the heat of an object within a window of revisions.
+type heatWindow struct {
+	start int64
+	key   string
+}
+
+// recordingHeat is the update heat of a recording by object and window of revisions.
+type recordingHeat struct {
+	prefix    string
+	window    int64
+	documents int
+	from, to  int64
+	objects   map[heatWindow]*objectHeat
+}
+
+func newRecordingHeat(prefix string, window int64) *recordingHeat {
+	return &recordingHeat{
+		prefix:  prefix,
+		window:  window,
+		objects: map[heatWindow]*objectHeat{},
+	}
+}
+
+func (h *recordingHeat) add(doc recordingDocument) error {
+	h.documents++
+	if h.from == 0 || doc.Revision < h.from {
+		h.from = doc.Revision
+	}
+	if doc.Revision > h.to {
+		h.to = doc.Revision
+	}
+
+	w := heatWindow{key: doc.Key}
+	if h.window > 0 {
+		w.start = doc.Revision / h.window * h.window
+	}
+	o, ok := h.objects[w]
+	if !ok {
+		o = &objectHeat{Key: doc.Key}
+		h.objects[w] = o
+	}
+	o.add(doc)
+	return nil
+}
+
+// resourceName returns the resource of the key and the frames of the object under it.
+func (h *recordingHeat) resourceName(key string) (string, []string) {
+	gr, rest, ok := groupResourceFromKey(h.prefix, key)
+	if !ok || rest == "" {
+		return strings.TrimPrefix(key, h.prefix+"/"), nil
+	}
+	return gr.String(), strings.SplitN(rest, "/", 2)
+}
+
+// stats returns the heat of the whole recording, hottest first, with up to top objects.
+func (h *recordingHeat) stats(top int) *recordingStats {
+	objects := map[string]*objectHeat{}
+	for w, o := range h.objects {
+		total, ok := objects[w.key]
+		if !ok {
+			total = &objectHeat{Key: w.key}
+			objects[w.key] = total
+		}
+		total.Writes += o.Writes
+		total.Deletes += o.Deletes
+		total.Bytes += o.Bytes
+	}
+
+	resources := map[string]*resourceHeat{}
+	sorted := make([]objectHeat, 0, len(objects))
+	for key, o := range objects {
+		resource, _ := h.resourceName(key)
+		r, ok := resources[resource]
+		if !ok {
+			r = &resourceHeat{Resource: resource}
+			resources[resource] = r
+		}
+		r.Objects++
+		r.Writes += o.Writes
+		r.Deletes += o.Deletes
+		r.Bytes += o.Bytes
+		sorted = append(sorted, *o)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Bytes != sorted[j].Bytes {
+			return sorted[i].Bytes > sorted[j].Bytes
+		}
+		if sorted[i].Writes+sorted[i].Deletes != sorted[j].Writes+sorted[j].Deletes {
+			return sorted[i].Writes+sorted[i].Deletes > sorted[j].Writes+sorted[j].Deletes
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	if top > 0 && len(sorted) > top {
+		sorted = sorted[:top]
+	}
+
+	stats := &recordingStats{
+		Documents:    h.documents,
+		FromRevision: h.from,
+		ToRevision:   h.to,
+		Resources:    make([]resourceHeat, 0, len(resources)),
+		Objects:      sorted,
+	}
+	for _, r := range resources {
+		stats.Resources = append(stats.Resources, *r)
+	}
+	sort.Slice(stats.Resources, func(i, j int) bool {
+		if stats.Resources[i].Bytes != stats.Resources[j].Bytes {
+			return stats.Resources[i].Bytes > stats.Resources[j].Bytes
+		}
+		if stats.Resources[i].Writes+stats.Resources[i].Deletes != stats.Resources[j].Writes+stats.Resources[j].Deletes {
+			return stats.Resources[i].Writes+stats.Resources[i].Deletes > stats.Resources[j].Writes+stats.Resources[j].Deletes
+		}
+		return stats.Resources[i].Resource < stats.Resources[j].Resource
+	})
+	return stats
+}
+
+// writeFlame writes the heat as folded stacks, one `frame;frame;frame weight` line for each object in each window,
+// weighted by the bytes or the number of the writes and deletes. The lines without weight are left out.
+func (h *recordingHeat) writeFlame(w io.Writer, weight string) error {
+	// The revisions are padded for the windows to be sorted by time as strings
+	width := len(strconv.FormatInt(h.to, 10))
+	lines := make([]string, 0, len(h.objects))
+	for hw, o := range h.objects {
+		var value int64
+		switch weight {
+		case "bytes":
+			value = o.Bytes
+		case "count":
+			value = int64(o.Writes + o.Deletes)
+		}
+		if value == 0 {
+			continue
+		}
+
+		resource, frames := h.resourceName(hw.key)
+		frames = append([]string{resource}, frames...)
+		if h.window > 0 {
+			frames = append([]string{fmt.Sprintf("revisions %0*d-%0*d", width, hw.start, width, hw.start+h.window-1)}, frames...)
+		}
+		// Semicolons separate the frames and the last space the weight, neither of which are in the names of k8s
+		lines = append(lines, fmt.Sprintf("%s %d", strings.Join(frames, ";"), value))
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func recordingStatsCommand(flags *recordingStatsFlagpole, path string) error {
+	if flags.Output != "table" && flags.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+	if flags.Weight != "bytes" && flags.Weight != "count" {
+		return fmt.Errorf("unsupported weight: %s", flags.Weight)
+	}
+	if flags.RevisionWindow < 0 {
+		return fmt.Errorf("invalid revision window %d", flags.RevisionWindow)
+	}
+	if flags.RevisionWindow != 0 && !flags.Flame {
+		return fmt.Errorf("--revision-window only applies to --flame")
+	}
+	if flags.FromRevision < 0 || flags.ToRevision < 0 || (flags.ToRevision != 0 && flags.FromRevision > flags.ToRevision) {
+		return fmt.Errorf("invalid revisions %d-%d", flags.FromRevision, flags.ToRevision)
+	}
+
+	var r io.ReadCloser
+	var err error
+	if path == "-" {
+		r, err = decompress(os.Stdin)
+	} else {
+		r, err = openRecordingRevisions(path, flags.FromRevision, flags.ToRevision)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	heat := newRecordingHeat(flags.Prefix, flags.RevisionWindow)
+	err = readRecordingDocuments(r, flags.Prefix, func(doc recordingDocument) error {
+		if doc.Revision < flags.FromRevision || (flags.ToRevision != 0 && doc.Revision > flags.ToRevision) {
+			return nil
+		}
+		return heat.add(doc)
+	})
+	if err != nil {
+		return err
+	}
+
+	if flags.Flame {
+		err = heat.writeFlame(os.Stdout, flags.Weight)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "read %d documents from revision %d to %d\n", heat.documents, heat.from, heat.to)
+		return nil
+	}
+
+	stats := heat.stats(flags.Top)
+	switch flags.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(stats)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "RESOURCE\tOBJECTS\tWRITES\tDELETES\tBYTES")
+		for _, r := range stats.Resources {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", r.Resource, r.Objects, r.Writes, r.Deletes, r.Bytes)
+		}
+		if len(stats.Objects) != 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "KEY\tWRITES\tDELETES\tBYTES")
+			for _, o := range stats.Objects {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", o.Key, o.Writes, o.Deletes, o.Bytes)
+			}
+		}
+		err = w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "read %d documents from revision %d to %d\n", heat.documents, heat.from, heat.to)
+	return nil
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+type recordingConvertFlagpole struct {
+	Format            string
+	Prefix            string
+	Path              string
+	OutputWatchEvents bool
+	MaxPatchChain     int
+}
+
+func newCtlRecordingConvertCommand() *cobra.Command {
+	flags := &recordingConvertFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "convert <path>",
+		Short: "Converts a recording between the v1, v2, kwok and kubectl formats",
+		Long: "Converts a recording, - for stdin, between the v1 format of the documents as printed and the v2 format\n" +
+			"of the documents in compressed chunks indexed by revision, which the readers seek in to the revisions they want.\n" +
+			"The kwok format is the objects followed by their changes as of `kwokctl snapshot record`, converted from a stream of\n" +
+			"full objects such as of `kubectl get -o yaml --watch` by patching each version from the one before it,\n" +
+			"and the kubectl format is that stream of full objects, converted from a recording by following the objects through its changes.\n" +
+			"The outputs other than v2 are compressed by the .gz or .zst extension of --path.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := recordingConvertCommand(flags, args[0])
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Format, "format", recordingFormatV2, "format to convert the recording to. One of: (v1, v2, kwok, kubectl).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().StringVar(&flags.Path, "path", "-", "path of the file to write the converted recording to, - for stdout")
+	cmd.Flags().BoolVar(&flags.OutputWatchEvents, "output-watch-events", false, "write the objects of the kubectl format as the events of kubectl get --watch --output-watch-events, for the deletions to be told apart from the updates.")
+	cmd.Flags().IntVar(&flags.MaxPatchChain, "max-patch-chain", 100, "number of patches to an object of the kwok format after which the object is checkpointed as a whole, for the readers following it to start from the checkpoint. 0 for never.")
+
+	return cmd
+}
+
+func recordingConvertCommand(flags *recordingConvertFlagpole, input string) (err error) {
+	switch flags.Format {
+	case recordingFormatV1, recordingFormatV2, recordingFormatKwok, recordingFormatKubectl:
+	default:
+		return fmt.Errorf("unsupported recording format %q. One of: (v1, v2, kwok, kubectl)", flags.Format)
+	}
+	if flags.OutputWatchEvents && flags.Format != recordingFormatKubectl {
+		return fmt.Errorf("--output-watch-events only applies to the kubectl format")
+	}
+
+	var r io.ReadCloser
+	if input == "-" {
+		r, err = decompress(os.Stdin)
+	} else {
+		r, err = openRecording(input)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out := io.Writer(os.Stdout)
+	if flags.Path != "-" {
+		file, err := createRecording(flags.Path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, file.Close())
+		}()
+		err = file.Truncate(0)
+		if err != nil {
+			return err
+		}
+		out = file
+	}
+
+	var w io.WriteCloser
+	if flags.Format == recordingFormatV2 {
+		w, err = newRecordingV2Writer(out, flags.Prefix)
+	} else {
+		compression := compressionNone
+		if flags.Path != "-" {
+			compression = compressionFromPath(flags.Path)
+		}
+		w, err = newCompressWriter(out, compression, 0)
+	}
+	if err != nil {
+		return err
+	}
+
+	dst := out
+	if w != nil {
+		dst = w
+	}
+	var summary string
+	switch flags.Format {
+	case recordingFormatKwok:
+		var objects, changes int
+		objects, changes, err = convertToKwokRecording(r, dst, flags.MaxPatchChain)
+		summary = fmt.Sprintf("convert into %d objects and %d changes of the kwok format", objects, changes)
+	case recordingFormatKubectl:
+		var count int
+		count, err = convertToKubectlWatch(r, dst, flags.OutputWatchEvents, os.Stderr)
+		summary = fmt.Sprintf("convert into %d versions of the objects of the kubectl format", count)
+	default:
+		var n int64
+		n, err = io.Copy(dst, r)
+		summary = fmt.Sprintf("convert %s of documents to the %s format", formatBytes(float64(n)), flags.Format)
+	}
+	if err == nil && w != nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, summary)
+	return nil
+}
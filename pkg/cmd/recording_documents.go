@@ -0,0 +1,294 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"github.com/wzshiming/kectl/pkg/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// recordingRawDocument is a document of a recording as it is written, with what it is.
+type recordingRawDocument struct {
+	Data []byte
+	// Header is whether the document is about the recording rather than a change, such as the clock skew or the Recording document of a kwok recording
+	Header bool
+	// ClockSkew is the clock skew of the recorder, of the header telling it
+	ClockSkew *clockSkew
+	// Bookmark is the time of a bookmark
+	Bookmark *time.Time
+	// Gap is the revisions compacted away from the watch of the recording, of a gap
+	Gap *client.Gap
+	// Key is the key of a document of the outputs of kectl, and Deleted whether it is a deletion
+	Key     string
+	Deleted bool
+	// Revision is the revision of a document of a key, or of a bookmark, 0 if the recording does not tell it
+	Revision int64
+	// Object is the object of the documents without a key, such as the ones of a kwok recording, nil for an empty one
+	Object *unstructured.Unstructured
+}
+
+// recordingScanner reads the documents of a recording in yaml or jsonl, as they are written.
+type recordingScanner struct {
+	br     *bufio.Reader
+	prefix string
+	// JSONL is whether the documents are the lines of the jsonl output, rather than yaml or json documents
+	JSONL bool
+}
+
+func newRecordingScanner(r io.Reader, prefix string) *recordingScanner {
+	br := bufio.NewReader(r)
+	return &recordingScanner{
+		br:     br,
+		prefix: prefix,
+		JSONL:  isJSONStream(br),
+	}
+}
+
+// Scan calls the visitFunc with each document in order.
+func (s *recordingScanner) Scan(visitFunc func(doc *recordingRawDocument) error) error {
+	scanner := bufio.NewScanner(s.br)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordingLineSize)
+
+	if s.JSONL {
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			doc, err := s.jsonLine(append(bytes.Clone(line), '\n'))
+			if err != nil {
+				return err
+			}
+			err = visitFunc(doc)
+			if err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+
+	var data []byte
+	flush := func() error {
+		if len(data) == 0 {
+			return nil
+		}
+		doc, err := s.yamlDocument(data)
+		if err != nil {
+			return err
+		}
+		data = nil
+		return visitFunc(doc)
+	}
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if string(line) == "---" {
+			err := flush()
+			if err != nil {
+				return err
+			}
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+	err := scanner.Err()
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (s *recordingScanner) yamlDocument(data []byte) (*recordingRawDocument, error) {
+	doc := &recordingRawDocument{Data: data}
+	header, _, _ := strings.Cut(strings.TrimPrefix(string(data), "---\n"), "\n")
+	if bookmark, ok := strings.CutPrefix(header, "# bookmark | "); ok {
+		rev, at, _ := strings.Cut(bookmark, " | ")
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bookmark %q: %w", header, err)
+		}
+		doc.Bookmark = &t
+		doc.Revision, _ = strconv.ParseInt(rev, 10, 64)
+		return doc, nil
+	}
+	if gap, ok := parseGapHeader(header); ok {
+		doc.Gap = &gap
+		return doc, nil
+	}
+	if skew, ok := parseClockSkew(header); ok {
+		doc.Header = true
+		doc.ClockSkew = &skew
+		return doc, nil
+	}
+	if key, ok := strings.CutPrefix(header, "# "); ok && strings.HasPrefix(key, s.prefix+"/") {
+		fields := strings.Split(key, " | ")
+		doc.Key = fields[0]
+		doc.Deleted = isDeletedDocument(data)
+		// The revision is the number among the fields of the outputs with the revisions,
+		// after the media type or alone before the mark of a deletion without the object
+		for _, field := range fields[1:] {
+			if rev, err := strconv.ParseInt(field, 10, 64); err == nil {
+				doc.Revision = rev
+			}
+		}
+		return doc, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	err := yaml.Unmarshal(data, &obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	return s.object(doc, obj)
+}
+
+func (s *recordingScanner) jsonLine(data []byte) (*recordingRawDocument, error) {
+	doc := &recordingRawDocument{Data: data}
+	var line jsonLine
+	err := json.Unmarshal(data, &line)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case line.ClockSkew != nil:
+		doc.Header = true
+		doc.ClockSkew = line.ClockSkew
+		return doc, nil
+	case line.Gap != nil:
+		doc.Gap = line.Gap
+		return doc, nil
+	case line.Bookmark != nil:
+		doc.Bookmark = line.Bookmark
+		doc.Revision = line.Revision
+		return doc, nil
+	case line.Key != "":
+		doc.Key = line.Key
+		doc.Deleted = line.Deleted
+		doc.Revision = line.Revision
+		return doc, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	err = json.Unmarshal(data, &obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	return s.object(doc, obj)
+}
+
+func (s *recordingScanner) object(doc *recordingRawDocument, obj *unstructured.Unstructured) (*recordingRawDocument, error) {
+	if len(obj.Object) == 0 {
+		return doc, nil
+	}
+	if isKwokRecording(obj) {
+		err := checkKwokVersion(obj)
+		if err != nil {
+			return nil, err
+		}
+		doc.Header = true
+	}
+	doc.Object = obj
+	return doc, nil
+}
+
+// keyDocumentObject returns the object of the document of a key, of a recording in JSON Lines if jsonl is true,
+// nil for the keys only, the values that can not be decoded or the deletions without it.
+func keyDocumentObject(doc *recordingRawDocument, jsonl bool) (map[string]any, error) {
+	var object map[string]any
+	if !jsonl {
+		err := yaml.Unmarshal(doc.Data, &object)
+		return object, err
+	}
+	var line jsonLine
+	err := json.Unmarshal(doc.Data, &line)
+	if err != nil || len(line.Object) == 0 {
+		return nil, err
+	}
+	err = json.Unmarshal(line.Object, &object)
+	return object, err
+}
+
+// recordingClock follows the time of a recording and the objects of its keys as its documents are scanned in order,
+// to tell the time and the method of each document of a key.
+type recordingClock struct {
+	// start is the time the recording starts at, of its clock skew or its first bookmark, and at the time of the last of them
+	start time.Time
+	at    time.Time
+	// bookmarked is whether a bookmark is read, the documents of a recording of kectl before it being the objects it starts with
+	bookmarked bool
+	// exists is the keys of the objects existing as of the document, to tell the creates from the updates
+	exists map[string]bool
+}
+
+// tick advances the clock to the clock skew of a header or to the time of a bookmark, the other documents leave it as it is.
+func (c *recordingClock) tick(doc *recordingRawDocument) {
+	switch {
+	case doc.Header:
+		if doc.ClockSkew != nil && c.start.IsZero() {
+			c.start = doc.ClockSkew.Time
+			c.at = c.start
+		}
+	case doc.Bookmark != nil:
+		if c.start.IsZero() {
+			c.start = *doc.Bookmark
+		}
+		c.at = *doc.Bookmark
+		c.bookmarked = true
+	}
+}
+
+// elapsed returns the time of the documents since the start of the recording.
+func (c *recordingClock) elapsed() time.Duration {
+	return c.at.Sub(c.start)
+}
+
+// method returns the method of the document of a key as of the keys existing before it, and records it as existing or not.
+// The documents before the first bookmark are the objects the recording starts with, and the ones after it the creates, updates and deletes.
+func (c *recordingClock) method(doc *recordingRawDocument) string {
+	if c.exists == nil {
+		c.exists = map[string]bool{}
+	}
+	method := "update"
+	switch {
+	case !c.bookmarked:
+		method = "object"
+	case doc.Deleted:
+		method = "delete"
+	case !c.exists[doc.Key]:
+		method = "create"
+	}
+	c.exists[doc.Key] = !doc.Deleted
+	return method
+}
+
+// kwokPatchMethod returns the method of a change of a kwok recording, checkpoint for the checkpoints of the objects.
+func kwokPatchMethod(rp *actionv1alpha1.ResourcePatch) string {
+	if rp.Checkpoint {
+		return "checkpoint"
+	}
+	return string(rp.Method)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+type recordingInfoFlagpole struct {
+	Output string
+	Prefix string
+}
+
+func newCtlRecordingInfoCommand() *cobra.Command {
+	flags := &recordingInfoFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "info <path>",
+		Short: "Reports the format, documents, revisions and times of a recording",
+		Long: "Reports the format, documents, revisions and times of a recording, - for stdin.\n" +
+			"A complete local recording of the v2 format is reported from its index without reading its documents,\n" +
+			"the others are read through. The times are the ones of the bookmarks.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := recordingInfoCommand(flags, args[0])
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+
+	return cmd
+}
+
+type recordingInfo struct {
+	Format       string `json:"format"`
+	Chunks       int    `json:"chunks,omitempty"`
+	Documents    int64  `json:"documents"`
+	Bytes        int64  `json:"bytes"`
+	FromRevision int64  `json:"fromRevision,omitempty"`
+	ToRevision   int64  `json:"toRevision,omitempty"`
+	FromTime     string `json:"fromTime,omitempty"`
+	ToTime       string `json:"toTime,omitempty"`
+}
+
+func newRecordingInfo(format string, index *recordingV2Index) *recordingInfo {
+	info := &recordingInfo{
+		Format:       format,
+		Documents:    index.Documents,
+		Bytes:        index.Bytes,
+		FromRevision: index.FromRevision,
+		ToRevision:   index.ToRevision,
+		FromTime:     index.FromTime,
+		ToTime:       index.ToTime,
+	}
+	if format == recordingFormatV2 {
+		info.Chunks = len(index.Chunks)
+	}
+	return info
+}
+
+// readRecordingInfo reads the info of the recording at the path, from the index of a complete local v2 recording.
+func readRecordingInfo(path, prefix string) (*recordingInfo, error) {
+	if path != "-" && !isObjectStoragePath(path) {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		stat, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		index, err := readRecordingV2Index(file, stat.Size())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if index != nil {
+			return newRecordingInfo(recordingFormatV2, index), nil
+		}
+	}
+
+	src, err := openRecordingSource(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	br := bufio.NewReader(src)
+	format := recordingFormatV1
+	if isRecordingV2(br) {
+		format = recordingFormatV2
+	} else if isRecordingBinary(br) {
+		format = recordingFormatBinary
+	}
+	r, err := decompress(br)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// The documents are indexed as they would be written in the v2 format, without writing them
+	indexer, err := newRecordingV2Writer(io.Discard, prefix)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(indexer, r)
+	if err != nil {
+		return nil, err
+	}
+	err = indexer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return newRecordingInfo(format, &indexer.index), nil
+}
+
+func recordingInfoCommand(flags *recordingInfoFlagpole, path string) error {
+	if flags.Output != "table" && flags.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+	info, err := readRecordingInfo(path, flags.Prefix)
+	if err != nil {
+		return err
+	}
+
+	switch flags.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintf(w, "FORMAT\t%s\n", info.Format)
+		if info.Chunks != 0 {
+			fmt.Fprintf(w, "CHUNKS\t%d\n", info.Chunks)
+		}
+		fmt.Fprintf(w, "DOCUMENTS\t%d\n", info.Documents)
+		fmt.Fprintf(w, "BYTES\t%d\n", info.Bytes)
+		if info.FromRevision != 0 {
+			fmt.Fprintf(w, "REVISIONS\t%d-%d\n", info.FromRevision, info.ToRevision)
+		}
+		if info.FromTime != "" {
+			fmt.Fprintf(w, "TIMES\t%s - %s\n", info.FromTime, info.ToTime)
+		}
+		return w.Flush()
+	}
+}
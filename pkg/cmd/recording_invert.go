@@ -0,0 +1,265 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"github.com/wzshiming/kectl/pkg/scheme"
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+type recordingInvertFlagpole struct {
+	Originals string
+	Path      string
+}
+
+func newCtlRecordingInvertCommand() *cobra.Command {
+	flags := &recordingInvertFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "invert <path>",
+		Short: "Computes the inverse of a kwok recording, to roll back putting it",
+		Long: "Computes the inverse of a recording of kwokctl snapshot record, - for stdin, as ResourcePatch documents in the reverse order:\n" +
+			"creates become deletes, deletes become creates of the deleted objects and patches become the patches back to the objects before them.\n" +
+			"The objects the recording overwrites are restored from --originals, such as the output of get before putting it, and deleted otherwise.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := recordingInvertCommand(flags, args[0])
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Originals, "originals", "", "path of the objects before putting the recording, to restore the ones it overwrites")
+	cmd.Flags().StringVar(&flags.Path, "path", "-", "path of the file to write the inverse to, - for stdout")
+
+	return cmd
+}
+
+func recordingInvertCommand(flags *recordingInvertFlagpole, input string) error {
+	originals := map[snapshotObjectRef][]byte{}
+	if flags.Originals != "" {
+		err := decodeFile(flags.Originals, func(obj *unstructured.Unstructured) error {
+			ref, _, err := snapshotObjectRefOf(obj)
+			if err != nil {
+				return err
+			}
+			data, err := obj.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			originals[ref] = data
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("originals %s: %w", flags.Originals, err)
+		}
+	}
+
+	var docs []*unstructured.Unstructured
+	err := decodeFile(input, func(obj *unstructured.Unstructured) error {
+		docs = append(docs, obj)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	inverse, err := invertKwokRecording(docs, originals, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if flags.Path != "-" {
+		file, err := createRecording(flags.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		err = file.Truncate(0)
+		if err != nil {
+			return err
+		}
+		out = file
+	}
+	for _, rp := range inverse {
+		err = writeYAMLDocument(out, rp)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "invert %d documents into %d changes\n", len(docs), len(inverse))
+	return nil
+}
+
+// invertKwokRecording returns the changes undoing the recording, from the last one back to the objects it starts with.
+// The objects are followed through the recording to know the ones before each change,
+// the changes to objects it does not know about can not be undone and are warned about to w.
+func invertKwokRecording(docs []*unstructured.Unstructured, originals map[snapshotObjectRef][]byte, w io.Writer) ([]*actionv1alpha1.ResourcePatch, error) {
+	// state is the objects as of the change, nil for the deleted ones
+	state := map[snapshotObjectRef][]byte{}
+	lookup := func(ref snapshotObjectRef) []byte {
+		if data, ok := state[ref]; ok {
+			return data
+		}
+		return originals[ref]
+	}
+
+	var undos []*actionv1alpha1.ResourcePatch
+	var elapsed time.Duration
+	for i, obj := range docs {
+		if !isKwokResourcePatch(obj) {
+			ref, gvr, err := snapshotObjectRefOf(obj)
+			if err != nil {
+				return nil, fmt.Errorf("document %d: %w", i, err)
+			}
+			data, err := obj.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			// The objects are undone after all the changes
+			undos = append(undos, restorePatch(gvr, ref, lookup(ref), -1))
+			state[ref] = data
+			continue
+		}
+
+		rp, err := decodeKwokResourcePatch(obj)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		ref := snapshotObjectRef{
+			gr:        rp.Resource.GroupResource(),
+			namespace: rp.Target.Namespace,
+			name:      rp.Target.Name,
+		}
+		gvr := schema.GroupVersionResource{Group: rp.Resource.Group, Version: rp.Resource.Version, Resource: rp.Resource.Resource}
+		elapsed = max(elapsed, rp.DurationNanosecond)
+		before := lookup(ref)
+
+		switch rp.Method {
+		case actionv1alpha1.PatchMethodCreate:
+			undos = append(undos, restorePatch(gvr, ref, before, rp.DurationNanosecond))
+			state[ref] = rp.Template
+		case actionv1alpha1.PatchMethodDelete:
+			if len(rp.Template) != 0 {
+				// The object as it was deleted, rather than as followed through the recording
+				before = rp.Template
+			}
+			if before == nil {
+				fmt.Fprintf(w, "warning: can not undo the delete of %s, the object is not known before it\n", ref)
+			} else {
+				undos = append(undos, restorePatch(gvr, ref, before, rp.DurationNanosecond))
+			}
+			state[ref] = nil
+		case actionv1alpha1.PatchMethodPatch:
+			if rp.Checkpoint {
+				// The object as of the checkpoint, which changes nothing to undo
+				state[ref] = rp.Template
+				continue
+			}
+			if before == nil {
+				fmt.Fprintf(w, "warning: can not undo the patch of %s, the object is not known before it\n", ref)
+				delete(state, ref)
+				continue
+			}
+			after, err := applyStrategicPatch(before, rp.Template)
+			if err != nil {
+				return nil, fmt.Errorf("document %d: patch %s: %w", i, ref, err)
+			}
+			afterData, err := after.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			reverse, err := reversePatch(afterData, before)
+			if err != nil {
+				return nil, fmt.Errorf("document %d: patch %s: %w", i, ref, err)
+			}
+			undo := newResourcePatch(gvr, ref, actionv1alpha1.PatchMethodPatch, rp.DurationNanosecond)
+			undo.Template = reverse
+			undos = append(undos, undo)
+			state[ref] = afterData
+		}
+	}
+
+	// The inverse starts with the last change, the duration of a change is mirrored from the end of the recording
+	inverse := make([]*actionv1alpha1.ResourcePatch, 0, len(undos))
+	for i := len(undos) - 1; i >= 0; i-- {
+		rp := undos[i]
+		if rp.DurationNanosecond < 0 {
+			rp.DurationNanosecond = elapsed
+		} else {
+			rp.DurationNanosecond = elapsed - rp.DurationNanosecond
+		}
+		inverse = append(inverse, rp)
+	}
+	return inverse, nil
+}
+
+func newResourcePatch(gvr schema.GroupVersionResource, ref snapshotObjectRef, method actionv1alpha1.PatchMethod, duration time.Duration) *actionv1alpha1.ResourcePatch {
+	rp := &actionv1alpha1.ResourcePatch{
+		DurationNanosecond: duration,
+		Method:             method,
+	}
+	rp.Resource.Group = gvr.Group
+	rp.Resource.Version = gvr.Version
+	rp.Resource.Resource = gvr.Resource
+	rp.Target.Name = ref.name
+	rp.Target.Namespace = ref.namespace
+	return rp
+}
+
+// restorePatch returns the change back to the object before, a delete if there was none.
+func restorePatch(gvr schema.GroupVersionResource, ref snapshotObjectRef, before []byte, duration time.Duration) *actionv1alpha1.ResourcePatch {
+	if before == nil {
+		return newResourcePatch(gvr, ref, actionv1alpha1.PatchMethodDelete, duration)
+	}
+	rp := newResourcePatch(gvr, ref, actionv1alpha1.PatchMethodCreate, duration)
+	rp.Template = before
+	return rp
+}
+
+// reversePatch returns the patch from the object after back to the one before, in the way applyStrategicPatch applies it.
+func reversePatch(after, before []byte) ([]byte, error) {
+	return twoWayPatch(after, before)
+}
+
+// twoWayPatch returns the patch from the object from to the object to, in the way applyStrategicPatch applies it.
+func twoWayPatch(from, to []byte) ([]byte, error) {
+	obj := &unstructured.Unstructured{}
+	err := obj.UnmarshalJSON(from)
+	if err != nil {
+		return nil, err
+	}
+	dataStruct, err := scheme.Scheme.New(obj.GroupVersionKind())
+	if err == nil {
+		return strategicpatch.CreateTwoWayMergePatch(from, to, dataStruct)
+	}
+	return jsonpatch.CreateMergePatch(from, to)
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRecordingInvert(t *testing.T) {
+	dir := t.TempDir()
+	originals := filepath.Join(dir, "originals.yaml")
+	err := os.WriteFile(originals, []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: a\n  namespace: default\nspec:\n  nodeName: node-old\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recording := filepath.Join(dir, "recording.yaml")
+	docs := []string{
+		"apiVersion: v1\nkind: Pod\nmetadata:\n  name: a\n  namespace: default\nspec:\n  nodeName: node0\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n  namespace: default\ndata:\n  key: value\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: pods\ntarget:\n  name: a\n  namespace: default\ndurationNanosecond: 1000\nmethod: patch\ntemplate:\n  status:\n    phase: Running\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: b\n  namespace: default\ndurationNanosecond: 2000\nmethod: delete\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: c\n  namespace: default\ndurationNanosecond: 3000\nmethod: create\ntemplate:\n  apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: c\n    namespace: default\n",
+	}
+	err = os.WriteFile(recording, []byte(strings.Join(docs, "---\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inverse := filepath.Join(dir, "inverse.yaml")
+	err = recordingInvertCommand(&recordingInvertFlagpole{
+		Originals: originals,
+		Path:      inverse,
+	}, recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	err = decodeFile(inverse, func(obj *unstructured.Unstructured) error {
+		rp, err := decodeKwokResourcePatch(obj)
+		if err != nil {
+			return err
+		}
+		got = append(got, string(rp.Method)+" "+rp.Target.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"delete c", "create b", "patch a", "delete b", "create a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inverse = %v, want %v", got, want)
+	}
+
+	// Putting the recording then its inverse leaves the originals
+	etcdclient := fake.NewClient()
+	for _, path := range []string{originals, recording, inverse} {
+		err = putCommand(context.Background(), etcdclient, &putFlagpole{
+			Output:     "none",
+			Path:       path,
+			Prefix:     "/registry",
+			DecodeMode: "lenient",
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var keys []string
+	_, err = etcdclient.Get(context.Background(), "/registry",
+		client.WithKeysOnly(),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			keys = append(keys, string(kv.Key))
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/registry/pods/default/a"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+	data, err := getObjectJSON(context.Background(), etcdclient, "/registry", podGroupResource, "a", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := &unstructured.Unstructured{}
+	err = pod.UnmarshalJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodeName, _, _ := unstructured.NestedString(pod.Object, "spec", "nodeName"); nodeName != "node-old" {
+		t.Errorf("spec.nodeName = %q, want node-old", nodeName)
+	}
+	if _, found, _ := unstructured.NestedString(pod.Object, "status", "phase"); found {
+		t.Errorf("status.phase is not rolled back")
+	}
+}
+
+func TestInvertKwokRecordingUnknown(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	err := obj.UnmarshalJSON([]byte(`{"apiVersion":"action.kwok.x-k8s.io/v1alpha1","kind":"ResourcePatch","resource":{"version":"v1","resource":"pods"},"target":{"name":"a","namespace":"default"},"method":"delete"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings bytes.Buffer
+	inverse, err := invertKwokRecording([]*unstructured.Unstructured{obj}, nil, &warnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inverse) != 0 || !strings.Contains(warnings.String(), "can not undo the delete of pods/default/a") {
+		t.Errorf("invertKwokRecording() = %v, warnings %q", inverse, warnings.String())
+	}
+}
+
+func TestInvertKwokRecordingDeletedObject(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	err := obj.UnmarshalJSON([]byte(`{"apiVersion":"action.kwok.x-k8s.io/v1alpha1","kind":"ResourcePatch","resource":{"version":"v1","resource":"pods"},"target":{"name":"a","namespace":"default"},"method":"delete",` +
+		`"template":{"apiVersion":"v1","kind":"Pod","metadata":{"name":"a","namespace":"default"},"spec":{"nodeName":"node0"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings bytes.Buffer
+	inverse, err := invertKwokRecording([]*unstructured.Unstructured{obj}, nil, &warnings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inverse) != 1 || inverse[0].Method != actionv1alpha1.PatchMethodCreate || !strings.Contains(string(inverse[0].Template), `"nodeName":"node0"`) {
+		t.Fatalf("invertKwokRecording() = %v, warnings %q", inverse, warnings.String())
+	}
+}
+
+func TestTwoWayPatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+	}{
+		{
+			name:   "scheme",
+			before: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a"},"data":{"a":"1","b":"2"}}`,
+			after:  `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a"},"data":{"a":"1","c":"3"}}`,
+		},
+		{
+			name:   "unknown kind",
+			before: `{"apiVersion":"example.com/v1","kind":"Unknown","metadata":{"name":"a"},"spec":{"a":1,"b":2}}`,
+			after:  `{"apiVersion":"example.com/v1","kind":"Unknown","metadata":{"name":"a"},"spec":{"a":1,"c":3}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The forward patch takes the object before to the one after, and the reverse one back
+			for _, step := range []struct {
+				from, to string
+				patch    func(from, to []byte) ([]byte, error)
+			}{
+				{tt.before, tt.after, twoWayPatch},
+				{tt.after, tt.before, reversePatch},
+			} {
+				patch, err := step.patch([]byte(step.from), []byte(step.to))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := applyStrategicPatch([]byte(step.from), patch)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := &unstructured.Unstructured{}
+				err = want.UnmarshalJSON([]byte(step.to))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got.Object, want.Object) {
+					t.Errorf("patch %s applied to %s = %v, want %v", patch, step.from, got.Object, want.Object)
+				}
+			}
+		})
+	}
+}
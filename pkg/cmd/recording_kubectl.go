@@ -0,0 +1,397 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"github.com/wzshiming/kectl/pkg/client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// The formats of the recordings converted from and to the streams of full objects of `kubectl get -o yaml --watch`.
+const (
+	// recordingFormatKwok is the objects followed by the ResourcePatch changes of `kwokctl snapshot record`
+	recordingFormatKwok = "kwok"
+	// recordingFormatKubectl is the full objects one version after another, as `kubectl get -o yaml --watch` prints them
+	recordingFormatKubectl = "kubectl"
+)
+
+// The types of the events of `kubectl get --watch --output-watch-events`.
+const (
+	watchEventAdded    = "ADDED"
+	watchEventModified = "MODIFIED"
+	watchEventDeleted  = "DELETED"
+)
+
+// watchDocument is a version of an object of a stream of full objects, with the type of its event if known,
+// or a gap of the revisions compacted away from the watch of the stream, without an object.
+type watchDocument struct {
+	eventType string
+	obj       *unstructured.Unstructured
+	gap       *client.Gap
+}
+
+// decodeWatchDocuments decodes the documents of a stream of full objects in YAML or JSON, the ones of `kubectl get --watch`
+// with or without --output-watch-events, or of the outputs of kectl. The documents without an object, such as the bookmarks, are skipped,
+// except for the gaps of the outputs of kectl.
+// The deletions of the outputs of kectl carry the object as it was before them, and are told apart by their headers.
+func decodeWatchDocuments(r io.Reader, visitFunc func(doc watchDocument) error) error {
+	br := bufio.NewReader(r)
+	var next func() (m map[string]interface{}, eventType string, gap *client.Gap, err error)
+	if isJSONStream(br) {
+		d := json.NewDecoder(br)
+		next = func() (m map[string]interface{}, eventType string, gap *client.Gap, err error) {
+			var raw json.RawMessage
+			err = d.Decode(&raw)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			var line jsonLine
+			if json.Unmarshal(raw, &line) == nil && line.Gap != nil {
+				return nil, "", line.Gap, nil
+			}
+			err = json.Unmarshal(raw, &m)
+			return m, "", nil, err
+		}
+	} else {
+		// The documents are read with their comments, the headers of the outputs of kectl
+		d := utilyaml.NewYAMLReader(br)
+		next = func() (m map[string]interface{}, eventType string, gap *client.Gap, err error) {
+			doc, err := d.Read()
+			if err != nil {
+				return nil, "", nil, err
+			}
+			if gap, ok := parseGapHeader(documentHeader(doc)); ok {
+				return nil, "", &gap, nil
+			}
+			err = yaml.Unmarshal(doc, &m)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			if isDeletedDocument(doc) {
+				eventType = watchEventDeleted
+			}
+			return m, eventType, nil, nil
+		}
+	}
+	for {
+		m, eventType, gap, err := next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if gap != nil {
+			err = visitFunc(watchDocument{gap: gap})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if len(m) == 0 {
+			continue
+		}
+
+		if _, ok := m["kind"]; !ok {
+			// The events of kubectl, and the lines of the jsonl output of kectl
+			object, ok := m["object"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			eventType, _ = m["type"].(string)
+			if deleted, _ := m["deleted"].(bool); deleted {
+				eventType = watchEventDeleted
+			}
+			m = object
+		}
+
+		obj := &unstructured.Unstructured{Object: m}
+		if !obj.IsList() {
+			err = visitFunc(watchDocument{eventType: eventType, obj: obj})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		items, err := obj.ToList()
+		if err != nil {
+			return err
+		}
+		for i := range items.Items {
+			err = visitFunc(watchDocument{eventType: eventType, obj: &items.Items[i]})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// isDeletedDocument returns whether the header of the document of an output of kectl says it is a deletion.
+func isDeletedDocument(doc []byte) bool {
+	line := documentHeader(doc)
+	return strings.HasPrefix(line, "# ") && strings.HasSuffix(line, " | deleted")
+}
+
+// deletedDocumentKey returns the key in the header of a document of a deletion, the first field of it.
+func deletedDocumentKey(doc []byte) string {
+	key, _, _ := strings.Cut(strings.TrimPrefix(documentHeader(doc), "# "), " | ")
+	return key
+}
+
+// documentHeader returns the first line of a document, its header if it is one of the outputs of kectl.
+func documentHeader(doc []byte) string {
+	for _, line := range strings.Split(string(doc), "\n") {
+		if line == "" || line == "---" {
+			continue
+		}
+		return line
+	}
+	return ""
+}
+
+// versionTime returns the latest time the object says it was written at, from its creation, deletion and managed fields,
+// or the zero time if it says none.
+func versionTime(obj *unstructured.Unstructured) time.Time {
+	t := obj.GetCreationTimestamp().Time
+	if deletion := obj.GetDeletionTimestamp(); deletion != nil && deletion.After(t) {
+		t = deletion.Time
+	}
+	for _, field := range obj.GetManagedFields() {
+		if field.Time != nil && field.Time.After(t) {
+			t = field.Time.Time
+		}
+	}
+	return t
+}
+
+// comparableJSON returns the object in JSON without the resourceVersion and the managed fields, which any write of it changes.
+func comparableJSON(obj *unstructured.Unstructured) ([]byte, error) {
+	obj = obj.DeepCopy()
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+	return obj.MarshalJSON()
+}
+
+// convertToKwokRecording converts a stream of full objects into a recording of `kwokctl snapshot record`.
+// The objects it starts with, up to the first document of an object seen before or of a change event, are the objects of the recording,
+// the first version of any other object is created, and the next ones are patched from the version before them.
+// The deletions are only told apart from the updates by the events of --output-watch-events.
+// The duration of a change is from the times of the managed fields of the version, since the latest one of the objects it starts with.
+// After maxPatchChain patches to an object since it is created or checkpointed, the object is checkpointed as a whole, 0 for never.
+// The gaps of the stream are kept in the recording as they are.
+func convertToKwokRecording(r io.Reader, w io.Writer, maxPatchChain int) (objects, changes int, err error) {
+	// state is the last version of the objects, as compared for the patches
+	state := map[snapshotObjectRef][]byte{}
+	// chains is the number of patches to the objects since they are created or checkpointed
+	chains := map[snapshotObjectRef]int{}
+	listing := true
+	var start time.Time
+	var elapsed time.Duration
+	err = decodeWatchDocuments(r, func(doc watchDocument) error {
+		if doc.gap != nil {
+			// The changes compacted away are missing from the recording, which tells where
+			listing = false
+			return printGap(w, "yaml", *doc.gap)
+		}
+		if isKwokResourcePatch(doc.obj) {
+			return fmt.Errorf("the input is a kwok recording already")
+		}
+		ref, gvr, err := snapshotObjectRefOf(doc.obj)
+		if err != nil {
+			return err
+		}
+		before, seen := state[ref]
+		if listing && (seen || (doc.eventType != "" && doc.eventType != watchEventAdded)) {
+			listing = false
+		}
+
+		after, err := comparableJSON(doc.obj)
+		if err != nil {
+			return err
+		}
+		t := versionTime(doc.obj)
+		if listing {
+			if t.After(start) {
+				start = t
+			}
+			state[ref] = after
+			objects++
+			return writeYAMLDocument(w, doc.obj.Object)
+		}
+
+		if !start.IsZero() && t.After(start) {
+			elapsed = max(elapsed, t.Sub(start))
+		}
+		var rp *actionv1alpha1.ResourcePatch
+		switch {
+		case doc.eventType == watchEventDeleted:
+			if !seen {
+				return nil
+			}
+			delete(state, ref)
+			delete(chains, ref)
+			rp = newResourcePatch(gvr, ref, actionv1alpha1.PatchMethodDelete, elapsed)
+			// The object deleted is kept as it was last, for the analyses and the inverse of the recording
+			rp.Template, err = doc.obj.MarshalJSON()
+			if err != nil {
+				return err
+			}
+		case !seen:
+			state[ref] = after
+			rp = newResourcePatch(gvr, ref, actionv1alpha1.PatchMethodCreate, elapsed)
+			rp.Template, err = doc.obj.MarshalJSON()
+			if err != nil {
+				return err
+			}
+		default:
+			patch, err := twoWayPatch(before, after)
+			if err != nil {
+				return fmt.Errorf("patch %s: %w", ref, err)
+			}
+			state[ref] = after
+			// The versions only changing the resourceVersion or the managed fields are not changes
+			if string(patch) == "{}" {
+				return nil
+			}
+			rp = newResourcePatch(gvr, ref, actionv1alpha1.PatchMethodPatch, elapsed)
+			rp.Template = patch
+			chains[ref]++
+		}
+		changes++
+		err = writeYAMLDocument(w, rp)
+		if err != nil {
+			return err
+		}
+		if maxPatchChain <= 0 || chains[ref] < maxPatchChain {
+			return nil
+		}
+		chains[ref] = 0
+		checkpoint := newResourcePatch(gvr, ref, actionv1alpha1.PatchMethodPatch, elapsed)
+		checkpoint.Checkpoint = true
+		checkpoint.Template, err = doc.obj.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		return writeYAMLDocument(w, checkpoint)
+	})
+	return objects, changes, err
+}
+
+// convertToKubectlWatch converts a recording into the stream of full objects of `kubectl get -o yaml --watch`,
+// following the objects through the changes of a kwok recording, as the events of --output-watch-events if events is true.
+// The changes to objects it does not know about before them are warned about to warn and left out.
+func convertToKubectlWatch(r io.Reader, w io.Writer, events bool, warn io.Writer) (count int, err error) {
+	state := map[snapshotObjectRef]*unstructured.Unstructured{}
+	write := func(eventType string, obj *unstructured.Unstructured) error {
+		count++
+		if !events {
+			return writeYAMLDocument(w, obj.Object)
+		}
+		return writeYAMLDocument(w, map[string]interface{}{
+			"type":   eventType,
+			"object": obj.Object,
+		})
+	}
+	err = decodeToUnstructured(r, func(obj *unstructured.Unstructured) error {
+		if !isKwokResourcePatch(obj) {
+			ref, _, err := snapshotObjectRefOf(obj)
+			if err != nil {
+				return err
+			}
+			eventType := watchEventAdded
+			if _, ok := state[ref]; ok {
+				eventType = watchEventModified
+			}
+			state[ref] = obj
+			return write(eventType, obj)
+		}
+
+		rp, err := decodeKwokResourcePatch(obj)
+		if err != nil {
+			return err
+		}
+		ref := snapshotObjectRef{
+			gr:        rp.Resource.GroupResource(),
+			namespace: rp.Target.Namespace,
+			name:      rp.Target.Name,
+		}
+		before := state[ref]
+		switch rp.Method {
+		case actionv1alpha1.PatchMethodCreate:
+			after := &unstructured.Unstructured{}
+			err = after.UnmarshalJSON(rp.Template)
+			if err != nil {
+				return fmt.Errorf("create %s: %w", ref, err)
+			}
+			state[ref] = after
+			return write(watchEventAdded, after)
+		case actionv1alpha1.PatchMethodPatch:
+			if rp.Checkpoint {
+				// The object as of the checkpoint is not a version of its own
+				after := &unstructured.Unstructured{}
+				err = after.UnmarshalJSON(rp.Template)
+				if err != nil {
+					return fmt.Errorf("checkpoint %s: %w", ref, err)
+				}
+				state[ref] = after
+				return nil
+			}
+			if before == nil {
+				fmt.Fprintf(warn, "warning: can not follow the patch of %s, the object is not known before it\n", ref)
+				return nil
+			}
+			data, err := before.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			after, err := applyStrategicPatch(data, rp.Template)
+			if err != nil {
+				return fmt.Errorf("patch %s: %w", ref, err)
+			}
+			state[ref] = after
+			return write(watchEventModified, after)
+		case actionv1alpha1.PatchMethodDelete:
+			deleted := before
+			if len(rp.Template) != 0 {
+				deleted = &unstructured.Unstructured{}
+				err = deleted.UnmarshalJSON(rp.Template)
+				if err != nil {
+					return fmt.Errorf("delete %s: %w", ref, err)
+				}
+			}
+			if deleted == nil {
+				fmt.Fprintf(warn, "warning: can not follow the delete of %s, the object is not known before it\n", ref)
+				return nil
+			}
+			delete(state, ref)
+			return write(watchEventDeleted, deleted)
+		}
+		return nil
+	})
+	return count, err
+}
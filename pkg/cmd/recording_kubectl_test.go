@@ -0,0 +1,203 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// recordingSteps returns the documents of a recording as the objects by name and the changes by method, name and duration.
+func recordingSteps(t *testing.T, recording string) []string {
+	t.Helper()
+	var steps []string
+	err := decodeToUnstructured(strings.NewReader(recording), func(obj *unstructured.Unstructured) error {
+		if !isKwokResourcePatch(obj) {
+			steps = append(steps, obj.GetName())
+			return nil
+		}
+		rp, err := decodeKwokResourcePatch(obj)
+		if err != nil {
+			return err
+		}
+		step := string(rp.Method) + " " + rp.Target.Name + " " + rp.DurationNanosecond.String()
+		if rp.Checkpoint {
+			step = "checkpoint " + rp.Target.Name + " " + rp.DurationNanosecond.String()
+		} else if rp.Method == "patch" {
+			step += " " + string(rp.Template)
+		}
+		if rp.Method == "delete" && len(rp.Template) != 0 {
+			step += " with the object"
+		}
+		steps = append(steps, step)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return steps
+}
+
+func TestConvertToKwokRecording(t *testing.T) {
+	configMap := func(name, rv, at, value string) string {
+		return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n  namespace: default\n  resourceVersion: \"" + rv + "\"\n" +
+			"  creationTimestamp: \"2024-01-01T00:00:00Z\"\n  managedFields:\n  - manager: kubectl\n    operation: Update\n    time: \"" + at + "\"\n" +
+			"data:\n  key: " + value + "\n"
+	}
+	tests := []struct {
+		name          string
+		input         string
+		maxPatchChain int
+		want          []string
+	}{
+		{
+			name: "full objects",
+			input: strings.Join([]string{
+				configMap("a", "1", "2024-01-01T00:00:00Z", "a0"),
+				configMap("b", "2", "2024-01-01T00:00:10Z", "b0"),
+				configMap("a", "3", "2024-01-01T00:00:15Z", "a1"),
+				configMap("a", "4", "2024-01-01T00:00:20Z", "a1"),
+				configMap("c", "5", "2024-01-01T00:00:30Z", "c0"),
+			}, "---\n"),
+			want: []string{
+				"a",
+				"b",
+				`patch a 5s {"data":{"key":"a1"}}`,
+				"create c 20s",
+			},
+		},
+		{
+			name: "checkpoints",
+			input: strings.Join([]string{
+				configMap("a", "1", "2024-01-01T00:00:00Z", "a0"),
+				configMap("a", "2", "2024-01-01T00:00:01Z", "a1"),
+				configMap("a", "3", "2024-01-01T00:00:02Z", "a2"),
+				configMap("a", "4", "2024-01-01T00:00:03Z", "a3"),
+			}, "---\n"),
+			maxPatchChain: 2,
+			want: []string{
+				"a",
+				`patch a 1s {"data":{"key":"a1"}}`,
+				`patch a 2s {"data":{"key":"a2"}}`,
+				"checkpoint a 2s",
+				`patch a 3s {"data":{"key":"a3"}}`,
+			},
+		},
+		{
+			name: "watch events",
+			input: "type: ADDED\nobject:\n" + indent(configMap("a", "1", "2024-01-01T00:00:00Z", "a0")) +
+				"---\ntype: ADDED\nobject:\n" + indent(configMap("b", "2", "2024-01-01T00:00:00Z", "b0")) +
+				"---\ntype: DELETED\nobject:\n" + indent(configMap("a", "3", "2024-01-01T00:00:02Z", "a0")) +
+				"---\ntype: ADDED\nobject:\n" + indent(configMap("a", "4", "2024-01-01T00:00:01Z", "a1")),
+			want: []string{
+				"a",
+				"b",
+				"delete a 2s with the object",
+				"create a 2s",
+			},
+		},
+		{
+			name: "jsonl output",
+			input: `{"key":"/registry/configmaps/default/a","revision":1,"object":{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"}}}
+{"revision":1,"bookmark":"2024-01-01T00:00:00Z"}
+{"key":"/registry/configmaps/default/a","revision":2,"deleted":true,"object":{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"}}}
+`,
+			want: []string{
+				"a",
+				"delete a 0s with the object",
+			},
+		},
+		{
+			name: "yaml output",
+			input: "---\n# /registry/configmaps/default/a | application/yaml\n" + configMap("a", "1", "2024-01-01T00:00:00Z", "a0") +
+				"---\n# bookmark | 1 | 2024-01-01T00:00:00Z\n" +
+				"---\n# /registry/configmaps/default/a | application/yaml | deleted\n" + configMap("a", "1", "2024-01-01T00:00:00Z", "a0"),
+			want: []string{
+				"a",
+				"delete a 0s with the object",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			_, _, err := convertToKwokRecording(strings.NewReader(tt.input), buf, tt.maxPatchChain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := recordingSteps(t, buf.String()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("converted %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertToKubectlWatch(t *testing.T) {
+	recording := strings.Join([]string{
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: default\ndata:\n  key: a0\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: a\n  namespace: default\ndurationNanosecond: 1000\nmethod: patch\ntemplate:\n  data:\n    key: a1\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: b\n  namespace: default\ndurationNanosecond: 2000\nmethod: patch\ntemplate:\n  data:\n    key: b1\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: a\n  namespace: default\ndurationNanosecond: 1000\nmethod: patch\ncheckpoint: true\ntemplate:\n  apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: a\n    namespace: default\n  data:\n    key: a1\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: c\n  namespace: default\ndurationNanosecond: 3000\nmethod: create\ntemplate:\n  apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: c\n    namespace: default\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: a\n  namespace: default\ndurationNanosecond: 4000\nmethod: delete\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: d\n  namespace: default\ndurationNanosecond: 5000\nmethod: delete\ntemplate:\n  apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: d\n    namespace: default\n  data:\n    key: d0\n",
+	}, "---\n")
+
+	watch := bytes.NewBuffer(nil)
+	warn := bytes.NewBuffer(nil)
+	count, err := convertToKubectlWatch(strings.NewReader(recording), watch, true, warn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 || !strings.Contains(warn.String(), "patch of configmaps/default/b") {
+		t.Errorf("converted %d versions, warned %q", count, warn.String())
+	}
+	var events []string
+	err = decodeWatchDocuments(bytes.NewReader(watch.Bytes()), func(doc watchDocument) error {
+		data, _, _ := unstructured.NestedString(doc.obj.Object, "data", "key")
+		events = append(events, doc.eventType+" "+doc.obj.GetName()+" "+data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The object deleted is known from the delete, not only from the recording before it
+	want := []string{"ADDED a a0", "MODIFIED a a1", "ADDED c ", "DELETED a a1", "DELETED d d0"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events %q, want %q", events, want)
+	}
+
+	// The events are converted back into the changes they were converted from
+	kwok := bytes.NewBuffer(nil)
+	_, _, err = convertToKwokRecording(bytes.NewReader(watch.Bytes()), kwok, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := recordingSteps(t, kwok.String())
+	wantSteps := []string{"a", `patch a 0s {"data":{"key":"a1"}}`, "create c 0s", "delete a 0s with the object"}
+	if !reflect.DeepEqual(got, wantSteps) {
+		t.Errorf("converted back %q, want %q", got, wantSteps)
+	}
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(strings.TrimSuffix(s, "\n"), "\n", "\n  ") + "\n"
+}
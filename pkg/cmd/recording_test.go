@@ -0,0 +1,127 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testRecording = `---
+# /registry/pods/default/a | application/vnd.kubernetes.protobuf | 10
+apiVersion: v1
+kind: Pod
+---
+# bookmark | 10 | 2024-01-01T00:00:00Z
+---
+# /registry/pods/default/a | application/vnd.kubernetes.protobuf | 12
+apiVersion: v1
+kind: Pod
+metadata: {}
+---
+# /registry/minions/node0 | application/vnd.kubernetes.protobuf | 15
+kind: Node
+---
+# /registry/pods/default/a | 21 | deleted
+/registry/pods/default/b | 22
+`
+
+func TestReadRecordingDocuments(t *testing.T) {
+	var got []recordingDocument
+	err := readRecordingDocuments(strings.NewReader(testRecording), "/registry", func(doc recordingDocument) error {
+		got = append(got, doc)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []recordingDocument{
+		{Key: "/registry/pods/default/a", Revision: 10, Bytes: 25},
+		{Key: "/registry/pods/default/a", Revision: 12, Bytes: 38},
+		{Key: "/registry/minions/node0", Revision: 15, Bytes: 11},
+		{Key: "/registry/pods/default/a", Revision: 21, Deleted: true},
+		{Key: "/registry/pods/default/b", Revision: 22},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readRecordingDocuments() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRecordingHeat(t *testing.T) {
+	newHeat := func(window int64) *recordingHeat {
+		heat := newRecordingHeat("/registry", window)
+		err := readRecordingDocuments(strings.NewReader(testRecording), "/registry", heat.add)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return heat
+	}
+
+	stats := newHeat(0).stats(1)
+	wantResources := []resourceHeat{
+		{Resource: "pods", Objects: 2, Writes: 3, Deletes: 1, Bytes: 63},
+		{Resource: "nodes", Objects: 1, Writes: 1, Bytes: 11},
+	}
+	if !reflect.DeepEqual(stats.Resources, wantResources) {
+		t.Errorf("stats() resources = %+v, want %+v", stats.Resources, wantResources)
+	}
+	wantObjects := []objectHeat{
+		{Key: "/registry/pods/default/a", Writes: 2, Deletes: 1, Bytes: 63},
+	}
+	if !reflect.DeepEqual(stats.Objects, wantObjects) {
+		t.Errorf("stats() objects = %+v, want %+v", stats.Objects, wantObjects)
+	}
+
+	tests := []struct {
+		name   string
+		window int64
+		weight string
+		want   string
+	}{
+		{
+			name:   "bytes",
+			weight: "bytes",
+			want: `nodes;node0 11
+pods;default;a 63
+`,
+		},
+		{
+			name:   "count by revision window",
+			window: 10,
+			weight: "count",
+			want: `revisions 10-19;nodes;node0 1
+revisions 10-19;pods;default;a 2
+revisions 20-29;pods;default;a 1
+revisions 20-29;pods;default;b 1
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := newHeat(tt.window).writeFlame(&buf, tt.weight)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeFlame() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"text/tabwriter"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type verifyRecordingFlagpole struct {
+	Output string
+	Prefix string
+}
+
+func newCtlVerifyRecordingCommand() *cobra.Command {
+	flags := &verifyRecordingFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "verify-recording <path>",
+		Short: "Verifies that a recording is neither truncated nor corrupted",
+		Long: "Verifies that a recording is neither truncated nor corrupted, - for stdin, before it is put or replayed.\n" +
+			"A recording of the v2 format is verified frame by frame against the checksums of its chunks and its index,\n" +
+			"the others are decoded through, which tells a truncated compression, record or document but not a changed value.\n" +
+			"It fails if any problem is found.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := verifyRecordingCommand(flags, args[0])
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "output format. One of: (table, json).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+
+	return cmd
+}
+
+// recordingVerification is what verifying a recording found.
+type recordingVerification struct {
+	Format string `json:"format"`
+	// Indexed is whether the index of a v2 recording is found after its chunks
+	Indexed   bool  `json:"indexed,omitempty"`
+	Chunks    int   `json:"chunks,omitempty"`
+	Checksums int   `json:"checksums,omitempty"`
+	Documents int64 `json:"documents"`
+	// Problems are the truncations and corruptions found, the recording is intact without any
+	Problems []string `json:"problems,omitempty"`
+}
+
+func (v *recordingVerification) problem(format string, args ...any) {
+	v.Problems = append(v.Problems, fmt.Sprintf(format, args...))
+}
+
+// verifyRecording verifies the recording read from the reader as it is stored.
+func verifyRecording(r io.Reader, prefix string) (*recordingVerification, error) {
+	br := bufio.NewReader(r)
+	if isRecordingV2(br) {
+		v := &recordingVerification{
+			Format: recordingFormatV2,
+		}
+		err := verifyRecordingV2(br, prefix, v)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
+	v := &recordingVerification{
+		Format: recordingFormatV1,
+	}
+	if isRecordingBinary(br) {
+		v.Format = recordingFormatBinary
+	}
+	d, err := decompress(br)
+	if err != nil {
+		v.problem("%v", err)
+		return v, nil
+	}
+	defer d.Close()
+
+	// The documents are counted as they would be indexed in the v2 format, while they are decoded
+	indexer, err := newRecordingV2Writer(io.Discard, prefix)
+	if err != nil {
+		return nil, err
+	}
+	indexer.chunkSize = math.MaxInt
+	err = decodeToUnstructured(io.TeeReader(d, indexer), func(obj *unstructured.Unstructured) error {
+		return nil
+	})
+	if err != nil {
+		v.problem("truncated or corrupted after %d documents: %v", indexer.current.Documents, err)
+	}
+	v.Documents = indexer.current.Documents
+	return v, nil
+}
+
+// verifyRecordingV2 reads the frames of a v2 recording in order, checking each chunk is whole and decompresses
+// to the revisions of its header, then checks them against the index after the end frame.
+func verifyRecordingV2(br *bufio.Reader, prefix string, v *recordingVerification) error {
+	_, err := br.Discard(len(recordingV2Magic))
+	if err != nil {
+		return err
+	}
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return err
+	}
+	defer decoder.Close()
+
+	offset := int64(len(recordingV2Magic))
+	header := make([]byte, recordingV2HeaderSize)
+	var chunks []recordingV2Chunk
+	var buf bytes.Buffer
+	var decoded []byte
+	for {
+		n, err := io.ReadFull(br, header)
+		if err != nil {
+			if n == 0 && errors.Is(err, io.EOF) {
+				v.problem("no end frame at %d, the recording is cut short or still being written", offset)
+			} else {
+				v.problem("truncated frame header at %d", offset)
+			}
+			v.Chunks = len(chunks)
+			return nil
+		}
+		length := binary.BigEndian.Uint64(header[0:])
+		if length == 0 {
+			offset += recordingV2HeaderSize
+			break
+		}
+
+		got, err := readRecordingV2Chunk(br, &buf, length)
+		if err != nil {
+			v.problem("truncated chunk at %d, %d of its %d bytes", offset, got, length)
+			v.Chunks = len(chunks)
+			return nil
+		}
+		sum := sha256.Sum256(buf.Bytes())
+		chunk := recordingV2Chunk{
+			Offset:       offset,
+			Length:       int64(length),
+			FromRevision: int64(binary.BigEndian.Uint64(header[8:])),
+			ToRevision:   int64(binary.BigEndian.Uint64(header[16:])),
+			SHA256:       hex.EncodeToString(sum[:]),
+		}
+		decoded, err = decoder.DecodeAll(buf.Bytes(), decoded[:0])
+		if err != nil {
+			v.problem("corrupted chunk at %d: %v", offset, err)
+		} else {
+			indexer, err := newRecordingV2Writer(io.Discard, prefix)
+			if err != nil {
+				return err
+			}
+			indexer.chunkSize = math.MaxInt
+			_, _ = indexer.Write(decoded)
+			chunk.Documents = indexer.current.Documents
+			if indexer.current.FromRevision != chunk.FromRevision || indexer.current.ToRevision != chunk.ToRevision {
+				v.problem("chunk at %d holds the revisions %d-%d, not the %d-%d of its header",
+					offset, indexer.current.FromRevision, indexer.current.ToRevision, chunk.FromRevision, chunk.ToRevision)
+			}
+		}
+		v.Documents += chunk.Documents
+		chunks = append(chunks, chunk)
+		offset += recordingV2HeaderSize + chunk.Length
+	}
+	v.Chunks = len(chunks)
+
+	rest, err := io.ReadAll(br)
+	if err != nil {
+		return err
+	}
+	if len(rest) < recordingV2TrailerSize || !bytes.Equal(rest[len(rest)-len(recordingV2IndexMagic):], recordingV2IndexMagic) {
+		v.problem("no index after the end frame at %d", offset-recordingV2HeaderSize)
+		return nil
+	}
+	trailer := rest[len(rest)-recordingV2TrailerSize:]
+	indexOffset := int64(binary.BigEndian.Uint64(trailer[0:]))
+	indexLength := int64(binary.BigEndian.Uint64(trailer[8:]))
+	if indexOffset != offset || indexLength != int64(len(rest)-recordingV2TrailerSize) {
+		v.problem("index trailer points at %d with %d bytes, not at %d with %d bytes",
+			indexOffset, indexLength, offset, len(rest)-recordingV2TrailerSize)
+		return nil
+	}
+	var index recordingV2Index
+	err = json.Unmarshal(rest[:indexLength], &index)
+	if err != nil {
+		v.problem("corrupted index at %d: %v", offset, err)
+		return nil
+	}
+	v.Indexed = true
+
+	if len(index.Chunks) != len(chunks) {
+		v.problem("index lists %d chunks, the recording has %d", len(index.Chunks), len(chunks))
+	}
+	for i := range min(len(index.Chunks), len(chunks)) {
+		indexed, chunk := index.Chunks[i], chunks[i]
+		if indexed.Offset != chunk.Offset || indexed.Length != chunk.Length {
+			v.problem("chunk %d is indexed at %d with %d bytes, found at %d with %d bytes",
+				i, indexed.Offset, indexed.Length, chunk.Offset, chunk.Length)
+			continue
+		}
+		if indexed.Documents != chunk.Documents {
+			v.problem("chunk %d at %d is indexed with %d documents, holds %d", i, chunk.Offset, indexed.Documents, chunk.Documents)
+		}
+		// The recordings written before the checksums were added are verified by their structure alone
+		if indexed.SHA256 == "" {
+			continue
+		}
+		if indexed.SHA256 != chunk.SHA256 {
+			v.problem("chunk %d at %d does not match its checksum", i, chunk.Offset)
+			continue
+		}
+		v.Checksums++
+	}
+	if index.Documents != v.Documents {
+		v.problem("index lists %d documents, the recording has %d", index.Documents, v.Documents)
+	}
+	return nil
+}
+
+func verifyRecordingCommand(flags *verifyRecordingFlagpole, path string) error {
+	if flags.Output != "table" && flags.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+	src, err := openRecordingSource(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	v, err := verifyRecording(src, flags.Prefix)
+	if err != nil {
+		return err
+	}
+
+	switch flags.Output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(v)
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintf(w, "FORMAT\t%s\n", v.Format)
+		if v.Format == recordingFormatV2 {
+			fmt.Fprintf(w, "INDEXED\t%t\n", v.Indexed)
+			fmt.Fprintf(w, "CHUNKS\t%d\n", v.Chunks)
+			fmt.Fprintf(w, "CHECKSUMS\t%d/%d\n", v.Checksums, v.Chunks)
+		}
+		fmt.Fprintf(w, "DOCUMENTS\t%d\n", v.Documents)
+		for _, problem := range v.Problems {
+			fmt.Fprintf(w, "PROBLEM\t%s\n", problem)
+		}
+		err = w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	if len(v.Problems) != 0 {
+		return fmt.Errorf("%d problems found in the recording", len(v.Problems))
+	}
+	return nil
+}
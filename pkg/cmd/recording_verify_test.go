@@ -0,0 +1,125 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.kr2")
+	trace := revisionTrace(1, 100)
+	writeRecordingV2(t, path, trace, 512)
+	v2, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := readRecordingV2Index(bytes.NewReader(v2), int64(len(v2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Chunks) < 2 {
+		t.Fatalf("want several chunks, got %d", len(index.Chunks))
+	}
+	corrupted := bytes.Clone(v2)
+	corrupted[index.Chunks[1].Offset+recordingV2HeaderSize+index.Chunks[1].Length/2] ^= 0xff
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, _ = gw.Write([]byte(trace))
+	_ = gw.Close()
+
+	tests := []struct {
+		name          string
+		data          []byte
+		wantFormat    string
+		wantIndexed   bool
+		wantChecksums int
+		wantProblems  bool
+	}{
+		{
+			name:          "v2",
+			data:          v2,
+			wantFormat:    recordingFormatV2,
+			wantIndexed:   true,
+			wantChecksums: len(index.Chunks),
+		},
+		{
+			name:         "v2 cut in a chunk",
+			data:         v2[:index.Chunks[1].Offset+recordingV2HeaderSize+3],
+			wantFormat:   recordingFormatV2,
+			wantProblems: true,
+		},
+		{
+			name:         "v2 without index",
+			data:         v2[:index.Chunks[len(index.Chunks)-1].Offset+recordingV2HeaderSize+index.Chunks[len(index.Chunks)-1].Length],
+			wantFormat:   recordingFormatV2,
+			wantProblems: true,
+		},
+		{
+			name:          "v2 corrupted chunk",
+			data:          corrupted,
+			wantFormat:    recordingFormatV2,
+			wantIndexed:   true,
+			wantChecksums: len(index.Chunks) - 1,
+			wantProblems:  true,
+		},
+		{
+			name:       "v1",
+			data:       []byte(trace),
+			wantFormat: recordingFormatV1,
+		},
+		{
+			name:       "v1 gzip",
+			data:       gz.Bytes(),
+			wantFormat: recordingFormatV1,
+		},
+		{
+			name:         "v1 gzip cut",
+			data:         gz.Bytes()[:gz.Len()/2],
+			wantFormat:   recordingFormatV1,
+			wantProblems: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := verifyRecording(bytes.NewReader(tt.data), "/registry")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Format != tt.wantFormat {
+				t.Errorf("format = %s, want %s", v.Format, tt.wantFormat)
+			}
+			if v.Indexed != tt.wantIndexed {
+				t.Errorf("indexed = %t, want %t", v.Indexed, tt.wantIndexed)
+			}
+			if v.Checksums != tt.wantChecksums {
+				t.Errorf("checksums = %d, want %d", v.Checksums, tt.wantChecksums)
+			}
+			if (len(v.Problems) != 0) != tt.wantProblems {
+				t.Errorf("problems = %q, wantProblems %t", v.Problems, tt.wantProblems)
+			}
+			if !tt.wantProblems && v.Documents != 100 {
+				t.Errorf("documents = %d, want 100", v.Documents)
+			}
+		})
+	}
+}
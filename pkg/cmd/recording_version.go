@@ -0,0 +1,189 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	actioninternalversion "github.com/wzshiming/kectl/apis/action/internalversion"
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	actionv1alpha2 "github.com/wzshiming/kectl/apis/action/v1alpha2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type convertRecordingFlagpole struct {
+	ToVersion string
+	Path      string
+}
+
+func newCtlConvertRecordingCommand() *cobra.Command {
+	flags := &convertRecordingFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "convert-recording <path>",
+		Short: "Converts the ResourcePatches of a kwok recording to another version",
+		Long: "Converts the ResourcePatches of a kwok recording, - for stdin, to another version of " + actionv1alpha1.GroupName + ",\n" +
+			"and copies its objects as they are. A recording of v1alpha2 starts with a Recording document telling its version,\n" +
+			"and its ResourcePatches address the objects by their uid as well, filled in from the objects and the creations of the recording.\n" +
+			"v1alpha1 is the version of `kwokctl snapshot record` and replay, without the uids.\n" +
+			"The output is compressed by the .gz or .zst extension of --path.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := convertRecordingCommand(flags, args[0])
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.ToVersion, "to-version", actionv1alpha2.Version, "version to convert the ResourcePatches to. One of: (v1alpha1, v1alpha2).")
+	cmd.Flags().StringVar(&flags.Path, "path", "-", "path of the file to write the converted recording to, - for stdout")
+
+	return cmd
+}
+
+func convertRecordingCommand(flags *convertRecordingFlagpole, input string) (err error) {
+	switch flags.ToVersion {
+	case actionv1alpha1.Version, actionv1alpha2.Version:
+	default:
+		return fmt.Errorf("unsupported version %q. One of: (v1alpha1, v1alpha2)", flags.ToVersion)
+	}
+
+	out := io.Writer(os.Stdout)
+	compression := compressionNone
+	if flags.Path != "-" {
+		file, err := createRecording(flags.Path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, file.Close())
+		}()
+		err = file.Truncate(0)
+		if err != nil {
+			return err
+		}
+		out = file
+		compression = compressionFromPath(flags.Path)
+	}
+	w, err := newCompressWriter(out, compression, 0)
+	if err != nil {
+		return err
+	}
+	if w != nil {
+		out = w
+	}
+
+	c := newRecordingVersionConverter(out, flags.ToVersion)
+	err = decodeFile(input, c.convert)
+	if err == nil && w != nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "convert %d objects and %d changes to %s\n", c.objects, c.changes, flags.ToVersion)
+	return nil
+}
+
+// recordingVersionConverter converts the ResourcePatches of a recording to a version,
+// following the uids of the objects to address the ResourcePatches of v1alpha2 by.
+type recordingVersionConverter struct {
+	encode  func(obj interface{}) error
+	version string
+	uids    map[snapshotObjectRef]types.UID
+
+	objects int
+	changes int
+}
+
+func newRecordingVersionConverter(w io.Writer, version string) *recordingVersionConverter {
+	c := &recordingVersionConverter{
+		version: version,
+		uids:    map[snapshotObjectRef]types.UID{},
+	}
+	if version == actionv1alpha2.Version {
+		c.encode = actionv1alpha2.NewEncoder(w).Encode
+	} else {
+		c.encode = actionv1alpha1.NewEncoder(w).Encode
+	}
+	return c
+}
+
+func (c *recordingVersionConverter) convert(obj *unstructured.Unstructured) error {
+	if !isKwokResourcePatch(obj) {
+		// The uids of the objects are followed for the ResourcePatches to address them by
+		if ref, _, err := snapshotObjectRefOf(obj); err == nil && obj.GetUID() != "" {
+			c.uids[ref] = obj.GetUID()
+		}
+		c.objects++
+		return c.encode(obj.Object)
+	}
+
+	rp, err := decodeInternalResourcePatch(obj)
+	if err != nil {
+		return err
+	}
+	ref := snapshotObjectRef{
+		gr:        schema.GroupResource{Group: rp.Resource.Group, Resource: rp.Resource.Resource},
+		namespace: rp.Target.Namespace,
+		name:      rp.Target.Name,
+	}
+	switch rp.Method {
+	case actioninternalversion.PatchMethodCreate:
+		created := &unstructured.Unstructured{}
+		if rp.Target.UID == "" && created.UnmarshalJSON(rp.Template) == nil {
+			rp.Target.UID = created.GetUID()
+		}
+		c.uids[ref] = rp.Target.UID
+	case actioninternalversion.PatchMethodDelete:
+		deleted := &unstructured.Unstructured{}
+		if rp.Target.UID == "" && len(rp.Template) != 0 && deleted.UnmarshalJSON(rp.Template) == nil {
+			rp.Target.UID = deleted.GetUID()
+		}
+		if rp.Target.UID == "" {
+			rp.Target.UID = c.uids[ref]
+		}
+		delete(c.uids, ref)
+	default:
+		if rp.Target.UID == "" {
+			rp.Target.UID = c.uids[ref]
+		}
+	}
+
+	var out runtime.Object
+	if c.version == actionv1alpha2.Version {
+		out = &actionv1alpha2.ResourcePatch{}
+	} else {
+		out = &actionv1alpha1.ResourcePatch{}
+	}
+	err = actionScheme.Convert(rp, out, nil)
+	if err != nil {
+		return err
+	}
+	c.changes++
+	return c.encode(out)
+}
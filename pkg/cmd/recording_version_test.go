@@ -0,0 +1,151 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestConvertRecording(t *testing.T) {
+	dir := t.TempDir()
+	recording := filepath.Join(dir, "recording.yaml")
+	docs := []string{
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: default\n  uid: uid-a\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: a\n  namespace: default\ndurationNanosecond: 1000\nmethod: delete\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: a\n  namespace: default\ndurationNanosecond: 2000\nmethod: create\ntemplate:\n  apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: a\n    namespace: default\n    uid: uid-b\n",
+		"apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: a\n  namespace: default\ndurationNanosecond: 3000\nmethod: patch\ntemplate:\n  data:\n    key: value\n",
+	}
+	err := os.WriteFile(recording, []byte(strings.Join(docs, "---\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upgraded := filepath.Join(dir, "upgraded.yaml")
+	err = convertRecordingCommand(&convertRecordingFlagpole{ToVersion: "v1alpha2", Path: upgraded}, recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(upgraded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "---\napiVersion: action.kwok.x-k8s.io/v1alpha2\nkind: Recording\n---\n") {
+		t.Fatalf("the recording does not start with its version:\n%s", data)
+	}
+
+	var got []string
+	err = decodeFile(upgraded, func(obj *unstructured.Unstructured) error {
+		if !isKwokResourcePatch(obj) {
+			got = append(got, obj.GetKind()+" "+obj.GetName())
+			return nil
+		}
+		if obj.GetAPIVersion() != "action.kwok.x-k8s.io/v1alpha2" {
+			t.Errorf("want the ResourcePatch of v1alpha2, got %s", obj.GetAPIVersion())
+		}
+		rp, err := decodeInternalResourcePatch(obj)
+		if err != nil {
+			return err
+		}
+		got = append(got, string(rp.Method)+" "+rp.Target.Name+" "+string(rp.Target.UID))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ConfigMap a", "delete a uid-a", "create a uid-b", "patch a uid-b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	downgraded := filepath.Join(dir, "downgraded.yaml")
+	err = convertRecordingCommand(&convertRecordingFlagpole{ToVersion: "v1alpha1", Path: downgraded}, upgraded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(downgraded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "v1alpha2") || strings.Contains(string(data), "\n  uid: uid-b\n") {
+		t.Errorf("the recording is not of v1alpha1:\n%s", data)
+	}
+	if n := strings.Count(string(data), "kind: ResourcePatch"); n != 3 {
+		t.Errorf("want 3 ResourcePatches, got %d", n)
+	}
+}
+
+func TestDecodeRecordingVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "v1alpha1",
+			data: "apiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource: {version: v1, resource: pods}\ntarget: {name: a}\nmethod: delete\n",
+			want: []string{"delete a"},
+		},
+		{
+			name: "v1alpha2",
+			data: "apiVersion: action.kwok.x-k8s.io/v1alpha2\nkind: Recording\n---\n" +
+				"apiVersion: action.kwok.x-k8s.io/v1alpha2\nkind: ResourcePatch\nresource: {version: v1, resource: pods}\ntarget: {name: a, uid: b}\nmethod: delete\n",
+			want: []string{"delete a"},
+		},
+		{
+			name:    "newer recording",
+			data:    "apiVersion: action.kwok.x-k8s.io/v1alpha3\nkind: Recording\n",
+			wantErr: `unsupported version "action.kwok.x-k8s.io/v1alpha3" of the Recording`,
+		},
+		{
+			name:    "newer resource patch",
+			data:    "apiVersion: action.kwok.x-k8s.io/v1alpha3\nkind: ResourcePatch\nresource: {version: v1, resource: pods}\ntarget: {name: a}\nmethod: delete\n",
+			wantErr: `unsupported version "action.kwok.x-k8s.io/v1alpha3" of the ResourcePatch`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := decodeToUnstructured(strings.NewReader(tt.data), func(obj *unstructured.Unstructured) error {
+				rp, err := decodeKwokResourcePatch(obj)
+				if err != nil {
+					return err
+				}
+				got = append(got, string(rp.Method)+" "+rp.Target.Name)
+				return nil
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("want error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+)
+
+// The recordings, e.g. recording:///tmp/trace.yaml or recording:///tmp/trace.jsonl?prefix=/registry,
+// read as the objects at their end without an etcd.
+func init() {
+	client.RegisterBackend("recording", client.BackendFunc(newRecordingClient))
+}
+
+// newRecordingClient returns a read-only client of the objects at the end of the recording of the only endpoint,
+// stored under the prefix of its query, /registry by default, as the apiserver stores them.
+func newRecordingClient(conf client.Config) (client.Client, error) {
+	if len(conf.Endpoints) != 1 {
+		return nil, fmt.Errorf("exactly one recording endpoint is required, got %d", len(conf.Endpoints))
+	}
+	path, rawQuery, _ := strings.Cut(strings.TrimPrefix(conf.Endpoints[0], "recording://"), "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", conf.Endpoints[0], err)
+	}
+	prefix := query.Get("prefix")
+	if prefix == "" {
+		prefix = "/registry"
+	}
+
+	objects, err := readFinalObjects(path, prefix, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	// The objects are put in order, for the revisions to be the same for the same recording
+	refs := make([]snapshotObjectRef, 0, len(objects))
+	for ref := range objects {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].String() < refs[j].String()
+	})
+
+	store := fake.NewClient()
+	for _, ref := range refs {
+		obj := objects[ref]
+		mediaType, err := client.MediaTypeFromGR(ref.gr)
+		if err != nil {
+			return nil, err
+		}
+		data, err := obj.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		data, err = convertToStorage(data, mediaType)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		err = store.Put(context.Background(), prefix, data,
+			client.WithName(ref.name, ref.namespace),
+			client.WithGR(ref.gr),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return client.ReadOnly(store), nil
+}
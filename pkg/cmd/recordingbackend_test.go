@@ -0,0 +1,75 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRecordingBackend(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "trace.yaml")
+	configMap := func(name string) string {
+		return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n  namespace: default\n"
+	}
+	err := os.WriteFile(input, []byte(
+		"# /registry/configmaps/default/a | application/json | 2\n"+configMap("a")+
+			"---\n# /registry/configmaps/default/b | application/json | 3\n"+configMap("b")+
+			"---\n# /registry/configmaps/default/a | 4 | deleted\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	etcdclient, err := client.NewClient(client.Config{Endpoints: []string{"recording://" + input}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	_, err = etcdclient.Get(context.Background(), "/registry",
+		client.WithGR(schema.GroupResource{Resource: "configmaps"}),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			got = append(got, string(kv.Key))
+			// The objects are stored as the apiserver stores them
+			if !strings.HasPrefix(string(kv.Value), "k8s\x00") {
+				t.Errorf("value of %s = %q, want it in protobuf", kv.Key, kv.Value)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/registry/configmaps/default/b"; strings.Join(got, ",") != want {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+
+	err = etcdclient.Delete(context.Background(), "/registry",
+		client.WithGR(schema.GroupResource{Resource: "configmaps"}),
+		client.WithName("b", "default"),
+	)
+	if !errors.Is(err, client.ErrReadOnly) {
+		t.Errorf("Delete() error = %v, want %v", err, client.ErrReadOnly)
+	}
+}
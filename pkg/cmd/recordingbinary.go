@@ -0,0 +1,255 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// recordingFormatBinary is the key-values with their values as stored, as length-delimited protobuf records,
+// rather than decoded and printed, for the recordings of very large clusters to be smaller and cheaper to write.
+const recordingFormatBinary = "binary"
+
+// A recording of the binary format is:
+//
+//	magic | record...
+//
+// Each record is the varint of its length followed by the protobuf message of the fields below,
+// the key-value of a change, or a bookmark of the revision up to which the recording is complete.
+// The unknown fields are skipped, for the fields to be added to without breaking the readers.
+var recordingBinaryMagic = []byte("KECTLRB1")
+
+const (
+	recordingBinaryFieldKey      protowire.Number = 1
+	recordingBinaryFieldValue    protowire.Number = 2
+	recordingBinaryFieldRevision protowire.Number = 3
+	recordingBinaryFieldDeleted  protowire.Number = 4
+	// recordingBinaryFieldBookmark is the time of a bookmark in unix nanoseconds
+	recordingBinaryFieldBookmark protowire.Number = 5
+)
+
+// recordingBinaryRecord is a record of a binary recording.
+type recordingBinaryRecord struct {
+	key      []byte
+	value    []byte
+	revision int64
+	// deleted is whether the key-value is a deletion, its value is the one before it if known
+	deleted  bool
+	bookmark time.Time
+}
+
+func (r *recordingBinaryRecord) marshal(b []byte) []byte {
+	if len(r.key) != 0 {
+		b = protowire.AppendTag(b, recordingBinaryFieldKey, protowire.BytesType)
+		b = protowire.AppendBytes(b, r.key)
+	}
+	if len(r.value) != 0 {
+		b = protowire.AppendTag(b, recordingBinaryFieldValue, protowire.BytesType)
+		b = protowire.AppendBytes(b, r.value)
+	}
+	if r.revision != 0 {
+		b = protowire.AppendTag(b, recordingBinaryFieldRevision, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(r.revision))
+	}
+	if r.deleted {
+		b = protowire.AppendTag(b, recordingBinaryFieldDeleted, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(true))
+	}
+	if !r.bookmark.IsZero() {
+		b = protowire.AppendTag(b, recordingBinaryFieldBookmark, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(r.bookmark.UnixNano()))
+	}
+	return b
+}
+
+func (r *recordingBinaryRecord) unmarshal(b []byte) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch {
+		case num == recordingBinaryFieldKey && typ == protowire.BytesType:
+			r.key, n = protowire.ConsumeBytes(b)
+		case num == recordingBinaryFieldValue && typ == protowire.BytesType:
+			r.value, n = protowire.ConsumeBytes(b)
+		case num == recordingBinaryFieldRevision && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			r.revision = int64(v)
+		case num == recordingBinaryFieldDeleted && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			r.deleted = protowire.DecodeBool(v)
+		case num == recordingBinaryFieldBookmark && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			r.bookmark = time.Unix(0, int64(v)).UTC()
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// writeRecordingBinaryRecord writes the record with the varint of its length.
+func writeRecordingBinaryRecord(w io.Writer, r *recordingBinaryRecord) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	message := r.marshal(nil)
+	var length [binary.MaxVarintLen64]byte
+	buf.Write(length[:binary.PutUvarint(length[:], uint64(len(message)))])
+	buf.Write(message)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// newRecordingBinaryPrinter returns a response callback that writes the key-values as records of a binary recording.
+// The deletions are written with the value before them if known, as the json and yaml outputs print it.
+func newRecordingBinaryPrinter(w io.Writer) func(kv *client.KeyValue) error {
+	return func(kv *client.KeyValue) error {
+		r := &recordingBinaryRecord{
+			key:      kv.Key,
+			value:    kv.Value,
+			revision: kv.Revision,
+		}
+		if len(kv.Value) == 0 {
+			r.deleted = true
+			r.value = kv.PrevValue
+		}
+		return writeRecordingBinaryRecord(w, r)
+	}
+}
+
+// writeRecordingBinaryBookmark writes a bookmark of the revision up to which the recording is complete, at the time.
+func writeRecordingBinaryBookmark(w io.Writer, revision int64, t time.Time) error {
+	return writeRecordingBinaryRecord(w, &recordingBinaryRecord{
+		revision: revision,
+		bookmark: t.UTC().Truncate(time.Second),
+	})
+}
+
+func isRecordingBinary(br *bufio.Reader) bool {
+	magic, _ := br.Peek(len(recordingBinaryMagic))
+	return bytes.Equal(magic, recordingBinaryMagic)
+}
+
+// recordingBinaryReader reads a binary recording as the documents of a v1 recording with their revisions,
+// decoding the values as get -o yaml prints them, for the readers of recordings to read either.
+type recordingBinaryReader struct {
+	r       *bufio.Reader
+	printer func(kv *client.KeyValue) error
+	buf     bytes.Buffer
+	done    bool
+	// err is the error of the recording, returned again by the reads after it, such as the ones after a peek
+	err error
+}
+
+// newRecordingBinaryReader returns the reader of the records the reader is at, after the magic.
+func newRecordingBinaryReader(r *bufio.Reader) (*recordingBinaryReader, error) {
+	rr := &recordingBinaryReader{
+		r: r,
+	}
+	printer, err := newPrinter(&rr.buf, printerOptions{
+		Output:       "yaml",
+		WithRevision: true,
+		DecodeMode:   decodeModeLenient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rr.printer = printer
+	return rr, nil
+}
+
+// next prints the next record, it is false at the end of the recording.
+func (r *recordingBinaryReader) next() (bool, error) {
+	length, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, fmt.Errorf("truncated recording: %w", err)
+	}
+	if length > maxRecordingLineSize {
+		return false, fmt.Errorf("corrupt recording: record of %d bytes, larger than the %d bytes of the largest one", length, maxRecordingLineSize)
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r.r, data)
+	if err != nil {
+		return false, fmt.Errorf("truncated recording: %w", err)
+	}
+	var record recordingBinaryRecord
+	err = record.unmarshal(data)
+	if err != nil {
+		return false, err
+	}
+
+	if !record.bookmark.IsZero() {
+		return true, printBookmark(&r.buf, "yaml", record.revision, record.bookmark)
+	}
+	kv := &client.KeyValue{
+		Key:      record.key,
+		Value:    record.value,
+		Revision: record.revision,
+	}
+	if record.deleted {
+		kv.Value = nil
+		kv.PrevValue = record.value
+	}
+	return true, r.printer(kv)
+}
+
+func (r *recordingBinaryReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		ok, err := r.next()
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		if !ok {
+			r.done = true
+			return 0, io.EOF
+		}
+	}
+	return r.buf.Read(p)
+}
+
+func (r *recordingBinaryReader) Close() error {
+	return nil
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"google.golang.org/protobuf/encoding/protowire"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRecordingBinaryRecord(t *testing.T) {
+	tests := []recordingBinaryRecord{
+		{key: []byte("/registry/pods/default/a"), value: []byte("k8s\x00value"), revision: 10},
+		{key: []byte("/registry/pods/default/a"), value: []byte("k8s\x00value"), revision: 11, deleted: true},
+		{key: []byte("/registry/pods/default/b"), revision: 12, deleted: true},
+		{revision: 12, bookmark: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, want := range tests {
+		data := want.marshal(nil)
+		// The fields unknown to the reader are skipped
+		data = protowire.AppendTag(data, 100, protowire.BytesType)
+		data = protowire.AppendBytes(data, []byte("unknown"))
+
+		var got recordingBinaryRecord
+		err := got.unmarshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unmarshal() = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestRecordingBinaryTruncated(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	buf.Write(recordingBinaryMagic)
+	printer := newRecordingBinaryPrinter(buf)
+	err := printer(&client.KeyValue{Key: []byte("/registry/configmaps/default/a"), Value: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"}}`), Revision: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	r, err := decompress(bytes.NewReader(data[:len(data)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(r)
+	if err == nil || !strings.Contains(err.Error(), "truncated recording") {
+		t.Errorf("read a truncated recording with %v", err)
+	}
+}
+
+func TestGetCommandRecordingBinary(t *testing.T) {
+	dir := t.TempDir()
+	etcdclient := fake.NewClient()
+	gr := schema.GroupResource{Resource: "configmaps"}
+	for _, name := range []string{"a", "b", "c"} {
+		err := etcdclient.Put(context.Background(), "/registry", []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"`+name+`","namespace":"default"}}`),
+			client.WithGR(gr),
+			client.WithName(name, "default"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := etcdclient.Delete(context.Background(), "/registry",
+		client.WithGR(gr),
+		client.WithName("b", "default"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string, format string) {
+		t.Helper()
+		err := getCommand(context.Background(), etcdclient, &getFlagpole{
+			Output:           "yaml",
+			Prefix:           "/registry",
+			DecodeMode:       "lenient",
+			MaxBandwidth:     "0",
+			RawRevisionRange: "1-",
+			RecordingFormat:  format,
+			Path:             path,
+		}, []string{"configmaps"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(path string) string {
+		t.Helper()
+		r, err := openRecording(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// A binary recording is read as the documents of the v1 recording of the same get
+	v1 := filepath.Join(dir, "trace.yaml")
+	get(v1, recordingFormatV1)
+	for _, name := range []string{"trace.bin", "trace.bin.zst"} {
+		path := filepath.Join(dir, name)
+		get(path, recordingFormatBinary)
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed := !isRecordingBinary(bufio.NewReader(f))
+		f.Close()
+		if compressed != strings.HasSuffix(name, ".zst") {
+			t.Errorf("%s is written compressed %v", name, compressed)
+		}
+		if got, want := read(path), read(v1); got != want {
+			t.Errorf("%s is read as\n%s\nwant\n%s", name, got, want)
+		}
+		info, err := readRecordingInfo(path, "/registry")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Documents != 4 {
+			t.Errorf("%s has %d documents, want 4", name, info.Documents)
+		}
+	}
+
+	// The objects of a binary recording are put
+	path := filepath.Join(dir, "objects.bin")
+	err = getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:          "yaml",
+		Prefix:          "/registry",
+		DecodeMode:      "lenient",
+		MaxBandwidth:    "0",
+		RecordingFormat: recordingFormatBinary,
+		Path:            path,
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := fake.NewClient()
+	err = putCommand(context.Background(), replayed, &putFlagpole{
+		Output:     "none",
+		Path:       path,
+		Prefix:     "/registry",
+		DecodeMode: "strict",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	_, err = replayed.Get(context.Background(), "/registry",
+		client.WithGR(gr),
+		client.WithKeysOnly(),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			keys = append(keys, string(kv.Key))
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/registry/configmaps/default/a,/registry/configmaps/default/c"; strings.Join(keys, ",") != want {
+		t.Errorf("replayed %v, want %s", keys, want)
+	}
+
+	err = getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:          "key",
+		Prefix:          "/registry",
+		DecodeMode:      "lenient",
+		MaxBandwidth:    "0",
+		RecordingFormat: recordingFormatBinary,
+		Path:            filepath.Join(dir, "keys.bin"),
+	}, []string{"configmaps"})
+	if err == nil {
+		t.Errorf("getCommand() of a binary recording with -o key did not fail")
+	}
+}
+
+func FuzzRecordingBinaryReader(f *testing.F) {
+	buf := bytes.NewBuffer(nil)
+	buf.Write(recordingBinaryMagic)
+	printer := newRecordingBinaryPrinter(buf)
+	err := printer(&client.KeyValue{Key: []byte("/registry/configmaps/default/a"), Value: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"}}`), Revision: 2})
+	if err != nil {
+		f.Fatal(err)
+	}
+	err = writeRecordingBinaryBookmark(buf, 3, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add(buf.Bytes())
+	f.Add(buf.Bytes()[:buf.Len()/2])
+	f.Add(append(bytes.Clone(recordingBinaryMagic), "\xff\xff\xff\xff\xff\xff\xff\xff\x7f"...))
+	f.Add(append(bytes.Clone(recordingBinaryMagic), "\x05\x0a\xff\xff\xff\x0f"...))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := decompress(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		defer r.Close()
+		_, _ = io.Copy(io.Discard, r)
+	})
+}
+
+func TestRecordingBinaryCorrupt(t *testing.T) {
+	data := append(bytes.Clone(recordingBinaryMagic), "\xff\xff\xff\xff\xff\xff\xff\xff\x7f"...)
+	r, err := decompress(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(r)
+	if err == nil || !strings.Contains(err.Error(), "corrupt recording") {
+		t.Errorf("read a recording of a record too large with %v", err)
+	}
+
+	v, err := verifyRecording(bytes.NewReader(data), "/registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Problems) == 0 {
+		t.Errorf("verifyRecording() found no problem in a recording of a record too large")
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// The environment variables of the keys of the encrypted recordings,
+// read rather than passed as flags to keep them out of the shell history and the process list.
+const (
+	// recordingPassphraseEnv is the passphrase to encrypt the recordings with, and to decrypt them
+	recordingPassphraseEnv = "KECTL_RECORDING_PASSPHRASE"
+	// recordingIdentityFileEnv is the path of the file of the age identities to decrypt the recordings with
+	recordingIdentityFileEnv = "KECTL_RECORDING_IDENTITY_FILE"
+)
+
+var ageMagic = []byte("age-encryption.org/v1\n")
+
+// newEncryptWriter returns the writer encrypting to w for the age recipients, each an age1 public key or the path of a file of them,
+// or with the passphrase of KECTL_RECORDING_PASSPHRASE. It is nil without encryption.
+func newEncryptWriter(w io.Writer, recipients []string, passphrase bool) (io.WriteCloser, error) {
+	if passphrase {
+		if len(recipients) != 0 {
+			return nil, fmt.Errorf("--encrypt-passphrase can not be used with --encrypt-recipient")
+		}
+		p := os.Getenv(recordingPassphraseEnv)
+		if p == "" {
+			return nil, fmt.Errorf("--encrypt-passphrase needs the passphrase in $%s", recordingPassphraseEnv)
+		}
+		r, err := age.NewScryptRecipient(p)
+		if err != nil {
+			return nil, err
+		}
+		return age.Encrypt(w, r)
+	}
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+
+	var rs []age.Recipient
+	for _, recipient := range recipients {
+		if strings.HasPrefix(recipient, "age1") {
+			r, err := age.ParseX25519Recipient(recipient)
+			if err != nil {
+				return nil, err
+			}
+			rs = append(rs, r)
+			continue
+		}
+		data, err := os.ReadFile(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("recipients file: %w", err)
+		}
+		parsed, err := age.ParseRecipients(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("recipients file %s: %w", recipient, err)
+		}
+		rs = append(rs, parsed...)
+	}
+	return age.Encrypt(w, rs...)
+}
+
+// isRecordingEncrypted returns whether the reader starts with the header of the age format.
+func isRecordingEncrypted(br *bufio.Reader) bool {
+	magic, _ := br.Peek(len(ageMagic))
+	return bytes.Equal(magic, ageMagic)
+}
+
+// decryptRecording returns the reader decrypting the age encrypted recording, with the identities of KECTL_RECORDING_IDENTITY_FILE
+// or the passphrase of KECTL_RECORDING_PASSPHRASE.
+func decryptRecording(r io.Reader) (io.Reader, error) {
+	var identities []age.Identity
+	if path := os.Getenv(recordingIdentityFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("identity file: %w", err)
+		}
+		parsed, err := age.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("identity file %s: %w", path, err)
+		}
+		identities = append(identities, parsed...)
+	}
+	if p := os.Getenv(recordingPassphraseEnv); p != "" {
+		identity, err := age.NewScryptIdentity(p)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("the recording is encrypted, set $%s or $%s to decrypt it", recordingIdentityFileEnv, recordingPassphraseEnv)
+	}
+
+	d, err := age.Decrypt(r, identities...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, fmt.Errorf("the recording is encrypted for other keys: %w", err)
+		}
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+)
+
+func TestEncryptRecording(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte(strings.Repeat("apiVersion: v1\nkind: Secret\n---\n", 100))
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityFile := filepath.Join(dir, "key.txt")
+	err = os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipientsFile := filepath.Join(dir, "recipients.txt")
+	err = os.WriteFile(recipientsFile, []byte("# the team\n"+identity.Recipient().String()+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		recipients   []string
+		passphrase   string
+		encrypt      bool
+		identityFile string
+		wantErr      bool
+		wantReadErr  bool
+	}{
+		{
+			name:         "recipient",
+			recipients:   []string{identity.Recipient().String()},
+			identityFile: identityFile,
+		},
+		{
+			name:         "recipients file",
+			recipients:   []string{recipientsFile},
+			identityFile: identityFile,
+		},
+		{
+			name:       "passphrase",
+			passphrase: "correct horse battery staple",
+			encrypt:    true,
+		},
+		{
+			name:        "without identity",
+			recipients:  []string{identity.Recipient().String()},
+			wantReadErr: true,
+		},
+		{
+			name:       "passphrase and recipient",
+			recipients: []string{identity.Recipient().String()},
+			passphrase: "correct horse battery staple",
+			encrypt:    true,
+			wantErr:    true,
+		},
+		{
+			name:    "missing passphrase",
+			encrypt: true,
+			wantErr: true,
+		},
+		{
+			name:       "invalid recipient",
+			recipients: []string{"age1invalid"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(recordingPassphraseEnv, tt.passphrase)
+			t.Setenv(recordingIdentityFileEnv, tt.identityFile)
+
+			var buf bytes.Buffer
+			w, err := newEncryptWriter(&buf, tt.recipients, tt.encrypt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newEncryptWriter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			// Compressed under the encryption as get writes it
+			c, err := newCompressWriter(w, compressionGzip, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = c.Write(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = w.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(buf.Bytes(), []byte("Secret")) {
+				t.Fatal("the output is not encrypted")
+			}
+
+			r, err := decompress(&buf)
+			if (err != nil) != tt.wantReadErr {
+				t.Fatalf("decompress() error = %v, wantReadErr %v", err, tt.wantReadErr)
+			}
+			if tt.wantReadErr {
+				return
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = r.Close()
+			if !bytes.Equal(got, data) {
+				t.Errorf("decrypted %q, want %q", got, data)
+			}
+		})
+	}
+}
+
+func TestGetPutEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(recordingPassphraseEnv, "correct horse battery staple")
+	input := filepath.Join(dir, "input.yaml")
+	err := os.WriteFile(input, []byte(strings.Join(configMapDocuments(10), "---\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etcdclient := fake.NewClient()
+	err = putCommand(context.Background(), etcdclient, &putFlagpole{
+		Output:     "none",
+		Path:       input,
+		Prefix:     "/registry",
+		DecodeMode: "lenient",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "run.yaml.zst")
+	err = getCommand(context.Background(), etcdclient, &getFlagpole{
+		Output:            "yaml",
+		Prefix:            "/registry",
+		DecodeMode:        "lenient",
+		MaxBandwidth:      "0",
+		Path:              path,
+		EncryptPassphrase: true,
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, ageMagic) {
+		t.Fatalf("%s is not encrypted", path)
+	}
+
+	replayed := fake.NewClient()
+	err = putCommand(context.Background(), replayed, &putFlagpole{
+		Output:     "none",
+		Path:       path,
+		Prefix:     "/registry",
+		DecodeMode: "lenient",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := replayed.Revision(), etcdclient.Revision(); got != want {
+		t.Errorf("replayed %d revisions, want %d", got, want)
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errLocked is returned by the non-blocking locks of a file already locked.
+var errLocked = errors.New("locked")
+
+// recordingFile is a file recorded to by a kectl, locked against another kectl recording to the same path,
+// and locked for each document written, so that the kectl reading it meanwhile only read whole documents.
+type recordingFile struct {
+	*os.File
+	lock *os.File
+	// held is whether the document being written holds the lock of the file
+	held bool
+}
+
+// createRecording opens the file at the path to record to, created if it does not exist,
+// and fails if another kectl is recording to it.
+func createRecording(path string) (*recordingFile, error) {
+	lockPath := path + ".lock"
+	lock, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	err = lockFile(lock, true, false)
+	if err != nil {
+		_ = lock.Close()
+		if errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("%s is being recorded to by another kectl, which holds %s", path, lockPath)
+		}
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		_ = os.Remove(lockPath)
+		_ = lock.Close()
+		return nil, err
+	}
+	return &recordingFile{
+		File: file,
+		lock: lock,
+	}, nil
+}
+
+// Write writes the bytes as a whole document, unless they are a part of the document being written.
+func (f *recordingFile) Write(p []byte) (int, error) {
+	if f.held {
+		return f.File.Write(p)
+	}
+	err := lockFile(f.File, true, true)
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.File.Write(p)
+	return n, errors.Join(err, unlockFile(f.File))
+}
+
+// document holds the lock of the file while the document is written by the writes of fn.
+func (f *recordingFile) document(fn func() error) error {
+	err := lockFile(f.File, true, true)
+	if err != nil {
+		return err
+	}
+	f.held = true
+	err = fn()
+	f.held = false
+	return errors.Join(err, unlockFile(f.File))
+}
+
+// Close closes the file and releases the path to another kectl to record to.
+func (f *recordingFile) Close() error {
+	err := f.File.Close()
+	// The lock file is removed while still held, for another kectl not to lock the one about to be removed
+	_ = os.Remove(f.lock.Name())
+	return errors.Join(err, f.lock.Close())
+}
+
+// openRecording opens the recording at the path to be read up to the end of the last document written,
+// waiting for the one being written by a kectl recording to it rather than reading it half written.
+// The objects of object storages, such as s3://bucket/key, are read as they are downloaded,
+// and the compressed recordings are decompressed.
+func openRecording(path string) (io.ReadCloser, error) {
+	var r io.ReadCloser
+	var err error
+	if isObjectStoragePath(path) {
+		r, err = openObject(context.Background(), path)
+	} else {
+		r, err = openRecordingFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d, err := decompress(r)
+	if err != nil {
+		_ = r.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{d, closers{d, r}}, nil
+}
+
+// openRecordingSource opens the recording at the path as it is stored, without decompressing it, - for stdin.
+func openRecordingSource(path string) (io.ReadCloser, error) {
+	switch {
+	case path == "-":
+		return io.NopCloser(os.Stdin), nil
+	case isObjectStoragePath(path):
+		return openObject(context.Background(), path)
+	default:
+		return openRecordingFile(path)
+	}
+}
+
+// closers closes all of the closers.
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var errs []error
+	for _, closer := range c {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// openRecordingFile opens the local file of the recording at the path.
+func openRecordingFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	err = lockFile(file, false, true)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	info, err := file.Stat()
+	err = errors.Join(err, unlockFile(file))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, info.Size()), file}, nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.yaml")
+	file, err := createRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = createRecording(path)
+	if err == nil || !strings.Contains(err.Error(), "another kectl") {
+		t.Fatalf("createRecording() of the path being recorded to, error = %v", err)
+	}
+
+	opened := make(chan io.ReadCloser, 1)
+	err = file.document(func() error {
+		fmt.Fprint(file, "---\n# /registry/pods/default/a | 1\n")
+		go func() {
+			r, err := openRecording(path)
+			if err != nil {
+				t.Error(err)
+			}
+			opened <- r
+		}()
+		select {
+		case <-opened:
+			t.Error("openRecording() returns in the middle of a document")
+		case <-time.After(100 * time.Millisecond):
+		}
+		_, err := fmt.Fprint(file, "kind: Pod\n")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := <-opened
+	defer r.Close()
+
+	// Written after the recording is opened
+	fmt.Fprint(file, "---\n# /registry/pods/default/b | 2\n")
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "---\n# /registry/pods/default/a | 1\nkind: Pod\n"; string(got) != want {
+		t.Errorf("read %q, want %q", got, want)
+	}
+
+	err = file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err = createRecording(path)
+	if err != nil {
+		t.Fatalf("createRecording() once the path is released, error = %v", err)
+	}
+	_ = file.Close()
+}
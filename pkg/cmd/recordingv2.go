@@ -0,0 +1,447 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The formats of the recordings written to a file.
+const (
+	// recordingFormatV1 is the documents as printed, one after another
+	recordingFormatV1 = "v1"
+	// recordingFormatV2 is the documents in chunks compressed on their own, followed by an index of the chunks,
+	// for a reader to seek to the chunks of the revisions it wants rather than read all those before.
+	recordingFormatV2 = "v2"
+)
+
+// A recording of the v2 format is:
+//
+//	magic | frame... | end frame | index | trailer
+//
+// Each frame is a header of the length of the chunk and its first and last revisions, as big endian 64 bits integers,
+// followed by the chunk, the documents of a v1 recording compressed as a zstd frame. The end frame is a header of zeros.
+// The index is the JSON of recordingV2Index, with the SHA-256 of each compressed chunk,
+// and the trailer its offset and length followed by the magic of the index.
+// The end frame and what follows are only written once the recording is complete, a recording without them
+// is still read in order up to its last whole frame.
+var (
+	recordingV2Magic      = []byte("KECTLRv2")
+	recordingV2IndexMagic = []byte("KECTLIX2")
+)
+
+const (
+	recordingV2HeaderSize  = 24
+	recordingV2TrailerSize = 24
+	// recordingV2ChunkSize is the size of the documents a chunk is cut after
+	recordingV2ChunkSize = 4 << 20
+)
+
+// recordingV2Index is the index of the chunks of a v2 recording, and what they hold.
+type recordingV2Index struct {
+	Documents    int64              `json:"documents"`
+	Bytes        int64              `json:"bytes"`
+	FromRevision int64              `json:"fromRevision,omitempty"`
+	ToRevision   int64              `json:"toRevision,omitempty"`
+	FromTime     string             `json:"fromTime,omitempty"`
+	ToTime       string             `json:"toTime,omitempty"`
+	Chunks       []recordingV2Chunk `json:"chunks"`
+}
+
+// recordingV2Chunk is a chunk of a v2 recording, the revisions and times are the ones of its documents and bookmarks.
+type recordingV2Chunk struct {
+	// Offset is the offset of the frame of the chunk in the file, and Length the length of the compressed chunk after its header.
+	Offset       int64  `json:"offset"`
+	Length       int64  `json:"length"`
+	Documents    int64  `json:"documents"`
+	Bytes        int64  `json:"bytes"`
+	FromRevision int64  `json:"fromRevision,omitempty"`
+	ToRevision   int64  `json:"toRevision,omitempty"`
+	FromTime     string `json:"fromTime,omitempty"`
+	ToTime       string `json:"toTime,omitempty"`
+	// SHA256 is the hex SHA-256 of the compressed chunk, it is empty in the recordings written before it was added.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// overlaps returns whether the chunk may hold documents of the revisions from and to, 0 for no limit.
+// The chunks without revisions, such as the ones of a list, may hold any.
+func (c *recordingV2Chunk) overlaps(from, to int64) bool {
+	if c.FromRevision == 0 {
+		return true
+	}
+	return (from == 0 || c.ToRevision >= from) && (to == 0 || c.FromRevision <= to)
+}
+
+// observe adds the revision and the time of a document or a bookmark to the chunk.
+func (c *recordingV2Chunk) observe(revision int64, t string) {
+	if revision != 0 {
+		if c.FromRevision == 0 || revision < c.FromRevision {
+			c.FromRevision = revision
+		}
+		c.ToRevision = max(c.ToRevision, revision)
+	}
+	if t != "" {
+		if c.FromTime == "" {
+			c.FromTime = t
+		}
+		c.ToTime = t
+	}
+}
+
+// recordingV2Writer writes the documents written to it as a v2 recording, cutting the chunks between documents.
+// The revisions and times of the chunks are the ones of the headers of the documents and of the bookmarks.
+type recordingV2Writer struct {
+	w         io.Writer
+	prefix    string
+	chunkSize int
+	encoder   *zstd.Encoder
+
+	offset  int64
+	line    []byte
+	buf     bytes.Buffer
+	current recordingV2Chunk
+	index   recordingV2Index
+}
+
+func newRecordingV2Writer(w io.Writer, prefix string) (*recordingV2Writer, error) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingV2Writer{
+		w:         w,
+		prefix:    prefix,
+		chunkSize: recordingV2ChunkSize,
+		encoder:   encoder,
+	}, nil
+}
+
+func (w *recordingV2Writer) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) != 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.line = append(w.line, p...)
+			break
+		}
+		line := p[:i+1]
+		if len(w.line) != 0 {
+			line = append(w.line, line...)
+			w.line = w.line[:0]
+		}
+		err := w.writeLine(line)
+		if err != nil {
+			return 0, err
+		}
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (w *recordingV2Writer) writeLine(line []byte) error {
+	text := strings.TrimSuffix(string(line), "\n")
+	if w.buf.Len() >= w.chunkSize && (text == "---" || strings.HasPrefix(text, w.prefix+"/")) {
+		err := w.flushChunk()
+		if err != nil {
+			return err
+		}
+	}
+	if bookmark, ok := strings.CutPrefix(text, "# bookmark | "); ok {
+		revision, t, _ := strings.Cut(bookmark, " | ")
+		rev, _ := strconv.ParseInt(revision, 10, 64)
+		w.current.observe(rev, t)
+	} else if strings.HasPrefix(strings.TrimPrefix(text, "# "), w.prefix+"/") {
+		w.current.Documents++
+		_, revision, _, _ := parseTraceHeader(text, w.prefix)
+		w.current.observe(revision, "")
+	}
+	w.current.Bytes += int64(len(line))
+	w.buf.Write(line)
+	return nil
+}
+
+func (w *recordingV2Writer) writeMagic() error {
+	if w.offset != 0 {
+		return nil
+	}
+	n, err := w.w.Write(recordingV2Magic)
+	w.offset += int64(n)
+	return err
+}
+
+func (w *recordingV2Writer) flushChunk() error {
+	err := w.writeMagic()
+	if err != nil {
+		return err
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	data := w.encoder.EncodeAll(w.buf.Bytes(), nil)
+	w.buf.Reset()
+
+	chunk := w.current
+	w.current = recordingV2Chunk{}
+	chunk.Offset = w.offset
+	chunk.Length = int64(len(data))
+	sum := sha256.Sum256(data)
+	chunk.SHA256 = hex.EncodeToString(sum[:])
+	err = w.writeFrame(uint64(len(data)), chunk.FromRevision, chunk.ToRevision, data)
+	if err != nil {
+		return err
+	}
+
+	w.index.Chunks = append(w.index.Chunks, chunk)
+	w.index.Documents += chunk.Documents
+	w.index.Bytes += chunk.Bytes
+	if chunk.FromRevision != 0 && (w.index.FromRevision == 0 || chunk.FromRevision < w.index.FromRevision) {
+		w.index.FromRevision = chunk.FromRevision
+	}
+	w.index.ToRevision = max(w.index.ToRevision, chunk.ToRevision)
+	if w.index.FromTime == "" {
+		w.index.FromTime = chunk.FromTime
+	}
+	if chunk.ToTime != "" {
+		w.index.ToTime = chunk.ToTime
+	}
+	return nil
+}
+
+func (w *recordingV2Writer) writeFrame(length uint64, from, to int64, data []byte) error {
+	header := make([]byte, recordingV2HeaderSize, recordingV2HeaderSize+len(data))
+	binary.BigEndian.PutUint64(header[0:], length)
+	binary.BigEndian.PutUint64(header[8:], uint64(from))
+	binary.BigEndian.PutUint64(header[16:], uint64(to))
+	// The frame is written at once, for the reader of a recording being written to only see whole frames
+	n, err := w.w.Write(append(header, data...))
+	w.offset += int64(n)
+	return err
+}
+
+// Close writes the last chunk, the end frame and the index, it does not close the writer under it.
+func (w *recordingV2Writer) Close() error {
+	if len(w.line) != 0 {
+		err := w.writeLine(w.line)
+		if err != nil {
+			return err
+		}
+		w.line = nil
+	}
+	err := w.flushChunk()
+	if err != nil {
+		return err
+	}
+	err = w.writeFrame(0, 0, 0, nil)
+	if err != nil {
+		return err
+	}
+
+	if w.index.Chunks == nil {
+		w.index.Chunks = []recordingV2Chunk{}
+	}
+	data, err := json.Marshal(w.index)
+	if err != nil {
+		return err
+	}
+	trailer := make([]byte, recordingV2TrailerSize-len(recordingV2IndexMagic), recordingV2TrailerSize)
+	binary.BigEndian.PutUint64(trailer[0:], uint64(w.offset))
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(data)))
+	trailer = append(trailer, recordingV2IndexMagic...)
+	_, err = w.w.Write(append(data, trailer...))
+	return errors.Join(err, w.encoder.Close())
+}
+
+// isRecordingV2 returns whether the reader starts with the magic of the v2 format.
+func isRecordingV2(br *bufio.Reader) bool {
+	magic, _ := br.Peek(len(recordingV2Magic))
+	return bytes.Equal(magic, recordingV2Magic)
+}
+
+// recordingV2Reader reads the documents of the chunks of a v2 recording in order, from the frame it is at.
+type recordingV2Reader struct {
+	r       io.Reader
+	decoder *zstd.Decoder
+	chunk   []byte
+	buf     bytes.Buffer
+	done    bool
+	// err is the error of the recording, returned again by the reads after it, such as the ones after a peek
+	err error
+}
+
+// readRecordingV2Chunk reads the bytes of the length into the buffer, and returns the number of them read.
+// They are copied rather than read into a buffer of the length, which is not to be trusted before they are read.
+func readRecordingV2Chunk(r io.Reader, buf *bytes.Buffer, length uint64) (int64, error) {
+	buf.Reset()
+	return io.CopyN(buf, r, int64(min(length, math.MaxInt64)))
+}
+
+func newRecordingV2Reader(r io.Reader) (*recordingV2Reader, error) {
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingV2Reader{
+		r:       r,
+		decoder: decoder,
+	}, nil
+}
+
+// next reads the chunk of the next frame, it is false once at the end frame or the end of a recording being written.
+func (r *recordingV2Reader) next() (bool, error) {
+	header := make([]byte, recordingV2HeaderSize)
+	_, err := io.ReadFull(r.r, header)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, fmt.Errorf("truncated recording: %w", err)
+	}
+	length := binary.BigEndian.Uint64(header)
+	if length == 0 {
+		return false, nil
+	}
+	_, err = readRecordingV2Chunk(r.r, &r.buf, length)
+	if err != nil {
+		return false, fmt.Errorf("truncated recording: %w", io.ErrUnexpectedEOF)
+	}
+	r.chunk, err = r.decoder.DecodeAll(r.buf.Bytes(), r.chunk[:0])
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *recordingV2Reader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		ok, err := r.next()
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		if !ok {
+			r.done = true
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+func (r *recordingV2Reader) Close() error {
+	r.decoder.Close()
+	return nil
+}
+
+// readRecordingV2Index reads the index of a complete v2 recording, it is nil for a recording without one,
+// such as one of the v1 format or one still being written.
+func readRecordingV2Index(r io.ReaderAt, size int64) (*recordingV2Index, error) {
+	if size < int64(len(recordingV2Magic)+recordingV2TrailerSize) {
+		return nil, nil
+	}
+	magic := make([]byte, len(recordingV2Magic))
+	_, err := r.ReadAt(magic, 0)
+	if err != nil {
+		return nil, err
+	}
+	trailer := make([]byte, recordingV2TrailerSize)
+	_, err = r.ReadAt(trailer, size-recordingV2TrailerSize)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, recordingV2Magic) || !bytes.Equal(trailer[16:], recordingV2IndexMagic) {
+		return nil, nil
+	}
+	offset := int64(binary.BigEndian.Uint64(trailer[0:]))
+	length := int64(binary.BigEndian.Uint64(trailer[8:]))
+	if offset < 0 || length < 0 || offset+length != size-recordingV2TrailerSize {
+		return nil, fmt.Errorf("invalid index of the recording at %d", offset)
+	}
+	var data bytes.Buffer
+	n, err := readRecordingV2Chunk(io.NewSectionReader(r, offset, length), &data, uint64(length))
+	if err != nil {
+		return nil, fmt.Errorf("truncated index of the recording, %d of its %d bytes: %w", n, length, err)
+	}
+	var index recordingV2Index
+	err = json.Unmarshal(data.Bytes(), &index)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index of the recording: %w", err)
+	}
+	return &index, nil
+}
+
+// openRecordingRevisions opens the recording at the path to read the documents of the revisions from and to, 0 for no limit.
+// The local recordings of the v2 format are read from the chunks that may hold them, found by their index,
+// the others from the start. The documents of other revisions are left to the caller to skip.
+func openRecordingRevisions(path string, from, to int64) (io.ReadCloser, error) {
+	if (from == 0 && to == 0) || path == "-" || isObjectStoragePath(path) {
+		return openRecording(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	index, err := readRecordingV2Index(file, info.Size())
+	if err != nil || index == nil {
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return openRecording(path)
+	}
+
+	var readers []io.Reader
+	for _, chunk := range index.Chunks {
+		if chunk.overlaps(from, to) {
+			readers = append(readers, io.NewSectionReader(file, chunk.Offset, recordingV2HeaderSize+chunk.Length))
+		}
+	}
+	r, err := newRecordingV2Reader(io.MultiReader(readers...))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, closers{r, file}}, nil
+}
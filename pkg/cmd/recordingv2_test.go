@@ -0,0 +1,302 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// revisionTrace returns a trace of the revisions as printed by get --raw-revision-range -o yaml, with a bookmark every 10 revisions.
+func revisionTrace(from, to int64) string {
+	var buf strings.Builder
+	for rev := from; rev <= to; rev++ {
+		fmt.Fprintf(&buf, "---\n# /registry/configmaps/default/c%d | application/json | %d\napiVersion: v1\nkind: ConfigMap\n", rev%7, rev)
+		if rev%10 == 0 {
+			fmt.Fprintf(&buf, "---\n# bookmark | %d | 2024-01-01T00:00:%02dZ\n", rev, rev%60)
+		}
+	}
+	return buf.String()
+}
+
+func writeRecordingV2(t *testing.T, path, trace string, chunkSize int) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	w, err := newRecordingV2Writer(file, "/registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.chunkSize = chunkSize
+	// Written in pieces not aligned to the lines
+	for data := []byte(trace); len(data) != 0; {
+		n := min(len(data), 37)
+		_, err = w.Write(data[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordingV2(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "trace.kr2")
+	trace := revisionTrace(1, 100)
+	writeRecordingV2(t, path, trace, 512)
+
+	r, err := openRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != trace {
+		t.Errorf("openRecording() read a different recording than written")
+	}
+
+	info, err := readRecordingInfo(path, "/registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != recordingFormatV2 || info.Documents != 100 || info.FromRevision != 1 || info.ToRevision != 100 ||
+		info.Bytes != int64(len(trace)) || info.Chunks < 10 || info.FromTime != "2024-01-01T00:00:10Z" || info.ToTime != "2024-01-01T00:00:40Z" {
+		t.Errorf("readRecordingInfo() = %+v", info)
+	}
+
+	// Only the chunks of the revisions are read
+	r, err = openRecordingRevisions(path, 60, 70)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var revisions []int64
+	err = readRecordingDocuments(r, "/registry", func(doc recordingDocument) error {
+		revisions = append(revisions, doc.Revision)
+		return nil
+	})
+	_ = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) == 0 || revisions[0] > 60 || revisions[len(revisions)-1] < 70 || len(revisions) >= 50 {
+		t.Errorf("openRecordingRevisions() read revisions %v, want the chunks of 60-70", revisions)
+	}
+	for i := 1; i < len(revisions); i++ {
+		if revisions[i] != revisions[i-1]+1 {
+			t.Fatalf("openRecordingRevisions() read revisions %v, want them in order without gaps", revisions)
+		}
+	}
+}
+
+func TestRecordingV2Truncated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "trace.kr2")
+	var buf bytes.Buffer
+	w, err := newRecordingV2Writer(&buf, "/registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.chunkSize = 256
+	trace := revisionTrace(1, 30)
+	_, err = w.Write([]byte(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Killed before the last chunk and the index are written
+	err = os.WriteFile(path, buf.Bytes(), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := readRecordingInfo(path, "/registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != recordingFormatV2 || info.Documents == 0 || info.Bytes >= int64(len(trace)) || info.FromRevision != 1 {
+		t.Errorf("readRecordingInfo() = %+v, want the documents of the whole chunks", info)
+	}
+
+	// A chunk cut short fails rather than being read in part
+	err = os.WriteFile(path, buf.Bytes()[:buf.Len()-1], 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = readRecordingInfo(path, "/registry")
+	if err == nil {
+		t.Errorf("readRecordingInfo() of a truncated chunk did not fail")
+	}
+}
+
+func TestRecordingConvert(t *testing.T) {
+	dir := t.TempDir()
+	v1 := filepath.Join(dir, "trace.yaml")
+	trace := revisionTrace(1, 50)
+	err := os.WriteFile(v1, []byte(trace), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v2 := filepath.Join(dir, "trace.kr2")
+	err = recordingConvertCommand(&recordingConvertFlagpole{Format: recordingFormatV2, Prefix: "/registry", Path: v2}, v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := filepath.Join(dir, "back.yaml.zst")
+	err = recordingConvertCommand(&recordingConvertFlagpole{Format: recordingFormatV1, Prefix: "/registry", Path: back}, v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{v1, v2, back} {
+		info, err := readRecordingInfo(path, "/registry")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Documents != 50 || info.FromRevision != 1 || info.ToRevision != 50 {
+			t.Errorf("readRecordingInfo(%s) = %+v", filepath.Base(path), info)
+		}
+	}
+	if got := readRecordingString(t, back); got != trace {
+		t.Errorf("converted back to v1 a different recording")
+	}
+
+	err = recordingConvertCommand(&recordingConvertFlagpole{Format: "v3", Path: "-"}, v1)
+	if err == nil {
+		t.Errorf("recordingConvertCommand() to an unknown format did not fail")
+	}
+}
+
+func readRecordingString(t *testing.T, path string) string {
+	t.Helper()
+	r, err := openRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestGetCommandRecordingV2(t *testing.T) {
+	etcdclient := fake.NewClient()
+	gr := schema.GroupResource{Resource: "configmaps"}
+	for i := 0; i < 20; i++ {
+		err := etcdclient.Put(context.Background(), "/registry", []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"a","namespace":"default"}}`),
+			client.WithGR(gr),
+			client.WithName("a", "default"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "trace.kr2")
+	flags := &getFlagpole{
+		Output:           "yaml",
+		Prefix:           "/registry",
+		DecodeMode:       "lenient",
+		MaxBandwidth:     "0",
+		RawRevisionRange: "1-",
+		RecordingFormat:  recordingFormatV2,
+		Path:             path,
+	}
+	err := getCommand(context.Background(), etcdclient, flags, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := readRecordingInfo(path, "/registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != recordingFormatV2 || info.Documents != 20 || info.ToRevision != etcdclient.Revision() {
+		t.Errorf("readRecordingInfo() = %+v", info)
+	}
+
+	flags.Watch = true
+	flags.RawRevisionRange = ""
+	err = getCommand(context.Background(), etcdclient, flags, []string{"configmaps"})
+	if err == nil {
+		t.Errorf("getCommand() of a v2 recording with --watch did not fail")
+	}
+}
+
+func FuzzRecordingV2Reader(f *testing.F) {
+	var buf bytes.Buffer
+	w, err := newRecordingV2Writer(&buf, "/registry")
+	if err != nil {
+		f.Fatal(err)
+	}
+	w.chunkSize = 256
+	_, err = w.Write([]byte(revisionTrace(1, 20)))
+	if err != nil {
+		f.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add(buf.Bytes())
+	f.Add(buf.Bytes()[:buf.Len()/2])
+	f.Add(append(bytes.Clone(recordingV2Magic), "\x7f\xff\xff\xff\xff\xff\xff\xff\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x01"...))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := decompress(bytes.NewReader(data))
+		if err == nil {
+			_, _ = io.Copy(io.Discard, r)
+			_ = r.Close()
+		}
+		_, _ = readRecordingV2Index(bytes.NewReader(data), int64(len(data)))
+		_, _ = verifyRecording(bytes.NewReader(data), "/registry")
+	})
+}
+
+func TestRecordingV2Corrupt(t *testing.T) {
+	// A frame of a chunk far larger than the recording
+	data := append(bytes.Clone(recordingV2Magic), "\x7f\xff\xff\xff\xff\xff\xff\xff\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x01"...)
+	r, err := decompress(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(r)
+	if err == nil || !strings.Contains(err.Error(), "truncated recording") {
+		t.Errorf("read a recording of a chunk too large with %v", err)
+	}
+}
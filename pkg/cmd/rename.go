@@ -0,0 +1,631 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/validation/path"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type renameFlagpole struct {
+	Namespace        string
+	Output           string
+	Prefix           string
+	ChunkSize        int64
+	AllNamespace     bool
+	Map              []string
+	Regex            string
+	Replacement      string
+	NamespaceMap     []string
+	RewriteSelectors bool
+}
+
+func newCtlRenameCommand() *cobra.Command {
+	flags := &renameFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.RangeArgs(0, 2),
+		Use:   "rename [resource] [name]",
+		Short: "Renames the objects of k8s in etcd, and rewrites the references to them",
+		Long: "Renames the objects of k8s in etcd by --map or --regex, and moves them to other namespaces by --namespace-map,\n" +
+			"such as to consolidate namespaces or to adopt a naming convention in test fixtures. Without a resource, the objects of all resources are renamed.\n" +
+			"Each object is created under its new key, read back to verify it, then deleted from its old key, keeping its uid.\n" +
+			"The ownerReferences to the renamed objects are rewritten, and with --rewrite-selectors the label values equal to an old name\n" +
+			"in the labels, selectors and pod templates of the namespace as well, for the services and workloads to keep selecting the renamed objects.\n" +
+			"An object whose new key exists, or whose namespaced owner is neither moved along with it nor in its new namespace, is left unchanged.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			err = checkLiveControlPlane(cmd.Context(), cmd, etcdclient, flags.Prefix)
+			if err != nil {
+				return err
+			}
+			err = renameCommand(cmd.Context(), etcdclient, flags, args)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "key", "output format. One of: (key, none).")
+	cmd.Flags().StringVarP(&flags.Namespace, "namespace", "n", "", "namespace of resource")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().Int64Var(&flags.ChunkSize, "chunk-size", 500, "chunk size of the list pager")
+	cmd.Flags().BoolVarP(&flags.AllNamespace, "all-namespace", "A", false, "all namespace")
+	cmd.Flags().StringSliceVar(&flags.Map, "map", nil, "names to rename, in the form of old=new")
+	cmd.Flags().StringVar(&flags.Regex, "regex", "", "regular expression of the names to rename, replaced by --replacement")
+	cmd.Flags().StringVar(&flags.Replacement, "replacement", "", "replacement of the names matching --regex, with $1 for the submatches")
+	cmd.Flags().StringSliceVar(&flags.NamespaceMap, "namespace-map", nil, "namespaces to move the objects of, in the form of old=new. The new namespaces must exist.")
+	cmd.Flags().BoolVar(&flags.RewriteSelectors, "rewrite-selectors", false, "also rewrite the label values equal to a renamed name, in the labels, selectors and pod templates of its namespace")
+
+	return cmd
+}
+
+// objectRenamer maps the namespaces and names of the objects to their new ones.
+type objectRenamer struct {
+	names       map[string]string
+	regex       *regexp.Regexp
+	replacement string
+	namespaces  map[string]string
+}
+
+func newObjectRenamer(flags *renameFlagpole) (*objectRenamer, error) {
+	names, err := parseRenameMap("--map", flags.Map)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := parseRenameMap("--namespace-map", flags.NamespaceMap)
+	if err != nil {
+		return nil, err
+	}
+	r := &objectRenamer{
+		names:       names,
+		replacement: flags.Replacement,
+		namespaces:  namespaces,
+	}
+	if flags.Regex != "" {
+		r.regex, err = regexp.Compile(flags.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --regex %q: %w", flags.Regex, err)
+		}
+	}
+	if len(r.names) == 0 && r.regex == nil && len(r.namespaces) == 0 {
+		return nil, fmt.Errorf("one of --map, --regex or --namespace-map is required")
+	}
+	return r, nil
+}
+
+func parseRenameMap(flag string, pairs []string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, pair := range pairs {
+		from, to, ok := strings.Cut(pair, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid %s %q, in the form of old=new", flag, pair)
+		}
+		m[from] = to
+	}
+	return m, nil
+}
+
+// rename returns the new namespace and name of the object, the same ones if it is not renamed.
+func (r *objectRenamer) rename(namespace, name string) (string, string) {
+	if to, ok := r.namespaces[namespace]; ok && namespace != "" {
+		namespace = to
+	}
+	if to, ok := r.names[name]; ok {
+		name = to
+	} else if r.regex != nil && r.regex.MatchString(name) {
+		name = r.regex.ReplaceAllString(name, r.replacement)
+	}
+	return namespace, name
+}
+
+// renameTarget is an object to rename, decoded to be written under its new key.
+type renameTarget struct {
+	from      snapshotObjectRef
+	to        snapshotObjectRef
+	key       string
+	revision  int64
+	mediaType string
+	obj       *unstructured.Unstructured
+}
+
+// renameReferences rewrites the references to the renamed objects.
+type renameReferences struct {
+	byUID map[types.UID]snapshotObjectRef
+	byRef map[snapshotObjectRef]snapshotObjectRef
+	// labels are the old names to the new ones by the namespace they are in after the renaming,
+	// only set to rewrite the selectors
+	labels map[string]map[string]string
+}
+
+func newRenameReferences(targets []*renameTarget, selectors bool) *renameReferences {
+	refs := &renameReferences{
+		byUID:  map[types.UID]snapshotObjectRef{},
+		byRef:  map[snapshotObjectRef]snapshotObjectRef{},
+		labels: map[string]map[string]string{},
+	}
+	for _, t := range targets {
+		if uid := t.obj.GetUID(); uid != "" {
+			refs.byUID[uid] = t.to
+		}
+		refs.byRef[t.from] = t.to
+		if selectors && t.from.name != t.to.name && t.to.namespace != "" {
+			if refs.labels[t.to.namespace] == nil {
+				refs.labels[t.to.namespace] = map[string]string{}
+			}
+			refs.labels[t.to.namespace][t.from.name] = t.to.name
+		}
+	}
+	return refs
+}
+
+// owner returns the renamed owner of the reference of an object in the namespace.
+// The references without a uid, such as the ones of test fixtures, are matched by their kind and name.
+func (r *renameReferences) owner(namespace string, ref metav1.OwnerReference) (snapshotObjectRef, bool) {
+	if ref.UID != "" {
+		to, ok := r.byUID[ref.UID]
+		return to, ok
+	}
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return snapshotObjectRef{}, false
+	}
+	gvr, _ := meta.UnsafeGuessKindToResource(gv.WithKind(ref.Kind))
+	for _, ns := range []string{namespace, ""} {
+		to, ok := r.byRef[snapshotObjectRef{gr: gvr.GroupResource(), namespace: ns, name: ref.Name}]
+		if ok {
+			return to, true
+		}
+	}
+	return snapshotObjectRef{}, false
+}
+
+// rewrite rewrites the references of the object, in the namespace before and after it is renamed,
+// and returns whether any is rewritten.
+func (r *renameReferences) rewrite(obj *unstructured.Unstructured, namespace, newNamespace string) bool {
+	var changed bool
+	owners := obj.GetOwnerReferences()
+	for i, ref := range owners {
+		to, ok := r.owner(namespace, ref)
+		if ok && to.name != ref.Name {
+			owners[i].Name = to.name
+			changed = true
+		}
+	}
+	if changed {
+		obj.SetOwnerReferences(owners)
+	}
+
+	names := r.labels[newNamespace]
+	if len(names) == 0 {
+		return changed
+	}
+	for _, fields := range [][]string{
+		{"metadata", "labels"},
+		{"spec", "selector"},
+		{"spec", "selector", "matchLabels"},
+		{"spec", "template", "metadata", "labels"},
+	} {
+		// The selectors of the workloads are not a map of strings, but have the matchLabels
+		labels, found, err := unstructured.NestedStringMap(obj.Object, fields...)
+		if err != nil || !found {
+			continue
+		}
+		var rewritten bool
+		for k, v := range labels {
+			if to, ok := names[v]; ok {
+				labels[k] = to
+				rewritten = true
+			}
+		}
+		if rewritten {
+			_ = unstructured.SetNestedStringMap(obj.Object, labels, fields...)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func renameCommand(ctx context.Context, etcdclient client.Client, flags *renameFlagpole, args []string) error {
+	renamer, err := newObjectRenamer(flags)
+	if err != nil {
+		return err
+	}
+	// The objects are renamed by the names in their keys
+	if !client.NamesInKeys(etcdclient) {
+		return fmt.Errorf("the key codec does not keep the names in the keys, which rename needs")
+	}
+
+	var targetGr schema.GroupResource
+	var targetName string
+	var targetNamespace string
+	if len(args) != 0 {
+		gr := schema.ParseGroupResource(args[0])
+		if gr.Empty() {
+			return fmt.Errorf("invalid resource %q", args[0])
+		}
+		targetGr = gr
+		targetNamespace = flags.Namespace
+		if len(args) >= 2 {
+			targetName = args[1]
+		}
+
+		if correctGr, namespaced, found := wellknown.CorrectGroupResource(gr); found {
+			targetGr = correctGr
+			if !namespaced || flags.AllNamespace {
+				targetNamespace = ""
+			} else if flags.Namespace == "" {
+				targetNamespace = "default"
+			}
+		}
+	} else if !flags.AllNamespace {
+		targetNamespace = flags.Namespace
+	}
+
+	// The objects moved to a namespace that does not exist would be served by no apiserver
+	for _, namespace := range renamer.namespaces {
+		var found bool
+		_, err = etcdclient.Get(ctx, flags.Prefix,
+			client.WithGR(namespaceGroupResource),
+			client.WithName(namespace, ""),
+			client.WithKeysOnly(),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				found = true
+				return nil
+			}),
+		)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("namespace %q does not exist, put it before moving objects to it", namespace)
+		}
+	}
+
+	var failed int
+	fail := func(key string, err error) {
+		failed++
+		fmt.Fprintf(os.Stderr, "%s: %v\n", key, err)
+	}
+
+	var targets []*renameTarget
+	_, err = etcdclient.Get(ctx, flags.Prefix,
+		client.WithGR(targetGr),
+		client.WithName(targetName, targetNamespace),
+		client.WithPageLimit(flags.ChunkSize),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			gr, rest, ok := groupResourceFromKey(flags.Prefix, string(kv.Key))
+			if !ok {
+				return fmt.Errorf("key %q is not under %q", kv.Key, flags.Prefix)
+			}
+			namespace, name, err := splitName(rest)
+			if err != nil {
+				return err
+			}
+			if targetNamespace != "" && namespace != targetNamespace {
+				return nil
+			}
+			from := snapshotObjectRef{gr: gr, namespace: namespace, name: name}
+			to := from
+			to.namespace, to.name = renamer.rename(namespace, name)
+			if to == from {
+				return nil
+			}
+			if gr == namespaceGroupResource {
+				fail(string(kv.Key), fmt.Errorf("the namespaces are not renamed, move their objects with --namespace-map"))
+				return nil
+			}
+			if errs := path.IsValidPathSegmentName(to.name); len(errs) != 0 || to.name == "" {
+				fail(string(kv.Key), fmt.Errorf("invalid new name %q", to.name))
+				return nil
+			}
+			if bytes.HasPrefix(kv.Value, []byte(encryptedValuePrefix)) {
+				fail(string(kv.Key), fmt.Errorf("the value is encrypted"))
+				return nil
+			}
+
+			mediaType, data, err := convertValue(kv.Value, encoding.JsonMediaType)
+			if err != nil {
+				fail(string(kv.Key), err)
+				return nil
+			}
+			obj := &unstructured.Unstructured{}
+			err = obj.UnmarshalJSON(data)
+			if err != nil {
+				fail(string(kv.Key), err)
+				return nil
+			}
+			targets = append(targets, &renameTarget{
+				from:      from,
+				to:        to,
+				key:       string(kv.Key),
+				revision:  kv.Revision,
+				mediaType: mediaType,
+				obj:       obj,
+			})
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	targets, err = checkRenameTargets(ctx, etcdclient, flags.Prefix, targets, fail)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		if flags.Output == "key" {
+			fmt.Fprintf(os.Stderr, "rename 0 keys, update 0 references, fail %d keys\n", failed)
+		}
+		return nil
+	}
+
+	// The references are found before any is renamed, for the renamed ones to be told apart by their old keys
+	refs := newRenameReferences(targets, flags.RewriteSelectors)
+	renaming := map[string]struct{}{}
+	for _, t := range targets {
+		renaming[t.key] = struct{}{}
+	}
+	var updates []*renameTarget
+	_, err = etcdclient.Get(ctx, flags.Prefix,
+		client.WithPageLimit(flags.ChunkSize),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			if _, ok := renaming[string(kv.Key)]; ok || bytes.HasPrefix(kv.Value, []byte(encryptedValuePrefix)) {
+				return nil
+			}
+			gr, rest, ok := groupResourceFromKey(flags.Prefix, string(kv.Key))
+			if !ok {
+				return nil
+			}
+			namespace, name, err := splitName(rest)
+			if err != nil {
+				return nil
+			}
+			// The values of the other tools sharing the prefix are not objects
+			mediaType, data, err := convertValue(kv.Value, encoding.JsonMediaType)
+			if err != nil {
+				return nil
+			}
+			obj := &unstructured.Unstructured{}
+			if obj.UnmarshalJSON(data) != nil || !refs.rewrite(obj, namespace, namespace) {
+				return nil
+			}
+			ref := snapshotObjectRef{gr: gr, namespace: namespace, name: name}
+			updates = append(updates, &renameTarget{
+				from:      ref,
+				to:        ref,
+				key:       string(kv.Key),
+				revision:  kv.Revision,
+				mediaType: mediaType,
+				obj:       obj,
+			})
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	var renamed, updated int
+	for _, t := range targets {
+		_ = refs.rewrite(t.obj, t.from.namespace, t.to.namespace)
+		key, err := renameObject(ctx, etcdclient, flags.Prefix, t)
+		if err != nil {
+			fail(t.key, err)
+			continue
+		}
+		renamed++
+		if flags.Output == "key" {
+			fmt.Fprintf(os.Stdout, "%s -> %s\n", t.key, key)
+		}
+	}
+
+	for _, u := range updates {
+		data, err := u.obj.MarshalJSON()
+		if err == nil {
+			data, err = convertToStorage(data, u.mediaType)
+		}
+		if err == nil {
+			err = etcdclient.Put(ctx, flags.Prefix, data,
+				client.WithGR(u.from.gr),
+				client.WithName(u.from.name, u.from.namespace),
+				client.WithModRevision(u.revision),
+			)
+		}
+		if err != nil {
+			if errors.Is(err, client.ErrConflict) {
+				fail(u.key, err)
+				continue
+			}
+			return err
+		}
+		updated++
+		if flags.Output == "key" {
+			fmt.Fprintf(os.Stdout, "%s\n", u.key)
+		}
+	}
+
+	if flags.Output == "key" {
+		fmt.Fprintf(os.Stderr, "rename %d keys, update %d references, fail %d keys\n", renamed, updated, failed)
+	}
+	return nil
+}
+
+// checkRenameTargets returns the targets which can be renamed, and fails the others:
+// the ones whose new key exists or is the new key of another, and the ones moved to another namespace without their namespaced owners.
+func checkRenameTargets(ctx context.Context, etcdclient client.Client, prefix string, targets []*renameTarget, fail func(key string, err error)) ([]*renameTarget, error) {
+	moved := map[snapshotObjectRef]string{}
+	for _, t := range targets {
+		moved[t.from] = t.to.namespace
+	}
+	refs := newRenameReferences(targets, false)
+
+	seen := map[snapshotObjectRef]string{}
+	checked := make([]*renameTarget, 0, len(targets))
+	for _, t := range targets {
+		if key, ok := seen[t.to]; ok {
+			fail(t.key, fmt.Errorf("renamed to %s as %s is", t.to, key))
+			continue
+		}
+		seen[t.to] = t.key
+
+		var exists bool
+		_, err := etcdclient.Get(ctx, prefix,
+			client.WithGR(t.to.gr),
+			client.WithName(t.to.name, t.to.namespace),
+			client.WithKeysOnly(),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				exists = true
+				return nil
+			}),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			fail(t.key, fmt.Errorf("%s already exists", t.to))
+			continue
+		}
+
+		if t.from.namespace != t.to.namespace {
+			err = checkMovedOwners(ctx, etcdclient, prefix, t, refs)
+			if err != nil {
+				fail(t.key, err)
+				continue
+			}
+		}
+		checked = append(checked, t)
+	}
+	return checked, nil
+}
+
+// checkMovedOwners returns an error if an owner in the namespace of the object is neither moved along with it nor in the new namespace,
+// which the garbage collector would not find there, and would delete the object for.
+func checkMovedOwners(ctx context.Context, etcdclient client.Client, prefix string, t *renameTarget, refs *renameReferences) error {
+	for _, ref := range t.obj.GetOwnerReferences() {
+		to, ok := refs.owner(t.from.namespace, ref)
+		if ok && to.namespace == t.to.namespace {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			return err
+		}
+		gvr, _ := meta.UnsafeGuessKindToResource(gv.WithKind(ref.Kind))
+		// The owners of unknown resources, such as custom resources, are taken to be namespaced
+		if _, namespaced, found := wellknown.CorrectGroupResource(gvr.GroupResource()); found && !namespaced {
+			continue
+		}
+		var exists bool
+		_, err = etcdclient.Get(ctx, prefix,
+			client.WithGR(gvr.GroupResource()),
+			client.WithName(ref.Name, t.to.namespace),
+			client.WithKeysOnly(),
+			client.WithResponse(func(kv *client.KeyValue) error {
+				exists = true
+				return nil
+			}),
+		)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		return fmt.Errorf("the owner %s %s is not moved to %s along with it", ref.Kind, ref.Name, t.to.namespace)
+	}
+	return nil
+}
+
+// renameObject creates the object under its new key, reads it back to verify it, then deletes its old key,
+// and returns the new key.
+func renameObject(ctx context.Context, etcdclient client.Client, prefix string, t *renameTarget) (string, error) {
+	t.obj.SetName(t.to.name)
+	if t.to.namespace != "" {
+		t.obj.SetNamespace(t.to.namespace)
+	}
+	data, err := t.obj.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	data, err = convertToStorage(data, t.mediaType)
+	if err != nil {
+		return "", err
+	}
+
+	key, _, err := client.NewOp(
+		client.WithGR(t.to.gr),
+		client.WithName(t.to.name, t.to.namespace),
+	).Key(prefix)
+	if err != nil {
+		return "", err
+	}
+	err = etcdclient.Put(ctx, prefix, data,
+		client.WithGR(t.to.gr),
+		client.WithName(t.to.name, t.to.namespace),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	var verified bool
+	_, err = etcdclient.Get(ctx, prefix,
+		client.WithGR(t.to.gr),
+		client.WithName(t.to.name, t.to.namespace),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			verified = bytes.Equal(kv.Value, data)
+			return nil
+		}),
+	)
+	if err != nil {
+		return "", err
+	}
+	if !verified {
+		return "", fmt.Errorf("%s is not read back as written, the old key is kept", key)
+	}
+
+	err = etcdclient.Delete(ctx, prefix,
+		client.WithGR(t.from.gr),
+		client.WithName(t.from.name, t.from.namespace),
+	)
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
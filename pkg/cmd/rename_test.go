@@ -0,0 +1,220 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newRenameClient(t *testing.T) client.Client {
+	etcdclient := fake.NewClient()
+	objects := []struct {
+		gr    schema.GroupResource
+		value string
+	}{
+		{namespaceGroupResource, `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"a"}}`},
+		{namespaceGroupResource, `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"b"}}`},
+		{schema.GroupResource{Group: "apps", Resource: "deployments"}, `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web","namespace":"a","uid":"uid-web"},"spec":{"selector":{"matchLabels":{"app":"web"}},"template":{"metadata":{"labels":{"app":"web"}}}}}`},
+		{schema.GroupResource{Group: "apps", Resource: "replicasets"}, `{"apiVersion":"apps/v1","kind":"ReplicaSet","metadata":{"name":"web-1","namespace":"a","labels":{"app":"web"},"ownerReferences":[{"apiVersion":"apps/v1","kind":"Deployment","name":"web","uid":"uid-web"}]}}`},
+		{schema.GroupResource{Resource: "services"}, `{"apiVersion":"v1","kind":"Service","metadata":{"name":"web","namespace":"a"},"spec":{"selector":{"app":"web"}}}`},
+		{schema.GroupResource{Resource: "configmaps"}, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"web","namespace":"a"}}`},
+		{schema.GroupResource{Resource: "configmaps"}, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"frontend","namespace":"b"}}`},
+	}
+	for _, o := range objects {
+		obj := &unstructured.Unstructured{}
+		err := obj.UnmarshalJSON([]byte(o.value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = etcdclient.Put(context.Background(), "/registry", []byte(o.value),
+			client.WithGR(o.gr),
+			client.WithName(obj.GetName(), obj.GetNamespace()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return etcdclient
+}
+
+func renameKeys(t *testing.T, etcdclient client.Client) []string {
+	var keys []string
+	_, err := etcdclient.Get(context.Background(), "/registry",
+		client.WithKeysOnly(),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			keys = append(keys, string(kv.Key))
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestRename(t *testing.T) {
+	etcdclient := newRenameClient(t)
+	err := renameCommand(context.Background(), etcdclient, &renameFlagpole{
+		Output:           "none",
+		Prefix:           "/registry",
+		Namespace:        "a",
+		Map:              []string{"web=frontend"},
+		RewriteSelectors: true,
+	}, []string{"deployments"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"/registry/configmaps/a/web",
+		"/registry/configmaps/b/frontend",
+		"/registry/deployments/a/frontend",
+		"/registry/namespaces/a",
+		"/registry/namespaces/b",
+		"/registry/replicasets/a/web-1",
+		"/registry/services/specs/a/web",
+	}
+	if got := renameKeys(t, etcdclient); !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys = %v, want %v", got, want)
+	}
+
+	deployment := getRenamed(t, etcdclient, schema.GroupResource{Group: "apps", Resource: "deployments"}, "frontend", "a")
+	if deployment.GetUID() != "uid-web" {
+		t.Errorf("uid = %q, want uid-web", deployment.GetUID())
+	}
+	if labels, _, _ := unstructured.NestedStringMap(deployment.Object, "spec", "template", "metadata", "labels"); labels["app"] != "frontend" {
+		t.Errorf("pod template labels = %v, want app=frontend", labels)
+	}
+	replicaset := getRenamed(t, etcdclient, schema.GroupResource{Group: "apps", Resource: "replicasets"}, "web-1", "a")
+	if owners := replicaset.GetOwnerReferences(); len(owners) != 1 || owners[0].Name != "frontend" {
+		t.Errorf("ownerReferences = %v, want frontend", owners)
+	}
+	service := getRenamed(t, etcdclient, schema.GroupResource{Resource: "services"}, "web", "a")
+	if selector, _, _ := unstructured.NestedStringMap(service.Object, "spec", "selector"); selector["app"] != "frontend" {
+		t.Errorf("service selector = %v, want app=frontend", selector)
+	}
+}
+
+func TestRenameNamespace(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags renameFlagpole
+		args  []string
+		want  []string
+	}{
+		{
+			name:  "move a namespace",
+			flags: renameFlagpole{NamespaceMap: []string{"a=b"}},
+			want: []string{
+				"/registry/configmaps/b/frontend",
+				"/registry/configmaps/b/web",
+				"/registry/deployments/b/web",
+				"/registry/namespaces/a",
+				"/registry/namespaces/b",
+				"/registry/replicasets/b/web-1",
+				"/registry/services/specs/b/web",
+			},
+		},
+		{
+			name:  "without the owner",
+			flags: renameFlagpole{Namespace: "a", NamespaceMap: []string{"a=b"}},
+			args:  []string{"replicasets"},
+			want: []string{
+				"/registry/configmaps/a/web",
+				"/registry/configmaps/b/frontend",
+				"/registry/deployments/a/web",
+				"/registry/namespaces/a",
+				"/registry/namespaces/b",
+				"/registry/replicasets/a/web-1",
+				"/registry/services/specs/a/web",
+			},
+		},
+		{
+			name:  "onto an existing key",
+			flags: renameFlagpole{Namespace: "b", Map: []string{"frontend=web"}, NamespaceMap: []string{"b=a"}},
+			args:  []string{"configmaps"},
+			want: []string{
+				"/registry/configmaps/a/web",
+				"/registry/configmaps/b/frontend",
+				"/registry/deployments/a/web",
+				"/registry/namespaces/a",
+				"/registry/namespaces/b",
+				"/registry/replicasets/a/web-1",
+				"/registry/services/specs/a/web",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			etcdclient := newRenameClient(t)
+			flags := tt.flags
+			flags.Output = "none"
+			flags.Prefix = "/registry"
+			err := renameCommand(context.Background(), etcdclient, &flags, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := renameKeys(t, etcdclient); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameMissingNamespace(t *testing.T) {
+	err := renameCommand(context.Background(), newRenameClient(t), &renameFlagpole{
+		Output:       "none",
+		Prefix:       "/registry",
+		NamespaceMap: []string{"a=c"},
+	}, nil)
+	if err == nil {
+		t.Fatal("want an error for a namespace that does not exist")
+	}
+}
+
+func getRenamed(t *testing.T, etcdclient client.Client, gr schema.GroupResource, name, namespace string) *unstructured.Unstructured {
+	data, err := getObjectJSON(context.Background(), etcdclient, "/registry", gr, name, namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &unstructured.Unstructured{}
+	err = obj.UnmarshalJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestRenameHashedKeys(t *testing.T) {
+	etcdclient, _ := newHashedKeysClient(t)
+	err := renameCommand(context.Background(), etcdclient, &renameFlagpole{
+		Output:    "none",
+		Prefix:    "/registry",
+		Namespace: "a",
+		Map:       []string{"web=frontend"},
+	}, []string{"deployments"})
+	if err == nil {
+		t.Fatal("want an error for the names hashed in the keys")
+	}
+}
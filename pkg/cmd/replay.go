@@ -0,0 +1,237 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replayLaneSize is the number of the changes of a namespace queued ahead of its clock,
+// beyond which the input is read no further until the oldest of them is applied.
+const replayLaneSize = 1024
+
+// replayClocks applies the changes of a kwok recording at their durations, on a virtual clock by namespace:
+// the changes of a namespace are applied in order at its own speed, and wait for neither the changes nor the clocks of the others.
+type replayClocks struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	start  time.Time
+	speed  float64
+	speeds map[string]float64
+	// laneSize is the number of the changes queued by lane
+	laneSize int
+
+	wg    sync.WaitGroup
+	mut   sync.Mutex
+	lanes map[string]*replayLane
+	err   error
+}
+
+// replayLane is the changes of a namespace waiting to be applied.
+type replayLane struct {
+	mut     sync.Mutex
+	pending []replayStep
+	closed  bool
+	// wakeup is signaled when a change is added or the lane is closed
+	wakeup chan struct{}
+	// space holds a token for each change queued, bounding the pending ones
+	space chan struct{}
+}
+
+type replayStep struct {
+	at    time.Duration
+	apply func() error
+}
+
+// newReplayClocks returns the clocks of the replay at the speed, 1 for the durations as recorded and 0 not to wait for them,
+// and at the speeds of the namespaces in the form of namespace=speed. It is nil if the changes are applied as they are read.
+func newReplayClocks(ctx context.Context, speed float64, namespaceSpeeds []string) (*replayClocks, error) {
+	if speed < 0 {
+		return nil, fmt.Errorf("invalid replay speed %v", speed)
+	}
+	speeds := map[string]float64{}
+	for _, s := range namespaceSpeeds {
+		namespace, value, ok := strings.Cut(s, "=")
+		if !ok || namespace == "" {
+			return nil, fmt.Errorf("invalid namespace speed %q, want namespace=speed", s)
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid namespace speed %q", s)
+		}
+		speeds[namespace] = v
+	}
+	if speed == 0 && len(speeds) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return &replayClocks{
+		ctx:      ctx,
+		cancel:   cancel,
+		start:    time.Now(),
+		speed:    speed,
+		speeds:   speeds,
+		laneSize: replayLaneSize,
+		lanes:    map[string]*replayLane{},
+	}, nil
+}
+
+// wallClock returns the time since the start of the replay the change at the duration is applied at, in the namespace.
+func (c *replayClocks) wallClock(namespace string, at time.Duration) time.Duration {
+	speed, ok := c.speeds[namespace]
+	if !ok {
+		speed = c.speed
+	}
+	if speed == 0 {
+		return 0
+	}
+	return time.Duration(float64(at) / speed)
+}
+
+func (c *replayClocks) lane(namespace string) *replayLane {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	l, ok := c.lanes[namespace]
+	if !ok {
+		l = &replayLane{
+			wakeup: make(chan struct{}, 1),
+			space:  make(chan struct{}, c.laneSize),
+		}
+		c.lanes[namespace] = l
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			err := c.run(namespace, l)
+			if err != nil {
+				c.mut.Lock()
+				if c.err == nil {
+					c.err = err
+				}
+				c.mut.Unlock()
+				c.cancel()
+			}
+		}()
+	}
+	return l
+}
+
+// run applies the changes of the lane in order, each once the clock of the namespace is at it.
+func (c *replayClocks) run(namespace string, l *replayLane) error {
+	for {
+		l.mut.Lock()
+		if len(l.pending) == 0 {
+			closed := l.closed
+			l.mut.Unlock()
+			if closed {
+				return nil
+			}
+			select {
+			case <-l.wakeup:
+				continue
+			case <-c.ctx.Done():
+				return nil
+			}
+		}
+		step := l.pending[0]
+		l.pending[0] = replayStep{}
+		l.pending = l.pending[1:]
+		l.mut.Unlock()
+		<-l.space
+
+		if wait := time.Until(c.start.Add(c.wallClock(namespace, step.at))); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-c.ctx.Done():
+				timer.Stop()
+				return nil
+			}
+		}
+		err := step.apply()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// submit queues the change of the namespace at the duration since the start of the recording,
+// it returns the error of a change failed before so that no more are submitted.
+// It blocks while the lane is full, until the oldest change of the namespace is applied or the replay is interrupted.
+func (c *replayClocks) submit(namespace string, at time.Duration, apply func() error) error {
+	err := c.failed()
+	if err != nil {
+		return err
+	}
+	l := c.lane(namespace)
+	select {
+	case l.space <- struct{}{}:
+	case <-c.ctx.Done():
+		return c.failed()
+	}
+	l.mut.Lock()
+	l.pending = append(l.pending, replayStep{at: at, apply: apply})
+	l.mut.Unlock()
+	select {
+	case l.wakeup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (c *replayClocks) failed() error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.err == nil && c.ctx.Err() != nil {
+		return c.ctx.Err()
+	}
+	return c.err
+}
+
+// wait waits for the changes queued to be applied, and returns the error of the first failed one.
+// If the input failed, the changes queued are dropped instead.
+func (c *replayClocks) wait(inputErr error) error {
+	if inputErr != nil {
+		c.cancel()
+	}
+	c.mut.Lock()
+	for _, l := range c.lanes {
+		l.mut.Lock()
+		l.closed = true
+		l.mut.Unlock()
+		select {
+		case l.wakeup <- struct{}{}:
+		default:
+		}
+	}
+	c.mut.Unlock()
+	c.wg.Wait()
+	c.mut.Lock()
+	err := c.err
+	c.mut.Unlock()
+	if err == nil && inputErr == nil {
+		// The lanes stop early when the replay is interrupted
+		err = c.ctx.Err()
+	}
+	c.cancel()
+	return err
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewReplayClocks(t *testing.T) {
+	tests := []struct {
+		name            string
+		speed           float64
+		namespaceSpeeds []string
+		wantNil         bool
+		wantErr         bool
+	}{
+		{name: "disabled", wantNil: true},
+		{name: "speed", speed: 1},
+		{name: "namespace speed", namespaceSpeeds: []string{"load=10", "idle=0"}},
+		{name: "negative speed", speed: -1, wantErr: true},
+		{name: "missing speed", namespaceSpeeds: []string{"load"}, wantErr: true},
+		{name: "missing namespace", namespaceSpeeds: []string{"=1"}, wantErr: true},
+		{name: "invalid speed", namespaceSpeeds: []string{"load=fast"}, wantErr: true},
+		{name: "negative namespace speed", namespaceSpeeds: []string{"load=-2"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clocks, err := newReplayClocks(context.Background(), tt.speed, tt.namespaceSpeeds)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newReplayClocks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (clocks == nil) != tt.wantNil {
+				t.Errorf("newReplayClocks() = %v, wantNil %v", clocks, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestReplayClocksIndependentNamespaces(t *testing.T) {
+	clocks, err := newReplayClocks(context.Background(), 1, []string{"fast=100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mut sync.Mutex
+	applied := map[string][]time.Duration{}
+	record := func(namespace string, at time.Duration) func() error {
+		return func() error {
+			mut.Lock()
+			defer mut.Unlock()
+			applied[namespace] = append(applied[namespace], at)
+			return nil
+		}
+	}
+
+	start := time.Now()
+	for _, step := range []struct {
+		namespace string
+		at        time.Duration
+	}{
+		{"slow", 0},
+		{"slow", 100 * time.Millisecond},
+		{"fast", 1 * time.Second},
+		{"slow", 200 * time.Millisecond},
+		{"fast", 2 * time.Second},
+	} {
+		err = clocks.submit(step.namespace, step.at, record(step.namespace, step.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = clocks.wait(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+
+	// The fast namespace is done in 20ms, without waiting for the slow one to reach its changes
+	if elapsed < 200*time.Millisecond || elapsed > 900*time.Millisecond {
+		t.Errorf("replay took %v, want about 200ms", elapsed)
+	}
+	want := map[string][]time.Duration{
+		"slow": {0, 100 * time.Millisecond, 200 * time.Millisecond},
+		"fast": {1 * time.Second, 2 * time.Second},
+	}
+	for namespace, durations := range want {
+		if !slices.Equal(applied[namespace], durations) {
+			t.Errorf("applied %s = %v, want %v", namespace, applied[namespace], durations)
+		}
+	}
+}
+
+func TestReplayClocksFailure(t *testing.T) {
+	clocks, err := newReplayClocks(context.Background(), 0, []string{"a=0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errApply := errors.New("apply")
+	err = clocks.submit("a", 0, func() error { return errApply })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = clocks.wait(nil)
+	if !errors.Is(err, errApply) {
+		t.Errorf("wait() = %v, want %v", err, errApply)
+	}
+}
+
+func TestReplayClocksBoundedLane(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clocks, err := newReplayClocks(ctx, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clocks.laneSize = 1
+
+	// The first change is being applied, the second fills the lane
+	applying := make(chan struct{})
+	release := make(chan struct{})
+	err = clocks.submit("a", 0, func() error {
+		close(applying)
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-applying
+	err = clocks.submit("a", 0, func() error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- clocks.submit("a", 0, func() error { return nil })
+	}()
+	select {
+	case err := <-submitted:
+		t.Fatalf("submit() to a full lane returned %v, want it to block", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-submitted:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("submit() still blocked after the lane was drained")
+	}
+	err = clocks.wait(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayClocksBoundedLaneInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	clocks, err := newReplayClocks(ctx, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clocks.laneSize = 1
+
+	// The lane waits an hour for its first change, so that the second one blocks
+	for _, at := range []time.Duration{time.Hour, time.Hour} {
+		err = clocks.submit("a", at, func() error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- clocks.submit("a", time.Hour, func() error { return nil })
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-submitted:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("submit() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("submit() still blocked after the replay was interrupted")
+	}
+	err = clocks.wait(nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("wait() = %v, want %v", err, context.Canceled)
+	}
+}
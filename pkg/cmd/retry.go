@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type retryCfg struct {
+	// requestTimeout is the timeout of each attempt of a request, 0 is none
+	requestTimeout time.Duration
+	maxRetries     int
+	backoff        time.Duration
+	maxBackoff     time.Duration
+}
+
+// unaryInterceptor retries the requests that fail with transient errors, such as during a leader election,
+// waiting for an exponential backoff between the attempts.
+// The writes are retried as well, a write that was applied before its response is lost is applied again.
+func (r *retryCfg) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		for attempt := 0; ; attempt++ {
+			callCtx, cancel := ctx, context.CancelFunc(func() {})
+			if r.requestTimeout > 0 {
+				callCtx, cancel = context.WithTimeout(ctx, r.requestTimeout)
+			}
+			err := invoker(callCtx, method, req, reply, cc, opts...)
+			timedOut := callCtx.Err() != nil
+			cancel()
+			if err == nil || ctx.Err() != nil || attempt >= r.maxRetries {
+				return err
+			}
+			if !timedOut && !isTransientError(err) {
+				return err
+			}
+
+			delay := r.backoffDelay(attempt)
+			fmt.Fprintf(os.Stderr, "retry %s in %s: %v\n", method, delay, err)
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// backoffDelay returns the wait before the retry after the attempt,
+// which doubles with each attempt up to maxBackoff with a jitter of up to half of it.
+func (r *retryCfg) backoffDelay(attempt int) time.Duration {
+	delay := r.backoff
+	for i := 0; i < attempt && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	if r.maxBackoff > 0 && delay > r.maxBackoff {
+		delay = r.maxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// isTransientError returns whether the request may succeed if it is sent again.
+func isTransientError(err error) bool {
+	switch rpctypes.Error(err) {
+	case rpctypes.ErrLeaderChanged,
+		rpctypes.ErrNoLeader,
+		rpctypes.ErrTimeout,
+		rpctypes.ErrTimeoutDueToLeaderFail,
+		rpctypes.ErrTimeoutDueToConnectionLost,
+		rpctypes.ErrTimeoutWaitAppliedIndex,
+		rpctypes.ErrTooManyRequests:
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable:
+		return true
+	}
+	return false
+}
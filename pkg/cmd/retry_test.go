@@ -0,0 +1,115 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"google.golang.org/grpc"
+)
+
+func TestRetryUnaryInterceptor(t *testing.T) {
+	tests := []struct {
+		name         string
+		errs         []error
+		hang         int
+		maxRetries   int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{
+			name:         "leader changed",
+			errs:         []error{rpctypes.ErrGRPCLeaderChanged, rpctypes.ErrGRPCNoLeader, nil},
+			maxRetries:   5,
+			wantAttempts: 3,
+		},
+		{
+			name:         "not transient",
+			errs:         []error{rpctypes.ErrGRPCCompacted},
+			maxRetries:   5,
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+		{
+			name:         "retries exhausted",
+			errs:         []error{rpctypes.ErrGRPCLeaderChanged, rpctypes.ErrGRPCLeaderChanged, rpctypes.ErrGRPCLeaderChanged},
+			maxRetries:   2,
+			wantErr:      true,
+			wantAttempts: 3,
+		},
+		{
+			name:         "request timeout",
+			errs:         []error{nil, nil},
+			hang:         1,
+			maxRetries:   5,
+			wantAttempts: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &retryCfg{
+				requestTimeout: 10 * time.Millisecond,
+				maxRetries:     tt.maxRetries,
+				backoff:        time.Millisecond,
+				maxBackoff:     2 * time.Millisecond,
+			}
+			attempts := 0
+			invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				attempts++
+				if attempts <= tt.hang {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				return tt.errs[attempts-1]
+			}
+			err := r.unaryInterceptor()(context.Background(), "/etcdserverpb.KV/Put", nil, nil, nil, invoker)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unaryInterceptor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("unaryInterceptor() attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryBackoffDelay(t *testing.T) {
+	r := &retryCfg{
+		backoff:    100 * time.Millisecond,
+		maxBackoff: time.Second,
+	}
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		got := r.backoffDelay(attempt)
+		if got < want/2 || got > want {
+			t.Errorf("backoffDelay(%d) = %v, want in [%v, %v]", attempt, got, want/2, want)
+		}
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	if !isTransientError(rpctypes.ErrGRPCLeaderChanged) {
+		t.Errorf("leader changed should be transient")
+	}
+	if isTransientError(rpctypes.ErrGRPCCompacted) || isTransientError(errors.New("decode")) {
+		t.Errorf("compacted and plain errors should not be transient")
+	}
+}
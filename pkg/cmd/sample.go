@@ -0,0 +1,109 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// eventSampler keeps 1 in a number of the updates of each resource while watching,
+// for the chatty resources such as leases not to make up most of a recording.
+// The creations and deletions are always kept, for each object of the recording to have all its lifetime.
+type eventSampler struct {
+	prefix string
+	rate   int
+	rules  map[schema.GroupResource]int
+
+	seen    map[schema.GroupResource]int
+	skipped int
+}
+
+// newEventSampler returns the sampler of the rate of all the resources and the rules of the resources in the form of resource=rate,
+// or nil if all the updates are kept.
+func newEventSampler(prefix string, rate int, rules []string) (*eventSampler, error) {
+	if rate < 0 {
+		return nil, fmt.Errorf("invalid sample rate %d", rate)
+	}
+	// 0 is the same as 1, keeping all the updates
+	rate = max(rate, 1)
+	s := &eventSampler{
+		prefix: prefix,
+		rate:   rate,
+		rules:  map[schema.GroupResource]int{},
+		seen:   map[schema.GroupResource]int{},
+	}
+	sampled := rate > 1
+	for _, rule := range rules {
+		resource, rateStr, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid sample rule %q, it is resource=rate", rule)
+		}
+		gr, err := parseStorageGroupResource(resource)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample rule %q: %w", rule, err)
+		}
+		r, err := strconv.Atoi(rateStr)
+		if err != nil || r < 1 {
+			return nil, fmt.Errorf("invalid sample rule %q, the rate is 1 or more", rule)
+		}
+		s.rules[gr] = r
+		if r > 1 {
+			sampled = true
+		}
+	}
+	if !sampled {
+		return nil, nil
+	}
+	return s, nil
+}
+
+func (s *eventSampler) wrap(response func(kv *client.KeyValue) error) func(kv *client.KeyValue) error {
+	if s == nil {
+		return response
+	}
+	return func(kv *client.KeyValue) error {
+		// Only the updates of a watch come with both values
+		if len(kv.Value) != 0 && len(kv.PrevValue) != 0 && !s.keep(string(kv.Key)) {
+			s.skipped++
+			return nil
+		}
+		return response(kv)
+	}
+}
+
+// keep returns whether the update of the key is kept, the first of every rate of its resource.
+func (s *eventSampler) keep(key string) bool {
+	gr, _, ok := groupResourceFromKey(s.prefix, key)
+	if !ok {
+		return true
+	}
+	rate, ok := s.rules[gr]
+	if !ok {
+		rate = s.rate
+	}
+	if rate == 1 {
+		return true
+	}
+	n := s.seen[gr]
+	s.seen[gr] = n + 1
+	return n%rate == 0
+}
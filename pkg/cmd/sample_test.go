@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+func TestEventSampler(t *testing.T) {
+	s, err := newEventSampler("/registry", 2, []string{"leases=3", "pods=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := []byte("{}")
+	update := func(key string) *client.KeyValue {
+		return &client.KeyValue{Key: []byte(key), Value: value, PrevValue: value}
+	}
+	events := []*client.KeyValue{
+		// Created
+		{Key: []byte("/registry/leases/kube-node-lease/n0"), Value: value},
+	}
+	for i := 0; i < 6; i++ {
+		events = append(events,
+			update("/registry/leases/kube-node-lease/n0"),
+			update("/registry/pods/default/p0"),
+			update("/registry/configmaps/default/c0"),
+		)
+	}
+	// Deleted
+	events = append(events, &client.KeyValue{Key: []byte("/registry/leases/kube-node-lease/n0"), PrevValue: value})
+
+	written := map[string]int{}
+	response := s.wrap(func(kv *client.KeyValue) error {
+		written[string(kv.Key)]++
+		return nil
+	})
+	for _, kv := range events {
+		err := response(kv)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]int{
+		"/registry/leases/kube-node-lease/n0": 1 + 2 + 1,
+		"/registry/pods/default/p0":           6,
+		"/registry/configmaps/default/c0":     3,
+	}
+	for key, n := range want {
+		if written[key] != n {
+			t.Errorf("written %d events of %s, want %d", written[key], key, n)
+		}
+	}
+	if s.skipped != 7 {
+		t.Errorf("skipped %d, want 7", s.skipped)
+	}
+}
+
+func TestNewEventSampler(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    int
+		rules   []string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "all kept", rate: 1, wantNil: true},
+		{name: "all kept by the rules", rate: 1, rules: []string{"pods=1"}, wantNil: true},
+		{name: "rate", rate: 10},
+		{name: "rule", rate: 1, rules: []string{"leases.coordination.k8s.io=10"}},
+		{name: "negative rate", rate: -1, wantErr: true},
+		{name: "rule without a rate", rate: 1, rules: []string{"leases"}, wantErr: true},
+		{name: "rule with a zero rate", rate: 1, rules: []string{"leases=0"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newEventSampler("/registry", tt.rate, tt.rules)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newEventSampler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (s == nil) != tt.wantNil {
+				t.Errorf("newEventSampler() = %v, want nil %v", s, tt.wantNil)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// skippedDocuments writes the documents that are skipped to a file with the reasons as comments,
+// the file can be put again once the reasons are dealt with.
+// The file is only created once a document is skipped.
+type skippedDocuments struct {
+	path  string
+	file  *os.File
+	count int
+
+	// appending is whether the file is appended to rather than truncated
+	appending bool
+}
+
+func newSkippedDocuments(path string) *skippedDocuments {
+	return &skippedDocuments{
+		path: path,
+	}
+}
+
+// add writes the skipped document, it does nothing if there is no path.
+func (s *skippedDocuments) add(obj *unstructured.Unstructured, reason string) error {
+	if s.path == "" {
+		return nil
+	}
+	if s.file == nil {
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if s.appending {
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		file, err := os.OpenFile(s.path, flag, 0o666)
+		if err != nil {
+			return err
+		}
+		s.file = file
+	}
+
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return err
+	}
+	// Reasons may span lines, such as the errors of the strict decoding
+	reason = strings.ReplaceAll(reason, "\n", "\n# ")
+	_, err = fmt.Fprintf(s.file, "---\n# skipped: %s\n%s", reason, data)
+	if err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+// Close closes the file and reports how many documents were skipped.
+func (s *skippedDocuments) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "skip %d documents, written to %s\n", s.count, s.path)
+	return s.file.Close()
+}
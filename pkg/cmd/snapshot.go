@@ -0,0 +1,297 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+func newCtlSnapshotCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Builds snapshots of k8s to be put in etcd, and exports them",
+	}
+	cmd.AddCommand(
+		newCtlSnapshotFromManifestsCommand(),
+		newCtlSnapshotExportInventoryCommand(),
+	)
+	return cmd
+}
+
+type snapshotFromManifestsFlagpole struct {
+	Timeline string
+	Path     string
+}
+
+func newCtlSnapshotFromManifestsCommand() *cobra.Command {
+	flags := &snapshotFromManifestsFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "from-manifests <dir>",
+		Short: "Builds a recording from a directory of manifests, without any cluster",
+		Long: "Builds a recording in the format of kwokctl snapshot record from the manifests of a directory,\n" +
+			"the .yaml, .yml and .json files in it and its subdirectories in lexical order, followed by the changes of an optional timeline.\n" +
+			"It can be put by put --path or replayed by kwokctl snapshot replay.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := snapshotFromManifestsCommand(flags, args[0])
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Timeline, "timeline", "", "path of the YAML list of changes to follow the manifests, each with the duration after the previous one, a method, one of (create, patch, delete), and the object to create, the patch or the object to delete")
+	cmd.Flags().StringVar(&flags.Path, "path", "-", "path of the file to write the recording to, - for stdout")
+
+	return cmd
+}
+
+// timelineStep is a change of the timeline of a snapshot built from manifests.
+// The object is the one to create, the strategic merge patch, or the object to delete,
+// its apiVersion, kind and metadata name and namespace are the target of the change.
+type timelineStep struct {
+	After  string          `json:"after,omitempty"`
+	Method string          `json:"method"`
+	Object json.RawMessage `json:"object"`
+}
+
+// snapshotObjectRef identifies an object of a snapshot, to check the timeline against the objects it has at each step.
+type snapshotObjectRef struct {
+	gr        schema.GroupResource
+	namespace string
+	name      string
+}
+
+func (r snapshotObjectRef) String() string {
+	return path.Join(r.gr.String(), r.namespace, r.name)
+}
+
+func snapshotFromManifestsCommand(flags *snapshotFromManifestsFlagpole, dir string) error {
+	objs, err := readManifests(dir)
+	if err != nil {
+		return err
+	}
+
+	var steps []timelineStep
+	if flags.Timeline != "" {
+		data, err := os.ReadFile(flags.Timeline)
+		if err != nil {
+			return err
+		}
+		err = yaml.UnmarshalStrict(data, &steps)
+		if err != nil {
+			return fmt.Errorf("timeline %s: %w", flags.Timeline, err)
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if flags.Path != "-" {
+		file, err := createRecording(flags.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		err = file.Truncate(0)
+		if err != nil {
+			return err
+		}
+		out = file
+	}
+
+	count, changes, err := buildSnapshot(out, objs, steps)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "build %d objects and %d changes\n", count, changes)
+	return nil
+}
+
+// readManifests reads the objects of the manifests in the directory, in the lexical order of the files.
+func readManifests(dir string) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(p)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		err = decodeToUnstructured(f, func(obj *unstructured.Unstructured) error {
+			objs = append(objs, obj)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("manifest %s: %w", p, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
+
+func snapshotObjectRefOf(obj *unstructured.Unstructured) (snapshotObjectRef, schema.GroupVersionResource, error) {
+	gvk := obj.GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return snapshotObjectRef{}, schema.GroupVersionResource{}, fmt.Errorf("object without an apiVersion or a kind")
+	}
+	if obj.GetName() == "" {
+		return snapshotObjectRef{}, schema.GroupVersionResource{}, fmt.Errorf("%s without a name", gvk.Kind)
+	}
+	// The same guess as put, for the recording to be put where put would
+	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+	ref := snapshotObjectRef{
+		gr:        gvr.GroupResource(),
+		namespace: obj.GetNamespace(),
+		name:      obj.GetName(),
+	}
+	return ref, gvr, nil
+}
+
+// buildSnapshot writes the objects, the CRDs and namespaces first as put orders them by default,
+// followed by the changes of the timeline as ResourcePatch documents.
+// The timeline is checked against the objects, each change is to an object that exists at that step, or not for a create.
+func buildSnapshot(w io.Writer, objs []*unstructured.Unstructured, steps []timelineStep) (count, changes int, err error) {
+	policy := defaultWritePolicy()
+	existing := map[snapshotObjectRef]struct{}{}
+	type entry struct {
+		obj   *unstructured.Unstructured
+		phase int
+	}
+	entries := make([]entry, 0, len(objs))
+	for _, obj := range objs {
+		ref, _, err := snapshotObjectRefOf(obj)
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, ok := existing[ref]; ok {
+			return 0, 0, fmt.Errorf("duplicate object %s", ref)
+		}
+		existing[ref] = struct{}{}
+		entries = append(entries, entry{obj: obj, phase: policy.rule(ref.gr).Phase})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].phase < entries[j].phase
+	})
+
+	// The timeline is checked before anything is written
+	rps := make([]*actionv1alpha1.ResourcePatch, 0, len(steps))
+	var elapsed time.Duration
+	for i, step := range steps {
+		rp, err := timelineResourcePatch(step, existing, &elapsed)
+		if err != nil {
+			return 0, 0, fmt.Errorf("step %d of the timeline: %w", i, err)
+		}
+		rps = append(rps, rp)
+	}
+
+	for _, e := range entries {
+		err = writeYAMLDocument(w, e.obj.Object)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	for _, rp := range rps {
+		err = writeYAMLDocument(w, rp)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(entries), len(steps), nil
+}
+
+// timelineResourcePatch returns the ResourcePatch of the step, and updates the existing objects with it.
+// The duration of a ResourcePatch is since the start of the recording, the elapsed one of the steps so far.
+func timelineResourcePatch(step timelineStep, existing map[snapshotObjectRef]struct{}, elapsed *time.Duration) (*actionv1alpha1.ResourcePatch, error) {
+	var after time.Duration
+	if step.After != "" {
+		var err error
+		after, err = time.ParseDuration(step.After)
+		if err != nil || after < 0 {
+			return nil, fmt.Errorf("invalid duration %q", step.After)
+		}
+	}
+	obj := &unstructured.Unstructured{}
+	err := obj.UnmarshalJSON(step.Object)
+	if err != nil {
+		return nil, err
+	}
+	ref, gvr, err := snapshotObjectRefOf(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	_, exists := existing[ref]
+	*elapsed += after
+	rp := &actionv1alpha1.ResourcePatch{
+		DurationNanosecond: *elapsed,
+		Method:             actionv1alpha1.PatchMethod(step.Method),
+	}
+	switch rp.Method {
+	case actionv1alpha1.PatchMethodCreate:
+		if exists {
+			return nil, fmt.Errorf("create %s: already exists", ref)
+		}
+		existing[ref] = struct{}{}
+		rp.Template = step.Object
+	case actionv1alpha1.PatchMethodPatch:
+		if !exists {
+			return nil, fmt.Errorf("patch %s: not found", ref)
+		}
+		rp.Template = step.Object
+	case actionv1alpha1.PatchMethodDelete:
+		if !exists {
+			return nil, fmt.Errorf("delete %s: not found", ref)
+		}
+		delete(existing, ref)
+	default:
+		return nil, fmt.Errorf("unsupported method %q. One of: (create, patch, delete)", step.Method)
+	}
+	rp.Resource.Group = gvr.Group
+	rp.Resource.Version = gvr.Version
+	rp.Resource.Resource = gvr.Resource
+	rp.Target.Name = ref.name
+	rp.Target.Namespace = ref.namespace
+	return rp, nil
+}
@@ -0,0 +1,321 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// The formats of the inventories exported from a snapshot.
+const (
+	// inventoryFormatTerraform is the JSON syntax of Terraform, a kubernetes_manifest resource of the hashicorp/kubernetes provider by object
+	inventoryFormatTerraform = "terraform"
+	// inventoryFormatCrossplane is an Object of the Crossplane provider-kubernetes by object
+	inventoryFormatCrossplane = "crossplane"
+)
+
+// defaultInventoryResources are the resources of the configuration of a cluster, rather than of its workloads.
+var defaultInventoryResources = []string{
+	"namespaces",
+	"resourcequotas",
+	"limitranges",
+	"roles.rbac.authorization.k8s.io",
+	"rolebindings.rbac.authorization.k8s.io",
+	"clusterroles.rbac.authorization.k8s.io",
+	"clusterrolebindings.rbac.authorization.k8s.io",
+}
+
+// systemNamespaces are the namespaces created by k8s itself, which are not exported as configuration.
+var systemNamespaces = map[string]struct{}{
+	"default":         {},
+	"kube-system":     {},
+	"kube-public":     {},
+	"kube-node-lease": {},
+}
+
+type snapshotExportInventoryFlagpole struct {
+	Format         string
+	Resources      []string
+	IncludeSystem  bool
+	ProviderConfig string
+	Path           string
+}
+
+func newCtlSnapshotExportInventoryCommand() *cobra.Command {
+	flags := &snapshotExportInventoryFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "export-inventory <path>",
+		Short: "Exports the configuration of a cluster from a snapshot to be declared by infrastructure-as-code tools",
+		Long: "Exports the objects of the resources of a snapshot, as printed by get, - for stdin, to be declared by Terraform or Crossplane,\n" +
+			"to bootstrap the configuration of a cluster again from what is found in etcd.\n" +
+			"The last document of each object is exported, without its status and the metadata set by the apiserver.\n" +
+			"The changes of a kwokctl recording are left out.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := snapshotExportInventoryCommand(flags, args[0])
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.Format, "format", inventoryFormatTerraform, "format of the inventory. One of: (terraform, crossplane).")
+	cmd.Flags().StringSliceVar(&flags.Resources, "resources", defaultInventoryResources, "resources to export, as resource or resource.group")
+	cmd.Flags().BoolVar(&flags.IncludeSystem, "include-system", false, "also export the namespaces created by k8s and the RBAC objects of its defaults, system: prefixed or labeled kubernetes.io/bootstrapping=rbac-defaults")
+	cmd.Flags().StringVar(&flags.ProviderConfig, "provider-config", "default", "name of the ProviderConfig of the Crossplane objects")
+	cmd.Flags().StringVar(&flags.Path, "path", "-", "path of the file to write the inventory to, - for stdout")
+
+	return cmd
+}
+
+// inventoryObject is an object of the inventory, with the name of its declaration.
+type inventoryObject struct {
+	name  string
+	phase int
+	ref   snapshotObjectRef
+	obj   *unstructured.Unstructured
+}
+
+func snapshotExportInventoryCommand(flags *snapshotExportInventoryFlagpole, path string) error {
+	if flags.Format != inventoryFormatTerraform && flags.Format != inventoryFormatCrossplane {
+		return fmt.Errorf("unsupported inventory format: %s", flags.Format)
+	}
+
+	objs, err := readInventory(path, flags.Resources, flags.IncludeSystem)
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if flags.Path != "-" {
+		file, err := os.Create(flags.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch flags.Format {
+	case inventoryFormatCrossplane:
+		err = writeCrossplaneInventory(out, objs, flags.ProviderConfig)
+	default:
+		err = writeTerraformInventory(out, objs)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "export %d objects\n", len(objs))
+	return nil
+}
+
+// readInventory reads the last document of each object of the resources from the snapshot at the path,
+// cleaned to be declared, in the order put would write them.
+func readInventory(path string, resources []string, includeSystem bool) ([]inventoryObject, error) {
+	wanted := map[string]struct{}{}
+	for _, resource := range resources {
+		wanted[resource] = struct{}{}
+	}
+
+	policy := defaultWritePolicy()
+	index := map[snapshotObjectRef]int{}
+	var objs []inventoryObject
+	err := decodeFile(path, func(obj *unstructured.Unstructured) error {
+		if isKwokResourcePatch(obj) {
+			return nil
+		}
+		ref, _, err := snapshotObjectRefOf(obj)
+		if err != nil {
+			return err
+		}
+		_, ok := wanted[ref.gr.Resource]
+		if !ok {
+			_, ok = wanted[ref.gr.String()]
+		}
+		if !ok || (!includeSystem && isSystemInventoryObject(ref, obj)) {
+			return nil
+		}
+		o := inventoryObject{
+			phase: policy.rule(ref.gr).Phase,
+			ref:   ref,
+			obj:   cleanInventoryObject(obj),
+		}
+		if i, ok := index[ref]; ok {
+			objs[i] = o
+			return nil
+		}
+		index[ref] = len(objs)
+		objs = append(objs, o)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(objs, func(i, j int) bool {
+		if objs[i].phase != objs[j].phase {
+			return objs[i].phase < objs[j].phase
+		}
+		return objs[i].ref.String() < objs[j].ref.String()
+	})
+	names := map[string]int{}
+	for i := range objs {
+		name := inventoryName(objs[i].ref)
+		// The names of different objects may be the same once sanitized
+		names[name]++
+		if n := names[name]; n > 1 {
+			name = fmt.Sprintf("%s-%d", name, n)
+		}
+		objs[i].name = name
+	}
+	return objs, nil
+}
+
+// isSystemInventoryObject returns whether the object is created by k8s itself rather than configured.
+func isSystemInventoryObject(ref snapshotObjectRef, obj *unstructured.Unstructured) bool {
+	switch ref.gr.String() {
+	case "namespaces":
+		_, ok := systemNamespaces[ref.name]
+		return ok
+	case "roles.rbac.authorization.k8s.io", "rolebindings.rbac.authorization.k8s.io",
+		"clusterroles.rbac.authorization.k8s.io", "clusterrolebindings.rbac.authorization.k8s.io":
+		return strings.HasPrefix(ref.name, "system:") ||
+			obj.GetLabels()["kubernetes.io/bootstrapping"] == "rbac-defaults"
+	}
+	return false
+}
+
+// cleanInventoryObject returns the object without its status and the metadata set by the apiserver.
+// The rules of an aggregated ClusterRole are left out too, they are filled by its controller.
+func cleanInventoryObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	cleaned := &unstructured.Unstructured{
+		Object: map[string]interface{}{},
+	}
+	for key, value := range obj.Object {
+		switch key {
+		case "metadata", "status":
+		default:
+			cleaned.Object[key] = value
+		}
+	}
+	cleaned.SetName(obj.GetName())
+	cleaned.SetNamespace(obj.GetNamespace())
+	cleaned.SetLabels(obj.GetLabels())
+	annotations := obj.GetAnnotations()
+	delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+	if len(annotations) != 0 {
+		cleaned.SetAnnotations(annotations)
+	}
+	if _, ok := cleaned.Object["aggregationRule"]; ok {
+		delete(cleaned.Object, "rules")
+	}
+	return cleaned
+}
+
+var inventoryNameInvalid = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// inventoryName returns the name of the declaration of the object, valid both as a Terraform identifier and a k8s name.
+func inventoryName(ref snapshotObjectRef) string {
+	parts := []string{ref.gr.Resource}
+	if ref.namespace != "" {
+		parts = append(parts, ref.namespace)
+	}
+	parts = append(parts, ref.name)
+	name := inventoryNameInvalid.ReplaceAllString(strings.ToLower(strings.Join(parts, "-")), "-")
+	name = strings.Trim(name, "-")
+	if len(name) > 200 {
+		name = strings.TrimRight(name[:200], "-")
+	}
+	return name
+}
+
+// writeTerraformInventory writes the objects as kubernetes_manifest resources in the JSON syntax of Terraform, a .tf.json file.
+func writeTerraformInventory(w io.Writer, objs []inventoryObject) error {
+	resources := map[string]interface{}{}
+	for _, o := range objs {
+		resources[o.name] = map[string]interface{}{
+			"manifest": escapeTerraformTemplates(o.obj.Object),
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]interface{}{
+		"resource": map[string]interface{}{
+			"kubernetes_manifest": resources,
+		},
+	})
+}
+
+// escapeTerraformTemplates escapes the template sequences of the strings of the value,
+// which Terraform would otherwise interpolate in its JSON syntax as in its native one.
+func escapeTerraformTemplates(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		v = strings.ReplaceAll(v, "${", "$${")
+		return strings.ReplaceAll(v, "%{", "%%{")
+	case map[string]interface{}:
+		escaped := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			escaped[escapeTerraformTemplates(key).(string)] = escapeTerraformTemplates(value)
+		}
+		return escaped
+	case []interface{}:
+		escaped := make([]interface{}, len(v))
+		for i, value := range v {
+			escaped[i] = escapeTerraformTemplates(value)
+		}
+		return escaped
+	}
+	return v
+}
+
+// writeCrossplaneInventory writes the objects as Objects of the Crossplane provider-kubernetes, of the provider config.
+func writeCrossplaneInventory(w io.Writer, objs []inventoryObject, providerConfig string) error {
+	for _, o := range objs {
+		err := writeYAMLDocument(w, map[string]interface{}{
+			"apiVersion": "kubernetes.crossplane.io/v1alpha2",
+			"kind":       "Object",
+			"metadata": map[string]interface{}{
+				"name": o.name,
+			},
+			"spec": map[string]interface{}{
+				"forProvider": map[string]interface{}{
+					"manifest": o.obj.Object,
+				},
+				"providerConfigRef": map[string]interface{}{
+					"name": providerConfig,
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
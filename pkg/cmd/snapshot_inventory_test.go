@@ -0,0 +1,212 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const inventorySnapshot = `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team
+  uid: 0a4f
+  resourceVersion: "5"
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: "{}"
+    note: cost ${center}
+status:
+  phase: Active
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: kube-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:node
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: view
+aggregationRule:
+  clusterRoleSelectors:
+  - matchLabels:
+      rbac.example.com/aggregate-to-view: "true"
+rules:
+- verbs: ["get"]
+---
+apiVersion: v1
+kind: ResourceQuota
+metadata:
+  name: pods
+  namespace: team
+spec:
+  hard:
+    pods: "10"
+---
+apiVersion: v1
+kind: ResourceQuota
+metadata:
+  name: pods
+  namespace: team
+spec:
+  hard:
+    pods: "20"
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  namespace: team
+`
+
+func TestReadInventory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.yaml")
+	err := os.WriteFile(path, []byte(inventorySnapshot), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		resources     []string
+		includeSystem bool
+		want          []string
+	}{
+		{
+			name:      "default",
+			resources: defaultInventoryResources,
+			want:      []string{"namespaces-team", "clusterroles-view", "resourcequotas-team-pods"},
+		},
+		{
+			name:          "include system",
+			resources:     defaultInventoryResources,
+			includeSystem: true,
+			want:          []string{"namespaces-kube-system", "namespaces-team", "clusterroles-system-node", "clusterroles-view", "resourcequotas-team-pods"},
+		},
+		{
+			name:      "resources",
+			resources: []string{"pods", "clusterroles.rbac.authorization.k8s.io"},
+			want:      []string{"clusterroles-view", "pods-team-web"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs, err := readInventory(path, tt.resources, tt.includeSystem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, o := range objs {
+				got = append(got, o.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readInventory() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteTerraformInventory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.yaml")
+	err := os.WriteFile(path, []byte(inventorySnapshot), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs, err := readInventory(path, defaultInventoryResources, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = writeTerraformInventory(&buf, objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Resource struct {
+			KubernetesManifest map[string]struct {
+				Manifest map[string]interface{} `json:"manifest"`
+			} `json:"kubernetes_manifest"`
+		} `json:"resource"`
+	}
+	err = json.Unmarshal(buf.Bytes(), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests := got.Resource.KubernetesManifest
+
+	want := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": "team",
+			"annotations": map[string]interface{}{
+				"note": "cost $${center}",
+			},
+		},
+	}
+	if !reflect.DeepEqual(manifests["namespaces-team"].Manifest, want) {
+		t.Errorf("namespace = %v, want %v", manifests["namespaces-team"].Manifest, want)
+	}
+	if _, ok := manifests["clusterroles-view"].Manifest["rules"]; ok {
+		t.Errorf("the rules of an aggregated ClusterRole are exported")
+	}
+	hard := manifests["resourcequotas-team-pods"].Manifest["spec"].(map[string]interface{})["hard"]
+	if !reflect.DeepEqual(hard, map[string]interface{}{"pods": "20"}) {
+		t.Errorf("quota = %v, want the last document of it", hard)
+	}
+}
+
+func TestInventoryName(t *testing.T) {
+	tests := []struct {
+		ref  snapshotObjectRef
+		want string
+	}{
+		{
+			ref:  snapshotObjectRef{gr: schema.GroupResource{Resource: "namespaces"}, name: "team"},
+			want: "namespaces-team",
+		},
+		{
+			ref:  snapshotObjectRef{gr: schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "rolebindings"}, namespace: "team", name: "Admin.Binding"},
+			want: "rolebindings-team-admin-binding",
+		},
+		{
+			ref:  snapshotObjectRef{gr: schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"}, name: "system:aggregate-to-edit"},
+			want: "clusterroles-system-aggregate-to-edit",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := inventoryName(tt.ref); got != tt.want {
+				t.Errorf("inventoryName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
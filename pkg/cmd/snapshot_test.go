@@ -0,0 +1,203 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+)
+
+func TestSnapshotFromManifests(t *testing.T) {
+	dir := t.TempDir()
+	manifests := filepath.Join(dir, "manifests")
+	files := map[string]string{
+		"app/pod.yaml":   "apiVersion: v1\nkind: Pod\nmetadata:\n  name: a\n  namespace: test\nspec:\n  containers:\n  - name: c\n    image: busybox\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n  namespace: test\n",
+		"namespace.json": `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"test"}}`,
+		"README.md":      "not a manifest",
+	}
+	for name, content := range files {
+		p := filepath.Join(manifests, name)
+		err := os.MkdirAll(filepath.Dir(p), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(p, []byte(content), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	timeline := filepath.Join(dir, "timeline.yaml")
+	err := os.WriteFile(timeline, []byte(`
+- after: 1s
+  method: patch
+  object:
+    apiVersion: v1
+    kind: Pod
+    metadata:
+      name: a
+      namespace: test
+    status:
+      phase: Running
+- after: 2s
+  method: delete
+  object:
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: b
+      namespace: test
+- method: create
+  object:
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: c
+      namespace: test
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recording := filepath.Join(dir, "recording.yaml")
+	err = snapshotFromManifestsCommand(&snapshotFromManifestsFlagpole{
+		Timeline: timeline,
+		Path:     recording,
+	}, manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The namespace is before the objects in it, the durations are since the start
+	if !strings.HasPrefix(string(data), "---\napiVersion: v1\nkind: Namespace\n") {
+		t.Errorf("recording does not start with the namespace:\n%s", data)
+	}
+	if !strings.Contains(string(data), "durationNanosecond: 3000000000\nkind: ResourcePatch\nmethod: create\n") {
+		t.Errorf("recording does not create c at 3s:\n%s", data)
+	}
+
+	etcdclient := fake.NewClient()
+	err = putCommand(context.Background(), etcdclient, &putFlagpole{
+		Output:     "none",
+		Path:       recording,
+		Prefix:     "/registry",
+		DecodeMode: "lenient",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	_, err = etcdclient.Get(context.Background(), "/registry",
+		client.WithKeysOnly(),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			keys = append(keys, string(kv.Key))
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/registry/configmaps/test/c", "/registry/namespaces/test", "/registry/pods/test/a"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
+
+func TestSnapshotFromManifestsInvalid(t *testing.T) {
+	pod := "apiVersion: v1\nkind: Pod\nmetadata:\n  name: a\n  namespace: default\n"
+	tests := []struct {
+		name      string
+		manifests string
+		timeline  string
+		wantErr   string
+	}{
+		{
+			name:      "duplicate",
+			manifests: pod + "---\n" + pod,
+			wantErr:   "duplicate object pods/default/a",
+		},
+		{
+			name:      "unnamed",
+			manifests: "apiVersion: v1\nkind: Pod\nmetadata:\n  namespace: default\n",
+			wantErr:   "Pod without a name",
+		},
+		{
+			name:      "patch of a deleted object",
+			manifests: pod,
+			timeline: `
+- method: delete
+  object: {apiVersion: v1, kind: Pod, metadata: {name: a, namespace: default}}
+- method: patch
+  object: {apiVersion: v1, kind: Pod, metadata: {name: a, namespace: default}}
+`,
+			wantErr: "step 1 of the timeline: patch pods/default/a: not found",
+		},
+		{
+			name:      "create of an existing object",
+			manifests: pod,
+			timeline: `
+- method: create
+  object: {apiVersion: v1, kind: Pod, metadata: {name: a, namespace: default}}
+`,
+			wantErr: "step 0 of the timeline: create pods/default/a: already exists",
+		},
+		{
+			name:      "unknown method",
+			manifests: pod,
+			timeline: `
+- method: update
+  object: {apiVersion: v1, kind: Pod, metadata: {name: a, namespace: default}}
+`,
+			wantErr: `unsupported method "update"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			manifests := filepath.Join(dir, "manifests")
+			err := os.Mkdir(manifests, 0o755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(filepath.Join(manifests, "objects.yaml"), []byte(tt.manifests), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			flags := &snapshotFromManifestsFlagpole{Path: filepath.Join(dir, "recording.yaml")}
+			if tt.timeline != "" {
+				flags.Timeline = filepath.Join(dir, "timeline.yaml")
+				err = os.WriteFile(flags.Timeline, []byte(tt.timeline), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err = snapshotFromManifestsCommand(flags, manifests)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("snapshotFromManifestsCommand() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
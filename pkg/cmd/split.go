@@ -0,0 +1,331 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	splitByResource  = "resource"
+	splitByNamespace = "namespace"
+	splitByInterval  = "interval"
+)
+
+type splitFlagpole struct {
+	By     string
+	Prefix string
+	Path   string
+}
+
+func newCtlSplitCommand() *cobra.Command {
+	flags := &splitFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "split <path>",
+		Short: "Splits a recording into files by resource, namespace or interval",
+		Long: "Splits a recording, - for stdin, into files in the directory of --path, one by resource, by namespace or by interval of the times of its bookmarks,\n" +
+			"for the changes of a resource to be replayed alone, or the files to be analyzed in parallel.\n" +
+			"A document goes to the file of its key, or of its object for the documents without one, the changes of a kwok recording by their target.\n" +
+			"The bookmarks go to every file opened so far, and the clock skew or the Recording document the recording starts with to the start of every file.\n" +
+			"The files are of the yaml or jsonl documents of the recording, compressed by the .gz or .zst extension of it.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := splitCommand(flags, args[0])
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.By, "by", splitByResource, "what the recording is split by, interval=10m for the documents between the bookmarks of each 10 minutes. One of: (resource, namespace, interval=<duration>).")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().StringVar(&flags.Path, "path", ".", "path of the directory to write the files to, none of which may exist")
+
+	return cmd
+}
+
+func splitCommand(flags *splitFlagpole, input string) (err error) {
+	s := &recordingSplitter{
+		dir:    flags.Path,
+		prefix: flags.Prefix,
+		files:  map[string]*splitFile{},
+	}
+	by, value, _ := strings.Cut(flags.By, "=")
+	switch {
+	case (by == splitByResource || by == splitByNamespace) && value == "":
+		s.by = by
+	case by == splitByInterval:
+		s.by = by
+		s.interval, err = time.ParseDuration(value)
+		if err != nil || s.interval <= 0 {
+			return fmt.Errorf("invalid interval %q", value)
+		}
+	default:
+		return fmt.Errorf("unsupported split %q. One of: (resource, namespace, interval=<duration>)", flags.By)
+	}
+	if input != "-" {
+		s.compression = compressionFromPath(input)
+	}
+
+	var r io.ReadCloser
+	if input == "-" {
+		r, err = decompress(os.Stdin)
+	} else {
+		r, err = openRecording(input)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	err = os.MkdirAll(flags.Path, 0o755)
+	if err != nil {
+		return err
+	}
+	err = s.split(r)
+	err = errors.Join(err, s.Close())
+	if err != nil {
+		return err
+	}
+	for _, f := range s.order {
+		fmt.Fprintln(os.Stdout, f.path)
+	}
+	fmt.Fprintf(os.Stderr, "split %d documents into %d files\n", s.documents, len(s.order))
+	return nil
+}
+
+// recordingSplitter writes the documents of a recording to the files of what they are split by.
+type recordingSplitter struct {
+	dir         string
+	prefix      string
+	compression string
+	by          string
+	interval    time.Duration
+	// ext is the extension of the files, of the format of the documents
+	ext string
+
+	// header is the documents the recording starts with, written at the start of every file
+	header []byte
+	files  map[string]*splitFile
+	order  []*splitFile
+	// bucket is the start of the interval of the current file, and index its number, of a split by interval
+	bucket time.Time
+	index  int
+
+	documents int
+}
+
+type splitFile struct {
+	path       string
+	file       *os.File
+	compressor compressWriter
+	w          io.Writer
+	closed     bool
+}
+
+func (s *recordingSplitter) split(r io.Reader) error {
+	scanner := newRecordingScanner(r, s.prefix)
+	s.ext = ".yaml"
+	if scanner.JSONL {
+		s.ext = ".jsonl"
+	}
+	return scanner.Scan(func(doc *recordingRawDocument) error {
+		switch {
+		case doc.Header:
+			return s.addHeader(doc.Data)
+		case doc.Bookmark != nil:
+			return s.bookmark(doc.Data, *doc.Bookmark)
+		case doc.Key != "":
+			return s.key(doc.Data, doc.Key)
+		case doc.Object != nil:
+			return s.object(doc.Data, doc.Object)
+		}
+		return nil
+	})
+}
+
+func (s *recordingSplitter) key(doc []byte, key string) error {
+	gr, rest, ok := groupResourceFromKey(s.prefix, key)
+	if !ok {
+		return fmt.Errorf("key %q is not under %q", key, s.prefix)
+	}
+	namespace, _, err := splitName(rest)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", key, err)
+	}
+	return s.write(doc, gr, namespace)
+}
+
+// object writes the document of an object without a key, by the target of the change of a kwok recording or by the object itself.
+func (s *recordingSplitter) object(doc []byte, obj *unstructured.Unstructured) error {
+	if isKwokResourcePatch(obj) {
+		rp, err := decodeKwokResourcePatch(obj)
+		if err != nil {
+			return err
+		}
+		return s.write(doc, rp.Resource.GroupResource(), rp.Target.Namespace)
+	}
+	gvr, _ := meta.UnsafeGuessKindToResource(obj.GroupVersionKind())
+	return s.write(doc, gvr.GroupResource(), obj.GetNamespace())
+}
+
+// addHeader adds a document the recording starts with to the start of every file.
+func (s *recordingSplitter) addHeader(doc []byte) error {
+	s.header = append(s.header, doc...)
+	return s.writeAll(doc)
+}
+
+// bookmark writes the bookmark to every file opened so far, or moves on to the file of its interval.
+func (s *recordingSplitter) bookmark(doc []byte, t time.Time) error {
+	if s.by != splitByInterval {
+		return s.writeAll(doc)
+	}
+	bucket := t.Truncate(s.interval)
+	if s.bucket.IsZero() {
+		// The documents before the first bookmark are of its interval
+		s.bucket = bucket
+	} else if bucket.After(s.bucket) {
+		s.bucket = bucket
+		name := s.intervalName()
+		if f, ok := s.files[name]; ok {
+			err := f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		s.index++
+	}
+	f, err := s.file(s.intervalName())
+	if err != nil {
+		return err
+	}
+	_, err = f.w.Write(doc)
+	return err
+}
+
+func (s *recordingSplitter) intervalName() string {
+	return fmt.Sprintf("%04d", s.index)
+}
+
+func (s *recordingSplitter) write(doc []byte, gr schema.GroupResource, namespace string) error {
+	var name string
+	switch s.by {
+	case splitByResource:
+		name = gr.String()
+	case splitByNamespace:
+		name = namespace
+		if name == "" {
+			// The namespaces can not start with _
+			name = "_cluster"
+		}
+	case splitByInterval:
+		name = s.intervalName()
+	}
+	f, err := s.file(name)
+	if err != nil {
+		return err
+	}
+	s.documents++
+	_, err = f.w.Write(doc)
+	return err
+}
+
+func (s *recordingSplitter) writeAll(doc []byte) error {
+	for _, f := range s.order {
+		if f.closed {
+			continue
+		}
+		_, err := f.w.Write(doc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// file returns the file of the name, created with the header if it is not yet.
+func (s *recordingSplitter) file(name string) (*splitFile, error) {
+	if f, ok := s.files[name]; ok {
+		return f, nil
+	}
+	path := filepath.Join(s.dir, name+s.ext)
+	switch s.compression {
+	case compressionGzip:
+		path += ".gz"
+	case compressionZstd:
+		path += ".zst"
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	f := &splitFile{
+		path: path,
+		file: file,
+		w:    file,
+	}
+	s.files[name] = f
+	s.order = append(s.order, f)
+
+	f.compressor, err = newCompressWriter(file, s.compression, 0)
+	if err != nil {
+		return nil, err
+	}
+	if f.compressor != nil {
+		f.w = f.compressor
+	}
+	_, err = f.w.Write(s.header)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Close closes the files, the ones left unfinished by an error as well.
+func (s *recordingSplitter) Close() error {
+	var errs []error
+	for _, f := range s.order {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (f *splitFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	var err error
+	if f.compressor != nil {
+		err = f.compressor.Close()
+	}
+	return errors.Join(err, f.file.Close())
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRecordingSplitter(t *testing.T) {
+	const recording = `---
+# clock-skew | 10ms | 500ms | 2024-01-01T00:00:00Z
+---
+# /registry/pods/default/a | application/yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+---
+# /registry/deployments/kube-system/b | application/yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: b
+  namespace: kube-system
+---
+# bookmark | 3 | 2024-01-01T00:01:00Z
+---
+# /registry/pods/default/a | deleted
+---
+# bookmark | 4 | 2024-01-01T00:11:00Z
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: c
+`
+	const jsonlRecording = `{"clockSkew":{"skew":10000000,"uncertainty":500000000,"time":"2024-01-01T00:00:00Z"}}
+{"key":"/registry/pods/default/a","revision":2,"object":{"apiVersion":"v1","kind":"Pod","metadata":{"name":"a","namespace":"default"}}}
+{"revision":2,"bookmark":"2024-01-01T00:01:00Z"}
+{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"c"}}
+`
+	tests := []struct {
+		name  string
+		by    string
+		input string
+		want  map[string][]string
+	}{
+		{
+			name:  "by resource",
+			by:    "resource",
+			input: recording,
+			want: map[string][]string{
+				"pods.yaml":             {"# clock-skew", "# /registry/pods/default/a", "# bookmark | 3", "# /registry/pods/default/a", "# bookmark | 4"},
+				"deployments.apps.yaml": {"# clock-skew", "# /registry/deployments/kube-system/b", "# bookmark | 3", "# bookmark | 4"},
+				"namespaces.yaml":       {"# clock-skew", "name: c"},
+			},
+		},
+		{
+			name:  "by namespace",
+			by:    "namespace",
+			input: recording,
+			want: map[string][]string{
+				"default.yaml":     {"# clock-skew", "# /registry/pods/default/a", "# bookmark | 3", "# /registry/pods/default/a", "# bookmark | 4"},
+				"kube-system.yaml": {"# clock-skew", "# /registry/deployments/kube-system/b", "# bookmark | 3", "# bookmark | 4"},
+				"_cluster.yaml":    {"# clock-skew", "name: c"},
+			},
+		},
+		{
+			name:  "by interval",
+			by:    "interval=10m",
+			input: recording,
+			want: map[string][]string{
+				"0000.yaml": {"# clock-skew", "# /registry/pods/default/a", "# /registry/deployments/kube-system/b", "# bookmark | 3", "# /registry/pods/default/a"},
+				"0001.yaml": {"# clock-skew", "# bookmark | 4", "name: c"},
+			},
+		},
+		{
+			name:  "jsonl",
+			by:    "namespace",
+			input: jsonlRecording,
+			want: map[string][]string{
+				"default.jsonl":  {`{"clockSkew"`, `{"key":"/registry/pods/default/a"`, `{"revision":2,"bookmark"`},
+				"_cluster.jsonl": {`{"clockSkew"`, `{"apiVersion":"v1","kind":"Namespace"`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "recording")
+			err := os.WriteFile(input, []byte(tt.input), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := filepath.Join(dir, "split")
+			err = splitCommand(&splitFlagpole{By: tt.by, Prefix: "/registry", Path: out}, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := os.ReadDir(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			var wantNames []string
+			for name := range tt.want {
+				wantNames = append(wantNames, name)
+			}
+			sort.Strings(wantNames)
+			if strings.Join(names, ",") != strings.Join(wantNames, ",") {
+				t.Fatalf("got files %v, want %v", names, wantNames)
+			}
+
+			for name, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(out, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, line := range strings.Split(string(data), "\n") {
+					for _, w := range want {
+						if strings.HasPrefix(strings.TrimSpace(line), w) {
+							got = append(got, w)
+							break
+						}
+					}
+				}
+				if strings.Join(got, "\n") != strings.Join(want, "\n") {
+					t.Errorf("%s: got\n%s\nwant\n%s\nof\n%s", name, strings.Join(got, "\n"), strings.Join(want, "\n"), data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/bgentry/speakeasy"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type sshCfg struct {
+	target   string
+	identity string
+
+	insecureIgnoreHostKey bool
+	// remoteCerts is whether the certificates are read from the remote host
+	remoteCerts bool
+}
+
+// sshTunnel is the connection to the remote host that etcd is dialed through.
+type sshTunnel struct {
+	client *ssh.Client
+}
+
+// parseSSHTarget parses user@host[:port], the user defaults to the current one and the port to 22.
+func parseSSHTarget(target string) (username, addr string, err error) {
+	username, hostport, ok := strings.Cut(target, "@")
+	if !ok {
+		hostport = target
+		u, err := user.Current()
+		if err != nil {
+			return "", "", err
+		}
+		username = u.Username
+	}
+	if hostport == "" || username == "" {
+		return "", "", fmt.Errorf("invalid ssh target %q, expected user@host[:port]", target)
+	}
+
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = strings.TrimSuffix(strings.TrimPrefix(hostport, "["), "]"), "22"
+	}
+	return username, net.JoinHostPort(host, port), nil
+}
+
+// dialSSH connects to the remote host, authenticating with the ssh-agent and the identity,
+// which defaults to the keys in ~/.ssh, and verifying the host key against ~/.ssh/known_hosts.
+func dialSSH(cfg *sshCfg) (*sshTunnel, error) {
+	username, addr, err := parseSSHTarget(cfg.target)
+	if err != nil {
+		return nil, err
+	}
+
+	home, _ := os.UserHomeDir()
+	var auths []ssh.AuthMethod
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err == nil {
+			auths = append(auths, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	identities := []string{cfg.identity}
+	if cfg.identity == "" {
+		identities = []string{
+			filepath.Join(home, ".ssh", "id_ed25519"),
+			filepath.Join(home, ".ssh", "id_ecdsa"),
+			filepath.Join(home, ".ssh", "id_rsa"),
+		}
+	}
+	for _, identity := range identities {
+		signer, err := loadSSHIdentity(identity)
+		if err != nil {
+			if cfg.identity == "" && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if !cfg.insecureIgnoreHostKey {
+		hostKeyCallback, err = knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+		if err != nil {
+			return nil, fmt.Errorf("load known hosts: %w", err)
+		}
+	}
+
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            username,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ssh %s: %w", cfg.target, err)
+	}
+	return &sshTunnel{
+		client: client,
+	}, nil
+}
+
+// loadSSHIdentity loads the private key, prompting for the passphrase if it is protected.
+func loadSSHIdentity(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err == nil {
+		return signer, nil
+	}
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	passphrase, err := speakeasy.Ask(fmt.Sprintf("Passphrase for %s: ", path))
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+}
+
+// dialContext dials the address of etcd from the remote host, the unix sockets are also dialed there.
+func (t *sshTunnel) dialContext(ctx context.Context, addr string) (net.Conn, error) {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		return t.client.DialContext(ctx, "unix", path)
+	}
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return t.client.DialContext(ctx, "unix", path)
+	}
+	return t.client.DialContext(ctx, "tcp", addr)
+}
+
+// tlsConfig returns the TLS configuration with the certificates read from the remote host over SFTP,
+// they are kept in memory rather than copied to local files.
+func (t *sshTunnel) tlsConfig(scfg *secureCfg) (*tls.Config, error) {
+	sc, err := sftp.NewClient(t.client)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: %w", err)
+	}
+	defer sc.Close()
+
+	cfg := &tls.Config{
+		ServerName:         scfg.serverName,
+		InsecureSkipVerify: scfg.insecureSkipVerify,
+	}
+	if scfg.cert != "" || scfg.key != "" {
+		cert, err := readRemoteFile(sc, scfg.cert)
+		if err != nil {
+			return nil, err
+		}
+		key, err := readRemoteFile(sc, scfg.key)
+		if err != nil {
+			return nil, err
+		}
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	if scfg.cacert != "" {
+		ca, err := readRemoteFile(sc, scfg.cacert)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate in %s", scfg.cacert)
+		}
+	}
+	return cfg, nil
+}
+
+func readRemoteFile(sc *sftp.Client, path string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("both --cert and --key are needed")
+	}
+	f, err := sc.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open remote %s: %w", path, err)
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
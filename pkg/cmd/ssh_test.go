@@ -0,0 +1,48 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+)
+
+func TestParseSSHTarget(t *testing.T) {
+	tests := []struct {
+		target   string
+		wantUser string
+		wantAddr string
+		wantErr  bool
+	}{
+		{target: "root@cp0", wantUser: "root", wantAddr: "cp0:22"},
+		{target: "root@cp0:2222", wantUser: "root", wantAddr: "cp0:2222"},
+		{target: "core@[fd00::1]:2222", wantUser: "core", wantAddr: "[fd00::1]:2222"},
+		{target: "core@[fd00::1]", wantUser: "core", wantAddr: "[fd00::1]:22"},
+		{target: "root@", wantErr: true},
+		{target: "@cp0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			gotUser, gotAddr, err := parseSSHTarget(tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSSHTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotUser != tt.wantUser || gotAddr != tt.wantAddr {
+				t.Errorf("parseSSHTarget() = %v, %v, want %v, %v", gotUser, gotAddr, tt.wantUser, tt.wantAddr)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// throttleReportInterval is how often the throughput is reported.
+const throttleReportInterval = time.Second
+
+// throttle limits the rate the key-values are received at, in bytes per second.
+// It holds back the responses, which delays the request of the next page and, for watches,
+// lets the flow control of gRPC slow down the server, so that a constrained link is not saturated.
+type throttle struct {
+	limiter *rate.Limiter
+	w       io.Writer
+	start   time.Time
+
+	// mut guards the total and the report against the responses of parallel lists
+	mut        sync.Mutex
+	lastReport time.Time
+	total      int64
+}
+
+// parseBandwidth parses the bandwidth in bytes per second, such as 10Mi.
+func parseBandwidth(s string) (int64, error) {
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth %q: %w", s, err)
+	}
+	bandwidth, ok := q.AsInt64()
+	if !ok || bandwidth < 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q", s)
+	}
+	return bandwidth, nil
+}
+
+// newThrottle returns a throttle to the bandwidth that reports the throughput to w, it is nil with no bandwidth.
+func newThrottle(bandwidth int64, w io.Writer) *throttle {
+	if bandwidth == 0 {
+		return nil
+	}
+	now := time.Now()
+	return &throttle{
+		limiter:    rate.NewLimiter(rate.Limit(bandwidth), int(bandwidth)),
+		w:          w,
+		start:      now,
+		lastReport: now,
+	}
+}
+
+// wrap returns the response callback that waits for the bandwidth before calling the response.
+func (t *throttle) wrap(ctx context.Context, response func(kv *client.KeyValue) error) func(kv *client.KeyValue) error {
+	if t == nil {
+		return response
+	}
+	return func(kv *client.KeyValue) error {
+		if kv != nil {
+			err := t.wait(ctx, len(kv.Key)+len(kv.Value)+len(kv.PrevValue))
+			if err != nil {
+				return err
+			}
+		}
+		return response(kv)
+	}
+}
+
+// wait blocks until n bytes are allowed, values larger than a second of bandwidth are waited for in parts.
+func (t *throttle) wait(ctx context.Context, n int) error {
+	t.mut.Lock()
+	t.total += int64(n)
+	t.mut.Unlock()
+	for n > 0 {
+		part := min(n, t.limiter.Burst())
+		err := t.limiter.WaitN(ctx, part)
+		if err != nil {
+			return err
+		}
+		n -= part
+	}
+
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if now := time.Now(); now.Sub(t.lastReport) >= throttleReportInterval {
+		t.lastReport = now
+		t.report(now)
+	}
+	return nil
+}
+
+func (t *throttle) report(now time.Time) {
+	elapsed := now.Sub(t.start)
+	fmt.Fprintf(t.w, "received %s in %s, %s/s\n",
+		formatBytes(float64(t.total)),
+		elapsed.Truncate(time.Second),
+		formatBytes(float64(t.total)/elapsed.Seconds()),
+	)
+}
+
+// formatBytes formats the size in binary units.
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
+
+// done reports the final throughput.
+func (t *throttle) done() {
+	if t == nil {
+		return
+	}
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.report(time.Now())
+}
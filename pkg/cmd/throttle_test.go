@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{
+			name: "unlimited",
+			s:    "0",
+			want: 0,
+		},
+		{
+			name: "binary",
+			s:    "10Mi",
+			want: 10 << 20,
+		},
+		{
+			name: "decimal",
+			s:    "1k",
+			want: 1000,
+		},
+		{
+			name:    "negative",
+			s:       "-1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			s:       "fast",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBandwidth(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBandwidth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseBandwidth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[float64]string{
+		512:              "512 B",
+		1536:             "1.5 KiB",
+		10 << 20:         "10.0 MiB",
+		3 << 30:          "3.0 GiB",
+		float64(5 << 40): "5120.0 GiB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%v) = %v, want %v", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type touchFlagpole struct {
+	Namespace    string
+	Output       string
+	ChunkSize    int64
+	Prefix       string
+	AllNamespace bool
+}
+
+func newCtlTouchCommand() *cobra.Command {
+	flags := &touchFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.RangeArgs(0, 2),
+		Use:   "touch [resource] [name]",
+		Short: "Touches the resource of k8s in etcd so that the apiserver serves it afresh",
+		Long: "Touches the resource of k8s in etcd so that the apiserver serves it afresh.\n" +
+			"Each value is written back unchanged, which bumps its revision and sends a modification to the watchers, " +
+			"so that the watch cache of a running apiserver picks up the objects changed directly in etcd.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			etcdclient, err := clientFromCmd(cmd)
+			if err != nil {
+				return err
+			}
+			// The control plane is not checked, touching is meant for a running one and does not change any value
+			err = touchCommand(cmd.Context(), etcdclient, flags, args)
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "key", "output format. One of: (key, none).")
+	cmd.Flags().StringVarP(&flags.Namespace, "namespace", "n", "", "namespace of resource")
+	cmd.Flags().Int64Var(&flags.ChunkSize, "chunk-size", 500, "chunk size of the list pager")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().BoolVarP(&flags.AllNamespace, "all-namespace", "A", false, "all namespace")
+
+	return cmd
+}
+
+func touchCommand(ctx context.Context, etcdclient client.Client, flags *touchFlagpole, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("resource is required")
+	}
+
+	gr := schema.ParseGroupResource(args[0])
+	if gr.Empty() {
+		return fmt.Errorf("invalid resource %q", args[0])
+	}
+	targetGr := gr
+	targetNamespace := flags.Namespace
+	var targetName string
+	if len(args) >= 2 {
+		targetName = args[1]
+	}
+
+	if correctGr, namespaced, found := wellknown.CorrectGroupResource(gr); found {
+		targetGr = correctGr
+		if !namespaced || flags.AllNamespace {
+			targetNamespace = ""
+		} else if flags.Namespace == "" {
+			targetNamespace = "default"
+		}
+	}
+
+	keyPrefix, err := client.PrefixFromGR(targetGr)
+	if err != nil {
+		return err
+	}
+	keyPrefix = flags.Prefix + "/" + keyPrefix + "/"
+
+	var touched, changed int
+	_, err = etcdclient.Get(ctx, flags.Prefix,
+		client.WithName(targetName, targetNamespace),
+		client.WithGR(targetGr),
+		client.WithPageLimit(flags.ChunkSize),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			namespace, name, err := splitKeyName(keyPrefix, string(kv.Key))
+			if err != nil {
+				return err
+			}
+
+			err = etcdclient.Put(ctx, flags.Prefix, kv.Value,
+				client.WithGR(targetGr),
+				client.WithName(name, namespace),
+				client.WithKey(string(kv.Key)),
+				client.WithModRevision(kv.Revision),
+			)
+			if err != nil {
+				// Changed in the meantime, which has already refreshed it
+				if errors.Is(err, client.ErrConflict) {
+					changed++
+					return nil
+				}
+				return err
+			}
+
+			touched++
+			if flags.Output == "key" {
+				fmt.Fprintf(os.Stdout, "%s\n", kv.Key)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	if flags.Output == "key" {
+		fmt.Fprintf(os.Stderr, "touch %d keys, skip %d keys changed in the meantime\n", touched, changed)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/client/fake"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// newHashedKeysClient returns the client storing the keys of the objects put by it with their names hashed,
+// and the client of the keys as stored.
+func newHashedKeysClient(t *testing.T) (client.Client, *fake.Client) {
+	t.Helper()
+	codec, err := client.KeyCodecByName("sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := fake.NewClient()
+	return client.WithKeyCodec(raw, codec), raw
+}
+
+// storedValues returns the values of the keys as stored.
+func storedValues(t *testing.T, raw client.Client) map[string]string {
+	t.Helper()
+	values := map[string]string{}
+	_, err := raw.Get(context.Background(), "/registry", client.WithResponse(func(kv *client.KeyValue) error {
+		values[string(kv.Key)] = string(kv.Value)
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func TestTouchCommandInvalidArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "no resource",
+			wantErr: "resource is required",
+		},
+		{
+			name:    "empty resource",
+			args:    []string{""},
+			wantErr: `invalid resource ""`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The arguments are refused before reaching etcd
+			err := touchCommand(context.Background(), nil, &touchFlagpole{
+				Output: "none",
+				Prefix: "/registry",
+			}, tt.args)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("touchCommand() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTouchCommand(t *testing.T) {
+	ctx := context.Background()
+	etcdclient := fake.NewClient()
+	configmaps := schema.GroupResource{Resource: "configmaps"}
+	for _, namespace := range []string{"default", "other"} {
+		for _, name := range []string{"a", "b"} {
+			err := etcdclient.Put(ctx, "/registry", []byte(namespace+"/"+name), client.WithGR(configmaps), client.WithName(name, namespace))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	want := storedValues(t, etcdclient)
+	rev := etcdclient.Revision()
+
+	err := touchCommand(ctx, etcdclient, &touchFlagpole{
+		Output:    "none",
+		Namespace: "default",
+		Prefix:    "/registry",
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The objects are written back as they are, only the ones of the namespace
+	if got := storedValues(t, etcdclient); !reflect.DeepEqual(got, want) {
+		t.Errorf("stored values = %v, want %v", got, want)
+	}
+	if got := etcdclient.Revision(); got != rev+2 {
+		t.Errorf("revision = %d, want %d after touching 2 keys", got, rev+2)
+	}
+}
+
+func TestTouchCommandHashedKeys(t *testing.T) {
+	ctx := context.Background()
+	etcdclient, raw := newHashedKeysClient(t)
+	configmaps := schema.GroupResource{Resource: "configmaps"}
+	for _, name := range []string{"a", "b"} {
+		err := etcdclient.Put(ctx, "/registry", []byte(name), client.WithGR(configmaps), client.WithName(name, "default"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := storedValues(t, raw)
+	rev := raw.Revision()
+
+	err := touchCommand(ctx, etcdclient, &touchFlagpole{
+		Output:    "none",
+		Namespace: "default",
+		Prefix:    "/registry",
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The keys listed are touched, rather than the keys of their hashes hashed again
+	if got := storedValues(t, raw); !reflect.DeepEqual(got, want) {
+		t.Errorf("stored values = %v, want %v", got, want)
+	}
+	if got := raw.Revision(); got != rev+2 {
+		t.Errorf("revision = %d, want %d after touching 2 keys", got, rev+2)
+	}
+}
+
+// changingClient changes the key of the name before the first key listed is delivered, as a write in the meantime.
+type changingClient struct {
+	client.Client
+	gr   schema.GroupResource
+	name string
+}
+
+func (c *changingClient) Get(ctx context.Context, prefix string, opOpts ...client.OpOption) (int64, error) {
+	response := client.NewOp(opOpts...).Response()
+	var changed bool
+	return c.Client.Get(ctx, prefix, append(opOpts, client.WithResponse(func(kv *client.KeyValue) error {
+		if !changed {
+			changed = true
+			err := c.Client.Put(ctx, prefix, []byte("changed"), client.WithGR(c.gr), client.WithName(c.name, "default"))
+			if err != nil {
+				return err
+			}
+		}
+		return response(kv)
+	}))...)
+}
+
+func TestTouchCommandChanged(t *testing.T) {
+	ctx := context.Background()
+	raw := fake.NewClient()
+	configmaps := schema.GroupResource{Resource: "configmaps"}
+	for _, name := range []string{"a", "b"} {
+		err := raw.Put(ctx, "/registry", []byte(name), client.WithGR(configmaps), client.WithName(name, "default"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rev := raw.Revision()
+
+	// The key changed in the meantime has been refreshed by the change, which is not an error
+	err := touchCommand(ctx, &changingClient{Client: raw, gr: configmaps, name: "b"}, &touchFlagpole{
+		Output:    "key",
+		Namespace: "default",
+		Prefix:    "/registry",
+	}, []string{"configmaps"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"/registry/configmaps/default/a": "a",
+		"/registry/configmaps/default/b": "changed",
+	}
+	if got := storedValues(t, raw); !reflect.DeepEqual(got, want) {
+		t.Errorf("stored values = %v, want %v", got, want)
+	}
+	if got := raw.Revision(); got != rev+2 {
+		t.Errorf("revision = %d, want %d after the change of b and the touch of a", got, rev+2)
+	}
+}
@@ -0,0 +1,381 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	actionv1alpha1 "github.com/wzshiming/kectl/apis/action/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type trimFlagpole struct {
+	From   time.Duration
+	To     time.Duration
+	Prefix string
+	Path   string
+}
+
+func newCtlTrimCommand() *cobra.Command {
+	flags := &trimFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Use:   "trim <path>",
+		Short: "Trims a recording to a time range",
+		Long: "Trims a recording, - for stdin, to the changes between --from and --to since its start, starting with the objects as of --from,\n" +
+			"so an incident in the middle of a long recording is replayed alone, without replaying the hours before it.\n" +
+			"The times of a recording of kectl are the ones of its bookmarks since its clock skew or its first bookmark, the documents before any bookmark being at its start,\n" +
+			"and the ones of a kwok recording the durations of its changes, which are moved to start at --from.\n" +
+			"The output is of the yaml or jsonl documents of the recording, compressed by the .gz or .zst extension of --path.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := trimCommand(flags, args[0])
+
+			if err != nil {
+				return fmt.Errorf("%v: %w", args, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&flags.From, "from", 0, "time since the start of the recording to trim it from, the objects as of which it starts with")
+	cmd.Flags().DurationVar(&flags.To, "to", 0, "time since the start of the recording to trim it to, 0 for its end")
+	cmd.Flags().StringVar(&flags.Prefix, "prefix", "/registry", "prefix to prepend to the resource")
+	cmd.Flags().StringVar(&flags.Path, "path", "-", "path of the file to write the trimmed recording to, - for stdout")
+
+	return cmd
+}
+
+func trimCommand(flags *trimFlagpole, input string) (err error) {
+	if flags.From < 0 || flags.To < 0 {
+		return fmt.Errorf("invalid range from %s to %s", flags.From, flags.To)
+	}
+	if flags.To != 0 && flags.To < flags.From {
+		return fmt.Errorf("invalid range from %s to %s, it ends before it starts", flags.From, flags.To)
+	}
+
+	var r io.ReadCloser
+	if input == "-" {
+		r, err = decompress(os.Stdin)
+	} else {
+		r, err = openRecording(input)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out := io.Writer(os.Stdout)
+	compression := compressionNone
+	if flags.Path != "-" {
+		file, err := createRecording(flags.Path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, file.Close())
+		}()
+		err = file.Truncate(0)
+		if err != nil {
+			return err
+		}
+		out = file
+		compression = compressionFromPath(flags.Path)
+	}
+	w, err := newCompressWriter(out, compression, 0)
+	if err != nil {
+		return err
+	}
+	if w != nil {
+		out = w
+	}
+
+	scanner := newRecordingScanner(r, flags.Prefix)
+	t := newRecordingTrimmer(out, os.Stderr, flags.From, flags.To, scanner.JSONL)
+	err = scanner.Scan(t.trim)
+	if err == nil {
+		err = t.Close()
+	}
+	if err == nil && w != nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return err
+	}
+	to := "the end"
+	if flags.To != 0 {
+		to = flags.To.String()
+	}
+	fmt.Fprintf(os.Stderr, "trim to %d objects and %d changes from %s to %s\n", t.objects, t.changes, flags.From, to)
+	return nil
+}
+
+// recordingTrimmer writes the documents of a recording within a time range,
+// after the objects as of its start, followed through the documents before it.
+type recordingTrimmer struct {
+	w     io.Writer
+	warn  io.Writer
+	from  time.Duration
+	to    time.Duration
+	jsonl bool
+
+	// start is the time the recording starts at, and offset the time since it of the last bookmark
+	start  time.Time
+	offset time.Duration
+	// bookmark is the last bookmark before the range, written after the objects as of it
+	bookmark []byte
+	// state is the objects before the range by their key, or by their reference for the ones without one, nil for the deleted ones
+	state map[string]*trimObject
+	order []string
+	// started is whether the range has started, after the objects as of it are written
+	started bool
+
+	objects int
+	changes int
+}
+
+// trimObject is an object before the range, the document of its key or the object of a kwok recording.
+type trimObject struct {
+	data []byte
+	obj  *unstructured.Unstructured
+	// patches is the patches to the object of a kwok recording since it is created or checkpointed, applied when it is written
+	patches [][]byte
+}
+
+func newRecordingTrimmer(w, warn io.Writer, from, to time.Duration, jsonl bool) *recordingTrimmer {
+	return &recordingTrimmer{
+		w:     w,
+		warn:  warn,
+		from:  from,
+		to:    to,
+		jsonl: jsonl,
+		state: map[string]*trimObject{},
+	}
+}
+
+func (t *recordingTrimmer) trim(doc *recordingRawDocument) error {
+	switch {
+	case doc.Header:
+		if doc.ClockSkew != nil && t.start.IsZero() {
+			t.start = doc.ClockSkew.Time
+		}
+		// The headers are at the start of the recording, before the objects
+		_, err := t.w.Write(doc.Data)
+		return err
+	case doc.Bookmark != nil:
+		if t.start.IsZero() {
+			t.start = *doc.Bookmark
+		}
+		t.offset = doc.Bookmark.Sub(t.start)
+		if t.offset < t.from {
+			t.bookmark = doc.Data
+			return nil
+		}
+		ok, err := t.within(t.offset)
+		if !ok || err != nil {
+			return err
+		}
+		_, err = t.w.Write(doc.Data)
+		return err
+	case doc.Key != "":
+		if t.offset < t.from {
+			t.set(doc.Key, &trimObject{data: doc.Data}, doc.Deleted)
+			return nil
+		}
+		return t.write(t.offset, doc.Data)
+	case doc.Object != nil:
+		if isKwokResourcePatch(doc.Object) {
+			return t.resourcePatch(doc)
+		}
+		ref, _, err := snapshotObjectRefOf(doc.Object)
+		if err != nil {
+			return err
+		}
+		if t.offset < t.from {
+			t.set(ref.String(), &trimObject{obj: doc.Object}, false)
+			return nil
+		}
+		ok, err := t.within(t.offset)
+		if !ok || err != nil {
+			return err
+		}
+		t.objects++
+		_, err = t.w.Write(doc.Data)
+		return err
+	}
+	return nil
+}
+
+// resourcePatch follows the change of a kwok recording before the range, or writes it moved to start at the range.
+func (t *recordingTrimmer) resourcePatch(doc *recordingRawDocument) error {
+	rp, err := decodeKwokResourcePatch(doc.Object)
+	if err != nil {
+		return err
+	}
+	if rp.DurationNanosecond >= t.from {
+		if t.from == 0 {
+			return t.write(rp.DurationNanosecond, doc.Data)
+		}
+		// The object is changed rather than rp to keep the version of the recording
+		err = unstructured.SetNestedField(doc.Object.Object, int64(rp.DurationNanosecond-t.from), "durationNanosecond")
+		if err != nil {
+			return err
+		}
+		data, err := t.encode(doc.Object)
+		if err != nil {
+			return err
+		}
+		return t.write(rp.DurationNanosecond, data)
+	}
+
+	ref := snapshotObjectRef{
+		gr:        rp.Resource.GroupResource(),
+		namespace: rp.Target.Namespace,
+		name:      rp.Target.Name,
+	}
+	switch rp.Method {
+	case actionv1alpha1.PatchMethodCreate:
+		obj := &unstructured.Unstructured{}
+		err = obj.UnmarshalJSON(rp.Template)
+		if err != nil {
+			return fmt.Errorf("create %s: %w", ref, err)
+		}
+		t.set(ref.String(), &trimObject{obj: obj}, false)
+	case actionv1alpha1.PatchMethodDelete:
+		t.set(ref.String(), nil, true)
+	case actionv1alpha1.PatchMethodPatch:
+		if rp.Checkpoint {
+			// The object starts over from the checkpoint, without the patches before it
+			obj := &unstructured.Unstructured{}
+			err = obj.UnmarshalJSON(rp.Template)
+			if err != nil {
+				return fmt.Errorf("checkpoint %s: %w", ref, err)
+			}
+			t.set(ref.String(), &trimObject{obj: obj}, false)
+			return nil
+		}
+		before := t.state[ref.String()]
+		if before == nil || before.obj == nil {
+			fmt.Fprintf(t.warn, "warning: skip the patch of %s, the object is not known before it\n", ref)
+			return nil
+		}
+		before.patches = append(before.patches, rp.Template)
+	default:
+		return fmt.Errorf("unsupported method %q of %s", rp.Method, ref)
+	}
+	return nil
+}
+
+func (t *recordingTrimmer) set(id string, obj *trimObject, deleted bool) {
+	if _, ok := t.state[id]; !ok {
+		t.order = append(t.order, id)
+	}
+	if deleted {
+		obj = nil
+	}
+	t.state[id] = obj
+}
+
+// write writes a change at the offset, if it is within the range.
+func (t *recordingTrimmer) write(offset time.Duration, data []byte) error {
+	ok, err := t.within(offset)
+	if !ok || err != nil {
+		return err
+	}
+	t.changes++
+	_, err = t.w.Write(data)
+	return err
+}
+
+// within returns whether the offset is within the range, writing the objects as of the range before the first document of it.
+func (t *recordingTrimmer) within(offset time.Duration) (bool, error) {
+	if offset < t.from {
+		return false, nil
+	}
+	err := t.writeState()
+	if err != nil {
+		return false, err
+	}
+	return t.to == 0 || offset <= t.to, nil
+}
+
+func (t *recordingTrimmer) writeState() error {
+	if t.started {
+		return nil
+	}
+	t.started = true
+	for _, id := range t.order {
+		obj := t.state[id]
+		if obj == nil {
+			continue
+		}
+		data := obj.data
+		if obj.obj != nil {
+			for _, patch := range obj.patches {
+				original, err := obj.obj.MarshalJSON()
+				if err != nil {
+					return err
+				}
+				obj.obj, err = applyStrategicPatch(original, patch)
+				if err != nil {
+					return fmt.Errorf("patch %s: %w", id, err)
+				}
+			}
+			var err error
+			data, err = t.encode(obj.obj)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := t.w.Write(data)
+		if err != nil {
+			return err
+		}
+		t.objects++
+	}
+	t.state = nil
+	t.order = nil
+	_, err := t.w.Write(t.bookmark)
+	return err
+}
+
+func (t *recordingTrimmer) encode(obj *unstructured.Unstructured) ([]byte, error) {
+	if t.jsonl {
+		data, err := obj.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+	buf := bytes.NewBuffer(nil)
+	err := writeYAMLDocument(buf, obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Close writes the objects as of the range, if no document is within it.
+func (t *recordingTrimmer) Close() error {
+	return t.writeState()
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// trimDocuments returns the first line of each document of the output, the object of a kwok recording by its name and duration.
+func trimDocuments(t *testing.T, out string) []string {
+	var docs []string
+	err := newRecordingScanner(strings.NewReader(out), "/registry").Scan(func(doc *recordingRawDocument) error {
+		if doc.Object != nil && isKwokResourcePatch(doc.Object) {
+			rp, err := decodeKwokResourcePatch(doc.Object)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, string(rp.Method)+" "+rp.Target.Name+" "+rp.DurationNanosecond.String())
+			return nil
+		}
+		if doc.Object != nil {
+			docs = append(docs, doc.Object.GetName())
+			return nil
+		}
+		line, _, _ := strings.Cut(strings.TrimPrefix(string(doc.Data), "---\n"), "\n")
+		docs = append(docs, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return docs
+}
+
+func TestRecordingTrimmer(t *testing.T) {
+	const recording = `---
+# clock-skew | 10ms | 500ms | 2024-01-01T00:00:00Z
+---
+# /registry/pods/default/a | application/yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+---
+# /registry/pods/default/b | application/yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: b
+  namespace: default
+---
+# bookmark | 3 | 2024-01-01T00:05:00Z
+---
+# /registry/pods/default/a | deleted
+---
+# bookmark | 4 | 2024-01-01T00:10:00Z
+---
+# /registry/pods/default/c | application/yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: c
+  namespace: default
+---
+# bookmark | 5 | 2024-01-01T00:20:00Z
+---
+# /registry/pods/default/b | deleted
+`
+	const kwokRecording = `---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: a
+  namespace: default
+durationNanosecond: 1000
+method: patch
+template:
+  status:
+    phase: Running
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: b
+  namespace: default
+durationNanosecond: 2000
+method: create
+template:
+  apiVersion: v1
+  kind: Pod
+  metadata:
+    name: b
+    namespace: default
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: a
+  namespace: default
+durationNanosecond: 3000
+method: delete
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: b
+  namespace: default
+durationNanosecond: 4000
+method: delete
+`
+	tests := []struct {
+		name  string
+		input string
+		from  time.Duration
+		to    time.Duration
+		want  []string
+	}{
+		{
+			name:  "whole",
+			input: recording,
+			want: []string{
+				"# clock-skew | 10ms | 500ms | 2024-01-01T00:00:00Z",
+				"# /registry/pods/default/a | application/yaml",
+				"# /registry/pods/default/b | application/yaml",
+				"# bookmark | 3 | 2024-01-01T00:05:00Z",
+				"# /registry/pods/default/a | deleted",
+				"# bookmark | 4 | 2024-01-01T00:10:00Z",
+				"# /registry/pods/default/c | application/yaml",
+				"# bookmark | 5 | 2024-01-01T00:20:00Z",
+				"# /registry/pods/default/b | deleted",
+			},
+		},
+		{
+			name:  "window",
+			input: recording,
+			from:  10 * time.Minute,
+			to:    15 * time.Minute,
+			want: []string{
+				"# clock-skew | 10ms | 500ms | 2024-01-01T00:00:00Z",
+				"# /registry/pods/default/b | application/yaml",
+				"# bookmark | 3 | 2024-01-01T00:05:00Z",
+				"# bookmark | 4 | 2024-01-01T00:10:00Z",
+				"# /registry/pods/default/c | application/yaml",
+			},
+		},
+		{
+			name:  "after the end",
+			input: recording,
+			from:  time.Hour,
+			want: []string{
+				"# clock-skew | 10ms | 500ms | 2024-01-01T00:00:00Z",
+				"# /registry/pods/default/c | application/yaml",
+				"# bookmark | 5 | 2024-01-01T00:20:00Z",
+			},
+		},
+		{
+			name:  "kwok",
+			input: kwokRecording,
+			from:  2500 * time.Nanosecond,
+			to:    3500 * time.Nanosecond,
+			want: []string{
+				"a",
+				"b",
+				"delete a 500ns",
+			},
+		},
+		{
+			name:  "kwok whole",
+			input: kwokRecording,
+			want: []string{
+				"a",
+				"patch a 1µs",
+				"create b 2µs",
+				"delete a 3µs",
+				"delete b 4µs",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := bytes.NewBuffer(nil)
+			scanner := newRecordingScanner(strings.NewReader(tt.input), "/registry")
+			trimmer := newRecordingTrimmer(out, io.Discard, tt.from, tt.to, scanner.JSONL)
+			err := scanner.Scan(trimmer.trim)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = trimmer.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := trimDocuments(t, out.String())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordingTrimmerPatchedObject(t *testing.T) {
+	const recording = `---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  namespace: default
+---
+apiVersion: action.kwok.x-k8s.io/v1alpha1
+kind: ResourcePatch
+resource:
+  version: v1
+  resource: pods
+target:
+  name: a
+  namespace: default
+durationNanosecond: 1000
+method: patch
+template:
+  status:
+    phase: Running
+`
+	out := bytes.NewBuffer(nil)
+	trimmer := newRecordingTrimmer(out, io.Discard, 2000, 0, false)
+	err := newRecordingScanner(strings.NewReader(recording), "/registry").Scan(trimmer.trim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = trimmer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "phase: Running") {
+		t.Errorf("want the patched object, got %q", out.String())
+	}
+	if trimmer.objects != 1 || trimmer.changes != 0 {
+		t.Errorf("got %d objects and %d changes, want 1 and 0", trimmer.objects, trimmer.changes)
+	}
+}
+
+func TestRecordingTrimmerCheckpoint(t *testing.T) {
+	patch := func(duration, extra, template string) string {
+		return "---\napiVersion: action.kwok.x-k8s.io/v1alpha1\nkind: ResourcePatch\nresource:\n  version: v1\n  resource: configmaps\ntarget:\n  name: a\n  namespace: default\n" +
+			"durationNanosecond: " + duration + "\nmethod: patch\n" + extra + "template:\n" + template
+	}
+	// The object is only known from the checkpoint, the patch before it is not needed
+	recording := patch("500", "", "  data:\n    key: a0\n") +
+		patch("1000", "checkpoint: true\n", "  apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: a\n    namespace: default\n  data:\n    key: a1\n") +
+		patch("1500", "", "  data:\n    key: a2\n")
+
+	out := bytes.NewBuffer(nil)
+	warn := bytes.NewBuffer(nil)
+	trimmer := newRecordingTrimmer(out, warn, 2000, 0, false)
+	err := newRecordingScanner(strings.NewReader(recording), "/registry").Scan(trimmer.trim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = trimmer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "key: a2") || trimmer.objects != 1 {
+		t.Errorf("want the object patched from the checkpoint, got %q", out.String())
+	}
+	if !strings.Contains(warn.String(), "skip the patch of configmaps/default/a") {
+		t.Errorf("want the patch before the checkpoint warned about, got %q", warn.String())
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/etcd-io/auger/pkg/encoding"
+	"github.com/google/cel-go/cel"
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+// errWatchUntil is returned by the response of a watch once a stop condition is reached.
+var errWatchUntil = errors.New("stop condition reached")
+
+// watchUntil stops a watch once a duration elapses, a number of events are written,
+// or an event has an object matching a CEL predicate, for the watch to end as a list does.
+type watchUntil struct {
+	duration time.Duration
+	count    int
+	object   string
+	program  cel.Program
+
+	events int
+	// reason is why the watch is stopped, once it is
+	reason string
+}
+
+// newWatchUntil returns the stop conditions of a watch, it is nil without any.
+// The predicate has the object of the event as object, the previous one for a deletion, and whether it is deleted as deleted.
+func newWatchUntil(duration time.Duration, count int, object string) (*watchUntil, error) {
+	if duration == 0 && count == 0 && object == "" {
+		return nil, nil
+	}
+	u := &watchUntil{
+		duration: duration,
+		count:    count,
+		object:   object,
+	}
+	if object != "" {
+		var err error
+		u.program, err = compileObjectPredicate("--until-object", object)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// compileObjectPredicate compiles the CEL predicate of the flag, of the object as object and whether it is deleted as deleted.
+func compileObjectPredicate(flag, predicate string) (cel.Program, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("deleted", cel.BoolType),
+	)
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(predicate)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", flag, predicate, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("invalid %s %q: it is %s rather than bool", flag, predicate, ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", flag, predicate, err)
+	}
+	return program, nil
+}
+
+// matchObjectPredicate returns whether the object matches the predicate,
+// the fields missing from the object of another resource do not match.
+func matchObjectPredicate(program cel.Program, object map[string]any, deleted bool) bool {
+	out, _, err := program.Eval(map[string]any{
+		"object":  object,
+		"deleted": deleted,
+	})
+	if err != nil {
+		return false
+	}
+	matched, ok := out.Value().(bool)
+	return ok && matched
+}
+
+// context returns the context of the watch, done once the duration elapses.
+func (u *watchUntil) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if u == nil || u.duration == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, u.duration, errWatchUntil)
+}
+
+// observe checks the stop conditions against the event written, and returns errWatchUntil once one is reached.
+func (u *watchUntil) observe(kv *client.KeyValue) error {
+	if u == nil {
+		return nil
+	}
+	u.events++
+	if u.count != 0 && u.events >= u.count {
+		u.reason = fmt.Sprintf("after %d events", u.events)
+		return errWatchUntil
+	}
+	if u.program != nil {
+		matched, err := u.match(kv)
+		if err != nil {
+			return fmt.Errorf("--until-object %s: %w", kv.Key, err)
+		}
+		if matched {
+			u.reason = fmt.Sprintf("at %s matching --until-object", kv.Key)
+			return errWatchUntil
+		}
+	}
+	return nil
+}
+
+func (u *watchUntil) match(kv *client.KeyValue) (bool, error) {
+	value := kv.Value
+	deleted := len(value) == 0
+	if deleted {
+		value = kv.PrevValue
+	}
+	var object map[string]any
+	if len(value) != 0 {
+		_, data, err := convertValue(value, encoding.JsonMediaType)
+		if err != nil {
+			// The values not decoded do not match any object
+			return false, nil
+		}
+		err = json.Unmarshal(data, &object)
+		if err != nil {
+			return false, nil
+		}
+	}
+	return matchObjectPredicate(u.program, object, deleted), nil
+}
+
+// stopped returns whether the watch that returned the error is stopped by a stop condition,
+// and the error of the watch otherwise.
+func (u *watchUntil) stopped(ctx context.Context, err error) (bool, error) {
+	if u == nil {
+		return false, err
+	}
+	if errors.Is(err, errWatchUntil) {
+		return true, nil
+	}
+	if err == nil && errors.Is(context.Cause(ctx), errWatchUntil) {
+		u.reason = fmt.Sprintf("after %s", u.duration)
+		return true, nil
+	}
+	return false, err
+}
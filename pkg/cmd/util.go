@@ -0,0 +1,143 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wzshiming/kectl/pkg/client"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// splitKeyName returns the namespace and name of the key under the resource key prefix.
+func splitKeyName(keyPrefix, key string) (namespace, name string, err error) {
+	rest, ok := strings.CutPrefix(key, keyPrefix)
+	if !ok {
+		return "", "", fmt.Errorf("key %q is not under %q", key, keyPrefix)
+	}
+	return splitName(rest)
+}
+
+// splitName returns the namespace and name of the rest of key under the resource.
+func splitName(rest string) (namespace, name string, err error) {
+	parts := strings.Split(rest, "/")
+	switch len(parts) {
+	case 1:
+		return "", parts[0], nil
+	case 2:
+		return parts[0], parts[1], nil
+	default:
+		return "", "", fmt.Errorf("invalid name %q", rest)
+	}
+}
+
+var storagePrefixes = func() map[string]schema.GroupResource {
+	m := map[string]schema.GroupResource{}
+	for _, gr := range wellknown.GroupResources() {
+		p, err := client.PrefixFromGR(gr)
+		if err != nil {
+			continue
+		}
+		// The first one wins for the resources sharing the same storage, such as events
+		if _, ok := m[p]; !ok {
+			m[p] = gr
+		}
+	}
+	return m
+}()
+
+// groupResourceFromKey returns the GroupResource and the rest of the key under the prefix.
+func groupResourceFromKey(prefix, key string) (gr schema.GroupResource, rest string, ok bool) {
+	rest, ok = strings.CutPrefix(key, prefix+"/")
+	if !ok {
+		return gr, "", false
+	}
+
+	parts := strings.SplitN(rest, "/", 3)
+	// Some resources are stored under a two-segment prefix, such as services/specs
+	if len(parts) >= 2 {
+		p := parts[0] + "/" + parts[1]
+		if gr, ok := storagePrefixes[p]; ok {
+			return gr, strings.TrimPrefix(rest, p+"/"), true
+		}
+	}
+
+	if gr, ok := storagePrefixes[parts[0]]; ok {
+		return gr, strings.TrimPrefix(rest, parts[0]+"/"), true
+	}
+
+	// Custom resources are stored under group/resource
+	if len(parts) >= 2 && strings.Contains(parts[0], ".") {
+		gr = schema.GroupResource{Group: parts[0], Resource: parts[1]}
+		return gr, strings.TrimPrefix(rest, parts[0]+"/"+parts[1]+"/"), true
+	}
+
+	return schema.GroupResource{Resource: parts[0]}, strings.TrimPrefix(rest, parts[0]+"/"), true
+}
+
+// parseRevisionRange parses the revision range in the form of START-[END].
+func parseRevisionRange(s string) (start, end int64, err error) {
+	startStr, endStr, _ := strings.Cut(s, "-")
+	start, err = strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start <= 0 {
+		return 0, 0, fmt.Errorf("invalid start revision in range %q", s)
+	}
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, fmt.Errorf("invalid end revision in range %q", s)
+		}
+	}
+	return start, end, nil
+}
+
+// consistencyOpOptions returns the options for reads of the consistency, one of l (linearizable) or s (serializable),
+// or their full names.
+// Without one, listings are serializable to reduce the load on the leader and single objects are linearizable.
+func consistencyOpOptions(consistency string, single bool) ([]client.OpOption, error) {
+	switch consistency {
+	case "l", "linearizable":
+		return nil, nil
+	case "s", "serializable":
+		// Served by the member from its local data, without the quorum
+		return []client.OpOption{client.WithSerializable()}, nil
+	case "":
+		if single {
+			return nil, nil
+		}
+		return []client.OpOption{client.WithSerializable()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported consistency: %q", consistency)
+	}
+}
+
+// currentRevision returns the current revision of etcd.
+// The header of any read carries it, so a single key that may not exist is enough.
+func currentRevision(ctx context.Context, etcdclient client.Client, prefix string) (int64, error) {
+	return etcdclient.Get(ctx, prefix,
+		client.WithGR(schema.GroupResource{Resource: "namespaces"}),
+		client.WithName("default", ""),
+		client.WithKeysOnly(),
+		client.WithResponse(func(kv *client.KeyValue) error {
+			return nil
+		}),
+	)
+}
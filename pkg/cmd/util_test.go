@@ -0,0 +1,163 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGroupResourceFromKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		wantGr   schema.GroupResource
+		wantRest string
+		wantOk   bool
+	}{
+		{
+			name:     "pod",
+			key:      "/registry/pods/default/pod",
+			wantGr:   schema.GroupResource{Resource: "pods"},
+			wantRest: "default/pod",
+			wantOk:   true,
+		},
+		{
+			name:     "service",
+			key:      "/registry/services/specs/default/kubernetes",
+			wantGr:   schema.GroupResource{Resource: "services"},
+			wantRest: "default/kubernetes",
+			wantOk:   true,
+		},
+		{
+			name:     "endpoints",
+			key:      "/registry/services/endpoints/default/kubernetes",
+			wantGr:   schema.GroupResource{Resource: "endpoints"},
+			wantRest: "default/kubernetes",
+			wantOk:   true,
+		},
+		{
+			name:     "node",
+			key:      "/registry/minions/node",
+			wantGr:   schema.GroupResource{Resource: "nodes"},
+			wantRest: "node",
+			wantOk:   true,
+		},
+		{
+			name:     "deployment",
+			key:      "/registry/deployments/default/foo",
+			wantGr:   schema.GroupResource{Group: "apps", Resource: "deployments"},
+			wantRest: "default/foo",
+			wantOk:   true,
+		},
+		{
+			name:     "apiservice",
+			key:      "/registry/apiregistration.k8s.io/apiservices/v1.apps",
+			wantGr:   schema.GroupResource{Group: "apiregistration.k8s.io", Resource: "apiservices"},
+			wantRest: "v1.apps",
+			wantOk:   true,
+		},
+		{
+			name:     "cr",
+			key:      "/registry/auger.x-k8s.io/foo/default/bar",
+			wantGr:   schema.GroupResource{Group: "auger.x-k8s.io", Resource: "foo"},
+			wantRest: "default/bar",
+			wantOk:   true,
+		},
+		{
+			name:     "unknown",
+			key:      "/registry/masterleases/10.0.0.1",
+			wantGr:   schema.GroupResource{Resource: "masterleases"},
+			wantRest: "10.0.0.1",
+			wantOk:   true,
+		},
+		{
+			name: "other prefix",
+			key:  "/other/pods/default/pod",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotGr, gotRest, gotOk := groupResourceFromKey("/registry", tt.key)
+			if gotOk != tt.wantOk {
+				t.Fatalf("groupResourceFromKey() gotOk = %v, wantOk %v", gotOk, tt.wantOk)
+			}
+			if gotGr != tt.wantGr {
+				t.Errorf("groupResourceFromKey() gotGr = %v, wantGr %v", gotGr, tt.wantGr)
+			}
+			if gotRest != tt.wantRest {
+				t.Errorf("groupResourceFromKey() gotRest = %v, wantRest %v", gotRest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestConsistencyOpOptions(t *testing.T) {
+	tests := []struct {
+		name             string
+		consistency      string
+		single           bool
+		wantSerializable bool
+		wantErr          bool
+	}{
+		{
+			name:             "default list",
+			wantSerializable: true,
+		},
+		{
+			name:   "default single",
+			single: true,
+		},
+		{
+			name:        "linearizable list",
+			consistency: "l",
+		},
+		{
+			name:             "serializable single",
+			consistency:      "s",
+			single:           true,
+			wantSerializable: true,
+		},
+		{
+			name:             "serializable by name",
+			consistency:      "serializable",
+			single:           true,
+			wantSerializable: true,
+		},
+		{
+			name:        "linearizable by name",
+			consistency: "linearizable",
+		},
+		{
+			name:        "unknown",
+			consistency: "x",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := consistencyOpOptions(tt.consistency, tt.single)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("consistencyOpOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotSerializable := len(got) != 0; gotSerializable != tt.wantSerializable {
+				t.Errorf("consistencyOpOptions() serializable = %v, want %v", gotSerializable, tt.wantSerializable)
+			}
+		})
+	}
+}
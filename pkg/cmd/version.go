@@ -0,0 +1,191 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wzshiming/kectl/pkg/client"
+	oldscheme "github.com/wzshiming/kectl/pkg/old/scheme"
+	"github.com/wzshiming/kectl/pkg/scheme"
+	"github.com/wzshiming/kectl/pkg/wellknown"
+)
+
+type versionFlagpole struct {
+	Output string
+	Server bool
+}
+
+// versionInfo is what kectl is built with, for tools to check the compatibility with a cluster before running.
+type versionInfo struct {
+	Version    string             `json:"version"`
+	GitCommit  string             `json:"gitCommit,omitempty"`
+	GoVersion  string             `json:"goVersion"`
+	EtcdClient string             `json:"etcdClient,omitempty"`
+	Kubernetes kubernetesVersions `json:"kubernetes"`
+	// APIGroupVersions are the group versions the objects can be decoded from
+	APIGroupVersions []string `json:"apiGroupVersions"`
+	// Server is the features negotiated with the etcd server, of --server
+	Server *client.Features `json:"server,omitempty"`
+}
+
+type kubernetesVersions struct {
+	// API is the version of k8s.io/api the types are built from
+	API string `json:"api,omitempty"`
+	// RemovedAPIsSince is the oldest release the removed APIs are kept from
+	RemovedAPIsSince string `json:"removedAPIsSince"`
+	// Wellknown is the version the well-known resources are discovered from
+	Wellknown string `json:"wellknown"`
+}
+
+func newCtlVersionCommand() *cobra.Command {
+	flags := &versionFlagpole{}
+
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Use:   "version",
+		Short: "Prints the version of kectl and the Kubernetes versions it supports",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var server *client.Features
+			if flags.Server {
+				etcdclient, err := clientFromCmd(cmd)
+				if err != nil {
+					return err
+				}
+				server = client.FeaturesOf(etcdclient)
+				if server == nil {
+					return fmt.Errorf("the features of the server are not negotiated, only the ones of etcd are")
+				}
+			}
+			return versionCommand(os.Stdout, flags, server)
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "text", "output format. One of: (text, json).")
+	cmd.Flags().BoolVar(&flags.Server, "server", false, "also print the features negotiated with the etcd server: its version, its authentication and the requests it supports")
+
+	return cmd
+}
+
+func versionCommand(w io.Writer, flags *versionFlagpole, server *client.Features) error {
+	info := getVersionInfo()
+	info.Server = server
+	switch flags.Output {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	case "text":
+		fmt.Fprintf(w, "Version: %s\n", info.Version)
+		if info.GitCommit != "" {
+			fmt.Fprintf(w, "Git commit: %s\n", info.GitCommit)
+		}
+		fmt.Fprintf(w, "Go version: %s\n", info.GoVersion)
+		if info.EtcdClient != "" {
+			fmt.Fprintf(w, "etcd client: %s\n", info.EtcdClient)
+		}
+		fmt.Fprintf(w, "Kubernetes API: %s, with the removed APIs since %s\n", info.Kubernetes.API, info.Kubernetes.RemovedAPIsSince)
+		fmt.Fprintf(w, "Well-known resources: %s\n", info.Kubernetes.Wellknown)
+		fmt.Fprintf(w, "API group versions: %s\n", strings.Join(info.APIGroupVersions, ", "))
+		if server != nil {
+			printServerFeatures(w, server)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %s", flags.Output)
+	}
+}
+
+func getVersionInfo() versionInfo {
+	info := versionInfo{
+		Version:   "(devel)",
+		GoVersion: runtime.Version(),
+		Kubernetes: kubernetesVersions{
+			RemovedAPIsSince: oldscheme.FirstRelease,
+			Wellknown:        wellknown.KubeVersion,
+		},
+		APIGroupVersions: schemeGroupVersions(),
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if bi.Main.Version != "" {
+		info.Version = bi.Main.Version
+	}
+	for _, setting := range bi.Settings {
+		if setting.Key == "vcs.revision" {
+			info.GitCommit = setting.Value
+		}
+	}
+	for _, dep := range bi.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		switch dep.Path {
+		case "k8s.io/api":
+			// The staging modules of Kubernetes are versioned as v0.minor.patch
+			info.Kubernetes.API = strings.Replace(dep.Version, "v0.", "v1.", 1)
+		case "go.etcd.io/etcd/client/v3":
+			info.EtcdClient = dep.Version
+		}
+	}
+	return info
+}
+
+func printServerFeatures(w io.Writer, server *client.Features) {
+	name := "etcd"
+	if server.Kine {
+		name = "kine"
+	}
+	fmt.Fprintf(w, "Server: %s %s\n", name, server.Version)
+	auth := "unknown"
+	if server.AuthEnabled != nil {
+		auth = fmt.Sprintf("%t", *server.AuthEnabled)
+	}
+	fmt.Fprintf(w, "Server authentication enabled: %s\n", auth)
+	fmt.Fprintf(w, "Server requests of progress notifications: %t\n", server.RequestProgress)
+	for _, warning := range server.Warnings {
+		fmt.Fprintf(w, "Server warning: %s\n", warning)
+	}
+}
+
+// schemeGroupVersions returns the group versions known by the scheme.
+func schemeGroupVersions() []string {
+	seen := map[string]struct{}{}
+	for gvk := range scheme.Scheme.AllKnownTypes() {
+		if gvk.Version == "__internal" {
+			continue
+		}
+		seen[gvk.GroupVersion().String()] = struct{}{}
+	}
+	gvs := make([]string, 0, len(seen))
+	for gv := range seen {
+		gvs = append(gvs, gv)
+	}
+	sort.Strings(gvs)
+	return gvs
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/wzshiming/kectl/pkg/client"
+)
+
+func TestVersionCommand(t *testing.T) {
+	var buf bytes.Buffer
+	err := versionCommand(&buf, &versionFlagpole{Output: "json"}, nil)
+	if err != nil {
+		t.Fatalf("versionCommand() error = %v", err)
+	}
+	var info versionInfo
+	err = json.Unmarshal(buf.Bytes(), &info)
+	if err != nil {
+		t.Fatalf("unmarshal version: %v", err)
+	}
+	if info.Kubernetes.Wellknown == "" || info.Kubernetes.RemovedAPIsSince == "" {
+		t.Errorf("missing Kubernetes versions: %+v", info.Kubernetes)
+	}
+	for _, gv := range []string{"v1", "apps/v1", "batch/v2alpha1"} {
+		if !slices.Contains(info.APIGroupVersions, gv) {
+			t.Errorf("missing group version %q in %v", gv, info.APIGroupVersions)
+		}
+	}
+
+	buf.Reset()
+	server := &client.Features{Version: "3.3.27", Warnings: []string{"etcd 3.3.27 does not answer the requests of progress notifications"}}
+	err = versionCommand(&buf, &versionFlagpole{Output: "text"}, server)
+	if err != nil {
+		t.Fatalf("versionCommand() error = %v", err)
+	}
+	for _, want := range []string{"Server: etcd 3.3.27\n", "Server authentication enabled: unknown\n", "Server warning: etcd 3.3.27 does not answer"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in %q", want, buf.String())
+		}
+	}
+
+	err = versionCommand(&buf, &versionFlagpole{Output: "yaml"}, nil)
+	if err == nil {
+		t.Errorf("versionCommand() expected an error for an unsupported output")
+	}
+}
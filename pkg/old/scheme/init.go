@@ -20,6 +20,9 @@ import (
 	"github.com/wzshiming/kectl/pkg/scheme"
 )
 
+// FirstRelease is the oldest minor release of Kubernetes whose removed APIs are cloned by hack/clone_old_apis.sh.
+const FirstRelease = "1.18"
+
 func init() {
 	AddToScheme(scheme.Scheme)
 }
@@ -0,0 +1,76 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheme
+
+import (
+	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// addConversionFuncs adds the conversions between the versions of the groups whose objects are migrated,
+// as the conversions of the apiserver go through the internal versions that k8s.io/api does not have.
+func addConversionFuncs(scheme *runtime.Scheme) error {
+	return scheme.AddConversionFunc((*policyv1beta1.PodDisruptionBudget)(nil), (*policyv1.PodDisruptionBudget)(nil), func(a, b any, scope conversion.Scope) error {
+		return convertPodDisruptionBudgetV1beta1ToV1(a.(*policyv1beta1.PodDisruptionBudget), b.(*policyv1.PodDisruptionBudget))
+	})
+}
+
+// The selectors standing for the empty selector of policy/v1beta1, which selects no pods rather than all of them as in policy/v1,
+// as the apiserver converts them.
+var (
+	pdbV1beta1MatchNoneSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "pdb.kubernetes.io/deprecated-v1beta1-empty-selector-match",
+			Operator: metav1.LabelSelectorOpExists,
+		}},
+	}
+	pdbV1beta1MatchAllSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "pdb.kubernetes.io/deprecated-v1beta1-empty-selector-match",
+			Operator: metav1.LabelSelectorOpDoesNotExist,
+		}},
+	}
+)
+
+func convertPodDisruptionBudgetV1beta1ToV1(in *policyv1beta1.PodDisruptionBudget, out *policyv1.PodDisruptionBudget) error {
+	out.TypeMeta = metav1.TypeMeta{
+		APIVersion: policyv1.SchemeGroupVersion.String(),
+		Kind:       "PodDisruptionBudget",
+	}
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+
+	out.Spec = policyv1.PodDisruptionBudgetSpec{
+		MinAvailable:               in.Spec.MinAvailable,
+		Selector:                   in.Spec.Selector.DeepCopy(),
+		MaxUnavailable:             in.Spec.MaxUnavailable,
+		UnhealthyPodEvictionPolicy: (*policyv1.UnhealthyPodEvictionPolicyType)(in.Spec.UnhealthyPodEvictionPolicy),
+	}
+	switch {
+	case in.Spec.Selector == nil:
+	case equality.Semantic.DeepEqual(in.Spec.Selector, &metav1.LabelSelector{}):
+		out.Spec.Selector = pdbV1beta1MatchNoneSelector.DeepCopy()
+	case equality.Semantic.DeepEqual(in.Spec.Selector, pdbV1beta1MatchAllSelector):
+		out.Spec.Selector = &metav1.LabelSelector{}
+	}
+
+	in.Status.DeepCopyInto((*policyv1beta1.PodDisruptionBudgetStatus)(&out.Status))
+	return nil
+}
@@ -29,4 +29,12 @@ var Codecs = serializer.NewCodecFactory(Scheme)
 func init() {
 	v1.AddToGroupVersion(Scheme, schema.GroupVersion{Version: "v1"})
 	AddToScheme(Scheme)
+	_ = addConversionFuncs(Scheme)
+}
+
+// Register adds the types of groups that are not part of k8s.io/api into the Scheme,
+// so that they are decoded like the built-in ones.
+func Register(addToSchemes ...func(*runtime.Scheme) error) error {
+	builder := runtime.NewSchemeBuilder(addToSchemes...)
+	return builder.AddToScheme(Scheme)
 }
@@ -23,6 +23,10 @@ type resource struct {
 }
 
 // Don't edit this file directly. It is generated by hack/gen_wellknown_resources
+
+// KubeVersion is the version of Kubernetes the resources are discovered from.
+const KubeVersion = "v1.30.3"
+
 var resources = []resource{
 	{
 		Names: []string{
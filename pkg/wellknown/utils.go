@@ -51,3 +51,15 @@ func CorrectGroupResource(target schema.GroupResource) (gr schema.GroupResource,
 	}
 	return gr, r.Namespaced, true
 }
+
+// GroupResources returns all the well-known GroupResource
+func GroupResources() []schema.GroupResource {
+	grs := make([]schema.GroupResource, 0, len(resources))
+	for _, r := range resources {
+		grs = append(grs, schema.GroupResource{
+			Group:    r.Group,
+			Resource: r.Names[0],
+		})
+	}
+	return grs
+}